@@ -0,0 +1,224 @@
+package aferofs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/aferofs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/suite"
+)
+
+func writeString(fsys filestore.FS, name string, content string) error {
+	file, err := fsys.Write(name)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write([]byte(content)); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func readString(fsys filestore.FS, name string) (string, error) {
+	file, err := fsys.Read(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	return string(data), err
+}
+
+type FromAferoTestSuite struct {
+	suite.Suite
+	afs afero.Fs
+	fs  filestore.FS
+}
+
+func TestFromAferoTestSuite(t *testing.T) {
+	suite.Run(t, &FromAferoTestSuite{})
+}
+
+func (s *FromAferoTestSuite) SetupTest() {
+	s.afs = afero.NewMemMapFs()
+	s.Require().NoError(afero.WriteFile(s.afs, "1.lebowski", []byte("jeff"), 0644))
+	s.Require().NoError(afero.WriteFile(s.afs, "duderino/5.lebowski", []byte("jackie"), 0644))
+	s.Require().NoError(afero.WriteFile(s.afs, "duderino/inner/6.lebowski", []byte("nihilist"), 0644))
+	s.fs = aferofs.FromAfero(s.afs)
+}
+
+func (s *FromAferoTestSuite) TestStat() {
+	info, err := s.fs.Stat("duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("5.lebowski", info.Name())
+	s.Require().Equal(int64(6), info.Size())
+
+	info, err = s.fs.Stat("duderino")
+	s.Require().NoError(err)
+	s.Require().True(info.IsDir())
+
+	_, err = s.fs.Stat("nope")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	s.Require().False(s.fs.Exists("nope"))
+}
+
+func (s *FromAferoTestSuite) TestReadWrite() {
+	content, err := readString(s.fs, "duderino/inner/6.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("nihilist", content)
+
+	_, err = s.fs.Read("duderino")
+	s.Require().Error(err)
+
+	s.Require().NoError(writeString(s.fs.ChangeDirectory("walter"), "sobchak/2.lebowski", "walter"))
+	data, err := afero.ReadFile(s.afs, "walter/sobchak/2.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("walter", string(data))
+
+	s.Require().NoError(writeString(s.fs, "1.lebowski", "the"))
+	content, err = readString(s.fs, "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("the", content, "Writes should truncate existing files")
+}
+
+func (s *FromAferoTestSuite) TestList() {
+	infos, err := s.fs.List("duderino", filestore.WithExt("lebowski"))
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(infos))
+	s.Require().Equal("5.lebowski", infos[0].Name())
+
+	infos, err = s.fs.List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(infos))
+	s.Require().Equal("1.lebowski", infos[0].Name())
+	s.Require().Equal("duderino", infos[1].Name())
+
+	infos, err = s.fs.List("nope")
+	s.Require().NoError(err)
+	s.Require().Empty(infos)
+}
+
+func (s *FromAferoTestSuite) TestMoveRemove() {
+	s.Require().NoError(s.fs.Move("duderino", "dude/duderino"))
+	s.Require().True(s.fs.Exists("dude/duderino/inner/6.lebowski"))
+	s.Require().False(s.fs.Exists("duderino"))
+	s.Require().Error(s.fs.Move("nope", "other"))
+
+	s.Require().NoError(s.fs.Remove("dude"))
+	s.Require().False(s.fs.Exists("dude/duderino/5.lebowski"))
+}
+
+func (s *FromAferoTestSuite) TestCopyAll() {
+	dst := filestore.Memory()
+	s.Require().NoError(filestore.CopyAll(s.fs, ".", dst, "backup"))
+	content, err := readString(dst, "backup/duderino/inner/6.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("nihilist", content)
+}
+
+type ToAferoTestSuite struct {
+	suite.Suite
+	store filestore.FS
+	fs    afero.Fs
+}
+
+func TestToAferoTestSuite(t *testing.T) {
+	suite.Run(t, &ToAferoTestSuite{})
+}
+
+func (s *ToAferoTestSuite) SetupTest() {
+	s.store = filestore.Memory()
+	s.Require().NoError(writeString(s.store, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.store, "duderino/5.lebowski", "jackie"))
+	s.Require().NoError(writeString(s.store, "duderino/inner/6.lebowski", "nihilist"))
+	s.fs = aferofs.ToAfero(s.store)
+}
+
+func (s *ToAferoTestSuite) TestReadWrite() {
+	data, err := afero.ReadFile(s.fs, "/duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jackie", string(data))
+
+	s.Require().NoError(afero.WriteFile(s.fs, "walter/2.lebowski", []byte("walter"), 0644))
+	content, err := readString(s.store, "walter/2.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("walter", content)
+
+	_, err = s.fs.Open("nope")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = s.fs.OpenFile("1.lebowski", os.O_RDWR, 0644)
+	s.Require().True(errors.Is(err, aferofs.ErrNotSupported))
+	_, err = s.fs.OpenFile("1.lebowski", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	s.Require().True(errors.Is(err, fs.ErrExist))
+	_, err = s.fs.OpenFile("nope", os.O_WRONLY, 0644)
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+}
+
+func (s *ToAferoTestSuite) TestOpenFile_append() {
+	file, err := s.fs.OpenFile("1.lebowski", os.O_WRONLY|os.O_APPEND, 0644)
+	s.Require().NoError(err)
+	_, err = file.WriteString(" lebowski")
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+
+	file, err = s.fs.OpenFile("duderino/5.lebowski", os.O_WRONLY, 0644)
+	s.Require().NoError(err)
+	_, err = file.WriteString("J")
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+
+	content, err := readString(s.store, "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jeff lebowski", content)
+	content, err = readString(s.store, "duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("Jackie", content, "Writing w/o O_TRUNC should only overwrite the bytes written")
+}
+
+func (s *ToAferoTestSuite) TestReaddir() {
+	dir, err := s.fs.Open("duderino")
+	s.Require().NoError(err)
+	defer dir.Close()
+
+	names, err := dir.Readdirnames(1)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"5.lebowski"}, names)
+	names, err = dir.Readdirnames(-1)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"inner"}, names)
+	_, err = dir.Readdirnames(1)
+	s.Require().Equal(io.EOF, err)
+
+	var visited []string
+	err = afero.Walk(s.fs, ".", func(filePath string, _ os.FileInfo, err error) error {
+		visited = append(visited, filePath)
+		return err
+	})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{".", "1.lebowski", "duderino", "duderino/5.lebowski", "duderino/inner", "duderino/inner/6.lebowski"}, visited)
+}
+
+func (s *ToAferoTestSuite) TestRemoveRename() {
+	s.Require().Error(s.fs.Remove("duderino"), "Remove should not delete non-empty directories")
+	s.Require().NoError(s.fs.Remove("duderino/inner/6.lebowski"))
+	s.Require().False(s.store.Exists("duderino/inner/6.lebowski"))
+
+	s.Require().NoError(s.fs.Rename("duderino", "dude"))
+	s.Require().True(s.store.Exists("dude/5.lebowski"))
+
+	s.Require().NoError(s.fs.RemoveAll("dude"))
+	s.Require().False(s.store.Exists("dude"))
+	s.Require().NoError(s.fs.RemoveAll("nope"), "RemoveAll should ignore missing files")
+}
+
+func (s *ToAferoTestSuite) TestDirectories() {
+	s.Require().NoError(s.fs.MkdirAll("walter/sobchak", 0755))
+	s.Require().Error(s.fs.Mkdir("duderino", 0755))
+	s.Require().Error(s.fs.MkdirAll("1.lebowski/nope", 0755), "Should not create directories inside files")
+	s.Require().Error(s.fs.Chmod("1.lebowski", 0600))
+}
@@ -0,0 +1,204 @@
+// Package aferofs bridges filestore and afero (github.com/spf13/afero) so that you can hand any of
+// filestore's stores to libraries that expect an afero.Fs, and use any afero.Fs (SFTP, GCS, etc.)
+// anywhere you'd use a filestore.FS.
+package aferofs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/monadicstack/filestore"
+	"github.com/spf13/afero"
+)
+
+// FromAfero adapts an afero.Fs so that you can use it as a filestore.FS. Paths are relative to the
+// root of the afero.Fs (or whatever it considers its working directory), and just like DiskFS, writing
+// a file lazily creates the directories it lives in.
+//
+// Example:
+//
+//	files := aferofs.FromAfero(afero.NewBasePathFs(sftpFs, "/exports"))
+//	err := filestore.CopyAll(files, "reports", bucket, "reports")
+func FromAfero(afs afero.Fs) *AferoFS {
+	return &AferoFS{fs: afs, basePath: "."}
+}
+
+// AferoFS is a filestore.FS whose operations are performed by an afero.Fs.
+type AferoFS struct {
+	fs       afero.Fs
+	basePath string
+}
+
+// resolve converts a path relative to this FS' working directory into the name we hand to afero.
+func (a AferoFS) resolve(filePath string) (string, error) {
+	if strings.ContainsRune(filePath, 0) {
+		return "", fmt.Errorf("invalid path: %q", filePath)
+	}
+	return path.Clean(path.Join(a.basePath, filepath.ToSlash(filePath))), nil
+}
+
+// WorkingDirectory returns the current FS context's path/directory.
+func (a AferoFS) WorkingDirectory() string {
+	return a.basePath
+}
+
+// ChangeDirectory returns a new FS that is rooted in the given subdirectory of this FS.
+func (a AferoFS) ChangeDirectory(dir string) filestore.FS {
+	return &AferoFS{fs: a.fs, basePath: path.Clean(path.Join(a.basePath, filepath.ToSlash(dir)))}
+}
+
+// Stat fetches metadata about the file w/o actually opening it for reading/writing.
+func (a AferoFS) Stat(filePath string) (filestore.FileInfo, error) {
+	name, err := a.resolve(filePath)
+	if err != nil {
+		return nil, pathError("stat", filePath, err)
+	}
+	info, err := a.fs.Stat(name)
+	if err != nil {
+		return nil, pathError("stat", filePath, err)
+	}
+	return info, nil
+}
+
+// Exists returns true when the file/directory already exits in the file system.
+func (a AferoFS) Exists(filePath string) bool {
+	_, err := a.Stat(filePath)
+	return err == nil
+}
+
+// Read opens the file at the given path for reading.
+func (a AferoFS) Read(filePath string) (filestore.ReaderFile, error) {
+	name, err := a.resolve(filePath)
+	if err != nil {
+		return nil, pathError("open", filePath, err)
+	}
+	info, err := a.fs.Stat(name)
+	if err != nil {
+		return nil, pathError("open", filePath, err)
+	}
+	if info.IsDir() {
+		return nil, pathError("open", filePath, errIsDirectory)
+	}
+	file, err := a.fs.Open(name)
+	if err != nil {
+		return nil, pathError("open", filePath, err)
+	}
+	return file, nil
+}
+
+// Write opens the file at the given path for writing, creating it (and its directory) if it doesn't
+// exist yet or truncating it if it does.
+func (a AferoFS) Write(filePath string) (filestore.WriterFile, error) {
+	name, err := a.resolve(filePath)
+	if err != nil {
+		return nil, pathError("write", filePath, err)
+	}
+	if err := a.fs.MkdirAll(path.Dir(name), 0755); err != nil {
+		return nil, pathError("write", filePath, err)
+	}
+	file, err := a.fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, pathError("write", filePath, err)
+	}
+	return file, nil
+}
+
+// List performs the equivalent of the "ls" command. It returns a slice of
+// all files and directories found in the target dirPath.
+//
+// You can optionally provide a set of filters to limit which files/directories
+// are included in the final set.
+func (a AferoFS) List(dirPath string, filters ...filestore.FileFilter) ([]filestore.FileInfo, error) {
+	name, err := a.resolve(dirPath)
+	if err != nil {
+		return nil, pathError("list files", dirPath, err)
+	}
+	infos, err := afero.ReadDir(a.fs, name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, pathError("list files", dirPath, err)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	var results []filestore.FileInfo
+	for _, info := range infos {
+		matches, err := matchesFilters(info, filters)
+		if err != nil {
+			return nil, pathError("list files", dirPath, err)
+		}
+		if matches {
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// Remove deletes the given file/directory and any of its children.
+func (a AferoFS) Remove(fileOrDirPath string) error {
+	name, err := a.resolve(fileOrDirPath)
+	if err != nil {
+		return pathError("remove", fileOrDirPath, err)
+	}
+	if err := a.fs.RemoveAll(name); err != nil {
+		return pathError("remove", fileOrDirPath, err)
+	}
+	return nil
+}
+
+// Move takes the existing file/directory at fromPath and moves it to toPath, lazily creating
+// toPath's parent directory if necessary.
+func (a AferoFS) Move(fromPath string, toPath string) error {
+	from, err := a.resolve(fromPath)
+	if err != nil {
+		return pathError("move", fromPath, err)
+	}
+	to, err := a.resolve(toPath)
+	if err != nil {
+		return pathError("move", toPath, err)
+	}
+	if _, err := a.fs.Stat(from); err != nil {
+		return pathError("move", fromPath, err)
+	}
+	if err := a.fs.MkdirAll(path.Dir(to), 0755); err != nil {
+		return pathError("move", toPath, err)
+	}
+	if err := a.fs.Rename(from, to); err != nil {
+		return pathError("move", fromPath, err)
+	}
+	return nil
+}
+
+// pathError creates the structured error for an operation that failed on the afero.Fs.
+func pathError(op string, filePath string, err error) error {
+	return &filestore.PathError{Backend: "afero", Op: op, Path: filePath, Err: err}
+}
+
+// errIsDirectory is the cause of errors when you try to read a directory as if it were a file.
+var errIsDirectory = errors.New("is a directory")
+
+// matchesFilters determines whether the file makes it through all of the filters, failing w/ a
+// *filestore.PanicError rather than crashing if one of them panics.
+func matchesFilters(info fs.FileInfo, filters []filestore.FileFilter) (matches bool, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &filestore.PanicError{Value: value, Stack: debug.Stack()}
+		}
+	}()
+	for _, filter := range filters {
+		if !filter(info) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+var _ filestore.FS = AferoFS{}
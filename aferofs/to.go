@@ -0,0 +1,353 @@
+package aferofs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/spf13/afero"
+)
+
+// ErrNotSupported is the error returned by operations that have no filestore.FS equivalent, such as
+// changing a file's permissions or owner.
+var ErrNotSupported = errors.New("aferofs: operation not supported")
+
+// ToAfero adapts a filestore.FS so that you can hand it to libraries that expect an afero.Fs. Names are
+// relative to the store's working directory (a leading "/" refers to the working directory as well).
+//
+// Keep in mind that many stores (e.g. S3) don't have real directories; a directory exists as long as
+// there are files in it. Mkdir() and MkdirAll() succeed, but the directory won't show up until you write
+// a file into it. Files can be opened for reading or for writing, but not both; O_RDWR is only allowed
+// along w/ O_TRUNC (like Create() does), and the resulting file is write-only. Since stores have no
+// notion of permissions, ownership, or access times, Chmod(), Chown(), and Chtimes() fail w/
+// ErrNotSupported.
+//
+// Example:
+//
+//	bucket := aferofs.ToAfero(filestore.S3("my-bucket"))
+//	err := afero.WriteFile(bucket, "reports/summary.csv", data, 0644)
+//	...
+//	err = afero.Walk(bucket, "reports", visit)
+func ToAfero(fsys filestore.FS) afero.Fs {
+	return &storeFs{fs: fsys}
+}
+
+// storeFs is the afero.Fs view of a filestore.FS.
+type storeFs struct {
+	fs filestore.FS
+}
+
+// name converts an afero name into a path relative to the store's working directory.
+func name(fileName string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(fileName)), "/")
+}
+
+func (s *storeFs) Name() string {
+	return "filestore"
+}
+
+func (s *storeFs) Create(fileName string) (afero.File, error) {
+	return s.OpenFile(fileName, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Mkdir fails if something already exists at the given name. Otherwise, it does nothing since stores
+// create directories on demand as you write files into them.
+func (s *storeFs) Mkdir(fileName string, _ os.FileMode) error {
+	if s.fs.Exists(name(fileName)) {
+		return &os.PathError{Op: "mkdir", Path: fileName, Err: fs.ErrExist}
+	}
+	return nil
+}
+
+// MkdirAll only fails if a file (not a directory) already exists at the given name or one of its
+// parents. Otherwise, it does nothing since stores create directories on demand as you write files
+// into them.
+func (s *storeFs) MkdirAll(fileName string, _ os.FileMode) error {
+	for dir := name(fileName); dir != "" && dir != "."; dir = path.Dir(dir) {
+		if info, err := s.fs.Stat(dir); err == nil {
+			if !info.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: fileName, Err: fmt.Errorf("not a directory")}
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *storeFs) Open(fileName string) (afero.File, error) {
+	return s.OpenFile(fileName, os.O_RDONLY, 0)
+}
+
+// OpenFile opens the file for reading (O_RDONLY) or writing (O_WRONLY). When writing w/o O_TRUNC, we
+// start w/ a copy of the existing contents so that you only overwrite the bytes you write, and O_APPEND
+// starts writing at the end of them.
+func (s *storeFs) OpenFile(fileName string, flag int, _ os.FileMode) (afero.File, error) {
+	filePath := name(fileName)
+	info, err := s.fs.Stat(filePath)
+	exists := err == nil
+
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: fileName, Err: err}
+		}
+		if info.IsDir() {
+			return &dirFile{fs: s.fs, name: fileName, path: filePath, info: info}, nil
+		}
+		file, err := s.fs.Read(filePath)
+		if err != nil {
+			return nil, &os.PathError{Op: "open", Path: fileName, Err: err}
+		}
+		return &readerFile{ReaderFile: file, name: fileName, info: info}, nil
+	}
+
+	switch {
+	case flag&os.O_RDWR != 0 && flag&os.O_TRUNC == 0:
+		// We could support this, but it would mean reading the whole file into memory and writing it all
+		// back out again, which could be really surprising for large remote files.
+		return nil, &os.PathError{Op: "open", Path: fileName, Err: fmt.Errorf("read/write access: %w", ErrNotSupported)}
+	case exists && info.IsDir():
+		return nil, &os.PathError{Op: "open", Path: fileName, Err: fmt.Errorf("is a directory")}
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: fileName, Err: fs.ErrExist}
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: fileName, Err: fs.ErrNotExist}
+	}
+
+	// Grab the existing contents before Write() truncates the file.
+	var existing []byte
+	if exists && flag&os.O_TRUNC == 0 {
+		if existing, err = s.readAll(filePath); err != nil {
+			return nil, &os.PathError{Op: "open", Path: fileName, Err: err}
+		}
+	}
+	file, err := s.fs.Write(filePath)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: fileName, Err: err}
+	}
+	if len(existing) > 0 {
+		if _, err := file.Write(existing); err != nil {
+			_ = file.Close()
+			return nil, &os.PathError{Op: "open", Path: fileName, Err: err}
+		}
+		if flag&os.O_APPEND == 0 {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				_ = file.Close()
+				return nil, &os.PathError{Op: "open", Path: fileName, Err: err}
+			}
+		}
+	}
+	return &writerFile{WriterFile: file, fs: s.fs, name: fileName, path: filePath}, nil
+}
+
+func (s *storeFs) readAll(filePath string) ([]byte, error) {
+	file, err := s.fs.Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// Remove deletes a single file or empty directory, just like os.Remove().
+func (s *storeFs) Remove(fileName string) error {
+	filePath := name(fileName)
+	info, err := s.fs.Stat(filePath)
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: fileName, Err: err}
+	}
+	if info.IsDir() {
+		infos, err := s.fs.List(filePath)
+		if err != nil {
+			return &os.PathError{Op: "remove", Path: fileName, Err: err}
+		}
+		if len(infos) > 0 {
+			return &os.PathError{Op: "remove", Path: fileName, Err: fmt.Errorf("directory not empty")}
+		}
+	}
+	if err := s.fs.Remove(filePath); err != nil {
+		return &os.PathError{Op: "remove", Path: fileName, Err: err}
+	}
+	return nil
+}
+
+// RemoveAll deletes the file/directory and everything in it. It's not an error if it doesn't exist.
+func (s *storeFs) RemoveAll(fileName string) error {
+	filePath := name(fileName)
+	if !s.fs.Exists(filePath) {
+		return nil
+	}
+	if err := s.fs.Remove(filePath); err != nil {
+		return &os.PathError{Op: "remove", Path: fileName, Err: err}
+	}
+	return nil
+}
+
+func (s *storeFs) Rename(oldName string, newName string) error {
+	if err := s.fs.Move(name(oldName), name(newName)); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: err}
+	}
+	return nil
+}
+
+func (s *storeFs) Stat(fileName string) (os.FileInfo, error) {
+	info, err := s.fs.Stat(name(fileName))
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: fileName, Err: err}
+	}
+	return info, nil
+}
+
+func (s *storeFs) Chmod(fileName string, _ os.FileMode) error {
+	return &os.PathError{Op: "chmod", Path: fileName, Err: ErrNotSupported}
+}
+
+func (s *storeFs) Chown(fileName string, _ int, _ int) error {
+	return &os.PathError{Op: "chown", Path: fileName, Err: ErrNotSupported}
+}
+
+func (s *storeFs) Chtimes(fileName string, _ time.Time, _ time.Time) error {
+	return &os.PathError{Op: "chtimes", Path: fileName, Err: ErrNotSupported}
+}
+
+// readerFile is a file opened for reading.
+type readerFile struct {
+	filestore.ReaderFile
+	name string
+	info os.FileInfo
+}
+
+func (f *readerFile) Name() string               { return f.name }
+func (f *readerFile) Stat() (os.FileInfo, error) { return f.info, nil }
+func (f *readerFile) Sync() error                { return nil }
+func (f *readerFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, f.err("readdir", "not a directory")
+}
+func (f *readerFile) Readdirnames(int) ([]string, error) {
+	return nil, f.err("readdir", "not a directory")
+}
+func (f *readerFile) Write([]byte) (int, error) { return 0, f.err("write", "opened for reading") }
+func (f *readerFile) WriteAt([]byte, int64) (int, error) {
+	return 0, f.err("write", "opened for reading")
+}
+func (f *readerFile) WriteString(string) (int, error) { return 0, f.err("write", "opened for reading") }
+func (f *readerFile) Truncate(int64) error            { return f.err("truncate", "opened for reading") }
+
+func (f *readerFile) err(op string, message string) error {
+	return &os.PathError{Op: op, Path: f.name, Err: errors.New(message)}
+}
+
+// writerFile is a file opened for writing.
+type writerFile struct {
+	filestore.WriterFile
+	fs   filestore.FS
+	name string
+	path string
+}
+
+func (f *writerFile) Name() string { return f.name }
+func (f *writerFile) Sync() error  { return nil }
+func (f *writerFile) Readdir(int) ([]os.FileInfo, error) {
+	return nil, f.err("readdir", "not a directory")
+}
+func (f *writerFile) Readdirnames(int) ([]string, error) {
+	return nil, f.err("readdir", "not a directory")
+}
+func (f *writerFile) Read([]byte) (int, error) { return 0, f.err("read", "opened for writing") }
+func (f *writerFile) ReadAt([]byte, int64) (int, error) {
+	return 0, f.err("read", "opened for writing")
+}
+func (f *writerFile) Truncate(int64) error { return f.err("truncate", ErrNotSupported.Error()) }
+
+func (f *writerFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// Stat describes the file as it currently exists in the store, which won't reflect what you've written
+// until you close the file for many stores.
+func (f *writerFile) Stat() (os.FileInfo, error) {
+	info, err := f.fs.Stat(f.path)
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: f.name, Err: err}
+	}
+	return info, nil
+}
+
+func (f *writerFile) err(op string, message string) error {
+	return &os.PathError{Op: op, Path: f.name, Err: errors.New(message)}
+}
+
+// dirFile is a directory opened for reading its entries.
+type dirFile struct {
+	fs      filestore.FS
+	name    string
+	path    string
+	info    os.FileInfo
+	entries []os.FileInfo
+	listed  bool
+	offset  int
+}
+
+func (d *dirFile) Name() string                       { return d.name }
+func (d *dirFile) Stat() (os.FileInfo, error)         { return d.info, nil }
+func (d *dirFile) Sync() error                        { return nil }
+func (d *dirFile) Close() error                       { return nil }
+func (d *dirFile) Read([]byte) (int, error)           { return 0, d.err("read") }
+func (d *dirFile) ReadAt([]byte, int64) (int, error)  { return 0, d.err("read") }
+func (d *dirFile) Seek(int64, int) (int64, error)     { return 0, d.err("seek") }
+func (d *dirFile) Write([]byte) (int, error)          { return 0, d.err("write") }
+func (d *dirFile) WriteAt([]byte, int64) (int, error) { return 0, d.err("write") }
+func (d *dirFile) WriteString(string) (int, error)    { return 0, d.err("write") }
+func (d *dirFile) Truncate(int64) error               { return d.err("truncate") }
+
+func (d *dirFile) err(op string) error {
+	return &os.PathError{Op: op, Path: d.name, Err: errors.New("is a directory")}
+}
+
+// Readdir returns the next 'count' entries in the directory, following the rules of os.File.Readdir().
+func (d *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.listed {
+		infos, err := d.fs.List(d.path)
+		if err != nil {
+			return nil, &os.PathError{Op: "readdir", Path: d.name, Err: err}
+		}
+		d.entries, d.listed = make([]os.FileInfo, len(infos)), true
+		for i, info := range infos {
+			d.entries[i] = info
+		}
+	}
+
+	remaining := d.entries[d.offset:]
+	if count <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	d.offset += count
+	return remaining[:count], nil
+}
+
+func (d *dirFile) Readdirnames(count int) ([]string, error) {
+	infos, err := d.Readdir(count)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+var _ afero.Fs = &storeFs{}
+var _ afero.File = &readerFile{}
+var _ afero.File = &writerFile{}
+var _ afero.File = &dirFile{}
@@ -0,0 +1,101 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// AgeEncrypted wraps a file store so that every file is encrypted for the given age recipients (public keys)
+// as you Write() it, and decrypted using the given identities (private keys) as you Read() it. Unlike
+// Encrypted(), nobody shares a secret key: a service that only writes files just needs the recipients, and
+// only the holders of one of the matching identities can read them back. Either list may be empty if this
+// store only ever writes or reads files; reading w/o any identities (or writing w/o recipients) fails.
+//
+// The files are standard age files, so you can decrypt them w/ the age CLI, too. Much like Compressed(),
+// files are encrypted/decrypted as a stream, so you can only write them sequentially, and seeking backwards
+// while reading means decrypting the file from the beginning. Stat() and List() report the encrypted sizes,
+// although List() filters see the decrypted ones.
+//
+// Example:
+//
+//	// The service that uploads reports can't read anyone else's.
+//	auditor, err := age.ParseX25519Recipient("age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p")
+//	...
+//	reports := filestore.AgeEncrypted(filestore.S3("reports"), []age.Recipient{auditor}, nil)
+//	output, err := reports.Write("2022/q3.pdf")
+func AgeEncrypted(fs FS, recipients []age.Recipient, identities []age.Identity) FS {
+	return &compressedFS{FS: fs, compression: ageCompression{recipients: recipients, identities: identities}, name: "age encrypted"}
+}
+
+func init() {
+	RegisterLayer("age_encrypted", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Recipients   []string `json:"recipients"`
+			Identities   []string `json:"identities"`
+			IdentityFile string   `json:"identityFile"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		if len(settings.Recipients) == 0 && len(settings.Identities) == 0 && settings.IdentityFile == "" {
+			return nil, fmt.Errorf("age encrypted: recipients or identities are required")
+		}
+
+		var recipients []age.Recipient
+		if len(settings.Recipients) > 0 {
+			parsed, err := age.ParseRecipients(strings.NewReader(strings.Join(settings.Recipients, "\n")))
+			if err != nil {
+				return nil, fmt.Errorf("age encrypted: %w", err)
+			}
+			recipients = parsed
+		}
+
+		identityData := strings.Join(settings.Identities, "\n")
+		if settings.IdentityFile != "" {
+			data, err := os.ReadFile(settings.IdentityFile)
+			if err != nil {
+				return nil, fmt.Errorf("age encrypted: %w", err)
+			}
+			identityData += "\n" + string(data)
+		}
+		var identities []age.Identity
+		if strings.TrimSpace(identityData) != "" {
+			parsed, err := age.ParseIdentities(strings.NewReader(identityData))
+			if err != nil {
+				return nil, fmt.Errorf("age encrypted: %w", err)
+			}
+			identities = parsed
+		}
+		return AgeEncrypted(fs, recipients, identities), nil
+	})
+}
+
+// ageCompression plugs age into the same streaming machinery that Compressed() uses; it's not actually
+// compression, but it's the same idea: transform the data on its way in and undo it on its way out.
+type ageCompression struct {
+	recipients []age.Recipient
+	identities []age.Identity
+}
+
+func (c ageCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if len(c.recipients) == 0 {
+		return nil, errors.New("no recipients to encrypt for")
+	}
+	return age.Encrypt(w, c.recipients...)
+}
+
+func (c ageCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	if len(c.identities) == 0 {
+		return nil, errors.New("no identities to decrypt with")
+	}
+	decrypted, err := age.Decrypt(r, c.identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(decrypted), nil
+}
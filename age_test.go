@@ -0,0 +1,118 @@
+package filestore_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type AgeEncryptedTestSuite struct {
+	suite.Suite
+	identity *age.X25519Identity
+	inner    filestore.FS
+	writer   filestore.FS
+	reader   filestore.FS
+}
+
+func TestAgeEncryptedTestSuite(t *testing.T) {
+	suite.Run(t, &AgeEncryptedTestSuite{})
+}
+
+func (s *AgeEncryptedTestSuite) SetupTest() {
+	identity, err := age.GenerateX25519Identity()
+	s.Require().NoError(err)
+	s.identity = identity
+	s.inner = filestore.Memory()
+	s.writer = filestore.AgeEncrypted(s.inner, []age.Recipient{identity.Recipient()}, nil)
+	s.reader = filestore.AgeEncrypted(s.inner, nil, []age.Identity{identity})
+}
+
+func (s *AgeEncryptedTestSuite) TestWriteAndRead() {
+	s.Require().NoError(writeString(s.writer, "reports/q3.txt", "the dude abides"))
+
+	stored, err := readString(s.inner, "reports/q3.txt")
+	s.Require().NoError(err)
+	s.Require().NotContains(stored, "dude")
+	decrypted, err := age.Decrypt(strings.NewReader(stored), s.identity)
+	s.Require().NoError(err)
+	data, err := io.ReadAll(decrypted)
+	s.Require().NoError(err)
+	s.Require().Equal("the dude abides", string(data), "Should be a plain old age file")
+
+	content, err := readString(s.reader, "reports/q3.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("the dude abides", content)
+
+	_, err = s.writer.Read("reports/q3.txt")
+	s.Require().Error(err, "Should not be able to read w/o an identity")
+	s.Require().Error(writeString(s.reader, "reports/q4.txt", "nope"), "Should not be able to write w/o a recipient")
+}
+
+func (s *AgeEncryptedTestSuite) TestRead_wrongIdentity() {
+	s.Require().NoError(writeString(s.writer, "reports/q3.txt", "the dude abides"))
+
+	other, err := age.GenerateX25519Identity()
+	s.Require().NoError(err)
+	_, err = filestore.AgeEncrypted(s.inner, nil, []age.Identity{other}).Read("reports/q3.txt")
+	s.Require().Error(err)
+
+	// Any one of the recipients should be able to read it.
+	both := filestore.AgeEncrypted(s.inner, []age.Recipient{s.identity.Recipient(), other.Recipient()}, nil)
+	s.Require().NoError(writeString(both, "reports/q4.txt", "abide"))
+	content, err := readString(filestore.AgeEncrypted(s.inner, nil, []age.Identity{other}), "reports/q4.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("abide", content)
+}
+
+func (s *AgeEncryptedTestSuite) TestRead_seek() {
+	s.Require().NoError(writeString(s.writer, "dude.txt", "the dude abides"))
+	file, err := s.reader.Read("dude.txt")
+	s.Require().NoError(err)
+	defer file.Close()
+
+	buffer := make([]byte, 4)
+	n, err := file.ReadAt(buffer, 4)
+	s.Require().NoError(err)
+	s.Require().Equal("dude", string(buffer[:n]))
+	position, err := file.Seek(-6, io.SeekEnd)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(9), position)
+}
+
+func (s *AgeEncryptedTestSuite) TestFromConfig() {
+	identityFile := filepath.Join(s.T().TempDir(), "key.txt")
+	s.Require().NoError(os.WriteFile(identityFile, []byte("# created: today\n"+s.identity.String()+"\n"), 0o600))
+
+	fs, err := filestore.FromConfig(filestore.Config{
+		URL: "mem://",
+		Layers: []filestore.LayerConfig{{Type: "age_encrypted", Options: filestore.LayerOptions{
+			"recipients":   []string{s.identity.Recipient().String()},
+			"identityFile": identityFile,
+		}}},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(fs, "dude.txt", "the dude abides"))
+	content, err := readString(fs, "dude.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("the dude abides", content)
+
+	_, err = filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "age_encrypted", Options: filestore.LayerOptions{"identities": []string{s.identity.String()}}}},
+	})
+	s.Require().NoError(err)
+
+	_, err = filestore.FromConfig(filestore.Config{URL: "mem://", Layers: []filestore.LayerConfig{{Type: "age_encrypted"}}})
+	s.Require().Error(err, "Should require recipients or identities")
+	_, err = filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "age_encrypted", Options: filestore.LayerOptions{"recipients": []string{"age1nope"}}}},
+	})
+	s.Require().Error(err)
+}
@@ -0,0 +1,213 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditEvent is a single entry in the audit trail of an Audited() store.
+type AuditEvent struct {
+	// Time is when the operation finished.
+	Time time.Time `json:"time"`
+	// Op is the operation that changed the store: "write", "move", or "remove".
+	Op string `json:"op"`
+	// Path is the full path of the file/directory, including the working directory of the store.
+	Path string `json:"path"`
+	// ToPath is the full path that a file/directory was moved to; it's empty for every other operation.
+	ToPath string `json:"toPath,omitempty"`
+	// Size is the number of bytes written for "write" events; it's zero for every other operation.
+	Size int64 `json:"size"`
+	// User is the user from the Identity of the request that the store was bound to (see ForRequest), if any.
+	User string `json:"user,omitempty"`
+	// TraceID is the trace ID from the Identity of the request that the store was bound to, if any.
+	TraceID string `json:"traceId,omitempty"`
+	// Error is the message of the error that the operation failed w/, if any. Failed attempts to change
+	// the store are audited, too.
+	Error string `json:"error,omitempty"`
+}
+
+// AuditSink is where an Audited() store sends its events. Implementations must be safe for concurrent use.
+// Sinks are typically append-only (a log file, a database table w/o UPDATE/DELETE grants, a SIEM, etc.).
+type AuditSink interface {
+	// Record durably stores the event, returning an error if it couldn't.
+	Record(event AuditEvent) error
+}
+
+// AuditSinkFunc lets you use an ordinary function as an AuditSink.
+type AuditSinkFunc func(event AuditEvent) error
+
+// Record calls the function w/ the event.
+func (fn AuditSinkFunc) Record(event AuditEvent) error {
+	return fn(event)
+}
+
+// AuditWriter creates a sink that appends each event to the writer as a single line of JSON. Events are
+// written one at a time, so it's safe to share the writer between multiple stores.
+//
+// Example:
+//
+//	trail, err := os.OpenFile("/var/log/files-audit.jsonl", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+//	...
+//	files := filestore.Audited(filestore.Disk("/srv/records"), filestore.AuditWriter(trail))
+func AuditWriter(w io.Writer) AuditSink {
+	return &auditWriter{writer: w}
+}
+
+type auditWriter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func (a *auditWriter) Record(event AuditEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	_, err = a.writer.Write(append(data, '\n'))
+	return err
+}
+
+// Audited wraps a file store, sending an AuditEvent to the sink for every operation that changes the
+// store: writing a file (once you close it), moving a file/directory, or removing one. Each event says
+// what happened to which path, when, how many bytes were written, and who did it according to the
+// Identity of the request that the store was bound to w/ ForRequest(). Attempts that fail are recorded,
+// too. Reads aren't audited; use Logged() if you need those.
+//
+// If the sink fails to record an event, the operation returns that error even though the change itself
+// already happened, so that nothing can change the store w/o anybody noticing that it wasn't audited.
+// You can supply WithClock() to control the event times in tests.
+//
+// Example:
+//
+//	files := filestore.Audited(filestore.Disk("/srv/records"), filestore.AuditWriter(trail))
+//	...
+//	ctx := filestore.ContextWithIdentity(req.Context(), filestore.Identity{User: userID})
+//	err := filestore.ForRequest(files, ctx).Remove("patients/1234.pdf")
+func Audited(fs FS, sink AuditSink, opts ...Option) FS {
+	options := newOptions(opts)
+	return &auditedFS{FS: fs, audit: &auditor{sink: sink, clock: options.clock}}
+}
+
+type auditedFS struct {
+	FS
+	audit *auditor
+}
+
+// auditor sends the events for an Audited() store and any instances/files derived from it.
+type auditor struct {
+	sink  AuditSink
+	clock Clock
+}
+
+// Write opens the file for writing. The write is audited once you close the file.
+func (a *auditedFS) Write(filePath string) (WriterFile, error) {
+	fullPath := a.fullPath(filePath)
+	file, err := a.FS.Write(filePath)
+	if err != nil {
+		return nil, a.audit.record(a.FS, AuditEvent{Op: "write", Path: fullPath}, err)
+	}
+	return &auditedWriterFile{WriterFile: file, audit: a.audit, fs: a.FS, path: fullPath}, nil
+}
+
+// Move relocates the file/directory, auditing the attempt.
+func (a *auditedFS) Move(fromPath string, toPath string) error {
+	err := a.FS.Move(fromPath, toPath)
+	return a.audit.record(a.FS, AuditEvent{Op: "move", Path: a.fullPath(fromPath), ToPath: a.fullPath(toPath)}, err)
+}
+
+// Remove deletes the file/directory, auditing the attempt.
+func (a *auditedFS) Remove(fileOrDirPath string) error {
+	err := a.FS.Remove(fileOrDirPath)
+	return a.audit.record(a.FS, AuditEvent{Op: "remove", Path: a.fullPath(fileOrDirPath)}, err)
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that audits to the same sink.
+func (a *auditedFS) ChangeDirectory(dir string) FS {
+	return &auditedFS{FS: a.FS.ChangeDirectory(dir), audit: a.audit}
+}
+
+func (a *auditedFS) withContext(ctx context.Context) FS {
+	return &auditedFS{FS: ForRequest(a.FS, ctx), audit: a.audit}
+}
+
+func (a *auditedFS) requestContext() context.Context {
+	return RequestContext(a.FS)
+}
+
+func (a *auditedFS) wrapped() []FS {
+	return []FS{a.FS}
+}
+
+func (a *auditedFS) fullPath(filePath string) string {
+	return joinPath(a.FS.WorkingDirectory(), filePath)
+}
+
+// record fills in the rest of the event and sends it to the sink. It returns the operation's error or, if
+// the operation succeeded but we couldn't audit it, the sink's error.
+func (a *auditor) record(fs FS, event AuditEvent, err error) error {
+	event.Time = a.clock.Now()
+	if identity, ok := IdentityFromContext(RequestContext(fs)); ok {
+		event.User = identity.User
+		event.TraceID = identity.TraceID
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	if sinkErr := a.sink.Record(event); sinkErr != nil && err == nil {
+		return fmt.Errorf("filestore: audit: %s: %s: %w", event.Op, event.Path, sinkErr)
+	}
+	return err
+}
+
+// auditedWriterFile counts the bytes written to the file so that we can audit them when it's closed.
+type auditedWriterFile struct {
+	WriterFile
+	audit *auditor
+	fs    FS
+	path  string
+	bytes int64
+	err   error
+}
+
+func (f *auditedWriterFile) Write(data []byte) (int, error) {
+	n, err := f.WriterFile.Write(data)
+	f.record(n, err)
+	return n, err
+}
+
+func (f *auditedWriterFile) WriteAt(data []byte, offset int64) (int, error) {
+	n, err := f.WriterFile.WriteAt(data, offset)
+	f.record(n, err)
+	return n, err
+}
+
+func (f *auditedWriterFile) record(n int, err error) {
+	f.bytes += int64(n)
+	if f.err == nil {
+		f.err = err
+	}
+}
+
+func (f *auditedWriterFile) Close() error {
+	err := f.WriterFile.Close()
+	failure := err
+	if failure == nil {
+		failure = f.err
+	}
+	auditErr := f.audit.record(f.fs, AuditEvent{Op: "write", Path: f.path, Size: f.bytes}, failure)
+	if failure == nil {
+		return auditErr
+	}
+	return err
+}
+
+var _ requestBinder = &auditedFS{}
+var _ wrapper = &auditedFS{}
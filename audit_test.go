@@ -0,0 +1,89 @@
+package filestore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditTestSuite struct {
+	suite.Suite
+	clock  *filestoretest.Clock
+	events []filestore.AuditEvent
+	fs     filestore.FS
+}
+
+func TestAuditTestSuite(t *testing.T) {
+	suite.Run(t, &AuditTestSuite{})
+}
+
+func (s *AuditTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.events = nil
+	sink := filestore.AuditSinkFunc(func(event filestore.AuditEvent) error {
+		s.events = append(s.events, event)
+		return nil
+	})
+	s.fs = filestore.Audited(filestore.Memory(), sink, filestore.WithClock(s.clock))
+}
+
+func (s *AuditTestSuite) TestMutations() {
+	ctx := filestore.ContextWithIdentity(context.Background(), filestore.Identity{User: "dude", TraceID: "abc123"})
+	files := filestore.ForRequest(s.fs, ctx).ChangeDirectory("records")
+
+	s.Require().NoError(writeString(files, "a.txt", "abide"))
+	s.clock.Advance(time.Minute)
+	s.Require().NoError(files.Move("a.txt", "b.txt"))
+	_, err := readString(files, "b.txt")
+	s.Require().NoError(err)
+	s.Require().NoError(files.Remove("b.txt"))
+	s.Require().Error(files.Move("nope.txt", "c.txt"))
+
+	s.Require().Len(s.events, 4, "Should only audit mutating operations")
+	s.Require().Equal(filestore.AuditEvent{
+		Time:    time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC),
+		Op:      "write",
+		Path:    "/records/a.txt",
+		Size:    5,
+		User:    "dude",
+		TraceID: "abc123",
+	}, s.events[0])
+	s.Require().Equal("move", s.events[1].Op)
+	s.Require().Equal("/records/b.txt", s.events[1].ToPath)
+	s.Require().Equal(time.Date(2022, 9, 6, 12, 1, 0, 0, time.UTC), s.events[1].Time)
+	s.Require().Equal("remove", s.events[2].Op)
+	s.Require().Equal("/records/nope.txt", s.events[3].Path)
+	s.Require().NotEmpty(s.events[3].Error, "Should audit failed attempts, too")
+}
+
+func (s *AuditTestSuite) TestSinkFailure() {
+	errSink := errors.New("audit log unavailable")
+	files := filestore.Audited(filestore.Memory(), filestore.AuditSinkFunc(func(filestore.AuditEvent) error {
+		return errSink
+	}))
+	s.Require().True(errors.Is(writeString(files, "a.txt", "abide"), errSink))
+	s.Require().True(errors.Is(files.Remove("a.txt"), errSink))
+}
+
+func (s *AuditTestSuite) TestAuditWriter() {
+	buf := &bytes.Buffer{}
+	files := filestore.Audited(filestore.Memory(), filestore.AuditWriter(buf), filestore.WithClock(s.clock))
+	s.Require().NoError(writeString(files, "a.txt", "abide"))
+	s.Require().NoError(files.Remove("a.txt"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	s.Require().Len(lines, 2)
+	s.Require().Equal(`{"time":"2022-09-06T12:00:00Z","op":"write","path":"/a.txt","size":5}`, lines[0])
+
+	event := filestore.AuditEvent{}
+	s.Require().NoError(json.Unmarshal([]byte(lines[1]), &event))
+	s.Require().Equal("remove", event.Op)
+}
@@ -0,0 +1,169 @@
+package filestore
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+)
+
+// BatchReport describes what happened to each path during a recursive operation that kept going after
+// failures (see WithContinueOnError).
+type BatchReport struct {
+	// Succeeded contains the paths that were processed successfully, in the order we processed them.
+	Succeeded []string
+	// Failed contains the paths that we couldn't process and why, in the order we processed them.
+	Failed []BatchFailure
+}
+
+// BatchFailure is a single path that a recursive operation failed to process.
+type BatchFailure struct {
+	// Path is the file/directory that we failed to process.
+	Path string
+	// Err is the reason it failed.
+	Err error
+}
+
+// batchOptions contains the settings for recursive operations that can keep going after failures.
+type batchOptions struct {
+	continueOnError bool
+	report          *BatchReport
+}
+
+// WithContinueOnError makes recursive operations (CopyAll and RemoveAll) keep going when they fail to
+// process a file or directory, rather than stopping at the first failure. Once they're done, they return
+// all of the failures combined into a single error (so errors.Is() and errors.As() look at every one of
+// them), or nil if everything worked. When the report isn't nil, we fill it in w/ the paths that
+// succeeded and failed so you can retry just the ones that didn't make it.
+//
+// Example:
+//
+//	// Don't abandon the whole backup just because one file was locked.
+//	report := filestore.BatchReport{}
+//	err := filestore.CopyAll(files, "data", bucket, "backups/data", filestore.WithContinueOnError(&report))
+//	for _, failure := range report.Failed {
+//	    log.Printf("not backed up: %s: %v", failure.Path, failure.Err)
+//	}
+func WithContinueOnError(report *BatchReport) Option {
+	return func(opts *options) {
+		opts.batch.continueOnError = true
+		opts.batch.report = report
+	}
+}
+
+// batchRun tracks the outcome of a single recursive operation. A nil run stops at the first failure.
+type batchRun struct {
+	report BatchReport
+	// reportTo is where the caller wants the report, if anywhere.
+	reportTo *BatchReport
+	// failedDirs contains every path that failed, along w/ all of the directories above it.
+	failedDirs map[string]struct{}
+}
+
+// newBatchRun starts tracking a recursive operation, returning nil unless you asked it to continue on error.
+func newBatchRun(options batchOptions) *batchRun {
+	if !options.continueOnError {
+		return nil
+	}
+	return &batchRun{reportTo: options.report, failedDirs: map[string]struct{}{}}
+}
+
+// succeed records that we processed the path successfully.
+func (b *batchRun) succeed(filePath string) {
+	if b != nil {
+		b.report.Succeeded = append(b.report.Succeeded, filePath)
+	}
+}
+
+// fail records that we failed to process the path. It returns nil when the operation should keep going
+// or the original error when it should stop.
+func (b *batchRun) fail(filePath string, err error) error {
+	if b == nil || errors.Is(err, fs.SkipDir) || errors.Is(err, ErrTooManyEntries) {
+		return err
+	}
+	b.report.Failed = append(b.report.Failed, BatchFailure{Path: filePath, Err: err})
+	for dir := filePath; ; dir = path.Dir(dir) {
+		b.failedDirs[dir] = struct{}{}
+		if dir == "." || dir == "/" {
+			break
+		}
+	}
+	return nil
+}
+
+// failedWithin returns true if we failed to process the directory itself or anything inside of it.
+func (b *batchRun) failedWithin(dir string) bool {
+	if b == nil {
+		return false
+	}
+	_, ok := b.failedDirs[dir]
+	return ok
+}
+
+// finish hands the report to whoever asked for it and returns the operation's overall error: the error
+// that stopped it early, if any, or all of the failures that we kept going after.
+func (b *batchRun) finish(err error) error {
+	if b == nil {
+		return err
+	}
+	if b.reportTo != nil {
+		*b.reportTo = b.report
+	}
+	if err != nil {
+		return err
+	}
+	errs := make([]error, len(b.report.Failed))
+	for i, failure := range b.report.Failed {
+		errs[i] = failure.Err
+	}
+	return joinErrors(errs...)
+}
+
+// RemoveAll deletes the file/directory at the given path and everything inside of it. On its own, this is
+// the same as calling the store's Remove(). When you supply WithContinueOnError(), we remove each file
+// individually instead, so a single file that can't be removed doesn't stop us from removing the rest of
+// the tree. Directories that still contain such a file are left in place. We never follow symbolic links;
+// a link is removed rather than the file/directory it points to.
+//
+// Example:
+//
+//	report := filestore.BatchReport{}
+//	err := filestore.RemoveAll(scratch, "jobs/2022-09-06", filestore.WithContinueOnError(&report))
+func RemoveAll(fsys FS, fileOrDirPath string, opts ...Option) error {
+	batch := newBatchRun(newOptions(opts).batch)
+	if batch == nil {
+		return fsys.Remove(fileOrDirPath)
+	}
+
+	// Remove the files as we find them, but hang onto the directories until they're (hopefully) empty.
+	var dirs []string
+	root := path.Clean(fileOrDirPath)
+	err := Walk(fsys, root, func(filePath string, info FileInfo, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && filePath == root:
+			return nil
+		case err != nil:
+			return batch.fail(filePath, err)
+		case info.IsDir():
+			dirs = append(dirs, filePath)
+			return nil
+		}
+		if err = fsys.Remove(filePath); err != nil {
+			return batch.fail(filePath, err)
+		}
+		batch.succeed(filePath)
+		return nil
+	})
+
+	// Children come after their parents in the walk, so going backwards removes them first.
+	for i := len(dirs) - 1; err == nil && i >= 0; i-- {
+		if batch.failedWithin(dirs[i]) {
+			continue
+		}
+		if removeErr := fsys.Remove(dirs[i]); removeErr != nil {
+			err = batch.fail(dirs[i], removeErr)
+			continue
+		}
+		batch.succeed(dirs[i])
+	}
+	return batch.finish(err)
+}
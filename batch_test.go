@@ -0,0 +1,104 @@
+package filestore_test
+
+import (
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type BatchTestSuite struct {
+	suite.Suite
+	memory filestore.FS
+	fs     filestore.FS
+}
+
+func TestBatchTestSuite(t *testing.T) {
+	suite.Run(t, &BatchTestSuite{})
+}
+
+func (s *BatchTestSuite) SetupTest() {
+	s.memory = filestore.Memory()
+	s.fs = &lockedFS{FS: s.memory, locked: map[string]bool{"locked.txt": true, "sealed": true}}
+	s.Require().NoError(writeString(s.memory, "data/a/1.txt", "the dude"))
+	s.Require().NoError(writeString(s.memory, "data/a/locked.txt", "walter"))
+	s.Require().NoError(writeString(s.memory, "data/b/2.txt", "donny"))
+	s.Require().NoError(writeString(s.memory, "data/sealed/3.txt", "maude"))
+}
+
+func (s *BatchTestSuite) TestCopyAll() {
+	dst := filestore.Memory()
+	err := filestore.CopyAll(s.fs, "data", dst, "backup")
+	s.Require().True(errors.Is(err, errLocked))
+	s.Require().False(dst.Exists("backup/b/2.txt"), "Should stop at the first failure by default")
+
+	report := filestore.BatchReport{}
+	err = filestore.CopyAll(s.fs, "data", dst, "backup", filestore.WithContinueOnError(&report))
+	s.Require().True(errors.Is(err, errLocked))
+	s.Require().Equal([]string{"data/a/1.txt", "data/b/2.txt"}, report.Succeeded)
+	s.Require().Len(report.Failed, 2)
+	s.Require().Equal("data/a/locked.txt", report.Failed[0].Path)
+	s.Require().Equal("data/sealed", report.Failed[1].Path)
+	s.Require().True(errors.Is(report.Failed[1].Err, errLocked))
+
+	content, err := readString(dst, "backup/b/2.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("donny", content)
+	s.Require().False(dst.Exists("backup/a/locked.txt"))
+
+	report = filestore.BatchReport{}
+	err = filestore.CopyAll(s.fs, "data/b", dst, "backup/b", filestore.WithContinueOnError(&report))
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"data/b/2.txt"}, report.Succeeded)
+	s.Require().Empty(report.Failed)
+}
+
+func (s *BatchTestSuite) TestRemoveAll() {
+	report := filestore.BatchReport{}
+	err := filestore.RemoveAll(s.fs, "data", filestore.WithContinueOnError(&report))
+	s.Require().True(errors.Is(err, errLocked))
+	s.Require().Equal([]string{"data/a/1.txt", "data/b/2.txt", "data/b"}, report.Succeeded)
+	s.Require().Len(report.Failed, 2)
+	s.Require().Equal("data/a/locked.txt", report.Failed[0].Path)
+	s.Require().Equal("data/sealed", report.Failed[1].Path)
+
+	s.Require().False(s.memory.Exists("data/a/1.txt"))
+	s.Require().False(s.memory.Exists("data/b"))
+	s.Require().True(s.memory.Exists("data/a/locked.txt"), "Should leave the files it couldn't remove")
+	s.Require().True(s.memory.Exists("data/sealed/3.txt"), "Should not remove directories it couldn't list")
+
+	s.Require().NoError(filestore.RemoveAll(s.fs, "data/missing", filestore.WithContinueOnError(nil)))
+	s.Require().NoError(filestore.RemoveAll(s.fs, "data"), "Should just call Remove() by default")
+	s.Require().False(s.memory.Exists("data"))
+}
+
+var errLocked = errors.New("file is locked")
+
+// lockedFS fails to read, remove, or list any file/directory w/ one of the locked names.
+type lockedFS struct {
+	filestore.FS
+	locked map[string]bool
+}
+
+func (l *lockedFS) Read(filePath string) (filestore.ReaderFile, error) {
+	if l.locked[path.Base(filePath)] {
+		return nil, &filestore.PathError{Backend: "locked", Op: "open", Path: filePath, Err: errLocked}
+	}
+	return l.FS.Read(filePath)
+}
+
+func (l *lockedFS) List(dirPath string, filters ...filestore.FileFilter) ([]filestore.FileInfo, error) {
+	if l.locked[path.Base(dirPath)] {
+		return nil, &filestore.PathError{Backend: "locked", Op: "list files", Path: dirPath, Err: errLocked}
+	}
+	return l.FS.List(dirPath, filters...)
+}
+
+func (l *lockedFS) Remove(fileOrDirPath string) error {
+	if l.locked[path.Base(fileOrDirPath)] {
+		return &filestore.PathError{Backend: "locked", Op: "remove", Path: fileOrDirPath, Err: errLocked}
+	}
+	return l.FS.Remove(fileOrDirPath)
+}
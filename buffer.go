@@ -0,0 +1,104 @@
+package filestore
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// bufferWriter is the WriterFile for stores that can only save a file's contents all at once (memory,
+// Redis, SQL, zip archives, etc.). It buffers all writes privately and hands the complete contents to
+// its flush callback when you close the file. Backends embed it, supplying their own flush.
+type bufferWriter struct {
+	mutex sync.Mutex
+	// name identifies the store in error messages (e.g. "redis").
+	name   string
+	flush  func(data []byte) error
+	buffer []byte
+	offset int64
+	closed bool
+}
+
+func newBufferWriter(name string, flush func(data []byte) error) *bufferWriter {
+	return &bufferWriter{name: name, flush: flush}
+}
+
+// Write writes len(b) bytes from b to the file at the current offset.
+func (w *bufferWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, fmt.Errorf("%s fs: write: %w", w.name, fs.ErrClosed)
+	}
+	n := w.writeAt(p, w.offset)
+	w.offset += int64(n)
+	return n, nil
+}
+
+// WriteAt writes len(b) bytes to the file starting at byte offset off.
+func (w *bufferWriter) WriteAt(p []byte, off int64) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, fmt.Errorf("%s fs: write at: %w", w.name, fs.ErrClosed)
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("%s fs: write at: negative offset", w.name)
+	}
+	return w.writeAt(p, off), nil
+}
+
+// writeAt copies the bytes into our private buffer, growing it (w/ zeros) as necessary.
+func (w *bufferWriter) writeAt(p []byte, off int64) int {
+	if end := off + int64(len(p)); end > int64(len(w.buffer)) {
+		w.buffer = append(w.buffer, make([]byte, end-int64(len(w.buffer)))...)
+	}
+	return copy(w.buffer[off:], p)
+}
+
+// Seek moves to the given offset w/o writing any data.
+func (w *bufferWriter) Seek(offset int64, whence int) (int64, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, fmt.Errorf("%s fs: seek: %w", w.name, fs.ErrClosed)
+	}
+
+	var position int64
+	switch whence {
+	case io.SeekStart:
+		position = offset
+	case io.SeekCurrent:
+		position = w.offset + offset
+	case io.SeekEnd:
+		position = int64(len(w.buffer)) + offset
+	default:
+		return 0, fmt.Errorf("%s fs: seek: invalid whence %d", w.name, whence)
+	}
+	if position < 0 {
+		return 0, fmt.Errorf("%s fs: seek: negative position", w.name)
+	}
+	w.offset = position
+	return position, nil
+}
+
+// Close hands everything you wrote to the flush callback. Closing the file again does nothing.
+func (w *bufferWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.flush(w.buffer)
+	w.buffer = nil
+	return err
+}
+
+var _ WriterFile = &bufferWriter{}
@@ -0,0 +1,263 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Buffered wraps a file store so that data written to its files is accumulated in memory and only
+// passed along to the underlying store in larger chunks. This is useful for backends where lots of
+// tiny writes are expensive (e.g. S3 or SFTP), so that a producer calling Write() with a couple hundred
+// bytes at a time doesn't result in a pathological number of requests.
+//
+// A file's buffer is flushed once it holds at least 'size' bytes, or once 'interval' has elapsed since
+// the first unflushed write (use 0 to disable time-based flushing). Buffers are also flushed before any
+// WriteAt() or Seek() call, when you call Flush() on the file, and when you close the file.
+//
+// The returned store implements the Closer capability; shutting it down flushes the buffers of all files
+// that are still open. You can supply the WithClock() option to control time-based flushing in tests.
+//
+// Example:
+//
+//	uploads := filestore.Buffered(s3Store, 5*1024*1024, 10*time.Second)
+//	file, err := uploads.Write("logs/app.log")
+//	...
+//	file.Write(smallChunk) // buffered until we have 5MB or 10s has passed
+func Buffered(fs FS, size int, interval time.Duration, opts ...Option) FS {
+	options := newOptions(opts)
+	return &bufferedFS{
+		FS:       fs,
+		size:     size,
+		interval: interval,
+		clock:    options.clock,
+		open:     &openFiles{files: map[*bufferedWriterFile]struct{}{}},
+	}
+}
+
+func init() {
+	RegisterLayer("buffered", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Size     int    `json:"size"`
+			Interval string `json:"interval"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+
+		interval := time.Duration(0)
+		if settings.Interval != "" {
+			var err error
+			if interval, err = time.ParseDuration(settings.Interval); err != nil {
+				return nil, fmt.Errorf("invalid interval: %w", err)
+			}
+		}
+		return Buffered(fs, settings.Size, interval), nil
+	})
+}
+
+type bufferedFS struct {
+	FS
+	size     int
+	interval time.Duration
+	clock    Clock
+	open     *openFiles
+}
+
+// openFiles tracks all files opened by a bufferedFS (and instances derived from it via ChangeDirectory) that
+// have not been closed yet, so that we can flush them all when shutting down.
+type openFiles struct {
+	mutex sync.Mutex
+	files map[*bufferedWriterFile]struct{}
+}
+
+func (b *bufferedFS) Write(filePath string) (WriterFile, error) {
+	file, err := b.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := &bufferedWriterFile{file: file, fs: b}
+	b.open.mutex.Lock()
+	b.open.files[buffered] = struct{}{}
+	b.open.mutex.Unlock()
+	return buffered, nil
+}
+
+func (b *bufferedFS) ChangeDirectory(dir string) FS {
+	return &bufferedFS{
+		FS:       b.FS.ChangeDirectory(dir),
+		size:     b.size,
+		interval: b.interval,
+		clock:    b.clock,
+		open:     b.open,
+	}
+}
+
+func (b *bufferedFS) withContext(ctx context.Context) FS {
+	return &bufferedFS{
+		FS:       ForRequest(b.FS, ctx),
+		size:     b.size,
+		interval: b.interval,
+		clock:    b.clock,
+		open:     b.open,
+	}
+}
+
+func (b *bufferedFS) requestContext() context.Context {
+	return RequestContext(b.FS)
+}
+
+func (b *bufferedFS) wrapped() []FS {
+	return []FS{b.FS}
+}
+
+// Close flushes the buffers of every file that is still open (it does not close the files themselves),
+// and then closes the underlying store if it supports the Closer capability.
+func (b *bufferedFS) Close(ctx context.Context) error {
+	b.open.mutex.Lock()
+	var files []*bufferedWriterFile
+	for file := range b.open.files {
+		files = append(files, file)
+	}
+	b.open.mutex.Unlock()
+
+	var firstErr error
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := file.Flush(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := Shutdown(ctx, b.FS); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// bufferedWriterFile accumulates sequential writes in memory before passing them along to the real file.
+type bufferedWriterFile struct {
+	mutex      sync.Mutex
+	file       WriterFile
+	fs         *bufferedFS
+	buffer     []byte
+	generation int
+	err        error
+	closed     bool
+}
+
+// Write appends the data to the buffer, flushing it to the underlying file once it's large enough.
+func (w *bufferedWriterFile) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, fmt.Errorf("buffered fs: write: file already closed")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	// The first write to an empty buffer starts the clock on the time-based flush.
+	if len(w.buffer) == 0 && w.fs.interval > 0 {
+		go w.flushAfter(w.generation, w.fs.clock.After(w.fs.interval))
+	}
+	w.buffer = append(w.buffer, p...)
+	if len(w.buffer) >= w.fs.size {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// WriteAt flushes any buffered data before writing directly to the underlying file.
+func (w *bufferedWriterFile) WriteAt(p []byte, off int64) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.flush(); err != nil {
+		return 0, err
+	}
+	return w.file.WriteAt(p, off)
+}
+
+// Seek flushes any buffered data before moving the underlying file's offset.
+func (w *bufferedWriterFile) Seek(offset int64, whence int) (int64, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.flush(); err != nil {
+		return 0, err
+	}
+	return w.file.Seek(offset, whence)
+}
+
+// Flush immediately writes any buffered data to the underlying file.
+func (w *bufferedWriterFile) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.flush()
+}
+
+// Close flushes any buffered data and closes the underlying file.
+func (w *bufferedWriterFile) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	w.fs.open.mutex.Lock()
+	delete(w.fs.open.files, w)
+	w.fs.open.mutex.Unlock()
+
+	flushErr := w.flush()
+	closeErr := w.file.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// flush writes the buffer to the underlying file. You must hold the mutex when calling this.
+func (w *bufferedWriterFile) flush() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	// Any pending time-based flush for this buffer is now obsolete.
+	w.generation++
+	if len(w.buffer) == 0 {
+		return nil
+	}
+
+	_, err := w.file.Write(w.buffer)
+	w.buffer = w.buffer[:0]
+	if err != nil {
+		w.err = fmt.Errorf("buffered fs: flush: %w", err)
+	}
+	return w.err
+}
+
+// flushAfter waits for the flush interval to elapse and then flushes the buffer, but only if it hasn't
+// already been flushed for some other reason in the meantime.
+func (w *bufferedWriterFile) flushAfter(generation int, timer <-chan time.Time) {
+	<-timer
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed || w.generation != generation {
+		return
+	}
+	_ = w.flush()
+}
+
+var _ Closer = &bufferedFS{}
+var _ requestBinder = &bufferedFS{}
+var _ wrapper = &bufferedFS{}
@@ -0,0 +1,185 @@
+package filestore_test
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type BufferedTestSuite struct {
+	suite.Suite
+	inner *chunkFS
+	clock *filestoretest.Clock
+}
+
+func TestBufferedTestSuite(t *testing.T) {
+	suite.Run(t, &BufferedTestSuite{})
+}
+
+func (s *BufferedTestSuite) SetupTest() {
+	s.inner = &chunkFS{FS: filestore.Memory()}
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+}
+
+func (s *BufferedTestSuite) TestWrite_sizeThreshold() {
+	fs := filestore.Buffered(s.inner, 10, 0)
+
+	file, err := fs.Write("foo.txt")
+	s.Require().NoError(err)
+	for i := 0; i < 7; i++ {
+		n, err := file.Write([]byte("abc"))
+		s.Require().NoError(err)
+		s.Require().Equal(3, n)
+	}
+	s.Require().Equal([]string{"abcabcabcabc"}, s.inner.chunks(), "Should flush once buffer reaches threshold")
+	s.Require().NoError(file.Close())
+	s.Require().Equal([]string{"abcabcabcabc", "abcabcabc"}, s.inner.chunks(), "Closing should flush remaining data")
+	s.Require().Equal("abcabcabcabcabcabcabc", s.read("foo.txt"))
+}
+
+func (s *BufferedTestSuite) TestWrite_timeThreshold() {
+	fs := filestore.Buffered(s.inner, 1024, time.Second, filestore.WithClock(s.clock))
+
+	file, err := fs.Write("foo.txt")
+	s.Require().NoError(err)
+	_, _ = file.Write([]byte("abc"))
+	_, _ = file.Write([]byte("def"))
+
+	s.clock.BlockUntil(1)
+	s.clock.Advance(999 * time.Millisecond)
+	s.Require().Empty(s.inner.chunks(), "Should not flush before interval elapses")
+
+	s.clock.Advance(time.Millisecond)
+	s.Require().Eventually(func() bool { return len(s.inner.chunks()) == 1 }, time.Second, time.Millisecond)
+	s.Require().Equal([]string{"abcdef"}, s.inner.chunks())
+
+	// The next write should start a brand-new timer.
+	_, _ = file.Write([]byte("ghi"))
+	s.clock.BlockUntil(1)
+	s.clock.Advance(time.Second)
+	s.Require().Eventually(func() bool { return len(s.inner.chunks()) == 2 }, time.Second, time.Millisecond)
+	s.Require().NoError(file.Close())
+	s.Require().Equal([]string{"abcdef", "ghi"}, s.inner.chunks())
+}
+
+func (s *BufferedTestSuite) TestWriteAtAndSeek() {
+	fs := filestore.Buffered(s.inner, 1024, 0)
+
+	file, err := fs.Write("foo.txt")
+	s.Require().NoError(err)
+	_, _ = file.Write([]byte("abcdef"))
+	_, err = file.WriteAt([]byte("X"), 1)
+	s.Require().NoError(err)
+	_, err = file.Seek(0, io.SeekEnd)
+	s.Require().NoError(err)
+	_, _ = file.Write([]byte("ghi"))
+	s.Require().NoError(file.Close())
+	s.Require().Equal("aXcdefghi", s.read("foo.txt"))
+}
+
+func (s *BufferedTestSuite) TestFlush() {
+	fs := filestore.Buffered(s.inner, 1024, 0)
+
+	file, err := fs.ChangeDirectory("a").Write("foo.txt")
+	s.Require().NoError(err)
+	_, _ = file.Write([]byte("abc"))
+	s.Require().Empty(s.inner.chunks())
+
+	flusher, ok := file.(interface{ Flush() error })
+	s.Require().True(ok, "Buffered files should support Flush()")
+	s.Require().NoError(flusher.Flush())
+	s.Require().Equal([]string{"abc"}, s.inner.chunks())
+	s.Require().NoError(file.Close())
+}
+
+func (s *BufferedTestSuite) TestShutdown() {
+	fs := filestore.Buffered(s.inner, 1024, 0)
+
+	file1, _ := fs.Write("foo.txt")
+	file2, _ := fs.ChangeDirectory("a").Write("bar.txt")
+	_, _ = file1.Write([]byte("foo"))
+	_, _ = file2.Write([]byte("bar"))
+	s.Require().Empty(s.inner.chunks())
+
+	s.Require().NoError(filestore.Shutdown(context.Background(), fs))
+	s.Require().ElementsMatch([]string{"foo", "bar"}, s.inner.chunks(), "Shutdown should flush all open files")
+	s.Require().NoError(file1.Close())
+	s.Require().NoError(file2.Close())
+}
+
+func (s *BufferedTestSuite) TestFromConfig() {
+	cfg := filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "buffered", Options: filestore.LayerOptions{"size": 1024, "interval": "5s"}}},
+	}
+	_, err := filestore.FromConfig(cfg)
+	s.Require().NoError(err)
+
+	cfg.Layers[0].Options["interval"] = "forever"
+	_, err = filestore.FromConfig(cfg)
+	s.Require().Error(err)
+}
+
+func (s *BufferedTestSuite) read(name string) string {
+	file, err := s.inner.Read(name)
+	s.Require().NoError(err)
+	defer file.Close()
+	data, _ := io.ReadAll(file)
+	return string(data)
+}
+
+// chunkFS records the payload of every individual Write() call made to any of its files.
+type chunkFS struct {
+	filestore.FS
+	mutex  sync.Mutex
+	writes []string
+}
+
+func (fs *chunkFS) Write(name string) (filestore.WriterFile, error) {
+	file, err := fs.FS.Write(name)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkWriterFile{WriterFile: file, fs: fs}, nil
+}
+
+func (fs *chunkFS) ChangeDirectory(dir string) filestore.FS {
+	return &chunkDirFS{FS: fs.FS.ChangeDirectory(dir), parent: fs}
+}
+
+func (fs *chunkFS) chunks() []string {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return append([]string{}, fs.writes...)
+}
+
+type chunkDirFS struct {
+	filestore.FS
+	parent *chunkFS
+}
+
+func (fs *chunkDirFS) Write(name string) (filestore.WriterFile, error) {
+	file, err := fs.FS.Write(name)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkWriterFile{WriterFile: file, fs: fs.parent}, nil
+}
+
+type chunkWriterFile struct {
+	filestore.WriterFile
+	fs *chunkFS
+}
+
+func (w *chunkWriterFile) Write(p []byte) (int, error) {
+	w.fs.mutex.Lock()
+	w.fs.writes = append(w.fs.writes, string(p))
+	w.fs.mutex.Unlock()
+	return w.WriterFile.Write(p)
+}
@@ -0,0 +1,256 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// bundleTempPattern is the name of the hidden directories where bundles are staged while being copied
+// and set aside while being removed. Bundle-aware stores leave these out of listings.
+const bundleTempPattern = ".bundle-*.tmp"
+
+// errBundleFound stops the walk that looks for bundles inside of a directory.
+var errBundleFound = errors.New("bundle found")
+
+// bundler is implemented by stores that treat some directories as bundles; see Bundles().
+type bundler interface {
+	isBundle(filePath string) bool
+}
+
+// Bundles wraps a file store so that directories whose names end w/ one of the given suffixes (e.g. ".app",
+// ".bundle", or ".pages") are treated as single logical files, like the document packages on macOS. You
+// should never see half of one:
+//
+//   - Moving a bundle (or a directory containing one) is all-or-nothing. Should the underlying store fail
+//     part way through (e.g. S3, which moves one object at a time), we move everything back.
+//   - Removing a bundle first moves it out of the way, so it disappears all at once even if removing its
+//     contents takes a while or fails.
+//   - CopyAll() copies each bundle into a hidden staging directory and only moves it into place once all
+//     of its files have been copied. If the bundle already exists, it's replaced as a whole.
+//   - List() leaves out the hidden directories used for staging and removal.
+//
+// Suffixes are case-insensitive and may be given w/ or w/o their leading dot. Files inside a bundle can
+// still be read and written individually.
+//
+// Example:
+//
+//	docs := filestore.Bundles(filestore.S3("documents"), ".pages", ".key")
+//	err := docs.Move("drafts/Q3 Report.pages", "final/Q3 Report.pages")
+func Bundles(fs FS, suffixes ...string) FS {
+	var normalized []string
+	for _, suffix := range suffixes {
+		if suffix = strings.ToLower(strings.TrimPrefix(suffix, ".")); suffix != "" {
+			normalized = append(normalized, "."+suffix)
+		}
+	}
+	return &bundleFS{FS: fs, suffixes: normalized}
+}
+
+func init() {
+	RegisterLayer("bundles", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Suffixes []string `json:"suffixes"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		if len(settings.Suffixes) == 0 {
+			return nil, fmt.Errorf("bundles: at least one suffix is required")
+		}
+		return Bundles(fs, settings.Suffixes...), nil
+	})
+}
+
+type bundleFS struct {
+	FS
+	suffixes []string
+}
+
+// isBundle returns true when the path's name has one of the bundle suffixes. It does not check whether
+// the path is actually a directory.
+func (b *bundleFS) isBundle(filePath string) bool {
+	name := strings.ToLower(path.Base(path.Clean(filePath)))
+	for _, suffix := range b.suffixes {
+		if len(name) > len(suffix) && strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// protected returns true when the path is a bundle or a directory that contains one, so moving or
+// removing it must be all-or-nothing.
+func (b *bundleFS) protected(filePath string) bool {
+	info, err := b.FS.Stat(filePath)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	if b.isBundle(filePath) {
+		return true
+	}
+	err = Walk(b.FS, filePath, func(walkPath string, info FileInfo, err error) error {
+		if err == nil && info.IsDir() && b.isBundle(walkPath) {
+			return errBundleFound
+		}
+		return nil
+	})
+	return errors.Is(err, errBundleFound)
+}
+
+// List performs the equivalent of the "ls" command, leaving out bundles that are still being copied
+// into the directory or removed from it.
+func (b *bundleFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	infos, err := b.FS.List(dirPath, filters...)
+	if err != nil {
+		return nil, err
+	}
+	results := infos[:0]
+	for _, info := range infos {
+		if !isBundleTemp(info.Name()) {
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// Move relocates the file/directory. Moving a bundle (or a directory containing one) either moves all
+// of it or none of it.
+func (b *bundleFS) Move(fromPath string, toPath string) error {
+	if !b.protected(fromPath) {
+		return b.FS.Move(fromPath, toPath)
+	}
+	if err := moveWhole(b.FS, fromPath, toPath); err != nil {
+		return fmt.Errorf("filestore: bundles: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the file/directory. Bundles (and directories containing them) are moved out of the way
+// first so that they disappear all at once.
+func (b *bundleFS) Remove(fileOrDirPath string) error {
+	if !b.protected(fileOrDirPath) {
+		return b.FS.Remove(fileOrDirPath)
+	}
+	name, err := UniqueName(bundleTempPattern)
+	if err != nil {
+		return fmt.Errorf("filestore: bundles: remove: %w", err)
+	}
+	trashPath := path.Join(path.Dir(path.Clean(fileOrDirPath)), name)
+	if err := moveWhole(b.FS, fileOrDirPath, trashPath); err != nil {
+		return fmt.Errorf("filestore: bundles: remove: %w", err)
+	}
+	if err := b.FS.Remove(trashPath); err != nil {
+		return fmt.Errorf("filestore: bundles: remove: %w", err)
+	}
+	return nil
+}
+
+func (b *bundleFS) ChangeDirectory(dir string) FS {
+	return &bundleFS{FS: b.FS.ChangeDirectory(dir), suffixes: b.suffixes}
+}
+
+func (b *bundleFS) withContext(ctx context.Context) FS {
+	return &bundleFS{FS: ForRequest(b.FS, ctx), suffixes: b.suffixes}
+}
+
+func (b *bundleFS) requestContext() context.Context {
+	return RequestContext(b.FS)
+}
+
+func (b *bundleFS) wrapped() []FS {
+	return []FS{b.FS}
+}
+
+// isBundleTemp returns true for the names of the hidden directories that we stage bundles in.
+func isBundleTemp(name string) bool {
+	return strings.HasPrefix(name, ".bundle-") && strings.HasSuffix(name, ".tmp")
+}
+
+// moveWhole moves the directory, putting back everything that already moved if the store fails part
+// way through. We only do that when nothing was at the destination beforehand, so that we never touch
+// files that weren't ours to begin with.
+func moveWhole(fsys FS, fromPath string, toPath string) error {
+	existed := fsys.Exists(toPath)
+	err := fsys.Move(fromPath, toPath)
+	if err == nil || existed {
+		return err
+	}
+	if rollbackErr := rollbackMove(fsys, fromPath, toPath); rollbackErr != nil {
+		return fmt.Errorf("move: %w (failed to roll back: %v)", err, rollbackErr)
+	}
+	return err
+}
+
+// rollbackMove undoes a partial move of a directory. Files that made it to the destination but still
+// exist in the source are copies, so we remove them; the rest are moved back where they came from.
+func rollbackMove(fsys FS, fromPath string, toPath string) error {
+	toPath = path.Clean(toPath)
+	err := Walk(fsys, toPath, func(filePath string, info FileInfo, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		originalPath := path.Join(fromPath, strings.TrimPrefix(filePath, toPath))
+		if fsys.Exists(originalPath) {
+			return fsys.Remove(filePath)
+		}
+		return fsys.Move(filePath, originalPath)
+	})
+	if err != nil {
+		return err
+	}
+	return fsys.Remove(toPath)
+}
+
+// copyBundle copies the bundle into a hidden staging directory next to its destination and then moves
+// it into place, replacing any existing bundle as a whole. Nothing changes at dstPath unless every file
+// was copied successfully.
+func copyBundle(src FS, srcPath string, dst FS, dstPath string, opts []Option) error {
+	name, err := UniqueName(bundleTempPattern, opts...)
+	if err != nil {
+		return fmt.Errorf("filestore: copy: %w", err)
+	}
+	dstDir := path.Dir(path.Clean(dstPath))
+	stagingPath := path.Join(dstDir, name)
+	if err := copyAll(src, srcPath, dst, stagingPath, opts, true); err != nil {
+		_ = dst.Remove(stagingPath)
+		return err
+	}
+
+	// Set the existing bundle aside rather than removing it, so we can put it back if we can't replace it.
+	var trashPath string
+	if dst.Exists(dstPath) {
+		if name, err = UniqueName(bundleTempPattern, opts...); err != nil {
+			_ = dst.Remove(stagingPath)
+			return fmt.Errorf("filestore: copy: %w", err)
+		}
+		trashPath = path.Join(dstDir, name)
+		if err := moveWhole(dst, dstPath, trashPath); err != nil {
+			_ = dst.Remove(stagingPath)
+			return fmt.Errorf("filestore: copy: %w", err)
+		}
+	}
+	if err := moveWhole(dst, stagingPath, dstPath); err != nil {
+		_ = dst.Remove(stagingPath)
+		if trashPath != "" {
+			_ = moveWhole(dst, trashPath, dstPath)
+		}
+		return fmt.Errorf("filestore: copy: %w", err)
+	}
+	if trashPath != "" {
+		if err := dst.Remove(trashPath); err != nil {
+			return fmt.Errorf("filestore: copy: %w", err)
+		}
+	}
+	return nil
+}
+
+var _ requestBinder = &bundleFS{}
+var _ wrapper = &bundleFS{}
+var _ bundler = &bundleFS{}
@@ -0,0 +1,182 @@
+package filestore_test
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type BundleTestSuite struct {
+	suite.Suite
+	inner *flakyFS
+	fs    filestore.FS
+}
+
+func TestBundleTestSuite(t *testing.T) {
+	suite.Run(t, &BundleTestSuite{})
+}
+
+func (s *BundleTestSuite) SetupTest() {
+	s.inner = &flakyFS{FS: filestore.Memory(), failMoveAfter: -1}
+	s.Require().NoError(writeString(s.inner, "drafts/Report.pages/index.xml", "<report/>"))
+	s.Require().NoError(writeString(s.inner, "drafts/Report.pages/Data/chart.png", "chart"))
+	s.Require().NoError(writeString(s.inner, "drafts/Report.pages/preview.jpg", "preview"))
+	s.Require().NoError(writeString(s.inner, "drafts/notes.txt", "abide"))
+	s.fs = filestore.Bundles(s.inner, "pages", ".KEY")
+}
+
+func (s *BundleTestSuite) TestMove() {
+	s.Require().NoError(s.fs.Move("drafts/Report.pages", "final/Report.pages"))
+	s.Require().False(s.inner.Exists("drafts/Report.pages"))
+	s.Require().Equal("chart", s.read("final/Report.pages/Data/chart.png"))
+
+	s.Require().NoError(s.fs.Move("drafts/notes.txt", "final/notes.txt"), "Regular files should move like normal")
+	s.Require().NoError(s.fs.Move("final/Report.pages/index.xml", "final/Report.pages/main.xml"),
+		"Files inside of bundles should move like normal")
+}
+
+func (s *BundleTestSuite) TestMove_rollback() {
+	s.inner.failMoveAfter = 2
+	err := s.fs.Move("drafts/Report.pages", "final/Report.pages")
+	s.Require().Error(err)
+	s.assertWhole("drafts/Report.pages")
+	s.Require().False(s.inner.Exists("final/Report.pages"), "Should remove the files that did move")
+
+	// Directories that contain bundles are protected, too.
+	err = s.fs.Move("drafts", "archive/drafts")
+	s.Require().Error(err)
+	s.assertWhole("drafts/Report.pages")
+	s.Require().Equal("abide", s.read("drafts/notes.txt"))
+	s.Require().False(s.inner.Exists("archive/drafts"))
+}
+
+func (s *BundleTestSuite) TestRemove() {
+	s.inner.failRemove = true
+	s.Require().Error(s.fs.Remove("drafts/Report.pages"))
+	s.Require().False(s.fs.Exists("drafts/Report.pages"), "Bundle should be gone even though removing its files failed")
+
+	files, err := s.fs.List("drafts")
+	s.Require().NoError(err)
+	s.Require().Len(files, 1, "Should hide the bundle we set aside")
+	s.Require().Equal("notes.txt", files[0].Name())
+
+	s.inner.failRemove = false
+	s.Require().NoError(s.fs.Remove("drafts/notes.txt"))
+	s.Require().NoError(s.fs.Remove("drafts/missing.pages"))
+}
+
+func (s *BundleTestSuite) TestCopyAll() {
+	s.Require().NoError(filestore.CopyAll(s.fs, "drafts", s.fs, "backup"))
+	s.assertWhole("backup/Report.pages")
+	s.Require().Equal("abide", s.read("backup/notes.txt"))
+
+	// Existing bundles are replaced as a whole, so files that aren't in the new copy go away.
+	s.Require().NoError(writeString(s.inner, "backup/Report.pages/stale.txt", "stale"))
+	s.Require().NoError(filestore.CopyAll(s.inner, "drafts/Report.pages", s.fs, "backup/Report.pages"))
+	s.assertWhole("backup/Report.pages")
+	s.Require().False(s.inner.Exists("backup/Report.pages/stale.txt"))
+
+	files, err := s.inner.List("backup")
+	s.Require().NoError(err)
+	s.Require().Len(files, 2, "Should not leave any staging directories behind")
+}
+
+func (s *BundleTestSuite) TestCopyAll_failure() {
+	s.Require().NoError(filestore.CopyAll(s.inner, "drafts/Report.pages", s.inner, "backup/Report.pages"))
+	s.inner.failWrite = "preview.jpg"
+
+	err := filestore.CopyAll(s.inner, "drafts/Report.pages", s.fs, "copy/Report.pages")
+	s.Require().Error(err)
+	s.Require().False(s.inner.Exists("copy/Report.pages"), "Should not copy any of the bundle")
+	files, err := s.inner.List("copy")
+	s.Require().NoError(err)
+	s.Require().Empty(files, "Should clean up the staging directory")
+
+	s.Require().NoError(writeString(s.inner, "drafts/Report.pages/index.xml", "<changed/>"))
+	err = filestore.CopyAll(s.fs, "drafts/Report.pages", s.inner, "backup/Report.pages")
+	s.Require().Error(err, "Bundles in the source store should be copied whole, too")
+	s.assertWhole("backup/Report.pages")
+	s.Require().Equal("<report/>", s.read("backup/Report.pages/index.xml"), "Should keep the existing bundle")
+}
+
+func (s *BundleTestSuite) TestFromConfig() {
+	fs, err := filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "bundles", Options: filestore.LayerOptions{"suffixes": []string{".app"}}}},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(fs, "Dude.app/Contents/Info.plist", "abide"))
+	s.Require().NoError(fs.Remove("Dude.app"))
+	s.Require().False(fs.Exists("Dude.app"))
+
+	_, err = filestore.FromConfig(filestore.Config{URL: "mem://", Layers: []filestore.LayerConfig{{Type: "bundles"}}})
+	s.Require().Error(err, "Should require at least one suffix")
+}
+
+func (s *BundleTestSuite) assertWhole(bundlePath string) {
+	s.Require().Equal("<report/>", s.read(path.Join(bundlePath, "index.xml")))
+	s.Require().Equal("chart", s.read(path.Join(bundlePath, "Data/chart.png")))
+	s.Require().Equal("preview", s.read(path.Join(bundlePath, "preview.jpg")))
+}
+
+func (s *BundleTestSuite) read(name string) string {
+	content, err := readString(s.inner, name)
+	s.Require().NoError(err)
+	return content
+}
+
+// flakyFS moves directories one file at a time (like S3), which lets us make it fail part way through.
+type flakyFS struct {
+	filestore.FS
+	// failMoveAfter is the number of files in a directory that we move before failing; -1 never fails.
+	failMoveAfter int
+	// failWrite is the name of a file that always fails to be written.
+	failWrite string
+	// failRemove makes every attempt to remove a directory fail.
+	failRemove bool
+}
+
+func (f *flakyFS) Write(filePath string) (filestore.WriterFile, error) {
+	if f.failWrite != "" && path.Base(filePath) == f.failWrite {
+		return nil, errors.New("flaky write")
+	}
+	return f.FS.Write(filePath)
+}
+
+func (f *flakyFS) Remove(fileOrDirPath string) error {
+	if info, err := f.FS.Stat(fileOrDirPath); f.failRemove && err == nil && info.IsDir() {
+		return errors.New("flaky remove")
+	}
+	return f.FS.Remove(fileOrDirPath)
+}
+
+func (f *flakyFS) Move(fromPath string, toPath string) error {
+	info, err := f.FS.Stat(fromPath)
+	if err != nil || !info.IsDir() || f.failMoveAfter < 0 {
+		return f.FS.Move(fromPath, toPath)
+	}
+
+	var files []string
+	err = filestore.Walk(f.FS, fromPath, func(filePath string, info filestore.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, filePath)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for i, filePath := range files {
+		if i == f.failMoveAfter {
+			return errors.New("flaky move")
+		}
+		if err := f.FS.Move(filePath, toPath+strings.TrimPrefix(filePath, fromPath)); err != nil {
+			return err
+		}
+	}
+	return f.FS.Remove(fromPath)
+}
@@ -0,0 +1,316 @@
+package filestore
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+)
+
+// cacheOptions contains the settings that only apply to Cached().
+type cacheOptions struct {
+	maxSize int64
+}
+
+// WithCacheSize limits how much local disk space Cached() uses for its copies of remote files. Once the
+// cache is bigger than this, the least recently read files are evicted until it fits again. Files bigger
+// than the entire cache are never cached. By default, the cache holds up to 1GB.
+func WithCacheSize(bytes int64) Option {
+	return func(opts *options) {
+		if bytes > 0 {
+			opts.cache.maxSize = bytes
+		}
+	}
+}
+
+// Cached wraps a (typically remote) file store so that Read() serves files from copies in a local
+// directory. The first time you read a file, we download the whole thing into the cache; subsequent reads
+// don't touch the remote store at all. Writing, moving, or removing a file through the wrapper evicts the
+// cached copies of everything involved, so you always read your own writes. Use WithCacheSize() to limit
+// how much disk space the cache can use; the least recently read files are evicted first.
+//
+// Only file contents are cached. Stat(), List(), and Exists() still go to the remote store, and the wrapper
+// can't know about changes that other processes make to the remote store, so only cache stores whose files
+// are modified through the wrapper (or not at all). The cache only lives for as long as the process does;
+// copies left in the directory by a previous process are discarded because we can't tell whether the remote
+// files changed in the meantime. Those are the only files in the directory that the wrapper ever touches.
+//
+// Example:
+//
+//	assets := filestore.Cached(filestore.S3("assets"), "/var/cache/assets", filestore.WithCacheSize(10<<30))
+//	file, err := assets.Read("images/splash.png") // downloads it from S3 the first time only
+func Cached(remote FS, localDir string, opts ...Option) FS {
+	options := newOptions(opts)
+	cache := &fileCache{
+		local:   Disk(localDir),
+		root:    remote.WorkingDirectory(),
+		maxSize: options.cache.maxSize,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+	cache.clear()
+	return &cachedFS{FS: remote, cache: cache}
+}
+
+func init() {
+	RegisterLayer("cached", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			LocalDir string `json:"localDir"`
+			MaxSize  int64  `json:"maxSize"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		if settings.LocalDir == "" {
+			return nil, fmt.Errorf("cached: localDir is required")
+		}
+		return Cached(fs, settings.LocalDir, WithCacheSize(settings.MaxSize)), nil
+	})
+}
+
+type cachedFS struct {
+	FS
+	cache *fileCache
+}
+
+// cachedFilePattern matches the names of the files that we store in the cache directory, including the
+// temporary files that we download them to.
+var cachedFilePattern = regexp.MustCompile(`^[0-9a-f]{64}\.cache(\.[0-9a-f]{16}\.tmp)?$`)
+
+// fileCache tracks the files in the cache directory, shared by the original wrapper and any instances
+// derived from it via ChangeDirectory().
+type fileCache struct {
+	mutex   sync.Mutex
+	local   FS
+	root    string
+	maxSize int64
+	size    int64
+	// entries maps each remote file's path (relative to the root of the remote store) to its element in
+	// the lru list, whose values are *cacheEntry.
+	entries map[string]*list.Element
+	// lru contains every cached file, most recently read first.
+	lru *list.List
+	// generation changes every time we evict files because they changed, so that a download that was
+	// already in progress doesn't put a stale copy into the cache.
+	generation uint64
+}
+
+// cacheEntry is a single remote file that's been copied into the cache directory.
+type cacheEntry struct {
+	key  string
+	name string
+	size int64
+}
+
+// key determines which file a path refers to, relative to the root of the remote store, regardless
+// of which directory the caller cd'd into.
+func (c *cachedFS) key(filePath string) string {
+	return relativeTo(c.cache.root, joinPath(c.FS.WorkingDirectory(), filePath))
+}
+
+// Read opens the cached copy of the file, downloading it from the remote store first if necessary.
+func (c *cachedFS) Read(filePath string) (ReaderFile, error) {
+	key := c.key(filePath)
+	if file, ok := c.cache.read(key); ok {
+		return file, nil
+	}
+
+	generation := c.cache.currentGeneration()
+	remote, err := c.FS.Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer remote.Close()
+
+	file, err := c.cache.populate(key, generation, remote)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: cached: read: %s: %w", filePath, err)
+	}
+	return file, nil
+}
+
+// Write opens the file for writing in the remote store, evicting any cached copy of it.
+func (c *cachedFS) Write(filePath string) (WriterFile, error) {
+	key := c.key(filePath)
+	c.cache.evict(key)
+	file, err := c.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedWriterFile{WriterFile: file, cache: c.cache, key: key}, nil
+}
+
+// Move relocates the file/directory in the remote store, evicting any cached copies of either path.
+func (c *cachedFS) Move(fromPath string, toPath string) error {
+	defer c.cache.evict(c.key(fromPath))
+	defer c.cache.evict(c.key(toPath))
+	return c.FS.Move(fromPath, toPath)
+}
+
+// Remove deletes the file/directory from the remote store, evicting any cached copies of it.
+func (c *cachedFS) Remove(fileOrDirPath string) error {
+	defer c.cache.evict(c.key(fileOrDirPath))
+	return c.FS.Remove(fileOrDirPath)
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that shares the same cache.
+func (c *cachedFS) ChangeDirectory(dir string) FS {
+	return &cachedFS{FS: c.FS.ChangeDirectory(dir), cache: c.cache}
+}
+
+func (c *cachedFS) withContext(ctx context.Context) FS {
+	return &cachedFS{FS: ForRequest(c.FS, ctx), cache: c.cache}
+}
+
+func (c *cachedFS) requestContext() context.Context {
+	return RequestContext(c.FS)
+}
+
+func (c *cachedFS) wrapped() []FS {
+	return []FS{c.FS, c.cache.local}
+}
+
+// read opens the cached copy of the remote file, if we have one.
+func (c *fileCache) read(key string) (ReaderFile, bool) {
+	c.mutex.Lock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.mutex.Unlock()
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	name := element.Value.(*cacheEntry).name
+	c.mutex.Unlock()
+
+	file, err := c.local.Read(name)
+	if err != nil {
+		// Someone removed it out from under us, so we'll just have to download it again.
+		c.evict(key)
+		return nil, false
+	}
+	return file, true
+}
+
+// populate copies the remote file into the cache and opens the copy. The copy is only kept if nothing
+// was evicted since the given generation (otherwise, it might be out of date already) and if it's not
+// bigger than the entire cache.
+func (c *fileCache) populate(key string, generation uint64, remote io.Reader) (ReaderFile, error) {
+	name := cacheFileName(key)
+	tempName, err := UniqueName(name + ".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = c.local.Remove(tempName) }()
+
+	output, err := c.local.Write(tempName)
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(output, remote)
+	if err != nil {
+		_ = output.Close()
+		return nil, err
+	}
+	if err = output.Close(); err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.generation != generation || size > c.maxSize {
+		// We can still let the caller read what they asked for; we just can't keep it.
+		return c.local.Read(tempName)
+	}
+	if err = c.local.Move(tempName, name); err != nil {
+		return nil, err
+	}
+	if element, ok := c.entries[key]; ok {
+		// Someone else downloaded it at the same time, and we just replaced their copy.
+		c.size -= element.Value.(*cacheEntry).size
+		c.lru.Remove(element)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, name: name, size: size})
+	c.size += size
+	c.shrink()
+	return c.local.Read(name)
+}
+
+// evict removes the cached copies of the file at the path, or everything in the directory at the path.
+func (c *fileCache) evict(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	for entryKey, element := range c.entries {
+		if isWithinPath(key, entryKey) {
+			c.remove(element)
+		}
+	}
+}
+
+// shrink evicts the least recently read files until the cache fits within its maximum size. You must
+// hold the mutex to call this.
+func (c *fileCache) shrink() {
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove deletes the cached copy of a single file. You must hold the mutex to call this.
+func (c *fileCache) remove(element *list.Element) {
+	entry := element.Value.(*cacheEntry)
+	c.lru.Remove(element)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+	_ = c.local.Remove(entry.name)
+}
+
+func (c *fileCache) currentGeneration() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.generation
+}
+
+// clear discards any copies left in the cache directory by a previous process.
+func (c *fileCache) clear() {
+	infos, err := c.local.List(".", WithName(cachedFilePattern.MatchString))
+	if err != nil {
+		return
+	}
+	for _, info := range infos {
+		_ = c.local.Remove(info.Name())
+	}
+}
+
+// cacheFileName determines the name of the file in the cache directory that holds a copy of the remote
+// file. We hash the path so that every remote file is a single file in the directory, no matter how
+// deeply nested (or long) its path is.
+func cacheFileName(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:]) + ".cache"
+}
+
+// cachedWriterFile evicts the file's cached copy again once it's been written, in case someone read
+// (and cached) the old version while we were writing the new one.
+type cachedWriterFile struct {
+	WriterFile
+	cache  *fileCache
+	key    string
+	closed bool
+}
+
+func (f *cachedWriterFile) Close() error {
+	if f.closed {
+		return f.WriterFile.Close()
+	}
+	f.closed = true
+	defer f.cache.evict(f.key)
+	return f.WriterFile.Close()
+}
+
+var _ requestBinder = &cachedFS{}
+var _ wrapper = &cachedFS{}
@@ -0,0 +1,147 @@
+package filestore_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type CachedTestSuite struct {
+	suite.Suite
+	remote   *countingFS
+	localDir string
+	fs       filestore.FS
+}
+
+func TestCachedTestSuite(t *testing.T) {
+	suite.Run(t, &CachedTestSuite{})
+}
+
+func (s *CachedTestSuite) SetupTest() {
+	s.remote = &countingFS{FS: filestore.Memory(), reads: map[string]int{}}
+	s.localDir = s.T().TempDir()
+	s.fs = filestore.Cached(s.remote, s.localDir)
+
+	s.Require().NoError(writeString(s.remote, "images/splash.png", "splash"))
+	s.Require().NoError(writeString(s.remote, "images/logo.png", "logo"))
+	s.Require().NoError(writeString(s.remote, "readme.md", "the dude abides"))
+}
+
+func (s *CachedTestSuite) TestRead() {
+	s.assertContent(s.fs, "images/splash.png", "splash")
+	s.assertContent(s.fs, "images/splash.png", "splash")
+	s.assertContent(s.fs.ChangeDirectory("images"), "splash.png", "splash")
+	s.Require().Equal(1, s.remote.readCount("images/splash.png"), "Should only download it once")
+
+	_, err := s.fs.Read("images/missing.png")
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+
+	entries, err := os.ReadDir(s.localDir)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1, "Should only leave the cached copy in the directory")
+}
+
+func (s *CachedTestSuite) TestWrite() {
+	s.assertContent(s.fs, "images/splash.png", "splash")
+	s.Require().NoError(writeString(s.fs, "images/splash.png", "new splash"))
+	s.assertContent(s.fs, "images/splash.png", "new splash")
+	s.assertContent(s.remote, "images/splash.png", "new splash")
+
+	s.assertContent(s.fs, "images/logo.png", "logo")
+	s.Require().NoError(writeString(s.fs.ChangeDirectory("images"), "logo.png", "new logo"))
+	s.assertContent(s.fs, "images/logo.png", "new logo")
+}
+
+func (s *CachedTestSuite) TestMoveAndRemove() {
+	s.assertContent(s.fs, "images/splash.png", "splash")
+	s.assertContent(s.fs, "images/logo.png", "logo")
+	s.assertContent(s.fs, "readme.md", "the dude abides")
+
+	s.Require().NoError(s.fs.Move("images/logo.png", "readme.md"))
+	s.assertContent(s.fs, "readme.md", "logo")
+	_, err := s.fs.Read("images/logo.png")
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+
+	s.Require().NoError(s.fs.Remove("images"))
+	_, err = s.fs.Read("images/splash.png")
+	s.Require().ErrorIs(err, fs.ErrNotExist, "Should evict everything in the directory")
+}
+
+func (s *CachedTestSuite) TestCacheSize() {
+	s.fs = filestore.Cached(s.remote, s.localDir, filestore.WithCacheSize(12))
+	s.assertContent(s.fs, "images/splash.png", "splash")
+	s.assertContent(s.fs, "images/logo.png", "logo")
+	s.assertContent(s.fs, "images/splash.png", "splash")
+	s.Require().Equal(1, s.remote.readCount("images/splash.png"))
+
+	// Both won't fit, so the least recently read one (the logo) should be evicted.
+	s.Require().NoError(writeString(s.remote, "images/icon.png", "icon"))
+	s.assertContent(s.fs, "images/icon.png", "icon")
+	s.assertContent(s.fs, "images/splash.png", "splash")
+	s.assertContent(s.fs, "images/logo.png", "logo")
+	s.Require().Equal(1, s.remote.readCount("images/splash.png"))
+	s.Require().Equal(2, s.remote.readCount("images/logo.png"))
+
+	s.assertContent(s.fs, "readme.md", "the dude abides")
+	s.assertContent(s.fs, "readme.md", "the dude abides")
+	s.Require().Equal(2, s.remote.readCount("readme.md"), "Should not cache files bigger than the cache")
+}
+
+func (s *CachedTestSuite) TestLeftovers() {
+	s.assertContent(s.fs, "images/splash.png", "splash")
+	other := filepath.Join(s.localDir, "other.txt")
+	s.Require().NoError(os.WriteFile(other, []byte("not ours"), 0o644))
+
+	restarted := filestore.Cached(s.remote, s.localDir)
+	entries, err := os.ReadDir(s.localDir)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1, "Should discard copies from the previous process")
+	s.Require().Equal("other.txt", entries[0].Name(), "Should leave other files alone")
+
+	s.assertContent(restarted, "images/splash.png", "splash")
+	s.Require().Equal(2, s.remote.readCount("images/splash.png"))
+}
+
+func (s *CachedTestSuite) TestFromConfig() {
+	_, err := filestore.FromConfig(filestore.Config{URL: "mem://", Layers: []filestore.LayerConfig{{Type: "cached"}}})
+	s.Require().Error(err, "Should require a local directory")
+
+	cached, err := filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "cached", Options: filestore.LayerOptions{"localDir": s.localDir, "maxSize": 1024}}},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(cached, "dude.txt", "abide"))
+	s.assertContent(cached, "dude.txt", "abide")
+}
+
+func (s *CachedTestSuite) assertContent(fsys filestore.FS, filePath string, expected string) {
+	content, err := readString(fsys, filePath)
+	s.Require().NoError(err)
+	s.Require().Equal(expected, content)
+}
+
+// countingFS keeps track of how many times each file was opened for reading.
+type countingFS struct {
+	filestore.FS
+	mutex sync.Mutex
+	reads map[string]int
+}
+
+func (f *countingFS) Read(filePath string) (filestore.ReaderFile, error) {
+	f.mutex.Lock()
+	f.reads[filepath.ToSlash(filepath.Join(f.FS.WorkingDirectory(), filePath))]++
+	f.mutex.Unlock()
+	return f.FS.Read(filePath)
+}
+
+func (f *countingFS) readCount(filePath string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.reads[filepath.ToSlash(filepath.Join(f.FS.WorkingDirectory(), filePath))]
+}
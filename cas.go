@@ -0,0 +1,239 @@
+package filestore
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// CAS is a content-addressable store of blobs: each blob is identified by the SHA-256 hash of its contents
+// rather than a name you choose, so identical content is only ever stored once. Since a blob's hash
+// alone doesn't tell you what it's for, you attach named references to blobs (e.g. "builds/1234/app.tar")
+// and let GC() reclaim any blob that nothing refers to anymore. This is the typical layout for artifact and
+// build caches.
+//
+// Blobs live in the "blobs" directory of the underlying store and references in the "refs" directory,
+// so you can point a CAS at any store (or subdirectory of one) that you like.
+type CAS struct {
+	fs FS
+	// gc keeps GC() from collecting a blob in between the time it's stored and the time its reference is.
+	gc *sync.RWMutex
+}
+
+// ContentAddressed creates a content-addressable store whose blobs and references live in the given store.
+// Collecting garbage w/ GC() is only safe w/ respect to Put() and Ref() calls made through the same *CAS,
+// so don't collect garbage from one process while another is adding blobs to the same store.
+//
+// Example:
+//
+//	artifacts := filestore.ContentAddressed(filestore.Disk("/var/cache/artifacts"))
+//	hash, err := artifacts.Put(tarball, "builds/1234/app.tar")
+//	...
+//	err = artifacts.Unref("builds/1234/app.tar")
+//	removed, err := artifacts.GC()
+func ContentAddressed(fs FS) *CAS {
+	return &CAS{fs: fs, gc: &sync.RWMutex{}}
+}
+
+const (
+	casBlobDir = "blobs"
+	casRefDir  = "refs"
+)
+
+// ErrInvalidHash is the error returned by a CAS when you give it a hash that isn't a hex-encoded SHA-256.
+var ErrInvalidHash = errors.New("filestore: invalid content hash")
+
+// Put stores everything in the reader as a blob and returns its hash, which you use to read it back. If
+// a blob w/ identical contents already exists, we just return its hash w/o storing another copy. Any
+// names you supply will refer to the blob (see Ref) once we're done. A blob that nothing refers to is
+// removed by the next GC(), so you'll typically want at least one.
+func (c *CAS) Put(r io.Reader, refs ...string) (string, error) {
+	c.gc.RLock()
+	defer c.gc.RUnlock()
+
+	blobPath, err := Put(c.fs, casBlobDir, "", r)
+	if err != nil {
+		return "", fmt.Errorf("filestore: cas: put: %w", err)
+	}
+	hash := path.Base(blobPath)
+	for _, name := range refs {
+		if err = c.writeRef(name, hash); err != nil {
+			return "", fmt.Errorf("filestore: cas: put: %w", err)
+		}
+	}
+	return hash, nil
+}
+
+// Read opens the blob w/ the given hash for reading.
+func (c *CAS) Read(hash string) (ReaderFile, error) {
+	blobPath, err := c.blobPath(hash)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: cas: read: %w", err)
+	}
+	file, err := c.fs.Read(blobPath)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: cas: read: %w", err)
+	}
+	return file, nil
+}
+
+// Exists returns true when a blob w/ the given hash is in the store.
+func (c *CAS) Exists(hash string) bool {
+	blobPath, err := c.blobPath(hash)
+	return err == nil && c.fs.Exists(blobPath)
+}
+
+// Ref makes the name refer to the blob w/ the given hash, replacing whatever it referred to before. Names
+// are slash-separated paths like "builds/1234/app.tar". It fails w/ fs.ErrNotExist if there's no such blob.
+func (c *CAS) Ref(name string, hash string) error {
+	c.gc.RLock()
+	defer c.gc.RUnlock()
+
+	if !c.Exists(hash) {
+		return fmt.Errorf("filestore: cas: ref: %s: %w", hash, fs.ErrNotExist)
+	}
+	if err := c.writeRef(name, hash); err != nil {
+		return fmt.Errorf("filestore: cas: ref: %w", err)
+	}
+	return nil
+}
+
+// Resolve returns the hash of the blob that the name refers to. It fails w/ fs.ErrNotExist if the name
+// doesn't refer to anything.
+func (c *CAS) Resolve(name string) (string, error) {
+	refPath, err := casRefPath(name)
+	if err != nil {
+		return "", fmt.Errorf("filestore: cas: resolve: %w", err)
+	}
+	hash, err := c.readRef(refPath)
+	if err != nil {
+		return "", fmt.Errorf("filestore: cas: resolve: %w", err)
+	}
+	return hash, nil
+}
+
+// Unref removes the name so it no longer refers to any blob. The blob itself sticks around until GC()
+// finds that nothing else refers to it. Removing a name that doesn't exist does nothing.
+func (c *CAS) Unref(name string) error {
+	refPath, err := casRefPath(name)
+	if err != nil {
+		return fmt.Errorf("filestore: cas: unref: %w", err)
+	}
+	if err = c.fs.Remove(refPath); err != nil {
+		return fmt.Errorf("filestore: cas: unref: %w", err)
+	}
+	return nil
+}
+
+// GC removes every blob that no name refers to, returning the hashes of the blobs it removed.
+func (c *CAS) GC() ([]string, error) {
+	c.gc.Lock()
+	defer c.gc.Unlock()
+
+	referenced := map[string]bool{}
+	err := Walk(c.fs, casRefDir, func(refPath string, info FileInfo, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist) && refPath == casRefDir:
+			return nil
+		case err != nil:
+			return err
+		case info.IsDir():
+			return nil
+		}
+		hash, err := c.readRef(refPath)
+		if err != nil {
+			return err
+		}
+		referenced[hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("filestore: cas: gc: %w", err)
+	}
+
+	blobs, err := c.fs.List(casBlobDir)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: cas: gc: %w", err)
+	}
+	var removed []string
+	for _, blob := range blobs {
+		// Leave behind anything that isn't a blob, like the temp files of Put() calls in progress.
+		hash := blob.Name()
+		if blob.IsDir() || !isContentHash(hash) || referenced[hash] {
+			continue
+		}
+		if err = c.fs.Remove(path.Join(casBlobDir, hash)); err != nil {
+			return removed, fmt.Errorf("filestore: cas: gc: %w", err)
+		}
+		removed = append(removed, hash)
+	}
+	return removed, nil
+}
+
+// blobPath determines where the blob w/ the given hash is stored.
+func (c *CAS) blobPath(hash string) (string, error) {
+	if !isContentHash(hash) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidHash, hash)
+	}
+	return path.Join(casBlobDir, hash), nil
+}
+
+// writeRef writes the reference file for the name, whose entire contents is the blob's hash.
+func (c *CAS) writeRef(name string, hash string) error {
+	refPath, err := casRefPath(name)
+	if err != nil {
+		return err
+	}
+	file, err := c.fs.Write(refPath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.WriteString(file, hash); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// casRefPath is the path of the reference file for the name. Names that resolve to the reference directory
+// itself (e.g. "" or ".") are rejected; otherwise Unref() would remove every reference.
+func casRefPath(name string) (string, error) {
+	refPath, err := SafeJoin(casRefDir, name)
+	if err != nil {
+		return "", err
+	}
+	if refPath == casRefDir {
+		return "", fmt.Errorf("invalid ref name: %q", name)
+	}
+	return refPath, nil
+}
+
+// readRef reads the hash from the reference file.
+func (c *CAS) readRef(refPath string) (string, error) {
+	file, err := c.fs.Read(refPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	hash := strings.TrimSpace(string(data))
+	if !isContentHash(hash) {
+		return "", fmt.Errorf("%s: %w", refPath, ErrInvalidHash)
+	}
+	return hash, nil
+}
+
+// isContentHash returns true when the value looks like a hex-encoded SHA-256 hash, which is what Put() names blobs.
+func isContentHash(value string) bool {
+	decoded, err := hex.DecodeString(value)
+	return err == nil && len(decoded) == 32 && strings.ToLower(value) == value
+}
@@ -0,0 +1,106 @@
+package filestore_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type CASTestSuite struct {
+	suite.Suite
+	fs  filestore.FS
+	cas *filestore.CAS
+}
+
+func TestCASTestSuite(t *testing.T) {
+	suite.Run(t, &CASTestSuite{})
+}
+
+// echo -n "abide" | sha256sum
+const abideHash = "072469151f6451023b0cbcab21730504d661cf6ca6958135f2fc7c9a59174ff8"
+
+func (s *CASTestSuite) SetupTest() {
+	s.fs = filestore.Memory()
+	s.cas = filestore.ContentAddressed(s.fs.ChangeDirectory("cache"))
+}
+
+func (s *CASTestSuite) TestPutAndRead() {
+	hash, err := s.cas.Put(strings.NewReader("abide"), "builds/1/app.tar")
+	s.Require().NoError(err)
+	s.Require().Equal(abideHash, hash)
+	s.Require().True(s.cas.Exists(hash))
+	s.Require().Equal("abide", s.read(hash))
+
+	again, err := s.cas.Put(strings.NewReader("abide"), "builds/2/app.tar")
+	s.Require().NoError(err)
+	s.Require().Equal(hash, again)
+	blobs, err := s.fs.List("cache/blobs")
+	s.Require().NoError(err)
+	s.Require().Len(blobs, 1, "Should only store identical content once")
+
+	resolved, err := s.cas.Resolve("builds/2/app.tar")
+	s.Require().NoError(err)
+	s.Require().Equal(hash, resolved)
+
+	_, err = s.cas.Resolve("builds/3/app.tar")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = s.cas.Read("nope")
+	s.Require().True(errors.Is(err, filestore.ErrInvalidHash))
+	s.Require().False(s.cas.Exists("../../etc/passwd"))
+}
+
+func (s *CASTestSuite) TestRef() {
+	hash, err := s.cas.Put(strings.NewReader("abide"))
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.cas.Ref("latest", hash))
+	resolved, err := s.cas.Resolve("latest")
+	s.Require().NoError(err)
+	s.Require().Equal(hash, resolved)
+
+	missing := strings.Repeat("0", 64)
+	s.Require().True(errors.Is(s.cas.Ref("latest", missing), fs.ErrNotExist))
+	s.Require().True(errors.Is(s.cas.Ref("../escape", hash), filestore.ErrPathEscapesBase))
+}
+
+func (s *CASTestSuite) TestGC() {
+	removed, err := s.cas.GC()
+	s.Require().NoError(err)
+	s.Require().Empty(removed, "Should handle an empty store")
+
+	kept, err := s.cas.Put(strings.NewReader("abide"), "a", "b")
+	s.Require().NoError(err)
+	dropped, err := s.cas.Put(strings.NewReader("walter"), "c")
+	s.Require().NoError(err)
+	orphan, err := s.cas.Put(strings.NewReader("donny"))
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.cas.Unref("a"))
+	s.Require().NoError(s.cas.Unref("c"))
+	s.Require().NoError(s.cas.Unref("nope"))
+	s.Require().Error(s.cas.Unref(""), "Should not remove every reference")
+	s.Require().Error(s.cas.Unref("."), "Should not remove every reference")
+	s.Require().Error(s.cas.Unref("b/.."), "Should not remove every reference")
+
+	removed, err = s.cas.GC()
+	s.Require().NoError(err)
+	s.Require().ElementsMatch([]string{dropped, orphan}, removed)
+	s.Require().True(s.cas.Exists(kept), "Should keep blobs that something still refers to")
+	s.Require().False(s.cas.Exists(dropped))
+	s.Require().False(s.cas.Exists(orphan))
+}
+
+func (s *CASTestSuite) read(hash string) string {
+	file, err := s.cas.Read(hash)
+	s.Require().NoError(err)
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	return string(data)
+}
@@ -0,0 +1,153 @@
+package filestore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// ErrChecksumMismatch is the error returned when the data we assembled/received doesn't hash to the
+// checksum the client said it should.
+var ErrChecksumMismatch = errors.New("filestore: checksum mismatch")
+
+// chunksDir is the path of the directory that holds the chunks received so far for the given file.
+// The file's path is escaped into a single segment so that "a" and "a/b" don't collide.
+func (u *UploadSession) chunksDir(filePath string) string {
+	return path.Join(u.dir(), "chunks", url.PathEscape(path.Clean(filePath)))
+}
+
+// WriteChunk stores a single chunk of the given file, the server-side half of resumable upload libraries
+// that send large files in pieces. Chunks are numbered from 0 and can arrive in any order (or more than
+// once; the last copy wins). They're staged in the session until you call AssembleChunks(), so they
+// survive across requests/restarts and count as activity as far as CollectUploads() is concerned.
+//
+// Example:
+//
+//	// e.g. POST /uploads/{token}/chunks?file=video.mp4&index=3
+//	session, err := filestore.ResumeUpload(files, token)
+//	...
+//	err = session.WriteChunk(query.Get("file"), index, req.Body)
+func (u *UploadSession) WriteChunk(filePath string, index int, r io.Reader) error {
+	if index < 0 {
+		return fmt.Errorf("filestore: write chunk: %s: invalid chunk index %d", filePath, index)
+	}
+	file, err := u.store.Write(path.Join(u.chunksDir(filePath), strconv.Itoa(index)))
+	if err != nil {
+		return fmt.Errorf("filestore: write chunk: %s: %w", filePath, err)
+	}
+	if _, err = io.Copy(file, r); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("filestore: write chunk: %s: %w", filePath, err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("filestore: write chunk: %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// Chunks returns the indexes of the chunks we've received so far for the given file, in ascending
+// order. Resumable upload clients use this to figure out which chunks they still need to send.
+func (u *UploadSession) Chunks(filePath string) ([]int, error) {
+	infos, err := u.store.List(u.chunksDir(filePath))
+	if err != nil {
+		return nil, fmt.Errorf("filestore: chunks: %s: %w", filePath, err)
+	}
+	var indexes []int
+	for _, info := range infos {
+		if index, err := strconv.Atoi(info.Name()); err == nil && !info.IsDir() {
+			indexes = append(indexes, index)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// AssembleChunks concatenates chunks 0 through count-1 of the given file into the file itself, which
+// lives in the session's staging area like any other uploaded file until you Commit() the session. It
+// fails w/ fs.ErrNotExist if any of the chunks are missing.
+//
+// The chunks are assembled into a temp file in the session that only replaces the file once it's complete.
+// When you supply a hash and the expected checksum, the assembled data must hash to that checksum or
+// assembly fails w/ ErrChecksumMismatch, the temp file is discarded, and any previously assembled copy
+// of the file is left untouched. Pass a nil hash to skip verification. Once the file has been assembled
+// successfully, we discard its chunks.
+//
+// Example:
+//
+//	expected, err := hex.DecodeString(req.Header.Get("X-Upload-Sha256"))
+//	...
+//	err = session.AssembleChunks("video.mp4", totalChunks, sha256.New(), expected)
+//	...
+//	err = session.Commit("videos")
+func (u *UploadSession) AssembleChunks(filePath string, count int, h hash.Hash, checksum []byte) error {
+	chunksDir := u.chunksDir(filePath)
+	chunkPath := func(index int) string {
+		return path.Join(chunksDir, strconv.Itoa(index))
+	}
+
+	// Make sure we have everything before we start copying so that we don't read (and hash) most of
+	// a huge file only to find out we can't finish it.
+	for index := 0; index < count; index++ {
+		if !u.store.Exists(chunkPath(index)) {
+			return fmt.Errorf("filestore: assemble chunks: %s: chunk %d: %w", filePath, index, fs.ErrNotExist)
+		}
+	}
+
+	// Assemble into a temp file outside of the staging area so that a previously assembled copy of the
+	// file stays put (and a concurrent Commit() doesn't pick up a half-written one) until we've verified it.
+	output, tempPath, err := TempFile(u.store, u.dir(), "assemble-*.tmp")
+	if err != nil {
+		return fmt.Errorf("filestore: assemble chunks: %s: %w", filePath, err)
+	}
+	defer func() { _ = u.store.Remove(tempPath) }()
+
+	var writer io.Writer = output
+	if h != nil {
+		h.Reset()
+		writer = io.MultiWriter(output, h)
+	}
+	for index := 0; index < count; index++ {
+		if err = u.appendChunk(writer, chunkPath(index)); err != nil {
+			_ = output.Close()
+			return fmt.Errorf("filestore: assemble chunks: %s: chunk %d: %w", filePath, index, err)
+		}
+	}
+	if err = output.Close(); err != nil {
+		return fmt.Errorf("filestore: assemble chunks: %s: %w", filePath, err)
+	}
+
+	if h != nil && !bytes.Equal(h.Sum(nil), checksum) {
+		return fmt.Errorf("filestore: assemble chunks: %s: %w", filePath, ErrChecksumMismatch)
+	}
+	if err = u.store.Move(tempPath, u.stagedPath(filePath)); err != nil {
+		return fmt.Errorf("filestore: assemble chunks: %s: %w", filePath, err)
+	}
+	if err = u.store.Remove(chunksDir); err != nil {
+		return fmt.Errorf("filestore: assemble chunks: %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// stagedPath is where the given file lives in the session's staging area, relative to the store's working
+// directory. Like the staging area's own ChangeDirectory(), ".." can't climb out of it.
+func (u *UploadSession) stagedPath(filePath string) string {
+	return path.Join(u.dir(), "files", path.Clean("/"+filePath))
+}
+
+func (u *UploadSession) appendChunk(w io.Writer, chunkPath string) error {
+	chunk, err := u.store.Read(chunkPath)
+	if err != nil {
+		return err
+	}
+	defer chunk.Close()
+
+	_, err = io.Copy(w, chunk)
+	return err
+}
@@ -0,0 +1,31 @@
+package filestore
+
+import (
+	"time"
+)
+
+// Clock is the source of the current time for any store or wrapper that needs to know what time
+// it is (modification times, TTLs, retention, etc.). In production you'll just use the SystemClock(),
+// but tests can supply a fake clock (see filestoretest.Clock) so they can advance time
+// deterministically rather than sleeping.
+type Clock interface {
+	// Now returns the current time according to this clock.
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+}
+
+// SystemClock returns a Clock that simply reports the real time of the system.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,475 @@
+package filestore
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is an algorithm that Compressed() uses to shrink files as you write them and restore them
+// as you read them. You can use one of the built-in algorithms (e.g. Gzip) or implement your own.
+type Compression interface {
+	// NewWriter returns a writer that compresses everything written to it and writes the result to w.
+	// Closing it must flush any remaining data, but it must NOT close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader that decompresses the data read from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Gzip compresses files using gzip w/ the default compression level. Use GzipLevel() for another level.
+var Gzip Compression = GzipLevel(gzip.DefaultCompression)
+
+// GzipLevel compresses files using gzip w/ the given level, from gzip.BestSpeed (1) to gzip.BestCompression
+// (9). Invalid levels fail when you write a file.
+func GzipLevel(level int) Compression {
+	return gzipCompression{level: level}
+}
+
+type gzipCompression struct {
+	level int
+}
+
+func (c gzipCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+func (c gzipCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// Zstd compresses files using Zstandard w/ the default compression level. It's typically both faster and
+// smaller than gzip. Use ZstdLevel() for another level or ZstdDictionary() to supply a dictionary.
+var Zstd Compression = ZstdLevel(3)
+
+// ZstdLevel compresses files using Zstandard w/ the given level, from 1 (fastest) to 22 (smallest). Like the
+// zstd command, levels beyond the ones the encoder implements use its best level.
+func ZstdLevel(level int) Compression {
+	return zstdCompression{level: level}
+}
+
+// ZstdDictionary compresses files using Zstandard w/ the given level and dictionary (e.g. one built by
+// "zstd --train" from a sample of your files). Dictionaries make a big difference for lots of small,
+// similar files like JSON documents. You must use the same dictionary to read the files back.
+func ZstdDictionary(level int, dictionary []byte) Compression {
+	return zstdCompression{level: level, dictionary: dictionary}
+}
+
+type zstdCompression struct {
+	level      int
+	dictionary []byte
+}
+
+func (c zstdCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	if c.level < 1 || c.level > 22 {
+		return nil, fmt.Errorf("zstd: invalid compression level: %d", c.level)
+	}
+	options := []zstd.EOption{zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(c.level))}
+	if c.dictionary != nil {
+		options = append(options, zstd.WithEncoderDict(c.dictionary))
+	}
+	return zstd.NewWriter(w, options...)
+}
+
+func (c zstdCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	var options []zstd.DOption
+	if c.dictionary != nil {
+		options = append(options, zstd.WithDecoderDicts(c.dictionary))
+	}
+	decoder, err := zstd.NewReader(r, options...)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+// Compressed wraps a file store so that every file is compressed as you Write() it and decompressed as you
+// Read() it, so callers never have to deal w/ the compressed bytes. Paths don't change; writing "app.log"
+// stores the compressed data at "app.log" rather than "app.log.gz". Since Stat() and List() describe the
+// files that are actually stored, sizes are the compressed sizes. List() filters, however, see the
+// decompressed sizes so that they behave the same as they would w/o compression.
+//
+// Files are compressed/decompressed as a stream, so they never have to fit in memory. As a result, you can
+// only write files sequentially (Seek() and WriteAt() only work for the current offset). Readers support
+// Seek() and ReadAt(), but anything other than reading forward decompresses the file from the beginning.
+//
+// Example:
+//
+//	archive := filestore.Compressed(filestore.S3("logs"), filestore.Gzip)
+//	output, err := archive.Write("2022/09/06/app.log")
+func Compressed(fs FS, compression Compression) FS {
+	return &compressedFS{FS: fs, compression: compression, name: "compressed"}
+}
+
+func init() {
+	RegisterLayer("compressed", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Algorithm      string `json:"algorithm"`
+			Level          *int   `json:"level"`
+			DictionaryFile string `json:"dictionaryFile"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(settings.Algorithm) {
+		case "", "gzip":
+			if settings.Level != nil {
+				return Compressed(fs, GzipLevel(*settings.Level)), nil
+			}
+			return Compressed(fs, Gzip), nil
+		case "zstd":
+			level := 3
+			if settings.Level != nil {
+				level = *settings.Level
+			}
+			var dictionary []byte
+			if settings.DictionaryFile != "" {
+				data, err := os.ReadFile(settings.DictionaryFile)
+				if err != nil {
+					return nil, fmt.Errorf("compressed: dictionary: %w", err)
+				}
+				dictionary = data
+			}
+			return Compressed(fs, ZstdDictionary(level, dictionary)), nil
+		default:
+			return nil, fmt.Errorf("compressed: unknown algorithm: %s", settings.Algorithm)
+		}
+	})
+}
+
+type compressedFS struct {
+	FS
+	compression Compression
+	// name identifies the wrapper in error messages, since AgeEncrypted() works the exact same way.
+	name string
+}
+
+// Read opens the file and decompresses its contents as you read them.
+func (c *compressedFS) Read(filePath string) (ReaderFile, error) {
+	reader := &compressedReaderFile{open: func() (io.ReadCloser, error) {
+		file, err := c.FS.Read(filePath)
+		if err != nil {
+			return nil, err
+		}
+		stream, err := c.compression.NewReader(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		return &compressedStream{ReadCloser: stream, file: file}, nil
+	}, name: c.name, size: -1}
+	if err := reader.reset(); err != nil {
+		return nil, fmt.Errorf("filestore: %s: read %s: %w", c.name, filePath, err)
+	}
+	return reader, nil
+}
+
+// List performs the equivalent of the "ls" command. Like Stat(), it reports the compressed size of each file,
+// but your filters see the decompressed sizes, which we only calculate (by decompressing the file) if they ask.
+func (c *compressedFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	infos, err := listDescribed(c.FS, dirPath, filters, func(info FileInfo) FileInfo {
+		if info.IsDir() {
+			return info
+		}
+		return &compressedFilterInfo{FileInfo: info, fs: c, path: path.Join(dirPath, info.Name())}
+	})
+	for i, info := range infos {
+		if filterInfo, ok := info.(*compressedFilterInfo); ok {
+			infos[i] = filterInfo.FileInfo
+		}
+	}
+	return infos, err
+}
+
+// Write opens the file and compresses everything you write to it.
+func (c *compressedFS) Write(filePath string) (WriterFile, error) {
+	file, err := c.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := c.compression.NewWriter(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("filestore: %s: write %s: %w", c.name, filePath, err)
+	}
+	return &compressedWriterFile{file: file, stream: stream, name: c.name}, nil
+}
+
+func (c *compressedFS) ChangeDirectory(dir string) FS {
+	return &compressedFS{FS: c.FS.ChangeDirectory(dir), compression: c.compression, name: c.name}
+}
+
+func (c *compressedFS) withContext(ctx context.Context) FS {
+	return &compressedFS{FS: ForRequest(c.FS, ctx), compression: c.compression, name: c.name}
+}
+
+func (c *compressedFS) requestContext() context.Context {
+	return RequestContext(c.FS)
+}
+
+func (c *compressedFS) wrapped() []FS {
+	return []FS{c.FS}
+}
+
+// compressedFilterInfo is what List() filters see: it reports the decompressed size of the file, which it
+// calculates the first time you ask. If we can't decompress the file, it falls back to the stored size.
+type compressedFilterInfo struct {
+	FileInfo
+	fs   *compressedFS
+	path string
+	once sync.Once
+	size int64
+}
+
+func (info *compressedFilterInfo) Size() int64 {
+	info.once.Do(func() {
+		info.size = info.FileInfo.Size()
+		file, err := info.fs.Read(info.path)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		if size, err := io.Copy(io.Discard, file); err == nil {
+			info.size = size
+		}
+	})
+	return info.size
+}
+
+// errCompressedSeek is returned when you try to write anywhere other than the end of a compressed (or
+// age encrypted) file.
+var errCompressedSeek = errors.New("file can only be written sequentially")
+
+// compressedWriterFile compresses everything written to it on its way to the underlying file.
+type compressedWriterFile struct {
+	mutex  sync.Mutex
+	file   WriterFile
+	stream io.WriteCloser
+	offset int64
+	closed bool
+	name   string
+}
+
+// Write compresses len(b) bytes from b and appends them to the file.
+func (w *compressedWriterFile) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	n, err := w.stream.Write(p)
+	w.offset += int64(n)
+	return n, err
+}
+
+// WriteAt writes len(b) bytes to the file, but only if off is the current end of the file.
+func (w *compressedWriterFile) WriteAt(p []byte, off int64) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if off != w.offset {
+		return 0, fmt.Errorf("filestore: %s: write at: %w", w.name, errCompressedSeek)
+	}
+	n, err := w.stream.Write(p)
+	w.offset += int64(n)
+	return n, err
+}
+
+// Seek reports the current offset; you can't actually move to any other offset.
+func (w *compressedWriterFile) Seek(offset int64, whence int) (int64, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	position := offset
+	switch whence {
+	case io.SeekCurrent, io.SeekEnd:
+		position = w.offset + offset
+	}
+	if position != w.offset {
+		return 0, fmt.Errorf("filestore: %s: seek: %w", w.name, errCompressedSeek)
+	}
+	return position, nil
+}
+
+// Close flushes the rest of the compressed data and closes the underlying file.
+func (w *compressedWriterFile) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	streamErr := w.stream.Close()
+	fileErr := w.file.Close()
+	if streamErr != nil {
+		return fmt.Errorf("filestore: %s: close: %w", w.name, streamErr)
+	}
+	return fileErr
+}
+
+// compressedStream decompresses the data in the underlying file, closing the file when you close it.
+type compressedStream struct {
+	io.ReadCloser
+	file ReaderFile
+}
+
+func (s *compressedStream) Close() error {
+	streamErr := s.ReadCloser.Close()
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return streamErr
+}
+
+// compressedReaderFile decompresses the underlying file as you read it. Since compressed data isn't
+// seekable, moving backwards means starting over from the beginning of the file.
+type compressedReaderFile struct {
+	mutex sync.Mutex
+	// open starts decompressing the file from the beginning.
+	open   func() (io.ReadCloser, error)
+	stream io.ReadCloser
+	offset int64
+	size   int64 // -1 until we've read to the end once (unless whoever opened the file already knew)
+	// beyond is how far past the end of the data Seek() moved us, since os.File lets you seek there, too.
+	beyond int64
+	name   string
+}
+
+// reset (re)opens the underlying file and starts decompressing it from the beginning.
+func (r *compressedReaderFile) reset() error {
+	r.closeStream()
+	stream, err := r.open()
+	if err != nil {
+		return err
+	}
+	r.stream, r.offset = stream, 0
+	return nil
+}
+
+func (r *compressedReaderFile) closeStream() {
+	if r.stream != nil {
+		_ = r.stream.Close()
+		r.stream = nil
+	}
+}
+
+// Read decompresses up to len(p) bytes from the current offset.
+func (r *compressedReaderFile) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.read(p)
+}
+
+func (r *compressedReaderFile) read(p []byte) (int, error) {
+	if r.stream == nil {
+		return 0, fmt.Errorf("filestore: %s: read: file already closed", r.name)
+	}
+	if r.beyond > 0 {
+		return 0, io.EOF
+	}
+	n, err := r.stream.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF {
+		r.size = r.offset
+	}
+	return n, err
+}
+
+// ReadAt decompresses len(p) bytes starting at byte offset off, leaving the current offset alone.
+func (r *compressedReaderFile) ReadAt(p []byte, off int64) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if off < 0 {
+		return 0, fmt.Errorf("filestore: %s: read at: negative offset", r.name)
+	}
+	offset, beyond := r.offset, r.beyond
+	r.beyond = 0
+	if err := r.seek(off); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(readerFunc(r.read), p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	if seekErr := r.seek(offset); seekErr != nil && err == nil {
+		err = seekErr
+	}
+	r.beyond = beyond
+	return n, err
+}
+
+// Seek moves to the given offset of the decompressed data.
+func (r *compressedReaderFile) Seek(offset int64, whence int) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var position int64
+	switch whence {
+	case io.SeekStart:
+		position = offset
+	case io.SeekCurrent:
+		position = r.offset + r.beyond + offset
+	case io.SeekEnd:
+		// We won't know how big the file is until we've decompressed all of it.
+		if r.size < 0 {
+			if err := r.seek(1<<63 - 1); err != nil {
+				return 0, err
+			}
+		}
+		position = r.size + offset
+	default:
+		return 0, fmt.Errorf("filestore: %s: seek: invalid whence %d", r.name, whence)
+	}
+	if position < 0 {
+		return 0, fmt.Errorf("filestore: %s: seek: negative position", r.name)
+	}
+	r.beyond = 0
+	if err := r.seek(position); err != nil {
+		return 0, err
+	}
+	r.beyond = position - r.offset
+	return position, nil
+}
+
+// seek moves to the offset by decompressing (and discarding) everything before it, starting over if we're
+// already past it. Seeking beyond the end leaves the stream at the end, like reading would.
+func (r *compressedReaderFile) seek(offset int64) error {
+	if r.stream == nil {
+		return fmt.Errorf("filestore: %s: seek: file already closed", r.name)
+	}
+	if offset < r.offset {
+		if err := r.reset(); err != nil {
+			return fmt.Errorf("filestore: %s: seek: %w", r.name, err)
+		}
+	}
+	if _, err := io.CopyN(io.Discard, readerFunc(r.read), offset-r.offset); err != nil && err != io.EOF {
+		return fmt.Errorf("filestore: %s: seek: %w", r.name, err)
+	}
+	return nil
+}
+
+// Close closes the underlying file.
+func (r *compressedReaderFile) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.closeStream()
+	return nil
+}
+
+// readerFunc lets a plain function act as an io.Reader.
+type readerFunc func(p []byte) (int, error)
+
+func (fn readerFunc) Read(p []byte) (int, error) {
+	return fn(p)
+}
+
+var _ requestBinder = &compressedFS{}
+var _ wrapper = &compressedFS{}
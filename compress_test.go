@@ -0,0 +1,316 @@
+package filestore_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompressedTestSuite struct {
+	suite.Suite
+	inner filestore.FS
+	fs    filestore.FS
+}
+
+func TestCompressedTestSuite(t *testing.T) {
+	suite.Run(t, &CompressedTestSuite{})
+}
+
+func (s *CompressedTestSuite) SetupTest() {
+	s.inner = filestore.Memory()
+	s.fs = filestore.Compressed(s.inner, filestore.Gzip)
+}
+
+func (s *CompressedTestSuite) TestWrite() {
+	content := strings.Repeat("the dude abides. ", 100)
+	s.Require().NoError(writeString(s.fs, "logs/app.log", content))
+
+	stored, err := readString(s.inner, "logs/app.log")
+	s.Require().NoError(err)
+	s.Require().Less(len(stored), len(content), "Should store the compressed data under the same path")
+
+	decompressed, err := gzip.NewReader(strings.NewReader(stored))
+	s.Require().NoError(err)
+	data, err := io.ReadAll(decompressed)
+	s.Require().NoError(err)
+	s.Require().Equal(content, string(data), "Should be plain old gzip")
+
+	info, err := s.fs.Stat("logs/app.log")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(len(stored)), info.Size(), "Sizes should be the compressed size")
+}
+
+func (s *CompressedTestSuite) TestWrite_sequential() {
+	file, err := s.fs.Write("app.log")
+	s.Require().NoError(err)
+	_, err = file.Write([]byte("abc"))
+	s.Require().NoError(err)
+	_, err = file.WriteAt([]byte("def"), 3)
+	s.Require().NoError(err, "Writing at the current offset should be fine")
+
+	position, err := file.Seek(0, io.SeekCurrent)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(6), position)
+	_, err = file.Seek(0, io.SeekStart)
+	s.Require().Error(err, "Should not be able to go back")
+	_, err = file.WriteAt([]byte("nope"), 1)
+	s.Require().Error(err)
+	s.Require().NoError(file.Close())
+
+	s.Require().Equal("abcdef", s.read("app.log"))
+}
+
+func (s *CompressedTestSuite) TestRead() {
+	s.Require().NoError(writeString(s.fs, "app.log", "the dude abides"))
+	s.Require().Equal("the dude abides", s.read("app.log"))
+	s.Require().Equal("the dude abides", s.read("../app.log"))
+
+	_, err := s.fs.Read("missing.log")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+
+	s.Require().NoError(writeString(s.inner, "plain.log", "not compressed"))
+	_, err = s.fs.Read("plain.log")
+	s.Require().Error(err, "Should not pass through files that aren't compressed")
+}
+
+func (s *CompressedTestSuite) TestRead_seek() {
+	s.Require().NoError(writeString(s.fs, "app.log", "the dude abides"))
+	file, err := s.fs.Read("app.log")
+	s.Require().NoError(err)
+	defer file.Close()
+
+	buffer := make([]byte, 4)
+	_, err = file.Seek(4, io.SeekStart)
+	s.Require().NoError(err)
+	_, err = io.ReadFull(file, buffer)
+	s.Require().NoError(err)
+	s.Require().Equal("dude", string(buffer))
+
+	_, err = file.Seek(0, io.SeekStart)
+	s.Require().NoError(err, "Should be able to go backwards")
+	_, err = io.ReadFull(file, buffer[:3])
+	s.Require().NoError(err)
+	s.Require().Equal("the", string(buffer[:3]))
+
+	n, err := file.ReadAt(buffer, 9)
+	s.Require().NoError(err)
+	s.Require().Equal("abid", string(buffer[:n]))
+	rest, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal(" dude abides", string(rest), "ReadAt should not move the offset")
+
+	position, err := file.Seek(-6, io.SeekEnd)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(9), position)
+	rest, err = io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal("abides", string(rest))
+
+	n, err = file.ReadAt(buffer, 13)
+	s.Require().ErrorIs(err, io.EOF)
+	s.Require().Equal("es", string(buffer[:n]))
+
+	position, err = file.Seek(100, io.SeekStart)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(100), position)
+	position, err = file.Seek(0, io.SeekCurrent)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(100), position, "Should keep the position past the end, like os.File")
+	n, err = file.Read(buffer)
+	s.Require().ErrorIs(err, io.EOF)
+	s.Require().Equal(0, n)
+	n, err = file.ReadAt(buffer, 4)
+	s.Require().NoError(err)
+	s.Require().Equal("dude", string(buffer[:n]))
+	position, err = file.Seek(-90, io.SeekCurrent)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(10), position)
+	rest, err = io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal("bides", string(rest))
+}
+
+func (s *CompressedTestSuite) TestList_filters() {
+	s.Require().NoError(writeString(s.fs, "logs/small.log", "abide"))
+	s.Require().NoError(writeString(s.fs, "logs/big.log", strings.Repeat("abide", 100)))
+
+	bigFiles := func(info filestore.FileInfo) bool { return info.Size() > 100 }
+	infos, err := s.fs.List("logs", bigFiles)
+	s.Require().NoError(err)
+	s.Require().Len(infos, 1, "Filters should see the decompressed sizes")
+	s.Require().Equal("big.log", infos[0].Name())
+
+	stored, err := s.inner.Stat("logs/big.log")
+	s.Require().NoError(err)
+	s.Require().Equal(stored.Size(), infos[0].Size(), "Results should still report the compressed size")
+}
+
+func (s *CompressedTestSuite) TestChangeDirectory() {
+	logs := s.fs.ChangeDirectory("logs")
+	s.Require().NoError(writeString(logs, "app.log", "the dude abides"))
+	s.Require().Equal("the dude abides", s.read("logs/app.log"))
+}
+
+func (s *CompressedTestSuite) TestFromConfig() {
+	fs, err := filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "compressed", Options: filestore.LayerOptions{"algorithm": "gzip", "level": 9}}},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(fs, "app.log", "the dude abides"))
+	content, err := readString(fs, "app.log")
+	s.Require().NoError(err)
+	s.Require().Equal("the dude abides", content)
+
+	fs, err = filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "compressed", Options: filestore.LayerOptions{"level": 42}}},
+	})
+	s.Require().NoError(err)
+	s.Require().Error(writeString(fs, "app.log", "the dude abides"), "Invalid levels should fail on write")
+
+	_, err = filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "compressed", Options: filestore.LayerOptions{"algorithm": "rar"}}},
+	})
+	s.Require().Error(err)
+}
+
+func (s *CompressedTestSuite) TestZstd() {
+	content := strings.Repeat(`{"name":"the dude","drink":"white russian"}`, 100)
+	fs := filestore.Compressed(s.inner, filestore.ZstdLevel(19))
+	s.Require().NoError(writeString(fs, "export.json", content))
+	s.Require().Equal(content, s.readFrom(fs, "export.json"))
+
+	stored, err := readString(s.inner, "export.json")
+	s.Require().NoError(err)
+	s.Require().Less(len(stored), len(content))
+	decoder, err := zstd.NewReader(strings.NewReader(stored))
+	s.Require().NoError(err)
+	defer decoder.Close()
+	data, err := io.ReadAll(decoder)
+	s.Require().NoError(err)
+	s.Require().Equal(content, string(data), "Should be plain old zstd")
+
+	fs = filestore.Compressed(s.inner, filestore.ZstdLevel(0))
+	s.Require().Error(writeString(fs, "export.json", content), "Invalid levels should fail on write")
+}
+
+func (s *CompressedTestSuite) TestZstd_dictionary() {
+	dictionary := s.dictionary()
+	fs := filestore.Compressed(s.inner, filestore.ZstdDictionary(3, dictionary))
+	s.Require().NoError(writeString(fs, "dude.json", `{"name":"the dude","drink":"white russian"}`))
+	s.Require().Equal(`{"name":"the dude","drink":"white russian"}`, s.readFrom(fs, "dude.json"))
+
+	_, err := readString(filestore.Compressed(s.inner, filestore.Zstd), "dude.json")
+	s.Require().Error(err, "Should need the dictionary to read the file")
+
+	dictionaryFile := filepath.Join(s.T().TempDir(), "json.dict")
+	s.Require().NoError(os.WriteFile(dictionaryFile, dictionary, 0o644))
+	fs, err = filestore.FromConfig(filestore.Config{
+		URL: "mem://",
+		Layers: []filestore.LayerConfig{{Type: "compressed", Options: filestore.LayerOptions{
+			"algorithm":      "zstd",
+			"level":          19,
+			"dictionaryFile": dictionaryFile,
+		}}},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(fs, "walter.json", `{"name":"walter","drink":"beer"}`))
+	s.Require().Equal(`{"name":"walter","drink":"beer"}`, s.readFrom(fs, "walter.json"))
+
+	_, err = filestore.FromConfig(filestore.Config{
+		URL: "mem://",
+		Layers: []filestore.LayerConfig{{Type: "compressed", Options: filestore.LayerOptions{
+			"algorithm":      "zstd",
+			"dictionaryFile": filepath.Join(s.T().TempDir(), "missing.dict"),
+		}}},
+	})
+	s.Require().Error(err)
+}
+
+func (s *CompressedTestSuite) TestCustomCompression() {
+	fs := filestore.Compressed(s.inner, reverseCompression{})
+	s.Require().NoError(writeString(fs, "app.log", "abide"))
+	stored, _ := readString(s.inner, "app.log")
+	s.Require().Equal("ediba", stored)
+	s.Require().Equal("abide", s.readFrom(fs, "app.log"))
+}
+
+func (s *CompressedTestSuite) read(name string) string {
+	return s.readFrom(s.fs, name)
+}
+
+func (s *CompressedTestSuite) readFrom(fs filestore.FS, name string) string {
+	content, err := readString(fs, name)
+	s.Require().NoError(err)
+	return content
+}
+
+// dictionary builds a small zstd dictionary from some sample JSON documents.
+func (s *CompressedTestSuite) dictionary() []byte {
+	names := []string{"the dude", "walter", "donny", "maude", "jackie treehorn", "bunny"}
+	drinks := []string{"white russian", "beer", "oat soda"}
+	var samples [][]byte
+	for i := 0; i < 1000; i++ {
+		samples = append(samples, []byte(fmt.Sprintf(`{"id":%d,"name":"%s","drink":"%s"}`,
+			i, names[i%len(names)], drinks[i%len(drinks)])))
+	}
+	dictionary, err := zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       42,
+		Contents: samples,
+		History:  bytes.Join(samples[:20], nil),
+		Level:    zstd.SpeedFastest,
+		Offsets:  [3]int{1, 4, 8},
+	})
+	s.Require().NoError(err)
+	return dictionary
+}
+
+// reverseCompression is a (terrible) compression algorithm that just reverses the bytes of each file.
+type reverseCompression struct{}
+
+func (reverseCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return &reverseWriter{w: w}, nil
+}
+
+func (reverseCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(reverse(data))), nil
+}
+
+type reverseWriter struct {
+	w      io.Writer
+	buffer bytes.Buffer
+}
+
+func (rw *reverseWriter) Write(p []byte) (int, error) {
+	return rw.buffer.Write(p)
+}
+
+func (rw *reverseWriter) Close() error {
+	_, err := rw.w.Write(reverse(rw.buffer.Bytes()))
+	return err
+}
+
+func reverse(data []byte) []byte {
+	reversed := make([]byte, len(data))
+	for i, b := range data {
+		reversed[len(data)-1-i] = b
+	}
+	return reversed
+}
@@ -0,0 +1,152 @@
+package filestore
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Config declaratively describes an entire storage stack: the backend that actually stores the
+// files plus any number of layers (caching, encryption, logging, etc.) wrapped around it. This lets
+// deployments change how/where they store files w/o changing any code. The struct has both JSON
+// and YAML tags, so you can decode it from either format (e.g. using gopkg.in/yaml.v3).
+//
+// Example JSON:
+//
+//	{
+//	  "url": "file:///var/data",
+//	  "layers": [
+//	    {"type": "subdir", "options": {"path": "tenants/acme"}}
+//	  ]
+//	}
+type Config struct {
+	// URL is the connection string for the backend store. See Open() for details.
+	URL string `json:"url" yaml:"url"`
+	// Layers are the wrappers to apply on top of the backend. The first layer wraps the backend
+	// directly, the second one wraps the first, and so on.
+	Layers []LayerConfig `json:"layers,omitempty" yaml:"layers,omitempty"`
+}
+
+// LayerConfig describes a single wrapper in a Config's storage stack.
+type LayerConfig struct {
+	// Type is the name the layer was registered under (see RegisterLayer).
+	Type string `json:"type" yaml:"type"`
+	// Options are the layer-specific settings for the wrapper.
+	Options LayerOptions `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+// LayerOptions are the free-form, layer-specific settings in a LayerConfig.
+type LayerOptions map[string]any
+
+// Decode unpacks the free-form options into a strongly typed struct. Fields are matched up
+// using the struct's JSON tags.
+//
+// Example:
+//
+//	settings := struct {
+//	    MaxSize int64 `json:"maxSize"`
+//	}{}
+//	err := options.Decode(&settings)
+func (options LayerOptions) Decode(target any) error {
+	data, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("decode layer options: %w", err)
+	}
+	if err = json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("decode layer options: %w", err)
+	}
+	return nil
+}
+
+// Layer wraps a file store with additional behavior based on the options from a LayerConfig.
+type Layer func(fs FS, options LayerOptions) (FS, error)
+
+var layers = struct {
+	mutex    sync.RWMutex
+	registry map[string]Layer
+}{registry: map[string]Layer{}}
+
+// RegisterLayer makes a wrapper available to FromConfig() under the given type name. This is
+// typically called from the init() function of the package that implements the wrapper. Much
+// like RegisterScheme(), this panics if you register the same name twice or supply a nil layer.
+func RegisterLayer(name string, layer Layer) {
+	layers.mutex.Lock()
+	defer layers.mutex.Unlock()
+
+	name = strings.ToLower(name)
+	if layer == nil {
+		panic("filestore: register layer: layer is nil for " + name)
+	}
+	if _, exists := layers.registry[name]; exists {
+		panic("filestore: register layer: called twice for " + name)
+	}
+	layers.registry[name] = layer
+}
+
+// Layers returns the sorted list of layer types that FromConfig() currently supports.
+func Layers() []string {
+	layers.mutex.RLock()
+	defer layers.mutex.RUnlock()
+
+	var results []string
+	for name := range layers.registry {
+		results = append(results, name)
+	}
+	sort.Strings(results)
+	return results
+}
+
+// ParseConfig decodes a JSON representation of a Config.
+func ParseConfig(data []byte) (Config, error) {
+	cfg := Config{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("filestore: parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// FromConfig assembles the backend described by the config's URL and then wraps it in each of
+// the config's layers, in order.
+//
+// Example:
+//
+//	cfg, err := filestore.ParseConfig(configFileBytes)
+//	if err != nil {
+//	    // handle error
+//	}
+//	files, err := filestore.FromConfig(cfg)
+func FromConfig(cfg Config) (FS, error) {
+	fs, err := Open(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: from config: %w", err)
+	}
+
+	for i, layerConfig := range cfg.Layers {
+		layers.mutex.RLock()
+		layer, ok := layers.registry[strings.ToLower(layerConfig.Type)]
+		layers.mutex.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("filestore: from config: layer %d: unsupported type: %s", i, layerConfig.Type)
+		}
+		if fs, err = layer(fs, layerConfig.Options); err != nil {
+			return nil, fmt.Errorf("filestore: from config: layer %d (%s): %w", i, layerConfig.Type, err)
+		}
+	}
+	return fs, nil
+}
+
+func init() {
+	// The "subdir" layer scopes the entire stack to a subdirectory of whatever it wraps.
+	RegisterLayer("subdir", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Path string `json:"path"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		return fs.ChangeDirectory(settings.Path), nil
+	})
+}
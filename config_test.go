@@ -0,0 +1,97 @@
+package filestore_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, &ConfigTestSuite{})
+}
+
+func (s *ConfigTestSuite) TestFromConfig_backendOnly() {
+	fs, err := filestore.FromConfig(filestore.Config{URL: "file:testdata"})
+	s.Require().NoError(err)
+	s.Require().Equal("testdata", fs.WorkingDirectory())
+	s.Require().True(fs.Exists("hello.txt"))
+}
+
+func (s *ConfigTestSuite) TestFromConfig_layers() {
+	cfg, err := filestore.ParseConfig([]byte(`{
+		"url": "file:testdata",
+		"layers": [
+			{"type": "subdir", "options": {"path": "inner1"}},
+			{"type": "SUBDIR", "options": {"path": "inner2"}}
+		]
+	}`))
+	s.Require().NoError(err)
+
+	fs, err := filestore.FromConfig(cfg)
+	s.Require().NoError(err)
+	s.Require().Equal("testdata/inner1/inner2", fs.WorkingDirectory())
+	s.Require().True(fs.Exists("bar.txt"))
+}
+
+var configTestRegister sync.Once
+
+func (s *ConfigTestSuite) TestRegisterLayer() {
+	configTestRegister.Do(func() {
+		filestore.RegisterLayer("configtest", func(fs filestore.FS, options filestore.LayerOptions) (filestore.FS, error) {
+			settings := struct {
+				Fail  bool `json:"fail"`
+				Count int  `json:"count"`
+			}{}
+			if err := options.Decode(&settings); err != nil {
+				return nil, err
+			}
+			if settings.Fail {
+				return nil, fmt.Errorf("nope")
+			}
+			return fs.ChangeDirectory(fmt.Sprintf("dir%d", settings.Count)), nil
+		})
+	})
+	s.Require().Contains(filestore.Layers(), "configtest")
+	s.Require().Contains(filestore.Layers(), "subdir")
+
+	fs, err := filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "configtest", Options: filestore.LayerOptions{"count": 42}}},
+	})
+	s.Require().NoError(err)
+	s.Require().Equal("/dir42", fs.WorkingDirectory())
+
+	_, err = filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "configtest", Options: filestore.LayerOptions{"fail": true}}},
+	})
+	s.Require().Error(err, "Layer errors should propagate")
+
+	_, err = filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "configtest", Options: filestore.LayerOptions{"count": "not a number"}}},
+	})
+	s.Require().Error(err, "Layer option decoding errors should propagate")
+
+	s.Require().Panics(func() {
+		filestore.RegisterLayer("configtest", func(fs filestore.FS, options filestore.LayerOptions) (filestore.FS, error) { return fs, nil })
+	})
+}
+
+func (s *ConfigTestSuite) TestFromConfig_invalid() {
+	_, err := filestore.FromConfig(filestore.Config{URL: "nope://"})
+	s.Require().Error(err, "Invalid backend should fail")
+
+	_, err = filestore.FromConfig(filestore.Config{URL: "mem://", Layers: []filestore.LayerConfig{{Type: "nope"}}})
+	s.Require().Error(err, "Unknown layer should fail")
+
+	_, err = filestore.ParseConfig([]byte(`{"url": `))
+	s.Require().Error(err, "Malformed JSON should fail")
+}
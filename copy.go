@@ -0,0 +1,283 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// Linker is an optional capability for stores that support hard links: multiple paths that refer
+// to the same underlying file. Link fails if newPath already exists.
+type Linker interface {
+	Link(existingPath string, newPath string) error
+}
+
+// CopyAll copies the file/directory at srcPath in the source store to dstPath in the destination
+// store, including all of a directory's subdirectories and files. The stores can be the same or
+// completely different (e.g. copying from Disk() to S3()). Existing files in the destination are
+// overwritten. Like Walk, this does not descend into linked directories unless you supply
+// WithFollowSymlinks(); links to files are copied as regular files containing the target's data.
+//
+// When you supply WithHardlinkDetection(), files that are hard links to each other in the source
+// are only copied once. If the destination supports the Linker capability, the other paths become
+// hard links to that copy; otherwise, we fall back to copying the data again. When the destination
+// limits how big files can be (see MaxFileSize), files that are too big fail before we copy any data.
+// When either store treats directories as bundles (see Bundles), each bundle is copied whole or not at all.
+//
+// If a source file's size or modification time changes while we're copying it, the destination would
+// end up w/ a mix of old and new data, so we remove the destination file and fail w/ ErrFileChanged. Use
+// WithChangedFiles() to retry or skip those files instead. Any other failure stops the copy unless you
+// supply WithContinueOnError().
+//
+// Example:
+//
+//	// Back up the local uploads directory to S3.
+//	err := filestore.CopyAll(filestore.Disk("."), "uploads", bucket, "backups/uploads")
+func CopyAll(src FS, srcPath string, dst FS, dstPath string, opts ...Option) error {
+	return copyAll(src, srcPath, dst, dstPath, opts, false)
+}
+
+// ErrFileChanged is the error returned by CopyAll() when a source file changes while we're copying it.
+var ErrFileChanged = errors.New("filestore: file changed during copy")
+
+// ChangedFilePolicy determines what CopyAll() does when a source file changes while we're copying it.
+type ChangedFilePolicy string
+
+const (
+	// ChangedFileFail removes the partially copied file and stops the copy w/ ErrFileChanged. This is the default.
+	ChangedFileFail ChangedFilePolicy = "fail"
+	// ChangedFileRetry copies the file again, up to a few times, before failing like ChangedFileFail.
+	ChangedFileRetry ChangedFilePolicy = "retry"
+	// ChangedFileSkip removes the partially copied file, reports it to the WithCopyWarning() callback, and
+	// moves on to the next file.
+	ChangedFileSkip ChangedFilePolicy = "skip"
+)
+
+// copyOptions contains the settings that only apply to CopyAll().
+type copyOptions struct {
+	changed  ChangedFilePolicy
+	attempts int
+	warn     func(path string, err error)
+}
+
+// WithChangedFiles determines what CopyAll() does when a source file's size or modification time changes
+// while we're copying it, which would otherwise leave the destination w/ a mix of old and new data. By
+// default, the copy fails w/ ErrFileChanged (ChangedFileFail).
+//
+// Example:
+//
+//	// Log files are appended to constantly; just try again.
+//	err := filestore.CopyAll(files, "logs", backups, "logs", filestore.WithChangedFiles(filestore.ChangedFileRetry))
+func WithChangedFiles(policy ChangedFilePolicy) Option {
+	return func(opts *options) {
+		switch policy {
+		case ChangedFileFail, ChangedFileRetry, ChangedFileSkip:
+			opts.copy.changed = policy
+		}
+	}
+}
+
+// WithCopyWarning lets you know when CopyAll() skips a file because it changed while we were copying it
+// (see WithChangedFiles). The callback is given the source path and the reason.
+//
+// Example:
+//
+//	warn := func(filePath string, err error) {
+//	    log.Printf("skipped %s: %v", filePath, err)
+//	}
+//	err := filestore.CopyAll(files, ".", backups, ".", filestore.WithChangedFiles(filestore.ChangedFileSkip), filestore.WithCopyWarning(warn))
+func WithCopyWarning(fn func(path string, err error)) Option {
+	return func(opts *options) {
+		if fn != nil {
+			opts.copy.warn = fn
+		}
+	}
+}
+
+// copyAll does the work for CopyAll. When staged is true, we're copying a bundle into its staging
+// directory, so the root itself must not be staged all over again.
+func copyAll(src FS, srcPath string, dst FS, dstPath string, opts []Option, staged bool) error {
+	options := newOptions(opts)
+	srcBundles, _ := src.(bundler)
+	dstBundles, _ := dst.(bundler)
+	root := path.Clean(srcPath)
+
+	// Bundles are copied whole or not at all, so there's no continuing after a failure inside of one.
+	var batch *batchRun
+	if !staged {
+		batch = newBatchRun(options.batch)
+	}
+
+	err := planCopy(src, srcPath, dst, dstPath, opts, batch, func(step copyStep) error {
+		if step.dir {
+			isBundle := (srcBundles != nil && srcBundles.isBundle(step.srcPath)) ||
+				(dstBundles != nil && dstBundles.isBundle(step.dstPath))
+			if !isBundle || (staged && step.srcPath == root) {
+				return nil
+			}
+			if err := copyBundle(src, step.srcPath, dst, step.dstPath, opts); err != nil {
+				if err = batch.fail(step.srcPath, err); err != nil {
+					return err
+				}
+				return fs.SkipDir
+			}
+			batch.succeed(step.srcPath)
+			return fs.SkipDir
+		}
+		if err := copyFileStep(src, step, dst, options.copy); err != nil {
+			return batch.fail(step.srcPath, err)
+		}
+		batch.succeed(step.srcPath)
+		return nil
+	})
+	if errors.Is(err, fs.SkipDir) {
+		err = nil
+	}
+	return batch.finish(err)
+}
+
+// copyFileStep copies a single file, or hard links it to a file we've already copied.
+func copyFileStep(src FS, step copyStep, dst FS, options copyOptions) error {
+	if step.linkTo != "" {
+		if err := dst.Remove(step.dstPath); err != nil {
+			return err
+		}
+		return dst.(Linker).Link(step.linkTo, step.dstPath)
+	}
+	if limiter, ok := dst.(sizeLimiter); ok && step.size > limiter.maxFileSize() {
+		return &FileTooLargeError{Path: step.dstPath, Limit: limiter.maxFileSize()}
+	}
+	return copyStableFile(src, step, dst, options)
+}
+
+// copyStep is a single action that CopyAll performs: either copy one file's data or, when linkTo
+// is set, hard link the destination path to a file we've already copied. Steps for directories
+// don't do anything on their own; they just tell you which directories we listed.
+type copyStep struct {
+	srcPath string
+	dstPath string
+	size    int64
+	modTime time.Time
+	dir     bool
+	linkTo  string
+}
+
+// planCopy walks the source tree, calling fn with each step that CopyAll needs to perform. This
+// lets us estimate the cost of a copy using the exact same decisions as the copy itself. When the
+// batch isn't nil, failing to stat/list part of the source tree doesn't stop the walk.
+func planCopy(src FS, srcPath string, dst FS, dstPath string, opts []Option, batch *batchRun, fn func(step copyStep) error) error {
+	_, canLink := dst.(Linker)
+
+	// Hard links only make sense within the same store, so we track the destination path of the
+	// first copy of each linked file rather than its source path.
+	copies := map[string]string{}
+	root := path.Clean(srcPath)
+	w := newWalker(src, opts)
+	return w.walkRoot(root, func(filePath string, info FileInfo, firstLink string, err error) error {
+		if err != nil {
+			if err = batch.fail(filePath, err); err != nil {
+				return fmt.Errorf("filestore: copy: %w", err)
+			}
+			return nil
+		}
+		relativePath := filePath
+		if root != "." {
+			relativePath = strings.TrimPrefix(filePath, root)
+		}
+		step := copyStep{srcPath: filePath, dstPath: path.Join(dstPath, relativePath), size: info.Size(), modTime: info.ModTime()}
+		if info.IsDir() {
+			step.dir = true
+			return fn(step)
+		}
+
+		// Links that we didn't follow are copied as the file they point to. We skip broken links and
+		// links to directories since we'd have to follow them to copy anything.
+		if info.Mode()&fs.ModeSymlink != 0 {
+			target, err := src.Stat(filePath)
+			if err != nil || target.IsDir() {
+				return nil
+			}
+			step.size = target.Size()
+			step.modTime = target.ModTime()
+		}
+
+		if w.options.hardlinks && firstLink == "" {
+			copies[filePath] = step.dstPath
+		}
+		if first, ok := copies[firstLink]; ok && canLink {
+			step.linkTo = first
+		}
+		if err := fn(step); err != nil {
+			return fmt.Errorf("filestore: copy: %w", err)
+		}
+		return nil
+	})
+}
+
+// copyStableFile copies a single file, handling the source changing while we copy it according to
+// the ChangedFilePolicy.
+func copyStableFile(src FS, step copyStep, dst FS, options copyOptions) error {
+	for attempt := 1; ; attempt++ {
+		err := copyFile(src, step.srcPath, dst, step.dstPath, step.size, step.modTime)
+		if !errors.Is(err, ErrFileChanged) {
+			return err
+		}
+
+		// Whatever we wrote is a mix of the old and new data, so it's no good to anyone.
+		if removeErr := dst.Remove(step.dstPath); removeErr != nil {
+			return removeErr
+		}
+		switch {
+		case options.changed == ChangedFileRetry && attempt < options.attempts:
+			// The walk's info is out of date now, so compare against the file as it is now.
+			info, statErr := src.Stat(step.srcPath)
+			if statErr != nil {
+				return statErr
+			}
+			step.size, step.modTime = info.Size(), info.ModTime()
+		case options.changed == ChangedFileSkip:
+			options.warn(step.srcPath, err)
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// copyFile streams the contents of a single file from one store to another. It fails w/ ErrFileChanged
+// if the source file no longer has the given size/modification time once we're done.
+func copyFile(src FS, srcPath string, dst FS, dstPath string, size int64, modTime time.Time) error {
+	input, err := src.Read(srcPath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := dst.Write(dstPath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(output, input); err != nil {
+		_ = output.Close()
+		return err
+	}
+	if err = output.Close(); err != nil {
+		return err
+	}
+
+	// Make sure that the file we just copied is still the same one we found while walking.
+	info, err := src.Stat(srcPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: ErrFileChanged}
+	case err != nil:
+		return err
+	case info.Size() != size || !info.ModTime().Equal(modTime):
+		return &fs.PathError{Op: "copy", Path: srcPath, Err: ErrFileChanged}
+	}
+	return nil
+}
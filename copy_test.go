@@ -0,0 +1,133 @@
+package filestore_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type CopyTestSuite struct {
+	suite.Suite
+	src filestore.FS
+}
+
+func TestCopyTestSuite(t *testing.T) {
+	suite.Run(t, &CopyTestSuite{})
+}
+
+func (s *CopyTestSuite) SetupTest() {
+	s.src = filestore.Memory()
+	s.Require().NoError(writeString(s.src, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.src, "duderino/5.lebowski", "jackie"))
+	s.Require().NoError(writeString(s.src, "duderino/inner/6.lebowski", "nihilist"))
+}
+
+func (s *CopyTestSuite) read(fsys filestore.FS, name string) string {
+	content, err := readString(fsys, name)
+	s.Require().NoError(err)
+	return content
+}
+
+func (s *CopyTestSuite) TestCopyAll() {
+	dst := filestore.Memory()
+	s.Require().NoError(writeString(dst, "backup/1.lebowski", "old"))
+
+	s.Require().NoError(filestore.CopyAll(s.src, ".", dst, "backup"))
+	s.Require().Equal("jeff", s.read(dst, "backup/1.lebowski"), "Existing files should be overwritten")
+	s.Require().Equal("jackie", s.read(dst, "backup/duderino/5.lebowski"))
+	s.Require().Equal("nihilist", s.read(dst, "backup/duderino/inner/6.lebowski"))
+
+	s.Require().NoError(filestore.CopyAll(s.src, "./duderino/", dst, "other"))
+	s.Require().Equal("jackie", s.read(dst, "other/5.lebowski"))
+	s.Require().Equal("nihilist", s.read(dst, "other/inner/6.lebowski"))
+
+	s.Require().NoError(filestore.CopyAll(s.src, "1.lebowski", dst, "single.lebowski"))
+	s.Require().Equal("jeff", s.read(dst, "single.lebowski"), "Should be able to copy a single file")
+
+	s.Require().Error(filestore.CopyAll(s.src, "nope", dst, "nope"))
+}
+
+func (s *CopyTestSuite) TestCopyAll_hardlinks() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("Hardlink detection requires inodes")
+	}
+
+	srcDir := s.T().TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("the dude"), 0644))
+	s.Require().NoError(os.Link(filepath.Join(srcDir, "a.txt"), filepath.Join(srcDir, "b.txt")))
+	src := filestore.Disk(srcDir)
+
+	dstDir := s.T().TempDir()
+	s.Require().NoError(filestore.CopyAll(src, ".", filestore.Disk(dstDir), "copy", filestore.WithHardlinkDetection()))
+	a, err := os.Stat(filepath.Join(dstDir, "copy", "a.txt"))
+	s.Require().NoError(err)
+	b, err := os.Stat(filepath.Join(dstDir, "copy", "b.txt"))
+	s.Require().NoError(err)
+	s.Require().True(os.SameFile(a, b), "Links should be preserved in destinations that support them")
+
+	s.Require().NoError(filestore.CopyAll(src, ".", filestore.Disk(dstDir), "plain"))
+	a, _ = os.Stat(filepath.Join(dstDir, "plain", "a.txt"))
+	b, _ = os.Stat(filepath.Join(dstDir, "plain", "b.txt"))
+	s.Require().False(os.SameFile(a, b), "Links should only be preserved when detection is enabled")
+
+	dst := filestore.Memory()
+	s.Require().NoError(filestore.CopyAll(src, ".", dst, ".", filestore.WithHardlinkDetection()))
+	s.Require().Equal("the dude", s.read(dst, "a.txt"))
+	s.Require().Equal("the dude", s.read(dst, "b.txt"), "Should copy the data again if the destination can't link")
+}
+
+func (s *CopyTestSuite) TestCopyAll_changedFiles() {
+	src := &changingFS{FS: s.src, changes: map[string]int{"duderino/5.lebowski": 1}}
+	dst := filestore.Memory()
+	err := filestore.CopyAll(src, ".", dst, "backup")
+	s.Require().True(errors.Is(err, filestore.ErrFileChanged))
+	s.Require().False(dst.Exists("backup/duderino/5.lebowski"), "Should not leave a mixed-version file behind")
+
+	src.changes["duderino/5.lebowski"] = 1
+	err = filestore.CopyAll(src, ".", dst, "backup", filestore.WithChangedFiles(filestore.ChangedFileRetry))
+	s.Require().NoError(err)
+	s.Require().Equal("jackie!!!!", s.read(dst, "backup/duderino/5.lebowski"), "Should copy the latest version")
+
+	src.changes["duderino/5.lebowski"] = 5
+	err = filestore.CopyAll(src, ".", dst, "retried", filestore.WithChangedFiles(filestore.ChangedFileRetry))
+	s.Require().True(errors.Is(err, filestore.ErrFileChanged), "Should give up eventually")
+
+	var skipped []string
+	warn := func(filePath string, err error) {
+		s.Require().True(errors.Is(err, filestore.ErrFileChanged))
+		skipped = append(skipped, filePath)
+	}
+	src.changes["duderino/5.lebowski"] = 1
+	err = filestore.CopyAll(src, ".", dst, "skipped", filestore.WithChangedFiles(filestore.ChangedFileSkip), filestore.WithCopyWarning(warn))
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"duderino/5.lebowski"}, skipped)
+	s.Require().False(dst.Exists("skipped/duderino/5.lebowski"))
+	s.Require().Equal("nihilist", s.read(dst, "skipped/duderino/inner/6.lebowski"), "Should still copy everything else")
+}
+
+// changingFS appends to files right after they're opened for reading, like an application that's
+// actively writing to them while we copy.
+type changingFS struct {
+	filestore.FS
+	// changes is the number of times to change each file before leaving it alone.
+	changes map[string]int
+}
+
+func (f *changingFS) Read(filePath string) (filestore.ReaderFile, error) {
+	file, err := f.FS.Read(filePath)
+	if err != nil || f.changes[filePath] <= 0 {
+		return file, err
+	}
+	f.changes[filePath]--
+
+	content, err := readString(f.FS, filePath)
+	if err != nil {
+		return nil, err
+	}
+	return file, writeString(f.FS, filePath, content+"!!")
+}
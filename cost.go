@@ -0,0 +1,128 @@
+package filestore
+
+import (
+	"path"
+)
+
+// CopyEstimate describes the work that CopyAll would perform, so you can predict the cost of a large
+// migration (e.g. the S3 request and egress charges) before you actually run it.
+type CopyEstimate struct {
+	// Files is the number of files whose data would be copied.
+	Files int
+	// Links is the number of files that would become hard links to another copy rather than being
+	// copied again (see WithHardlinkDetection()).
+	Links int
+	// Bytes is the total size of the files whose data would be copied.
+	Bytes int64
+	// Source describes the work performed against the store we're copying from.
+	Source StoreCost
+	// Destination describes the work performed against the store we're copying to.
+	Destination StoreCost
+}
+
+// StoreCost describes the work that an operation performs against a single store.
+type StoreCost struct {
+	// Requests counts the API requests we'd send to the store, keyed by the name of the operation
+	// (e.g. "PutObject" or "GetObject" for S3). Stores that don't have a request-based API, such as
+	// Disk() and Memory(), don't report any requests.
+	Requests map[string]int
+	// BytesRead is how much data we'd download from the store (i.e. egress).
+	BytesRead int64
+	// BytesWritten is how much data we'd upload to the store.
+	BytesWritten int64
+}
+
+// costEstimator is implemented by stores whose operations are billed per request, so they can predict
+// which requests each operation would send.
+type costEstimator interface {
+	// estimateList records the requests needed to list a directory w/ the given number of entries.
+	estimateList(entries int, cost *StoreCost)
+	// estimateRead records the requests needed to download a file w/ the given size.
+	estimateRead(size int64, cost *StoreCost)
+	// estimateWrite records the requests needed to upload a file w/ the given size.
+	estimateWrite(size int64, cost *StoreCost)
+	// estimateStat records the requests needed to fetch a file's metadata.
+	estimateStat(cost *StoreCost)
+}
+
+// EstimateCopyAll predicts the work that CopyAll would perform w/ the same arguments w/o copying any
+// data. It still needs to walk the source tree to figure out what to copy, so the estimate itself costs
+// whatever it takes to list those directories (Source.Requests includes those listings).
+//
+// The request counts assume that each file is read once from start to finish, just like CopyAll does.
+// They don't account for retries (see WithChangedFiles) or for anything else modifying the stores in the
+// meantime. Wrappers (e.g. WithHooks() or Deduplicated()) report the requests of the store they wrap,
+// based on the file sizes the wrapper reports, so layers that change the data (e.g. Compressed()) are
+// only approximate.
+//
+// Example:
+//
+//	estimate, err := filestore.EstimateCopyAll(filestore.Disk("archive"), ".", bucket, "archive")
+//	if err != nil {
+//	    // handle your error nicely
+//	}
+//	fmt.Printf("PUT requests: %d\n", estimate.Destination.Requests["PutObject"])
+//	fmt.Printf("Uploaded: %d bytes\n", estimate.Destination.BytesWritten)
+func EstimateCopyAll(src FS, srcPath string, dst FS, dstPath string, opts ...Option) (CopyEstimate, error) {
+	estimate := CopyEstimate{
+		Source:      StoreCost{Requests: map[string]int{}},
+		Destination: StoreCost{Requests: map[string]int{}},
+	}
+	srcEstimator := findCostEstimator(src)
+	dstEstimator := findCostEstimator(dst)
+
+	// We don't know how many entries are in each directory until we've seen them all, so we tally them
+	// up and figure out the listing requests at the end.
+	var dirs []string
+	entries := map[string]int{}
+	root := path.Clean(srcPath)
+	err := planCopy(src, srcPath, dst, dstPath, opts, nil, func(step copyStep) error {
+		if step.srcPath != root {
+			entries[path.Dir(step.srcPath)]++
+		}
+		switch {
+		case step.dir:
+			dirs = append(dirs, step.srcPath)
+		case step.linkTo != "":
+			estimate.Links++
+		default:
+			estimate.Files++
+			estimate.Bytes += step.size
+			estimate.Source.BytesRead += step.size
+			estimate.Destination.BytesWritten += step.size
+			if srcEstimator != nil {
+				srcEstimator.estimateRead(step.size, &estimate.Source)
+				// CopyAll checks that the file didn't change while it was being copied.
+				srcEstimator.estimateStat(&estimate.Source)
+			}
+			if dstEstimator != nil {
+				dstEstimator.estimateWrite(step.size, &estimate.Destination)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return estimate, err
+	}
+
+	if srcEstimator != nil {
+		for _, dir := range dirs {
+			srcEstimator.estimateList(entries[dir], &estimate.Source)
+		}
+	}
+	return estimate, nil
+}
+
+// findCostEstimator returns the store (or the store that it wraps, like Ping() does) that can estimate
+// its requests, or nil when none of them are billed per request.
+func findCostEstimator(fs FS) costEstimator {
+	switch store := fs.(type) {
+	case costEstimator:
+		return store
+	case wrapper:
+		if inner := store.wrapped(); len(inner) > 0 {
+			return findCostEstimator(inner[0])
+		}
+	}
+	return nil
+}
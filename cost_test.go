@@ -0,0 +1,133 @@
+package filestore_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type CostTestSuite struct {
+	suite.Suite
+	server *filestoretest.S3Server
+	local  filestore.FS
+}
+
+func TestCostTestSuite(t *testing.T) {
+	suite.Run(t, &CostTestSuite{})
+}
+
+func (s *CostTestSuite) SetupTest() {
+	s.server = filestoretest.NewS3Server("lebowski")
+	s.local = filestore.Memory()
+	s.Require().NoError(writeString(s.local, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.local, "2.lebowski", ""))
+	s.Require().NoError(writeString(s.local, "duderino/5.lebowski", "jackie"))
+	s.Require().NoError(writeString(s.local, "duderino/inner/6.lebowski", "nihilist"))
+}
+
+func (s *CostTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *CostTestSuite) bucket(opts ...filestore.Option) filestore.FS {
+	opts = append([]filestore.Option{
+		filestore.WithEndpoint(s.server.URL),
+		filestore.WithRegion("us-east-1"),
+		filestore.WithCredentials("AKID", "SECRET", ""),
+	}, opts...)
+	return filestore.S3("lebowski", opts...)
+}
+
+// requests tallies the requests the fake server has received using the same operation names
+// as the estimates.
+func (s *CostTestSuite) requests() map[string]int {
+	counts := map[string]int{}
+	for _, req := range s.server.Requests() {
+		switch {
+		case req.Method == http.MethodHead:
+			counts["HeadObject"]++
+		case req.Method == http.MethodGet && req.Query.Has("list-type"):
+			counts["ListObjectsV2"]++
+		case req.Method == http.MethodGet:
+			counts["GetObject"]++
+		case req.Method == http.MethodPost && req.Query.Has("uploads"):
+			counts["CreateMultipartUpload"]++
+		case req.Method == http.MethodPost && req.Query.Has("uploadId"):
+			counts["CompleteMultipartUpload"]++
+		case req.Method == http.MethodPut && req.Query.Has("partNumber"):
+			counts["UploadPart"]++
+		case req.Method == http.MethodPut:
+			counts["PutObject"]++
+		}
+	}
+	return counts
+}
+
+func (s *CostTestSuite) TestEstimateCopyAll_upload() {
+	bucket := s.bucket(filestore.WithPartSize(5))
+
+	estimate, err := filestore.EstimateCopyAll(s.local, ".", bucket, "backup")
+	s.Require().NoError(err)
+	s.Require().Equal(4, estimate.Files)
+	s.Require().Equal(0, estimate.Links)
+	s.Require().Equal(int64(18), estimate.Bytes)
+	s.Require().Equal(int64(18), estimate.Source.BytesRead)
+	s.Require().Equal(int64(18), estimate.Destination.BytesWritten)
+	s.Require().Empty(estimate.Source.Requests, "Memory stores aren't billed per request")
+	s.Require().Equal(map[string]int{
+		"PutObject":               2,
+		"CreateMultipartUpload":   2,
+		"UploadPart":              4,
+		"CompleteMultipartUpload": 2,
+	}, estimate.Destination.Requests)
+	s.Require().Empty(s.server.Requests(), "Estimating an upload shouldn't touch the bucket")
+
+	s.Require().NoError(filestore.CopyAll(s.local, ".", bucket, "backup"))
+	s.Require().Equal(estimate.Destination.Requests, s.requests(), "Estimate should match the actual copy")
+}
+
+func (s *CostTestSuite) TestEstimateCopyAll_download() {
+	bucket := s.bucket()
+	s.Require().NoError(filestore.CopyAll(s.local, ".", bucket, "."))
+	s.server.ResetRequests()
+
+	estimate, err := filestore.EstimateCopyAll(bucket, ".", filestore.Memory(), "restore")
+	s.Require().NoError(err)
+	s.Require().Equal(4, estimate.Files)
+	s.Require().Equal(int64(18), estimate.Source.BytesRead)
+	s.Require().Equal(map[string]int{
+		"ListObjectsV2": 3,
+		"HeadObject":    8,
+		"GetObject":     3,
+	}, estimate.Source.Requests)
+	s.Require().Empty(estimate.Destination.Requests)
+
+	s.server.ResetRequests()
+	s.Require().NoError(filestore.CopyAll(bucket, ".", filestore.Memory(), "restore"))
+	s.Require().Equal(estimate.Source.Requests, s.requests(), "Estimate should match the actual copy")
+}
+
+func (s *CostTestSuite) TestEstimateCopyAll_singleFile() {
+	estimate, err := filestore.EstimateCopyAll(s.local, "duderino/5.lebowski", s.bucket(), "5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(1, estimate.Files)
+	s.Require().Equal(int64(6), estimate.Bytes)
+	s.Require().Equal(map[string]int{"PutObject": 1}, estimate.Destination.Requests)
+
+	_, err = filestore.EstimateCopyAll(s.local, "nope", s.bucket(), "nope")
+	s.Require().Error(err)
+}
+
+func (s *CostTestSuite) TestEstimateCopyAll_wrapped() {
+	raw, err := filestore.EstimateCopyAll(s.local, ".", s.bucket(filestore.WithPartSize(5)), "backup")
+	s.Require().NoError(err)
+
+	bucket := filestore.Deduplicated(filestore.WithHooks(s.bucket(filestore.WithPartSize(5)), filestore.Hooks{}))
+	estimate, err := filestore.EstimateCopyAll(s.local, ".", bucket, "backup")
+	s.Require().NoError(err)
+	s.Require().NotEmpty(estimate.Destination.Requests)
+	s.Require().Equal(raw, estimate, "Wrappers should report the requests of the bucket they wrap")
+}
@@ -0,0 +1,147 @@
+package filestore
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ChangeKind describes what happened to a file in a CopyOnWrite() store.
+type ChangeKind string
+
+const (
+	// ChangeAdded indicates that the file did not exist in the base store.
+	ChangeAdded ChangeKind = "added"
+	// ChangeModified indicates that the file exists in the base store, but it has been overwritten.
+	ChangeModified ChangeKind = "modified"
+	// ChangeRemoved indicates that the file exists in the base store, but it has been removed.
+	ChangeRemoved ChangeKind = "removed"
+)
+
+// Change is a single difference between a CopyOnWrite() store and its base store.
+type Change struct {
+	// Path is the path of the file, relative to the working directory of the store you called Diff() on.
+	Path string
+	// Kind indicates whether the file was added, modified, or removed.
+	Kind ChangeKind
+}
+
+// CopyOnWrite wraps a file store so that it is never modified. Every Write(), Move(), and Remove() lands in
+// an in-memory scratch layer on top of it (see Overlay()), so the rest of your code sees its changes while
+// the base store stays exactly as it was. Call Diff() to see what would have changed. This is great for dry
+// runs of tools that manipulate real directory trees.
+//
+// Example:
+//
+//	project := filestore.CopyOnWrite(filestore.Disk("."))
+//	err := build.Run(project)
+//	...
+//	changes, err := project.Diff()
+//	for _, change := range changes {
+//	    fmt.Printf("%s %s\n", change.Kind, change.Path)
+//	}
+func CopyOnWrite(base FS) *CopyOnWriteFS {
+	return &CopyOnWriteFS{overlayFS: &overlayFS{upper: Memory(), lower: []FS{base}, dir: "."}}
+}
+
+// CopyOnWriteFS is a file store that never modifies the store underneath it; see CopyOnWrite().
+type CopyOnWriteFS struct {
+	*overlayFS
+}
+
+// WorkingDirectory returns the working directory of the base store.
+func (c *CopyOnWriteFS) WorkingDirectory() string {
+	return joinPath(c.base().WorkingDirectory(), c.dir)
+}
+
+// ChangeDirectory creates a new store rooted in the given subdirectory that shares the same scratch layer,
+// so changes made through either one are visible to (and reported by) both.
+func (c *CopyOnWriteFS) ChangeDirectory(dir string) FS {
+	return &CopyOnWriteFS{overlayFS: c.overlayFS.ChangeDirectory(dir).(*overlayFS)}
+}
+
+// Diff returns every file that has been added, modified, or removed compared to the base store, sorted
+// by path. Only files are reported; directories that were created or removed show up as the files inside
+// of them. A file that was moved is reported as removed from its old path and added to its new one. Files
+// are reported as modified as soon as they are overwritten, even if the new content happens to be the same.
+func (c *CopyOnWriteFS) Diff() ([]Change, error) {
+	base, scratch := c.base(), c.upper
+	kinds := map[string]ChangeKind{}
+
+	// removedUnder records every file in the base store at/under the path that's no longer visible.
+	removedUnder := func(filePath string) error {
+		if !base.Exists(filePath) {
+			return nil
+		}
+		return Walk(base, filePath, func(basePath string, info FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			if _, current, err := c.find(basePath); err != nil || current.IsDir() {
+				kinds[basePath] = ChangeRemoved
+			}
+			return nil
+		})
+	}
+
+	err := Walk(scratch, ".", func(filePath string, info FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case filePath == ".":
+			return nil
+		case strings.HasPrefix(info.Name(), whiteoutPrefix):
+			return removedUnder(path.Join(path.Dir(filePath), strings.TrimPrefix(info.Name(), whiteoutPrefix)))
+		}
+
+		baseInfo, baseErr := base.Stat(filePath)
+		existed := baseErr == nil && !baseInfo.IsDir()
+		switch {
+		case info.IsDir() && existed:
+			kinds[filePath] = ChangeRemoved
+		case info.IsDir():
+		case existed:
+			kinds[filePath] = ChangeModified
+		default:
+			kinds[filePath] = ChangeAdded
+			if baseErr == nil {
+				// A file replaced an entire directory.
+				return removedUnder(filePath)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for filePath, kind := range kinds {
+		if !isWithinPath(c.dir, filePath) || filePath == c.dir {
+			continue
+		}
+		changes = append(changes, Change{Path: relativeTo(c.dir, filePath), Kind: kind})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func (c *CopyOnWriteFS) withContext(ctx context.Context) FS {
+	return &CopyOnWriteFS{overlayFS: c.overlayFS.withContext(ctx).(*overlayFS)}
+}
+
+func (c *CopyOnWriteFS) base() FS {
+	return c.lower[0]
+}
+
+// wrapped only includes the base store; the scratch layer is ours and doesn't need closing.
+func (c *CopyOnWriteFS) wrapped() []FS {
+	return []FS{c.base()}
+}
+
+var _ FS = &CopyOnWriteFS{}
+var _ requestBinder = &CopyOnWriteFS{}
+var _ wrapper = &CopyOnWriteFS{}
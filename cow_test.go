@@ -0,0 +1,119 @@
+package filestore_test
+
+import (
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type CopyOnWriteTestSuite struct {
+	suite.Suite
+	base filestore.FS
+	fs   *filestore.CopyOnWriteFS
+}
+
+func TestCopyOnWriteTestSuite(t *testing.T) {
+	suite.Run(t, &CopyOnWriteTestSuite{})
+}
+
+func (s *CopyOnWriteTestSuite) SetupTest() {
+	s.base = filestore.Disk(s.T().TempDir())
+	s.Require().NoError(writeString(s.base, "go.mod", "module dude"))
+	s.Require().NoError(writeString(s.base, "main.go", "package main"))
+	s.Require().NoError(writeString(s.base, "build/app", "binary"))
+	s.Require().NoError(writeString(s.base, "build/cache/a.o", "object"))
+	s.fs = filestore.CopyOnWrite(s.base)
+}
+
+func (s *CopyOnWriteTestSuite) TestNeverModifiesBase() {
+	s.Require().NoError(writeString(s.fs, "main.go", "package dude"))
+	s.Require().NoError(writeString(s.fs, "README.md", "# Dude"))
+	s.Require().NoError(s.fs.Remove("build"))
+	s.Require().NoError(s.fs.Move("go.mod", "go.mod.bak"))
+
+	content, err := readString(s.fs, "main.go")
+	s.Require().NoError(err)
+	s.Require().Equal("package dude", content)
+	s.Require().False(s.fs.Exists("build/app"))
+	s.Require().True(s.fs.Exists("go.mod.bak"))
+
+	content, err = readString(s.base, "main.go")
+	s.Require().NoError(err)
+	s.Require().Equal("package main", content)
+	s.Require().True(s.base.Exists("build/cache/a.o"))
+	s.Require().True(s.base.Exists("go.mod"))
+	s.Require().False(s.base.Exists("README.md"))
+	s.Require().False(s.base.Exists("go.mod.bak"))
+	s.Require().Equal(s.base.WorkingDirectory(), s.fs.WorkingDirectory())
+}
+
+func (s *CopyOnWriteTestSuite) TestMove_ontoOrInsideItself() {
+	s.Require().NoError(s.fs.Move("main.go", "main.go"))
+	content, err := readString(s.fs, "main.go")
+	s.Require().NoError(err)
+	s.Require().Equal("package main", content)
+
+	s.Require().Error(s.fs.Move("build", "build/old"))
+	s.Require().True(s.fs.Exists("build/app"))
+	s.Require().True(s.fs.Exists("build/cache/a.o"))
+	s.Require().False(s.fs.Exists("build/old"))
+}
+
+func (s *CopyOnWriteTestSuite) TestDiff() {
+	changes, err := s.fs.Diff()
+	s.Require().NoError(err)
+	s.Require().Empty(changes)
+
+	s.Require().NoError(writeString(s.fs, "main.go", "package dude"))
+	s.Require().NoError(writeString(s.fs, "cmd/tool/main.go", "package main"))
+	s.Require().NoError(s.fs.Remove("build"))
+	s.Require().NoError(writeString(s.fs, "build/app", "new binary"))
+	s.Require().NoError(s.fs.Move("go.mod", "go.mod.bak"))
+	s.Require().NoError(s.fs.Remove("missing.txt"))
+
+	changes, err = s.fs.Diff()
+	s.Require().NoError(err)
+	s.Require().Equal([]filestore.Change{
+		{Path: "build/app", Kind: filestore.ChangeModified},
+		{Path: "build/cache/a.o", Kind: filestore.ChangeRemoved},
+		{Path: "cmd/tool/main.go", Kind: filestore.ChangeAdded},
+		{Path: "go.mod", Kind: filestore.ChangeRemoved},
+		{Path: "go.mod.bak", Kind: filestore.ChangeAdded},
+		{Path: "main.go", Kind: filestore.ChangeModified},
+	}, changes)
+}
+
+func (s *CopyOnWriteTestSuite) TestDiff_replacedTypes() {
+	// A file where there used to be a directory, and vice versa.
+	s.Require().NoError(s.fs.Move("main.go", "build"))
+	s.Require().NoError(s.fs.Remove("go.mod"))
+	s.Require().NoError(writeString(s.fs, "go.mod/nested.txt", "nested"))
+
+	changes, err := s.fs.Diff()
+	s.Require().NoError(err)
+	s.Require().Equal([]filestore.Change{
+		{Path: "build", Kind: filestore.ChangeAdded},
+		{Path: "build/app", Kind: filestore.ChangeRemoved},
+		{Path: "build/cache/a.o", Kind: filestore.ChangeRemoved},
+		{Path: "go.mod", Kind: filestore.ChangeRemoved},
+		{Path: "go.mod/nested.txt", Kind: filestore.ChangeAdded},
+		{Path: "main.go", Kind: filestore.ChangeRemoved},
+	}, changes)
+}
+
+func (s *CopyOnWriteTestSuite) TestChangeDirectory() {
+	build := s.fs.ChangeDirectory("build").(*filestore.CopyOnWriteFS)
+	s.Require().NoError(build.Remove("cache"))
+	s.Require().NoError(writeString(s.fs, "main.go", "package dude"))
+
+	changes, err := build.Diff()
+	s.Require().NoError(err)
+	s.Require().Equal([]filestore.Change{{Path: "cache/a.o", Kind: filestore.ChangeRemoved}}, changes,
+		"Should only report changes inside the directory")
+	s.Require().True(s.base.Exists("build/cache/a.o"))
+
+	changes, err = s.fs.Diff()
+	s.Require().NoError(err)
+	s.Require().Len(changes, 2, "Should share the scratch layer")
+}
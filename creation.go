@@ -0,0 +1,32 @@
+package filestore
+
+import (
+	"time"
+)
+
+// creationTimer is implemented by FileInfo values that know when their file was originally
+// created. Backends whose native 'stat' info doesn't carry a birth time (e.g. Linux) can
+// wrap their FileInfo values with this to still participate in creation-time filtering.
+type creationTimer interface {
+	CreationTime() (time.Time, bool)
+}
+
+// CreationTime returns the time that the file was originally created (its "birth time"). Not
+// every platform/file system records this, so the boolean result indicates whether
+// the returned time is actually meaningful or not.
+//
+// Example:
+//
+//	info, err := files.Stat("contract.pdf")
+//	if created, ok := filestore.CreationTime(info); ok {
+//	    fmt.Printf("Created on %v\n", created)
+//	}
+func CreationTime(info FileInfo) (time.Time, bool) {
+	if info == nil {
+		return time.Time{}, false
+	}
+	if timer, ok := info.(creationTimer); ok {
+		return timer.CreationTime()
+	}
+	return sysCreationTime(info.Sys())
+}
@@ -0,0 +1,23 @@
+//go:build darwin || freebsd || netbsd
+
+package filestore
+
+import (
+	"syscall"
+	"time"
+)
+
+// sysCreationTime extracts the birth time from the platform-specific 'stat' info. BSD-style
+// systems track this directly in the stat structure, so no additional system calls are required.
+func sysCreationTime(sys any) (time.Time, bool) {
+	stat, ok := sys.(*syscall.Stat_t)
+	if !ok || stat == nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(stat.Birthtimespec.Sec), int64(stat.Birthtimespec.Nsec)), true
+}
+
+// diskFileInfo is a no-op on platforms whose FileInfo already carries the creation time.
+func diskFileInfo(info FileInfo, _ string) FileInfo {
+	return info
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package filestore
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysCreationTime always fails on Linux. The standard stat structure doesn't include the
+// birth time, so we need to use statx(2) on the file's path instead (see diskFileInfo).
+func sysCreationTime(_ any) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// diskFileInfo decorates the standard 'stat' info with the ability to look up the file's
+// birth time using statx(2). The lookup is lazy, so you only pay for the extra system
+// call if you actually ask for the creation time.
+func diskFileInfo(info FileInfo, fullPath string) FileInfo {
+	return linuxFileInfo{FileInfo: info, fullPath: fullPath}
+}
+
+type linuxFileInfo struct {
+	FileInfo
+	fullPath string
+}
+
+// CreationTime uses statx(2) to fetch the file's birth time. Older kernels and some file
+// systems (e.g. tmpfs on older kernels) don't record this, in which case 'ok' is false.
+func (info linuxFileInfo) CreationTime() (time.Time, bool) {
+	var stat unix.Statx_t
+	err := unix.Statx(unix.AT_FDCWD, info.fullPath, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &stat)
+	if err != nil || stat.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stat.Btime.Sec, int64(stat.Btime.Nsec)), true
+}
@@ -0,0 +1,17 @@
+//go:build !darwin && !freebsd && !netbsd && !windows && !linux
+
+package filestore
+
+import (
+	"time"
+)
+
+// sysCreationTime always fails on platforms where we don't know how to find the birth time.
+func sysCreationTime(_ any) (time.Time, bool) {
+	return time.Time{}, false
+}
+
+// diskFileInfo is a no-op on platforms where we can't determine creation times.
+func diskFileInfo(info FileInfo, _ string) FileInfo {
+	return info
+}
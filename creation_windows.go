@@ -0,0 +1,22 @@
+//go:build windows
+
+package filestore
+
+import (
+	"syscall"
+	"time"
+)
+
+// sysCreationTime extracts the creation time from the Windows file attribute data.
+func sysCreationTime(sys any) (time.Time, bool) {
+	attrs, ok := sys.(*syscall.Win32FileAttributeData)
+	if !ok || attrs == nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, attrs.CreationTime.Nanoseconds()), true
+}
+
+// diskFileInfo is a no-op on platforms whose FileInfo already carries the creation time.
+func diskFileInfo(info FileInfo, _ string) FileInfo {
+	return info
+}
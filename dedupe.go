@@ -0,0 +1,153 @@
+package filestore
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Deduplicated wraps a file store so that concurrent reads of the same file are collapsed into a single
+// read from the underlying store; every caller waiting on that path gets its own reader over the shared
+// result. This prevents the "stampede" of identical origin fetches you get when a popular file on a
+// remote store is requested by many goroutines at once (e.g. right after a cache invalidation).
+//
+// Each read loads the entire file into memory, so this is best suited for the kinds of small,
+// popular assets that cause stampedes in the first place. Writes, moves, and removes that go
+// through this wrapper ensure that subsequent reads of the affected paths fetch fresh data rather
+// than joining a read that was already in flight.
+//
+// Example:
+//
+//	assets := filestore.Deduplicated(remoteStore)
+//	logo, err := assets.Read("images/logo.png") // 1,000 concurrent calls -> 1 remote fetch
+func Deduplicated(fs FS) FS {
+	return &dedupedFS{FS: fs, group: &readGroup{calls: map[string]*readCall{}}}
+}
+
+func init() {
+	RegisterLayer("deduplicated", func(fs FS, _ LayerOptions) (FS, error) {
+		return Deduplicated(fs), nil
+	})
+}
+
+type dedupedFS struct {
+	FS
+	group *readGroup
+}
+
+// readGroup tracks all of the reads currently in flight, keyed by the file's full path. It's shared by
+// the original wrapper and any instances derived from it via ChangeDirectory().
+type readGroup struct {
+	mutex sync.Mutex
+	calls map[string]*readCall
+}
+
+// readCall is a single in-flight read that any number of callers may wait on.
+type readCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// key determines which file a path refers to, regardless of which directory the caller cd'd into.
+func (d *dedupedFS) key(filePath string) string {
+	return joinPath(d.FS.WorkingDirectory(), filePath)
+}
+
+// forget ensures that future reads of this path (or of anything inside of it, when it's a directory) perform
+// a fresh read rather than joining one already in flight.
+func (d *dedupedFS) forget(fileOrDirPath string) {
+	prefix := d.key(fileOrDirPath)
+
+	d.group.mutex.Lock()
+	defer d.group.mutex.Unlock()
+	for key := range d.group.calls {
+		if isWithinPath(prefix, key) {
+			delete(d.group.calls, key)
+		}
+	}
+}
+
+// Read either starts a new read of the file or waits for one already in flight for the same path.
+func (d *dedupedFS) Read(filePath string) (ReaderFile, error) {
+	key := d.key(filePath)
+
+	d.group.mutex.Lock()
+	if call, ok := d.group.calls[key]; ok {
+		d.group.mutex.Unlock()
+		<-call.done
+		return call.result()
+	}
+	call := &readCall{done: make(chan struct{})}
+	d.group.calls[key] = call
+	d.group.mutex.Unlock()
+
+	call.data, call.err = d.readAll(filePath)
+	close(call.done)
+
+	d.group.mutex.Lock()
+	if d.group.calls[key] == call {
+		delete(d.group.calls, key)
+	}
+	d.group.mutex.Unlock()
+
+	return call.result()
+}
+
+func (d *dedupedFS) readAll(filePath string) ([]byte, error) {
+	file, err := d.FS.Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, newPathError("deduplicated", "read", filePath, err)
+	}
+	return data, nil
+}
+
+// result gives the caller their own reader over the shared data. The data is never modified, so
+// there's no need to copy it for each caller.
+func (call *readCall) result() (ReaderFile, error) {
+	if call.err != nil {
+		return nil, call.err
+	}
+	return newBytesReaderFile(call.data), nil
+}
+
+func (d *dedupedFS) Write(filePath string) (WriterFile, error) {
+	d.forget(filePath)
+	return d.FS.Write(filePath)
+}
+
+func (d *dedupedFS) Remove(fileOrDirPath string) error {
+	d.forget(fileOrDirPath)
+	return d.FS.Remove(fileOrDirPath)
+}
+
+func (d *dedupedFS) Move(fromPath string, toPath string) error {
+	d.forget(fromPath)
+	d.forget(toPath)
+	return d.FS.Move(fromPath, toPath)
+}
+
+func (d *dedupedFS) ChangeDirectory(dir string) FS {
+	return &dedupedFS{FS: d.FS.ChangeDirectory(dir), group: d.group}
+}
+
+func (d *dedupedFS) withContext(ctx context.Context) FS {
+	return &dedupedFS{FS: ForRequest(d.FS, ctx), group: d.group}
+}
+
+func (d *dedupedFS) requestContext() context.Context {
+	return RequestContext(d.FS)
+}
+
+func (d *dedupedFS) wrapped() []FS {
+	return []FS{d.FS}
+}
+
+var _ requestBinder = &dedupedFS{}
+var _ wrapper = &dedupedFS{}
@@ -0,0 +1,135 @@
+package filestore_test
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type DedupeTestSuite struct {
+	suite.Suite
+}
+
+func TestDedupeTestSuite(t *testing.T) {
+	suite.Run(t, &DedupeTestSuite{})
+}
+
+func (s *DedupeTestSuite) TestRead_concurrent() {
+	inner := &slowReadFS{FS: filestore.Memory(), release: make(chan struct{})}
+	s.write(inner.FS, "logo.png", "abide")
+	fs := filestore.Deduplicated(inner)
+
+	results := make([]string, 20)
+	wg := sync.WaitGroup{}
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = s.read(fs, "logo.png")
+		}(i)
+	}
+
+	// Give all of the goroutines a chance to pile up on the one in-flight read.
+	time.Sleep(50 * time.Millisecond)
+	close(inner.release)
+	wg.Wait()
+
+	s.Require().Equal(int32(1), inner.reads.Load(), "Concurrent reads should result in a single origin read")
+	for _, result := range results {
+		s.Require().Equal("abide", result)
+	}
+}
+
+func (s *DedupeTestSuite) TestRead_sequential() {
+	inner := &slowReadFS{FS: filestore.Memory(), release: make(chan struct{})}
+	close(inner.release)
+	s.write(inner.FS, "logo.png", "abide")
+	fs := filestore.Deduplicated(inner)
+
+	s.Require().Equal("abide", s.read(fs, "logo.png"))
+	s.Require().Equal("abide", s.read(fs, "logo.png"))
+	s.Require().Equal(int32(2), inner.reads.Load(), "Reads that don't overlap should not be de-duplicated")
+
+	s.write(fs, "logo.png", "the dude")
+	s.Require().Equal("the dude", s.read(fs, "logo.png"))
+
+	_, err := fs.Read("nope.png")
+	s.Require().Error(err, "Errors should be returned to callers")
+}
+
+func (s *DedupeTestSuite) TestForget() {
+	inner := &slowReadFS{FS: filestore.Memory(), release: make(chan struct{})}
+	s.write(inner.FS, "logo.png", "abide")
+	fs := filestore.Deduplicated(inner)
+
+	first := make(chan string)
+	go func() { first <- s.read(fs, "logo.png") }()
+	time.Sleep(20 * time.Millisecond)
+
+	// Writing through the wrapper should prevent new readers from joining the stale read.
+	s.write(fs, "logo.png", "the dude")
+	second := make(chan string)
+	go func() { second <- s.read(fs, "logo.png") }()
+	time.Sleep(20 * time.Millisecond)
+
+	close(inner.release)
+	<-first
+	s.Require().Equal("the dude", <-second)
+	s.Require().Equal(int32(2), inner.reads.Load())
+}
+
+func (s *DedupeTestSuite) TestForget_directory() {
+	inner := &slowReadFS{FS: filestore.Memory(), release: make(chan struct{})}
+	s.write(inner.FS, "images/2022/logo.png", "abide")
+	fs := filestore.Deduplicated(inner)
+
+	first := make(chan string)
+	go func() { first <- s.read(fs, "images/2022/logo.png") }()
+	time.Sleep(20 * time.Millisecond)
+
+	// Removing the directory should also forget the reads of everything inside of it.
+	s.Require().NoError(fs.Remove("images"))
+	second := make(chan string)
+	go func() { second <- s.read(fs, "images/2022/logo.png") }()
+	time.Sleep(20 * time.Millisecond)
+
+	close(inner.release)
+	<-first
+	s.Require().Equal("", <-second)
+	s.Require().Equal(int32(2), inner.reads.Load())
+}
+
+func (s *DedupeTestSuite) write(fs filestore.FS, name string, content string) {
+	file, err := fs.Write(name)
+	s.Require().NoError(err)
+	_, _ = file.Write([]byte(content))
+	s.Require().NoError(file.Close())
+}
+
+func (s *DedupeTestSuite) read(fs filestore.FS, name string) string {
+	file, err := fs.Read(name)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	data, _ := io.ReadAll(file)
+	return string(data)
+}
+
+// slowReadFS blocks all reads until the release channel is closed, counting how many actually happened.
+type slowReadFS struct {
+	filestore.FS
+	reads   atomic.Int32
+	release chan struct{}
+}
+
+func (fs *slowReadFS) Read(name string) (filestore.ReaderFile, error) {
+	fs.reads.Add(1)
+	<-fs.release
+	return fs.FS.Read(name)
+}
@@ -0,0 +1,334 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// derivedDir is the name of the hidden directory, alongside the source files, that holds the
+// derivatives of each file in that directory.
+const derivedDir = ".derived"
+
+// DerivedPath returns the conventional location of a single derivative of the source file, so every
+// service that creates or looks up thumbnails, previews, etc. agrees on where they live. Derivatives are
+// kept in a hidden ".derived" directory next to the source, in a subdirectory named after the source
+// file. The extension is optional.
+//
+// Example:
+//
+//	// Returns "photos/.derived/beach.jpg/thumb@2x.webp"
+//	thumbPath := filestore.DerivedPath("photos/beach.jpg", "thumb@2x", "webp")
+func DerivedPath(source string, variant string, ext string) string {
+	name := variant
+	if ext = strings.TrimPrefix(ext, "."); ext != "" {
+		name += "." + ext
+	}
+	return path.Join(derivedSourceDir(source), name)
+}
+
+// DerivedFiles returns the paths of all of the derivatives that currently exist for the source file.
+// It returns an empty slice (not an error) when the file has no derivatives.
+func DerivedFiles(fsys FS, source string) ([]string, error) {
+	dir := derivedSourceDir(source)
+	if !fsys.Exists(dir) {
+		return nil, nil
+	}
+	infos, err := fsys.List(dir)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: derived files: %w", err)
+	}
+	var paths []string
+	for _, info := range infos {
+		if !info.IsDir() {
+			paths = append(paths, path.Join(dir, info.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// IsDerivedStale returns true when the derivative at the given path (see DerivedPath) needs to be
+// (re)generated because it doesn't exist or the source file has been modified since it was created.
+func IsDerivedStale(fsys FS, source string, derived string) (bool, error) {
+	sourceInfo, err := fsys.Stat(source)
+	if err != nil {
+		return false, fmt.Errorf("filestore: check derived: %w", err)
+	}
+	derivedInfo, err := fsys.Stat(derived)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return true, nil
+	case err != nil:
+		return false, fmt.Errorf("filestore: check derived: %w", err)
+	default:
+		return derivedInfo.ModTime().Before(sourceInfo.ModTime()), nil
+	}
+}
+
+// InvalidateDerived removes all of the derivatives of the source file, typically because the source
+// has changed and they need to be regenerated. It does nothing if the file has no derivatives.
+func InvalidateDerived(fsys FS, source string) error {
+	if isDerivedPath(source) {
+		return nil
+	}
+	err := fsys.Remove(derivedSourceDir(source))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("filestore: invalidate derived: %w", err)
+	}
+	return nil
+}
+
+// derivedSourceDir is the directory that holds all of the derivatives of the given source file.
+func derivedSourceDir(source string) string {
+	dir, name := path.Split(path.Clean(source))
+	return path.Join(dir, derivedDir, name)
+}
+
+// isDerivedPath returns true when the path refers to a derivative (or a directory of them) rather
+// than a source file.
+func isDerivedPath(filePath string) bool {
+	for _, segment := range strings.Split(path.Clean(filePath), "/") {
+		if segment == derivedDir {
+			return true
+		}
+	}
+	return false
+}
+
+// Generator creates a single kind of derivative (thumbnail, transcoded video, text extract, etc.) from
+// source files. The package doesn't ship w/ any generators; you provide them using whatever image or
+// media libraries you like.
+type Generator struct {
+	// Variant names the derivative, e.g. "thumb" or "thumb@2x".
+	Variant string
+	// Ext is the file extension of the derivative, e.g. "webp". It can be empty.
+	Ext string
+	// Filter decides which source files this generator applies to (nil applies to every file).
+	Filter FileFilter
+	// Generate reads the source file's data and writes the derivative's data.
+	Generate func(source io.Reader, derived io.Writer) error
+}
+
+// Derivatives wraps a file store so that derived files (thumbnails, previews, transcodes, etc.) are
+// generated automatically whenever you write a source file. When you close a file you've written, we
+// run every generator whose filter matches the file, storing each result at its DerivedPath() (e.g.
+// "photos/.derived/beach.jpg/thumb.webp"). Derivatives are removed and moved along w/ their source
+// files, and listings don't include the ".derived" directories.
+//
+// Generators run synchronously when the source file is closed. If one fails, Close() returns its error,
+// but the source file has still been written; use Regenerate() to try again. Filters and generators that
+// panic fail w/ a *PanicError.
+//
+// Example:
+//
+//	photos := filestore.Derivatives(filestore.Disk("photos"), filestore.Generator{
+//	    Variant:  "thumb",
+//	    Ext:      "jpg",
+//	    Filter:   filestore.WithExts("jpg", "png"),
+//	    Generate: makeThumbnail, // func(source io.Reader, derived io.Writer) error
+//	})
+func Derivatives(fs FS, generators ...Generator) *DerivativesFS {
+	return &DerivativesFS{FS: fs, generators: generators}
+}
+
+// DerivativesFS is a file store wrapper that automatically generates derived files; see Derivatives().
+type DerivativesFS struct {
+	FS
+	generators []Generator
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that uses the same generators.
+func (d *DerivativesFS) ChangeDirectory(dir string) FS {
+	return &DerivativesFS{FS: d.FS.ChangeDirectory(dir), generators: d.generators}
+}
+
+// Write opens the file for writing. Its derivatives are generated once you close it.
+func (d *DerivativesFS) Write(filePath string) (WriterFile, error) {
+	file, err := d.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if isDerivedPath(filePath) {
+		return file, nil
+	}
+	return &derivativesWriterFile{WriterFile: file, fs: d, path: filePath}, nil
+}
+
+// List hides the directories that contain derivatives; everything else is listed as usual.
+func (d *DerivativesFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	infos, err := d.FS.List(dirPath, filters...)
+	if err != nil {
+		return nil, err
+	}
+	var results []FileInfo
+	for _, info := range infos {
+		if info.Name() != derivedDir {
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// Remove deletes the file/directory along w/ any derivatives.
+func (d *DerivativesFS) Remove(fileOrDirPath string) error {
+	if err := d.FS.Remove(fileOrDirPath); err != nil {
+		return err
+	}
+	return InvalidateDerived(d.FS, fileOrDirPath)
+}
+
+// Move relocates the file/directory, taking its derivatives w/ it. Derivatives of a directory's
+// files move along w/ the directory itself.
+func (d *DerivativesFS) Move(fromPath string, toPath string) error {
+	if err := d.FS.Move(fromPath, toPath); err != nil {
+		return err
+	}
+	if isDerivedPath(fromPath) || !d.FS.Exists(derivedSourceDir(fromPath)) {
+		return nil
+	}
+	if err := InvalidateDerived(d.FS, toPath); err != nil {
+		return err
+	}
+	return d.FS.Move(derivedSourceDir(fromPath), derivedSourceDir(toPath))
+}
+
+// Regenerate (re)creates the derivatives of the given file, or every file in the given directory and
+// its subdirectories. This is handy after you add a new generator or when a generator failed earlier.
+func (d *DerivativesFS) Regenerate(fileOrDirPath string) error {
+	err := Walk(d, fileOrDirPath, func(filePath string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return d.generate(filePath)
+	})
+	if err != nil {
+		return fmt.Errorf("filestore: regenerate: %w", err)
+	}
+	return nil
+}
+
+// Cleanup removes derivatives whose source files no longer exist anywhere in the given directory (or
+// its subdirectories), which can happen when the source was removed w/o going through this wrapper.
+// It returns the number of source files whose derivatives were removed.
+func (d *DerivativesFS) Cleanup(dir string) (int, error) {
+	var orphans []string
+	err := Walk(d.FS, dir, func(filePath string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() || path.Base(path.Dir(filePath)) != derivedDir {
+			return nil
+		}
+		// This is ".derived/<source name>"; we don't need to look inside of it.
+		source := path.Join(path.Dir(path.Dir(filePath)), info.Name())
+		if !d.FS.Exists(source) {
+			orphans = append(orphans, filePath)
+		}
+		return fs.SkipDir
+	})
+	if err != nil {
+		return 0, fmt.Errorf("filestore: cleanup derivatives: %w", err)
+	}
+	for _, orphan := range orphans {
+		if err := d.FS.Remove(orphan); err != nil {
+			return 0, fmt.Errorf("filestore: cleanup derivatives: %w", err)
+		}
+	}
+	return len(orphans), nil
+}
+
+// generate replaces all of the file's derivatives w/ fresh ones from the generators that match it.
+func (d *DerivativesFS) generate(filePath string) error {
+	info, err := d.FS.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	// Start from scratch so that derivatives from generators that no longer match don't stick around.
+	if err := InvalidateDerived(d.FS, filePath); err != nil {
+		return err
+	}
+	for _, generator := range d.generators {
+		if generator.Filter != nil {
+			matches, err := applyFilter(generator.Filter, info)
+			if err != nil {
+				return fmt.Errorf("generate %s: %s: %w", generator.Variant, filePath, err)
+			}
+			if !matches {
+				continue
+			}
+		}
+		if err := d.generateOne(filePath, generator); err != nil {
+			return fmt.Errorf("generate %s: %s: %w", generator.Variant, filePath, err)
+		}
+	}
+	return nil
+}
+
+func (d *DerivativesFS) generateOne(filePath string, generator Generator) error {
+	source, err := d.FS.Read(filePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target := DerivedPath(filePath, generator.Variant, generator.Ext)
+	derived, err := d.FS.Write(target)
+	if err != nil {
+		return err
+	}
+	if err := runGenerator(generator, source, derived); err != nil {
+		_ = derived.Close()
+		_ = d.FS.Remove(target)
+		return err
+	}
+	return derived.Close()
+}
+
+// runGenerator invokes the generator, converting a panic into a *PanicError so that a misbehaving generator
+// can't crash the process.
+func runGenerator(generator Generator, source io.Reader, derived io.Writer) (err error) {
+	defer recoverPanic(&err)
+	return generator.Generate(source, derived)
+}
+
+func (d *DerivativesFS) withContext(ctx context.Context) FS {
+	return &DerivativesFS{FS: ForRequest(d.FS, ctx), generators: d.generators}
+}
+
+func (d *DerivativesFS) requestContext() context.Context {
+	return RequestContext(d.FS)
+}
+
+func (d *DerivativesFS) wrapped() []FS {
+	return []FS{d.FS}
+}
+
+// derivativesWriterFile generates the source file's derivatives once it has been written.
+type derivativesWriterFile struct {
+	WriterFile
+	fs     *DerivativesFS
+	path   string
+	closed bool
+}
+
+func (f *derivativesWriterFile) Close() error {
+	if f.closed {
+		return f.WriterFile.Close()
+	}
+	f.closed = true
+	if err := f.WriterFile.Close(); err != nil {
+		return err
+	}
+	return f.fs.generate(f.path)
+}
+
+var _ FS = &DerivativesFS{}
+var _ requestBinder = &DerivativesFS{}
+var _ wrapper = &DerivativesFS{}
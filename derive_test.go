@@ -0,0 +1,263 @@
+package filestore_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeriveTestSuite struct {
+	suite.Suite
+}
+
+func TestDeriveTestSuite(t *testing.T) {
+	suite.Run(t, &DeriveTestSuite{})
+}
+
+// upperGenerator is a stand-in for a thumbnailer; its derivative is the source data in all caps.
+var upperGenerator = filestore.Generator{
+	Variant: "upper",
+	Ext:     "txt",
+	Filter:  filestore.WithExt("txt"),
+	Generate: func(source io.Reader, derived io.Writer) error {
+		data, err := io.ReadAll(source)
+		if err != nil {
+			return err
+		}
+		_, err = derived.Write(bytes.ToUpper(data))
+		return err
+	},
+}
+
+// sizeGenerator applies to every file; its derivative is the source's length.
+var sizeGenerator = filestore.Generator{
+	Variant: "size",
+	Generate: func(source io.Reader, derived io.Writer) error {
+		n, err := io.Copy(io.Discard, source)
+		if err != nil {
+			return err
+		}
+		_, err = derived.Write([]byte{byte('0' + n)})
+		return err
+	},
+}
+
+func (s *DeriveTestSuite) TestWrite() {
+	store := filestore.Memory()
+	fs := filestore.Derivatives(store, upperGenerator, sizeGenerator)
+
+	s.Require().NoError(writeString(fs, "notes/a.txt", "abide"))
+	s.assertFile(store, "notes/.derived/a.txt/upper.txt", "ABIDE")
+	s.assertFile(store, "notes/.derived/a.txt/size", "5")
+
+	s.Require().NoError(writeString(fs, "notes/b.csv", "dude"))
+	s.Require().False(store.Exists("notes/.derived/b.csv/upper.txt"), "Filter should skip non-matching files")
+	s.assertFile(store, "notes/.derived/b.csv/size", "4")
+
+	// Overwriting should regenerate, and the sources should be all that you see in listings.
+	s.Require().NoError(writeString(fs, "notes/a.txt", "rug"))
+	s.assertFile(store, "notes/.derived/a.txt/upper.txt", "RUG")
+	s.assertFile(store, "notes/.derived/a.txt/size", "3")
+	s.Require().Equal([]string{"a.txt", "b.csv"}, s.list(fs, "notes"))
+
+	// Derivatives are readable through the wrapper, and writing them doesn't spawn derivatives of derivatives.
+	s.assertFile(fs, "notes/.derived/a.txt/upper.txt", "RUG")
+	s.Require().NoError(writeString(fs, "notes/.derived/a.txt/upper.txt", "rug!"))
+	s.Require().False(store.Exists("notes/.derived/.derived"))
+
+	sub := fs.ChangeDirectory("notes")
+	s.Require().NoError(writeString(sub, "c.txt", "walter"))
+	s.assertFile(store, "notes/.derived/c.txt/upper.txt", "WALTER")
+}
+
+func (s *DeriveTestSuite) TestWrite_generatorFails() {
+	store := filestore.Memory()
+	broken := filestore.Generator{
+		Variant: "broken",
+		Generate: func(source io.Reader, derived io.Writer) error {
+			_, _ = derived.Write([]byte("partial"))
+			return errors.New("nope")
+		},
+	}
+	fs := filestore.Derivatives(store, broken)
+
+	s.Require().Error(writeString(fs, "a.txt", "abide"))
+	s.assertFile(store, "a.txt", "abide")
+	s.Require().False(store.Exists(".derived/a.txt/broken"), "Partial derivatives should be removed")
+}
+
+func (s *DeriveTestSuite) TestWrite_generatorPanics() {
+	store := filestore.Memory()
+	explodingFilter := filestore.Generator{
+		Variant:  "filter",
+		Filter:   func(filestore.FileInfo) bool { panic("bad filter") },
+		Generate: sizeGenerator.Generate,
+	}
+	explodingGenerator := filestore.Generator{
+		Variant: "generate",
+		Generate: func(source io.Reader, derived io.Writer) error {
+			_, _ = derived.Write([]byte("partial"))
+			panic("bad generator")
+		},
+	}
+
+	var panicErr *filestore.PanicError
+	err := writeString(filestore.Derivatives(store, explodingFilter), "a.txt", "abide")
+	s.Require().True(errors.As(err, &panicErr), "Should be a *PanicError: %v", err)
+	s.Require().Equal("bad filter", panicErr.Value)
+
+	err = writeString(filestore.Derivatives(store, explodingGenerator), "a.txt", "abide")
+	s.Require().True(errors.As(err, &panicErr), "Should be a *PanicError: %v", err)
+	s.Require().Equal("bad generator", panicErr.Value)
+	s.assertFile(store, "a.txt", "abide")
+	s.Require().False(store.Exists(".derived/a.txt/generate"), "Partial derivatives should be removed")
+}
+
+func (s *DeriveTestSuite) TestRemove() {
+	store := filestore.Memory()
+	fs := filestore.Derivatives(store, upperGenerator)
+	s.Require().NoError(writeString(fs, "notes/a.txt", "abide"))
+	s.Require().NoError(writeString(fs, "notes/b.txt", "dude"))
+
+	s.Require().NoError(fs.Remove("notes/a.txt"))
+	s.Require().False(store.Exists("notes/.derived/a.txt"))
+	s.Require().True(store.Exists("notes/.derived/b.txt/upper.txt"))
+
+	s.Require().NoError(fs.Remove("notes"))
+	s.Require().False(store.Exists("notes"))
+}
+
+func (s *DeriveTestSuite) TestMove() {
+	store := filestore.Memory()
+	fs := filestore.Derivatives(store, upperGenerator)
+	s.Require().NoError(writeString(fs, "notes/a.txt", "abide"))
+
+	s.Require().NoError(fs.Move("notes/a.txt", "archive/z.txt"))
+	s.Require().False(store.Exists("notes/.derived/a.txt"))
+	s.assertFile(store, "archive/.derived/z.txt/upper.txt", "ABIDE")
+
+	s.Require().NoError(fs.Move("archive", "old"))
+	s.assertFile(store, "old/.derived/z.txt/upper.txt", "ABIDE")
+}
+
+func (s *DeriveTestSuite) TestRegenerate() {
+	store := filestore.Memory()
+	s.Require().NoError(writeString(store, "notes/a.txt", "abide"))
+	s.Require().NoError(writeString(store, "notes/deep/b.txt", "dude"))
+	fs := filestore.Derivatives(store, upperGenerator)
+
+	s.Require().NoError(fs.Regenerate("notes/a.txt"))
+	s.assertFile(store, "notes/.derived/a.txt/upper.txt", "ABIDE")
+	s.Require().False(store.Exists("notes/deep/.derived"))
+
+	s.Require().NoError(fs.Regenerate("."))
+	s.assertFile(store, "notes/deep/.derived/b.txt/upper.txt", "DUDE")
+	s.Require().False(store.Exists("notes/.derived/.derived"), "Regenerate should skip derivatives")
+
+	s.Require().Error(fs.Regenerate("nope.txt"))
+}
+
+func (s *DeriveTestSuite) TestCleanup() {
+	store := filestore.Memory()
+	fs := filestore.Derivatives(store, upperGenerator)
+	s.Require().NoError(writeString(fs, "notes/a.txt", "abide"))
+	s.Require().NoError(writeString(fs, "notes/b.txt", "dude"))
+	s.Require().NoError(writeString(fs, "notes/deep/c.txt", "walter"))
+
+	// Remove sources behind the wrapper's back.
+	s.Require().NoError(store.Remove("notes/a.txt"))
+	s.Require().NoError(store.Remove("notes/deep/c.txt"))
+
+	removed, err := fs.Cleanup(".")
+	s.Require().NoError(err)
+	s.Require().Equal(2, removed)
+	s.Require().False(store.Exists("notes/.derived/a.txt"))
+	s.Require().False(store.Exists("notes/deep/.derived/c.txt"))
+	s.assertFile(store, "notes/.derived/b.txt/upper.txt", "DUDE")
+
+	removed, err = fs.Cleanup(".")
+	s.Require().NoError(err)
+	s.Require().Equal(0, removed)
+}
+
+func (s *DeriveTestSuite) assertFile(fs filestore.FS, name string, expected string) {
+	content, err := readString(fs, name)
+	s.Require().NoError(err, name)
+	s.Require().Equal(expected, content, name)
+}
+
+func (s *DeriveTestSuite) list(fs filestore.FS, dir string) []string {
+	infos, err := fs.List(dir)
+	s.Require().NoError(err)
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
+
+func (s *DeriveTestSuite) TestDerivedPath() {
+	s.Require().Equal("photos/.derived/beach.jpg/thumb@2x.webp", filestore.DerivedPath("photos/beach.jpg", "thumb@2x", "webp"))
+	s.Require().Equal("photos/.derived/beach.jpg/thumb@2x.webp", filestore.DerivedPath("photos/beach.jpg", "thumb@2x", ".webp"))
+	s.Require().Equal(".derived/beach.jpg/thumb", filestore.DerivedPath("beach.jpg", "thumb", ""))
+	s.Require().Equal("/photos/.derived/beach.jpg/thumb.webp", filestore.DerivedPath("/photos/./beach.jpg", "thumb", "webp"))
+}
+
+func (s *DeriveTestSuite) TestDerivedFiles() {
+	fs := filestore.Derivatives(filestore.Memory(), upperGenerator, sizeGenerator)
+	s.Require().NoError(writeString(fs, "notes/a.txt", "abide"))
+
+	paths, err := filestore.DerivedFiles(fs, "notes/a.txt")
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"notes/.derived/a.txt/size", "notes/.derived/a.txt/upper.txt"}, paths)
+
+	paths, err = filestore.DerivedFiles(fs, "notes/nope.txt")
+	s.Require().NoError(err)
+	s.Require().Empty(paths)
+}
+
+func (s *DeriveTestSuite) TestIsDerivedStale() {
+	clock := filestoretest.NewClock(time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC))
+	fs := filestore.Memory(filestore.WithClock(clock))
+	thumbPath := filestore.DerivedPath("a.txt", "thumb", "txt")
+
+	_, err := filestore.IsDerivedStale(fs, "a.txt", thumbPath)
+	s.Require().Error(err, "Should fail when the source doesn't exist")
+
+	s.Require().NoError(writeString(fs, "a.txt", "abide"))
+	stale, err := filestore.IsDerivedStale(fs, "a.txt", thumbPath)
+	s.Require().NoError(err)
+	s.Require().True(stale, "Missing derivatives should be stale")
+
+	clock.Advance(time.Minute)
+	s.Require().NoError(writeString(fs, thumbPath, "ABIDE"))
+	stale, err = filestore.IsDerivedStale(fs, "a.txt", thumbPath)
+	s.Require().NoError(err)
+	s.Require().False(stale)
+
+	clock.Advance(time.Minute)
+	s.Require().NoError(writeString(fs, "a.txt", "the dude"))
+	stale, err = filestore.IsDerivedStale(fs, "a.txt", thumbPath)
+	s.Require().NoError(err)
+	s.Require().True(stale, "Derivatives older than the source should be stale")
+}
+
+func (s *DeriveTestSuite) TestInvalidateDerived() {
+	store := filestore.Memory()
+	fs := filestore.Derivatives(store, upperGenerator)
+	s.Require().NoError(writeString(fs, "notes/a.txt", "abide"))
+	s.Require().NoError(writeString(fs, "notes/b.txt", "dude"))
+
+	s.Require().NoError(filestore.InvalidateDerived(store, "notes/a.txt"))
+	s.Require().False(store.Exists("notes/.derived/a.txt"))
+	s.Require().True(store.Exists("notes/a.txt"))
+	s.Require().True(store.Exists("notes/.derived/b.txt/upper.txt"))
+
+	s.Require().NoError(filestore.InvalidateDerived(store, "notes/a.txt"), "Should be a no-op w/o derivatives")
+}
@@ -0,0 +1,133 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+)
+
+// PushDir works like the shell's "pushd" command. It returns a store rooted in the given directory (just
+// like ChangeDirectory) that also remembers the store you started from, so PopDir() can take you back
+// there once you're done. Calling ChangeDirectory() on the result keeps the stack, so you can wander
+// around as deep as you like and still pop back to where you pushed.
+//
+// Stores are never modified; every call gives you a new one. That makes the stack safe to use from
+// multiple goroutines, and it means that each store you derive has its own stack.
+//
+// Example:
+//
+//	reports := filestore.PushDir(files, "reports/2022/09")
+//	...
+//	files = filestore.PopDir(reports) // back to wherever 'files' was
+func PushDir(fs FS, dir string) FS {
+	stack := &dirStackFS{stack: &dirFrame{fs: fs}}
+	if pushed, ok := fs.(*dirStackFS); ok {
+		stack.FS = pushed.FS.ChangeDirectory(dir)
+		stack.stack.prev = pushed.stack
+		stack.ctx = pushed.ctx
+		return stack
+	}
+	stack.FS = fs.ChangeDirectory(dir)
+	return stack
+}
+
+// PopDir works like the shell's "popd" command. It returns the store that you most recently called
+// PushDir() on to get this one. If nothing was pushed, you just get the same store back.
+func PopDir(fs FS) FS {
+	pushed, ok := fs.(*dirStackFS)
+	if !ok {
+		return fs
+	}
+	return pushed.bind(pushed.stack.fs)
+}
+
+// Root returns the store that you originally called PushDir() on, no matter how many directories you've
+// pushed or changed into since. If nothing was pushed, you just get the same store back.
+func Root(fs FS) FS {
+	pushed, ok := fs.(*dirStackFS)
+	if !ok {
+		return fs
+	}
+	frame := pushed.stack
+	for frame.prev != nil {
+		frame = frame.prev
+	}
+	return pushed.bind(frame.fs)
+}
+
+// Parent returns a store rooted in the parent of the store's working directory. It's the same as calling
+// ChangeDirectory(".."), but it reads a lot better when you climb more than one level.
+//
+// Example:
+//
+//	project := filestore.Parent(filestore.Parent(files)) // instead of files.ChangeDirectory("../..")
+func Parent(fs FS) FS {
+	return fs.ChangeDirectory("..")
+}
+
+// Sub works like io/fs.Sub(). It returns a store rooted in the given subdirectory, but unlike
+// ChangeDirectory(), it makes sure that the directory actually exists first. It fails w/ fs.ErrNotExist if
+// it doesn't, ErrNotDirectory if it's a file, and ErrPathEscapesBase if the path leads outside of the
+// store's working directory (e.g. "../secrets").
+//
+// Example:
+//
+//	reports, err := filestore.Sub(files, "reports/2022")
+//	if errors.Is(err, fs.ErrNotExist) {
+//	    // nothing to report
+//	}
+func Sub(fsys FS, dir string) (FS, error) {
+	if !isWithinPath(".", joinPath(".", dir)) {
+		return nil, fmt.Errorf("filestore: sub: %s: %w", dir, ErrPathEscapesBase)
+	}
+	info, err := fsys.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: sub: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("filestore: sub: %s: %w", dir, ErrNotDirectory)
+	}
+	return fsys.ChangeDirectory(dir), nil
+}
+
+// dirStackFS is a store derived via PushDir() that remembers where it came from.
+type dirStackFS struct {
+	FS
+	stack *dirFrame
+	// ctx is the request context the store was bound to via ForRequest() after it was pushed, if any.
+	ctx context.Context
+}
+
+// dirFrame is a single entry in the directory stack. Frames are never modified once they're created, so
+// any number of stores can safely share them.
+type dirFrame struct {
+	// fs is the store that we called PushDir() on.
+	fs   FS
+	prev *dirFrame
+}
+
+// bind makes sure that a store we pop back to is bound to the same request as the one we popped from.
+func (d *dirStackFS) bind(fs FS) FS {
+	if d.ctx == nil {
+		return fs
+	}
+	return ForRequest(fs, d.ctx)
+}
+
+func (d *dirStackFS) ChangeDirectory(dir string) FS {
+	return &dirStackFS{FS: d.FS.ChangeDirectory(dir), stack: d.stack, ctx: d.ctx}
+}
+
+func (d *dirStackFS) withContext(ctx context.Context) FS {
+	return &dirStackFS{FS: ForRequest(d.FS, ctx), stack: d.stack, ctx: ctx}
+}
+
+func (d *dirStackFS) requestContext() context.Context {
+	return RequestContext(d.FS)
+}
+
+func (d *dirStackFS) wrapped() []FS {
+	return []FS{d.FS}
+}
+
+var _ requestBinder = &dirStackFS{}
+var _ wrapper = &dirStackFS{}
@@ -0,0 +1,82 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type DirStackTestSuite struct {
+	suite.Suite
+	fs filestore.FS
+}
+
+func TestDirStackTestSuite(t *testing.T) {
+	suite.Run(t, &DirStackTestSuite{})
+}
+
+func (s *DirStackTestSuite) SetupTest() {
+	s.fs = filestore.Memory().ChangeDirectory("home")
+}
+
+func (s *DirStackTestSuite) TestPushAndPop() {
+	reports := filestore.PushDir(s.fs, "reports")
+	s.Require().Equal("/home/reports", reports.WorkingDirectory())
+
+	september := filestore.PushDir(reports.ChangeDirectory("2022"), "09")
+	s.Require().Equal("/home/reports/2022/09", september.WorkingDirectory())
+	s.Require().NoError(writeString(september, "totals.csv", "abides"))
+
+	popped := filestore.PopDir(september.ChangeDirectory("../../.."))
+	s.Require().Equal("/home/reports/2022", popped.WorkingDirectory(), "Should pop back to where we pushed from")
+	popped = filestore.PopDir(popped)
+	s.Require().Equal("/home", popped.WorkingDirectory())
+	s.Require().True(popped.Exists("reports/2022/09/totals.csv"))
+
+	s.Require().Equal(s.fs, filestore.PopDir(popped), "Popping an empty stack should do nothing")
+	s.Require().Equal(s.fs, filestore.PopDir(s.fs))
+}
+
+func (s *DirStackTestSuite) TestRoot() {
+	nested := filestore.PushDir(filestore.PushDir(s.fs, "a").ChangeDirectory("b"), "c")
+	s.Require().Equal("/home/a/b/c", nested.WorkingDirectory())
+	s.Require().Equal(s.fs, filestore.Root(nested))
+	s.Require().Equal(s.fs, filestore.Root(s.fs))
+}
+
+func (s *DirStackTestSuite) TestForRequest() {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "dude")
+
+	nested := filestore.ForRequest(filestore.PushDir(filestore.PushDir(s.fs, "a"), "b"), ctx)
+	s.Require().Equal("dude", filestore.RequestContext(nested).Value(key{}))
+	s.Require().Equal("dude", filestore.RequestContext(filestore.PopDir(nested)).Value(key{}))
+	s.Require().Equal("dude", filestore.RequestContext(filestore.Root(nested)).Value(key{}))
+	s.Require().Equal("/home", filestore.Root(nested).WorkingDirectory())
+}
+
+func (s *DirStackTestSuite) TestParent() {
+	nested := s.fs.ChangeDirectory("a/b/c")
+	s.Require().Equal("/home/a/b", filestore.Parent(nested).WorkingDirectory())
+	s.Require().Equal("/home/a", filestore.Parent(filestore.Parent(nested)).WorkingDirectory())
+}
+
+func (s *DirStackTestSuite) TestSub() {
+	s.Require().NoError(writeString(s.fs, "a/b/c.txt", "abide"))
+
+	sub, err := filestore.Sub(s.fs, "a/b")
+	s.Require().NoError(err)
+	s.Require().Equal("/home/a/b", sub.WorkingDirectory())
+	s.Require().True(sub.Exists("c.txt"))
+
+	_, err = filestore.Sub(s.fs, "a/nope")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = filestore.Sub(s.fs, "a/b/c.txt")
+	s.Require().True(errors.Is(err, filestore.ErrNotDirectory))
+	_, err = filestore.Sub(sub, "../../..")
+	s.Require().True(errors.Is(err, filestore.ErrPathEscapesBase))
+}
@@ -1,11 +1,28 @@
 package filestore
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/url"
 	"os"
 	"path"
+	"path/filepath"
+	"sync"
+	"time"
 )
 
+func init() {
+	RegisterScheme("file", func(u *url.URL) (FS, error) {
+		dir := urlPath(u)
+		if dir == "" {
+			dir = "."
+		}
+		return Disk(dir), nil
+	})
+}
+
 // Disk creates a new file store that reads and writes files to/from
 // the local file system. All operations will be rooted in the given directory.
 //
@@ -25,9 +42,29 @@ func Disk(basePath string) *DiskFS {
 	return &DiskFS{basePath: basePath}
 }
 
+// DiskAbs works just like Disk(), but it resolves a relative base path (e.g. "./data") against the
+// current working directory right away and pins the store to the resulting absolute path. A store
+// created using Disk("data") looks up "data" relative to whatever the working directory is when each
+// operation runs, so a long-running process that calls os.Chdir() suddenly points at a different tree.
+// Stores created using DiskAbs() keep pointing at the same one.
+//
+// Example:
+//
+//	files, err := filestore.DiskAbs("data") // e.g. "/srv/app/data", no matter what happens later
+func DiskAbs(basePath string) (*DiskFS, error) {
+	absPath, err := filepath.Abs(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: disk: %w", err)
+	}
+	return Disk(absPath), nil
+}
+
 // DiskFS is a file store whose operations interact w/ the local file system.
 type DiskFS struct {
 	basePath string
+
+	// ctx is the context of the request this store was derived for via ForRequest(), if any.
+	ctx context.Context
 }
 
 // diskFile provides implementations for all reading, writing, and 'stat' information
@@ -92,34 +129,48 @@ func (d diskFile) Close() error {
 
 // Stat fetches metadata about the file w/o actually opening it for reading/writing.
 func (d DiskFS) Stat(filePath string) (FileInfo, error) {
-	file, err := os.Stat(path.Join(d.basePath, filePath))
+	fullPath, err := resolvePath(d.basePath, filePath)
+	if err != nil {
+		return nil, newPathError("disk", "stat", filePath, err)
+	}
+	file, err := os.Stat(fullPath)
 	if err != nil {
-		return nil, fmt.Errorf("disk fs error: stat: %w", err)
+		return nil, newPathError("disk", "stat", filePath, err)
 	}
-	return file, nil
+	return diskFileInfo(file, fullPath), nil
 }
 
 // Exists returns true when the file/directory already exits in the file system.
 func (d DiskFS) Exists(filePath string) bool {
-	_, err := os.Stat(path.Join(d.basePath, filePath))
+	fullPath, err := resolvePath(d.basePath, filePath)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(fullPath)
 	return err == nil
 }
 
 // Read opens the given file at the given path, providing you with an io.Reader that
 // you can use to stream bytes from it.
 func (d DiskFS) Read(filePath string) (ReaderFile, error) {
-	file, err := os.Open(path.Join(d.basePath, filePath))
+	fullPath, err := resolvePath(d.basePath, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("disk fs error: open: %w", err)
+		return nil, newPathError("disk", "open", filePath, err)
+	}
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, newPathError("disk", "open", filePath, err)
 	}
 
 	// Make sure it's not a directory.
 	stat, err := file.Stat()
 	if err != nil {
-		return nil, fmt.Errorf("disk fs error: read: %w", err)
+		_ = file.Close()
+		return nil, newPathError("disk", "open", filePath, err)
 	}
 	if stat.IsDir() {
-		return nil, fmt.Errorf("disk fs error: trying to read directory like a file: %s", filePath)
+		_ = file.Close()
+		return nil, newPathError("disk", "open", filePath, errIsDirectory)
 	}
 	return diskFile{file: file}, nil
 }
@@ -131,17 +182,38 @@ func (d DiskFS) Read(filePath string) (ReaderFile, error) {
 // not exist. Should the file already exist, this will overwrite its entire contents
 // so that it only contains what you write this time.
 func (d DiskFS) Write(filePath string) (WriterFile, error) {
-	fullPath := path.Join(d.basePath, filePath)
+	fullPath, err := resolvePath(d.basePath, filePath)
+	if err != nil {
+		return nil, newPathError("disk", "write", filePath, err)
+	}
 
 	// Ensure that the target directory actually exists.
-	err := os.MkdirAll(path.Dir(fullPath), os.FileMode(0755))
+	err = os.MkdirAll(path.Dir(fullPath), os.FileMode(0755))
 	if err != nil {
-		return nil, fmt.Errorf("disk fs error: mkdir: %w", err)
+		return nil, newPathError("disk", "write", filePath, err)
 	}
 
 	file, err := os.Create(fullPath)
 	if err != nil {
-		return nil, fmt.Errorf("disk fs error: %w", err)
+		return nil, newPathError("disk", "write", filePath, err)
+	}
+	return diskFile{file: file}, nil
+}
+
+// createExclusive opens a brand new file for writing just like Write(), but fails w/ fs.ErrExist when
+// something already exists at that path (O_EXCL), so two processes can never claim the same file.
+func (d DiskFS) createExclusive(filePath string) (WriterFile, error) {
+	fullPath, err := resolvePath(d.basePath, filePath)
+	if err != nil {
+		return nil, newPathError("disk", "write", filePath, err)
+	}
+	if err = os.MkdirAll(path.Dir(fullPath), os.FileMode(0755)); err != nil {
+		return nil, newPathError("disk", "write", filePath, err)
+	}
+
+	file, err := os.OpenFile(fullPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return nil, newPathError("disk", "write", filePath, err)
 	}
 	return diskFile{file: file}, nil
 }
@@ -150,26 +222,71 @@ func (d DiskFS) Write(filePath string) (WriterFile, error) {
 // all files and directories found in the target dirPath.
 //
 // You can optionally provide a set of filters to limit which files/directories
-// are included in the final set.
+// are included in the final set. Entries are only stat'd once they make it through
+// the filters, so name-only filters like WithExt(), WithPattern(), or WithName() let
+// you scan huge directories w/o paying for a system call per rejected entry.
 func (d DiskFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
-	entries, err := os.ReadDir(path.Join(d.basePath, dirPath))
+	entries, err := d.readDir(dirPath, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []FileInfo
+	for _, entry := range entries {
+		if err := entry.load(); err != nil {
+			return nil, newPathError("disk", "list files", dirPath, err)
+		}
+		results = append(results, entry)
+	}
+	return results, nil
+}
+
+// ListEntries is like List, but it never stat()s the entries in the directory unless one of
+// your filters needs more than the name/type of the file, or you call Info() on the entry.
+func (d DiskFS) ListEntries(dirPath string, filters ...FileFilter) ([]Entry, error) {
+	entries, err := d.readDir(dirPath, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Entry
+	for _, entry := range entries {
+		results = append(results, entry)
+	}
+	return results, nil
+}
+
+// readDir reads the directory's entries, returning the lazily-stat'd info for the ones that
+// make it through all of the filters.
+func (d DiskFS) readDir(dirPath string, filters []FileFilter) ([]*diskEntryInfo, error) {
+	fullPath, err := resolvePath(d.basePath, dirPath)
+	if err != nil {
+		return nil, newPathError("disk", "list files", dirPath, err)
+	}
+	entries, err := os.ReadDir(fullPath)
 	if os.IsNotExist(err) {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("disk fs error: list files: %s %w", dirPath, err)
+		return nil, newPathError("disk", "list files", dirPath, err)
 	}
 
-	var results []FileInfo
-	for _, entry := range entries {
-		file, err := entry.Info()
+	// Allocate the info for every entry in one shot rather than one at a time. The results
+	// just point into this slice.
+	infos := make([]diskEntryInfo, len(entries))
+	var results []*diskEntryInfo
+	for i, entry := range entries {
+		file := &infos[i]
+		file.entry = entry
+		file.dir = fullPath
+		file.listPath = dirPath
+		matches, err := fileMatchesFilters(file, filters)
 		if err != nil {
-			return nil, fmt.Errorf("disk fs error: list files: %s %w", dirPath, err)
+			return nil, newPathError("disk", "list files", dirPath, err)
 		}
-		if !fileMatchesFilters(file, filters) {
-			continue
+		if matches {
+			results = append(results, file)
 		}
-		results = append(results, file)
 	}
 	return results, nil
 }
@@ -179,15 +296,44 @@ func (d DiskFS) WorkingDirectory() string {
 	return path.Clean(d.basePath)
 }
 
+// AbsolutePath returns the absolute path of the file/directory on the local file system, which is what
+// you need to hand it to other programs or libraries. If the store's base path is relative (i.e. it
+// wasn't created using DiskAbs), it's resolved against the current working directory.
+func (d DiskFS) AbsolutePath(filePath string) (string, error) {
+	fullPath, err := resolvePath(d.basePath, filePath)
+	if err != nil {
+		return "", newPathError("disk", "absolute path", filePath, err)
+	}
+	absPath, err := filepath.Abs(filepath.FromSlash(fullPath))
+	if err != nil {
+		return "", newPathError("disk", "absolute path", filePath, err)
+	}
+	return absPath, nil
+}
+
 // ChangeDirectory returns a new FS that is rooted in the given subdirectory of this FS.
 func (d DiskFS) ChangeDirectory(dir string) FS {
-	return Disk(path.Join(d.basePath, dir))
+	return &DiskFS{basePath: joinPath(d.basePath, dir), ctx: d.ctx}
+}
+
+// withContext returns a copy of this store that carries the request's context; see ForRequest().
+func (d DiskFS) withContext(ctx context.Context) FS {
+	d.ctx = ctx
+	return &d
+}
+
+func (d DiskFS) requestContext() context.Context {
+	return contextOrBackground(d.ctx)
 }
 
 // Remove deletes the given file/directory and any of its children.
 func (d DiskFS) Remove(fileOrDirPath string) error {
-	if err := os.RemoveAll(path.Join(d.basePath, fileOrDirPath)); err != nil {
-		return fmt.Errorf("disk fs error: remove %s: %w", fileOrDirPath, err)
+	fullPath, err := resolvePath(d.basePath, fileOrDirPath)
+	if err != nil {
+		return newPathError("disk", "remove", fileOrDirPath, err)
+	}
+	if err = os.RemoveAll(fullPath); err != nil {
+		return newPathError("disk", "remove", fileOrDirPath, err)
 	}
 	return nil
 }
@@ -195,31 +341,273 @@ func (d DiskFS) Remove(fileOrDirPath string) error {
 // Move takes an existing file at the fromPath location and moves it to another
 // spot in this file system; the toPath location.
 func (d DiskFS) Move(fromPath string, toPath string) error {
-	fromPath = path.Join(d.basePath, fromPath)
-	toPath = path.Join(d.basePath, toPath)
+	fromFullPath, err := resolvePath(d.basePath, fromPath)
+	if err != nil {
+		return newPathError("disk", "move", fromPath, err)
+	}
+	toFullPath, err := resolvePath(d.basePath, toPath)
+	if err != nil {
+		return newPathError("disk", "move", toPath, err)
+	}
 
 	// Ensure the original file exists in the first place.
-	if _, err := os.Stat(fromPath); err != nil {
-		return fmt.Errorf("disk fs error: move: %v", err)
+	if _, err := os.Stat(fromFullPath); err != nil {
+		return newPathError("disk", "move", fromPath, err)
 	}
 	// Lazily create the directory where we will move the file to.
-	if err := os.MkdirAll(path.Dir(toPath), os.FileMode(0755)); err != nil {
-		return fmt.Errorf("disk fs error: move: %v", err)
+	if err := os.MkdirAll(path.Dir(toFullPath), os.FileMode(0755)); err != nil {
+		return newPathError("disk", "move", toPath, err)
 	}
 	// Move (the file), bitch. Get out the way!
-	if err := os.Rename(fromPath, toPath); err != nil {
-		return fmt.Errorf("disk fs error: move: %v", err)
+	if err := os.Rename(fromFullPath, toFullPath); err != nil {
+		return newPathError("disk", "move", fromPath, err)
 	}
 	return nil
 }
 
-func fileMatchesFilters(file FileInfo, filters []FileFilter) bool {
-	for _, filter := range filters {
-		if !filter(file) {
-			return false
+// WriteIfUnchanged opens the given file at the given path for writing, but only replaces the file when you
+// close it if its change token (see ChangeToken) still matches the given one. Until then, everything you
+// write goes to a temporary file in the same directory, so the original is never partially overwritten. An
+// empty token means that the file must not exist yet.
+//
+// The token is checked and the file replaced under a lock, so this is safe against concurrent writers in
+// the same process. Other processes can still slip in a change in the brief moment between the two.
+func (d DiskFS) WriteIfUnchanged(filePath string, token string) (WriterFile, error) {
+	fullPath, err := resolvePath(d.basePath, filePath)
+	if err != nil {
+		return nil, newPathError("disk", "write", filePath, err)
+	}
+	if err = diskCheckToken(fullPath, token); err != nil {
+		return nil, newPathError("disk", "write", filePath, err)
+	}
+
+	// Ensure that the target directory actually exists.
+	err = os.MkdirAll(path.Dir(fullPath), os.FileMode(0755))
+	if err != nil {
+		return nil, newPathError("disk", "write", filePath, err)
+	}
+
+	file, err := os.CreateTemp(path.Dir(fullPath), "."+path.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return nil, newPathError("disk", "write", filePath, err)
+	}
+	// Temp files are only accessible by their owner, but the file should keep its usual permissions.
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(fullPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	_ = file.Chmod(mode)
+	return &diskConditionalFile{diskFile: diskFile{file: file}, path: filePath, fullPath: fullPath, token: token}, nil
+}
+
+// MoveIfUnchanged takes an existing file at the fromPath location and moves it to the toPath location, but
+// only if the change token (see ChangeToken) of whatever is at toPath still matches the given one. An empty
+// token means that nothing may exist at toPath yet.
+func (d DiskFS) MoveIfUnchanged(fromPath string, toPath string, token string) error {
+	toFullPath, err := resolvePath(d.basePath, toPath)
+	if err != nil {
+		return newPathError("disk", "move", toPath, err)
+	}
+
+	diskConditionalMutex.Lock()
+	defer diskConditionalMutex.Unlock()
+	if err = diskCheckToken(toFullPath, token); err != nil {
+		return newPathError("disk", "move", toPath, err)
+	}
+	return d.Move(fromPath, toPath)
+}
+
+// Link creates newPath as a hard link to the existing file at existingPath, lazily creating
+// newPath's parent directory(s) if necessary.
+func (d DiskFS) Link(existingPath string, newPath string) error {
+	existingFullPath, err := resolvePath(d.basePath, existingPath)
+	if err != nil {
+		return newPathError("disk", "link", existingPath, err)
+	}
+	newFullPath, err := resolvePath(d.basePath, newPath)
+	if err != nil {
+		return newPathError("disk", "link", newPath, err)
+	}
+
+	if err := os.MkdirAll(path.Dir(newFullPath), os.FileMode(0755)); err != nil {
+		return newPathError("disk", "link", newPath, err)
+	}
+	if err := os.Link(existingFullPath, newFullPath); err != nil {
+		return newPathError("disk", "link", newPath, err)
+	}
+	return nil
+}
+
+// Ping verifies that the base directory of this store exists, is actually a directory, and that
+// we're able to write files to it.
+func (d DiskFS) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	dir := d.WorkingDirectory()
+	info, err := os.Stat(dir)
+	if err != nil {
+		return newPathError("disk", "ping", dir, err)
+	}
+	if !info.IsDir() {
+		return newPathError("disk", "ping", dir, ErrNotDirectory)
+	}
+
+	file, err := os.CreateTemp(dir, ".filestore-ping-*")
+	if err != nil {
+		return newPathError("disk", "ping", dir, fmt.Errorf("directory not writable: %w", err))
+	}
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+	return nil
+}
+
+// diskEntryInfo is the FileInfo/Entry for a single directory entry returned by List. The name and
+// type come for free from reading the directory, but everything else requires a stat() call, so we
+// defer that until someone actually asks for it.
+type diskEntryInfo struct {
+	entry    fs.DirEntry
+	dir      string
+	listPath string
+	info     FileInfo
+	err      error
+	loaded   bool
+}
+
+// load performs the stat() call for this entry, if we haven't done so already.
+func (e *diskEntryInfo) load() error {
+	if !e.loaded {
+		e.loaded = true
+		info, err := e.entry.Info()
+		if err != nil {
+			e.err = err
+			return err
 		}
+		e.info = diskFileInfo(info, path.Join(e.dir, e.entry.Name()))
 	}
-	return true
+	return e.err
+}
+
+func (e *diskEntryInfo) Name() string {
+	return e.entry.Name()
+}
+
+func (e *diskEntryInfo) IsDir() bool {
+	return e.entry.IsDir()
+}
+
+func (e *diskEntryInfo) Path() string {
+	return path.Join(e.listPath, e.entry.Name())
+}
+
+func (e *diskEntryInfo) Type() fs.FileMode {
+	return e.entry.Type()
+}
+
+func (e *diskEntryInfo) Info() (FileInfo, error) {
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *diskEntryInfo) Size() int64 {
+	if e.load() != nil {
+		return 0
+	}
+	return e.info.Size()
+}
+
+func (e *diskEntryInfo) Mode() fs.FileMode {
+	if e.load() != nil {
+		return e.entry.Type()
+	}
+	return e.info.Mode()
+}
+
+func (e *diskEntryInfo) ModTime() time.Time {
+	if e.load() != nil {
+		return time.Time{}
+	}
+	return e.info.ModTime()
+}
+
+func (e *diskEntryInfo) Sys() any {
+	if e.load() != nil {
+		return nil
+	}
+	return e.info.Sys()
+}
+
+// CreationTime defers to the platform-specific lookup for the underlying stat info.
+func (e *diskEntryInfo) CreationTime() (time.Time, bool) {
+	if e.load() != nil {
+		return time.Time{}, false
+	}
+	return CreationTime(e.info)
+}
+
+// diskConditionalMutex makes checking a file's change token and replacing it a single step, at least as far
+// as the other conditional writes in this process are concerned.
+var diskConditionalMutex sync.Mutex
+
+// diskCheckToken fails w/ ErrPreconditionFailed when the change token of the file at the full path doesn't
+// match the expected one.
+func diskCheckToken(fullPath string, token string) error {
+	var current FileInfo
+	info, err := os.Stat(fullPath)
+	switch {
+	case err == nil:
+		current = info
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	if ChangeToken(current) != token {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// diskConditionalFile is a temporary file that replaces the real one when it's closed, provided that the
+// real one's change token still matches.
+type diskConditionalFile struct {
+	diskFile
+	path     string
+	fullPath string
+	token    string
+	closed   bool
+}
+
+// Close replaces the original file w/ everything you wrote, unless someone else changed the original file
+// in the meantime, in which case it fails w/ ErrPreconditionFailed and your changes are discarded.
+func (f *diskConditionalFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	tempPath := f.file.Name()
+	if err := f.diskFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return newPathError("disk", "write", f.path, err)
+	}
+
+	diskConditionalMutex.Lock()
+	defer diskConditionalMutex.Unlock()
+	if err := diskCheckToken(f.fullPath, f.token); err != nil {
+		_ = os.Remove(tempPath)
+		return newPathError("disk", "write", f.path, err)
+	}
+	if err := os.Rename(tempPath, f.fullPath); err != nil {
+		_ = os.Remove(tempPath)
+		return newPathError("disk", "write", f.path, err)
+	}
+	return nil
 }
 
 var _ FS = DiskFS{}
+var _ Linker = DiskFS{}
+var _ EntryLister = DiskFS{}
+var _ ConditionalWriter = DiskFS{}
+var _ requestBinder = DiskFS{}
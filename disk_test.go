@@ -1,10 +1,13 @@
 package filestore_test
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/monadicstack/filestore"
 	"github.com/stretchr/testify/suite"
@@ -61,6 +64,27 @@ func (s *DiskTestSuite) TestStat() {
 	s.Require().Error(err, "Running 'stat' on non-existent file should give an error")
 }
 
+func (s *DiskTestSuite) TestStat_creationTime() {
+	fs := filestore.Disk(s.tempDirPath)
+
+	info, err := fs.Stat("1.lebowski")
+	s.Require().NoError(err)
+
+	created, ok := filestore.CreationTime(info)
+	if !ok {
+		s.T().Skip("Underlying file system does not record file creation times")
+	}
+	s.Require().WithinDuration(time.Now(), created, time.Minute, "Freshly created file should have recent creation time")
+
+	files, err := fs.List(".", filestore.WithCreatedAfter(time.Now().Add(-time.Minute)))
+	s.Require().NoError(err)
+	s.Require().Equal(6, len(files), "All freshly created files should be included.")
+
+	files, err = fs.List(".", filestore.WithCreatedBefore(time.Now().Add(-time.Minute)))
+	s.Require().NoError(err)
+	s.Require().Equal(0, len(files), "No freshly created files should be included.")
+}
+
 func (s *DiskTestSuite) TestWorkingDirectory() {
 	var fs filestore.FS
 
@@ -142,6 +166,31 @@ func (s *DiskTestSuite) TestList_withFilters() {
 	s.assertFile(files[1], "baz.log")
 }
 
+func (s *DiskTestSuite) TestList_nameOnlyFilters() {
+	fs := filestore.Disk(s.tempDirPath)
+
+	files, err := fs.List(".", filestore.WithName(func(name string) bool { return name < "3" }))
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(files))
+	s.assertFile(files[0], "1.lebowski")
+	s.assertFile(files[1], "2.lebowski")
+
+	// The infos are stat'd lazily, but everything we return should still be fully populated.
+	info, err := fs.Stat("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(info.Size(), files[0].Size())
+	s.Require().Equal(info.Mode(), files[0].Mode())
+	s.Require().Equal(info.ModTime(), files[0].ModTime())
+	s.Require().NotNil(files[0].Sys())
+
+	// Filters that need the full stat info should still work.
+	files, err = fs.List(".", filestore.FileFilter(func(info filestore.FileInfo) bool { return info.Mode().IsDir() }))
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(files))
+	s.assertDir(files[0], "dude")
+	s.assertDir(files[1], "duderino")
+}
+
 // Removing a non-existent file should quietly do nothing.
 func (s *DiskTestSuite) TestRemove_nonExistent() {
 	err := filestore.Disk(s.tempDirPath).Remove("asldfjslkdfjasdf")
@@ -394,6 +443,20 @@ func (s *DiskTestSuite) TestWrite() {
 
 // Yes, our FS has a List() method, but this uses raw os.ReadDir() so that you can compare
 // directory contents without relying on potentially broken implementations in our FS.
+func (s *DiskTestSuite) TestLink() {
+	fs := filestore.Disk(s.tempDirPath)
+
+	s.Require().NoError(fs.Link("1.lebowski", "links/jeff.lebowski"), "Linking should create parent directories")
+	s.Require().Equal("jeff", s.read(s.tempDirPath, "links", "jeff.lebowski"))
+
+	original, _ := os.Stat(path.Join(s.tempDirPath, "1.lebowski"))
+	link, _ := os.Stat(path.Join(s.tempDirPath, "links", "jeff.lebowski"))
+	s.Require().True(os.SameFile(original, link))
+
+	s.Require().Error(fs.Link("1.lebowski", "2.lebowski"), "Linking over an existing file should fail")
+	s.Require().Error(fs.Link("nope.lebowski", "new.lebowski"), "Linking a non-existent file should fail")
+}
+
 func (s *DiskTestSuite) ls(directorySegments ...string) []filestore.FileInfo {
 	entries, _ := os.ReadDir(path.Join(directorySegments...))
 
@@ -414,3 +477,75 @@ func (s *DiskTestSuite) toString(file filestore.ReaderFile) string {
 	data, _ := io.ReadAll(file)
 	return string(data)
 }
+
+// Paths that no file system can represent should be rejected consistently by every operation.
+func (s *DiskTestSuite) TestInvalidPaths() {
+	fs := filestore.Disk(s.tempDirPath)
+	invalid := "bad\x00.lebowski"
+
+	_, err := fs.Stat(invalid)
+	s.Require().Error(err)
+	s.Require().False(fs.Exists(invalid))
+	_, err = fs.Read(invalid)
+	s.Require().Error(err)
+	_, err = fs.Write(invalid)
+	s.Require().Error(err)
+	_, err = fs.List(invalid)
+	s.Require().Error(err)
+	s.Require().Error(fs.Remove(invalid))
+	s.Require().Error(fs.Move(invalid, "1.lebowski"))
+	s.Require().Error(fs.Move("1.lebowski", invalid))
+	s.Require().False(fs.ChangeDirectory(invalid).Exists("."))
+}
+
+// Scanning a big directory for a handful of files should not stat() every entry. Compare the allocations
+// and time of the name-only filter against the one that needs each file's size.
+func BenchmarkDiskFS_List(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 1000; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%04d.log", i)), nil, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	fs := filestore.Disk(dir)
+
+	b.Run("name-only", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = fs.List(".", filestore.WithPattern("000*"))
+		}
+	})
+	b.Run("stat", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = fs.List(".", func(info filestore.FileInfo) bool { return info.Size() > 0 })
+		}
+	})
+}
+
+func (s *DiskTestSuite) TestDiskAbs() {
+	cwd, err := os.Getwd()
+	s.Require().NoError(err)
+	defer func() { _ = os.Chdir(cwd) }()
+
+	relative := filestore.Disk(s.tempDirPath)
+	pinned, err := filestore.DiskAbs(s.tempDirPath)
+	s.Require().NoError(err)
+	expected := filepath.Join(cwd, s.tempDirPath)
+	s.Require().Equal(filepath.ToSlash(expected), pinned.WorkingDirectory())
+
+	absPath, err := relative.AbsolutePath("duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(filepath.Join(expected, "duderino", "5.lebowski"), absPath)
+	absPath, err = pinned.AbsolutePath("duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(filepath.Join(expected, "duderino", "5.lebowski"), absPath)
+
+	// Moving somewhere else shouldn't change which files the pinned store sees.
+	s.Require().NoError(os.Chdir(os.TempDir()))
+	s.Require().True(pinned.Exists("1.lebowski"))
+	s.Require().False(relative.Exists("1.lebowski"))
+
+	_, err = pinned.AbsolutePath("bad\x00.lebowski")
+	s.Require().Error(err)
+}
@@ -0,0 +1,33 @@
+package filestore
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// FromEmbed presents the files you've embedded in your binary using "go:embed" as a read-only file
+// store, so you can hand templates, seed data, and the like to code that only accepts an FS. The root
+// is the embedded directory that becomes the root of the store (e.g. "templates"); use "." or "" to
+// expose everything. Paths can't escape the root, and Write(), Remove(), and Move() fail with ErrReadOnly.
+//
+// Embedded files don't have modification times, so ModTime() is always the zero time.
+//
+// Example:
+//
+//	//go:embed seed
+//	var seedFiles embed.FS
+//
+//	func Seed(dst filestore.FS) error {
+//	    return filestore.CopyAll(filestore.FromEmbed(seedFiles, "seed"), ".", dst, ".")
+//	}
+func FromEmbed(files embed.FS, root string) FS {
+	// Cleaning the root as an absolute path guarantees that it's a valid fs.FS name, so Sub() can't fail.
+	root = strings.TrimPrefix(path.Clean("/"+root), "/")
+	if root == "" {
+		root = "."
+	}
+	sub, _ := fs.Sub(files, root)
+	return &ioFS{fsys: sub, basePath: "/", kind: "embed"}
+}
@@ -0,0 +1,89 @@
+package filestore_test
+
+import (
+	"embed"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+//go:embed testdata
+var embeddedTestData embed.FS
+
+type EmbedTestSuite struct {
+	suite.Suite
+	fs filestore.FS
+}
+
+func TestEmbedTestSuite(t *testing.T) {
+	suite.Run(t, &EmbedTestSuite{})
+}
+
+func (s *EmbedTestSuite) SetupTest() {
+	s.fs = filestore.FromEmbed(embeddedTestData, "testdata")
+}
+
+func (s *EmbedTestSuite) TestStat() {
+	info, err := s.fs.Stat("hello.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("hello.txt", info.Name())
+	s.Require().Equal(int64(12), info.Size())
+	s.Require().False(info.IsDir())
+
+	info, err = s.fs.Stat("inner1/inner2")
+	s.Require().NoError(err)
+	s.Require().True(info.IsDir())
+
+	_, err = s.fs.Stat("nope.txt")
+	s.Require().Error(err)
+	s.Require().True(s.fs.Exists("inner1/foo.txt"))
+	s.Require().False(s.fs.Exists("testdata/hello.txt"), "Paths should be relative to the root")
+	s.Require().False(s.fs.Exists("../embed_test.go"), "Should not be able to escape the root")
+}
+
+func (s *EmbedTestSuite) TestRead() {
+	content, err := readString(s.fs, "hello.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("Hello World\n", content)
+
+	file, err := s.fs.Read("hello.txt")
+	s.Require().NoError(err)
+	defer file.Close()
+	_, err = file.Seek(6, io.SeekStart)
+	s.Require().NoError(err)
+	data, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal("World\n", string(data))
+
+	_, err = s.fs.Read("inner1")
+	s.Require().Error(err, "Should not read directories like files")
+}
+
+func (s *EmbedTestSuite) TestList() {
+	infos, err := s.fs.List("inner1/inner2", filestore.WithExt("txt"))
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(infos))
+	s.Require().Equal("bar.txt", infos[0].Name())
+
+	inner := s.fs.ChangeDirectory("inner1")
+	infos, err = inner.List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(infos))
+	s.Require().Equal("foo.txt", infos[0].Name())
+	s.Require().Equal("inner2", infos[1].Name())
+
+	infos, err = filestore.FromEmbed(embeddedTestData, "/").List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(infos))
+	s.Require().Equal("testdata", infos[0].Name())
+}
+
+func (s *EmbedTestSuite) TestReadOnly() {
+	_, err := s.fs.Write("hello.txt")
+	s.Require().True(errors.Is(err, filestore.ErrReadOnly))
+	s.Require().True(errors.Is(s.fs.Remove("hello.txt"), filestore.ErrReadOnly))
+	s.Require().True(errors.Is(s.fs.Move("hello.txt", "goodbye.txt"), filestore.ErrReadOnly))
+}
@@ -0,0 +1,530 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// The layout of the files that Encrypted() writes. Each file starts w/ a header containing a magic number,
+// the size of each chunk, and a random salt unique to that file, which we use to derive the file's own key
+// from yours. After that comes each chunk of the plaintext sealed w/ AES-GCM; every chunk is
+// encryptedChunkSize bytes except (possibly) the last.
+const (
+	encryptedMagic      = "fse\x02"
+	encryptedChunkSize  = 64 * 1024
+	encryptedSaltSize   = 32
+	encryptedTagSize    = 16
+	encryptedHeaderSize = len(encryptedMagic) + 4 + encryptedSaltSize
+)
+
+// encryptedKeyInfo is the HKDF "info" that ties the keys we derive to this file format.
+const encryptedKeyInfo = "filestore encrypted file v2"
+
+// errEncryptedSeek is returned when you try to write anywhere other than the end of an encrypted file.
+var errEncryptedSeek = errors.New("encrypted files can only be written sequentially")
+
+// Encrypted wraps a file store so that every file is encrypted using AES-GCM as you Write() it and decrypted
+// as you Read() it. The key must be 16, 24, or 32 bytes to use AES-128, AES-192, or AES-256; any other key
+// fails when you read/write a file. Like Compressed(), paths are left alone, so only the contents of your
+// files are protected, not their names.
+//
+// Each file is sealed w/ its own key, derived (via HKDF-SHA256) from your key and a random salt stored in
+// the file's header, so you can encrypt as many files as you like w/ the same key. Files are also bound to
+// their paths (relative to the store you passed in), so nobody can swap the contents of two files w/o us
+// noticing. That's why Move() has to decrypt and re-encrypt every file it moves rather than just renaming.
+//
+// Files are encrypted in 64KB chunks that are each authenticated separately, so readers support Seek() and
+// ReadAt() w/o decrypting the whole file, and any tampering (including truncating the file) results in an
+// error rather than garbage. Writers, however, only support writing sequentially. Stat() and List() report
+// the size of the decrypted contents, and that's also the size that List() filters see.
+//
+// Example:
+//
+//	key, err := hex.DecodeString(os.Getenv("FILES_KEY"))
+//	...
+//	files := filestore.Encrypted(filestore.Disk("/var/data"), key)
+//	output, err := files.Write("patients/1234/chart.pdf")
+func Encrypted(fs FS, key []byte) FS {
+	_, err := aes.NewCipher(key)
+	return &encryptedFS{FS: fs, key: append([]byte(nil), key...), root: fs.WorkingDirectory(), err: err}
+}
+
+func init() {
+	RegisterLayer("encrypted", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			// Key is base64 encoded, which is how encoding/json decodes a []byte.
+			Key []byte `json:"key"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		switch len(settings.Key) {
+		case 16, 24, 32:
+			return Encrypted(fs, settings.Key), nil
+		default:
+			return nil, fmt.Errorf("encrypted: key must be 16, 24, or 32 bytes")
+		}
+	})
+}
+
+type encryptedFS struct {
+	FS
+	key []byte
+	// root is the working directory of the store passed to Encrypted(). Files are bound to their path relative
+	// to it, so they still decrypt after you ChangeDirectory().
+	root string
+	err  error
+}
+
+// Stat fetches metadata about the file, reporting the size of its decrypted contents.
+func (e *encryptedFS) Stat(filePath string) (FileInfo, error) {
+	info, err := e.FS.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return e.decryptedInfo(info), nil
+}
+
+// List performs the equivalent of the "ls" command, reporting the size of each file's decrypted contents.
+// Your filters see those sizes, too.
+func (e *encryptedFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	return listDescribed(e.FS, dirPath, filters, e.decryptedInfo)
+}
+
+func (e *encryptedFS) decryptedInfo(info FileInfo) FileInfo {
+	if info.IsDir() {
+		return info
+	}
+	size, _ := decryptedSize(info.Size())
+	return encryptedFileInfo{FileInfo: info, size: size}
+}
+
+// Read opens the file and decrypts its contents as you read them.
+func (e *encryptedFS) Read(filePath string) (ReaderFile, error) {
+	if e.err != nil {
+		return nil, fmt.Errorf("filestore: encrypted: read %s: %w", filePath, e.err)
+	}
+	file, err := e.FS.Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newEncryptedReaderFile(file, e.key, e.boundPath(filePath))
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("filestore: encrypted: read %s: %w", filePath, err)
+	}
+	return reader, nil
+}
+
+// Write opens the file and encrypts everything you write to it.
+func (e *encryptedFS) Write(filePath string) (WriterFile, error) {
+	if e.err != nil {
+		return nil, fmt.Errorf("filestore: encrypted: write %s: %w", filePath, e.err)
+	}
+	salt := make([]byte, encryptedSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("filestore: encrypted: write %s: %w", filePath, err)
+	}
+	aead, err := encryptedAEAD(e.key, salt)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: encrypted: write %s: %w", filePath, err)
+	}
+
+	file, err := e.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, encryptedHeaderSize)
+	header = append(header, encryptedMagic...)
+	header = binary.BigEndian.AppendUint32(header, encryptedChunkSize)
+	header = append(header, salt...)
+	if _, err = file.Write(header); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("filestore: encrypted: write %s: %w", filePath, err)
+	}
+	return &encryptedWriterFile{file: file, aead: aead, path: e.boundPath(filePath)}, nil
+}
+
+// Move relocates the file/directory. Since every file is bound to its path, we can't just move the encrypted
+// bytes; we decrypt each file and encrypt it again at its new path, removing the originals once they've all
+// been copied.
+func (e *encryptedFS) Move(fromPath string, toPath string) error {
+	if bytes.Equal(e.boundPath(fromPath), e.boundPath(toPath)) {
+		return e.FS.Move(fromPath, toPath)
+	}
+	info, err := e.FS.Stat(fromPath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		err = e.reencrypt(fromPath, toPath)
+	} else {
+		err = Walk(e.FS, fromPath, func(walkPath string, info FileInfo, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case info.IsDir():
+				return nil
+			default:
+				return e.reencrypt(walkPath, path.Join(toPath, relativeTo(fromPath, walkPath)))
+			}
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("filestore: encrypted: move %s: %w", fromPath, err)
+	}
+	return e.FS.Remove(fromPath)
+}
+
+// reencrypt decrypts the file and encrypts it again at its new path, cleaning up after itself if that fails.
+func (e *encryptedFS) reencrypt(fromPath string, toPath string) error {
+	input, err := e.Read(fromPath)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := e.Write(toPath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(output, input); err != nil {
+		_ = output.Close()
+		_ = e.FS.Remove(toPath)
+		return err
+	}
+	if err = output.Close(); err != nil {
+		_ = e.FS.Remove(toPath)
+		return err
+	}
+	return nil
+}
+
+// boundPath is the path that we bind the file's contents to: its path relative to the store passed to Encrypted().
+func (e *encryptedFS) boundPath(filePath string) []byte {
+	return []byte(relativeTo(e.root, joinPath(e.FS.WorkingDirectory(), filePath)))
+}
+
+func (e *encryptedFS) ChangeDirectory(dir string) FS {
+	return &encryptedFS{FS: e.FS.ChangeDirectory(dir), key: e.key, root: e.root, err: e.err}
+}
+
+func (e *encryptedFS) withContext(ctx context.Context) FS {
+	return &encryptedFS{FS: ForRequest(e.FS, ctx), key: e.key, root: e.root, err: e.err}
+}
+
+func (e *encryptedFS) requestContext() context.Context {
+	return RequestContext(e.FS)
+}
+
+func (e *encryptedFS) wrapped() []FS {
+	return []FS{e.FS}
+}
+
+// encryptedAEAD derives the key for the file w/ the given salt from the master key and sets up AES-GCM w/ it.
+func encryptedAEAD(key []byte, salt []byte) (cipher.AEAD, error) {
+	fileKey := make([]byte, len(key))
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte(encryptedKeyInfo)), fileKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptedNonce builds the nonce for the given chunk. Every file has its own key, so the chunk's index is
+// unique enough, and including whether or not it's the last one keeps anyone from reordering or dropping
+// chunks w/o us noticing. The remaining 7 bytes of the 12 byte nonce are always zero.
+func encryptedNonce(index int64, last bool) []byte {
+	nonce := make([]byte, 7, 12)
+	nonce = binary.BigEndian.AppendUint32(nonce, uint32(index))
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// decryptedSize calculates the size of a file's plaintext based on the size of the encrypted file.
+func decryptedSize(encryptedSize int64) (int64, bool) {
+	body := encryptedSize - int64(encryptedHeaderSize)
+	sealedChunkSize := int64(encryptedChunkSize + encryptedTagSize)
+	if body < encryptedTagSize {
+		return 0, false
+	}
+	chunks := (body + sealedChunkSize - 1) / sealedChunkSize
+	if body-(chunks-1)*sealedChunkSize < encryptedTagSize {
+		return 0, false
+	}
+	return body - chunks*encryptedTagSize, true
+}
+
+// encryptedFileInfo reports the size of the decrypted file rather than the one in the underlying store.
+type encryptedFileInfo struct {
+	FileInfo
+	size int64
+}
+
+func (info encryptedFileInfo) Size() int64 {
+	return info.size
+}
+
+// encryptedWriterFile buffers each chunk of plaintext, sealing it and writing it to the underlying file once
+// it's full. We always hold on to the last chunk until Close() since we need to mark it as the last one.
+type encryptedWriterFile struct {
+	mutex  sync.Mutex
+	file   WriterFile
+	aead   cipher.AEAD
+	path   []byte
+	buffer []byte
+	chunk  int64
+	offset int64
+	closed bool
+}
+
+// Write appends len(b) bytes to the file, encrypting each chunk once it's full.
+func (w *encryptedWriterFile) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.write(p)
+}
+
+func (w *encryptedWriterFile) write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("filestore: encrypted: write: file already closed")
+	}
+	w.buffer = append(w.buffer, p...)
+	w.offset += int64(len(p))
+	for len(w.buffer) > encryptedChunkSize {
+		if err := w.flush(w.buffer[:encryptedChunkSize], false); err != nil {
+			return 0, err
+		}
+		w.buffer = append(w.buffer[:0], w.buffer[encryptedChunkSize:]...)
+	}
+	return len(p), nil
+}
+
+func (w *encryptedWriterFile) flush(chunk []byte, last bool) error {
+	sealed := w.aead.Seal(nil, encryptedNonce(w.chunk, last), chunk, w.path)
+	if _, err := w.file.Write(sealed); err != nil {
+		return fmt.Errorf("filestore: encrypted: write: %w", err)
+	}
+	w.chunk++
+	return nil
+}
+
+// WriteAt writes len(b) bytes to the file, but only if off is the current end of the file.
+func (w *encryptedWriterFile) WriteAt(p []byte, off int64) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if off != w.offset {
+		return 0, fmt.Errorf("filestore: encrypted: write at: %w", errEncryptedSeek)
+	}
+	return w.write(p)
+}
+
+// Seek reports the current offset; you can't actually move to any other offset.
+func (w *encryptedWriterFile) Seek(offset int64, whence int) (int64, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	position := offset
+	switch whence {
+	case io.SeekCurrent, io.SeekEnd:
+		position = w.offset + offset
+	}
+	if position != w.offset {
+		return 0, fmt.Errorf("filestore: encrypted: seek: %w", errEncryptedSeek)
+	}
+	return position, nil
+}
+
+// Close encrypts the last chunk and closes the underlying file.
+func (w *encryptedWriterFile) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	flushErr := w.flush(w.buffer, true)
+	fileErr := w.file.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return fileErr
+}
+
+// encryptedReaderFile decrypts the chunks of the underlying file as you read them. Since each chunk is
+// sealed separately, we can jump straight to any offset.
+type encryptedReaderFile struct {
+	mutex sync.Mutex
+	file  ReaderFile
+	aead  cipher.AEAD
+	path  []byte
+	// encryptedSize is the size of the underlying file while size is the size of the decrypted contents.
+	encryptedSize int64
+	size          int64
+	offset        int64
+	// chunk is the most recently decrypted chunk, so reading it a little at a time doesn't decrypt it over
+	// and over again.
+	chunk      []byte
+	chunkIndex int64
+}
+
+func newEncryptedReaderFile(file ReaderFile, key []byte, filePath []byte) (*encryptedReaderFile, error) {
+	header := make([]byte, encryptedHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !bytes.HasPrefix(header, []byte(encryptedMagic)) {
+		return nil, fmt.Errorf("not an encrypted file")
+	}
+	if chunkSize := binary.BigEndian.Uint32(header[len(encryptedMagic):]); chunkSize != encryptedChunkSize {
+		return nil, fmt.Errorf("unsupported chunk size: %d", chunkSize)
+	}
+
+	encryptedSize, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	size, ok := decryptedSize(encryptedSize)
+	if !ok {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	aead, err := encryptedAEAD(key, header[len(encryptedMagic)+4:])
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedReaderFile{
+		file:          file,
+		aead:          aead,
+		path:          filePath,
+		encryptedSize: encryptedSize,
+		size:          size,
+		chunkIndex:    -1,
+	}, nil
+}
+
+// Read decrypts up to len(p) bytes from the current offset.
+func (r *encryptedReaderFile) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	n, err := r.readAt(p, r.offset)
+	r.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt decrypts len(p) bytes starting at byte offset off, leaving the current offset alone.
+func (r *encryptedReaderFile) ReadAt(p []byte, off int64) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if off < 0 {
+		return 0, fmt.Errorf("filestore: encrypted: read at: negative offset")
+	}
+	return r.readAt(p, off)
+}
+
+func (r *encryptedReaderFile) readAt(p []byte, off int64) (int, error) {
+	if r.file == nil {
+		return 0, fmt.Errorf("filestore: encrypted: read: file already closed")
+	}
+	n := 0
+	for n < len(p) {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		index := off / encryptedChunkSize
+		if err := r.decrypt(index); err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], r.chunk[off-index*encryptedChunkSize:])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+// decrypt reads and decrypts the chunk w/ the given index unless it's the one we decrypted most recently.
+func (r *encryptedReaderFile) decrypt(index int64) error {
+	if index == r.chunkIndex {
+		return nil
+	}
+
+	sealedChunkSize := int64(encryptedChunkSize + encryptedTagSize)
+	start := int64(encryptedHeaderSize) + index*sealedChunkSize
+	end := start + sealedChunkSize
+	if end > r.encryptedSize {
+		end = r.encryptedSize
+	}
+	sealed := make([]byte, end-start)
+	if n, err := r.file.ReadAt(sealed, start); n < len(sealed) {
+		return fmt.Errorf("filestore: encrypted: read: %w", err)
+	}
+
+	last := end == r.encryptedSize
+	chunk, err := r.aead.Open(sealed[:0], encryptedNonce(index, last), sealed, r.path)
+	if err != nil {
+		return fmt.Errorf("filestore: encrypted: read: chunk %d: %w", index, err)
+	}
+	r.chunk, r.chunkIndex = chunk, index
+	return nil
+}
+
+// Seek moves to the given offset of the decrypted data.
+func (r *encryptedReaderFile) Seek(offset int64, whence int) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("filestore: encrypted: seek: invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("filestore: encrypted: seek: negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close closes the underlying file.
+func (r *encryptedReaderFile) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file, r.chunk = nil, nil
+	return err
+}
+
+var _ requestBinder = &encryptedFS{}
+var _ wrapper = &encryptedFS{}
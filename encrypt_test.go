@@ -0,0 +1,221 @@
+package filestore_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type EncryptedTestSuite struct {
+	suite.Suite
+	key   []byte
+	inner filestore.FS
+	fs    filestore.FS
+}
+
+func TestEncryptedTestSuite(t *testing.T) {
+	suite.Run(t, &EncryptedTestSuite{})
+}
+
+func (s *EncryptedTestSuite) SetupTest() {
+	s.key = []byte("0123456789abcdef0123456789abcdef")
+	s.inner = filestore.Memory()
+	s.fs = filestore.Encrypted(s.inner, s.key)
+}
+
+func (s *EncryptedTestSuite) TestWrite() {
+	s.Require().NoError(writeString(s.fs, "secrets/dude.txt", "the dude abides"))
+	s.Require().Equal("the dude abides", s.read("secrets/dude.txt"))
+
+	stored, err := readString(s.inner, "secrets/dude.txt")
+	s.Require().NoError(err)
+	s.Require().NotContains(stored, "dude")
+
+	info, err := s.fs.Stat("secrets/dude.txt")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(15), info.Size(), "Should report the decrypted size")
+	infos, err := s.fs.List("secrets")
+	s.Require().NoError(err)
+	s.Require().Len(infos, 1)
+	s.Require().Equal(int64(15), infos[0].Size())
+
+	// Same contents, different ciphertext (and a different salt for each file's key).
+	s.Require().NoError(writeString(s.fs, "secrets/walter.txt", "the dude abides"))
+	other, _ := readString(s.inner, "secrets/walter.txt")
+	s.Require().NotEqual(stored, other)
+	s.Require().NotEqual(stored[8:40], other[8:40])
+
+	s.Require().NoError(writeString(s.fs, "empty.txt", ""))
+	s.Require().Equal("", s.read("empty.txt"))
+}
+
+func (s *EncryptedTestSuite) TestWrite_sequential() {
+	file, err := s.fs.Write("app.log")
+	s.Require().NoError(err)
+	_, err = file.Write([]byte("abc"))
+	s.Require().NoError(err)
+	_, err = file.WriteAt([]byte("def"), 3)
+	s.Require().NoError(err, "Writing at the current offset should be fine")
+	_, err = file.Seek(0, io.SeekStart)
+	s.Require().Error(err, "Should not be able to go back")
+	_, err = file.WriteAt([]byte("nope"), 1)
+	s.Require().Error(err)
+	s.Require().NoError(file.Close())
+
+	s.Require().Equal("abcdef", s.read("app.log"))
+}
+
+func (s *EncryptedTestSuite) TestRead_seek() {
+	// Big enough to span a few chunks, w/ each chunk being easy to recognize.
+	content := strings.Repeat("a", 64*1024) + strings.Repeat("b", 64*1024) + "the dude abides"
+	s.Require().NoError(writeString(s.fs, "big.txt", content))
+	s.Require().Equal(content, s.read("big.txt"))
+
+	info, err := s.fs.Stat("big.txt")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(len(content)), info.Size())
+
+	file, err := s.fs.Read("big.txt")
+	s.Require().NoError(err)
+	defer file.Close()
+
+	buffer := make([]byte, 4)
+	n, err := file.ReadAt(buffer, 64*1024-2)
+	s.Require().NoError(err)
+	s.Require().Equal("aabb", string(buffer[:n]), "Should read across chunks")
+
+	position, err := file.Seek(-6, io.SeekEnd)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(len(content)-6), position)
+	rest, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal("abides", string(rest))
+
+	_, err = file.Seek(4, io.SeekStart)
+	s.Require().NoError(err)
+	_, err = io.ReadFull(file, buffer)
+	s.Require().NoError(err)
+	s.Require().Equal("aaaa", string(buffer))
+
+	n, err = file.ReadAt(buffer, int64(len(content)-2))
+	s.Require().ErrorIs(err, io.EOF)
+	s.Require().Equal("es", string(buffer[:n]))
+}
+
+func (s *EncryptedTestSuite) TestRead_tampered() {
+	content := strings.Repeat("the dude abides. ", 10000)
+	s.Require().NoError(writeString(s.fs, "dude.txt", content))
+	stored, err := readString(s.inner, "dude.txt")
+	s.Require().NoError(err)
+
+	// Flipping a single bit should fail.
+	tampered := []byte(stored)
+	tampered[len(tampered)-100] ^= 1
+	s.Require().NoError(writeString(s.inner, "dude.txt", string(tampered)))
+	_, err = readString(s.fs, "dude.txt")
+	s.Require().Error(err)
+
+	// So should chopping off the last chunk.
+	chunk := 64*1024 + 16
+	truncated := stored[:len(stored)-(len(stored)-40)%chunk]
+	s.Require().NoError(writeString(s.inner, "dude.txt", truncated))
+	_, err = readString(s.fs, "dude.txt")
+	s.Require().Error(err)
+
+	// Or using the wrong key.
+	s.Require().NoError(writeString(s.inner, "dude.txt", stored))
+	_, err = readString(filestore.Encrypted(s.inner, bytes.Repeat([]byte("x"), 32)), "dude.txt")
+	s.Require().Error(err)
+
+	// Or swapping the contents of two files.
+	s.Require().NoError(writeString(s.fs, "walter.txt", "shomer shabbos"))
+	s.Require().NoError(writeString(s.inner, "walter.txt", stored))
+	_, err = readString(s.fs, "walter.txt")
+	s.Require().Error(err)
+
+	s.Require().NoError(writeString(s.inner, "plain.txt", "not encrypted"))
+	_, err = s.fs.Read("plain.txt")
+	s.Require().Error(err)
+	_, err = s.fs.Read("missing.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+}
+
+func (s *EncryptedTestSuite) TestInvalidKey() {
+	fs := filestore.Encrypted(s.inner, []byte("too short"))
+	s.Require().Error(writeString(fs, "dude.txt", "the dude abides"))
+	_, err := fs.Read("dude.txt")
+	s.Require().Error(err)
+}
+
+func (s *EncryptedTestSuite) TestChangeDirectory() {
+	secrets := s.fs.ChangeDirectory("secrets")
+	s.Require().NoError(writeString(secrets, "dude.txt", "the dude abides"))
+	s.Require().Equal("the dude abides", s.read("secrets/dude.txt"))
+}
+
+func (s *EncryptedTestSuite) TestMove() {
+	content := strings.Repeat("the dude abides. ", 10000)
+	s.Require().NoError(writeString(s.fs, "dude.txt", content))
+	s.Require().NoError(writeString(s.fs, "docs/a.txt", "a"))
+	s.Require().NoError(writeString(s.fs, "docs/nested/b.txt", "b"))
+
+	s.Require().NoError(s.fs.Move("dude.txt", "lebowski.txt"))
+	s.Require().Equal(content, s.read("lebowski.txt"))
+	s.Require().False(s.inner.Exists("dude.txt"))
+
+	s.Require().NoError(s.fs.ChangeDirectory("docs").Move("nested", "moved"))
+	s.Require().Equal("b", s.read("docs/moved/b.txt"))
+	s.Require().NoError(s.fs.Move("docs", "archive"))
+	s.Require().Equal("a", s.read("archive/a.txt"))
+	s.Require().Equal("b", s.read("archive/moved/b.txt"))
+	s.Require().False(s.inner.Exists("docs"))
+
+	s.Require().Error(s.fs.Move("missing.txt", "nope.txt"))
+}
+
+func (s *EncryptedTestSuite) TestList_filters() {
+	s.Require().NoError(writeString(s.fs, "docs/small.txt", "abide"))
+	s.Require().NoError(writeString(s.fs, "docs/big.txt", strings.Repeat("abide", 10)))
+
+	bigFiles := func(info filestore.FileInfo) bool { return info.Size() > 20 }
+	infos, err := s.fs.List("docs", bigFiles)
+	s.Require().NoError(err)
+	s.Require().Len(infos, 1, "Filters should see the decrypted sizes")
+	s.Require().Equal("big.txt", infos[0].Name())
+	s.Require().Equal(int64(50), infos[0].Size())
+
+	infos, err = s.fs.List("docs", filestore.WithPrefix("small"))
+	s.Require().NoError(err)
+	s.Require().Len(infos, 1)
+	s.Require().Equal(int64(5), infos[0].Size())
+}
+
+func (s *EncryptedTestSuite) TestFromConfig() {
+	fs, err := filestore.FromConfig(filestore.Config{
+		URL: "mem://",
+		Layers: []filestore.LayerConfig{{Type: "encrypted", Options: filestore.LayerOptions{
+			"key": base64.StdEncoding.EncodeToString(s.key),
+		}}},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(fs, "dude.txt", "the dude abides"))
+	content, err := readString(fs, "dude.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("the dude abides", content)
+
+	_, err = filestore.FromConfig(filestore.Config{URL: "mem://", Layers: []filestore.LayerConfig{{Type: "encrypted"}}})
+	s.Require().Error(err, "Should require a key")
+}
+
+func (s *EncryptedTestSuite) read(name string) string {
+	content, err := readString(s.fs, name)
+	s.Require().NoError(err)
+	return content
+}
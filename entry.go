@@ -0,0 +1,86 @@
+package filestore
+
+import (
+	"io/fs"
+	"path"
+)
+
+// Entry is a lightweight handle to a file/directory found while listing a directory. Its name, path,
+// and type are known up front, but fetching anything else (size, modification time, etc.) may require
+// another round trip to the store (e.g. a stat() system call on disk), so that is deferred until you
+// call Info(). This lets you list massive directories when you only need the names of the files.
+type Entry interface {
+	// Name is the base name of the file/directory (e.g. "foo.txt").
+	Name() string
+	// Path is the path of the file/directory relative to the store's working directory, including
+	// the directory you listed (e.g. "docs/foo.txt" when listing "docs").
+	Path() string
+	// IsDir returns true when the entry is a directory.
+	IsDir() bool
+	// Type returns the type bits of the entry's mode (e.g. fs.ModeDir or fs.ModeSymlink).
+	Type() fs.FileMode
+	// Info fetches the full metadata for the entry. The file may have been removed or renamed since
+	// you listed the directory, in which case you'll get an error.
+	Info() (FileInfo, error)
+}
+
+// EntryLister is an optional capability for stores that can list a directory's contents w/o fetching
+// the full metadata for each file/directory.
+type EntryLister interface {
+	ListEntries(dirPath string, filters ...FileFilter) ([]Entry, error)
+}
+
+// ListEntries works just like the store's List operation, but gives you Entry values rather than the
+// full FileInfo for each file/directory. Stores that implement the EntryLister capability (e.g. DiskFS)
+// skip fetching metadata you didn't ask for; for all others, this is just a wrapper around List.
+//
+// Example:
+//
+//	entries, err := filestore.ListEntries(files, "uploads", filestore.WithExt("jpg"))
+//	if err != nil {
+//	    // handle your error nicely
+//	}
+//	for _, entry := range entries {
+//	    fmt.Println(entry.Path()) // no stat() calls were harmed in the printing of these paths
+//	}
+func ListEntries(fsys FS, dirPath string, filters ...FileFilter) ([]Entry, error) {
+	if lister, ok := fsys.(EntryLister); ok {
+		return lister.ListEntries(dirPath, filters...)
+	}
+
+	infos, err := fsys.List(dirPath, filters...)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(infos))
+	for i, info := range infos {
+		entries[i] = infoEntry{info: info, dirPath: dirPath}
+	}
+	return entries, nil
+}
+
+// infoEntry adapts a FileInfo that we've already fetched into an Entry.
+type infoEntry struct {
+	info    FileInfo
+	dirPath string
+}
+
+func (e infoEntry) Name() string {
+	return e.info.Name()
+}
+
+func (e infoEntry) Path() string {
+	return path.Join(e.dirPath, e.info.Name())
+}
+
+func (e infoEntry) IsDir() bool {
+	return e.info.IsDir()
+}
+
+func (e infoEntry) Type() fs.FileMode {
+	return e.info.Mode().Type()
+}
+
+func (e infoEntry) Info() (FileInfo, error) {
+	return e.info, nil
+}
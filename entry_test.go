@@ -0,0 +1,85 @@
+package filestore_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type EntryTestSuite struct {
+	suite.Suite
+}
+
+func TestEntryTestSuite(t *testing.T) {
+	suite.Run(t, &EntryTestSuite{})
+}
+
+func (s *EntryTestSuite) populate(fsys filestore.FS) {
+	s.Require().NoError(writeString(fsys, "docs/1.lebowski", "jeff"))
+	s.Require().NoError(writeString(fsys, "docs/2.txt", "walter"))
+	s.Require().NoError(writeString(fsys, "docs/inner/3.lebowski", "donnie"))
+}
+
+func (s *EntryTestSuite) assertEntries(fsys filestore.FS) {
+	entries, err := filestore.ListEntries(fsys, "docs")
+	s.Require().NoError(err)
+	s.Require().Equal(3, len(entries))
+
+	s.Require().Equal("1.lebowski", entries[0].Name())
+	s.Require().Equal("docs/1.lebowski", entries[0].Path())
+	s.Require().False(entries[0].IsDir())
+	s.Require().Equal(fs.FileMode(0), entries[0].Type())
+
+	s.Require().Equal("inner", entries[2].Name())
+	s.Require().Equal("docs/inner", entries[2].Path())
+	s.Require().True(entries[2].IsDir())
+	s.Require().Equal(fs.ModeDir, entries[2].Type())
+
+	info, err := entries[1].Info()
+	s.Require().NoError(err)
+	s.Require().Equal("2.txt", info.Name())
+	s.Require().Equal(int64(6), info.Size())
+
+	entries, err = filestore.ListEntries(fsys, "docs", filestore.WithExt("lebowski"))
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(entries))
+	s.Require().Equal("docs/1.lebowski", entries[0].Path())
+
+	entries, err = filestore.ListEntries(fsys, "nope")
+	s.Require().NoError(err)
+	s.Require().Equal(0, len(entries))
+}
+
+func (s *EntryTestSuite) TestListEntries_disk() {
+	disk := filestore.Disk(s.T().TempDir())
+	s.populate(disk)
+	s.assertEntries(disk)
+}
+
+func (s *EntryTestSuite) TestListEntries_memory() {
+	memory := filestore.Memory()
+	s.populate(memory)
+	s.assertEntries(memory)
+}
+
+// If we've truly deferred the stat() call, a file removed after listing will only fail once you ask for its info.
+func (s *EntryTestSuite) TestListEntries_lazy() {
+	dir := s.T().TempDir()
+	disk := filestore.Disk(dir)
+	s.populate(disk)
+
+	entries, err := filestore.ListEntries(disk, "docs")
+	s.Require().NoError(err)
+	s.Require().NoError(os.Remove(filepath.Join(dir, "docs", "1.lebowski")))
+
+	s.Require().Equal("1.lebowski", entries[0].Name(), "Names should still be available")
+	_, err = entries[0].Info()
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+
+	_, err = entries[1].Info()
+	s.Require().NoError(err)
+}
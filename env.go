@@ -0,0 +1,61 @@
+package filestore
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// The well-known environment variables that Default() uses to build a file store.
+const (
+	// EnvURL is the environment variable containing the connection URL of the default store (see Open).
+	EnvURL = "FILESTORE_URL"
+	// EnvConfig is the environment variable containing the path to a JSON config file describing the
+	// default store (see FromConfig). When present, this takes precedence over EnvURL.
+	EnvConfig = "FILESTORE_CONFIG"
+)
+
+// Default builds a file store based on well-known environment variables, making it trivial to point
+// twelve-factor services and CLIs at different backends in different environments:
+//
+//   - FILESTORE_CONFIG: path to a JSON file describing the full storage stack (see FromConfig).
+//   - FILESTORE_URL: connection URL for the backend (see Open).
+//
+// If neither is set, you get a store rooted in the process' current working directory. Both the
+// URL and the contents of the config file may reference other environment variables using the
+// ${VAR} syntax. This lets you keep credentials in their own variables (or secret mounts)
+// rather than embedding them in the connection string itself. Any other "$" (e.g. in a password
+// or a pattern) is left exactly as written.
+//
+// Example:
+//
+//	// FILESTORE_URL="s3://my-bucket/uploads?secret=${UPLOADS_SECRET}"
+//	files, err := filestore.Default()
+func Default() (FS, error) {
+	if configPath := os.Getenv(EnvConfig); configPath != "" {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("filestore: default: %w", err)
+		}
+		cfg, err := ParseConfig([]byte(expandEnv(string(data))))
+		if err != nil {
+			return nil, fmt.Errorf("filestore: default: %w", err)
+		}
+		return FromConfig(cfg)
+	}
+
+	if rawURL := os.Getenv(EnvURL); rawURL != "" {
+		return Open(expandEnv(rawURL))
+	}
+	return Disk("."), nil
+}
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces each ${VAR} reference in the value with that environment variable's value. Unlike
+// os.ExpandEnv, bare $VAR references and any other use of "$" are not touched.
+func expandEnv(value string) string {
+	return envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
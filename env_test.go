@@ -0,0 +1,81 @@
+package filestore_test
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type EnvTestSuite struct {
+	suite.Suite
+}
+
+func TestEnvTestSuite(t *testing.T) {
+	suite.Run(t, &EnvTestSuite{})
+}
+
+func (s *EnvTestSuite) SetupTest() {
+	s.T().Setenv(filestore.EnvURL, "")
+	s.T().Setenv(filestore.EnvConfig, "")
+}
+
+func (s *EnvTestSuite) TestDefault_nothingSet() {
+	fs, err := filestore.Default()
+	s.Require().NoError(err)
+	s.Require().IsType(&filestore.DiskFS{}, fs)
+	s.Require().Equal(".", fs.WorkingDirectory())
+}
+
+func (s *EnvTestSuite) TestDefault_url() {
+	s.T().Setenv("FILESTORE_TEST_DIR", "inner1")
+	s.T().Setenv(filestore.EnvURL, "file:testdata/${FILESTORE_TEST_DIR}")
+
+	fs, err := filestore.Default()
+	s.Require().NoError(err)
+	s.Require().Equal("testdata/inner1", fs.WorkingDirectory())
+	s.Require().True(fs.Exists("foo.txt"))
+
+	s.T().Setenv(filestore.EnvURL, "nope://")
+	_, err = filestore.Default()
+	s.Require().Error(err, "Invalid URL should fail")
+}
+
+func (s *EnvTestSuite) TestDefault_config() {
+	configPath := path.Join(s.T().TempDir(), "filestore.json")
+	config := `{"url": "file:testdata", "layers": [{"type": "subdir", "options": {"path": "${FILESTORE_TEST_DIR}"}}]}`
+	s.Require().NoError(os.WriteFile(configPath, []byte(config), 0644))
+
+	s.T().Setenv("FILESTORE_TEST_DIR", "inner1")
+	s.T().Setenv(filestore.EnvURL, "mem://") // config should take precedence
+	s.T().Setenv(filestore.EnvConfig, configPath)
+
+	fs, err := filestore.Default()
+	s.Require().NoError(err)
+	s.Require().Equal("testdata/inner1", fs.WorkingDirectory())
+
+	s.T().Setenv(filestore.EnvConfig, configPath+".nope")
+	_, err = filestore.Default()
+	s.Require().Error(err, "Missing config file should fail")
+}
+
+func (s *EnvTestSuite) TestDefault_literalDollar() {
+	configPath := path.Join(s.T().TempDir(), "filestore.json")
+	config := `{"url": "file:testdata", "layers": [{"type": "subdir", "options": {"path": "${FILESTORE_TEST_DIR}/pa$$word$HOME$"}}]}`
+	s.Require().NoError(os.WriteFile(configPath, []byte(config), 0644))
+
+	s.T().Setenv("FILESTORE_TEST_DIR", "inner1")
+	s.T().Setenv(filestore.EnvConfig, configPath)
+
+	fs, err := filestore.Default()
+	s.Require().NoError(err)
+	s.Require().Equal("testdata/inner1/pa$$word$HOME$", fs.WorkingDirectory())
+
+	s.T().Setenv(filestore.EnvConfig, "")
+	s.T().Setenv(filestore.EnvURL, "file:testdata/${FILESTORE_TEST_DIR}/$HOME")
+	fs, err = filestore.Default()
+	s.Require().NoError(err)
+	s.Require().Equal("testdata/inner1/$HOME", fs.WorkingDirectory())
+}
@@ -0,0 +1,163 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"runtime/debug"
+	"strings"
+	"syscall"
+)
+
+// PathError is the error that stores return when an operation fails. It works like the standard library's
+// *fs.PathError, but it also tells you which kind of store failed (e.g. "disk" or "s3"), so log lines and
+// error handlers can pick the details apart w/ errors.As() rather than parsing the message. The underlying
+// cause is still available to errors.Is(), so checks like errors.Is(err, fs.ErrNotExist) keep working.
+//
+// When one store wraps another (e.g. Versioned() around Disk()), you can get several of these in the same
+// chain; errors.As() gives you the outermost one.
+//
+// Example:
+//
+//	var pathErr *filestore.PathError
+//	if errors.As(err, &pathErr) {
+//	    slog.Error("storage failure", "backend", pathErr.Backend, "op", pathErr.Op, "path", pathErr.Path)
+//	}
+type PathError struct {
+	// Backend is the kind of store that failed, e.g. "disk", "memory", "s3", or "redis".
+	Backend string
+	// Op is the operation that failed, e.g. "stat", "open", "write", "list files", "remove", or "move".
+	Op string
+	// Path is the file/directory the operation failed on, as you supplied it to the store. It's empty when
+	// the failure isn't about any particular path (e.g. closing a Redis store's connections).
+	Path string
+	// Err is the underlying cause of the failure.
+	Err error
+}
+
+// Error formats the error as "backend fs error: op: path: cause".
+func (e *PathError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s fs error: %s: %v", e.Backend, e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s fs error: %s: %s: %v", e.Backend, e.Op, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying cause of the failure.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// newPathError creates the structured error for an operation that failed on the given store/path.
+func newPathError(backend string, op string, filePath string, err error) error {
+	return &PathError{Backend: backend, Op: op, Path: filePath, Err: err}
+}
+
+// errIsDirectory is the cause of errors when you try to read/write a directory as if it were a file.
+var errIsDirectory = errors.New("is a directory")
+
+// ErrNotDirectory is the cause of errors when you try to treat a file as if it were a directory (e.g. listing
+// it or calling Sub() on it).
+var ErrNotDirectory = errors.New("filestore: not a directory")
+
+// backendUnavailable returns true when the error means that the store itself isn't working (it's unreachable,
+// failing w/ I/O errors, etc.), so it's worth trying another store instead. Legit answers (the file doesn't
+// exist, you're not allowed to see it) and problems w/ the request itself (listing a file, an invalid path,
+// a cancelled context, a panicking filter) would fail the same way anywhere, so they don't count.
+func backendUnavailable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrExist), errors.Is(err, fs.ErrInvalid),
+		errors.Is(err, fs.ErrPermission), errors.Is(err, fs.ErrClosed):
+		return false
+	case errors.Is(err, ErrNotDirectory), errors.Is(err, errIsDirectory), errors.Is(err, syscall.ENOTDIR),
+		errors.Is(err, syscall.EISDIR), errors.Is(err, ErrPathEscapesBase), errors.Is(err, ErrTooManyEntries):
+		return false
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	}
+	var panicErr *PanicError
+	return !errors.As(err, &panicErr)
+}
+
+// PanicError is the cause of an operation's error when a callback that you (or a third-party plugin)
+// supplied panicked, such as a FileFilter passed to List(). Rather than letting one misbehaving callback
+// crash the entire process, we recover and fail just the operation that invoked it. If the callback
+// panicked w/ an error, errors.Is() and errors.As() can see through to it.
+type PanicError struct {
+	// Value is whatever the callback passed to panic().
+	Value any
+	// Stack is the goroutine's stack trace at the time of the panic, for tracking down the culprit.
+	Stack []byte
+}
+
+// Error formats the error as "panic: value".
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the value the callback panicked w/ when it's an error, or nil otherwise.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanic converts a panic into a *PanicError in the given error return value. You must defer it
+// directly (i.e. "defer recoverPanic(&err)") for it to be able to recover.
+func recoverPanic(err *error) {
+	if value := recover(); value != nil {
+		*err = &PanicError{Value: value, Stack: debug.Stack()}
+	}
+}
+
+// joinErrors combines the non-nil errors into one, returning nil if there aren't any. It works like Go
+// 1.20's errors.Join(), but the result implements Is() and As() itself so that errors.Is() and errors.As()
+// look at every one of the errors on older versions of Go, too.
+func joinErrors(errs ...error) error {
+	joined := &joinedError{}
+	for _, err := range errs {
+		if err != nil {
+			joined.errs = append(joined.errs, err)
+		}
+	}
+	if len(joined.errs) == 0 {
+		return nil
+	}
+	return joined
+}
+
+// joinedError is the result of joinErrors().
+type joinedError struct {
+	errs []error
+}
+
+// Error formats the errors one per line, just like errors.Join().
+func (e *joinedError) Error() string {
+	messages := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Is returns true when any of the errors matches the target.
+func (e *joinedError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches the target.
+func (e *joinedError) As(target any) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package filestore_test
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type ErrorsTestSuite struct {
+	suite.Suite
+}
+
+func TestErrorsTestSuite(t *testing.T) {
+	suite.Run(t, &ErrorsTestSuite{})
+}
+
+func (s *ErrorsTestSuite) TestPathError() {
+	err := error(&filestore.PathError{Backend: "disk", Op: "open", Path: "a.txt", Err: fs.ErrNotExist})
+	s.Require().Equal("disk fs error: open: a.txt: file does not exist", err.Error())
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+
+	err = &filestore.PathError{Backend: "redis", Op: "close", Err: fs.ErrClosed}
+	s.Require().Equal("redis fs error: close: file already closed", err.Error())
+}
+
+func (s *ErrorsTestSuite) TestStores() {
+	server := filestoretest.NewS3Server("lebowski")
+	defer server.Close()
+	bucket := filestore.S3("lebowski",
+		filestore.WithEndpoint(server.URL),
+		filestore.WithRegion("us-east-1"),
+		filestore.WithCredentials("AKID", "SECRET", ""),
+	)
+
+	stores := map[string]filestore.FS{
+		"disk":   filestore.Disk(s.T().TempDir()),
+		"memory": filestore.Memory(),
+		"s3":     bucket,
+	}
+	for backend, store := range stores {
+		s.Require().NoError(writeString(store, "docs/a.txt", "abide"))
+
+		_, err := store.ChangeDirectory("docs").Read("missing.txt")
+		s.assertPathError(err, backend, "open", "missing.txt")
+		s.Require().True(errors.Is(err, fs.ErrNotExist), "%s: should still be able to check the cause", backend)
+
+		_, err = store.Read("docs")
+		s.assertPathError(err, backend, "open", "docs")
+
+		_, err = store.List("docs/a.txt")
+		s.assertPathError(err, backend, "list files", "docs/a.txt")
+
+		err = store.Move("docs/missing.txt", "docs/b.txt")
+		s.assertPathError(err, backend, "move", "docs/missing.txt")
+	}
+}
+
+func (s *ErrorsTestSuite) TestReadOnlyStores() {
+	zipPath := filepath.Join(s.T().TempDir(), "testdata.zip")
+	output, err := os.Create(zipPath)
+	s.Require().NoError(err)
+	archive := zip.NewWriter(output)
+	entry, err := archive.Create("inner1/foo.txt")
+	s.Require().NoError(err)
+	_, err = entry.Write([]byte("foo"))
+	s.Require().NoError(err)
+	s.Require().NoError(archive.Close())
+	s.Require().NoError(output.Close())
+
+	zipFS, err := filestore.ZipReader(zipPath)
+	s.Require().NoError(err)
+	defer zipFS.Close(context.Background())
+
+	stores := map[string]filestore.FS{
+		"zip":   zipFS,
+		"embed": filestore.FromEmbed(embeddedTestData, "testdata"),
+	}
+	for backend, store := range stores {
+		_, err = store.ChangeDirectory("inner1").Read("missing.txt")
+		s.assertPathError(err, backend, "open", "missing.txt")
+		s.Require().True(errors.Is(err, fs.ErrNotExist), "%s: should still be able to check the cause", backend)
+
+		_, err = store.Stat("missing.txt")
+		s.assertPathError(err, backend, "stat", "missing.txt")
+
+		_, err = store.Read("inner1")
+		s.assertPathError(err, backend, "open", "inner1")
+
+		_, err = store.List("inner1/foo.txt")
+		s.assertPathError(err, backend, "list files", "inner1/foo.txt")
+
+		_, err = store.Write("inner1/foo.txt")
+		s.assertPathError(err, backend, "write", "inner1/foo.txt")
+		s.Require().True(errors.Is(err, filestore.ErrReadOnly))
+
+		err = store.Remove("inner1/foo.txt")
+		s.assertPathError(err, backend, "remove", "inner1/foo.txt")
+
+		err = store.Move("inner1/foo.txt", "inner1/bar.txt")
+		s.assertPathError(err, backend, "move", "inner1/foo.txt")
+	}
+}
+
+func (s *ErrorsTestSuite) TestWrappers() {
+	files, err := filestore.Scoped(filestore.Memory(), "1")
+	s.Require().NoError(err)
+	_, err = files.Write("../2/a.txt")
+	s.assertPathError(err, "scoped", "write", "../2/a.txt")
+	s.Require().True(errors.Is(err, fs.ErrPermission))
+
+	files = filestore.Versioned(filestore.Memory())
+	_, err = files.Write(".versions/a.txt/nope")
+	s.assertPathError(err, "versioned", "write", ".versions/a.txt/nope")
+	s.Require().True(errors.Is(err, fs.ErrPermission))
+}
+
+func (s *ErrorsTestSuite) TestPanickingFilter() {
+	errPlugin := errors.New("plugin bug")
+	explode := func(info filestore.FileInfo) bool {
+		if info.Name() == "b.txt" {
+			panic(errPlugin)
+		}
+		return true
+	}
+
+	stores := map[string]filestore.FS{
+		"disk":    filestore.Disk(s.T().TempDir()),
+		"memory":  filestore.Memory(),
+		"overlay": filestore.Overlay(filestore.Memory(), filestore.Memory()),
+	}
+	for backend, store := range stores {
+		s.Require().NoError(writeString(store, "docs/a.txt", "abide"))
+		s.Require().NoError(writeString(store, "docs/b.txt", "abide"))
+
+		_, err := store.List("docs", filestore.WithExt("txt"), explode)
+		s.assertPathError(err, backend, "list files", "docs")
+
+		var panicErr *filestore.PanicError
+		s.Require().True(errors.As(err, &panicErr), "%s: should be a *PanicError: %v", backend, err)
+		s.Require().Equal(errPlugin, panicErr.Value)
+		s.Require().NotEmpty(panicErr.Stack)
+		s.Require().True(errors.Is(err, errPlugin), "%s: should be able to check what it panicked w/", backend)
+
+		entries, err := store.List("docs", filestore.WithExt("txt"))
+		s.Require().NoError(err, "%s: should still work after a panic", backend)
+		s.Require().Len(entries, 2)
+	}
+
+	_, err := filestore.Find(stores["memory"], ".", explode)
+	s.Require().True(errors.Is(err, errPlugin))
+	s.Require().Equal("filestore: find: docs/b.txt: panic: plugin bug", err.Error())
+}
+
+func (s *ErrorsTestSuite) assertPathError(err error, backend string, op string, filePath string) {
+	var pathErr *filestore.PathError
+	s.Require().True(errors.As(err, &pathErr), "%s: should be a *PathError: %v", backend, err)
+	s.Require().Equal(backend, pathErr.Backend)
+	s.Require().Equal(op, pathErr.Op, "%s: wrong op", backend)
+	s.Require().Equal(filePath, pathErr.Path, "%s: wrong path", backend)
+}
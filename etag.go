@@ -0,0 +1,88 @@
+package filestore
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// etagger is implemented by FileInfo values for stores that report an entity tag for each file (e.g.
+// S3 and HTTP servers).
+type etagger interface {
+	ETag() string
+}
+
+// ETag returns the entity tag the store reported for the file w/o the surrounding quotes, or an empty
+// string for stores that don't have them (e.g. DiskFS). For S3 objects, you can compare this to the
+// result of ComputeETag() on a local file to see if they have the same contents.
+func ETag(info FileInfo) string {
+	if tagger, ok := info.(etagger); ok {
+		return strings.Trim(tagger.ETag(), `"`)
+	}
+	return ""
+}
+
+// ComputeETag calculates the ETag that S3 would assign to the file if it were uploaded using the given
+// part size, so you can compare a local file against an object w/o downloading it. Files smaller than
+// the part size are uploaded in a single request, so their ETag is just the MD5 of the data. Larger
+// files are uploaded in parts, so their ETag is the MD5 of each part's MD5 followed by the number of
+// parts, e.g. "d41d8cd98f00b204e9800998ecf8427e-3". This matches how S3() uploads files when you use
+// the same value for WithPartSize() (or 8MB, the default for both S3() and the AWS CLI).
+//
+// Example:
+//
+//	info, err := bucket.Stat("backups/2022.tar.gz")
+//	...
+//	etag, err := filestore.ComputeETag(filestore.Disk("backups"), "2022.tar.gz", 8*1024*1024)
+//	...
+//	if etag == filestore.ETag(info) {
+//	    // No need to upload it again.
+//	}
+func ComputeETag(fsys FS, filePath string, partSize int) (string, error) {
+	if partSize <= 0 {
+		return "", fmt.Errorf("filestore: compute etag: invalid part size %d", partSize)
+	}
+	file, err := fsys.Read(filePath)
+	if err != nil {
+		return "", fmt.Errorf("filestore: compute etag: %w", err)
+	}
+	defer file.Close()
+
+	etag, err := multipartETag(file, partSize)
+	if err != nil {
+		return "", fmt.Errorf("filestore: compute etag: %s: %w", filePath, err)
+	}
+	return etag, nil
+}
+
+// multipartETag hashes each part of the data as it's read, so we never hold more than one part
+// in memory.
+func multipartETag(r io.Reader, partSize int) (string, error) {
+	var size int64
+	var partSums []byte
+	part := make([]byte, partSize)
+	for {
+		n, err := io.ReadFull(r, part)
+		if n > 0 {
+			sum := md5.Sum(part[:n])
+			partSums = append(partSums, sum[:]...)
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Small files are uploaded in one request, and all of their data is still in the buffer.
+	if size < int64(partSize) {
+		sum := md5.Sum(part[:size])
+		return hex.EncodeToString(sum[:]), nil
+	}
+	sum := md5.Sum(partSums)
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), len(partSums)/md5.Size), nil
+}
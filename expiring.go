@@ -0,0 +1,226 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// expiringOptions contains the settings that only apply to Expiring().
+type expiringOptions struct {
+	interval time.Duration
+}
+
+// defaultSweepInterval is how often we sweep when the TTL is too short to use as the interval.
+const defaultSweepInterval = time.Minute
+
+// WithSweepInterval sets how often an Expiring() store looks for expired files to remove. By default, it
+// sweeps once per TTL (or once a minute if the TTL isn't positive). Expired files are hidden as soon as they expire regardless, so this only controls
+// how quickly their space is reclaimed.
+func WithSweepInterval(interval time.Duration) Option {
+	return func(opts *options) {
+		if interval > 0 {
+			opts.expiring.interval = interval
+		}
+	}
+}
+
+// Expiring wraps a file store so that its files expire once they haven't been written to for the given
+// TTL, which makes any store a good home for scratch files that nobody remembers to clean up. Expired files
+// are hidden right away: reading, listing, and stat-ing them behaves as if they don't exist. A background
+// sweeper periodically removes them (along w/ any directories that stay empty from one sweep to the next)
+// so the space is actually reclaimed; use WithSweepInterval() to control how often it runs.
+//
+// A file's age is based on its modification time in the underlying store, so files that were already
+// there expire, too, and the TTL survives restarts. Moving a file doesn't reset the clock. The sweeper
+// runs until you shut the store down via Shutdown(), so make sure you do. You can supply WithClock() to
+// control expiration in tests. A TTL <= 0 means that every file expires as soon as it's written.
+//
+// Example:
+//
+//	scratch := filestore.Expiring(filestore.Disk("/var/scratch"), 24*time.Hour, filestore.WithSweepInterval(time.Hour))
+//	defer filestore.Shutdown(context.Background(), scratch)
+func Expiring(fs FS, ttl time.Duration, opts ...Option) FS {
+	options := newOptions(opts)
+	interval := options.expiring.interval
+	if interval <= 0 {
+		interval = ttl
+	}
+	if interval <= 0 {
+		// Otherwise the sweeper would walk the whole tree over and over w/o ever waiting.
+		interval = defaultSweepInterval
+	}
+
+	sweeper := &expirySweeper{
+		fs:       fs,
+		ttl:      ttl,
+		interval: interval,
+		clock:    options.clock,
+		done:     make(chan struct{}),
+		empty:    map[string]bool{},
+	}
+	go sweeper.run()
+	return &expiringFS{FS: fs, sweeper: sweeper}
+}
+
+func init() {
+	RegisterLayer("expiring", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			TTL           string `json:"ttl"`
+			SweepInterval string `json:"sweepInterval"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+
+		ttl, err := time.ParseDuration(settings.TTL)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid ttl: %q", settings.TTL)
+		}
+		var opts []Option
+		if settings.SweepInterval != "" {
+			interval, err := time.ParseDuration(settings.SweepInterval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sweepInterval: %w", err)
+			}
+			opts = append(opts, WithSweepInterval(interval))
+		}
+		return Expiring(fs, ttl, opts...), nil
+	})
+}
+
+type expiringFS struct {
+	FS
+	sweeper *expirySweeper
+}
+
+// expirySweeper removes expired files in the background. It's shared by the original wrapper and any
+// instances derived from it via ChangeDirectory().
+type expirySweeper struct {
+	// fs is the store that we were given originally, so we always sweep the whole thing.
+	fs       FS
+	ttl      time.Duration
+	interval time.Duration
+	clock    Clock
+	done     chan struct{}
+	stop     sync.Once
+	// empty contains the directories that were empty during the last sweep. Only the sweeper goroutine uses it.
+	empty map[string]bool
+}
+
+// expired returns true when the file hasn't been written to for a whole TTL.
+func (s *expirySweeper) expired(info FileInfo) bool {
+	return !info.IsDir() && !s.clock.Now().Before(info.ModTime().Add(s.ttl))
+}
+
+// run sweeps the store every interval until the store is closed.
+func (s *expirySweeper) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.clock.After(s.interval):
+			s.sweep()
+		}
+	}
+}
+
+// sweep removes every expired file, followed by any directory that was already empty during the previous
+// sweep. That grace period keeps us from yanking a directory out from under someone who just made it. We
+// just try again next time if anything fails.
+func (s *expirySweeper) sweep() {
+	var dirs []string
+	_ = Walk(s.fs, ".", func(filePath string, info FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return nil
+		case info.IsDir():
+			dirs = append(dirs, filePath)
+		case s.expired(info):
+			_ = s.fs.Remove(filePath)
+		}
+		return nil
+	})
+
+	// Children come after their parents in the walk, so going backwards lets emptied parents go, too.
+	empty := map[string]bool{}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if entries, err := s.fs.List(dirs[i]); dirs[i] == "." || err != nil || len(entries) > 0 {
+			continue
+		}
+		if !s.empty[dirs[i]] || s.fs.Remove(dirs[i]) != nil {
+			empty[dirs[i]] = true
+		}
+	}
+	s.empty = empty
+}
+
+// Stat fetches metadata about the file, failing w/ fs.ErrNotExist if it has expired.
+func (e *expiringFS) Stat(filePath string) (FileInfo, error) {
+	info, err := e.FS.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if e.sweeper.expired(info) {
+		return nil, newPathError("expiring", "stat", filePath, fs.ErrNotExist)
+	}
+	return info, nil
+}
+
+// Exists returns true when the file/directory exists and hasn't expired.
+func (e *expiringFS) Exists(filePath string) bool {
+	_, err := e.Stat(filePath)
+	return err == nil
+}
+
+// Read opens the file for reading, failing w/ fs.ErrNotExist if it has expired.
+func (e *expiringFS) Read(filePath string) (ReaderFile, error) {
+	if _, err := e.Stat(filePath); errors.Is(err, fs.ErrNotExist) {
+		return nil, newPathError("expiring", "open", filePath, fs.ErrNotExist)
+	}
+	return e.FS.Read(filePath)
+}
+
+// List reads the contents of the directory, leaving out any files that have expired.
+func (e *expiringFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	notExpired := func(info FileInfo) bool { return !e.sweeper.expired(info) }
+	return e.FS.List(dirPath, append(filters, notExpired)...)
+}
+
+// Move relocates the file/directory, failing w/ fs.ErrNotExist if it's a file that has expired.
+func (e *expiringFS) Move(fromPath string, toPath string) error {
+	if _, err := e.Stat(fromPath); errors.Is(err, fs.ErrNotExist) {
+		return newPathError("expiring", "move", fromPath, fs.ErrNotExist)
+	}
+	return e.FS.Move(fromPath, toPath)
+}
+
+func (e *expiringFS) ChangeDirectory(dir string) FS {
+	return &expiringFS{FS: e.FS.ChangeDirectory(dir), sweeper: e.sweeper}
+}
+
+func (e *expiringFS) withContext(ctx context.Context) FS {
+	return &expiringFS{FS: ForRequest(e.FS, ctx), sweeper: e.sweeper}
+}
+
+func (e *expiringFS) requestContext() context.Context {
+	return RequestContext(e.FS)
+}
+
+func (e *expiringFS) wrapped() []FS {
+	return []FS{e.FS}
+}
+
+// Close stops the background sweeper and then closes the underlying store if it supports the Closer
+// capability.
+func (e *expiringFS) Close(ctx context.Context) error {
+	e.sweeper.stop.Do(func() { close(e.sweeper.done) })
+	return Shutdown(ctx, e.FS)
+}
+
+var _ Closer = &expiringFS{}
+var _ requestBinder = &expiringFS{}
+var _ wrapper = &expiringFS{}
@@ -0,0 +1,97 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExpiringTestSuite struct {
+	suite.Suite
+	clock  *filestoretest.Clock
+	memory filestore.FS
+	fs     filestore.FS
+}
+
+func TestExpiringTestSuite(t *testing.T) {
+	suite.Run(t, &ExpiringTestSuite{})
+}
+
+func (s *ExpiringTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.memory = filestore.Memory(filestore.WithClock(s.clock))
+	s.fs = filestore.Expiring(s.memory, time.Hour, filestore.WithClock(s.clock), filestore.WithSweepInterval(10*time.Minute))
+}
+
+func (s *ExpiringTestSuite) TearDownTest() {
+	s.Require().NoError(filestore.Shutdown(context.Background(), s.fs))
+}
+
+func (s *ExpiringTestSuite) TestHidesExpiredFiles() {
+	s.Require().NoError(writeString(s.fs, "scratch/old.txt", "the dude"))
+	s.clock.Advance(30 * time.Minute)
+	s.Require().NoError(writeString(s.fs, "scratch/new.txt", "walter"))
+	s.clock.Advance(30 * time.Minute)
+
+	s.Require().False(s.fs.Exists("scratch/old.txt"))
+	s.Require().True(s.fs.Exists("scratch/new.txt"))
+	s.Require().True(s.memory.Exists("scratch/old.txt"), "Should not remove anything until the sweeper runs")
+
+	_, err := s.fs.Read("scratch/old.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = s.fs.ChangeDirectory("scratch").Stat("old.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	s.Require().True(errors.Is(s.fs.Move("scratch/old.txt", "scratch/moved.txt"), fs.ErrNotExist))
+
+	entries, err := s.fs.List("scratch")
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Require().Equal("new.txt", entries[0].Name())
+
+	// Writing the file again should start it over w/ a brand-new TTL.
+	s.Require().NoError(writeString(s.fs, "scratch/old.txt", "donny"))
+	content, err := readString(s.fs, "scratch/old.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("donny", content)
+}
+
+func (s *ExpiringTestSuite) TestSweep() {
+	s.Require().NoError(writeString(s.fs, "scratch/a/old.txt", "the dude"))
+	s.Require().NoError(writeString(s.fs, "scratch/b/old.txt", "walter"))
+	s.clock.BlockUntil(1)
+	s.clock.Advance(50 * time.Minute)
+
+	s.Require().NoError(writeString(s.fs, "scratch/b/new.txt", "donny"))
+	s.clock.BlockUntil(1)
+	s.clock.Advance(10 * time.Minute)
+	s.Require().Eventually(func() bool { return !s.memory.Exists("scratch/a/old.txt") }, time.Second, time.Millisecond)
+	s.Require().False(s.memory.Exists("scratch/b/old.txt"))
+	s.Require().True(s.memory.Exists("scratch/b/new.txt"))
+	s.Require().True(s.memory.Exists("scratch/a"), "Should give empty directories until the next sweep")
+
+	s.clock.BlockUntil(1)
+	s.clock.Advance(10 * time.Minute)
+	s.Require().Eventually(func() bool { return !s.memory.Exists("scratch/a") }, time.Second, time.Millisecond)
+	s.Require().True(s.memory.Exists("scratch/b/new.txt"))
+	s.Require().True(s.memory.Exists("scratch"))
+}
+
+func (s *ExpiringTestSuite) TestZeroTTL() {
+	s.Require().NoError(writeString(s.memory, "scratch/old.txt", "the dude"))
+	expiring := filestore.Expiring(s.memory, 0, filestore.WithClock(s.clock))
+	defer func() { _ = filestore.Shutdown(context.Background(), expiring) }()
+
+	// The sweeper should wait for its interval rather than walking the store nonstop.
+	s.clock.BlockUntil(2)
+	s.Require().False(expiring.Exists("scratch/old.txt"))
+	s.Require().True(s.memory.Exists("scratch/old.txt"), "Should not sweep until the interval passes")
+
+	s.clock.Advance(time.Minute)
+	s.Require().Eventually(func() bool { return !s.memory.Exists("scratch/old.txt") }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package filestore
+
+import (
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// LimitInotifyWatches makes adding inotify watches fail w/ ENOSPC (like it does when you exceed
+// fs.inotify.max_user_watches) once 'limit' watches have been added. Call the returned function to
+// restore the real behavior.
+func LimitInotifyWatches(limit int) (restore func()) {
+	mutex := sync.Mutex{}
+	added := 0
+	inotifyAddWatch = func(fd int, pathname string, mask uint32) (int, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if added >= limit {
+			return -1, unix.ENOSPC
+		}
+		added++
+		return unix.InotifyAddWatch(fd, pathname, mask)
+	}
+	return func() { inotifyAddWatch = unix.InotifyAddWatch }
+}
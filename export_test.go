@@ -0,0 +1,7 @@
+package filestore
+
+// Expose a few internals so that the black box tests in package filestore_test can fuzz them.
+
+var ResolvePath = resolvePath
+
+var RebindPostgres = postgresDialect.rebind
@@ -0,0 +1,160 @@
+package filestore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// failoverOptions contains the settings that only apply to Failover().
+type failoverOptions struct {
+	interval time.Duration
+}
+
+// WithHealthCheckInterval determines how long Failover() waits after the primary store fails before it
+// checks whether the primary is healthy again. By default, it waits 30 seconds.
+func WithHealthCheckInterval(interval time.Duration) Option {
+	return func(opts *options) {
+		if interval > 0 {
+			opts.failover.interval = interval
+		}
+	}
+}
+
+// Failover wraps a primary store so that reads (Read, List, Stat, and Exists) are transparently retried
+// against a fallback store when the primary fails, e.g. a local replica of an NFS-backed Disk() store.
+// Failures that are legitimate answers or mistakes in the request, like the file not existing, listing a
+// file, or a cancelled context, are not retried and don't count against the primary.
+//
+// Once the primary fails, reads go straight to the fallback rather than waiting on the primary to fail
+// over and over. After the health check interval (see WithHealthCheckInterval), the next read checks the
+// primary using Ping(); if it's healthy again, we fail back to it automatically. Writes, moves, and
+// removals always go to the primary since the fallback is treated as read-only. You can supply the
+// WithClock() option to control the health checks in tests.
+//
+// Example:
+//
+//	files := filestore.Failover(filestore.Disk("/mnt/nfs/assets"), filestore.Disk("/var/replica/assets"))
+func Failover(primary FS, fallback FS, opts ...Option) FS {
+	options := newOptions(opts)
+	return &failoverFS{
+		FS:       primary,
+		fallback: fallback,
+		health:   &failoverHealth{clock: options.clock, interval: options.failover.interval, healthy: true},
+	}
+}
+
+type failoverFS struct {
+	FS
+	fallback FS
+	health   *failoverHealth
+}
+
+// failoverHealth tracks whether the primary store is healthy, shared by the original wrapper and any
+// instances derived from it.
+type failoverHealth struct {
+	mutex    sync.Mutex
+	clock    Clock
+	interval time.Duration
+	healthy  bool
+	checkAt  time.Time
+}
+
+// Read opens the file in the primary store, falling back to the fallback store if the primary fails.
+func (f *failoverFS) Read(filePath string) (ReaderFile, error) {
+	if f.usePrimary() {
+		file, err := f.FS.Read(filePath)
+		if !f.failed(err) {
+			return file, err
+		}
+	}
+	return f.fallback.Read(filePath)
+}
+
+// List reads the directory in the primary store, falling back to the fallback store if the primary fails.
+func (f *failoverFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	if f.usePrimary() {
+		infos, err := f.FS.List(dirPath, filters...)
+		if !f.failed(err) {
+			return infos, err
+		}
+	}
+	return f.fallback.List(dirPath, filters...)
+}
+
+// Stat fetches metadata about the file from the primary store, falling back to the fallback store if the
+// primary fails.
+func (f *failoverFS) Stat(filePath string) (FileInfo, error) {
+	if f.usePrimary() {
+		info, err := f.FS.Stat(filePath)
+		if !f.failed(err) {
+			return info, err
+		}
+	}
+	return f.fallback.Stat(filePath)
+}
+
+// Exists returns true when the file/directory exists in the primary store, or in the fallback store if
+// the primary fails.
+func (f *failoverFS) Exists(filePath string) bool {
+	_, err := f.Stat(filePath)
+	return err == nil
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory of both stores that shares the same
+// health status.
+func (f *failoverFS) ChangeDirectory(dir string) FS {
+	return &failoverFS{FS: f.FS.ChangeDirectory(dir), fallback: f.fallback.ChangeDirectory(dir), health: f.health}
+}
+
+func (f *failoverFS) withContext(ctx context.Context) FS {
+	return &failoverFS{FS: ForRequest(f.FS, ctx), fallback: ForRequest(f.fallback, ctx), health: f.health}
+}
+
+func (f *failoverFS) requestContext() context.Context {
+	return RequestContext(f.FS)
+}
+
+func (f *failoverFS) wrapped() []FS {
+	return []FS{f.FS, f.fallback}
+}
+
+// usePrimary returns true when we should try the primary store, checking its health first if it failed a
+// while ago.
+func (f *failoverFS) usePrimary() bool {
+	health := f.health
+	health.mutex.Lock()
+	if health.healthy || health.clock.Now().Before(health.checkAt) {
+		defer health.mutex.Unlock()
+		return health.healthy
+	}
+	// Make sure that nobody else checks while we are; they can use the fallback in the meantime.
+	health.checkAt = health.clock.Now().Add(health.interval)
+	health.mutex.Unlock()
+
+	if Ping(RequestContext(f.FS), f.FS) != nil {
+		return false
+	}
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+	health.healthy = true
+	return true
+}
+
+// failed returns true when the error means that the primary store isn't working (rather than a legit
+// answer like the file not existing or a mistake in the request), marking it as unhealthy.
+func (f *failoverFS) failed(err error) bool {
+	if !backendUnavailable(err) {
+		return false
+	}
+
+	health := f.health
+	health.mutex.Lock()
+	defer health.mutex.Unlock()
+	health.healthy = false
+	health.checkAt = health.clock.Now().Add(health.interval)
+	return true
+}
+
+var _ requestBinder = &failoverFS{}
+var _ wrapper = &failoverFS{}
@@ -0,0 +1,167 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type FailoverTestSuite struct {
+	suite.Suite
+	clock    *filestoretest.Clock
+	primary  *unavailableFS
+	fallback filestore.FS
+	fs       filestore.FS
+}
+
+func TestFailoverTestSuite(t *testing.T) {
+	suite.Run(t, &FailoverTestSuite{})
+}
+
+func (s *FailoverTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.primary = &unavailableFS{FS: filestore.Memory()}
+	s.fallback = filestore.Memory()
+	s.fs = filestore.Failover(s.primary, s.fallback, filestore.WithClock(s.clock), filestore.WithHealthCheckInterval(time.Minute))
+
+	s.Require().NoError(writeString(s.primary.FS, "assets/logo.png", "primary"))
+	s.Require().NoError(writeString(s.fallback, "assets/logo.png", "fallback"))
+	s.Require().NoError(writeString(s.fallback, "assets/stale.png", "fallback"))
+}
+
+func (s *FailoverTestSuite) TestRead() {
+	s.assertContent("assets/logo.png", "primary")
+	_, err := s.fs.Read("assets/stale.png")
+	s.Require().ErrorIs(err, fs.ErrNotExist, "Missing files should not fail over")
+	s.Require().False(s.fs.Exists("assets/stale.png"))
+
+	s.primary.down = true
+	s.assertContent("assets/logo.png", "fallback")
+	s.Require().True(s.fs.Exists("assets/stale.png"))
+	infos, err := s.fs.ChangeDirectory("assets").List(".")
+	s.Require().NoError(err)
+	s.Require().Len(infos, 2)
+}
+
+// Mistakes in the request would fail the same way on any store, so they shouldn't take the primary out.
+func (s *FailoverTestSuite) TestRead_requestErrors() {
+	_, err := s.fs.List("assets/logo.png")
+	s.Require().ErrorIs(err, filestore.ErrNotDirectory)
+	s.assertContent("assets/logo.png", "primary")
+
+	s.primary.failWith = context.Canceled
+	_, err = s.fs.Read("assets/logo.png")
+	s.Require().ErrorIs(err, context.Canceled)
+	s.primary.failWith = &fs.PathError{Op: "open", Path: "assets/logo.png", Err: context.DeadlineExceeded}
+	_, err = s.fs.Read("assets/logo.png")
+	s.Require().ErrorIs(err, context.DeadlineExceeded)
+	s.primary.failWith = fs.ErrPermission
+	_, err = s.fs.Read("assets/logo.png")
+	s.Require().ErrorIs(err, fs.ErrPermission)
+
+	s.primary.failWith = nil
+	s.assertContent("assets/logo.png", "primary")
+}
+
+func (s *FailoverTestSuite) TestFailBack() {
+	s.primary.down = true
+	s.assertContent("assets/logo.png", "fallback")
+	s.Require().Equal(1, s.primary.calls)
+
+	// Don't keep hammering the primary until it's time to check on it again.
+	s.primary.down = false
+	s.assertContent("assets/logo.png", "fallback")
+	s.Require().Equal(1, s.primary.calls)
+
+	s.clock.Advance(time.Minute)
+	s.primary.down = true
+	s.assertContent("assets/logo.png", "fallback")
+	s.Require().Equal(2, s.primary.calls, "Should only ping the primary")
+
+	s.primary.down = false
+	s.clock.Advance(30 * time.Second)
+	s.assertContent("assets/logo.png", "fallback")
+	s.clock.Advance(30 * time.Second)
+	s.assertContent("assets/logo.png", "primary")
+}
+
+func (s *FailoverTestSuite) TestWrite() {
+	s.primary.down = true
+	s.Require().Error(writeString(s.fs, "assets/new.png", "new"), "Writes should not fail over")
+	s.Require().False(s.fallback.Exists("assets/new.png"))
+
+	s.primary.down = false
+	s.Require().NoError(writeString(s.fs, "assets/new.png", "new"))
+	s.Require().True(s.primary.FS.Exists("assets/new.png"))
+	s.Require().False(s.fallback.Exists("assets/new.png"))
+}
+
+func (s *FailoverTestSuite) assertContent(filePath string, expected string) {
+	content, err := readString(s.fs, filePath)
+	s.Require().NoError(err)
+	s.Require().Equal(expected, content)
+}
+
+// unavailableFS fails every operation while it's down, like a disconnected NFS mount, counting how
+// many times it was asked to do something. Setting failWith fails every operation w/ that error instead.
+type unavailableFS struct {
+	filestore.FS
+	down     bool
+	failWith error
+	calls    int
+}
+
+var errUnavailable = errors.New("stale file handle")
+
+func (f *unavailableFS) check() error {
+	f.calls++
+	if f.failWith != nil {
+		return f.failWith
+	}
+	if f.down {
+		return errUnavailable
+	}
+	return nil
+}
+
+func (f *unavailableFS) Read(filePath string) (filestore.ReaderFile, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	return f.FS.Read(filePath)
+}
+
+func (f *unavailableFS) Write(filePath string) (filestore.WriterFile, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	return f.FS.Write(filePath)
+}
+
+func (f *unavailableFS) Stat(filePath string) (filestore.FileInfo, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	return f.FS.Stat(filePath)
+}
+
+func (f *unavailableFS) List(dirPath string, filters ...filestore.FileFilter) ([]filestore.FileInfo, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	return f.FS.List(dirPath, filters...)
+}
+
+func (f *unavailableFS) Ping(ctx context.Context) error {
+	return f.check()
+}
+
+func (f *unavailableFS) ChangeDirectory(dir string) filestore.FS {
+	return &unavailableFS{FS: f.FS.ChangeDirectory(dir), down: f.down}
+}
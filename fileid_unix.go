@@ -0,0 +1,16 @@
+//go:build unix
+
+package filestore
+
+import (
+	"syscall"
+)
+
+// sysFileID extracts the device/inode pair and link count from the platform-specific 'stat' info.
+func sysFileID(sys any) (fileID, uint64, bool) {
+	stat, ok := sys.(*syscall.Stat_t)
+	if !ok || stat == nil {
+		return fileID{}, 0, false
+	}
+	return fileID{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}, uint64(stat.Nlink), true
+}
@@ -0,0 +1,415 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+)
+
+// fileIDIndexName is the name of the hidden file, in the root of the wrapped store, where FileIDs() keeps
+// track of the identifiers it assigned when the store can't give us inode numbers.
+const fileIDIndexName = ".fileids.json"
+
+// FileIdentifier is an optional capability for stores that give each file an identifier that stays the
+// same when the file is renamed or moved, so you can keep track of files w/o relying on their paths.
+type FileIdentifier interface {
+	// FileID returns the file's stable identifier.
+	FileID(path string) (string, error)
+	// ResolveFileID returns the current path (relative to the store's working directory) of the file
+	// w/ the given identifier.
+	ResolveFileID(id string) (string, error)
+}
+
+// ErrFileIDsNotSupported is the error returned by FileID() and ResolveFileID() when the store does not
+// implement the FileIdentifier capability.
+var ErrFileIDsNotSupported = errors.New("filestore: file IDs not supported")
+
+// FileID returns the stable identifier of the file if the store supports the FileIdentifier capability
+// (see FileIDs()). For all other stores, this fails w/ ErrFileIDsNotSupported.
+//
+// Example:
+//
+//	id, err := filestore.FileID(files, "inbox/report.pdf")
+//	...
+//	index.Put(id, extractText(files, "inbox/report.pdf"))
+func FileID(fs FS, filePath string) (string, error) {
+	if identifier, ok := fs.(FileIdentifier); ok {
+		return identifier.FileID(filePath)
+	}
+	return "", fmt.Errorf("filestore: file id: %s: %w", filePath, ErrFileIDsNotSupported)
+}
+
+// ResolveFileID returns the current path of the file w/ the given identifier if the store supports the
+// FileIdentifier capability. When the file no longer exists (or lives outside of the store's working
+// directory), this fails w/ fs.ErrNotExist. For all other stores, this fails w/ ErrFileIDsNotSupported.
+//
+// Example:
+//
+//	// The file may have been renamed since we indexed it.
+//	filePath, err := filestore.ResolveFileID(files, id)
+func ResolveFileID(fs FS, id string) (string, error) {
+	if identifier, ok := fs.(FileIdentifier); ok {
+		return identifier.ResolveFileID(id)
+	}
+	return "", fmt.Errorf("filestore: resolve file id: %s: %w", id, ErrFileIDsNotSupported)
+}
+
+// FileIDs wraps a file store so that it supports the FileIdentifier capability, giving each file an
+// identifier that survives renames. Which kind of identifiers you get depends on the store:
+//
+//   - When the store exposes inode numbers (e.g. Disk() on Linux/macOS), a file's ID is derived from its
+//     device and inode, so it stays the same however the file is renamed, even by other programs. Resolving
+//     an ID we haven't seen before searches the store for the file, so that can be slow for large trees.
+//     Like inodes themselves, the ID of a deleted file may be reused by a new one.
+//   - For every other store, we assign a random ID the first time you ask for one, and keep track of them in
+//     a hidden ".fileids.json" file in the root of the store. Moving or removing files through the wrapper
+//     keeps the index up to date, but we can't know about changes made some other way (or by another
+//     process using the same store), so the ID of a file that was renamed behind our back is lost.
+//
+// Example:
+//
+//	files := filestore.FileIDs(filestore.Disk("/srv/documents"))
+//	id, err := filestore.FileID(files, "inbox/report.pdf")
+//	...
+//	err = files.Move("inbox/report.pdf", "archive/2022/report.pdf")
+//	filePath, err := filestore.ResolveFileID(files, id) // "archive/2022/report.pdf"
+func FileIDs(fs FS) FS {
+	index := &fileIDIndex{root: fs, paths: map[string]string{}}
+	if info, err := fs.Stat("."); err == nil {
+		_, _, index.inodes = sysFileID(info.Sys())
+	}
+	return &fileIDFS{FS: fs, index: index}
+}
+
+func init() {
+	RegisterLayer("file_ids", func(fs FS, _ LayerOptions) (FS, error) {
+		return FileIDs(fs), nil
+	})
+}
+
+// fileIDIndex is the state shared by a FileIDs() wrapper and every store derived from it. Paths are all
+// relative to the root of the wrapped store.
+type fileIDIndex struct {
+	mutex sync.Mutex
+	// root is the store that we wrapped, w/ its original working directory.
+	root FS
+	// inodes is true when the store gives us inode numbers, so we don't need to keep track of anything.
+	inodes bool
+	// paths maps each ID to the path of its file. For inode-based IDs, these are just hints.
+	paths  map[string]string
+	loaded bool
+}
+
+type fileIDFS struct {
+	FS
+	index *fileIDIndex
+}
+
+// relativePath converts a path relative to this store's working directory into one relative to the root.
+func (f *fileIDFS) relativePath(filePath string) string {
+	return relativeTo(f.index.root.WorkingDirectory(), joinPath(f.FS.WorkingDirectory(), filePath))
+}
+
+// FileID returns the file's stable identifier, assigning one if necessary.
+func (f *fileIDFS) FileID(filePath string) (string, error) {
+	info, err := f.FS.Stat(filePath)
+	if err != nil {
+		return "", fmt.Errorf("filestore: file id: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("filestore: file id: %s: is a directory", filePath)
+	}
+
+	index := f.index
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	relativePath := f.relativePath(filePath)
+	if index.inodes {
+		id, _, ok := sysFileID(info.Sys())
+		if !ok {
+			return "", fmt.Errorf("filestore: file id: %s: no inode available", filePath)
+		}
+		key := formatInodeID(id)
+		index.paths[key] = relativePath
+		return key, nil
+	}
+
+	if err := index.load(); err != nil {
+		return "", fmt.Errorf("filestore: file id: %w", err)
+	}
+	for id, idPath := range index.paths {
+		if idPath == relativePath {
+			return id, nil
+		}
+	}
+	id, err := UniqueName("")
+	if err != nil {
+		return "", fmt.Errorf("filestore: file id: %w", err)
+	}
+	index.paths[id] = relativePath
+	if err := index.save(); err != nil {
+		delete(index.paths, id)
+		return "", fmt.Errorf("filestore: file id: %w", err)
+	}
+	return id, nil
+}
+
+// ResolveFileID returns the current path of the file w/ the given identifier.
+func (f *fileIDFS) ResolveFileID(id string) (string, error) {
+	index := f.index
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	var relativePath string
+	var err error
+	if index.inodes {
+		relativePath, err = index.findInode(id)
+	} else {
+		relativePath, err = index.find(id)
+	}
+	if err != nil {
+		return "", fmt.Errorf("filestore: resolve file id: %s: %w", id, err)
+	}
+
+	// Convert the path to one relative to this store's working directory.
+	workingDirectory := relativeTo(index.root.WorkingDirectory(), f.FS.WorkingDirectory())
+	switch {
+	case workingDirectory == ".":
+		return relativePath, nil
+	case strings.HasPrefix(relativePath, workingDirectory+"/"):
+		return relativePath[len(workingDirectory)+1:], nil
+	default:
+		return "", fmt.Errorf("filestore: resolve file id: %s: %w", id, fs.ErrNotExist)
+	}
+}
+
+// List performs the equivalent of the "ls" command, leaving out our hidden index file.
+func (f *fileIDFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	infos, err := f.FS.List(dirPath, filters...)
+	if err != nil || f.index.inodes || f.relativePath(dirPath) != "." {
+		return infos, err
+	}
+	results := infos[:0]
+	for _, info := range infos {
+		if info.Name() != fileIDIndexName {
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// Move relocates the file/directory, updating the paths of any IDs we've assigned to the files inside.
+func (f *fileIDFS) Move(fromPath string, toPath string) error {
+	if err := f.FS.Move(fromPath, toPath); err != nil {
+		return err
+	}
+
+	index := f.index
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	// A new wrapper (e.g. after a restart) might not have read the index yet, and we don't want to
+	// overwrite it w/ an empty one.
+	if !index.inodes {
+		if err := index.load(); err != nil {
+			return fmt.Errorf("filestore: file ids: move: %w", err)
+		}
+	}
+
+	from, to := f.relativePath(fromPath), f.relativePath(toPath)
+	changed := false
+	for id, idPath := range index.paths {
+		switch {
+		case idPath == to && !index.inodes:
+			// The file that used to be here was overwritten, so its ID no longer refers to anything.
+			delete(index.paths, id)
+			changed = true
+		case idPath == from:
+			index.paths[id] = to
+			changed = true
+		case strings.HasPrefix(idPath, from+"/"):
+			index.paths[id] = to + idPath[len(from):]
+			changed = true
+		}
+	}
+	if changed && !index.inodes {
+		if err := index.save(); err != nil {
+			return fmt.Errorf("filestore: file ids: move: %w", err)
+		}
+	}
+	return nil
+}
+
+// Remove deletes the file/directory, forgetting the IDs of everything in it.
+func (f *fileIDFS) Remove(fileOrDirPath string) error {
+	if err := f.FS.Remove(fileOrDirPath); err != nil {
+		return err
+	}
+
+	index := f.index
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	// A new wrapper (e.g. after a restart) might not have read the index yet, and we don't want to
+	// overwrite it w/ an empty one.
+	if !index.inodes {
+		if err := index.load(); err != nil {
+			return fmt.Errorf("filestore: file ids: remove: %w", err)
+		}
+	}
+
+	removed := f.relativePath(fileOrDirPath)
+	changed := false
+	for id, idPath := range index.paths {
+		if removed == "." || idPath == removed || strings.HasPrefix(idPath, removed+"/") {
+			delete(index.paths, id)
+			changed = true
+		}
+	}
+	if changed && !index.inodes {
+		if err := index.save(); err != nil {
+			return fmt.Errorf("filestore: file ids: remove: %w", err)
+		}
+	}
+	return nil
+}
+
+func (f *fileIDFS) ChangeDirectory(dir string) FS {
+	return &fileIDFS{FS: f.FS.ChangeDirectory(dir), index: f.index}
+}
+
+func (f *fileIDFS) withContext(ctx context.Context) FS {
+	return &fileIDFS{FS: ForRequest(f.FS, ctx), index: f.index}
+}
+
+func (f *fileIDFS) requestContext() context.Context {
+	return RequestContext(f.FS)
+}
+
+func (f *fileIDFS) wrapped() []FS {
+	return []FS{f.FS}
+}
+
+// load reads the index file the first time we need it. The mutex must be locked.
+func (index *fileIDIndex) load() error {
+	if index.loaded {
+		return nil
+	}
+	file, err := index.root.Read(fileIDIndexName)
+	if errors.Is(err, fs.ErrNotExist) {
+		index.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &index.paths); err != nil {
+		return fmt.Errorf("%s: %w", fileIDIndexName, err)
+	}
+	index.loaded = true
+	return nil
+}
+
+// save writes the entire index back to the store. The mutex must be locked.
+func (index *fileIDIndex) save() error {
+	data, err := json.Marshal(index.paths)
+	if err != nil {
+		return err
+	}
+	file, err := index.root.Write(fileIDIndexName)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// find looks up the path of a file in the index, making sure that it still exists. The mutex must be locked.
+func (index *fileIDIndex) find(id string) (string, error) {
+	if err := index.load(); err != nil {
+		return "", err
+	}
+	idPath, ok := index.paths[id]
+	if !ok {
+		return "", fs.ErrNotExist
+	}
+	if info, err := index.root.Stat(idPath); err != nil || info.IsDir() {
+		return "", fs.ErrNotExist
+	}
+	return idPath, nil
+}
+
+// findInode finds the file whose device/inode matches the ID. We check the last path we saw it at first,
+// but if it's not there anymore, we have to search the whole store for it. The mutex must be locked.
+func (index *fileIDIndex) findInode(id string) (string, error) {
+	if hint, ok := index.paths[id]; ok {
+		if info, err := index.root.Stat(hint); err == nil && inodeIDMatches(info, id) {
+			return hint, nil
+		}
+		delete(index.paths, id)
+	}
+
+	errFound := errors.New("found")
+	var found string
+	err := Walk(index.root, ".", func(filePath string, info FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() && inodeIDMatches(info, id) {
+			found = filePath
+			return errFound
+		}
+		return nil
+	})
+	if !errors.Is(err, errFound) {
+		return "", fs.ErrNotExist
+	}
+	index.paths[id] = found
+	return found, nil
+}
+
+// formatInodeID converts the device/inode pair into the string that we use as the file's ID.
+func formatInodeID(id fileID) string {
+	return fmt.Sprintf("%x-%x", id.dev, id.ino)
+}
+
+// inodeIDMatches returns true when the file's device/inode pair is the given ID.
+func inodeIDMatches(info FileInfo, id string) bool {
+	fileID, _, ok := sysFileID(info.Sys())
+	return ok && formatInodeID(fileID) == id
+}
+
+// relativeTo converts the absolute path into one relative to the base path ("." when they're the same).
+func relativeTo(basePath string, fullPath string) string {
+	basePath, fullPath = path.Clean(basePath), path.Clean(fullPath)
+	switch {
+	case fullPath == basePath:
+		return "."
+	case basePath == "/" || basePath == ".":
+		return strings.TrimPrefix(fullPath, "/")
+	case strings.HasPrefix(fullPath, basePath+"/"):
+		return fullPath[len(basePath)+1:]
+	default:
+		return fullPath
+	}
+}
+
+var _ FileIdentifier = &fileIDFS{}
+var _ requestBinder = &fileIDFS{}
+var _ wrapper = &fileIDFS{}
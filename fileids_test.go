@@ -0,0 +1,139 @@
+package filestore_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type FileIDsTestSuite struct {
+	suite.Suite
+}
+
+func TestFileIDsTestSuite(t *testing.T) {
+	suite.Run(t, &FileIDsTestSuite{})
+}
+
+func (s *FileIDsTestSuite) TestNotSupported() {
+	_, err := filestore.FileID(filestore.Memory(), "foo.txt")
+	s.Require().ErrorIs(err, filestore.ErrFileIDsNotSupported)
+	_, err = filestore.ResolveFileID(filestore.Memory(), "1234")
+	s.Require().ErrorIs(err, filestore.ErrFileIDsNotSupported)
+}
+
+func (s *FileIDsTestSuite) TestIndex() {
+	inner := filestore.Memory()
+	files := filestore.FileIDs(inner)
+	s.Require().NoError(writeString(files, "inbox/report.pdf", "report"))
+	s.Require().NoError(writeString(files, "inbox/notes.txt", "notes"))
+
+	id, err := filestore.FileID(files, "inbox/report.pdf")
+	s.Require().NoError(err)
+	again, err := filestore.FileID(files, "inbox/report.pdf")
+	s.Require().NoError(err)
+	s.Require().Equal(id, again, "Should reuse the same ID")
+	notesID, err := filestore.FileID(files, "inbox/notes.txt")
+	s.Require().NoError(err)
+	s.Require().NotEqual(id, notesID)
+
+	s.Require().NoError(files.Move("inbox/report.pdf", "archive/report.pdf"))
+	s.assertResolves(files, id, "archive/report.pdf")
+	s.Require().NoError(files.Move("archive", "2022/archive"))
+	s.assertResolves(files, id, "2022/archive/report.pdf")
+
+	// Overwriting a file means its ID now belongs to nothing.
+	s.Require().NoError(files.Move("2022/archive/report.pdf", "inbox/notes.txt"))
+	s.assertResolves(files, id, "inbox/notes.txt")
+	s.assertMissing(files, notesID)
+
+	s.Require().NoError(files.Remove("inbox"))
+	s.assertMissing(files, id)
+
+	// The index should survive a brand new wrapper over the same store.
+	s.Require().NoError(writeString(files, "docs/readme.md", "abide"))
+	id, err = filestore.FileID(files, "docs/readme.md")
+	s.Require().NoError(err)
+	s.assertResolves(filestore.FileIDs(inner), id, "docs/readme.md")
+
+	// Brand new wrappers should also keep the existing index up to date.
+	s.Require().NoError(filestore.FileIDs(inner).Move("docs/readme.md", "docs/README.md"))
+	s.assertResolves(filestore.FileIDs(inner), id, "docs/README.md")
+	s.Require().NoError(filestore.FileIDs(inner).Remove("docs"))
+	s.assertMissing(filestore.FileIDs(inner), id)
+
+	infos, err := files.List(".")
+	s.Require().NoError(err)
+	s.Require().Len(infos, 1, "Should hide the index file")
+	s.Require().True(inner.Exists(".fileids.json"))
+}
+
+func (s *FileIDsTestSuite) TestInodes() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("no inodes on windows")
+	}
+	root := s.T().TempDir()
+	files := filestore.FileIDs(filestore.Disk(root))
+	s.Require().NoError(writeString(files, "inbox/report.pdf", "report"))
+
+	id, err := filestore.FileID(files, "inbox/report.pdf")
+	s.Require().NoError(err)
+	s.Require().NoError(files.Move("inbox/report.pdf", "archive/report.pdf"))
+	s.assertResolves(files, id, "archive/report.pdf")
+
+	// Renames outside of the wrapper should still be found.
+	s.Require().NoError(os.MkdirAll(filepath.Join(root, "2022"), 0o755))
+	s.Require().NoError(os.Rename(filepath.Join(root, "archive/report.pdf"), filepath.Join(root, "2022/report.pdf")))
+	s.assertResolves(files, id, "2022/report.pdf")
+	s.assertResolves(filestore.FileIDs(filestore.Disk(root)), id, "2022/report.pdf")
+
+	s.Require().NoError(files.Remove("2022/report.pdf"))
+	s.assertMissing(files, id)
+	s.Require().False(filestore.Disk(root).Exists(".fileids.json"), "Should not need an index")
+}
+
+func (s *FileIDsTestSuite) TestChangeDirectory() {
+	files := filestore.FileIDs(filestore.Memory())
+	s.Require().NoError(writeString(files, "inbox/report.pdf", "report"))
+
+	inbox := files.ChangeDirectory("inbox")
+	id, err := filestore.FileID(inbox, "report.pdf")
+	s.Require().NoError(err)
+	s.assertResolves(files, id, "inbox/report.pdf")
+	s.assertResolves(inbox, id, "report.pdf")
+
+	s.Require().NoError(files.Move("inbox/report.pdf", "archive/report.pdf"))
+	s.assertMissing(inbox, id)
+	s.assertResolves(files.ChangeDirectory("archive"), id, "report.pdf")
+
+	_, err = filestore.FileID(files, "archive")
+	s.Require().Error(err, "Directories should not have IDs")
+}
+
+func (s *FileIDsTestSuite) TestFromConfig() {
+	files, err := filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "file_ids"}},
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(files, "report.pdf", "report"))
+	id, err := filestore.FileID(files, "report.pdf")
+	s.Require().NoError(err)
+	s.assertResolves(files, id, "report.pdf")
+}
+
+func (s *FileIDsTestSuite) assertResolves(files filestore.FS, id string, expected string) {
+	filePath, err := filestore.ResolveFileID(files, id)
+	s.Require().NoError(err)
+	s.Require().Equal(expected, filePath)
+}
+
+func (s *FileIDsTestSuite) assertMissing(files filestore.FS, id string) {
+	_, err := filestore.ResolveFileID(files, id)
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Expected not exist, got %v", err)
+}
@@ -0,0 +1,109 @@
+// Package filestoretest provides helpers and test doubles for exercising code that
+// uses the filestore package w/o relying on real time, real randomness, or real backends.
+package filestoretest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// NewClock creates a fake clock whose current time is 'start'. Time never moves on its own; it
+// only changes when you call Advance() or Set(). It satisfies the filestore.Clock interface, so
+// you can feed it to any store/wrapper using filestore.WithClock().
+//
+// Example:
+//
+//	clock := filestoretest.NewClock(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+//	files := filestore.Memory(filestore.WithClock(clock))
+//	...
+//	clock.Advance(25 * time.Hour) // a day (and then some) goes by instantly
+func NewClock(start time.Time) *Clock {
+	clock := &Clock{now: start}
+	clock.cond = sync.NewCond(&clock.mutex)
+	return clock
+}
+
+// Clock is a fake, manually controlled source of time. It is safe for concurrent use.
+type Clock struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []clockWaiter
+}
+
+// clockWaiter is a pending After() call that fires once the clock reaches its deadline.
+type clockWaiter struct {
+	deadline time.Time
+	channel  chan time.Time
+}
+
+// Now returns the clock's current (fake) time.
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the clock's time once it has been advanced by
+// at least 'd'. Non-positive durations fire immediately.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	channel := make(chan time.Time, 1)
+	if d <= 0 {
+		channel <- c.now
+		return channel
+	}
+	c.waiters = append(c.waiters, clockWaiter{deadline: c.now.Add(d), channel: channel})
+	c.cond.Broadcast()
+	return channel
+}
+
+// Advance moves the clock forward by the given duration, firing any After() channels
+// whose deadlines have now passed.
+func (c *Clock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.setLocked(c.now.Add(d))
+}
+
+// Set moves the clock to the given time, firing any After() channels whose deadlines
+// have now passed. Setting the clock backwards will not un-fire anything.
+func (c *Clock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.setLocked(t)
+}
+
+// BlockUntil waits until at least 'n' goroutines are blocked waiting on After() channels. This lets
+// your test ensure that a background worker is actually waiting on the clock before you call
+// Advance(); otherwise you may advance the time before it has a chance to start waiting.
+func (c *Clock) BlockUntil(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *Clock) setLocked(t time.Time) {
+	c.now = t
+
+	// Fire waiters in deadline order so that consumers listening on multiple
+	// channels observe them in a sensible sequence.
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].deadline.Before(c.waiters[j].deadline)
+	})
+
+	remaining := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.deadline.After(c.now) {
+			remaining = append(remaining, waiter)
+			continue
+		}
+		waiter.channel <- c.now
+	}
+	c.waiters = remaining
+}
@@ -0,0 +1,91 @@
+package filestoretest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClockTestSuite struct {
+	suite.Suite
+}
+
+func TestClockTestSuite(t *testing.T) {
+	suite.Run(t, &ClockTestSuite{})
+}
+
+var start = time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC)
+
+func (s *ClockTestSuite) TestNow() {
+	var clock filestore.Clock = filestoretest.NewClock(start)
+	s.Require().Equal(start, clock.Now())
+	s.Require().Equal(start, clock.Now(), "Time should not move on its own")
+}
+
+func (s *ClockTestSuite) TestAdvance() {
+	clock := filestoretest.NewClock(start)
+	clock.Advance(time.Hour)
+	s.Require().Equal(start.Add(time.Hour), clock.Now())
+	clock.Advance(30 * time.Minute)
+	s.Require().Equal(start.Add(90*time.Minute), clock.Now())
+}
+
+func (s *ClockTestSuite) TestSet() {
+	clock := filestoretest.NewClock(start)
+	clock.Set(start.Add(-time.Hour))
+	s.Require().Equal(start.Add(-time.Hour), clock.Now())
+}
+
+func (s *ClockTestSuite) TestAfter() {
+	clock := filestoretest.NewClock(start)
+
+	immediate := clock.After(0)
+	s.Require().Equal(start, s.receive(immediate), "Non-positive durations should fire immediately")
+
+	ch1 := clock.After(time.Minute)
+	ch2 := clock.After(time.Hour)
+
+	clock.Advance(59 * time.Second)
+	s.Require().False(s.ready(ch1), "Should not fire before deadline")
+
+	clock.Advance(time.Second)
+	s.Require().Equal(start.Add(time.Minute), s.receive(ch1))
+	s.Require().False(s.ready(ch2), "Should not fire before deadline")
+
+	clock.Set(start.Add(2 * time.Hour))
+	s.Require().Equal(start.Add(2*time.Hour), s.receive(ch2))
+}
+
+func (s *ClockTestSuite) TestBlockUntil() {
+	clock := filestoretest.NewClock(start)
+	done := make(chan time.Time)
+	go func() {
+		done <- <-clock.After(time.Minute)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	s.Require().Equal(start.Add(time.Minute), <-done)
+}
+
+func (s *ClockTestSuite) ready(ch <-chan time.Time) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *ClockTestSuite) receive(ch <-chan time.Time) time.Time {
+	select {
+	case t := <-ch:
+		return t
+	default:
+		s.FailNow("Channel should have fired")
+		return time.Time{}
+	}
+}
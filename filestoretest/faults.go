@@ -0,0 +1,280 @@
+package filestoretest
+
+import (
+	"io"
+	"path"
+	"sync"
+
+	"github.com/monadicstack/filestore"
+)
+
+// NewFaultFS wraps a real file store (typically filestore.Memory()) so that you can program it to
+// misbehave in very specific ways. This lets you deterministically exercise the error handling paths
+// of code that uses a file store, which is otherwise nearly impossible to do w/ a real backend.
+//
+// Operations are named the same way as a Recorder's interactions: "stat", "exists", "read", "write",
+// "list", "remove", and "move". Paths are relative to the root of the FaultFS you created, even when
+// the code under test uses an instance derived via ChangeDirectory(). Anything you haven't programmed
+// to fail simply passes through to the wrapped store.
+//
+// Example:
+//
+//	errDiskFull := errors.New("disk full")
+//	files := filestoretest.NewFaultFS(filestore.Memory())
+//	files.FailPath("write", "reports/today.csv", errDiskFull)
+//	files.FailNth(3, errDiskFull)
+//	files.ShortWrites("reports/yesterday.csv", 10)
+//	runTheCodeUnderTest(files)
+func NewFaultFS(fs filestore.FS) *FaultFS {
+	return &FaultFS{
+		fs:  fs,
+		dir: ".",
+		faults: &faults{
+			nth:         map[int]error{},
+			paths:       map[string]error{},
+			shortReads:  map[string]int{},
+			shortWrites: map[string]int{},
+		},
+	}
+}
+
+// FaultFS is an FS that fails operations however you programmed it to.
+type FaultFS struct {
+	fs     filestore.FS
+	faults *faults
+	dir    string
+}
+
+// faults contains the programmed failures shared by a FaultFS and all instances derived from it via ChangeDirectory().
+type faults struct {
+	mutex sync.Mutex
+	// count is the number of operations performed so far.
+	count int
+	// nth maps the (1-based) number of an operation to the error it should fail with.
+	nth map[int]error
+	// paths maps an "op path" key (or just the path for any op) to the error it should fail with.
+	paths       map[string]error
+	shortReads  map[string]int
+	shortWrites map[string]int
+}
+
+// FailNth makes the nth operation performed from now on fail w/ the given error. For instance, n=1
+// fails the very next operation, regardless of which operation or path it is. Only that one operation
+// fails; subsequent ones behave normally (unless you programmed them to fail, too).
+func (f *FaultFS) FailNth(n int, err error) {
+	f.faults.mutex.Lock()
+	defer f.faults.mutex.Unlock()
+	f.faults.nth[f.faults.count+n] = err
+}
+
+// FailPath makes every call to the given operation on the given path fail w/ the given error until you
+// call FailPath() again w/ a nil error. Use an empty op to fail every operation on the path. For "move"
+// operations, this only looks at the path you're moving from.
+func (f *FaultFS) FailPath(op string, filePath string, err error) {
+	f.faults.mutex.Lock()
+	defer f.faults.mutex.Unlock()
+
+	key := faultKey(op, path.Clean(filePath))
+	if err == nil {
+		delete(f.faults.paths, key)
+		return
+	}
+	f.faults.paths[key] = err
+}
+
+// ShortReads makes each Read()/ReadAt() call on the file return at most n bytes, so you can make sure
+// that your code doesn't assume that a single read fills the whole buffer. Reads are short in a way that
+// satisfies the io.Reader/io.ReaderAt contracts, so ReadAt() reports io.ErrUnexpectedEOF when it comes up
+// short. Pass n <= 0 to go back to normal reads.
+func (f *FaultFS) ShortReads(filePath string, n int) {
+	f.faults.mutex.Lock()
+	defer f.faults.mutex.Unlock()
+	setLimit(f.faults.shortReads, path.Clean(filePath), n)
+}
+
+// ShortWrites makes each Write()/WriteAt() call on the file write at most n bytes and then fail w/
+// io.ErrShortWrite, so you can make sure that your code checks for partial writes. Pass n <= 0 to go
+// back to normal writes.
+func (f *FaultFS) ShortWrites(filePath string, n int) {
+	f.faults.mutex.Lock()
+	defer f.faults.mutex.Unlock()
+	setLimit(f.faults.shortWrites, path.Clean(filePath), n)
+}
+
+// Count returns the number of operations performed through this FS (and any derived from it) so far.
+func (f *FaultFS) Count() int {
+	f.faults.mutex.Lock()
+	defer f.faults.mutex.Unlock()
+	return f.faults.count
+}
+
+// check counts the operation and returns the error it should fail with, if any.
+func (f *FaultFS) check(op string, filePath string) error {
+	f.faults.mutex.Lock()
+	defer f.faults.mutex.Unlock()
+
+	f.faults.count++
+	if err, ok := f.faults.nth[f.faults.count]; ok {
+		delete(f.faults.nth, f.faults.count)
+		return err
+	}
+	if err, ok := f.faults.paths[faultKey(op, filePath)]; ok {
+		return err
+	}
+	return f.faults.paths[faultKey("", filePath)]
+}
+
+// limits returns the short read/write limits for the file; zero means no limit.
+func (f *FaultFS) limits(filePath string) (int, int) {
+	f.faults.mutex.Lock()
+	defer f.faults.mutex.Unlock()
+	return f.faults.shortReads[filePath], f.faults.shortWrites[filePath]
+}
+
+// key converts a path relative to this instance's directory into a path relative to the original FaultFS.
+func (f *FaultFS) key(filePath string) string {
+	return path.Join(f.dir, filePath)
+}
+
+func (f *FaultFS) WorkingDirectory() string {
+	return f.fs.WorkingDirectory()
+}
+
+func (f *FaultFS) ChangeDirectory(dir string) filestore.FS {
+	return &FaultFS{fs: f.fs.ChangeDirectory(dir), faults: f.faults, dir: f.key(dir)}
+}
+
+func (f *FaultFS) Stat(filePath string) (filestore.FileInfo, error) {
+	if err := f.check("stat", f.key(filePath)); err != nil {
+		return nil, err
+	}
+	return f.fs.Stat(filePath)
+}
+
+// Exists returns false when you programmed the operation to fail, since it has no other way to report errors.
+func (f *FaultFS) Exists(filePath string) bool {
+	if err := f.check("exists", f.key(filePath)); err != nil {
+		return false
+	}
+	return f.fs.Exists(filePath)
+}
+
+func (f *FaultFS) Read(filePath string) (filestore.ReaderFile, error) {
+	key := f.key(filePath)
+	if err := f.check("read", key); err != nil {
+		return nil, err
+	}
+	file, err := f.fs.Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if limit, _ := f.limits(key); limit > 0 {
+		return &shortReaderFile{ReaderFile: file, limit: limit}, nil
+	}
+	return file, nil
+}
+
+func (f *FaultFS) Write(filePath string) (filestore.WriterFile, error) {
+	key := f.key(filePath)
+	if err := f.check("write", key); err != nil {
+		return nil, err
+	}
+	file, err := f.fs.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if _, limit := f.limits(key); limit > 0 {
+		return &shortWriterFile{WriterFile: file, limit: limit}, nil
+	}
+	return file, nil
+}
+
+func (f *FaultFS) List(dirPath string, filters ...filestore.FileFilter) ([]filestore.FileInfo, error) {
+	if err := f.check("list", f.key(dirPath)); err != nil {
+		return nil, err
+	}
+	return f.fs.List(dirPath, filters...)
+}
+
+func (f *FaultFS) Remove(fileOrDirPath string) error {
+	if err := f.check("remove", f.key(fileOrDirPath)); err != nil {
+		return err
+	}
+	return f.fs.Remove(fileOrDirPath)
+}
+
+func (f *FaultFS) Move(fromPath string, toPath string) error {
+	if err := f.check("move", f.key(fromPath)); err != nil {
+		return err
+	}
+	return f.fs.Move(fromPath, toPath)
+}
+
+func faultKey(op string, filePath string) string {
+	if op == "" {
+		return filePath
+	}
+	return op + " " + filePath
+}
+
+func setLimit(limits map[string]int, filePath string, n int) {
+	if n <= 0 {
+		delete(limits, filePath)
+		return
+	}
+	limits[filePath] = n
+}
+
+// shortReaderFile never reads more than 'limit' bytes at a time.
+type shortReaderFile struct {
+	filestore.ReaderFile
+	limit int
+}
+
+func (r *shortReaderFile) Read(p []byte) (int, error) {
+	if len(p) > r.limit {
+		p = p[:r.limit]
+	}
+	return r.ReaderFile.Read(p)
+}
+
+func (r *shortReaderFile) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) <= r.limit {
+		return r.ReaderFile.ReadAt(p, off)
+	}
+	n, err := r.ReaderFile.ReadAt(p[:r.limit], off)
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// shortWriterFile never writes more than 'limit' bytes at a time.
+type shortWriterFile struct {
+	filestore.WriterFile
+	limit int
+}
+
+func (w *shortWriterFile) Write(p []byte) (int, error) {
+	if len(p) <= w.limit {
+		return w.WriterFile.Write(p)
+	}
+	n, err := w.WriterFile.Write(p[:w.limit])
+	if err == nil {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+func (w *shortWriterFile) WriteAt(p []byte, off int64) (int, error) {
+	if len(p) <= w.limit {
+		return w.WriterFile.WriteAt(p, off)
+	}
+	n, err := w.WriterFile.WriteAt(p[:w.limit], off)
+	if err == nil {
+		err = io.ErrShortWrite
+	}
+	return n, err
+}
+
+var _ filestore.FS = &FaultFS{}
@@ -0,0 +1,106 @@
+package filestoretest_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type FaultFSTestSuite struct {
+	suite.Suite
+	files *filestoretest.FaultFS
+}
+
+func TestFaultFSTestSuite(t *testing.T) {
+	suite.Run(t, &FaultFSTestSuite{})
+}
+
+var errBoom = errors.New("boom")
+
+func (s *FaultFSTestSuite) SetupTest() {
+	s.files = filestoretest.NewFaultFS(filestore.Memory())
+	s.write(s.files, "data/a.txt", "the dude abides")
+}
+
+func (s *FaultFSTestSuite) TestFailNth() {
+	start := s.files.Count()
+	s.files.FailNth(2, errBoom)
+
+	s.Require().True(s.files.Exists("data/a.txt"))
+	_, err := s.files.Stat("data/a.txt")
+	s.Require().ErrorIs(err, errBoom)
+	_, err = s.files.Stat("data/a.txt")
+	s.Require().NoError(err, "Should only fail the one operation")
+	s.Require().Equal(start+3, s.files.Count())
+}
+
+func (s *FaultFSTestSuite) TestFailPath() {
+	s.files.FailPath("read", "data/a.txt", errBoom)
+	s.files.FailPath("", "data/b.txt", errBoom)
+
+	_, err := s.files.ChangeDirectory("data").Read("a.txt")
+	s.Require().ErrorIs(err, errBoom, "Should match paths relative to the original FS")
+	_, err = s.files.Stat("data/a.txt")
+	s.Require().NoError(err, "Should only fail the given operation")
+
+	s.Require().ErrorIs(s.files.Remove("data/b.txt"), errBoom)
+	s.Require().ErrorIs(s.files.Move("data/b.txt", "data/c.txt"), errBoom)
+	s.Require().False(s.files.Exists("data/b.txt"))
+
+	s.files.FailPath("read", "data/a.txt", nil)
+	s.Require().Equal("the dude abides", s.read(s.files, "data/a.txt"))
+}
+
+func (s *FaultFSTestSuite) TestShortReads() {
+	s.files.ShortReads("data/a.txt", 4)
+	file, err := s.files.Read("data/a.txt")
+	s.Require().NoError(err)
+	defer file.Close()
+
+	buf := make([]byte, 10)
+	n, err := file.Read(buf)
+	s.Require().NoError(err)
+	s.Require().Equal("the ", string(buf[:n]))
+
+	n, err = file.ReadAt(buf, 4)
+	s.Require().ErrorIs(err, io.ErrUnexpectedEOF)
+	s.Require().Equal("dude", string(buf[:n]))
+
+	s.Require().Equal("the dude abides", s.read(s.files, "data/a.txt"), "Should still be able to read it all")
+}
+
+func (s *FaultFSTestSuite) TestShortWrites() {
+	s.files.ShortWrites("data/b.txt", 3)
+	file, err := s.files.Write("data/b.txt")
+	s.Require().NoError(err)
+
+	n, err := file.Write([]byte("walter"))
+	s.Require().ErrorIs(err, io.ErrShortWrite)
+	s.Require().Equal(3, n)
+	n, err = file.Write([]byte("ter"))
+	s.Require().NoError(err)
+	s.Require().Equal(3, n)
+	s.Require().NoError(file.Close())
+	s.Require().Equal("walter", s.read(s.files, "data/b.txt"))
+}
+
+func (s *FaultFSTestSuite) write(files filestore.FS, filePath string, content string) {
+	file, err := files.Write(filePath)
+	s.Require().NoError(err)
+	_, err = file.Write([]byte(content))
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+}
+
+func (s *FaultFSTestSuite) read(files filestore.FS, filePath string) string {
+	file, err := files.Read(filePath)
+	s.Require().NoError(err)
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	return string(data)
+}
@@ -0,0 +1,522 @@
+package filestoretest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/monadicstack/filestore"
+)
+
+// Fixture is a replayable recording of every operation (and its results) that was performed
+// against a real file store. You typically capture one using a Recorder, save it to a JSON file
+// in your testdata/ directory, and serve it back offline using a Replayer.
+type Fixture struct {
+	// WorkingDirectory is the working directory of the recorded FS.
+	WorkingDirectory string `json:"workingDirectory"`
+	// Interactions are all of the operations performed against the recorded FS, in order.
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded operation and its results.
+type Interaction struct {
+	// Op is the name of the FS operation (e.g. "stat", "read", "list", "move").
+	Op string `json:"op"`
+	// Path is the path the operation targeted, relative to the root of the recorded FS.
+	Path string `json:"path"`
+	// To is the destination path for "move" operations.
+	To string `json:"to,omitempty"`
+	// Error is the message of the error returned by the operation, if any.
+	Error string `json:"error,omitempty"`
+	// NotExist indicates that the recorded error was a "not exists" error.
+	NotExist bool `json:"notExist,omitempty"`
+	// Exists is the result of an "exists" operation.
+	Exists bool `json:"exists,omitempty"`
+	// Info is the result of a "stat" operation.
+	Info *FileInfo `json:"info,omitempty"`
+	// Entries are the (unfiltered) results of a "list" operation.
+	Entries []FileInfo `json:"entries,omitempty"`
+	// Data contains the bytes read by a "read" or written by a "write".
+	Data []byte `json:"data,omitempty"`
+}
+
+// FileInfo is a serializable snapshot of a filestore.FileInfo.
+type FileInfo struct {
+	FileName    string      `json:"name"`
+	FileSize    int64       `json:"size"`
+	FileMode    fs.FileMode `json:"mode"`
+	FileModTime time.Time   `json:"modTime"`
+	Dir         bool        `json:"dir"`
+}
+
+func (info FileInfo) Name() string       { return info.FileName }
+func (info FileInfo) Size() int64        { return info.FileSize }
+func (info FileInfo) Mode() fs.FileMode  { return info.FileMode }
+func (info FileInfo) ModTime() time.Time { return info.FileModTime }
+func (info FileInfo) IsDir() bool        { return info.Dir }
+func (info FileInfo) Sys() any           { return nil }
+
+func newFileInfo(info filestore.FileInfo) FileInfo {
+	return FileInfo{
+		FileName:    info.Name(),
+		FileSize:    info.Size(),
+		FileMode:    info.Mode(),
+		FileModTime: info.ModTime(),
+		Dir:         info.IsDir(),
+	}
+}
+
+// LoadFixture decodes a JSON fixture previously written using Recorder.Save().
+func LoadFixture(r io.Reader) (Fixture, error) {
+	fixture := Fixture{}
+	if err := json.NewDecoder(r).Decode(&fixture); err != nil {
+		return Fixture{}, fmt.Errorf("load fixture: %w", err)
+	}
+	return fixture, nil
+}
+
+// NewRecorder wraps a real file store, capturing every operation performed through it as well
+// as its results. Once you've exercised your code, call Save() to write the fixture somewhere so
+// that future test runs can use a Replayer instead of hitting the real backend.
+//
+// Example:
+//
+//	recorder := filestoretest.NewRecorder(realS3Store)
+//	runTheCodeUnderTest(recorder)
+//
+//	fixture, _ := os.Create("testdata/fixtures/upload.json")
+//	defer fixture.Close()
+//	recorder.Save(fixture)
+func NewRecorder(fs filestore.FS) *Recorder {
+	return &Recorder{
+		fs:   fs,
+		tape: &tape{fixture: Fixture{WorkingDirectory: fs.WorkingDirectory()}},
+		dir:  ".",
+	}
+}
+
+// Recorder is an FS that captures all operations performed against the FS it wraps.
+type Recorder struct {
+	fs   filestore.FS
+	tape *tape
+	dir  string
+}
+
+// tape is the shared recording for a Recorder and all instances derived from it via ChangeDirectory().
+type tape struct {
+	mutex   sync.Mutex
+	fixture Fixture
+}
+
+func (t *tape) record(interaction Interaction) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.fixture.Interactions = append(t.fixture.Interactions, interaction)
+}
+
+// Fixture returns a copy of everything recorded so far.
+func (r *Recorder) Fixture() Fixture {
+	r.tape.mutex.Lock()
+	defer r.tape.mutex.Unlock()
+
+	fixture := r.tape.fixture
+	fixture.Interactions = append([]Interaction(nil), fixture.Interactions...)
+	return fixture
+}
+
+// Save writes everything recorded so far as JSON that you can later load using LoadFixture().
+func (r *Recorder) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r.Fixture()); err != nil {
+		return fmt.Errorf("save fixture: %w", err)
+	}
+	return nil
+}
+
+// key converts a path relative to this recorder's directory into a path relative to the original recorder.
+func (r *Recorder) key(filePath string) string {
+	return path.Join(r.dir, filePath)
+}
+
+func (r *Recorder) WorkingDirectory() string {
+	return r.fs.WorkingDirectory()
+}
+
+func (r *Recorder) ChangeDirectory(dir string) filestore.FS {
+	return &Recorder{fs: r.fs.ChangeDirectory(dir), tape: r.tape, dir: r.key(dir)}
+}
+
+func (r *Recorder) Stat(filePath string) (filestore.FileInfo, error) {
+	info, err := r.fs.Stat(filePath)
+	interaction := withError(Interaction{Op: "stat", Path: r.key(filePath)}, err)
+	if err == nil {
+		recorded := newFileInfo(info)
+		interaction.Info = &recorded
+	}
+	r.tape.record(interaction)
+	return info, err
+}
+
+func (r *Recorder) Exists(filePath string) bool {
+	exists := r.fs.Exists(filePath)
+	r.tape.record(Interaction{Op: "exists", Path: r.key(filePath), Exists: exists})
+	return exists
+}
+
+// Read fully reads the file from the wrapped store so that its contents can be recorded. You
+// get a reader over those recorded bytes rather than the wrapped store's reader.
+func (r *Recorder) Read(filePath string) (filestore.ReaderFile, error) {
+	data, err := readAll(r.fs, filePath)
+	r.tape.record(withError(Interaction{Op: "read", Path: r.key(filePath), Data: data}, err))
+	if err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+// Write opens the file in the wrapped store. The interaction is recorded when you close the
+// file so that we can capture everything you wrote.
+func (r *Recorder) Write(filePath string) (filestore.WriterFile, error) {
+	file, err := r.fs.Write(filePath)
+	if err != nil {
+		r.tape.record(withError(Interaction{Op: "write", Path: r.key(filePath)}, err))
+		return nil, err
+	}
+	return &recorderWriterFile{WriterFile: file, onClose: func(data []byte, err error) {
+		r.tape.record(withError(Interaction{Op: "write", Path: r.key(filePath), Data: data}, err))
+	}}, nil
+}
+
+// List records the unfiltered listing of the directory and applies your filters afterwards. That
+// way a replayer can apply whatever filters the code under test uses, even if they change.
+func (r *Recorder) List(dirPath string, filters ...filestore.FileFilter) ([]filestore.FileInfo, error) {
+	infos, err := r.fs.List(dirPath)
+	interaction := withError(Interaction{Op: "list", Path: r.key(dirPath)}, err)
+	for _, info := range infos {
+		interaction.Entries = append(interaction.Entries, newFileInfo(info))
+	}
+	r.tape.record(interaction)
+	if err != nil {
+		return nil, err
+	}
+	return filterInfos(infos, filters), nil
+}
+
+func (r *Recorder) Remove(fileOrDirPath string) error {
+	err := r.fs.Remove(fileOrDirPath)
+	r.tape.record(withError(Interaction{Op: "remove", Path: r.key(fileOrDirPath)}, err))
+	return err
+}
+
+func (r *Recorder) Move(fromPath string, toPath string) error {
+	err := r.fs.Move(fromPath, toPath)
+	r.tape.record(withError(Interaction{Op: "move", Path: r.key(fromPath), To: r.key(toPath)}, err))
+	return err
+}
+
+// NewReplayer creates an FS that serves all operations from a previously recorded fixture
+// w/o touching any real backend. Operations are matched up with recorded interactions based on
+// the operation and path. When the same operation is performed on the same path multiple times,
+// the recorded results are served in order; once exhausted, the last result is repeated.
+//
+// Performing an operation that was never recorded results in an error.
+//
+// Example:
+//
+//	input, _ := os.Open("testdata/fixtures/upload.json")
+//	fixture, _ := filestoretest.LoadFixture(input)
+//	runTheCodeUnderTest(filestoretest.NewReplayer(fixture))
+func NewReplayer(fixture Fixture) *Replayer {
+	queues := map[string][]Interaction{}
+	for _, interaction := range fixture.Interactions {
+		key := replayKey(interaction.Op, interaction.Path, interaction.To)
+		queues[key] = append(queues[key], interaction)
+	}
+	return &Replayer{
+		workingDirectory: fixture.WorkingDirectory,
+		dir:              ".",
+		deck:             &deck{queues: queues},
+	}
+}
+
+// Replayer is an FS that serves all of its results from a recorded Fixture.
+type Replayer struct {
+	workingDirectory string
+	dir              string
+	deck             *deck
+}
+
+// deck contains the remaining interactions shared by a Replayer and all instances derived from it via ChangeDirectory().
+type deck struct {
+	mutex  sync.Mutex
+	queues map[string][]Interaction
+}
+
+func (d *deck) next(op string, filePath string, toPath string) (Interaction, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	key := replayKey(op, filePath, toPath)
+	queue := d.queues[key]
+	switch len(queue) {
+	case 0:
+		return Interaction{}, fmt.Errorf("replay fs error: no recorded interaction for %s", key)
+	case 1:
+		return queue[0], nil
+	default:
+		d.queues[key] = queue[1:]
+		return queue[0], nil
+	}
+}
+
+func replayKey(op string, filePath string, toPath string) string {
+	if toPath != "" {
+		return op + " " + filePath + " -> " + toPath
+	}
+	return op + " " + filePath
+}
+
+func (r *Replayer) key(filePath string) string {
+	return path.Join(r.dir, filePath)
+}
+
+func (r *Replayer) WorkingDirectory() string {
+	return path.Join(r.workingDirectory, r.dir)
+}
+
+func (r *Replayer) ChangeDirectory(dir string) filestore.FS {
+	return &Replayer{workingDirectory: r.workingDirectory, dir: r.key(dir), deck: r.deck}
+}
+
+func (r *Replayer) Stat(filePath string) (filestore.FileInfo, error) {
+	interaction, err := r.deck.next("stat", r.key(filePath), "")
+	if err != nil {
+		return nil, err
+	}
+	if err = interaction.err(); err != nil {
+		return nil, err
+	}
+	return *interaction.Info, nil
+}
+
+func (r *Replayer) Exists(filePath string) bool {
+	interaction, err := r.deck.next("exists", r.key(filePath), "")
+	return err == nil && interaction.Exists
+}
+
+func (r *Replayer) Read(filePath string) (filestore.ReaderFile, error) {
+	interaction, err := r.deck.next("read", r.key(filePath), "")
+	if err != nil {
+		return nil, err
+	}
+	if err = interaction.err(); err != nil {
+		return nil, err
+	}
+	return nopCloser{bytes.NewReader(interaction.Data)}, nil
+}
+
+// Write serves the recorded result of opening the file. When you close the file, it will
+// fail if you wrote different content than what was originally recorded.
+func (r *Replayer) Write(filePath string) (filestore.WriterFile, error) {
+	interaction, err := r.deck.next("write", r.key(filePath), "")
+	if err != nil {
+		return nil, err
+	}
+	if err = interaction.err(); err != nil && interaction.Data == nil {
+		return nil, err
+	}
+	return &replayerWriterFile{path: filePath, expected: interaction}, nil
+}
+
+func (r *Replayer) List(dirPath string, filters ...filestore.FileFilter) ([]filestore.FileInfo, error) {
+	interaction, err := r.deck.next("list", r.key(dirPath), "")
+	if err != nil {
+		return nil, err
+	}
+	if err = interaction.err(); err != nil {
+		return nil, err
+	}
+
+	var infos []filestore.FileInfo
+	for _, entry := range interaction.Entries {
+		infos = append(infos, entry)
+	}
+	return filterInfos(infos, filters), nil
+}
+
+func (r *Replayer) Remove(fileOrDirPath string) error {
+	interaction, err := r.deck.next("remove", r.key(fileOrDirPath), "")
+	if err != nil {
+		return err
+	}
+	return interaction.err()
+}
+
+func (r *Replayer) Move(fromPath string, toPath string) error {
+	interaction, err := r.deck.next("move", r.key(fromPath), r.key(toPath))
+	if err != nil {
+		return err
+	}
+	return interaction.err()
+}
+
+// err reconstructs the recorded error, if there was one.
+func (interaction Interaction) err() error {
+	switch {
+	case interaction.NotExist:
+		return fmt.Errorf("%s: %w", interaction.Error, fs.ErrNotExist)
+	case interaction.Error != "":
+		return errors.New(interaction.Error)
+	default:
+		return nil
+	}
+}
+
+func withError(interaction Interaction, err error) Interaction {
+	if err != nil {
+		interaction.Error = err.Error()
+		interaction.NotExist = errors.Is(err, fs.ErrNotExist)
+	}
+	return interaction
+}
+
+func readAll(fs filestore.FS, filePath string) ([]byte, error) {
+	file, err := fs.Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+func filterInfos(infos []filestore.FileInfo, filters []filestore.FileFilter) []filestore.FileInfo {
+	var results []filestore.FileInfo
+	for _, info := range infos {
+		matches := true
+		for _, filter := range filters {
+			matches = matches && filter(info)
+		}
+		if matches {
+			results = append(results, info)
+		}
+	}
+	return results
+}
+
+// nopCloser turns a bytes.Reader into a ReaderFile.
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// recorderWriterFile tees everything written to the real file so that it can be recorded on Close().
+type recorderWriterFile struct {
+	filestore.WriterFile
+	mutex   sync.Mutex
+	buffer  []byte
+	offset  int64
+	onClose func(data []byte, err error)
+}
+
+func (w *recorderWriterFile) Write(p []byte) (int, error) {
+	n, err := w.WriterFile.Write(p)
+	w.mutex.Lock()
+	w.offset += int64(w.capture(p[:n], w.offset))
+	w.mutex.Unlock()
+	return n, err
+}
+
+func (w *recorderWriterFile) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.WriterFile.WriteAt(p, off)
+	w.mutex.Lock()
+	w.capture(p[:n], off)
+	w.mutex.Unlock()
+	return n, err
+}
+
+func (w *recorderWriterFile) Seek(offset int64, whence int) (int64, error) {
+	position, err := w.WriterFile.Seek(offset, whence)
+	if err == nil {
+		w.mutex.Lock()
+		w.offset = position
+		w.mutex.Unlock()
+	}
+	return position, err
+}
+
+func (w *recorderWriterFile) Close() error {
+	err := w.WriterFile.Close()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.onClose != nil {
+		w.onClose(w.buffer, err)
+		w.onClose = nil
+	}
+	return err
+}
+
+func (w *recorderWriterFile) capture(p []byte, off int64) int {
+	if end := off + int64(len(p)); end > int64(len(w.buffer)) {
+		w.buffer = append(w.buffer, make([]byte, end-int64(len(w.buffer)))...)
+	}
+	return copy(w.buffer[off:], p)
+}
+
+// replayerWriterFile captures everything written so that we can compare it to what was recorded.
+type replayerWriterFile struct {
+	recorderWriterFile
+	path     string
+	expected Interaction
+}
+
+func (w *replayerWriterFile) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	n := w.capture(p, w.offset)
+	w.offset += int64(n)
+	return n, nil
+}
+
+func (w *replayerWriterFile) WriteAt(p []byte, off int64) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.capture(p, off), nil
+}
+
+func (w *replayerWriterFile) Seek(offset int64, whence int) (int64, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+		w.offset = offset
+	case io.SeekCurrent:
+		w.offset += offset
+	case io.SeekEnd:
+		w.offset = int64(len(w.buffer)) + offset
+	}
+	return w.offset, nil
+}
+
+func (w *replayerWriterFile) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !bytes.Equal(w.buffer, w.expected.Data) {
+		return fmt.Errorf("replay fs error: write %s: content does not match recording", w.path)
+	}
+	return w.expected.err()
+}
+
+var _ filestore.FS = &Recorder{}
+var _ filestore.FS = &Replayer{}
@@ -0,0 +1,118 @@
+package filestoretest_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type RecordTestSuite struct {
+	suite.Suite
+}
+
+func TestRecordTestSuite(t *testing.T) {
+	suite.Run(t, &RecordTestSuite{})
+}
+
+// exercise is our "code under test". It performs a little bit of everything.
+func (s *RecordTestSuite) exercise(files filestore.FS) {
+	s.write(files, "conf/config.json", `{"timeout":"10s"}`)
+	s.write(files, "data/1.txt", "one")
+	s.write(files, "data/2.log", "two")
+
+	s.Require().True(files.Exists("conf/config.json"))
+	s.Require().False(files.Exists("nope.txt"))
+
+	info, err := files.Stat("conf/config.json")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(17), info.Size())
+
+	_, err = files.Stat("nope.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+
+	s.Require().Equal(`{"timeout":"10s"}`, s.read(files, "conf/config.json"))
+
+	data := files.ChangeDirectory("data")
+	entries, err := data.List(".", filestore.WithExt("txt"))
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(entries))
+	s.Require().Equal("1.txt", entries[0].Name())
+
+	s.Require().NoError(data.Move("1.txt", "3.txt"))
+	s.Require().Equal("one", s.read(files, "data/3.txt"))
+	s.Require().NoError(files.Remove("data/2.log"))
+	s.Require().Error(files.Move("nope.txt", "nope2.txt"))
+}
+
+func (s *RecordTestSuite) TestRecordAndReplay() {
+	recorder := filestoretest.NewRecorder(filestore.Memory())
+	s.exercise(recorder)
+
+	buf := &bytes.Buffer{}
+	s.Require().NoError(recorder.Save(buf))
+
+	fixture, err := filestoretest.LoadFixture(buf)
+	s.Require().NoError(err)
+	s.Require().Equal(len(recorder.Fixture().Interactions), len(fixture.Interactions))
+
+	// Running the exact same code against the replayer should see all the same results.
+	replayer := filestoretest.NewReplayer(fixture)
+	s.Require().Equal("/", replayer.WorkingDirectory())
+	s.Require().Equal("/data", replayer.ChangeDirectory("data").WorkingDirectory())
+	s.exercise(replayer)
+}
+
+func (s *RecordTestSuite) TestReplay_unrecorded() {
+	replayer := filestoretest.NewReplayer(filestoretest.Fixture{})
+	_, err := replayer.Read("foo.txt")
+	s.Require().Error(err, "Unrecorded operations should fail")
+	s.Require().False(replayer.Exists("foo.txt"), "Unrecorded exists check should be false")
+}
+
+func (s *RecordTestSuite) TestReplay_writeMismatch() {
+	recorder := filestoretest.NewRecorder(filestore.Memory())
+	s.write(recorder, "foo.txt", "foo")
+
+	replayer := filestoretest.NewReplayer(recorder.Fixture())
+	file, err := replayer.Write("foo.txt")
+	s.Require().NoError(err)
+	_, _ = file.Write([]byte("bar"))
+	s.Require().Error(file.Close(), "Writing different content than what was recorded should fail")
+}
+
+// Repeated operations should be served in order, repeating the last one once exhausted.
+func (s *RecordTestSuite) TestReplay_sequence() {
+	recorder := filestoretest.NewRecorder(filestore.Memory())
+	s.Require().False(recorder.Exists("foo.txt"))
+	s.write(recorder, "foo.txt", "foo")
+	s.Require().True(recorder.Exists("foo.txt"))
+
+	replayer := filestoretest.NewReplayer(recorder.Fixture())
+	s.Require().False(replayer.Exists("foo.txt"))
+	s.Require().True(replayer.Exists("foo.txt"))
+	s.Require().True(replayer.Exists("foo.txt"))
+}
+
+func (s *RecordTestSuite) write(files filestore.FS, name string, content string) {
+	file, err := files.Write(name)
+	s.Require().NoError(err)
+	_, err = file.Write([]byte(content))
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+}
+
+func (s *RecordTestSuite) read(files filestore.FS, name string) string {
+	file, err := files.Read(name)
+	s.Require().NoError(err)
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	return string(data)
+}
@@ -0,0 +1,583 @@
+package filestoretest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewRedisServer starts an in-process fake of a Redis server that supports enough commands to exercise the
+// filestore Redis backend w/o running the real thing: PING, AUTH, SELECT, HSET, HGET, HMGET, DEL, EXISTS,
+// RENAME, PEXPIRE, PTTL, SCAN, and MULTI/EXEC/DISCARD. Hashes are the only type of value it supports. Keys
+// expire according to the server's fake Clock(), so you can test TTLs w/o sleeping.
+//
+// Remember to Close() the server when you're done with it.
+//
+// Example:
+//
+//	server := filestoretest.NewRedisServer()
+//	defer server.Close()
+//
+//	files := filestore.Redis(server.Addr)
+func NewRedisServer() *RedisServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("filestoretest: failed to listen on a port: %v", err))
+	}
+	server := &RedisServer{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		dbs:      map[int]map[string]*redisEntry{},
+		conns:    map[net.Conn]bool{},
+		clock:    NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC)),
+	}
+	server.wg.Add(1)
+	go server.serve()
+	return server
+}
+
+// RedisServer is a fake Redis server backed by memory.
+type RedisServer struct {
+	// Addr is the "host:port" address that the server is listening on.
+	Addr string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+	username string
+	password string
+	dbs      map[int]map[string]*redisEntry
+	conns    map[net.Conn]bool
+	commands []string
+	clock    *Clock
+}
+
+type redisEntry struct {
+	fields  map[string]string
+	expires time.Time
+}
+
+// redisStatus is a simple string reply such as "OK".
+type redisStatus string
+
+// redisSession is the state of a single client connection.
+type redisSession struct {
+	authenticated bool
+	db            int
+	multi         bool
+	queued        [][]string
+}
+
+// Clock returns the fake clock used to expire keys.
+func (server *RedisServer) Clock() *Clock {
+	return server.clock
+}
+
+// URL returns a "redis://" URL for the server that you can Open().
+func (server *RedisServer) URL() string {
+	return "redis://" + server.Addr
+}
+
+// RequireAuth makes clients AUTH w/ the given credentials before they can run any other commands. Leave
+// the username empty to emulate a server that only uses "requirepass".
+func (server *RedisServer) RequireAuth(username string, password string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.username = username
+	server.password = password
+}
+
+// Commands returns the name of every command the server has received so far (e.g. "HSET"), in order.
+func (server *RedisServer) Commands() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]string(nil), server.commands...)
+}
+
+// Keys returns the sorted keys of every unexpired entry in the given database.
+func (server *RedisServer) Keys(db int) []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.keys(db)
+}
+
+// Hash returns a copy of the fields of the hash at the given key, or nil if it doesn't exist.
+func (server *RedisServer) Hash(db int, key string) map[string]string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	entry := server.lookup(db, key)
+	if entry == nil {
+		return nil
+	}
+	fields := map[string]string{}
+	for field, value := range entry.fields {
+		fields[field] = value
+	}
+	return fields
+}
+
+// SetHash stores the hash fields at the given key (w/o an expiration), replacing anything that was there.
+func (server *RedisServer) SetHash(db int, key string, fields map[string]string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	entry := &redisEntry{fields: map[string]string{}}
+	for field, value := range fields {
+		entry.fields[field] = value
+	}
+	server.db(db)[key] = entry
+}
+
+// TTL returns how much longer the key has until it expires. It returns 0 when the key does not expire
+// (or does not exist).
+func (server *RedisServer) TTL(db int, key string) time.Duration {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	entry := server.lookup(db, key)
+	if entry == nil || entry.expires.IsZero() {
+		return 0
+	}
+	return entry.expires.Sub(server.clock.Now())
+}
+
+// Close stops accepting connections, disconnects every client, and waits for them to finish.
+func (server *RedisServer) Close() {
+	_ = server.listener.Close()
+
+	server.mutex.Lock()
+	for conn := range server.conns {
+		_ = conn.Close()
+	}
+	server.mutex.Unlock()
+	server.wg.Wait()
+}
+
+func (server *RedisServer) serve() {
+	defer server.wg.Done()
+	for {
+		conn, err := server.listener.Accept()
+		if err != nil {
+			return
+		}
+		server.mutex.Lock()
+		server.conns[conn] = true
+		server.mutex.Unlock()
+
+		server.wg.Add(1)
+		go server.handle(conn)
+	}
+}
+
+func (server *RedisServer) handle(conn net.Conn) {
+	defer server.wg.Done()
+	defer func() {
+		server.mutex.Lock()
+		delete(server.conns, conn)
+		server.mutex.Unlock()
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	session := &redisSession{}
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+		writeRedisReply(writer, server.run(session, args))
+
+		// Clients often pipeline several commands, so only flush once we've answered all of them.
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// run executes a single command for the session, handling authentication and transactions.
+func (server *RedisServer) run(session *redisSession, args []string) any {
+	if len(args) == 0 {
+		return errors.New("ERR empty command")
+	}
+	name := strings.ToUpper(args[0])
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.commands = append(server.commands, name)
+
+	if server.password != "" && !session.authenticated && name != "AUTH" {
+		return errors.New("NOAUTH Authentication required.")
+	}
+
+	switch {
+	case name == "MULTI" && session.multi:
+		return errors.New("ERR MULTI calls can not be nested")
+	case name == "MULTI":
+		session.multi = true
+		return redisStatus("OK")
+	case name == "EXEC" && !session.multi:
+		return errors.New("ERR EXEC without MULTI")
+	case name == "EXEC":
+		replies := make([]any, len(session.queued))
+		for i, queued := range session.queued {
+			replies[i] = server.exec(session, queued)
+		}
+		session.multi, session.queued = false, nil
+		return replies
+	case name == "DISCARD" && !session.multi:
+		return errors.New("ERR DISCARD without MULTI")
+	case name == "DISCARD":
+		session.multi, session.queued = false, nil
+		return redisStatus("OK")
+	case session.multi:
+		session.queued = append(session.queued, args)
+		return redisStatus("QUEUED")
+	default:
+		return server.exec(session, args)
+	}
+}
+
+// exec performs the command itself; the server's mutex must already be locked.
+func (server *RedisServer) exec(session *redisSession, args []string) any {
+	name := strings.ToUpper(args[0])
+	args = args[1:]
+	now := server.clock.Now()
+
+	switch {
+	case name == "PING":
+		return redisStatus("PONG")
+
+	case name == "AUTH" && (len(args) == 1 || len(args) == 2):
+		username, password := "", args[len(args)-1]
+		if len(args) == 2 {
+			username = args[0]
+		}
+		if server.password == "" {
+			return errors.New("ERR AUTH <password> called without any password configured for the default user.")
+		}
+		if username == "default" {
+			username = ""
+		}
+		if username != server.username || password != server.password {
+			return errors.New("WRONGPASS invalid username-password pair or user is disabled.")
+		}
+		session.authenticated = true
+		return redisStatus("OK")
+
+	case name == "SELECT" && len(args) == 1:
+		db, err := strconv.Atoi(args[0])
+		if err != nil || db < 0 || db > 15 {
+			return errors.New("ERR DB index is out of range")
+		}
+		session.db = db
+		return redisStatus("OK")
+
+	case name == "HSET" && len(args) >= 3 && len(args)%2 == 1:
+		entry := server.lookup(session.db, args[0])
+		if entry == nil {
+			entry = &redisEntry{fields: map[string]string{}}
+			server.db(session.db)[args[0]] = entry
+		}
+		added := 0
+		for i := 1; i < len(args); i += 2 {
+			if _, ok := entry.fields[args[i]]; !ok {
+				added++
+			}
+			entry.fields[args[i]] = args[i+1]
+		}
+		return added
+
+	case name == "HGET" && len(args) == 2:
+		entry := server.lookup(session.db, args[0])
+		if entry == nil {
+			return nil
+		}
+		if value, ok := entry.fields[args[1]]; ok {
+			return []byte(value)
+		}
+		return nil
+
+	case name == "HMGET" && len(args) >= 2:
+		entry := server.lookup(session.db, args[0])
+		values := make([]any, len(args)-1)
+		for i, field := range args[1:] {
+			if entry == nil {
+				continue
+			}
+			if value, ok := entry.fields[field]; ok {
+				values[i] = []byte(value)
+			}
+		}
+		return values
+
+	case (name == "DEL" || name == "EXISTS") && len(args) >= 1:
+		count := 0
+		for _, key := range args {
+			if server.lookup(session.db, key) != nil {
+				count++
+				if name == "DEL" {
+					delete(server.db(session.db), key)
+				}
+			}
+		}
+		return count
+
+	case name == "RENAME" && len(args) == 2:
+		entry := server.lookup(session.db, args[0])
+		if entry == nil {
+			return errors.New("ERR no such key")
+		}
+		delete(server.db(session.db), args[0])
+		server.db(session.db)[args[1]] = entry
+		return redisStatus("OK")
+
+	case name == "PEXPIRE" && len(args) == 2:
+		millis, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errors.New("ERR value is not an integer or out of range")
+		}
+		entry := server.lookup(session.db, args[0])
+		if entry == nil {
+			return 0
+		}
+		entry.expires = now.Add(time.Duration(millis) * time.Millisecond)
+		return 1
+
+	case name == "PTTL" && len(args) == 1:
+		entry := server.lookup(session.db, args[0])
+		switch {
+		case entry == nil:
+			return -2
+		case entry.expires.IsZero():
+			return -1
+		default:
+			return int(entry.expires.Sub(now).Milliseconds())
+		}
+
+	case name == "SCAN" && len(args) >= 1:
+		return server.scan(session.db, args)
+
+	case name == "AUTH" || name == "SELECT" || name == "HSET" || name == "HGET" || name == "HMGET" ||
+		name == "DEL" || name == "EXISTS" || name == "RENAME" || name == "PEXPIRE" || name == "PTTL" || name == "SCAN":
+		return fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+
+	default:
+		return fmt.Errorf("ERR unknown command '%s'", name)
+	}
+}
+
+// scan emulates "SCAN cursor [MATCH pattern] [COUNT count]". Our cursor is just an offset into the sorted
+// keys. Like the real thing, COUNT limits how many keys we look at, not how many match, so pages may be empty.
+func (server *RedisServer) scan(db int, args []string) any {
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		return errors.New("ERR invalid cursor")
+	}
+	pattern, count := "*", 10
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errors.New("ERR syntax error")
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern = args[i+1]
+		case "COUNT":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				return errors.New("ERR syntax error")
+			}
+		default:
+			return errors.New("ERR syntax error")
+		}
+	}
+
+	keys := server.keys(db)
+	end := cursor + count
+	if end >= len(keys) {
+		end = len(keys)
+	}
+	matches := []any{}
+	for i := cursor; i < end; i++ {
+		if matchRedisPattern(pattern, keys[i]) {
+			matches = append(matches, []byte(keys[i]))
+		}
+	}
+	next := strconv.Itoa(end)
+	if end >= len(keys) {
+		next = "0"
+	}
+	return []any{[]byte(next), matches}
+}
+
+// db returns the entries in the numbered database, creating it if necessary.
+func (server *RedisServer) db(db int) map[string]*redisEntry {
+	entries, ok := server.dbs[db]
+	if !ok {
+		entries = map[string]*redisEntry{}
+		server.dbs[db] = entries
+	}
+	return entries
+}
+
+// lookup finds the entry at the given key, deleting it (and returning nil) if it has expired.
+func (server *RedisServer) lookup(db int, key string) *redisEntry {
+	entry, ok := server.db(db)[key]
+	if !ok {
+		return nil
+	}
+	if !entry.expires.IsZero() && !server.clock.Now().Before(entry.expires) {
+		delete(server.db(db), key)
+		return nil
+	}
+	return entry
+}
+
+// keys returns the sorted keys of every unexpired entry in the database.
+func (server *RedisServer) keys(db int) []string {
+	var keys []string
+	for key := range server.db(db) {
+		if server.lookup(db, key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// matchRedisPattern implements the glob-style patterns supported by SCAN's MATCH option: "*" matches
+// anything, "?" matches any single character, "[abc]"/"[^a-z]" match character classes, and "\" escapes
+// the next character.
+func matchRedisPattern(pattern string, value string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(value); i >= 0; i-- {
+				if matchRedisPattern(pattern[1:], value[i:]) {
+					return true
+				}
+			}
+			return false
+
+		case '?':
+			if len(value) == 0 {
+				return false
+			}
+			pattern, value = pattern[1:], value[1:]
+
+		case '[':
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 || len(value) == 0 {
+				return false
+			}
+			class := pattern[1 : end+1]
+			negate := strings.HasPrefix(class, "^")
+			if negate {
+				class = class[1:]
+			}
+			matched := false
+			for i := 0; i < len(class); i++ {
+				if i+2 < len(class) && class[i+1] == '-' {
+					matched = matched || (value[0] >= class[i] && value[0] <= class[i+2])
+					i += 2
+					continue
+				}
+				matched = matched || value[0] == class[i]
+			}
+			if matched == negate {
+				return false
+			}
+			pattern, value = pattern[end+2:], value[1:]
+
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+
+		default:
+			if len(value) == 0 || pattern[0] != value[0] {
+				return false
+			}
+			pattern, value = pattern[1:], value[1:]
+		}
+	}
+	return len(value) == 0
+}
+
+// readRedisCommand reads the next command sent by a client as a RESP array of bulk strings.
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := readRedisLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		header, err := readRedisLine(reader)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header, "$") {
+			return nil, fmt.Errorf("expected bulk string: %q", header)
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func readRedisLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writeRedisReply encodes the reply: a redisStatus is a simple string, an error is an error reply, an int
+// is an integer, a []byte is a bulk string, a []any is an array, and nil is a null bulk string.
+func writeRedisReply(writer *bufio.Writer, reply any) {
+	switch reply := reply.(type) {
+	case redisStatus:
+		_, _ = fmt.Fprintf(writer, "+%s\r\n", reply)
+	case error:
+		_, _ = fmt.Fprintf(writer, "-%s\r\n", reply.Error())
+	case int:
+		_, _ = fmt.Fprintf(writer, ":%d\r\n", reply)
+	case []byte:
+		_, _ = fmt.Fprintf(writer, "$%d\r\n", len(reply))
+		_, _ = writer.Write(reply)
+		_, _ = writer.WriteString("\r\n")
+	case []any:
+		_, _ = fmt.Fprintf(writer, "*%d\r\n", len(reply))
+		for _, value := range reply {
+			writeRedisReply(writer, value)
+		}
+	default:
+		_, _ = writer.WriteString("$-1\r\n")
+	}
+}
@@ -0,0 +1,153 @@
+package filestoretest_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type RedisServerTestSuite struct {
+	suite.Suite
+	server *filestoretest.RedisServer
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func TestRedisServerTestSuite(t *testing.T) {
+	suite.Run(t, &RedisServerTestSuite{})
+}
+
+func (s *RedisServerTestSuite) SetupTest() {
+	s.server = filestoretest.NewRedisServer()
+	s.connect()
+}
+
+func (s *RedisServerTestSuite) TearDownTest() {
+	s.conn.Close()
+	s.server.Close()
+}
+
+func (s *RedisServerTestSuite) connect() {
+	conn, err := net.Dial("tcp", s.server.Addr)
+	s.Require().NoError(err)
+	s.conn, s.reader = conn, bufio.NewReader(conn)
+}
+
+// do sends the command and returns the raw reply w/ the line breaks replaced by spaces, so "*2\r\n:1\r\n:2\r\n"
+// becomes "*2 :1 :2".
+func (s *RedisServerTestSuite) do(args ...string) string {
+	command := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		command += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := s.conn.Write([]byte(command))
+	s.Require().NoError(err)
+	return s.reply()
+}
+
+func (s *RedisServerTestSuite) reply() string {
+	line, err := s.reader.ReadString('\n')
+	s.Require().NoError(err)
+	line = strings.TrimSuffix(line, "\r\n")
+
+	var count int
+	switch {
+	case line[0] == '$' && line != "$-1":
+		_, _ = fmt.Sscanf(line, "$%d", &count)
+		data := make([]byte, count+2)
+		_, err := io.ReadFull(s.reader, data)
+		s.Require().NoError(err)
+		return line + " " + string(data[:count])
+	case line[0] == '*':
+		_, _ = fmt.Sscanf(line, "*%d", &count)
+		for i := 0; i < count; i++ {
+			line += " " + s.reply()
+		}
+		return line
+	default:
+		return line
+	}
+}
+
+func (s *RedisServerTestSuite) TestHashes() {
+	s.Require().Equal("+PONG", s.do("PING"))
+	s.Require().Equal(":2", s.do("HSET", "rug", "data", "tied the room together", "size", "22"))
+	s.Require().Equal("$22 tied the room together", s.do("HGET", "rug", "data"))
+	s.Require().Equal("*2 $2 22 $-1", s.do("HMGET", "rug", "size", "missing"))
+	s.Require().Equal("$-1", s.do("HGET", "nope", "data"))
+	s.Require().Equal(map[string]string{"data": "tied the room together", "size": "22"}, s.server.Hash(0, "rug"))
+
+	s.Require().Equal("+OK", s.do("RENAME", "rug", "carpet"))
+	s.Require().Equal(":1", s.do("EXISTS", "carpet", "rug"))
+	s.Require().Equal("-ERR no such key", s.do("RENAME", "rug", "carpet"))
+	s.Require().Equal(":1", s.do("DEL", "carpet", "rug"))
+	s.Require().Empty(s.server.Keys(0))
+
+	s.Require().Equal("-ERR unknown command 'GETDEL'", s.do("GETDEL", "rug"))
+	s.Require().Equal("-ERR wrong number of arguments for 'hget' command", s.do("HGET", "rug"))
+}
+
+func (s *RedisServerTestSuite) TestExpiration() {
+	s.do("HSET", "rug", "data", "tied the room together")
+	s.Require().Equal(":-1", s.do("PTTL", "rug"))
+	s.Require().Equal(":1", s.do("PEXPIRE", "rug", "60000"))
+	s.Require().Equal(":60000", s.do("PTTL", "rug"))
+	s.Require().Equal(time.Minute, s.server.TTL(0, "rug"))
+
+	s.server.Clock().Advance(time.Minute)
+	s.Require().Equal("$-1", s.do("HGET", "rug", "data"))
+	s.Require().Equal(":-2", s.do("PTTL", "rug"))
+	s.Require().Equal(":0", s.do("PEXPIRE", "rug", "60000"))
+}
+
+func (s *RedisServerTestSuite) TestScan() {
+	for _, key := range []string{"a/1", "a/2", "a/3", "ab/1", "a*/1"} {
+		s.server.SetHash(0, key, map[string]string{"data": key})
+	}
+	s.Require().Equal("*2 $1 0 *3 $3 a/1 $3 a/2 $3 a/3", s.do("SCAN", "0", "MATCH", "a/*", "COUNT", "100"))
+	s.Require().Equal("*2 $1 0 *1 $4 a*/1", s.do("SCAN", "0", "MATCH", `a\*/*`))
+	s.Require().Equal("*2 $1 0 *1 $3 a/1", s.do("SCAN", "0", "MATCH", "a?1"))
+	s.Require().Equal("*2 $1 0 *2 $3 a/1 $4 ab/1", s.do("SCAN", "0", "MATCH", "a[/b]*1"))
+
+	// COUNT limits how many keys we look at, so you have to follow the cursor to see everything.
+	s.Require().Equal("*2 $1 2 *1 $3 a/1", s.do("SCAN", "0", "MATCH", "a/*", "COUNT", "2"))
+	s.Require().Equal("*2 $1 4 *2 $3 a/2 $3 a/3", s.do("SCAN", "2", "MATCH", "a/*", "COUNT", "2"))
+	s.Require().Equal("*2 $1 0 *0", s.do("SCAN", "4", "MATCH", "a/*", "COUNT", "2"))
+}
+
+func (s *RedisServerTestSuite) TestTransactions() {
+	s.Require().Equal("+OK", s.do("MULTI"))
+	s.Require().Equal("+QUEUED", s.do("HSET", "rug", "data", "abide"))
+	s.Require().Equal("+QUEUED", s.do("PEXPIRE", "rug", "1000"))
+	s.Require().Nil(s.server.Hash(0, "rug"), "Queued commands should not run until EXEC")
+	s.Require().Equal("*2 :1 :1", s.do("EXEC"))
+	s.Require().Equal("abide", s.server.Hash(0, "rug")["data"])
+
+	s.Require().Equal("+OK", s.do("MULTI"))
+	s.Require().Equal("+QUEUED", s.do("DEL", "rug"))
+	s.Require().Equal("+OK", s.do("DISCARD"))
+	s.Require().NotNil(s.server.Hash(0, "rug"))
+	s.Require().Equal("-ERR EXEC without MULTI", s.do("EXEC"))
+}
+
+func (s *RedisServerTestSuite) TestAuth() {
+	s.server.RequireAuth("dude", "abides")
+	s.Require().Equal("-NOAUTH Authentication required.", s.do("PING"))
+	s.Require().Equal("-WRONGPASS invalid username-password pair or user is disabled.", s.do("AUTH", "abides"))
+	s.Require().Equal("+OK", s.do("AUTH", "dude", "abides"))
+	s.Require().Equal("+PONG", s.do("PING"))
+
+	s.Require().Equal("+OK", s.do("SELECT", "2"))
+	s.do("HSET", "rug", "data", "abide")
+	s.Require().Equal([]string{"rug"}, s.server.Keys(2))
+	s.Require().Empty(s.server.Keys(0))
+	s.Require().Equal("-ERR DB index is out of range", s.do("SELECT", "16"))
+	s.Require().Contains(s.server.Commands(), "SELECT")
+}
@@ -0,0 +1,897 @@
+package filestoretest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewS3Server starts an in-process fake of the Amazon S3 REST API that supports enough of the API to exercise
+// the filestore S3 backend (or your own S3 code) w/o touching real infrastructure: HEAD/GET/PUT/DELETE on
+// objects (including ranged reads and server-side copies), ListObjectsV2, multipart uploads, versioning
+// (see EnableVersioning()), Object Lock retention, and restoring archived storage classes. It uses
+// path-style addressing (e.g. "http://127.0.0.1:1234/bucket/key") and does NOT validate request signatures,
+// although it does remember every request so that you can make assertions about them. Like S3, it assigns
+// each request an ID (the "x-amz-request-id" header), which is simply "FAKE" followed by its number.
+//
+// Remember to Close() the server when you're done with it.
+//
+// Example:
+//
+//	server := filestoretest.NewS3Server("my-bucket")
+//	defer server.Close()
+//
+//	files := filestore.S3("my-bucket", filestore.WithEndpoint(server.URL))
+func NewS3Server(buckets ...string) *S3Server {
+	server := &S3Server{
+		buckets:   map[string]map[string]*S3Object{},
+		versions:  map[string]map[string][]*S3Object{},
+		versioned: map[string]bool{},
+		uploads:   map[string]*s3Upload{},
+		expired:   map[string]bool{},
+		denied:    map[string]bool{},
+		clock:     NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC)),
+	}
+	for _, bucket := range buckets {
+		server.buckets[bucket] = map[string]*S3Object{}
+		server.versions[bucket] = map[string][]*S3Object{}
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	return server
+}
+
+// S3Server is a fake S3 service backed by memory.
+type S3Server struct {
+	*httptest.Server
+
+	// PageSize limits the number of keys returned by a single ListObjectsV2 call. Defaults to 1000.
+	PageSize int
+
+	mutex     sync.Mutex
+	buckets   map[string]map[string]*S3Object
+	versions  map[string]map[string][]*S3Object
+	versioned map[string]bool
+	uploads   map[string]*s3Upload
+	requests  []S3Request
+	expired   map[string]bool
+	denied    map[string]bool
+	clock     *Clock
+	nextID    int
+}
+
+// S3Object is the fake server's representation of a stored object (or one version of it).
+type S3Object struct {
+	Key          string
+	VersionID    string
+	DeleteMarker bool
+	RetainUntil  time.Time
+	RestoredTill time.Time
+	Data         []byte
+	ETag         string
+	LastModified time.Time
+	Header       http.Header
+}
+
+// StorageClass returns the object's storage class (e.g. "STANDARD" or "GLACIER").
+func (object *S3Object) StorageClass() string {
+	if class := object.Header.Get("X-Amz-Storage-Class"); class != "" {
+		return class
+	}
+	return "STANDARD"
+}
+
+// Archived returns true if the object is in one of the archive storage classes and has not been
+// temporarily restored, so you can't download its data.
+func (object *S3Object) Archived(now time.Time) bool {
+	switch object.StorageClass() {
+	case "GLACIER", "DEEP_ARCHIVE":
+		return !now.Before(object.RestoredTill)
+	default:
+		return false
+	}
+}
+
+// S3Request is a summary of a request the fake server received.
+type S3Request struct {
+	Method string
+	Bucket string
+	Key    string
+	Query  url.Values
+	Header http.Header
+}
+
+type s3Upload struct {
+	bucket string
+	key    string
+	header http.Header
+	parts  map[int][]byte
+}
+
+// Clock returns the fake clock used to stamp objects' LastModified times.
+func (server *S3Server) Clock() *Clock {
+	return server.clock
+}
+
+// Requests returns a copy of every request the server has received so far.
+func (server *S3Server) Requests() []S3Request {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return append([]S3Request(nil), server.requests...)
+}
+
+// ResetRequests forgets all of the requests the server has received so far.
+func (server *S3Server) ResetRequests() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.requests = nil
+}
+
+// ExpireSessionToken makes the server reject every request signed w/ the given session token w/ an
+// "ExpiredToken" error, like S3 does once temporary credentials expire.
+func (server *S3Server) ExpireSessionToken(token string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.expired[token] = true
+}
+
+// Deny makes the server reject every request for the given object w/ an "AccessDenied" error, like S3
+// does when a bucket policy forbids it. HEAD requests get the same 403 w/o a body, so they can't tell why.
+func (server *S3Server) Deny(bucket string, key string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.denied[bucket+"/"+key] = true
+}
+
+// Object fetches the current state of the object w/ the given key, or nil if it doesn't exist.
+func (server *S3Server) Object(bucket string, key string) *S3Object {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	object, ok := server.buckets[bucket][key]
+	if !ok {
+		return nil
+	}
+	copied := *object
+	return &copied
+}
+
+// EnableVersioning turns on object versioning for the bucket. From now on, overwriting or deleting an
+// object keeps its previous versions around (deletes just add a delete marker).
+func (server *S3Server) EnableVersioning(bucket string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.versioned[bucket] = true
+}
+
+// Versions returns every version of the object (including delete markers), newest first.
+func (server *S3Server) Versions(bucket string, key string) []S3Object {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	var versions []S3Object
+	for _, version := range server.versions[bucket][key] {
+		versions = append(versions, *version)
+	}
+	return versions
+}
+
+// DeleteObject removes an object directly, bypassing the HTTP API. In a versioned bucket, this adds a
+// delete marker just like a real DELETE would.
+func (server *S3Server) DeleteObject(bucket string, key string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.deleteLocked(bucket, key, "")
+}
+
+// PutObject stores an object directly, bypassing the HTTP API. This is handy for seeding test data.
+func (server *S3Server) PutObject(bucket string, key string, data []byte) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.putLocked(bucket, key, data, http.Header{})
+}
+
+// Keys returns the sorted keys of every object in the bucket.
+func (server *S3Server) Keys(bucket string) []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	var keys []string
+	for key := range server.buckets[bucket] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (server *S3Server) putLocked(bucket string, key string, data []byte, header http.Header) *S3Object {
+	sum := md5.Sum(data)
+	object := &S3Object{
+		Key:          key,
+		VersionID:    server.nextVersionLocked(bucket),
+		Data:         data,
+		ETag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		LastModified: server.clock.Now(),
+		Header:       header,
+	}
+	server.buckets[bucket][key] = object
+	server.addVersionLocked(bucket, object)
+	return object
+}
+
+// deleteLocked removes the object (or a specific version of it when versionID is not empty).
+func (server *S3Server) deleteLocked(bucket string, key string, versionID string) {
+	if versionID != "" {
+		versions := server.versions[bucket][key]
+		for i, version := range versions {
+			if version.VersionID == versionID {
+				server.versions[bucket][key] = append(versions[:i:i], versions[i+1:]...)
+				break
+			}
+		}
+		// The latest remaining version (if any) becomes the current object again.
+		delete(server.buckets[bucket], key)
+		if remaining := server.versions[bucket][key]; len(remaining) > 0 && !remaining[0].DeleteMarker {
+			server.buckets[bucket][key] = remaining[0]
+		}
+		return
+	}
+
+	delete(server.buckets[bucket], key)
+	if server.versioned[bucket] {
+		server.addVersionLocked(bucket, &S3Object{
+			Key:          key,
+			VersionID:    server.nextVersionLocked(bucket),
+			DeleteMarker: true,
+			LastModified: server.clock.Now(),
+		})
+	} else {
+		delete(server.versions[bucket], key)
+	}
+}
+
+// nextVersionLocked generates the version ID for a new object. Like S3, objects written while the
+// bucket is not versioned have the version "null".
+func (server *S3Server) nextVersionLocked(bucket string) string {
+	if !server.versioned[bucket] {
+		return "null"
+	}
+	server.nextID++
+	return fmt.Sprintf("version-%d", server.nextID)
+}
+
+// addVersionLocked records the new version as the most recent one for its key.
+func (server *S3Server) addVersionLocked(bucket string, object *S3Object) {
+	versions := server.versions[bucket][object.Key]
+	if !server.versioned[bucket] && len(versions) > 0 {
+		// Unversioned writes replace the "null" version rather than adding a new one.
+		versions = versions[1:]
+	}
+	server.versions[bucket][object.Key] = append([]*S3Object{object}, versions...)
+}
+
+func (server *S3Server) handle(w http.ResponseWriter, req *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	query := req.URL.Query()
+	server.requests = append(server.requests, S3Request{
+		Method: req.Method,
+		Bucket: bucket,
+		Key:    key,
+		Query:  query,
+		Header: req.Header.Clone(),
+	})
+	w.Header().Set("X-Amz-Request-Id", fmt.Sprintf("FAKE%012d", len(server.requests)))
+	w.Header().Set("X-Amz-Id-2", "fake-host-id")
+
+	if server.expired[req.Header.Get("X-Amz-Security-Token")] {
+		writeS3Error(w, http.StatusBadRequest, "ExpiredToken", "The provided token has expired.")
+		return
+	}
+	if server.denied[bucket+"/"+key] {
+		writeS3Error(w, http.StatusForbidden, "AccessDenied", "Access Denied")
+		return
+	}
+	objects, ok := server.buckets[bucket]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+		return
+	}
+
+	switch {
+	case key == "" && req.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case key == "" && req.Method == http.MethodGet && query.Get("list-type") == "2":
+		server.listObjects(w, objects, query)
+	case key == "" && req.Method == http.MethodGet && query.Has("versions"):
+		server.listVersions(w, server.versions[bucket], query)
+	case key == "" && req.Method == http.MethodPost && query.Has("delete"):
+		server.deleteObjects(w, req, bucket)
+	case key == "":
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "Unsupported bucket operation")
+	case req.Method == http.MethodPost && query.Has("restore"):
+		server.restoreObject(w, req, objects[key])
+	case req.Method == http.MethodPost && query.Has("uploads"):
+		server.createUpload(w, req, bucket, key)
+	case req.Method == http.MethodPut && query.Has("uploadId"):
+		server.uploadPart(w, req, query)
+	case req.Method == http.MethodPost && query.Has("uploadId"):
+		server.completeUpload(w, req, query)
+	case req.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(server.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case req.Method == http.MethodPut && query.Has("retention"):
+		server.putRetention(w, req, bucket, key, query.Get("versionId"))
+	case req.Method == http.MethodPut && req.Header.Get("X-Amz-Copy-Source") != "":
+		server.copyObject(w, req, bucket, key)
+	case req.Method == http.MethodPut:
+		data, _ := io.ReadAll(req.Body)
+		object := server.putLocked(bucket, key, data, s3ObjectHeaders(req.Header))
+		w.Header().Set("ETag", object.ETag)
+		w.WriteHeader(http.StatusOK)
+	case (req.Method == http.MethodGet || req.Method == http.MethodHead) && query.Has("versionId"):
+		server.getObject(w, req, server.findVersionLocked(bucket, key, query.Get("versionId")))
+	case req.Method == http.MethodGet || req.Method == http.MethodHead:
+		server.getObject(w, req, objects[key])
+	case req.Method == http.MethodDelete && server.retainedLocked(bucket, key, query.Get("versionId")):
+		writeS3Error(w, http.StatusForbidden, "AccessDenied", "Access Denied because object protected by object lock.")
+	case req.Method == http.MethodDelete:
+		server.deleteLocked(bucket, key, query.Get("versionId"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", "Unsupported object operation")
+	}
+}
+
+func (server *S3Server) getObject(w http.ResponseWriter, req *http.Request, object *S3Object) {
+	if object == nil {
+		if req.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+	if object.DeleteMarker {
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+		return
+	}
+	if req.Method == http.MethodGet && object.Archived(server.clock.Now()) {
+		writeS3Error(w, http.StatusForbidden, "InvalidObjectState", "The operation is not valid for the object's storage class")
+		return
+	}
+	if match := req.Header.Get("If-Match"); match != "" && match != object.ETag {
+		writeS3Error(w, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+		return
+	}
+
+	for name, values := range object.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("ETag", object.ETag)
+	w.Header().Set("Last-Modified", object.LastModified.Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("X-Amz-Version-Id", object.VersionID)
+	if !object.RetainUntil.IsZero() {
+		w.Header().Set("X-Amz-Object-Lock-Mode", "COMPLIANCE")
+		w.Header().Set("X-Amz-Object-Lock-Retain-Until-Date", object.RetainUntil.Format(time.RFC3339))
+	}
+	if !object.RestoredTill.IsZero() {
+		w.Header().Set("X-Amz-Restore", fmt.Sprintf(`ongoing-request="false", expiry-date="%s"`, object.RestoredTill.Format(http.TimeFormat)))
+	}
+
+	data := object.Data
+	status := http.StatusOK
+	if rangeHeader := req.Header.Get("Range"); rangeHeader != "" {
+		start, end, ok := parseRange(rangeHeader, int64(len(data)))
+		if !ok {
+			writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable")
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data = data[start : end+1]
+		status = http.StatusPartialContent
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+func (server *S3Server) copyObject(w http.ResponseWriter, req *http.Request, bucket string, key string) {
+	source, versionID, _ := strings.Cut(req.Header.Get("X-Amz-Copy-Source"), "?versionId=")
+	source, _ = url.PathUnescape(source)
+	sourceBucket, sourceKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	object, ok := server.buckets[sourceBucket][sourceKey]
+	if versionID != "" {
+		object = server.findVersionLocked(sourceBucket, sourceKey, versionID)
+		ok = object != nil
+	}
+	switch {
+	case !ok && versionID != "":
+		writeS3Error(w, http.StatusNotFound, "NoSuchVersion", "The specified version does not exist.")
+		return
+	case !ok:
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	case object.DeleteMarker:
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest", "The source of a copy request may not specifically refer to a delete marker by version id.")
+		return
+	}
+
+	header := object.Header
+	if req.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		header = s3ObjectHeaders(req.Header)
+	}
+	copied := server.putLocked(bucket, key, append([]byte(nil), object.Data...), header)
+	writeS3XML(w, http.StatusOK, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string   `xml:"ETag"`
+		LastModified string   `xml:"LastModified"`
+	}{ETag: copied.ETag, LastModified: copied.LastModified.Format(time.RFC3339)})
+}
+
+func (server *S3Server) listObjects(w http.ResponseWriter, objects map[string]*S3Object, query url.Values) {
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	after := query.Get("continuation-token")
+	if after == "" {
+		after = query.Get("start-after")
+	}
+	maxKeys := server.PageSize
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+	if requested, err := strconv.Atoi(query.Get("max-keys")); err == nil && requested < maxKeys {
+		maxKeys = requested
+	}
+
+	var keys []string
+	for key := range objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	type content struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int64  `xml:"Size"`
+		StorageClass string `xml:"StorageClass"`
+	}
+	type commonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+	result := struct {
+		XMLName               xml.Name       `xml:"ListBucketResult"`
+		Prefix                string         `xml:"Prefix"`
+		KeyCount              int            `xml:"KeyCount"`
+		IsTruncated           bool           `xml:"IsTruncated"`
+		NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+		Contents              []content      `xml:"Contents"`
+		CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+	}{Prefix: prefix}
+
+	for _, key := range keys {
+		if key <= after {
+			continue
+		}
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+
+		if delimiter != "" {
+			if index := strings.Index(key[len(prefix):], delimiter); index >= 0 {
+				common := key[:len(prefix)+index+len(delimiter)]
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: common})
+				result.KeyCount++
+				result.NextContinuationToken = common + "￿"
+				after = result.NextContinuationToken
+				continue
+			}
+		}
+
+		object := objects[key]
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: object.LastModified.Format(time.RFC3339),
+			ETag:         object.ETag,
+			Size:         int64(len(object.Data)),
+			StorageClass: object.StorageClass(),
+		})
+		result.KeyCount++
+		result.NextContinuationToken = key
+	}
+	if !result.IsTruncated {
+		result.NextContinuationToken = ""
+	}
+	writeS3XML(w, http.StatusOK, result)
+}
+
+// restoreObject temporarily restores an archived object so that you can download it. Unlike S3, which
+// takes hours, the fake completes the restore immediately.
+func (server *S3Server) restoreObject(w http.ResponseWriter, req *http.Request, object *S3Object) {
+	if object == nil {
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+		return
+	}
+	switch object.StorageClass() {
+	case "GLACIER", "DEEP_ARCHIVE":
+	default:
+		writeS3Error(w, http.StatusForbidden, "InvalidObjectState", "Restore is not allowed for the object's current storage class")
+		return
+	}
+
+	restore := struct {
+		Days int `xml:"Days"`
+	}{}
+	if err := xml.NewDecoder(req.Body).Decode(&restore); err != nil || restore.Days <= 0 {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed.")
+		return
+	}
+
+	now := server.clock.Now()
+	status := http.StatusAccepted
+	if now.Before(object.RestoredTill) {
+		status = http.StatusOK
+	}
+	object.RestoredTill = now.Add(time.Duration(restore.Days) * 24 * time.Hour)
+	w.WriteHeader(status)
+}
+
+// deleteObjects removes up to 1000 objects in a single request. Like S3, objects that can't be deleted
+// (e.g. under an unexpired retention period) are reported in the response w/o failing the whole request.
+func (server *S3Server) deleteObjects(w http.ResponseWriter, req *http.Request, bucket string) {
+	if req.Header.Get("Content-Md5") == "" {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest", "Missing required header for this request: Content-MD5")
+		return
+	}
+	request := struct {
+		Objects []struct {
+			Key       string `xml:"Key"`
+			VersionID string `xml:"VersionId"`
+		} `xml:"Object"`
+		Quiet bool `xml:"Quiet"`
+	}{}
+	if err := xml.NewDecoder(req.Body).Decode(&request); err != nil || len(request.Objects) == 0 || len(request.Objects) > 1000 {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed.")
+		return
+	}
+
+	type deleted struct {
+		Key string `xml:"Key"`
+	}
+	type deleteError struct {
+		Key     string `xml:"Key"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	result := struct {
+		XMLName xml.Name      `xml:"DeleteResult"`
+		Deleted []deleted     `xml:"Deleted"`
+		Errors  []deleteError `xml:"Error"`
+	}{}
+	for _, object := range request.Objects {
+		switch {
+		case server.denied[bucket+"/"+object.Key]:
+			result.Errors = append(result.Errors, deleteError{Key: object.Key, Code: "AccessDenied", Message: "Access Denied"})
+		case server.retainedLocked(bucket, object.Key, object.VersionID):
+			result.Errors = append(result.Errors, deleteError{Key: object.Key, Code: "AccessDenied", Message: "Access Denied because object protected by object lock."})
+		default:
+			server.deleteLocked(bucket, object.Key, object.VersionID)
+			if !request.Quiet {
+				result.Deleted = append(result.Deleted, deleted{Key: object.Key})
+			}
+		}
+	}
+	writeS3XML(w, http.StatusOK, result)
+}
+
+// putRetention applies an Object Lock retention period to the current (or a specific) version of an
+// object. Like S3, this only works in versioned buckets, and you can't shorten an existing period.
+func (server *S3Server) putRetention(w http.ResponseWriter, req *http.Request, bucket string, key string, versionID string) {
+	if !server.versioned[bucket] {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest", "Bucket is missing Object Lock Configuration")
+		return
+	}
+	if req.Header.Get("Content-Md5") == "" {
+		writeS3Error(w, http.StatusBadRequest, "InvalidRequest", "Content-MD5 HTTP header is required for Put Object requests with Object Lock parameters")
+		return
+	}
+
+	retention := struct {
+		Mode            string `xml:"Mode"`
+		RetainUntilDate string `xml:"RetainUntilDate"`
+	}{}
+	if err := xml.NewDecoder(req.Body).Decode(&retention); err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+	until, err := time.Parse(time.RFC3339, retention.RetainUntilDate)
+	if err != nil {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument", "The retain until date must be a valid timestamp.")
+		return
+	}
+
+	object := server.buckets[bucket][key]
+	if versionID != "" {
+		object = server.findVersionLocked(bucket, key, versionID)
+	}
+	switch {
+	case object == nil || object.DeleteMarker:
+		writeS3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	case until.Before(object.RetainUntil):
+		writeS3Error(w, http.StatusForbidden, "AccessDenied", "Access Denied because object protected by object lock.")
+	default:
+		object.RetainUntil = until
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// retainedLocked returns true if the given version of the object is protected by an unexpired retention period.
+func (server *S3Server) retainedLocked(bucket string, key string, versionID string) bool {
+	if versionID == "" {
+		// Deleting w/o a version just adds a delete marker in a versioned bucket, so it's always allowed.
+		object := server.buckets[bucket][key]
+		return !server.versioned[bucket] && object != nil && server.clock.Now().Before(object.RetainUntil)
+	}
+	object := server.findVersionLocked(bucket, key, versionID)
+	return object != nil && server.clock.Now().Before(object.RetainUntil)
+}
+
+func (server *S3Server) findVersionLocked(bucket string, key string, versionID string) *S3Object {
+	for _, version := range server.versions[bucket][key] {
+		if version.VersionID == versionID {
+			return version
+		}
+	}
+	return nil
+}
+
+func (server *S3Server) listVersions(w http.ResponseWriter, versions map[string][]*S3Object, query url.Values) {
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	keyMarker := query.Get("key-marker")
+	versionMarker := query.Get("version-id-marker")
+	maxKeys := server.PageSize
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+	if requested, err := strconv.Atoi(query.Get("max-keys")); err == nil && requested < maxKeys {
+		maxKeys = requested
+	}
+
+	var keys []string
+	for key := range versions {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	type version struct {
+		XMLName      xml.Name
+		Key          string `xml:"Key"`
+		VersionID    string `xml:"VersionId"`
+		IsLatest     bool   `xml:"IsLatest"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag,omitempty"`
+		Size         int64  `xml:"Size,omitempty"`
+		StorageClass string `xml:"StorageClass,omitempty"`
+	}
+	type commonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+	result := struct {
+		XMLName             xml.Name       `xml:"ListVersionsResult"`
+		Prefix              string         `xml:"Prefix"`
+		IsTruncated         bool           `xml:"IsTruncated"`
+		NextKeyMarker       string         `xml:"NextKeyMarker,omitempty"`
+		NextVersionIDMarker string         `xml:"NextVersionIdMarker,omitempty"`
+		Versions            []version      `xml:",any"`
+		CommonPrefixes      []commonPrefix `xml:"CommonPrefixes"`
+	}{Prefix: prefix}
+
+	count := 0
+	seenPrefixes := map[string]bool{}
+	for _, key := range keys {
+		if key < keyMarker {
+			continue
+		}
+		if delimiter != "" {
+			if index := strings.Index(key[len(prefix):], delimiter); index >= 0 {
+				common := key[:len(prefix)+index+len(delimiter)]
+				if !seenPrefixes[common] && common > keyMarker {
+					seenPrefixes[common] = true
+					result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: common})
+				}
+				continue
+			}
+		}
+
+		// Skip everything up to and including the marker from the previous page.
+		skipping := key == keyMarker
+		for i, object := range versions[key] {
+			if skipping {
+				skipping = versionMarker == "" || object.VersionID != versionMarker
+				continue
+			}
+			if count == maxKeys {
+				result.IsTruncated = true
+				break
+			}
+
+			name := "Version"
+			if object.DeleteMarker {
+				name = "DeleteMarker"
+			}
+			result.Versions = append(result.Versions, version{
+				XMLName:      xml.Name{Local: name},
+				Key:          key,
+				VersionID:    object.VersionID,
+				IsLatest:     i == 0,
+				LastModified: object.LastModified.Format(time.RFC3339),
+				ETag:         object.ETag,
+				Size:         int64(len(object.Data)),
+			})
+			if !object.DeleteMarker {
+				result.Versions[len(result.Versions)-1].StorageClass = object.StorageClass()
+			}
+			result.NextKeyMarker = key
+			result.NextVersionIDMarker = object.VersionID
+			count++
+		}
+		if result.IsTruncated {
+			break
+		}
+	}
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextVersionIDMarker = "", ""
+	}
+	writeS3XML(w, http.StatusOK, result)
+}
+
+func (server *S3Server) createUpload(w http.ResponseWriter, req *http.Request, bucket string, key string) {
+	server.nextID++
+	uploadID := fmt.Sprintf("upload-%d", server.nextID)
+	server.uploads[uploadID] = &s3Upload{bucket: bucket, key: key, header: s3ObjectHeaders(req.Header), parts: map[int][]byte{}}
+
+	writeS3XML(w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}{Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+func (server *S3Server) uploadPart(w http.ResponseWriter, req *http.Request, query url.Values) {
+	upload, ok := server.uploads[query.Get("uploadId")]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return
+	}
+	partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+	data, _ := io.ReadAll(req.Body)
+	upload.parts[partNumber] = data
+
+	sum := md5.Sum(data)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (server *S3Server) completeUpload(w http.ResponseWriter, req *http.Request, query url.Values) {
+	uploadID := query.Get("uploadId")
+	upload, ok := server.uploads[uploadID]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload", "The specified upload does not exist.")
+		return
+	}
+
+	manifest := struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}{}
+	if err := xml.NewDecoder(req.Body).Decode(&manifest); err != nil {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML", err.Error())
+		return
+	}
+
+	var data []byte
+	var partSums []byte
+	for _, part := range manifest.Parts {
+		partData, ok := upload.parts[part.PartNumber]
+		if !ok {
+			writeS3Error(w, http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+			return
+		}
+		data = append(data, partData...)
+		partSum := md5.Sum(partData)
+		partSums = append(partSums, partSum[:]...)
+	}
+	delete(server.uploads, uploadID)
+
+	// Like S3, the ETag of a multipart object is the MD5 of its parts' MD5s, followed by the part count.
+	object := server.putLocked(upload.bucket, upload.key, data, upload.header)
+	sum := md5.Sum(partSums)
+	object.ETag = fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(manifest.Parts))
+	writeS3XML(w, http.StatusOK, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Key     string   `xml:"Key"`
+		ETag    string   `xml:"ETag"`
+	}{Key: upload.key, ETag: object.ETag})
+}
+
+// Uploads returns the number of multipart uploads that have been started but not completed/aborted.
+func (server *S3Server) Uploads() int {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return len(server.uploads)
+}
+
+// s3ObjectHeaders extracts the headers from a write request that S3 stores alongside the object.
+func s3ObjectHeaders(header http.Header) http.Header {
+	stored := http.Header{}
+	for name, values := range header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "x-amz-storage-class" || strings.HasPrefix(lower, "x-amz-meta-") {
+			stored[name] = values
+		}
+	}
+	return stored
+}
+
+func parseRange(header string, size int64) (int64, int64, bool) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, 0, false
+	}
+	spec := strings.TrimPrefix(header, "bytes=")
+	startText, endText, _ := strings.Cut(spec, "-")
+	start, err := strconv.ParseInt(startText, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end := size - 1
+	if endText != "" {
+		if end, err = strconv.ParseInt(endText, 10, 64); err != nil {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, start <= end
+}
+
+func writeS3XML(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, xml.Header)
+	_ = xml.NewEncoder(w).Encode(value)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string, message string) {
+	writeS3XML(w, status, struct {
+		XMLName   xml.Name `xml:"Error"`
+		Code      string   `xml:"Code"`
+		Message   string   `xml:"Message"`
+		RequestID string   `xml:"RequestId"`
+		HostID    string   `xml:"HostId"`
+	}{Code: code, Message: message, RequestID: w.Header().Get("X-Amz-Request-Id"), HostID: w.Header().Get("X-Amz-Id-2")})
+}
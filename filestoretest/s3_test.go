@@ -0,0 +1,122 @@
+package filestoretest_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type S3ServerTestSuite struct {
+	suite.Suite
+	server *filestoretest.S3Server
+}
+
+func TestS3ServerTestSuite(t *testing.T) {
+	suite.Run(t, &S3ServerTestSuite{})
+}
+
+func (s *S3ServerTestSuite) SetupTest() {
+	s.server = filestoretest.NewS3Server("lebowski")
+}
+
+func (s *S3ServerTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *S3ServerTestSuite) do(method string, path string, body string, header http.Header) (*http.Response, string) {
+	req, err := http.NewRequest(method, s.server.URL+path, strings.NewReader(body))
+	s.Require().NoError(err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	res, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	s.Require().NoError(err)
+	return res, string(data)
+}
+
+func (s *S3ServerTestSuite) TestObjects() {
+	res, _ := s.do(http.MethodPut, "/lebowski/rug.txt", "tied the room together", nil)
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+	s.Require().Equal([]string{"rug.txt"}, s.server.Keys("lebowski"))
+	s.Require().Equal("tied the room together", string(s.server.Object("lebowski", "rug.txt").Data))
+
+	res, body := s.do(http.MethodGet, "/lebowski/rug.txt", "", http.Header{"Range": {"bytes=5-7"}})
+	s.Require().Equal(http.StatusPartialContent, res.StatusCode)
+	s.Require().Equal("the", body)
+
+	res, _ = s.do(http.MethodDelete, "/lebowski/rug.txt", "", nil)
+	s.Require().Equal(http.StatusNoContent, res.StatusCode)
+	s.Require().Nil(s.server.Object("lebowski", "rug.txt"))
+
+	res, body = s.do(http.MethodGet, "/lebowski/rug.txt", "", nil)
+	s.Require().Equal(http.StatusNotFound, res.StatusCode)
+	s.Require().Contains(body, "<Code>NoSuchKey</Code>")
+
+	res, _ = s.do(http.MethodGet, "/nope/rug.txt", "", nil)
+	s.Require().Equal(http.StatusNotFound, res.StatusCode)
+}
+
+func (s *S3ServerTestSuite) TestListObjects() {
+	s.server.PutObject("lebowski", "a/1.txt", nil)
+	s.server.PutObject("lebowski", "a/2.txt", nil)
+	s.server.PutObject("lebowski", "a/b/3.txt", nil)
+	s.server.PutObject("lebowski", "c.txt", nil)
+
+	_, body := s.do(http.MethodGet, "/lebowski?list-type=2&prefix=a/&delimiter=/", "", nil)
+	s.Require().Contains(body, "<Key>a/1.txt</Key>")
+	s.Require().Contains(body, "<Key>a/2.txt</Key>")
+	s.Require().Contains(body, "<Prefix>a/b/</Prefix>")
+	s.Require().NotContains(body, "a/b/3.txt")
+	s.Require().NotContains(body, "c.txt")
+
+	_, body = s.do(http.MethodGet, "/lebowski?list-type=2&max-keys=1", "", nil)
+	s.Require().Contains(body, "<IsTruncated>true</IsTruncated>")
+	s.Require().Contains(body, "<NextContinuationToken>a/1.txt</NextContinuationToken>")
+
+	_, body = s.do(http.MethodGet, "/lebowski?list-type=2&continuation-token=a/2.txt", "", nil)
+	s.Require().Contains(body, "<Key>a/b/3.txt</Key>")
+	s.Require().Contains(body, "<Key>c.txt</Key>")
+	s.Require().NotContains(body, "a/1.txt")
+	s.Require().Len(s.server.Requests(), 3)
+}
+
+func (s *S3ServerTestSuite) TestVersions() {
+	s.server.PutObject("lebowski", "rug.txt", []byte("unversioned"))
+	s.server.PutObject("lebowski", "rug.txt", []byte("still unversioned"))
+	s.Require().Len(s.server.Versions("lebowski", "rug.txt"), 1, "Unversioned writes should replace the null version")
+
+	s.server.EnableVersioning("lebowski")
+	s.server.PutObject("lebowski", "rug.txt", []byte("tied the room together"))
+	s.server.DeleteObject("lebowski", "rug.txt")
+	versions := s.server.Versions("lebowski", "rug.txt")
+	s.Require().Len(versions, 3)
+	s.Require().True(versions[0].DeleteMarker)
+	s.Require().Equal("null", versions[2].VersionID)
+	s.Require().Nil(s.server.Object("lebowski", "rug.txt"))
+
+	res, _ := s.do(http.MethodGet, "/lebowski/rug.txt", "", nil)
+	s.Require().Equal(http.StatusNotFound, res.StatusCode)
+
+	res, body := s.do(http.MethodGet, "/lebowski/rug.txt?versionId="+versions[1].VersionID, "", nil)
+	s.Require().Equal(http.StatusOK, res.StatusCode)
+	s.Require().Equal("tied the room together", body)
+	s.Require().Equal(versions[1].VersionID, res.Header.Get("X-Amz-Version-Id"))
+
+	_, body = s.do(http.MethodGet, "/lebowski?versions&max-keys=2", "", nil)
+	s.Require().Contains(body, "<DeleteMarker><Key>rug.txt</Key>")
+	s.Require().Contains(body, "<IsTruncated>true</IsTruncated>")
+	s.Require().NotContains(body, "<VersionId>null</VersionId>")
+
+	// Deleting the delete marker itself should bring the object back.
+	res, _ = s.do(http.MethodDelete, "/lebowski/rug.txt?versionId="+versions[0].VersionID, "", nil)
+	s.Require().Equal(http.StatusNoContent, res.StatusCode)
+	s.Require().Equal("tied the room together", string(s.server.Object("lebowski", "rug.txt").Data))
+}
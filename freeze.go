@@ -0,0 +1,234 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Freezer is an optional capability for stores that can temporarily block changes to part of the store,
+// e.g. so that a backup or export sees a consistent snapshot of a directory that's otherwise live.
+type Freezer interface {
+	// Freeze blocks writes, moves, and removals of the file/directory (and anything inside of it) until you
+	// call the returned unfreeze function.
+	Freeze(path string) (unfreeze func(), err error)
+}
+
+// ErrFrozen is the error returned when you try to write, move, or remove a file/directory that is frozen.
+var ErrFrozen = errors.New("filestore: path is frozen")
+
+// ErrFreezeNotSupported is the error returned by Freeze() when the store does not implement the
+// Freezer capability.
+var ErrFreezeNotSupported = errors.New("filestore: freeze not supported")
+
+// Freeze blocks writes, moves, and removals of the file/directory (and anything inside of it) until you
+// call the returned unfreeze function, provided that the store supports the Freezer capability. For all
+// other stores, this fails with ErrFreezeNotSupported; wrap them using Freezable() if you need it.
+//
+// Example:
+//
+//	unfreeze, err := filestore.Freeze(files, "projects/dude")
+//	if err != nil {
+//	    return err
+//	}
+//	defer unfreeze()
+//	err = filestore.CopyAll(files, "projects/dude", backups, "2022-09-06/dude")
+func Freeze(fs FS, path string) (unfreeze func(), err error) {
+	if freezer, ok := fs.(Freezer); ok {
+		return freezer.Freeze(path)
+	}
+	return nil, fmt.Errorf("filestore: freeze: %s: %w", path, ErrFreezeNotSupported)
+}
+
+// Freezable wraps a file store so that it supports the Freezer capability. While a directory is frozen,
+// writing, moving, or removing anything inside of it (or any of its parents) through the wrapper fails
+// with ErrFrozen right away rather than waiting, so callers never hang behind a long-running backup.
+// Freezing also waits for any files inside of the directory that are already being written to be closed,
+// so once Freeze() returns, nothing else will change until you unfreeze it.
+//
+// You can freeze the same (or overlapping) paths more than once; a path stays frozen until every freeze
+// covering it has been undone. Frozen paths only live in memory and are shared by every store derived
+// from the wrapper via ChangeDirectory() or ForRequest(). Keep in mind that the wrapper can only block
+// changes made through it.
+//
+// Example:
+//
+//	files := filestore.Freezable(filestore.Disk("/var/data"))
+func Freezable(fs FS) FS {
+	state := &freezeState{root: fs.WorkingDirectory(), frozen: map[string]int{}, writing: map[string]int{}}
+	state.released = sync.NewCond(&state.mutex)
+	return &freezableFS{FS: fs, state: state}
+}
+
+func init() {
+	RegisterLayer("freezable", func(fs FS, _ LayerOptions) (FS, error) {
+		return Freezable(fs), nil
+	})
+}
+
+type freezableFS struct {
+	FS
+	state *freezeState
+}
+
+// freezeState tracks which paths are frozen and which files are being written, relative to the root of
+// the wrapped store. It's shared by the original wrapper and any instances derived from it.
+type freezeState struct {
+	mutex    sync.Mutex
+	released *sync.Cond
+	root     string
+	// frozen counts the active freezes of each path.
+	frozen map[string]int
+	// writing counts the open writers of each path.
+	writing map[string]int
+}
+
+// key determines which file a path refers to, relative to the root of the wrapped store, regardless
+// of which directory the caller cd'd into.
+func (f *freezableFS) key(filePath string) string {
+	return relativeTo(f.state.root, joinPath(f.FS.WorkingDirectory(), filePath))
+}
+
+// Freeze blocks writes, moves, and removals of the file/directory (and anything inside of it) until you
+// call the returned unfreeze function. It waits for any files inside of it that are being written to be
+// closed before returning.
+func (f *freezableFS) Freeze(filePath string) (func(), error) {
+	key := f.key(filePath)
+	state := f.state
+
+	state.mutex.Lock()
+	state.frozen[key]++
+	for state.writingWithin(key) {
+		state.released.Wait()
+	}
+	state.mutex.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			state.mutex.Lock()
+			defer state.mutex.Unlock()
+			if state.frozen[key]--; state.frozen[key] <= 0 {
+				delete(state.frozen, key)
+			}
+		})
+	}, nil
+}
+
+// Write opens the file for writing unless it's frozen.
+func (f *freezableFS) Write(filePath string) (WriterFile, error) {
+	key := f.key(filePath)
+	state := f.state
+
+	state.mutex.Lock()
+	if state.isFrozen(key) {
+		state.mutex.Unlock()
+		return nil, newPathError("freezable", "write", filePath, ErrFrozen)
+	}
+	state.writing[key]++
+	state.mutex.Unlock()
+
+	file, err := f.FS.Write(filePath)
+	if err != nil {
+		state.release(key)
+		return nil, err
+	}
+	return &freezableWriterFile{WriterFile: file, state: state, key: key}, nil
+}
+
+// Move relocates the file/directory unless the source or destination is frozen.
+func (f *freezableFS) Move(fromPath string, toPath string) error {
+	if f.state.check(f.key(fromPath)) {
+		return newPathError("freezable", "move", fromPath, ErrFrozen)
+	}
+	if f.state.check(f.key(toPath)) {
+		return newPathError("freezable", "move", toPath, ErrFrozen)
+	}
+	return f.FS.Move(fromPath, toPath)
+}
+
+// Remove deletes the file/directory unless it's frozen (or contains something that is).
+func (f *freezableFS) Remove(fileOrDirPath string) error {
+	if f.state.check(f.key(fileOrDirPath)) {
+		return newPathError("freezable", "remove", fileOrDirPath, ErrFrozen)
+	}
+	return f.FS.Remove(fileOrDirPath)
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that shares the same frozen paths.
+func (f *freezableFS) ChangeDirectory(dir string) FS {
+	return &freezableFS{FS: f.FS.ChangeDirectory(dir), state: f.state}
+}
+
+func (f *freezableFS) withContext(ctx context.Context) FS {
+	return &freezableFS{FS: ForRequest(f.FS, ctx), state: f.state}
+}
+
+func (f *freezableFS) requestContext() context.Context {
+	return RequestContext(f.FS)
+}
+
+func (f *freezableFS) wrapped() []FS {
+	return []FS{f.FS}
+}
+
+// check is the locking version of isFrozen().
+func (s *freezeState) check(key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.isFrozen(key)
+}
+
+// isFrozen returns true when the path is frozen, is inside of a frozen directory, or contains something
+// that's frozen. You must hold the mutex to call this.
+func (s *freezeState) isFrozen(key string) bool {
+	for frozenKey := range s.frozen {
+		if isWithinPath(frozenKey, key) || isWithinPath(key, frozenKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// writingWithin returns true when a file at/under the path is being written. You must hold the mutex
+// to call this.
+func (s *freezeState) writingWithin(key string) bool {
+	for writingKey := range s.writing {
+		if isWithinPath(key, writingKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// release records that one of the path's writers has been closed, waking up anyone waiting to freeze it.
+func (s *freezeState) release(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.writing[key]--; s.writing[key] <= 0 {
+		delete(s.writing, key)
+	}
+	s.released.Broadcast()
+}
+
+// freezableWriterFile lets anyone waiting to freeze the file know once it has been written.
+type freezableWriterFile struct {
+	WriterFile
+	state  *freezeState
+	key    string
+	closed bool
+}
+
+func (f *freezableWriterFile) Close() error {
+	if f.closed {
+		return f.WriterFile.Close()
+	}
+	f.closed = true
+	defer f.state.release(f.key)
+	return f.WriterFile.Close()
+}
+
+var _ Freezer = &freezableFS{}
+var _ requestBinder = &freezableFS{}
+var _ wrapper = &freezableFS{}
@@ -0,0 +1,102 @@
+package filestore_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type FreezeTestSuite struct {
+	suite.Suite
+	fs filestore.FS
+}
+
+func TestFreezeTestSuite(t *testing.T) {
+	suite.Run(t, &FreezeTestSuite{})
+}
+
+func (s *FreezeTestSuite) SetupTest() {
+	s.fs = filestore.Freezable(filestore.Memory())
+	s.Require().NoError(writeString(s.fs, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.fs, "duderino/5.lebowski", "jackie"))
+	s.Require().NoError(writeString(s.fs, "duderino/6.lebowski", "nihilist"))
+}
+
+func (s *FreezeTestSuite) TestFreeze() {
+	unfreeze, err := filestore.Freeze(s.fs, "duderino")
+	s.Require().NoError(err)
+
+	s.Require().True(errors.Is(writeString(s.fs, "duderino/5.lebowski", "nope"), filestore.ErrFrozen))
+	s.Require().True(errors.Is(writeString(s.fs, "duderino/7.lebowski", "nope"), filestore.ErrFrozen))
+	s.Require().True(errors.Is(s.fs.Remove("duderino/6.lebowski"), filestore.ErrFrozen))
+	s.Require().True(errors.Is(s.fs.Remove("."), filestore.ErrFrozen), "Can't remove dirs w/ frozen content")
+	s.Require().True(errors.Is(s.fs.Move("duderino", "dude"), filestore.ErrFrozen))
+	s.Require().True(errors.Is(s.fs.Move("1.lebowski", "duderino/1.lebowski"), filestore.ErrFrozen))
+
+	dir := s.fs.ChangeDirectory("duderino")
+	s.Require().True(errors.Is(dir.Remove("5.lebowski"), filestore.ErrFrozen), "Should apply no matter the working directory")
+
+	content, err := readString(s.fs, "duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jackie", content, "Frozen files should still be readable")
+	s.Require().NoError(writeString(s.fs, "2.lebowski", "maude"), "Other files should be unaffected")
+	s.Require().NoError(s.fs.Move("2.lebowski", "3.lebowski"))
+
+	unfreeze()
+	unfreeze() // Should be safe to call more than once.
+	s.Require().NoError(writeString(s.fs, "duderino/7.lebowski", "walter"))
+	s.Require().NoError(s.fs.Remove("duderino"))
+}
+
+func (s *FreezeTestSuite) TestFreeze_overlapping() {
+	unfreezeDir, err := filestore.Freeze(s.fs, "duderino")
+	s.Require().NoError(err)
+	unfreezeFile, err := filestore.Freeze(s.fs.ChangeDirectory("duderino"), "5.lebowski")
+	s.Require().NoError(err)
+
+	unfreezeDir()
+	s.Require().NoError(writeString(s.fs, "duderino/6.lebowski", "the dude"))
+	s.Require().True(errors.Is(writeString(s.fs, "duderino/5.lebowski", "nope"), filestore.ErrFrozen))
+	unfreezeFile()
+	s.Require().NoError(writeString(s.fs, "duderino/5.lebowski", "the dude"))
+}
+
+func (s *FreezeTestSuite) TestFreeze_waitsForWriters() {
+	file, err := s.fs.Write("duderino/5.lebowski")
+	s.Require().NoError(err)
+
+	frozen := make(chan func())
+	go func() {
+		unfreeze, _ := filestore.Freeze(s.fs, "duderino")
+		frozen <- unfreeze
+	}()
+
+	select {
+	case <-frozen:
+		s.Fail("Should wait for the open file to be closed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	_, err = file.Write([]byte("bunny"))
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+	unfreeze := <-frozen
+	defer unfreeze()
+
+	content, err := readString(s.fs, "duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("bunny", content)
+}
+
+func (s *FreezeTestSuite) TestFreeze_notSupported() {
+	_, err := filestore.Freeze(filestore.Memory(), "duderino")
+	s.Require().True(errors.Is(err, filestore.ErrFreezeNotSupported))
+
+	fs, err := filestore.FromConfig(filestore.Config{URL: "mem://", Layers: []filestore.LayerConfig{{Type: "freezable"}}})
+	s.Require().NoError(err)
+	_, err = filestore.Freeze(fs, "duderino")
+	s.Require().NoError(err)
+}
@@ -1,10 +1,13 @@
 package filestore
 
 import (
+	"errors"
 	"io"
 	"io/fs"
 	"path/filepath"
 	"strings"
+	"time"
+	"unsafe"
 )
 
 // ReaderFile encapsulates a file within a file system that you can read from.
@@ -78,6 +81,10 @@ type FS interface {
 	Move(fromPath string, toPath string) error
 }
 
+// ErrReadOnly is the error returned when you try to write, remove, or move files in a store (or view
+// of a store) that does not support modifications.
+var ErrReadOnly = errors.New("filestore: read-only file system")
+
 // FileFilter provides a way to exclude files/directories from a list/search.
 type FileFilter func(info FileInfo) bool
 
@@ -122,9 +129,89 @@ func WithPattern(pattern string) FileFilter {
 		return WithEverything()
 	}
 
-	return func(f FileInfo) bool {
-		matched, err := filepath.Match(pattern, f.Name())
+	// Everything before the first wildcard must match literally, so remote stores can use that
+	// to narrow their listing queries.
+	literal := pattern
+	if index := strings.IndexAny(pattern, `*?[\`); index >= 0 {
+		literal = pattern[:index]
+	}
+	return prefixFilter(literal, func(name string) bool {
+		matched, err := filepath.Match(pattern, name)
 		return matched && err == nil
+	})
+}
+
+// WithPrefix only allows files to pass through whose names start with the given prefix. Stores
+// backed by remote object storage (e.g. S3) use the prefix to narrow the query they send to the
+// provider rather than fetching the whole directory and filtering it locally.
+//
+// Example:
+//
+//	// Only fetch the reports from 2022, even if the directory contains 10 years' worth.
+//	reports, err := myFS.List("reports", filestore.WithPrefix("2022-"))
+func WithPrefix(prefix string) FileFilter {
+	return prefixFilter(prefix, func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	})
+}
+
+// prefixFilter builds the filters for WithPrefix() and WithPattern(), which only accept names that start w/
+// the given literal prefix (and satisfy the matches predicate). When probed directly (see filterPrefix), the
+// filter reports that prefix instead of matching anything.
+func prefixFilter(prefix string, matches func(name string) bool) FileFilter {
+	var filter FileFilter
+	filter = func(f FileInfo) bool {
+		if probe, ok := f.(*prefixProbe); ok {
+			return probe.offer(filter, prefix)
+		}
+		return matches(f.Name())
+	}
+	return filter
+}
+
+// WithName only allows files to pass through whose names satisfy the given predicate. Since it
+// never looks at anything but the name, stores can evaluate it w/o fetching each file's full
+// metadata (e.g. stat() calls on disk), which matters when scanning very large directories.
+//
+// Example:
+//
+//	// Only the numbered log segments, like "app.log.1", "app.log.2", etc.
+//	segments, err := myFS.List("logs", filestore.WithName(func(name string) bool {
+//	    return segmentRegex.MatchString(name)
+//	}))
+func WithName(match func(name string) bool) FileFilter {
+	return func(f FileInfo) bool {
+		return match(f.Name())
+	}
+}
+
+// WithCreatedAfter only allows files to pass through that were originally created after the given
+// time. Files whose creation time can not be determined (see CreationTime) are always rejected, so
+// that retention rules based on this filter err on the side of keeping files around.
+//
+// Example:
+//
+//	// Only files created in the last 30 days.
+//	recent, err := myFS.List("invoices", filestore.WithCreatedAfter(time.Now().AddDate(0, 0, -30)))
+func WithCreatedAfter(t time.Time) FileFilter {
+	return func(f FileInfo) bool {
+		created, ok := CreationTime(f)
+		return ok && created.After(t)
+	}
+}
+
+// WithCreatedBefore only allows files to pass through that were originally created before the given
+// time. Files whose creation time can not be determined (see CreationTime) are always rejected, so
+// that retention rules based on this filter err on the side of keeping files around.
+//
+// Example:
+//
+//	// Only files created more than 7 years ago.
+//	expired, err := myFS.List("invoices", filestore.WithCreatedBefore(time.Now().AddDate(-7, 0, 0)))
+func WithCreatedBefore(t time.Time) FileFilter {
+	return func(f FileInfo) bool {
+		created, ok := CreationTime(f)
+		return ok && created.Before(t)
 	}
 }
 
@@ -135,3 +222,122 @@ func WithEverything() FileFilter {
 		return true
 	}
 }
+
+// applyFilter determines whether the file makes it through the filter. Filters are often supplied by
+// plugins we know nothing about, so a filter that panics fails the operation w/ a *PanicError instead of
+// crashing the process.
+func applyFilter(filter FileFilter, info FileInfo) (matches bool, err error) {
+	defer recoverPanic(&err)
+	return filter(info), nil
+}
+
+// fileMatchesFilters determines whether the file makes it through all of the given filters.
+func fileMatchesFilters(file FileInfo, filters []FileFilter) (bool, error) {
+	for _, filter := range filters {
+		if matches, err := applyFilter(filter, file); !matches || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// filterFiles returns just the files that make it through all of the given filters, in the same order.
+func filterFiles(infos []FileInfo, filters []FileFilter) ([]FileInfo, error) {
+	var results []FileInfo
+	for _, info := range infos {
+		matches, err := fileMatchesFilters(info, filters)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// filtersPrefix determines the longest literal name prefix that a file must have in order to make it
+// through all of the given filters (e.g. "2022-" for WithPattern("2022-*.csv")). This is an empty string
+// when the filters don't constrain the prefix, or they're custom filters we know nothing about. The
+// filters must still be applied to the results; this only lets stores narrow their queries.
+//
+// Only filters that are themselves WithPrefix()/WithPattern() filters count. One of those used inside
+// a custom filter (e.g. negated or OR'd w/ another one) doesn't tell us anything about the prefix.
+func filtersPrefix(filters []FileFilter) string {
+	longest := ""
+	for _, filter := range filters {
+		if prefix, ok := filterPrefix(filter); ok && len(prefix) > len(longest) {
+			longest = prefix
+		}
+	}
+	return longest
+}
+
+// nameFilters returns just the WithPrefix()/WithPattern() filters, which only ever look at a file's name.
+// Wrappers that describe files differently than the store they wrap (e.g. decrypted sizes) can safely pass
+// these along to narrow the listing, but they have to apply the rest of the filters themselves.
+func nameFilters(filters []FileFilter) []FileFilter {
+	var results []FileFilter
+	for _, filter := range filters {
+		if _, ok := filterPrefix(filter); ok {
+			results = append(results, filter)
+		}
+	}
+	return results
+}
+
+// listDescribed lists the directory in the wrapped store, applying the filters to each entry as the wrapper
+// describes it (see describe) rather than as the wrapped store does, so filters that look at sizes see the
+// same sizes that the wrapper reports.
+func listDescribed(fs FS, dirPath string, filters []FileFilter, describe func(FileInfo) FileInfo) ([]FileInfo, error) {
+	infos, err := fs.List(dirPath, nameFilters(filters)...)
+	if err != nil {
+		return nil, err
+	}
+	for i, info := range infos {
+		infos[i] = describe(info)
+	}
+	return filterFiles(infos, filters)
+}
+
+// filterPrefix asks the filter what literal name prefix it requires, reporting false if it is not a
+// WithPrefix()/WithPattern() filter. A custom filter that calls one of those passes our probe along to it,
+// so we only accept the answer when it comes from the very filter we probed.
+func filterPrefix(filter FileFilter) (string, bool) {
+	if filter == nil {
+		return "", false
+	}
+	probe := &prefixProbe{target: filterIdentity(filter)}
+	_, _ = applyFilter(filter, probe)
+	return probe.prefix, probe.answered
+}
+
+// filterIdentity distinguishes one filter value from another. Funcs aren't comparable, but every filter
+// built by prefixFilter() refers to its own closure, which copies of that filter share.
+func filterIdentity(filter FileFilter) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&filter))
+}
+
+// prefixProbe is a placeholder FileInfo that we pass to a filter in order to ask it what literal name
+// prefix it requires.
+type prefixProbe struct {
+	target   unsafe.Pointer
+	prefix   string
+	answered bool
+}
+
+// offer records the prefix required by the given filter, ignoring any filter but the one being probed.
+func (probe *prefixProbe) offer(filter FileFilter, prefix string) bool {
+	if filterIdentity(filter) == probe.target {
+		probe.prefix = prefix
+		probe.answered = true
+	}
+	return true
+}
+
+func (probe *prefixProbe) Name() string       { return "" }
+func (probe *prefixProbe) Size() int64        { return 0 }
+func (probe *prefixProbe) Mode() fs.FileMode  { return 0 }
+func (probe *prefixProbe) ModTime() time.Time { return time.Time{} }
+func (probe *prefixProbe) IsDir() bool        { return false }
+func (probe *prefixProbe) Sys() any           { return nil }
@@ -271,6 +271,54 @@ func (s *FSTestSuite) TestWithEverything() {
 	)
 }
 
+func (s *FSTestSuite) TestWithPrefix() {
+	s.allowName(filestore.WithPrefix(""),
+		"",
+		"foo",
+	)
+	s.allowName(filestore.WithPrefix("2022-"),
+		"2022-",
+		"2022-01.csv",
+		"2022-12-31.csv",
+	)
+	s.rejectName(filestore.WithPrefix("2022-"),
+		"",
+		"2022",
+		"2021-01.csv",
+		"backup-2022-01.csv",
+	)
+}
+
+func (s *FSTestSuite) TestWithName() {
+	filter := filestore.WithName(func(name string) bool {
+		return len(name) == 3
+	})
+	s.allowName(filter, "foo", "bar", "a.b")
+	s.rejectName(filter, "", "fo", "food", "foo.txt")
+}
+
+func (s *FSTestSuite) TestWithCreatedAfter() {
+	now := time.Now()
+	filter := filestore.WithCreatedAfter(now)
+
+	s.Require().True(filter(fakeFileInfo{name: "a", created: now.Add(time.Second)}), "Should allow files created after cutoff")
+	s.Require().True(filter(fakeFileInfo{name: "a", created: now.Add(24 * time.Hour)}), "Should allow files created after cutoff")
+	s.Require().False(filter(fakeFileInfo{name: "a", created: now}), "Should NOT allow files created exactly at cutoff")
+	s.Require().False(filter(fakeFileInfo{name: "a", created: now.Add(-time.Second)}), "Should NOT allow files created before cutoff")
+	s.Require().False(filter(fakeFileInfo{name: "a"}), "Should NOT allow files w/ unknown creation time")
+}
+
+func (s *FSTestSuite) TestWithCreatedBefore() {
+	now := time.Now()
+	filter := filestore.WithCreatedBefore(now)
+
+	s.Require().True(filter(fakeFileInfo{name: "a", created: now.Add(-time.Second)}), "Should allow files created before cutoff")
+	s.Require().True(filter(fakeFileInfo{name: "a", created: now.Add(-24 * time.Hour)}), "Should allow files created before cutoff")
+	s.Require().False(filter(fakeFileInfo{name: "a", created: now}), "Should NOT allow files created exactly at cutoff")
+	s.Require().False(filter(fakeFileInfo{name: "a", created: now.Add(time.Second)}), "Should NOT allow files created after cutoff")
+	s.Require().False(filter(fakeFileInfo{name: "a"}), "Should NOT allow files w/ unknown creation time")
+}
+
 func TestFSTestSuite(t *testing.T) {
 	suite.Run(t, &FSTestSuite{})
 }
@@ -282,6 +330,7 @@ type fakeFileInfo struct {
 	modTime time.Time
 	dir     bool
 	sys     any
+	created time.Time
 }
 
 func (f fakeFileInfo) Name() string {
@@ -307,3 +356,7 @@ func (f fakeFileInfo) IsDir() bool {
 func (f fakeFileInfo) Sys() any {
 	return f.sys
 }
+
+func (f fakeFileInfo) CreationTime() (time.Time, bool) {
+	return f.created, !f.created.IsZero()
+}
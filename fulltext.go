@@ -0,0 +1,205 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ContentIndexer is implemented by your full-text search engine of choice (bleve, Elasticsearch, etc.) so
+// that ContentIndexed() can keep it in sync w/ the files in a store. Paths are always full paths (i.e. they
+// include the store's working directory) so they're the same regardless of which directory you're in.
+type ContentIndexer interface {
+	// IndexContent adds/replaces the contents of the file at the given path.
+	IndexContent(fullPath string, content io.Reader) error
+	// RemoveContent removes the file at the given path from the index. This is called for every file that
+	// is moved or removed, even ones that were never indexed, so it should succeed if the file isn't there.
+	RemoveContent(fullPath string) error
+}
+
+// ContentIndexed wraps a file store so that the contents of text files are fed to your full-text indexer as
+// they're written, and files are removed from (or re-indexed in) the index as they're removed or moved. The
+// filter decides which files to index. If it's nil, we sniff each file's contents (see
+// http.DetectContentType) and index anything that looks like text, which includes JSON, XML, CSV, etc.
+//
+// The file is indexed when you close it, and it's read back from the store to do so. If the indexer fails,
+// Close() returns its error, but the file has still been written. Only changes made through this wrapper
+// (or a store derived from it using ChangeDirectory) are indexed.
+//
+// Example:
+//
+//	docs := filestore.ContentIndexed(filestore.Disk("docs"), bleveIndexer, filestore.WithExts("md", "txt"))
+//	file, err := docs.Write("guides/setup.md")
+//	...
+//	err = file.Close()                                      // indexed
+//	err = docs.Move("guides/setup.md", "guides/install.md") // re-indexed as guides/install.md
+func ContentIndexed(fs FS, indexer ContentIndexer, filter FileFilter) FS {
+	return &contentIndexedFS{FS: fs, indexer: indexer, filter: filter}
+}
+
+type contentIndexedFS struct {
+	FS
+	indexer ContentIndexer
+	filter  FileFilter
+}
+
+// Write opens the file for writing. Its contents are indexed once you close it.
+func (c *contentIndexedFS) Write(filePath string) (WriterFile, error) {
+	file, err := c.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &contentIndexedWriterFile{WriterFile: file, fs: c, path: filePath}, nil
+}
+
+// Move relocates the file/directory, moving each of the files it contains to its new path in the index.
+func (c *contentIndexedFS) Move(fromPath string, toPath string) error {
+	files, err := c.files(fromPath)
+	if err != nil {
+		return fmt.Errorf("filestore: content indexed: move: %w", err)
+	}
+	replaced, err := c.files(toPath)
+	if err != nil {
+		return fmt.Errorf("filestore: content indexed: move: %w", err)
+	}
+	if err = c.FS.Move(fromPath, toPath); err != nil {
+		return err
+	}
+
+	// Anything that used to be at the destination was overwritten, so it shouldn't be in the index either.
+	for _, filePath := range append(replaced, files...) {
+		if err = c.indexer.RemoveContent(c.fullPath(filePath)); err != nil {
+			return fmt.Errorf("filestore: content indexed: move: %w", err)
+		}
+	}
+	for _, filePath := range files {
+		if err = c.index(toPath + strings.TrimPrefix(filePath, fromPath)); err != nil {
+			return fmt.Errorf("filestore: content indexed: move: %w", err)
+		}
+	}
+	return nil
+}
+
+// Remove deletes the file/directory, removing each of the files it contains from the index.
+func (c *contentIndexedFS) Remove(fileOrDirPath string) error {
+	files, err := c.files(fileOrDirPath)
+	if err != nil {
+		return fmt.Errorf("filestore: content indexed: remove: %w", err)
+	}
+	if err = c.FS.Remove(fileOrDirPath); err != nil {
+		return err
+	}
+	for _, filePath := range files {
+		if err = c.indexer.RemoveContent(c.fullPath(filePath)); err != nil {
+			return fmt.Errorf("filestore: content indexed: remove: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *contentIndexedFS) ChangeDirectory(dir string) FS {
+	return &contentIndexedFS{FS: c.FS.ChangeDirectory(dir), indexer: c.indexer, filter: c.filter}
+}
+
+func (c *contentIndexedFS) withContext(ctx context.Context) FS {
+	return &contentIndexedFS{FS: ForRequest(c.FS, ctx), indexer: c.indexer, filter: c.filter}
+}
+
+func (c *contentIndexedFS) requestContext() context.Context {
+	return RequestContext(c.FS)
+}
+
+func (c *contentIndexedFS) wrapped() []FS {
+	return []FS{c.FS}
+}
+
+func (c *contentIndexedFS) fullPath(filePath string) string {
+	return joinPath(c.FS.WorkingDirectory(), filePath)
+}
+
+// files returns the path of the file, or of every file in the directory, so that we know what to remove from
+// the index once it's gone. It returns nothing if the file doesn't exist.
+func (c *contentIndexedFS) files(fileOrDirPath string) ([]string, error) {
+	if !c.FS.Exists(fileOrDirPath) {
+		return nil, nil
+	}
+	var files []string
+	err := Walk(c.FS, fileOrDirPath, func(filePath string, info FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files = append(files, filePath)
+		}
+		return err
+	})
+	return files, err
+}
+
+// index feeds the contents of the file to the indexer if it passes the filter (or looks like text). Otherwise,
+// we make sure the index doesn't have any stale content from a file that used to be at this path.
+func (c *contentIndexedFS) index(filePath string) error {
+	if c.filter != nil {
+		info, err := c.FS.Stat(filePath)
+		if err != nil {
+			return err
+		}
+		matches, err := applyFilter(c.filter, info)
+		if err != nil {
+			return err
+		}
+		if !matches {
+			return c.indexer.RemoveContent(c.fullPath(filePath))
+		}
+	}
+
+	file, err := c.FS.Read(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var content io.Reader = file
+	if c.filter == nil {
+		head := make([]byte, 512)
+		n, err := io.ReadFull(file, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		if !isTextContent(head[:n]) {
+			return c.indexer.RemoveContent(c.fullPath(filePath))
+		}
+		content = io.MultiReader(bytes.NewReader(head[:n]), file)
+	}
+	return c.indexer.IndexContent(c.fullPath(filePath), content)
+}
+
+// isTextContent sniffs the first few bytes of a file to see if it's something worth putting in a full-text index.
+func isTextContent(head []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(head), "text/")
+}
+
+// contentIndexedWriterFile indexes the file's contents once it has been written.
+type contentIndexedWriterFile struct {
+	WriterFile
+	fs     *contentIndexedFS
+	path   string
+	closed bool
+}
+
+func (f *contentIndexedWriterFile) Close() error {
+	if f.closed {
+		return f.WriterFile.Close()
+	}
+	f.closed = true
+	if err := f.WriterFile.Close(); err != nil {
+		return err
+	}
+	if err := f.fs.index(f.path); err != nil {
+		return fmt.Errorf("filestore: content indexed: %w", err)
+	}
+	return nil
+}
+
+var _ requestBinder = &contentIndexedFS{}
+var _ wrapper = &contentIndexedFS{}
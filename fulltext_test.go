@@ -0,0 +1,130 @@
+package filestore_test
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ContentIndexedTestSuite struct {
+	suite.Suite
+	indexer *fakeIndexer
+	inner   filestore.FS
+	fs      filestore.FS
+}
+
+func TestContentIndexedTestSuite(t *testing.T) {
+	suite.Run(t, &ContentIndexedTestSuite{})
+}
+
+func (s *ContentIndexedTestSuite) SetupTest() {
+	s.indexer = &fakeIndexer{content: map[string]string{}}
+	s.inner = filestore.Memory()
+	s.fs = filestore.ContentIndexed(s.inner, s.indexer, nil)
+}
+
+func (s *ContentIndexedTestSuite) TestWrite() {
+	s.Require().NoError(writeString(s.fs, "guides/setup.md", "# Setup\nThe dude abides."))
+	s.Require().NoError(writeString(s.fs, "data/dude.json", `{"name": "the dude"}`))
+	s.Require().NoError(writeString(s.fs, "images/dude.png", "\x89PNG\r\n\x1a\nbinary"))
+	s.Require().Equal(map[string]string{
+		"/guides/setup.md": "# Setup\nThe dude abides.",
+		"/data/dude.json":  `{"name": "the dude"}`,
+	}, s.indexer.content, "Should only index files that look like text")
+
+	// Overwriting a text file w/ something else should drop its old content.
+	s.Require().NoError(writeString(s.fs, "guides/setup.md", "\x00\x01\x02"))
+	s.Require().Equal([]string{"/data/dude.json"}, s.indexer.paths())
+}
+
+func (s *ContentIndexedTestSuite) TestWrite_filter() {
+	fs := filestore.ContentIndexed(s.inner, s.indexer, filestore.WithExt("md"))
+	s.Require().NoError(writeString(fs, "guides/setup.md", "# Setup"))
+	s.Require().NoError(writeString(fs, "guides/notes.txt", "not indexed"))
+	s.Require().Equal([]string{"/guides/setup.md"}, s.indexer.paths())
+
+	s.indexer.err = errors.New("index is down")
+	s.Require().Error(writeString(fs, "guides/install.md", "# Install"))
+	s.Require().True(s.inner.Exists("guides/install.md"), "Should still write the file")
+}
+
+func (s *ContentIndexedTestSuite) TestMove() {
+	s.Require().NoError(writeString(s.fs, "guides/setup.md", "setup"))
+	s.Require().NoError(writeString(s.fs, "guides/install.md", "install"))
+	s.Require().NoError(writeString(s.fs, "archive/old.md", "old"))
+
+	s.Require().NoError(s.fs.Move("guides/setup.md", "guides/getting-started.md"))
+	s.Require().Equal([]string{"/archive/old.md", "/guides/getting-started.md", "/guides/install.md"}, s.indexer.paths())
+	s.Require().Equal("setup", s.indexer.content["/guides/getting-started.md"])
+
+	s.Require().NoError(s.fs.Move("guides", "archive/guides"))
+	s.Require().Equal([]string{"/archive/guides/getting-started.md", "/archive/guides/install.md", "/archive/old.md"},
+		s.indexer.paths())
+
+	s.Require().NoError(s.fs.Move("archive/guides/install.md", "archive/old.md"))
+	s.Require().Equal([]string{"/archive/guides/getting-started.md", "/archive/old.md"}, s.indexer.paths())
+	s.Require().Equal("install", s.indexer.content["/archive/old.md"])
+}
+
+func (s *ContentIndexedTestSuite) TestRemove() {
+	s.Require().NoError(writeString(s.fs, "guides/setup.md", "setup"))
+	s.Require().NoError(writeString(s.fs, "guides/install.md", "install"))
+	s.Require().NoError(writeString(s.fs, "readme.md", "readme"))
+
+	s.Require().NoError(s.fs.Remove("readme.md"))
+	s.Require().Equal([]string{"/guides/install.md", "/guides/setup.md"}, s.indexer.paths())
+	s.Require().NoError(s.fs.Remove("guides"))
+	s.Require().Empty(s.indexer.paths())
+	s.Require().NoError(s.fs.Remove("missing.md"))
+}
+
+func (s *ContentIndexedTestSuite) TestChangeDirectory() {
+	guides := s.fs.ChangeDirectory("guides")
+	s.Require().NoError(writeString(guides, "setup.md", "setup"))
+	s.Require().NoError(guides.Move("setup.md", "install.md"))
+	s.Require().Equal([]string{"/guides/install.md"}, s.indexer.paths(), "Should use full paths")
+}
+
+// fakeIndexer keeps the content of every indexed file in memory.
+type fakeIndexer struct {
+	mutex   sync.Mutex
+	content map[string]string
+	err     error
+}
+
+func (f *fakeIndexer) IndexContent(fullPath string, content io.Reader) error {
+	if f.err != nil {
+		return f.err
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.content[fullPath] = string(data)
+	return nil
+}
+
+func (f *fakeIndexer) RemoveContent(fullPath string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.content, fullPath)
+	return nil
+}
+
+func (f *fakeIndexer) paths() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	paths := []string{}
+	for fullPath := range f.content {
+		paths = append(paths, fullPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -2,7 +2,16 @@ module github.com/monadicstack/filestore
 
 go 1.19
 
-require github.com/stretchr/testify v1.8.0
+require (
+	filippo.io/age v1.2.1
+	github.com/klauspost/compress v1.17.6
+	github.com/mattn/go-sqlite3 v1.14.17
+	github.com/spf13/afero v1.9.5
+	github.com/stretchr/testify v1.8.0
+	golang.org/x/crypto v0.24.0
+	golang.org/x/sys v0.21.0
+	golang.org/x/text v0.16.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,287 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HandleTracker is an optional capability for stores that keep track of which files are open, so that you
+// can find the code paths that forget to close them.
+type HandleTracker interface {
+	// OpenHandles returns every file that has been opened for reading/writing but hasn't been closed yet.
+	OpenHandles() []OpenHandle
+}
+
+// HandleMode indicates whether an OpenHandle was opened for reading or writing.
+type HandleMode string
+
+const (
+	// HandleRead indicates that the file was opened using Read().
+	HandleRead HandleMode = "read"
+	// HandleWrite indicates that the file was opened using Write().
+	HandleWrite HandleMode = "write"
+)
+
+// OpenHandle describes a single file that is still open.
+type OpenHandle struct {
+	// Path is the full path of the file, including the working directory of the store that opened it.
+	Path string
+	// Mode indicates whether the file was opened for reading or writing.
+	Mode HandleMode
+	// Opened is when the file was opened.
+	Opened time.Time
+	// Stack is the stack trace of the code that opened the file. It's only captured when you supply the
+	// WithHandleStacks() option since it's fairly expensive.
+	Stack string
+}
+
+// ErrLeakedHandles is the error that TrackHandles() stores return when you close them while files are
+// still open. The actual error is a *LeakedHandlesError, which tells you which files they are.
+var ErrLeakedHandles = errors.New("filestore: leaked file handles")
+
+// LeakedHandlesError is the error that TrackHandles() stores return when you close them while files are
+// still open.
+type LeakedHandlesError struct {
+	// Handles are the files that are still open, oldest first.
+	Handles []OpenHandle
+}
+
+func (e *LeakedHandlesError) Error() string {
+	paths := make([]string, len(e.Handles))
+	for i, handle := range e.Handles {
+		paths[i] = fmt.Sprintf("%s (%s)", handle.Path, handle.Mode)
+	}
+	return fmt.Sprintf("filestore: %d leaked file handles: %s", len(e.Handles), strings.Join(paths, ", "))
+}
+
+func (e *LeakedHandlesError) Unwrap() error {
+	return ErrLeakedHandles
+}
+
+// handleOptions contains the settings that only apply to TrackHandles().
+type handleOptions struct {
+	stacks bool
+}
+
+// WithHandleStacks makes TrackHandles() capture the stack trace of the code that opens each file, so
+// you can see exactly where a leaked handle came from. This is fairly expensive, so it's best saved for
+// debugging and tests.
+func WithHandleStacks() Option {
+	return func(opts *options) {
+		opts.handles.stacks = true
+	}
+}
+
+// OpenHandles returns every file that the store has opened but that hasn't been closed yet, oldest first,
+// if the store supports the HandleTracker capability (see TrackHandles). For all other stores, it returns
+// nil since there's no way to know.
+//
+// Example:
+//
+//	for _, handle := range filestore.OpenHandles(files) {
+//	    log.Printf("%s has been open since %v:\n%s", handle.Path, handle.Opened, handle.Stack)
+//	}
+func OpenHandles(fs FS) []OpenHandle {
+	if tracker, ok := fs.(HandleTracker); ok {
+		return tracker.OpenHandles()
+	}
+	return nil
+}
+
+// TrackHandles wraps a file store so that it supports the HandleTracker capability, keeping track of every
+// file opened through it until it's closed. Use OpenHandles() to see what's open right now (e.g. from a
+// debug endpoint), or shut the store down w/ Shutdown() to find out what leaked; it fails w/ a
+// *LeakedHandlesError if anything is still open. Supply WithHandleStacks() to capture where each file was
+// opened and WithClock() to control the opened times in tests.
+//
+// Files opened through stores derived from the wrapper via ChangeDirectory() or ForRequest() are
+// tracked together.
+//
+// Example:
+//
+//	files := filestore.TrackHandles(filestore.Disk("data"), filestore.WithHandleStacks())
+//	...
+//	if err := filestore.Shutdown(ctx, files); errors.Is(err, filestore.ErrLeakedHandles) {
+//	    log.Printf("someone forgot to close a file: %v", err)
+//	}
+func TrackHandles(fs FS, opts ...Option) FS {
+	options := newOptions(opts)
+	return &trackedFS{FS: fs, handles: &openHandles{
+		clock:   options.clock,
+		stacks:  options.handles.stacks,
+		handles: map[*OpenHandle]struct{}{},
+	}}
+}
+
+func init() {
+	RegisterLayer("track_handles", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Stacks bool `json:"stacks"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		if settings.Stacks {
+			return TrackHandles(fs, WithHandleStacks()), nil
+		}
+		return TrackHandles(fs), nil
+	})
+}
+
+type trackedFS struct {
+	FS
+	handles *openHandles
+}
+
+// openHandles is the set of files that are still open, shared by the original wrapper and any instances
+// derived from it.
+type openHandles struct {
+	mutex   sync.Mutex
+	clock   Clock
+	stacks  bool
+	handles map[*OpenHandle]struct{}
+}
+
+// Read opens the file for reading, tracking it until you close it.
+func (t *trackedFS) Read(filePath string) (ReaderFile, error) {
+	file, err := t.FS.Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &trackedReaderFile{ReaderFile: file, handles: t.handles, handle: t.handles.open(t.fullPath(filePath), HandleRead)}, nil
+}
+
+// Write opens the file for writing, tracking it until you close it.
+func (t *trackedFS) Write(filePath string) (WriterFile, error) {
+	file, err := t.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &trackedWriterFile{WriterFile: file, handles: t.handles, handle: t.handles.open(t.fullPath(filePath), HandleWrite)}, nil
+}
+
+// OpenHandles returns every file opened through the wrapper that hasn't been closed yet, oldest first.
+func (t *trackedFS) OpenHandles() []OpenHandle {
+	t.handles.mutex.Lock()
+	defer t.handles.mutex.Unlock()
+
+	results := make([]OpenHandle, 0, len(t.handles.handles))
+	for handle := range t.handles.handles {
+		results = append(results, *handle)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Opened.Equal(results[j].Opened) {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].Opened.Before(results[j].Opened)
+	})
+	return results
+}
+
+// Close shuts down the underlying store, failing w/ a *LeakedHandlesError if any files opened through the
+// wrapper are still open. It does not close them for you.
+func (t *trackedFS) Close(ctx context.Context) error {
+	err := Shutdown(ctx, t.FS)
+	leaked := t.OpenHandles()
+	switch {
+	case len(leaked) == 0:
+		return err
+	case err != nil:
+		return joinErrors(&LeakedHandlesError{Handles: leaked}, err)
+	default:
+		return &LeakedHandlesError{Handles: leaked}
+	}
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that tracks files along w/ this one.
+func (t *trackedFS) ChangeDirectory(dir string) FS {
+	return &trackedFS{FS: t.FS.ChangeDirectory(dir), handles: t.handles}
+}
+
+func (t *trackedFS) withContext(ctx context.Context) FS {
+	return &trackedFS{FS: ForRequest(t.FS, ctx), handles: t.handles}
+}
+
+func (t *trackedFS) requestContext() context.Context {
+	return RequestContext(t.FS)
+}
+
+func (t *trackedFS) wrapped() []FS {
+	return []FS{t.FS}
+}
+
+func (t *trackedFS) fullPath(filePath string) string {
+	return joinPath(t.FS.WorkingDirectory(), filePath)
+}
+
+// open starts tracking a file that was just opened.
+func (h *openHandles) open(fullPath string, mode HandleMode) *OpenHandle {
+	handle := &OpenHandle{Path: fullPath, Mode: mode, Opened: h.clock.Now()}
+	if h.stacks {
+		handle.Stack = callerStack()
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.handles[handle] = struct{}{}
+	return handle
+}
+
+// close stops tracking a file once it's been closed.
+func (h *openHandles) close(handle *OpenHandle) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.handles, handle)
+}
+
+// callerStack formats the stack trace of whoever called into the wrapper, leaving out the wrapper's
+// own frames.
+func callerStack() string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(4, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	stack := strings.Builder{}
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&stack, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			return stack.String()
+		}
+	}
+}
+
+// trackedReaderFile stops tracking the file once it's closed.
+type trackedReaderFile struct {
+	ReaderFile
+	handles *openHandles
+	handle  *OpenHandle
+}
+
+func (f *trackedReaderFile) Close() error {
+	f.handles.close(f.handle)
+	return f.ReaderFile.Close()
+}
+
+// trackedWriterFile stops tracking the file once it's closed.
+type trackedWriterFile struct {
+	WriterFile
+	handles *openHandles
+	handle  *OpenHandle
+}
+
+func (f *trackedWriterFile) Close() error {
+	f.handles.close(f.handle)
+	return f.WriterFile.Close()
+}
+
+var _ HandleTracker = &trackedFS{}
+var _ Closer = &trackedFS{}
+var _ requestBinder = &trackedFS{}
+var _ wrapper = &trackedFS{}
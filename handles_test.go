@@ -0,0 +1,80 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type TrackHandlesTestSuite struct {
+	suite.Suite
+	clock *filestoretest.Clock
+	fs    filestore.FS
+}
+
+func TestTrackHandlesTestSuite(t *testing.T) {
+	suite.Run(t, &TrackHandlesTestSuite{})
+}
+
+func (s *TrackHandlesTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.fs = filestore.TrackHandles(filestore.Memory(), filestore.WithClock(s.clock))
+	s.Require().NoError(writeString(s.fs, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.fs, "duderino/5.lebowski", "jackie"))
+}
+
+func (s *TrackHandlesTestSuite) TestOpenHandles() {
+	s.Require().Empty(filestore.OpenHandles(s.fs), "Should not count files that were closed")
+
+	reader, err := s.fs.Read("1.lebowski")
+	s.Require().NoError(err)
+	s.clock.Advance(time.Minute)
+	writer, err := s.fs.ChangeDirectory("duderino").Write("6.lebowski")
+	s.Require().NoError(err)
+
+	s.Require().Equal([]filestore.OpenHandle{
+		{Path: "/1.lebowski", Mode: filestore.HandleRead, Opened: time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC)},
+		{Path: "/duderino/6.lebowski", Mode: filestore.HandleWrite, Opened: time.Date(2022, 9, 6, 12, 1, 0, 0, time.UTC)},
+	}, filestore.OpenHandles(s.fs))
+
+	s.Require().NoError(reader.Close())
+	s.Require().NoError(writer.Close())
+	s.Require().Empty(filestore.OpenHandles(s.fs))
+	s.Require().Nil(filestore.OpenHandles(filestore.Memory()), "Untracked stores should not report anything")
+}
+
+func (s *TrackHandlesTestSuite) TestOpenHandles_stacks() {
+	fs := filestore.TrackHandles(filestore.Memory(), filestore.WithHandleStacks())
+	s.Require().NoError(writeString(fs, "1.lebowski", "jeff"))
+	reader, err := fs.Read("1.lebowski")
+	s.Require().NoError(err)
+	defer reader.Close()
+
+	handles := filestore.OpenHandles(fs)
+	s.Require().Len(handles, 1)
+	s.Require().Contains(handles[0].Stack, "TestOpenHandles_stacks", "Should point to the code that opened the file")
+	s.Require().NotContains(handles[0].Stack, "filestore.(*trackedFS)", "Should leave out the wrapper's own frames")
+}
+
+func (s *TrackHandlesTestSuite) TestShutdown() {
+	s.Require().NoError(filestore.Shutdown(context.Background(), s.fs))
+
+	reader, err := s.fs.Read("1.lebowski")
+	s.Require().NoError(err)
+	err = filestore.Shutdown(context.Background(), s.fs)
+	s.Require().True(errors.Is(err, filestore.ErrLeakedHandles))
+
+	var leaked *filestore.LeakedHandlesError
+	s.Require().True(errors.As(err, &leaked))
+	s.Require().Len(leaked.Handles, 1)
+	s.Require().Equal("/1.lebowski", leaked.Handles[0].Path)
+	s.Require().Contains(err.Error(), "/1.lebowski (read)")
+
+	s.Require().NoError(reader.Close())
+	s.Require().NoError(filestore.Shutdown(context.Background(), s.fs))
+}
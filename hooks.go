@@ -0,0 +1,128 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+)
+
+// Hooks are the callbacks that a WithHooks() store invokes after files change. Every hook is optional.
+// Paths are full paths, including the working directory of the store, so hooks on stores derived via
+// ChangeDirectory() see the same paths as hooks on the original.
+type Hooks struct {
+	// OnWrite is called once a file you've written has been closed successfully.
+	OnWrite func(filePath string) error
+	// OnRemove is called after a file/directory has been removed.
+	OnRemove func(fileOrDirPath string) error
+	// OnMove is called after a file/directory has been moved.
+	OnMove func(fromPath string, toPath string) error
+}
+
+// WithHooks wraps a file store so that your hooks are invoked whenever a file is written, removed, or moved
+// through it. This lets you index files, invalidate caches, or send notifications as soon as something
+// changes rather than polling the store. Hooks only run after the change succeeds, and they run
+// synchronously, so hand anything slow off to another goroutine.
+//
+// If a hook fails (or panics, which fails w/ a *PanicError), the operation returns that error even though
+// the change itself already happened, so you can tell that the hook didn't do its job.
+//
+// Example:
+//
+//	files := filestore.WithHooks(filestore.Disk("/srv/docs"), filestore.Hooks{
+//	    OnWrite: func(filePath string) error {
+//	        return searchIndex.Add(filePath)
+//	    },
+//	    OnRemove: func(fileOrDirPath string) error {
+//	        return searchIndex.Delete(fileOrDirPath)
+//	    },
+//	})
+func WithHooks(fs FS, hooks Hooks) FS {
+	return &hookedFS{FS: fs, hooks: hooks}
+}
+
+type hookedFS struct {
+	FS
+	hooks Hooks
+}
+
+// Write opens the file for writing. The OnWrite hook runs once you close it.
+func (h *hookedFS) Write(filePath string) (WriterFile, error) {
+	file, err := h.FS.Write(filePath)
+	if err != nil || h.hooks.OnWrite == nil {
+		return file, err
+	}
+	return &hookedWriterFile{WriterFile: file, hook: h.hooks.OnWrite, path: h.fullPath(filePath)}, nil
+}
+
+// Move relocates the file/directory and then runs the OnMove hook.
+func (h *hookedFS) Move(fromPath string, toPath string) error {
+	if err := h.FS.Move(fromPath, toPath); err != nil || h.hooks.OnMove == nil {
+		return err
+	}
+	fullFromPath, fullToPath := h.fullPath(fromPath), h.fullPath(toPath)
+	return runHook("move", fullFromPath, func() error {
+		return h.hooks.OnMove(fullFromPath, fullToPath)
+	})
+}
+
+// Remove deletes the file/directory and then runs the OnRemove hook.
+func (h *hookedFS) Remove(fileOrDirPath string) error {
+	if err := h.FS.Remove(fileOrDirPath); err != nil || h.hooks.OnRemove == nil {
+		return err
+	}
+	fullPath := h.fullPath(fileOrDirPath)
+	return runHook("remove", fullPath, func() error {
+		return h.hooks.OnRemove(fullPath)
+	})
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that runs the same hooks.
+func (h *hookedFS) ChangeDirectory(dir string) FS {
+	return &hookedFS{FS: h.FS.ChangeDirectory(dir), hooks: h.hooks}
+}
+
+func (h *hookedFS) withContext(ctx context.Context) FS {
+	return &hookedFS{FS: ForRequest(h.FS, ctx), hooks: h.hooks}
+}
+
+func (h *hookedFS) requestContext() context.Context {
+	return RequestContext(h.FS)
+}
+
+func (h *hookedFS) wrapped() []FS {
+	return []FS{h.FS}
+}
+
+func (h *hookedFS) fullPath(filePath string) string {
+	return joinPath(h.FS.WorkingDirectory(), filePath)
+}
+
+// runHook invokes the hook, converting a panic into a *PanicError so that a misbehaving hook can't crash
+// the process.
+func runHook(op string, filePath string, hook func() error) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("filestore: hooks: %s: %s: %w", op, filePath, err)
+		}
+	}()
+	defer recoverPanic(&err)
+	return hook()
+}
+
+// hookedWriterFile runs the OnWrite hook once the file has been closed successfully.
+type hookedWriterFile struct {
+	WriterFile
+	hook func(filePath string) error
+	path string
+}
+
+func (f *hookedWriterFile) Close() error {
+	if err := f.WriterFile.Close(); err != nil {
+		return err
+	}
+	return runHook("write", f.path, func() error {
+		return f.hook(f.path)
+	})
+}
+
+var _ requestBinder = &hookedFS{}
+var _ wrapper = &hookedFS{}
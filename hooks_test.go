@@ -0,0 +1,75 @@
+package filestore_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type HooksTestSuite struct {
+	suite.Suite
+	calls []string
+	fs    filestore.FS
+}
+
+func TestHooksTestSuite(t *testing.T) {
+	suite.Run(t, &HooksTestSuite{})
+}
+
+func (s *HooksTestSuite) SetupTest() {
+	s.calls = nil
+	s.fs = filestore.WithHooks(filestore.Memory(), filestore.Hooks{
+		OnWrite: func(filePath string) error {
+			s.calls = append(s.calls, "write "+filePath)
+			return nil
+		},
+		OnRemove: func(fileOrDirPath string) error {
+			s.calls = append(s.calls, "remove "+fileOrDirPath)
+			return nil
+		},
+		OnMove: func(fromPath string, toPath string) error {
+			s.calls = append(s.calls, "move "+fromPath+" "+toPath)
+			return nil
+		},
+	})
+}
+
+func (s *HooksTestSuite) TestHooks() {
+	files := s.fs.ChangeDirectory("docs")
+	s.Require().NoError(writeString(files, "a.txt", "abide"))
+	s.Require().NoError(files.Move("a.txt", "b.txt"))
+	_, err := readString(files, "b.txt")
+	s.Require().NoError(err)
+	s.Require().NoError(files.Remove("b.txt"))
+	s.Require().Error(files.Move("nope.txt", "c.txt"))
+
+	s.Require().Equal([]string{
+		"write /docs/a.txt",
+		"move /docs/a.txt /docs/b.txt",
+		"remove /docs/b.txt",
+	}, s.calls, "Should only run hooks after successful changes")
+}
+
+func (s *HooksTestSuite) TestHookFailure() {
+	errIndex := errors.New("index unavailable")
+	files := filestore.WithHooks(filestore.Memory(), filestore.Hooks{
+		OnWrite: func(string) error { return errIndex },
+		OnRemove: func(string) error {
+			panic("oops")
+		},
+	})
+
+	s.Require().True(errors.Is(writeString(files, "a.txt", "abide"), errIndex))
+	s.Require().True(files.Exists("a.txt"), "The change should still happen")
+
+	err := files.Remove("a.txt")
+	var panicErr *filestore.PanicError
+	s.Require().True(errors.As(err, &panicErr))
+	s.Require().Equal("oops", panicErr.Value)
+	s.Require().False(files.Exists("a.txt"))
+
+	s.Require().Error(writeString(files, "c.txt", "dude"))
+	s.Require().NoError(files.Move("c.txt", "d.txt"), "Should skip hooks you didn't supply")
+}
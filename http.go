@@ -0,0 +1,344 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	opener := func(u *url.URL) (FS, error) {
+		if u.Host == "" {
+			return nil, fmt.Errorf("missing host")
+		}
+		return HTTP(u.String()), nil
+	}
+	RegisterScheme("http", opener)
+	RegisterScheme("https", opener)
+}
+
+// HTTP creates a read-only file store whose files are served by a web server or CDN. The path of
+// each file is appended to the base URL, so reading "css/site.css" from a store w/ the base URL
+// "https://cdn.example.com/assets" downloads "https://cdn.example.com/assets/css/site.css".
+//
+// Stat() performs a HEAD request, and reading performs ranged GET requests, so seeking and ReadAt()
+// only download the bytes you need (servers that ignore the Range header still work; we just skip
+// over the data we don't want). HTTP has no standard way to list directories, so List() always
+// fails, and since the store is read-only, Write(), Remove(), and Move() fail with ErrReadOnly.
+//
+// Example:
+//
+//	assets := filestore.HTTP("https://cdn.example.com/assets", filestore.WithHTTPClient(client))
+//	logo, err := assets.Read("images/logo.png")
+//	if err != nil {
+//	    // handle your error nicely
+//	}
+//	defer logo.Close()
+func HTTP(baseURL string, opts ...Option) *HTTPFS {
+	options := newOptions(opts)
+	return &HTTPFS{
+		client:      options.client(),
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		basePath:    "/",
+		correlation: options.correlation,
+	}
+}
+
+// HTTPFS is a read-only file store whose files are served over HTTP(S).
+type HTTPFS struct {
+	client   *http.Client
+	baseURL  string
+	basePath string
+	// correlation is the header we send the request's trace ID in (see WithCorrelationHeader).
+	correlation string
+
+	// ctx is the context of the request this store was derived for via ForRequest(), if any.
+	ctx context.Context
+}
+
+// url converts a path relative to this FS' working directory into the full URL of the file.
+func (h HTTPFS) url(filePath string) (string, string, error) {
+	fullPath, err := resolvePath(h.basePath, filePath)
+	if err != nil {
+		return "", "", err
+	}
+	if fullPath == "/" {
+		return fullPath, h.baseURL + "/", nil
+	}
+	return fullPath, h.baseURL + uriEncode(fullPath, false), nil
+}
+
+// WorkingDirectory returns the current FS context's path/directory.
+func (h HTTPFS) WorkingDirectory() string {
+	return path.Clean(h.basePath)
+}
+
+// ChangeDirectory returns a new FS that is rooted in the given subdirectory of this FS.
+func (h HTTPFS) ChangeDirectory(dir string) FS {
+	h.basePath = joinPath(h.basePath, dir)
+	return &h
+}
+
+// withContext makes every request this store sends use the given context, so that cancelling the
+// request that derived it via ForRequest() also cancels any downloads still in flight.
+func (h HTTPFS) withContext(ctx context.Context) FS {
+	h.ctx = ctx
+	return &h
+}
+
+// requestContext is the context that HTTP requests should use.
+func (h HTTPFS) requestContext() context.Context {
+	return contextOrBackground(h.ctx)
+}
+
+// Stat fetches metadata about the file by performing a HEAD request. Since HTTP has no notion of
+// directories, the root of the store is the only thing we consider a directory.
+func (h HTTPFS) Stat(filePath string) (FileInfo, error) {
+	fullPath, fileURL, err := h.url(filePath)
+	if err != nil {
+		return nil, newPathError("http", "stat", filePath, err)
+	}
+	info, err := h.stat(h.requestContext(), fullPath, fileURL)
+	if err != nil {
+		return nil, newPathError("http", "stat", filePath, err)
+	}
+	return info, nil
+}
+
+func (h HTTPFS) stat(ctx context.Context, fullPath string, fileURL string) (httpFileInfo, error) {
+	if fullPath == "/" {
+		return httpFileInfo{name: "/", dir: true}, nil
+	}
+
+	res, err := h.do(ctx, http.MethodHead, fileURL, nil)
+	if err != nil {
+		return httpFileInfo{}, err
+	}
+	res.Body.Close()
+
+	modTime, _ := http.ParseTime(res.Header.Get("Last-Modified"))
+	return httpFileInfo{
+		name:    path.Base(fullPath),
+		size:    res.ContentLength,
+		modTime: modTime,
+		etag:    res.Header.Get("ETag"),
+	}, nil
+}
+
+// Exists returns true when a HEAD request for the file succeeds.
+func (h HTTPFS) Exists(filePath string) bool {
+	_, err := h.Stat(filePath)
+	return err == nil
+}
+
+// Read opens the file at the given path for reading. Data is downloaded lazily as you read it, and
+// seeking simply changes the offset of the next download. When the server provides a strong ETag,
+// subsequent downloads fail rather than mixing the contents of two different versions of the file.
+func (h HTTPFS) Read(filePath string) (ReaderFile, error) {
+	fullPath, fileURL, err := h.url(filePath)
+	if err != nil {
+		return nil, newPathError("http", "open", filePath, err)
+	}
+	info, err := h.stat(h.requestContext(), fullPath, fileURL)
+	if err != nil {
+		return nil, newPathError("http", "open", filePath, err)
+	}
+	if info.dir {
+		return nil, newPathError("http", "open", filePath, errIsDirectory)
+	}
+	if info.size < 0 {
+		return nil, newPathError("http", "open", filePath, errors.New("server did not provide the file's size"))
+	}
+
+	return &rangeReaderFile{size: info.size, fetch: func(offset int64, length int64) (io.ReadCloser, error) {
+		rangeHeader := fmt.Sprintf("bytes=%d-", offset)
+		if length >= 0 {
+			rangeHeader += strconv.FormatInt(offset+length-1, 10)
+		}
+		header := http.Header{"Range": {rangeHeader}}
+		if info.etag != "" && !strings.HasPrefix(info.etag, "W/") {
+			header.Set("If-Match", info.etag)
+		}
+		res, err := h.do(h.requestContext(), http.MethodGet, fileURL, header)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode == http.StatusPartialContent {
+			return res.Body, nil
+		}
+
+		// The server ignored our Range header and sent the whole file, so skip what we don't need.
+		if _, err := io.CopyN(io.Discard, res.Body, offset); err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+		if length < 0 {
+			return res.Body, nil
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{io.LimitReader(res.Body, length), res.Body}, nil
+	}}, nil
+}
+
+// Write always fails with ErrReadOnly.
+func (h HTTPFS) Write(filePath string) (WriterFile, error) {
+	return nil, newPathError("http", "write", filePath, ErrReadOnly)
+}
+
+// List always fails because HTTP has no standard way to list the files in a directory.
+func (h HTTPFS) List(dirPath string, _ ...FileFilter) ([]FileInfo, error) {
+	return nil, newPathError("http", "list files", dirPath, errors.New("listing directories is not supported"))
+}
+
+// Remove always fails with ErrReadOnly.
+func (h HTTPFS) Remove(fileOrDirPath string) error {
+	return newPathError("http", "remove", fileOrDirPath, ErrReadOnly)
+}
+
+// Move always fails with ErrReadOnly.
+func (h HTTPFS) Move(fromPath string, _ string) error {
+	return newPathError("http", "move", fromPath, ErrReadOnly)
+}
+
+// Ping verifies that the server is reachable. Plenty of servers don't serve anything at the base URL
+// itself, so any response that isn't a server error counts as healthy.
+func (h HTTPFS) Ping(ctx context.Context) error {
+	_, baseURL, _ := h.url(".")
+	res, err := h.do(ctx, http.MethodHead, baseURL, nil)
+	var statusErr *httpStatusError
+	switch {
+	case errors.As(err, &statusErr) && statusErr.StatusCode < 500:
+		return nil
+	case err != nil:
+		return newPathError("http", "ping", "", err)
+	}
+	res.Body.Close()
+	return nil
+}
+
+// do sends the request, turning non-2xx responses into an *httpStatusError.
+func (h HTTPFS) do(ctx context.Context, method string, fileURL string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	setCorrelationHeader(req, ctx, h.correlation)
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return nil, &httpStatusError{StatusCode: res.StatusCode, Status: res.Status, RequestID: httpRequestID(res.Header)}
+	}
+	return res, nil
+}
+
+// httpRequestIDHeaders are the response headers that servers and CDNs commonly use to identify requests,
+// in the order we look for them.
+var httpRequestIDHeaders = []string{"X-Request-Id", "X-Amz-Request-Id", "X-Amz-Cf-Id", "Cf-Ray", "X-Served-By"}
+
+// httpRequestID returns the ID that the server (or CDN) assigned to the request, if any.
+func httpRequestID(header http.Header) string {
+	for _, name := range httpRequestIDHeaders {
+		if id := header.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// httpStatusError is the error we return when the server responds w/ a non-2xx status.
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+	RequestID  string
+}
+
+func (err *httpStatusError) Error() string {
+	message := "http status " + err.Status
+	if err.Status == "" {
+		message = fmt.Sprintf("http status %d", err.StatusCode)
+	}
+	if err.RequestID != "" {
+		message += fmt.Sprintf(" (request id: %s)", err.RequestID)
+	}
+	return message
+}
+
+// ProviderRequestID is the ID that the server (or CDN) assigned to the failed request.
+func (err *httpStatusError) ProviderRequestID() string {
+	return err.RequestID
+}
+
+// Unwrap lets you use errors.Is() to check for missing files or permission problems in a
+// backend-neutral way.
+func (err *httpStatusError) Unwrap() error {
+	switch err.StatusCode {
+	case http.StatusNotFound, http.StatusGone:
+		return fs.ErrNotExist
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fs.ErrPermission
+	default:
+		return nil
+	}
+}
+
+// httpFileInfo describes a file served over HTTP (or the root "directory").
+type httpFileInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+	etag    string
+}
+
+func (info httpFileInfo) Name() string {
+	return info.name
+}
+
+func (info httpFileInfo) Size() int64 {
+	return info.size
+}
+
+func (info httpFileInfo) Mode() fs.FileMode {
+	if info.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (info httpFileInfo) ModTime() time.Time {
+	return info.modTime
+}
+
+func (info httpFileInfo) IsDir() bool {
+	return info.dir
+}
+
+func (info httpFileInfo) Sys() any {
+	return nil
+}
+
+func (info httpFileInfo) ETag() string {
+	return info.etag
+}
+
+var _ FS = HTTPFS{}
+var _ Pinger = HTTPFS{}
+var _ requestBinder = HTTPFS{}
+var _ etagger = httpFileInfo{}
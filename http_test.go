@@ -0,0 +1,217 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type HTTPTestSuite struct {
+	suite.Suite
+	dir      string
+	server   *httptest.Server
+	fs       filestore.FS
+	mutex    sync.Mutex
+	requests []*http.Request
+}
+
+func TestHTTPTestSuite(t *testing.T) {
+	suite.Run(t, &HTTPTestSuite{})
+}
+
+func (s *HTTPTestSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+	s.Require().NoError(os.MkdirAll(filepath.Join(s.dir, "assets", "el duderino"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.dir, "assets", "1.lebowski"), []byte("the dude abides"), 0644))
+	s.Require().NoError(os.WriteFile(filepath.Join(s.dir, "assets", "el duderino", "5.lebowski"), []byte("jackie"), 0644))
+
+	files := http.FileServer(http.Dir(s.dir))
+	s.requests = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.mutex.Lock()
+		s.requests = append(s.requests, req)
+		s.mutex.Unlock()
+		w.Header().Set("X-Request-Id", fmt.Sprintf("req-%d", len(s.requests)))
+		files.ServeHTTP(w, req)
+	}))
+	s.fs = filestore.HTTP(s.server.URL + "/assets/")
+}
+
+func (s *HTTPTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *HTTPTestSuite) TestStat() {
+	info, err := s.fs.Stat("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("1.lebowski", info.Name())
+	s.Require().Equal(int64(15), info.Size())
+	s.Require().False(info.IsDir())
+	s.Require().False(info.ModTime().IsZero())
+	s.Require().Equal(http.MethodHead, s.requests[0].Method)
+
+	info, err = s.fs.Stat("el duderino/5.lebowski")
+	s.Require().NoError(err, "Paths should be escaped properly")
+	s.Require().Equal(int64(6), info.Size())
+
+	info, err = s.fs.Stat(".")
+	s.Require().NoError(err)
+	s.Require().True(info.IsDir())
+
+	_, err = s.fs.Stat("nope.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	s.Require().True(s.fs.Exists("1.lebowski"))
+	s.Require().False(s.fs.Exists("nope.txt"))
+}
+
+func (s *HTTPTestSuite) TestRead() {
+	content, err := readString(s.fs, "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("the dude abides", content)
+
+	content, err = readString(s.fs.ChangeDirectory("el duderino"), "5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jackie", content)
+
+	_, err = s.fs.Read("nope.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = s.fs.Read(".")
+	s.Require().Error(err)
+}
+
+func (s *HTTPTestSuite) TestRead_ranges() {
+	s.assertRanges(s.fs)
+
+	for _, req := range s.requests {
+		if req.Method == http.MethodGet {
+			s.Require().NotEmpty(req.Header.Get("Range"), "Every download should be a ranged request")
+		}
+	}
+}
+
+// Servers that don't support the Range header should still work; we just download more than we need.
+func (s *HTTPTestSuite) TestRead_rangesNotSupported() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Length", "15")
+		if req.Method == http.MethodGet {
+			_, _ = io.WriteString(w, "the dude abides")
+		}
+	}))
+	defer server.Close()
+	s.assertRanges(filestore.HTTP(server.URL))
+}
+
+func (s *HTTPTestSuite) assertRanges(fsys filestore.FS) {
+	file, err := fsys.Read("1.lebowski")
+	s.Require().NoError(err)
+	defer file.Close()
+
+	buf := make([]byte, 4)
+	n, err := file.ReadAt(buf, 4)
+	s.Require().NoError(err)
+	s.Require().Equal("dude", string(buf[:n]))
+
+	n, err = file.ReadAt(buf, 12)
+	s.Require().Equal(io.EOF, err)
+	s.Require().Equal("des", string(buf[:n]))
+
+	_, err = file.Seek(9, io.SeekStart)
+	s.Require().NoError(err)
+	rest, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal("abides", string(rest))
+}
+
+func (s *HTTPTestSuite) TestRead_modified() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("ETag", `"1"`)
+		http.ServeContent(w, req, "1.lebowski", time.Time{}, strings.NewReader("the dude abides"))
+	}))
+	defer server.Close()
+
+	file, err := filestore.HTTP(server.URL).Read("1.lebowski")
+	s.Require().NoError(err)
+	defer file.Close()
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("ETag", `"2"`)
+		http.ServeContent(w, req, "1.lebowski", time.Time{}, strings.NewReader("the dude abides"))
+	})
+	_, err = io.ReadAll(file)
+	s.Require().Error(err, "Should not read a different version of the file than the one we opened")
+}
+
+func (s *HTTPTestSuite) TestRequestIDs() {
+	ctx := filestore.ContextWithIdentity(context.Background(), filestore.Identity{TraceID: "trace-123"})
+	_, err := filestore.ForRequest(s.fs, ctx).Stat("missing.lebowski")
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+	s.Require().Equal("trace-123", s.requests[0].Header.Get("X-Correlation-Id"))
+	s.Require().Equal("req-1", filestore.ProviderRequestID(err))
+	s.Require().Contains(err.Error(), "req-1")
+
+	assets := filestore.HTTP(s.server.URL+"/assets/", filestore.WithCorrelationHeader("X-Trace"))
+	_, err = filestore.ForRequest(assets, ctx).ChangeDirectory("el duderino").Stat("5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("trace-123", s.requests[1].Header.Get("X-Trace"))
+	s.Require().Empty(s.requests[1].Header.Get("X-Correlation-Id"))
+}
+
+func (s *HTTPTestSuite) TestReadOnly() {
+	_, err := s.fs.Write("1.lebowski")
+	s.Require().True(errors.Is(err, filestore.ErrReadOnly))
+	s.Require().True(errors.Is(s.fs.Remove("1.lebowski"), filestore.ErrReadOnly))
+	s.Require().True(errors.Is(s.fs.Move("1.lebowski", "2.lebowski"), filestore.ErrReadOnly))
+
+	_, err = s.fs.List(".")
+	s.Require().Error(err, "HTTP has no standard directory listing")
+}
+
+func (s *HTTPTestSuite) TestWorkingDirectory() {
+	s.Require().Equal("/", s.fs.WorkingDirectory())
+	s.Require().Equal("/el duderino", s.fs.ChangeDirectory("el duderino").WorkingDirectory())
+}
+
+func (s *HTTPTestSuite) TestPing() {
+	s.Require().NoError(filestore.Ping(context.Background(), s.fs))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	s.Require().Error(filestore.Ping(context.Background(), filestore.HTTP(failing.URL)))
+}
+
+func (s *HTTPTestSuite) TestOpen() {
+	s.Require().Contains(filestore.Schemes(), "http")
+	s.Require().Contains(filestore.Schemes(), "https")
+
+	fs, err := filestore.Open(s.server.URL + "/assets")
+	s.Require().NoError(err)
+	s.Require().IsType(&filestore.HTTPFS{}, fs)
+	s.Require().True(fs.Exists("1.lebowski"))
+}
+
+// Paths that no file system can represent should be rejected consistently by every operation.
+func (s *HTTPTestSuite) TestInvalidPaths() {
+	invalid := "bad\x00.lebowski"
+
+	_, err := s.fs.Stat(invalid)
+	s.Require().Error(err)
+	s.Require().False(s.fs.Exists(invalid))
+	_, err = s.fs.Read(invalid)
+	s.Require().Error(err)
+	s.Require().Empty(s.requests, "Invalid paths should never hit the server")
+}
@@ -0,0 +1,288 @@
+package filestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ImmutableSetter is an optional capability for stores that can prevent files from being modified or
+// deleted until some point in the future, also known as "write once, read many" (WORM) storage.
+type ImmutableSetter interface {
+	// SetImmutable prevents the file from being overwritten, moved, or removed until the given time. You
+	// can extend a file's retention period, but you can never shorten it.
+	SetImmutable(path string, until time.Time) error
+}
+
+// ErrImmutable is the error returned when you try to overwrite, move, or remove a file whose
+// retention period has not expired yet.
+var ErrImmutable = errors.New("filestore: file is immutable")
+
+// ErrImmutabilityNotSupported is the error returned by SetImmutable() when the store does not
+// implement the ImmutableSetter capability.
+var ErrImmutabilityNotSupported = errors.New("filestore: immutability not supported")
+
+// SetImmutable prevents the file from being overwritten, moved, or removed until the given time if the
+// store supports the ImmutableSetter capability. For all other stores, this fails with
+// ErrImmutabilityNotSupported; wrap them using WORM() if you need these semantics anyway.
+//
+// Example:
+//
+//	// Financial records must be kept for 7 years.
+//	until := time.Now().AddDate(7, 0, 0)
+//	err := filestore.SetImmutable(archive, "statements/2022-09.pdf", until)
+func SetImmutable(fs FS, path string, until time.Time) error {
+	if setter, ok := fs.(ImmutableSetter); ok {
+		return setter.SetImmutable(path, until)
+	}
+	return fmt.Errorf("filestore: set immutable: %s: %w", path, ErrImmutabilityNotSupported)
+}
+
+// wormRetentionFile is the name of the file in the root of a WORM() store that records each file's
+// retention period.
+const wormRetentionFile = ".retention.json"
+
+// WORM wraps a file store so that it supports the ImmutableSetter capability, even if the underlying
+// store has no such notion (e.g. DiskFS). The wrapper refuses to overwrite, move, or remove files until
+// their retention period has expired, failing with ErrImmutable instead. Removing or moving a directory
+// fails if any file inside of it is still immutable.
+//
+// Retention periods are recorded in a ".retention.json" file in the root of the wrapped store so that
+// they survive restarts; you can't modify that file through the wrapper. Keep in mind that the wrapper
+// can only protect files from changes made through it. Anyone w/ direct access to the underlying store
+// can still modify them. You can supply the WithClock() option to control expiration in tests.
+//
+// Example:
+//
+//	archive := filestore.WORM(filestore.Disk("/var/archive"))
+//	...
+//	err = filestore.SetImmutable(archive, "statements/2022-09.pdf", time.Now().AddDate(7, 0, 0))
+//	...
+//	err = archive.Remove("statements") // fails w/ filestore.ErrImmutable
+func WORM(fs FS, opts ...Option) FS {
+	options := newOptions(opts)
+	return &wormFS{FS: fs, retention: &wormRetention{
+		store: fs,
+		root:  fs.WorkingDirectory(),
+		clock: options.clock,
+	}}
+}
+
+func init() {
+	RegisterLayer("worm", func(fs FS, _ LayerOptions) (FS, error) {
+		return WORM(fs), nil
+	})
+}
+
+type wormFS struct {
+	FS
+	retention *wormRetention
+}
+
+// wormRetention tracks the retention periods of every file in the store. It's shared by the original
+// wrapper and any instances derived from it via ChangeDirectory().
+type wormRetention struct {
+	mutex  sync.Mutex
+	store  FS
+	root   string
+	clock  Clock
+	loaded bool
+	until  map[string]time.Time
+}
+
+// key determines which file a path refers to, relative to the root of the wrapped store, regardless
+// of which directory the caller cd'd into.
+func (w *wormFS) key(filePath string) string {
+	fullPath := joinPath(w.FS.WorkingDirectory(), filePath)
+	root := w.retention.root
+	switch {
+	case fullPath == root:
+		return "."
+	case root == ".":
+		return fullPath
+	case root == "/":
+		return strings.TrimPrefix(fullPath, "/")
+	case strings.HasPrefix(fullPath, root+"/"):
+		return fullPath[len(root)+1:]
+	default:
+		return fullPath
+	}
+}
+
+// check fails with ErrImmutable if the file (or any file inside of it when it's a directory) is still
+// within its retention period.
+func (w *wormFS) check(key string) error {
+	if key == wormRetentionFile {
+		return ErrImmutable
+	}
+
+	w.retention.mutex.Lock()
+	defer w.retention.mutex.Unlock()
+
+	if err := w.retention.load(); err != nil {
+		return err
+	}
+	now := w.retention.clock.Now()
+	for lockedKey, until := range w.retention.until {
+		if !now.Before(until) {
+			continue
+		}
+		if key == "." || lockedKey == key || strings.HasPrefix(lockedKey, key+"/") {
+			return ErrImmutable
+		}
+	}
+	return nil
+}
+
+// Write opens the file for writing unless it's still within its retention period.
+func (w *wormFS) Write(filePath string) (WriterFile, error) {
+	if err := w.check(w.key(filePath)); err != nil {
+		return nil, newPathError("worm", "write", filePath, err)
+	}
+	return w.FS.Write(filePath)
+}
+
+// List hides the file that records retention periods; everything else is listed as usual.
+func (w *wormFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	infos, err := w.FS.List(dirPath, filters...)
+	if err != nil || w.key(dirPath) != "." {
+		return infos, err
+	}
+
+	var results []FileInfo
+	for _, info := range infos {
+		if info.Name() != wormRetentionFile {
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// Remove deletes the file/directory unless it (or anything inside of it) is still within its
+// retention period.
+func (w *wormFS) Remove(fileOrDirPath string) error {
+	if err := w.check(w.key(fileOrDirPath)); err != nil {
+		return newPathError("worm", "remove", fileOrDirPath, err)
+	}
+	return w.FS.Remove(fileOrDirPath)
+}
+
+// Move relocates the file/directory unless either the source or destination (or anything inside of
+// them) is still within its retention period.
+func (w *wormFS) Move(fromPath string, toPath string) error {
+	if err := w.check(w.key(fromPath)); err != nil {
+		return newPathError("worm", "move", fromPath, err)
+	}
+	if err := w.check(w.key(toPath)); err != nil {
+		return newPathError("worm", "move", toPath, err)
+	}
+	return w.FS.Move(fromPath, toPath)
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that shares the same retention periods.
+func (w *wormFS) ChangeDirectory(dir string) FS {
+	return &wormFS{FS: w.FS.ChangeDirectory(dir), retention: w.retention}
+}
+
+func (w *wormFS) withContext(ctx context.Context) FS {
+	return &wormFS{FS: ForRequest(w.FS, ctx), retention: w.retention}
+}
+
+func (w *wormFS) requestContext() context.Context {
+	return RequestContext(w.FS)
+}
+
+func (w *wormFS) wrapped() []FS {
+	return []FS{w.FS}
+}
+
+// SetImmutable prevents the file from being overwritten, moved, or removed until the given time. You
+// can extend a file's retention period, but you can never shorten it.
+func (w *wormFS) SetImmutable(filePath string, until time.Time) error {
+	info, err := w.FS.Stat(filePath)
+	if err != nil {
+		return newPathError("worm", "set immutable", filePath, err)
+	}
+	if info.IsDir() {
+		return newPathError("worm", "set immutable", filePath, errIsDirectory)
+	}
+
+	key := w.key(filePath)
+	w.retention.mutex.Lock()
+	defer w.retention.mutex.Unlock()
+
+	if err := w.retention.load(); err != nil {
+		return newPathError("worm", "set immutable", filePath, err)
+	}
+	if current, ok := w.retention.until[key]; ok && until.Before(current) {
+		return newPathError("worm", "set immutable", filePath, fmt.Errorf("can not shorten retention period: %w", ErrImmutable))
+	}
+	w.retention.until[key] = until
+	if err := w.retention.save(); err != nil {
+		return newPathError("worm", "set immutable", filePath, err)
+	}
+	return nil
+}
+
+// load reads the retention periods from the underlying store the first time we need them. You must
+// hold the mutex to call this.
+func (r *wormRetention) load() error {
+	if r.loaded {
+		return nil
+	}
+
+	r.until = map[string]time.Time{}
+	file, err := r.store.Read(wormRetentionFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		r.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load retention: %w", err)
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&r.until); err != nil && err != io.EOF {
+		return fmt.Errorf("load retention: %w", err)
+	}
+	r.loaded = true
+	return nil
+}
+
+// save writes the retention periods to the underlying store, pruning any that have expired. You
+// must hold the mutex to call this.
+func (r *wormRetention) save() error {
+	now := r.clock.Now()
+	for key, until := range r.until {
+		if !now.Before(until) {
+			delete(r.until, key)
+		}
+	}
+
+	data, err := json.MarshalIndent(r.until, "", "  ")
+	if err != nil {
+		return fmt.Errorf("save retention: %w", err)
+	}
+	file, err := r.store.Write(wormRetentionFile)
+	if err != nil {
+		return fmt.Errorf("save retention: %w", err)
+	}
+	if _, err = file.Write(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("save retention: %w", err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("save retention: %w", err)
+	}
+	return nil
+}
+
+var _ ImmutableSetter = &wormFS{}
+var _ requestBinder = &wormFS{}
+var _ wrapper = &wormFS{}
@@ -0,0 +1,89 @@
+package filestore_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type ImmutableTestSuite struct {
+	suite.Suite
+	clock *filestoretest.Clock
+	inner filestore.FS
+	fs    filestore.FS
+}
+
+func TestImmutableTestSuite(t *testing.T) {
+	suite.Run(t, &ImmutableTestSuite{})
+}
+
+func (s *ImmutableTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.inner = filestore.Memory()
+	s.Require().NoError(writeString(s.inner, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.inner, "duderino/5.lebowski", "jackie"))
+	s.Require().NoError(writeString(s.inner, "duderino/6.lebowski", "nihilist"))
+	s.fs = filestore.WORM(s.inner, filestore.WithClock(s.clock))
+}
+
+func (s *ImmutableTestSuite) TestWORM() {
+	until := s.clock.Now().Add(time.Hour)
+	s.Require().NoError(filestore.SetImmutable(s.fs, "duderino/5.lebowski", until))
+
+	s.Require().True(errors.Is(writeString(s.fs, "duderino/5.lebowski", "nope"), filestore.ErrImmutable))
+	s.Require().True(errors.Is(s.fs.Remove("duderino/5.lebowski"), filestore.ErrImmutable))
+	s.Require().True(errors.Is(s.fs.Remove("duderino"), filestore.ErrImmutable), "Can't remove dirs w/ immutable files")
+	s.Require().True(errors.Is(s.fs.Remove("."), filestore.ErrImmutable))
+	s.Require().True(errors.Is(s.fs.Move("duderino", "dude"), filestore.ErrImmutable))
+	s.Require().True(errors.Is(s.fs.Move("1.lebowski", "duderino/5.lebowski"), filestore.ErrImmutable))
+
+	dir := s.fs.ChangeDirectory("duderino")
+	s.Require().True(errors.Is(dir.Remove("5.lebowski"), filestore.ErrImmutable), "Should apply no matter the working directory")
+
+	content, err := readString(s.fs, "duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jackie", content, "Immutable files should still be readable")
+
+	s.Require().NoError(writeString(s.fs, "duderino/6.lebowski", "the dude"), "Other files should be unaffected")
+	s.Require().NoError(s.fs.Move("1.lebowski", "2.lebowski"))
+	s.Require().NoError(s.fs.Remove("2.lebowski"))
+
+	s.clock.Advance(time.Hour)
+	s.Require().NoError(s.fs.Remove("duderino"), "Files should be mutable again once retention expires")
+}
+
+func (s *ImmutableTestSuite) TestWORM_extend() {
+	until := s.clock.Now().Add(time.Hour)
+	s.Require().NoError(filestore.SetImmutable(s.fs, "1.lebowski", until))
+	s.Require().NoError(filestore.SetImmutable(s.fs, "1.lebowski", until.Add(time.Hour)))
+	s.Require().True(errors.Is(filestore.SetImmutable(s.fs, "1.lebowski", until), filestore.ErrImmutable),
+		"Should not be able to shorten retention")
+
+	s.clock.Advance(time.Hour)
+	s.Require().Error(s.fs.Remove("1.lebowski"), "The extended retention period should apply")
+
+	s.Require().Error(filestore.SetImmutable(s.fs, "nope.lebowski", until))
+	s.Require().Error(filestore.SetImmutable(s.fs, "duderino", until), "Directories can't be immutable")
+}
+
+func (s *ImmutableTestSuite) TestWORM_persistence() {
+	s.Require().NoError(filestore.SetImmutable(s.fs, "1.lebowski", s.clock.Now().Add(time.Hour)))
+
+	reopened := filestore.WORM(s.inner, filestore.WithClock(s.clock))
+	s.Require().True(errors.Is(reopened.Remove("1.lebowski"), filestore.ErrImmutable), "Retention should survive restarts")
+
+	infos, err := reopened.List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(infos), "The retention file should be hidden")
+	s.Require().True(s.inner.Exists(".retention.json"))
+	s.Require().Error(writeString(reopened, ".retention.json", "{}"), "Can't tamper w/ the retention file")
+}
+
+func (s *ImmutableTestSuite) TestSetImmutable_notSupported() {
+	err := filestore.SetImmutable(s.inner, "1.lebowski", s.clock.Now().Add(time.Hour))
+	s.Require().True(errors.Is(err, filestore.ErrImmutabilityNotSupported))
+}
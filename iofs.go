@@ -0,0 +1,163 @@
+package filestore
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ioFS adapts a read-only standard library fs.FS (e.g. a zip.Reader or embed.FS) to our FS interface.
+// Every operation that would modify the store fails with ErrReadOnly.
+type ioFS struct {
+	fsys     fs.FS
+	basePath string
+
+	// kind identifies the store in its errors (e.g. "zip"); see PathError.Backend.
+	kind string
+
+	// open optionally overrides how we open files for reading. By default, we use the file as-is if
+	// it supports ReadAt() and Seek(); otherwise we read the whole thing into memory.
+	open func(name string) (ReaderFile, error)
+}
+
+// name converts a path relative to this FS' working directory into the unrooted, slash-separated
+// name that fs.FS expects (e.g. "/foo/bar.txt" becomes "foo/bar.txt" and "/" becomes ".").
+func (i ioFS) name(filePath string) (string, error) {
+	fullPath, err := resolvePath(i.basePath, filePath)
+	if err != nil {
+		return "", err
+	}
+	if fullPath == "/" {
+		return ".", nil
+	}
+	return strings.TrimPrefix(fullPath, "/"), nil
+}
+
+// WorkingDirectory returns the current FS context's path/directory.
+func (i ioFS) WorkingDirectory() string {
+	return path.Clean(i.basePath)
+}
+
+// ChangeDirectory returns a new FS that is rooted in the given subdirectory of this FS.
+func (i ioFS) ChangeDirectory(dir string) FS {
+	i.basePath = joinPath(i.basePath, dir)
+	return &i
+}
+
+// Stat fetches metadata about the file w/o actually opening it for reading.
+func (i ioFS) Stat(filePath string) (FileInfo, error) {
+	name, err := i.name(filePath)
+	if err != nil {
+		return nil, newPathError(i.kind, "stat", filePath, err)
+	}
+	info, err := fs.Stat(i.fsys, name)
+	if err != nil {
+		return nil, newPathError(i.kind, "stat", filePath, err)
+	}
+	return info, nil
+}
+
+// Exists returns true when the file/directory exists.
+func (i ioFS) Exists(filePath string) bool {
+	_, err := i.Stat(filePath)
+	return err == nil
+}
+
+// Read opens the given file for reading.
+func (i ioFS) Read(filePath string) (ReaderFile, error) {
+	name, err := i.name(filePath)
+	if err != nil {
+		return nil, newPathError(i.kind, "open", filePath, err)
+	}
+	info, err := fs.Stat(i.fsys, name)
+	if err != nil {
+		return nil, newPathError(i.kind, "open", filePath, err)
+	}
+	if info.IsDir() {
+		return nil, newPathError(i.kind, "open", filePath, errIsDirectory)
+	}
+
+	open := i.open
+	if open == nil {
+		open = i.openFile
+	}
+	file, err := open(name)
+	if err != nil {
+		return nil, newPathError(i.kind, "open", filePath, err)
+	}
+	return file, nil
+}
+
+// openFile is the default way to open files for reading; see the 'open' field for details.
+func (i ioFS) openFile(name string) (ReaderFile, error) {
+	file, err := i.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if readerFile, ok := file.(ReaderFile); ok {
+		return readerFile, nil
+	}
+
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return newBytesReaderFile(data), nil
+}
+
+// Write always fails with ErrReadOnly.
+func (i ioFS) Write(filePath string) (WriterFile, error) {
+	return nil, newPathError(i.kind, "write", filePath, ErrReadOnly)
+}
+
+// List performs the equivalent of the "ls" command. It returns a slice of
+// all files and directories found in the target dirPath.
+//
+// You can optionally provide a set of filters to limit which files/directories
+// are included in the final set.
+func (i ioFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	name, err := i.name(dirPath)
+	if err != nil {
+		return nil, newPathError(i.kind, "list files", dirPath, err)
+	}
+
+	entries, err := fs.ReadDir(i.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, newPathError(i.kind, "list files", dirPath, err)
+	}
+
+	infos := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, newPathError(i.kind, "list files", dirPath, err)
+		}
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(a, b int) bool { return infos[a].Name() < infos[b].Name() })
+	results, err := filterFiles(infos, filters)
+	if err != nil {
+		return nil, newPathError(i.kind, "list files", dirPath, err)
+	}
+	return results, nil
+}
+
+// Remove always fails with ErrReadOnly.
+func (i ioFS) Remove(fileOrDirPath string) error {
+	return newPathError(i.kind, "remove", fileOrDirPath, ErrReadOnly)
+}
+
+// Move always fails with ErrReadOnly.
+func (i ioFS) Move(fromPath string, _ string) error {
+	return newPathError(i.kind, "move", fromPath, ErrReadOnly)
+}
+
+var _ FS = ioFS{}
@@ -0,0 +1,87 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+)
+
+// Closer is an optional capability for stores and wrappers that hold onto resources beyond the
+// lifetime of a single operation: background workers, write-behind buffers, async replicas,
+// connection pools, etc. Closing should flush/drain any queued work before releasing resources,
+// giving up early if the context is cancelled or its deadline passes.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// Shutdown gracefully closes the file store if it supports the Closer capability; it does nothing
+// for stores that don't need it (e.g. DiskFS). Wrappers that don't need closing themselves (e.g.
+// Logged or Encrypted) close the store(s) they wrap instead. You should call this during your process'
+// shutdown sequence to ensure that wrappers don't drop queued writes on the floor.
+//
+// Example:
+//
+//	files, err := filestore.Default()
+//	...
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	if err := filestore.Shutdown(ctx, files); err != nil {
+//	    log.Printf("unable to flush file store: %v", err)
+//	}
+func Shutdown(ctx context.Context, fs FS) error {
+	switch store := fs.(type) {
+	case Closer:
+		return store.Close(ctx)
+	case wrapper:
+		var errs []error
+		for _, inner := range store.wrapped() {
+			errs = append(errs, Shutdown(ctx, inner))
+		}
+		return joinErrors(errs...)
+	default:
+		return nil
+	}
+}
+
+// wrapper is implemented by stores that wrap other stores, so that Shutdown() and Ping() can reach the
+// stores underneath them. The first store is the one in charge (e.g. the primary of a Mirror), which is
+// the one that Ping() checks.
+type wrapper interface {
+	wrapped() []FS
+}
+
+// Pinger is an optional capability for stores that can verify that they're healthy and usable:
+// the bucket is accessible, the share is mounted, the directory is writable, etc.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping checks whether the file store is healthy so that services can wire storage into their
+// readiness probes rather than failing on the first real request. Stores that implement the
+// Pinger capability perform their own backend-specific checks, and wrappers ping the store they
+// wrap. For any other store, this simply verifies that you can list the contents of its working
+// directory.
+//
+// Example:
+//
+//	http.HandleFunc("/ready", func(w http.ResponseWriter, req *http.Request) {
+//	    if err := filestore.Ping(req.Context(), files); err != nil {
+//	        http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//	    }
+//	})
+func Ping(ctx context.Context, fs FS) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	switch store := fs.(type) {
+	case Pinger:
+		return store.Ping(ctx)
+	case wrapper:
+		if inner := store.wrapped(); len(inner) > 0 {
+			return Ping(ctx, inner[0])
+		}
+	}
+	if _, err := fs.List("."); err != nil {
+		return fmt.Errorf("filestore: ping: %w", err)
+	}
+	return nil
+}
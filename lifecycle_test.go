@@ -0,0 +1,198 @@
+package filestore_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type LifecycleTestSuite struct {
+	suite.Suite
+}
+
+func TestLifecycleTestSuite(t *testing.T) {
+	suite.Run(t, &LifecycleTestSuite{})
+}
+
+func (s *LifecycleTestSuite) TestShutdown_notCloser() {
+	s.Require().NoError(filestore.Shutdown(context.Background(), filestore.Disk("testdata")))
+	s.Require().NoError(filestore.Shutdown(context.Background(), filestore.Memory()))
+}
+
+func (s *LifecycleTestSuite) TestShutdown_closer() {
+	fs := &closerFS{FS: filestore.Memory()}
+	s.Require().NoError(filestore.Shutdown(context.Background(), fs))
+	s.Require().Equal(1, fs.closed)
+
+	fs.err = fmt.Errorf("nope")
+	s.Require().Error(filestore.Shutdown(context.Background(), fs))
+	s.Require().Equal(2, fs.closed)
+
+	fs.err = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Require().ErrorIs(filestore.Shutdown(ctx, fs), context.Canceled, "Should pass context to the store")
+}
+
+func (s *LifecycleTestSuite) TestShutdown_wrappers() {
+	key := make([]byte, 32)
+	wrappers := map[string]func(fs filestore.FS) filestore.FS{
+		"Audited": func(fs filestore.FS) filestore.FS {
+			return filestore.Audited(fs, filestore.AuditWriter(io.Discard))
+		},
+		"Buffered":     func(fs filestore.FS) filestore.FS { return filestore.Buffered(fs, 1024, time.Second) },
+		"Bundles":      func(fs filestore.FS) filestore.FS { return filestore.Bundles(fs, ".app") },
+		"Cached":       func(fs filestore.FS) filestore.FS { return filestore.Cached(fs, s.T().TempDir()) },
+		"Compressed":   func(fs filestore.FS) filestore.FS { return filestore.Compressed(fs, filestore.Gzip) },
+		"CopyOnWrite":  func(fs filestore.FS) filestore.FS { return filestore.CopyOnWrite(fs) },
+		"Deduplicated": func(fs filestore.FS) filestore.FS { return filestore.Deduplicated(fs) },
+		"Derivatives":  func(fs filestore.FS) filestore.FS { return filestore.Derivatives(fs) },
+		"Encrypted":    func(fs filestore.FS) filestore.FS { return filestore.Encrypted(fs, key) },
+		"Expiring":     func(fs filestore.FS) filestore.FS { return filestore.Expiring(fs, time.Hour) },
+		"Failover": func(fs filestore.FS) filestore.FS {
+			return filestore.Failover(fs, filestore.Memory())
+		},
+		"FileIDs":   func(fs filestore.FS) filestore.FS { return filestore.FileIDs(fs) },
+		"Freezable": func(fs filestore.FS) filestore.FS { return filestore.Freezable(fs) },
+		"ContentIndexed": func(fs filestore.FS) filestore.FS {
+			return filestore.ContentIndexed(fs, &fakeIndexer{content: map[string]string{}}, nil)
+		},
+		"TrackHandles":   func(fs filestore.FS) filestore.FS { return filestore.TrackHandles(fs) },
+		"WithHooks":      func(fs filestore.FS) filestore.FS { return filestore.WithHooks(fs, filestore.Hooks{}) },
+		"WORM":           func(fs filestore.FS) filestore.FS { return filestore.WORM(fs) },
+		"MaxFileSize":    func(fs filestore.FS) filestore.FS { return filestore.MaxFileSize(fs, 1024) },
+		"Mirror":         func(fs filestore.FS) filestore.FS { return filestore.Mirror(fs, []filestore.FS{filestore.Memory()}) },
+		"EnforceNames":   func(fs filestore.FS) filestore.FS { return filestore.EnforceNames(fs, filestore.NamePolicy{}) },
+		"LimitOpenFiles": func(fs filestore.FS) filestore.FS { return filestore.LimitOpenFiles(fs, 10) },
+		"Overlay":        func(fs filestore.FS) filestore.FS { return filestore.Overlay(fs, filestore.Memory()) },
+		"PushDir":        func(fs filestore.FS) filestore.FS { return filestore.PushDir(fs, "a") },
+		"Replicate": func(fs filestore.FS) filestore.FS {
+			return filestore.Replicate(fs, []filestore.FS{filestore.Memory()})
+		},
+		"ForRequest": func(fs filestore.FS) filestore.FS { return filestore.ForRequest(fs, context.Background()) },
+		"Scoped": func(fs filestore.FS) filestore.FS {
+			scoped, err := filestore.Scoped(fs, "acme")
+			s.Require().NoError(err)
+			return scoped
+		},
+		"Indexed":         func(fs filestore.FS) filestore.FS { return filestore.Indexed(fs, filestore.MemoryIndex()) },
+		"Serialized":      func(fs filestore.FS) filestore.FS { return filestore.Serialized(fs) },
+		"Sharded":         func(fs filestore.FS) filestore.FS { return filestore.Sharded([]filestore.FS{fs}, nil) },
+		"SimulateNetwork": func(fs filestore.FS) filestore.FS { return filestore.SimulateNetwork(fs, filestore.NetworkProfile{}) },
+		"ReportSlow": func(fs filestore.FS) filestore.FS {
+			return filestore.ReportSlow(fs, time.Second, func(filestore.SlowOperation) {})
+		},
+		"SparseMirror": func(fs filestore.FS) filestore.FS { return filestore.SparseMirror(fs, filestore.Memory()) },
+		"Validated":    func(fs filestore.FS) filestore.FS { return filestore.Validated(fs, filestore.MaxSize(1024)) },
+		"Versioned":    func(fs filestore.FS) filestore.FS { return filestore.Versioned(fs) },
+	}
+	for name, wrap := range wrappers {
+		inner := newLifecycleFS()
+		s.Require().NoError(filestore.Shutdown(context.Background(), wrap(inner)), name)
+		s.Require().Equal(1, inner.state.closed, "%s should close the store it wraps", name)
+		s.Require().NoError(filestore.Ping(context.Background(), wrap(inner)), name)
+		s.Require().Equal(1, inner.state.pinged, "%s should ping the store it wraps", name)
+
+		inner.state.err = fmt.Errorf("nope")
+		s.Require().Error(filestore.Shutdown(context.Background(), wrap(inner)), "%s should report errors from the store it wraps", name)
+		s.Require().Error(filestore.Ping(context.Background(), wrap(inner)), "%s should report errors from the store it wraps", name)
+	}
+}
+
+func (s *LifecycleTestSuite) TestShutdown_zipWriterBehindWrapper() {
+	buf := &bytes.Buffer{}
+	files := filestore.WithHooks(filestore.ZipWriter(buf), filestore.Hooks{})
+	s.Require().NoError(writeString(files, "hello.txt", "hello"))
+	s.Require().NoError(filestore.Shutdown(context.Background(), files))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	s.Require().NoError(err, "Should finish writing the zip file")
+	s.Require().Len(archive.File, 1)
+}
+
+func (s *LifecycleTestSuite) TestPing_disk() {
+	dir := s.T().TempDir()
+	s.Require().NoError(filestore.Ping(context.Background(), filestore.Disk(dir)))
+	s.Require().NoError(filestore.Ping(context.Background(), filestore.Disk("testdata")))
+
+	files, _ := os.ReadDir(dir)
+	s.Require().Equal(0, len(files), "Ping should clean up after itself")
+
+	s.Require().Error(filestore.Ping(context.Background(), filestore.Disk("testdata/nope")), "Missing directory should fail")
+	s.Require().Error(filestore.Ping(context.Background(), filestore.Disk("testdata/hello.txt")), "Non-directory should fail")
+
+	if os.Geteuid() != 0 {
+		readOnly := path.Join(dir, "readonly")
+		s.Require().NoError(os.Mkdir(readOnly, 0555))
+		s.Require().Error(filestore.Ping(context.Background(), filestore.Disk(readOnly)), "Read-only directory should fail")
+	}
+}
+
+func (s *LifecycleTestSuite) TestPing_memory() {
+	s.Require().NoError(filestore.Ping(context.Background(), filestore.Memory()))
+}
+
+func (s *LifecycleTestSuite) TestPing_fallback() {
+	s.Require().NoError(filestore.Ping(context.Background(), &closerFS{FS: filestore.Memory()}))
+	s.Require().Error(filestore.Ping(context.Background(), &closerFS{FS: filestore.Disk("testdata/hello.txt")}))
+}
+
+func (s *LifecycleTestSuite) TestPing_cancelled() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Require().ErrorIs(filestore.Ping(ctx, filestore.Memory()), context.Canceled)
+	s.Require().ErrorIs(filestore.Ping(ctx, filestore.Disk("testdata")), context.Canceled)
+}
+
+// lifecycleFS counts how many times it was closed/pinged, including through stores derived from it.
+type lifecycleFS struct {
+	filestore.FS
+	state *lifecycleState
+}
+
+type lifecycleState struct {
+	closed int
+	pinged int
+	err    error
+}
+
+func newLifecycleFS() *lifecycleFS {
+	return &lifecycleFS{FS: filestore.Memory(), state: &lifecycleState{}}
+}
+
+func (fs *lifecycleFS) ChangeDirectory(dir string) filestore.FS {
+	return &lifecycleFS{FS: fs.FS.ChangeDirectory(dir), state: fs.state}
+}
+
+func (fs *lifecycleFS) Close(context.Context) error {
+	fs.state.closed++
+	return fs.state.err
+}
+
+func (fs *lifecycleFS) Ping(context.Context) error {
+	fs.state.pinged++
+	return fs.state.err
+}
+
+type closerFS struct {
+	filestore.FS
+	closed int
+	err    error
+}
+
+func (fs *closerFS) Close(ctx context.Context) error {
+	fs.closed++
+	if fs.err != nil {
+		return fs.err
+	}
+	return ctx.Err()
+}
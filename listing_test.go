@@ -0,0 +1,84 @@
+package filestore_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExportListingTestSuite struct {
+	suite.Suite
+	fs filestore.FS
+}
+
+func TestExportListingTestSuite(t *testing.T) {
+	suite.Run(t, &ExportListingTestSuite{})
+}
+
+func (s *ExportListingTestSuite) SetupTest() {
+	clock := filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.fs = filestore.Memory(filestore.WithClock(clock))
+	s.Require().NoError(writeString(s.fs, "uploads/2022/beach.jpg", "beach"))
+	clock.Advance(time.Hour)
+	s.Require().NoError(writeString(s.fs, "uploads/2022/dude, the.txt", "abide"))
+	s.Require().NoError(writeString(s.fs, "uploads/readme.md", ""))
+	s.Require().NoError(writeString(s.fs, "other/ignored.txt", "nope"))
+}
+
+func (s *ExportListingTestSuite) TestCSV() {
+	output := &bytes.Buffer{}
+	s.Require().NoError(filestore.ExportListing(s.fs, "uploads", output, filestore.ListingCSV))
+	s.Require().Equal(strings.Join([]string{
+		"path,size,mtime",
+		"uploads/2022/beach.jpg,5,2022-09-06T12:00:00Z",
+		`"uploads/2022/dude, the.txt",5,2022-09-06T13:00:00Z`,
+		"uploads/readme.md,0,2022-09-06T13:00:00Z",
+		"",
+	}, "\n"), output.String())
+}
+
+func (s *ExportListingTestSuite) TestCSV_hashes() {
+	output := &bytes.Buffer{}
+	err := filestore.ExportListing(s.fs, "uploads/readme.md", output, filestore.ListingCSV, filestore.WithListingHashes())
+	s.Require().NoError(err)
+	s.Require().Equal(strings.Join([]string{
+		"path,size,mtime,sha256",
+		"uploads/readme.md,0,2022-09-06T13:00:00Z,e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"",
+	}, "\n"), output.String())
+}
+
+func (s *ExportListingTestSuite) TestJSONLines() {
+	output := &bytes.Buffer{}
+	err := filestore.ExportListing(s.fs, ".", output, filestore.ListingJSONLines, filestore.WithMaxDepth(2))
+	s.Require().NoError(err)
+	s.Require().Equal(strings.Join([]string{
+		`{"path":"other/ignored.txt","size":4,"mtime":"2022-09-06T13:00:00Z"}`,
+		`{"path":"uploads/readme.md","size":0,"mtime":"2022-09-06T13:00:00Z"}`,
+		"",
+	}, "\n"), output.String())
+
+	output.Reset()
+	err = filestore.ExportListing(s.fs, "uploads/2022/beach.jpg", output, filestore.ListingJSONLines, filestore.WithListingHashes())
+	s.Require().NoError(err)
+	s.Require().Contains(output.String(), `"sha256":"`)
+}
+
+func (s *ExportListingTestSuite) TestErrors() {
+	s.Require().Error(filestore.ExportListing(s.fs, "uploads", &bytes.Buffer{}, "parquet"))
+	s.Require().Error(filestore.ExportListing(s.fs, "missing", &bytes.Buffer{}, filestore.ListingCSV))
+	s.Require().Error(filestore.ExportListing(s.fs, "uploads", failingWriter{}, filestore.ListingJSONLines))
+}
+
+// failingWriter is an io.Writer that can't write anything.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
@@ -0,0 +1,233 @@
+//go:build go1.21
+
+package filestore
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Logged wraps a file store, writing a structured log entry to the given logger for every call to it. Each
+// entry has the operation ("stat", "exists", "list", "open", "create", "move", or "remove"), the full path
+// (plus "to" for moves), how long it took, and the error if it failed. Files returned by Read() and Write()
+// get one more entry when you close them ("read" or "write") w/ the number of bytes that went through them
+// and how long they were open, so you don't get an entry for every little buffered read.
+//
+// Successful calls are logged at the Info level and failures at the Error level; use your handler's level
+// to filter them. Entries are logged w/ the context of the request the store was bound to (see
+// ForRequest), so handlers that pull trace IDs and such out of the context just work. When 'logger' is nil,
+// we use slog.Default(). You can supply the WithClock() option to control the durations in tests. Since it
+// relies on log/slog, Logged() (and the "logged" layer) is only available when you build w/ Go 1.21+.
+//
+// Example:
+//
+//	files := filestore.Logged(filestore.Disk("/mnt/nfs"), slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+func Logged(fs FS, logger *slog.Logger, opts ...Option) FS {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	options := newOptions(opts)
+	return &loggedFS{FS: fs, log: &fsLogger{logger: logger, clock: options.clock}}
+}
+
+func init() {
+	RegisterLayer("logged", func(fs FS, _ LayerOptions) (FS, error) {
+		return Logged(fs, nil), nil
+	})
+}
+
+type loggedFS struct {
+	FS
+	log *fsLogger
+}
+
+// fsLogger writes the entries for a Logged() store and any instances/files derived from it.
+type fsLogger struct {
+	logger *slog.Logger
+	clock  Clock
+}
+
+// Stat fetches metadata about the file, logging the call.
+func (l *loggedFS) Stat(filePath string) (FileInfo, error) {
+	began := l.log.clock.Now()
+	info, err := l.FS.Stat(filePath)
+	l.log.entry(l.FS, "stat", l.fullPath(filePath), began, err)
+	return info, err
+}
+
+// Exists returns true when the file/directory exists, logging the check.
+func (l *loggedFS) Exists(filePath string) bool {
+	began := l.log.clock.Now()
+	exists := l.FS.Exists(filePath)
+	l.log.entry(l.FS, "exists", l.fullPath(filePath), began, nil, slog.Bool("exists", exists))
+	return exists
+}
+
+// List reads the contents of the directory, logging the call.
+func (l *loggedFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	began := l.log.clock.Now()
+	infos, err := l.FS.List(dirPath, filters...)
+	l.log.entry(l.FS, "list", l.fullPath(dirPath), began, err, slog.Int("count", len(infos)))
+	return infos, err
+}
+
+// Read opens the file for reading, logging the call and, once the file is closed, how much was read.
+func (l *loggedFS) Read(filePath string) (ReaderFile, error) {
+	fullPath := l.fullPath(filePath)
+	began := l.log.clock.Now()
+	file, err := l.FS.Read(filePath)
+	l.log.entry(l.FS, "open", fullPath, began, err)
+	if err != nil {
+		return nil, err
+	}
+	return &loggedReaderFile{ReaderFile: file, log: l.log, fs: l.FS, path: fullPath, opened: l.log.clock.Now()}, nil
+}
+
+// Write opens the file for writing, logging the call and, once the file is closed, how much was written.
+func (l *loggedFS) Write(filePath string) (WriterFile, error) {
+	fullPath := l.fullPath(filePath)
+	began := l.log.clock.Now()
+	file, err := l.FS.Write(filePath)
+	l.log.entry(l.FS, "create", fullPath, began, err)
+	if err != nil {
+		return nil, err
+	}
+	return &loggedWriterFile{WriterFile: file, log: l.log, fs: l.FS, path: fullPath, opened: l.log.clock.Now()}, nil
+}
+
+// Move relocates the file/directory, logging the call.
+func (l *loggedFS) Move(fromPath string, toPath string) error {
+	began := l.log.clock.Now()
+	err := l.FS.Move(fromPath, toPath)
+	l.log.entry(l.FS, "move", l.fullPath(fromPath), began, err, slog.String("to", l.fullPath(toPath)))
+	return err
+}
+
+// Remove deletes the file/directory, logging the call.
+func (l *loggedFS) Remove(fileOrDirPath string) error {
+	began := l.log.clock.Now()
+	err := l.FS.Remove(fileOrDirPath)
+	l.log.entry(l.FS, "remove", l.fullPath(fileOrDirPath), began, err)
+	return err
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that logs to the same logger.
+func (l *loggedFS) ChangeDirectory(dir string) FS {
+	return &loggedFS{FS: l.FS.ChangeDirectory(dir), log: l.log}
+}
+
+func (l *loggedFS) withContext(ctx context.Context) FS {
+	return &loggedFS{FS: ForRequest(l.FS, ctx), log: l.log}
+}
+
+func (l *loggedFS) requestContext() context.Context {
+	return RequestContext(l.FS)
+}
+
+func (l *loggedFS) wrapped() []FS {
+	return []FS{l.FS}
+}
+
+func (l *loggedFS) fullPath(filePath string) string {
+	return joinPath(l.FS.WorkingDirectory(), filePath)
+}
+
+// entry logs a single call that started at 'began' and just finished w/ the given error.
+func (fl *fsLogger) entry(fs FS, op string, fullPath string, began time.Time, err error, attrs ...slog.Attr) {
+	level := slog.LevelInfo
+	attrs = append([]slog.Attr{
+		slog.String("op", op),
+		slog.String("path", fullPath),
+		slog.Duration("duration", fl.clock.Now().Sub(began)),
+	}, attrs...)
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	fl.logger.LogAttrs(RequestContext(fs), level, "filestore "+op, attrs...)
+}
+
+// loggedReaderFile counts the bytes read from the file so that we can log them when it's closed.
+type loggedReaderFile struct {
+	ReaderFile
+	log    *fsLogger
+	fs     FS
+	path   string
+	opened time.Time
+	bytes  int64
+	err    error
+}
+
+func (f *loggedReaderFile) Read(data []byte) (int, error) {
+	n, err := f.ReaderFile.Read(data)
+	f.record(n, err)
+	return n, err
+}
+
+func (f *loggedReaderFile) ReadAt(data []byte, offset int64) (int, error) {
+	n, err := f.ReaderFile.ReadAt(data, offset)
+	f.record(n, err)
+	return n, err
+}
+
+func (f *loggedReaderFile) record(n int, err error) {
+	f.bytes += int64(n)
+	if f.err == nil && err != io.EOF {
+		f.err = err
+	}
+}
+
+func (f *loggedReaderFile) Close() error {
+	err := f.ReaderFile.Close()
+	logErr := err
+	if logErr == nil {
+		logErr = f.err
+	}
+	f.log.entry(f.fs, "read", f.path, f.opened, logErr, slog.Int64("bytes", f.bytes))
+	return err
+}
+
+// loggedWriterFile counts the bytes written to the file so that we can log them when it's closed.
+type loggedWriterFile struct {
+	WriterFile
+	log    *fsLogger
+	fs     FS
+	path   string
+	opened time.Time
+	bytes  int64
+	err    error
+}
+
+func (f *loggedWriterFile) Write(data []byte) (int, error) {
+	n, err := f.WriterFile.Write(data)
+	f.record(n, err)
+	return n, err
+}
+
+func (f *loggedWriterFile) WriteAt(data []byte, offset int64) (int, error) {
+	n, err := f.WriterFile.WriteAt(data, offset)
+	f.record(n, err)
+	return n, err
+}
+
+func (f *loggedWriterFile) record(n int, err error) {
+	f.bytes += int64(n)
+	if f.err == nil {
+		f.err = err
+	}
+}
+
+func (f *loggedWriterFile) Close() error {
+	err := f.WriterFile.Close()
+	logErr := err
+	if logErr == nil {
+		logErr = f.err
+	}
+	f.log.entry(f.fs, "write", f.path, f.opened, logErr, slog.Int64("bytes", f.bytes))
+	return err
+}
+
+var _ requestBinder = &loggedFS{}
+var _ wrapper = &loggedFS{}
@@ -0,0 +1,118 @@
+//go:build go1.21
+
+package filestore_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoggedTestSuite struct {
+	suite.Suite
+	clock  *filestoretest.Clock
+	output *bytes.Buffer
+	fs     filestore.FS
+}
+
+func TestLoggedTestSuite(t *testing.T) {
+	suite.Run(t, &LoggedTestSuite{})
+}
+
+func (s *LoggedTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.output = &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(s.output, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	}))
+	s.fs = filestore.Logged(filestore.Memory(), logger, filestore.WithClock(s.clock))
+}
+
+func (s *LoggedTestSuite) TestLogged() {
+	s.Require().NoError(writeString(s.fs.ChangeDirectory("docs"), "readme.md", "the dude abides"))
+	_, err := readString(s.fs, "docs/readme.md")
+	s.Require().NoError(err)
+	s.Require().True(s.fs.Exists("docs/readme.md"))
+	_, err = s.fs.List("docs")
+	s.Require().NoError(err)
+	s.Require().NoError(s.fs.Move("docs/readme.md", "docs/README.md"))
+	s.Require().NoError(s.fs.Remove("docs/README.md"))
+
+	s.Require().Equal([]map[string]any{
+		{"level": "INFO", "msg": "filestore create", "op": "create", "path": "/docs/readme.md", "duration": 0.0},
+		{"level": "INFO", "msg": "filestore write", "op": "write", "path": "/docs/readme.md", "duration": 0.0, "bytes": 15.0},
+		{"level": "INFO", "msg": "filestore open", "op": "open", "path": "/docs/readme.md", "duration": 0.0},
+		{"level": "INFO", "msg": "filestore read", "op": "read", "path": "/docs/readme.md", "duration": 0.0, "bytes": 15.0},
+		{"level": "INFO", "msg": "filestore exists", "op": "exists", "path": "/docs/readme.md", "duration": 0.0, "exists": true},
+		{"level": "INFO", "msg": "filestore list", "op": "list", "path": "/docs", "duration": 0.0, "count": 1.0},
+		{"level": "INFO", "msg": "filestore move", "op": "move", "path": "/docs/readme.md", "duration": 0.0, "to": "/docs/README.md"},
+		{"level": "INFO", "msg": "filestore remove", "op": "remove", "path": "/docs/README.md", "duration": 0.0},
+	}, s.entries())
+}
+
+func (s *LoggedTestSuite) TestLogged_durations() {
+	file, err := s.fs.Write("slow.txt")
+	s.Require().NoError(err)
+	s.clock.Advance(2 * time.Second)
+	_, err = file.Write([]byte("slow"))
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+
+	entries := s.entries()
+	s.Require().Len(entries, 2)
+	s.Require().Equal(float64(2*time.Second), entries[1]["duration"], "Should log how long the file was open")
+	s.Require().Equal(4.0, entries[1]["bytes"])
+}
+
+func (s *LoggedTestSuite) TestLogged_errors() {
+	_, err := s.fs.Stat("missing.txt")
+	s.Require().Error(err)
+	_, err = s.fs.Read("missing.txt")
+	s.Require().Error(err)
+
+	entries := s.entries()
+	s.Require().Len(entries, 2, "Should not log a read when the file couldn't be opened")
+	for _, entry := range entries {
+		s.Require().Equal("ERROR", entry["level"])
+		s.Require().Contains(entry["error"], "missing.txt")
+	}
+}
+
+func (s *LoggedTestSuite) TestShutdown() {
+	buf := &bytes.Buffer{}
+	files := filestore.Logged(filestore.ZipWriter(buf), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.Require().NoError(writeString(files, "hello.txt", "hello"))
+	s.Require().NoError(filestore.Shutdown(context.Background(), files))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	s.Require().NoError(err, "Should finish writing the zip file")
+	s.Require().Len(archive.File, 1)
+}
+
+func (s *LoggedTestSuite) entries() []map[string]any {
+	var entries []map[string]any
+	decoder := json.NewDecoder(s.output)
+	for {
+		entry := map[string]any{}
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return entries
+		} else {
+			s.Require().NoError(err)
+		}
+		entries = append(entries, entry)
+	}
+}
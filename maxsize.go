@@ -0,0 +1,175 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrFileTooLarge is the error you can check for (using errors.Is) when a write fails because the file
+// would be bigger than a MaxFileSize() store allows.
+var ErrFileTooLarge = errors.New("filestore: file too large")
+
+// FileTooLargeError is the error returned when a write would make a file bigger than a MaxFileSize()
+// store allows.
+type FileTooLargeError struct {
+	// Path is the file that you were writing.
+	Path string
+	// Limit is the maximum size of a file, in bytes.
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("filestore: %s: file too large (limit is %d bytes)", e.Path, e.Limit)
+}
+
+func (e *FileTooLargeError) Unwrap() error {
+	return ErrFileTooLarge
+}
+
+// MaxFileSize wraps a file store so that no file written through it can be bigger than maxBytes, so one
+// runaway producer can't fill up the volume w/ a single file. The write that would cross the limit fails
+// w/ a *FileTooLargeError (w/o writing any of its data), as do any writes after it. Closing that file
+// removes it rather than leaving a truncated file behind that looks legitimate. CopyAll() checks each
+// file's size up front, so copying a file that's too big fails before we read any of it.
+//
+// Example:
+//
+//	uploads := filestore.MaxFileSize(filestore.Disk("/var/uploads"), 25<<20) // 25MB
+//	...
+//	_, err = io.Copy(file, request.Body)
+//	if errors.Is(err, filestore.ErrFileTooLarge) {
+//	    http.Error(w, "Your file is too big", http.StatusRequestEntityTooLarge)
+//	}
+func MaxFileSize(fs FS, maxBytes int64) FS {
+	return &maxSizeFS{FS: fs, limit: maxBytes}
+}
+
+func init() {
+	RegisterLayer("max_file_size", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Bytes int64 `json:"bytes"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		if settings.Bytes <= 0 {
+			return nil, fmt.Errorf("max_file_size: bytes must be positive")
+		}
+		return MaxFileSize(fs, settings.Bytes), nil
+	})
+}
+
+// sizeLimiter is implemented by stores that limit how big files can be, so that operations like
+// CopyAll() can fail before copying a file that would never fit anyway.
+type sizeLimiter interface {
+	maxFileSize() int64
+}
+
+type maxSizeFS struct {
+	FS
+	limit int64
+}
+
+func (m *maxSizeFS) maxFileSize() int64 {
+	return m.limit
+}
+
+// Write opens the file for writing, failing any write that would make the file bigger than the limit.
+func (m *maxSizeFS) Write(filePath string) (WriterFile, error) {
+	file, err := m.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &maxSizeWriterFile{WriterFile: file, fs: m.FS, path: filePath, limit: m.limit}, nil
+}
+
+func (m *maxSizeFS) ChangeDirectory(dir string) FS {
+	return &maxSizeFS{FS: m.FS.ChangeDirectory(dir), limit: m.limit}
+}
+
+func (m *maxSizeFS) withContext(ctx context.Context) FS {
+	return &maxSizeFS{FS: ForRequest(m.FS, ctx), limit: m.limit}
+}
+
+func (m *maxSizeFS) requestContext() context.Context {
+	return RequestContext(m.FS)
+}
+
+func (m *maxSizeFS) wrapped() []FS {
+	return []FS{m.FS}
+}
+
+// maxSizeWriterFile keeps track of where each write ends so it can stop the file from growing past the limit.
+type maxSizeWriterFile struct {
+	WriterFile
+	mutex  sync.Mutex
+	fs     FS
+	path   string
+	limit  int64
+	offset int64
+
+	// err is the error from the write that would have crossed the limit, if any.
+	err error
+}
+
+func (w *maxSizeWriterFile) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.check(w.offset, len(p)); err != nil {
+		return 0, err
+	}
+	n, err := w.WriterFile.Write(p)
+	w.offset += int64(n)
+	return n, err
+}
+
+func (w *maxSizeWriterFile) WriteAt(p []byte, off int64) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.check(off, len(p)); err != nil {
+		return 0, err
+	}
+	return w.WriterFile.WriteAt(p, off)
+}
+
+func (w *maxSizeWriterFile) Seek(offset int64, whence int) (int64, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	position, err := w.WriterFile.Seek(offset, whence)
+	if err == nil {
+		w.offset = position
+	}
+	return position, err
+}
+
+// check fails when writing 'length' bytes at the offset would make the file bigger than the limit. Once
+// that happens, every other write fails, too.
+func (w *maxSizeWriterFile) check(offset int64, length int) error {
+	if w.err == nil && offset+int64(length) > w.limit {
+		w.err = &FileTooLargeError{Path: w.path, Limit: w.limit}
+	}
+	return w.err
+}
+
+// Close finishes writing the file. If any write crossed the limit, we remove the file instead and give
+// you that error again, since it's easy to ignore errors from io.Copy() and friends.
+func (w *maxSizeWriterFile) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.WriterFile.Close()
+	if w.err == nil {
+		return err
+	}
+	_ = w.fs.Remove(w.path)
+	return w.err
+}
+
+var _ requestBinder = &maxSizeFS{}
+var _ wrapper = &maxSizeFS{}
+var _ WriterFile = &maxSizeWriterFile{}
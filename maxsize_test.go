@@ -0,0 +1,109 @@
+package filestore_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type MaxFileSizeTestSuite struct {
+	suite.Suite
+	inner filestore.FS
+	fs    filestore.FS
+}
+
+func TestMaxFileSizeTestSuite(t *testing.T) {
+	suite.Run(t, &MaxFileSizeTestSuite{})
+}
+
+func (s *MaxFileSizeTestSuite) SetupTest() {
+	s.inner = filestore.Memory()
+	s.Require().NoError(writeString(s.inner, "small.txt", "abide"))
+	s.Require().NoError(writeString(s.inner, "big.txt", "the dude abides"))
+	s.fs = filestore.MaxFileSize(s.inner, 10)
+}
+
+func (s *MaxFileSizeTestSuite) TestWrite() {
+	s.Require().NoError(writeString(s.fs, "a.txt", "0123456789"), "Files right at the limit are fine")
+	s.Require().Equal("0123456789", s.read(s.inner, "a.txt"))
+
+	file, err := s.fs.Write("b.txt")
+	s.Require().NoError(err)
+	_, err = io.Copy(file, strings.NewReader("the dude abides"))
+	s.Require().ErrorIs(err, filestore.ErrFileTooLarge)
+
+	var tooLarge *filestore.FileTooLargeError
+	s.Require().True(errors.As(err, &tooLarge))
+	s.Require().Equal("b.txt", tooLarge.Path)
+	s.Require().Equal(int64(10), tooLarge.Limit)
+
+	_, err = file.Write([]byte("x"))
+	s.Require().ErrorIs(err, filestore.ErrFileTooLarge, "Writes after crossing the limit should keep failing")
+	s.Require().ErrorIs(file.Close(), filestore.ErrFileTooLarge, "Close should report the error again")
+	s.Require().False(s.inner.Exists("b.txt"), "Files that were too large should be removed")
+
+	// Other files in the store should be unaffected.
+	s.Require().Equal("abide", s.read(s.inner, "small.txt"))
+	s.Require().Equal("the dude abides", s.read(s.inner, "big.txt"))
+}
+
+func (s *MaxFileSizeTestSuite) TestWrite_seek() {
+	file, err := s.fs.Write("seek.txt")
+	s.Require().NoError(err)
+	_, err = file.Write([]byte("abc"))
+	s.Require().NoError(err)
+	_, err = file.Seek(8, io.SeekStart)
+	s.Require().NoError(err)
+	_, err = file.Write([]byte("yz"))
+	s.Require().NoError(err)
+	_, err = file.Seek(0, io.SeekStart)
+	s.Require().NoError(err)
+	_, err = file.Write([]byte("ABC"))
+	s.Require().NoError(err, "Overwriting existing data shouldn't count against the limit")
+
+	_, err = file.WriteAt([]byte("nope"), 9)
+	s.Require().ErrorIs(err, filestore.ErrFileTooLarge)
+	s.Require().Error(file.Close())
+	s.Require().False(s.inner.Exists("seek.txt"))
+}
+
+func (s *MaxFileSizeTestSuite) TestChangeDirectory() {
+	dir := s.fs.ChangeDirectory("dir")
+	s.Require().ErrorIs(writeString(dir, "big.txt", "the dude abides"), filestore.ErrFileTooLarge)
+	s.Require().NoError(writeString(dir, "small.txt", "abide"))
+	s.Require().Equal("abide", s.read(s.inner, "dir/small.txt"))
+}
+
+func (s *MaxFileSizeTestSuite) TestCopyAll() {
+	err := filestore.CopyAll(s.inner, "big.txt", s.fs, "copy/big.txt")
+	s.Require().ErrorIs(err, filestore.ErrFileTooLarge)
+	s.Require().False(s.inner.Exists("copy/big.txt"), "Should fail before writing anything")
+
+	s.Require().NoError(filestore.CopyAll(s.inner, "small.txt", s.fs, "copy/small.txt"))
+	s.Require().Equal("abide", s.read(s.inner, "copy/small.txt"))
+}
+
+func (s *MaxFileSizeTestSuite) TestFromConfig() {
+	fs, err := filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "max_file_size", Options: filestore.LayerOptions{"bytes": 4}}},
+	})
+	s.Require().NoError(err)
+	s.Require().ErrorIs(writeString(fs, "a.txt", "abide"), filestore.ErrFileTooLarge)
+
+	_, err = filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "max_file_size"}},
+	})
+	s.Require().Error(err, "Should require a limit")
+}
+
+func (s *MaxFileSizeTestSuite) read(fs filestore.FS, name string) string {
+	content, err := readString(fs, name)
+	s.Require().NoError(err)
+	return content
+}
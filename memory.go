@@ -0,0 +1,585 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	RegisterScheme("mem", func(u *url.URL) (FS, error) {
+		return Memory().ChangeDirectory(urlPath(u)), nil
+	})
+}
+
+// Memory creates a new file store that keeps all of its files and directories in memory. Nothing
+// is ever written to the local disk, so this is ideal for unit tests or short-lived scratch space.
+//
+// A MemoryFS is safe for concurrent use by multiple goroutines. Readers always operate on a
+// snapshot of the file's contents as they were when Read() was called, so a concurrent Write()
+// to the same path never changes the bytes that an already-open reader sees. Data written through
+// a WriterFile becomes visible to new readers once that WriterFile is closed.
+//
+// You can supply the WithClock() option to control the modification/creation times
+// recorded for files in this store.
+//
+// Example:
+//
+//	files := Memory()
+//
+//	output, err := files.Write("conf/config.json")
+//	if err != nil {
+//	    // handle your error nicely
+//	}
+//	output.Write([]byte(`{"timeout":"10s"}`))
+//	output.Close()
+//
+//	input, err := files.Read("conf/config.json")
+func Memory(opts ...Option) *MemoryFS {
+	options := newOptions(opts)
+	now := options.clock.Now()
+	root := &memoryNode{name: "/", dir: true, modTime: now, created: now, children: map[string]*memoryNode{}}
+	return &MemoryFS{store: &memoryStore{root: root, clock: options.clock}, basePath: "/"}
+}
+
+// MemoryFS is a file store whose files/directories only live in memory.
+type MemoryFS struct {
+	store    *memoryStore
+	basePath string
+
+	// ctx is the context of the request this store was derived for via ForRequest(), if any.
+	ctx context.Context
+}
+
+// memoryStore is the file tree shared by a MemoryFS and all of the instances you derive from it
+// using ChangeDirectory(). The mutex guards the entire tree, including the contents of each node.
+type memoryStore struct {
+	mutex sync.RWMutex
+	root  *memoryNode
+	clock Clock
+	// revisions counts every change to a file in the store so each version of a file gets a unique revision.
+	revisions uint64
+}
+
+// memoryNode is a single file or directory in the tree. The data slice is never modified in
+// place; writes always replace it wholesale, which is what allows readers to hold onto a
+// snapshot of it without copying.
+type memoryNode struct {
+	name     string
+	dir      bool
+	data     []byte
+	modTime  time.Time
+	created  time.Time
+	revision uint64
+	children map[string]*memoryNode
+}
+
+// info builds a point-in-time snapshot of the node's 'stat' info. You must hold at least a
+// read lock on the store when calling this.
+func (node *memoryNode) info() FileInfo {
+	return memoryFileInfo{
+		name:     node.name,
+		size:     int64(len(node.data)),
+		dir:      node.dir,
+		modTime:  node.modTime,
+		created:  node.created,
+		revision: node.revision,
+	}
+}
+
+// token returns the node's change token, or an empty string when the node doesn't exist. You must hold at
+// least a read lock on the store when calling this.
+func (node *memoryNode) token() string {
+	if node == nil {
+		return ""
+	}
+	return ChangeToken(node.info())
+}
+
+// lookup finds the node at the given absolute, cleaned path. It returns nil when there is no such
+// file/directory. You must hold at least a read lock on the store when calling this.
+func (store *memoryStore) lookup(fullPath string) *memoryNode {
+	node := store.root
+	for _, segment := range memorySegments(fullPath) {
+		if node == nil || !node.dir {
+			return nil
+		}
+		node = node.children[segment]
+	}
+	return node
+}
+
+// mkdirAll finds the directory at the given absolute path, lazily creating it and any missing
+// parents as it goes. You must hold the write lock on the store when calling this.
+func (store *memoryStore) mkdirAll(fullPath string, now time.Time) (*memoryNode, error) {
+	node := store.root
+	for _, segment := range memorySegments(fullPath) {
+		child, ok := node.children[segment]
+		if !ok {
+			child = &memoryNode{name: segment, dir: true, modTime: now, created: now, children: map[string]*memoryNode{}}
+			node.children[segment] = child
+		}
+		if !child.dir {
+			return nil, fmt.Errorf("%s: %w", segment, ErrNotDirectory)
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// memorySegments breaks an absolute, cleaned path like "/foo/bar/baz.txt" into its individual
+// segments (e.g. "foo", "bar", "baz.txt"). The root path "/" has no segments.
+func memorySegments(fullPath string) []string {
+	fullPath = strings.Trim(fullPath, "/")
+	if fullPath == "" {
+		return nil
+	}
+	return strings.Split(fullPath, "/")
+}
+
+// resolve converts a path relative to this FS' working directory into an absolute path within the store.
+// Since the base path is always absolute, there's no way to ".." your way out of the root.
+func (m MemoryFS) resolve(filePath string) (string, error) {
+	return resolvePath(m.basePath, filePath)
+}
+
+// WorkingDirectory returns the current FS context's path/directory.
+func (m MemoryFS) WorkingDirectory() string {
+	return path.Clean(m.basePath)
+}
+
+// ChangeDirectory returns a new FS that is rooted in the given subdirectory of this FS. The new
+// instance shares the same underlying files as this one.
+func (m MemoryFS) ChangeDirectory(dir string) FS {
+	return &MemoryFS{store: m.store, basePath: joinPath(m.basePath, dir), ctx: m.ctx}
+}
+
+// withContext returns a copy of this store that carries the request's context; see ForRequest().
+func (m MemoryFS) withContext(ctx context.Context) FS {
+	m.ctx = ctx
+	return &m
+}
+
+func (m MemoryFS) requestContext() context.Context {
+	return contextOrBackground(m.ctx)
+}
+
+// Stat fetches metadata about the file w/o actually opening it for reading/writing.
+func (m MemoryFS) Stat(filePath string) (FileInfo, error) {
+	fullPath, err := m.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("memory", "stat", filePath, err)
+	}
+
+	m.store.mutex.RLock()
+	defer m.store.mutex.RUnlock()
+
+	node := m.store.lookup(fullPath)
+	if node == nil {
+		return nil, newPathError("memory", "stat", filePath, fs.ErrNotExist)
+	}
+	return node.info(), nil
+}
+
+// Exists returns true when the file/directory already exits in the file system.
+func (m MemoryFS) Exists(filePath string) bool {
+	fullPath, err := m.resolve(filePath)
+	if err != nil {
+		return false
+	}
+
+	m.store.mutex.RLock()
+	defer m.store.mutex.RUnlock()
+
+	return m.store.lookup(fullPath) != nil
+}
+
+// Read opens the given file at the given path, providing you with an io.Reader that
+// you can use to stream bytes from it. The reader sees a snapshot of the file's contents
+// at the time you called Read(), regardless of any writes that happen afterwards.
+func (m MemoryFS) Read(filePath string) (ReaderFile, error) {
+	fullPath, err := m.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("memory", "open", filePath, err)
+	}
+
+	m.store.mutex.RLock()
+	defer m.store.mutex.RUnlock()
+
+	node := m.store.lookup(fullPath)
+	if node == nil {
+		return nil, newPathError("memory", "open", filePath, fs.ErrNotExist)
+	}
+	if node.dir {
+		return nil, newPathError("memory", "open", filePath, errIsDirectory)
+	}
+	return newBytesReaderFile(node.data), nil
+}
+
+// Write opens the given file at the given path for writing. The resulting file
+// behaves like a standard io.Writer/At.
+//
+// This operation will lazy-create the parent directory(s) if it does not exist. Should
+// the file already exist, this will overwrite its entire contents so that it only contains
+// what you write this time. The new contents are visible to readers once you close the file.
+func (m MemoryFS) Write(filePath string) (WriterFile, error) {
+	fullPath, err := m.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("memory", "write", filePath, err)
+	}
+
+	m.store.mutex.Lock()
+	defer m.store.mutex.Unlock()
+
+	if fullPath == "/" {
+		return nil, newPathError("memory", "write", filePath, errIsDirectory)
+	}
+
+	now := m.store.clock.Now()
+	parent, err := m.store.mkdirAll(path.Dir(fullPath), now)
+	if err != nil {
+		return nil, newPathError("memory", "write", filePath, err)
+	}
+
+	name := path.Base(fullPath)
+	node, ok := parent.children[name]
+	switch {
+	case !ok:
+		node = &memoryNode{name: name, created: now}
+		parent.children[name] = node
+	case node.dir:
+		return nil, newPathError("memory", "write", filePath, errIsDirectory)
+	}
+	node.data = nil
+	node.modTime = now
+	m.store.revisions++
+	node.revision = m.store.revisions
+	return newMemoryWriterFile(&memoryWriterFile{store: m.store, node: node}), nil
+}
+
+// WriteIfUnchanged opens the given file at the given path for writing, but only replaces the file when you
+// close it if its change token (see ChangeToken) still matches the given one. An empty token means that the
+// file must not exist yet.
+func (m MemoryFS) WriteIfUnchanged(filePath string, token string) (WriterFile, error) {
+	fullPath, err := m.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("memory", "write", filePath, err)
+	}
+	if fullPath == "/" {
+		return nil, newPathError("memory", "write", filePath, errIsDirectory)
+	}
+
+	m.store.mutex.RLock()
+	defer m.store.mutex.RUnlock()
+
+	if m.store.lookup(fullPath).token() != token {
+		return nil, newPathError("memory", "write", filePath, ErrPreconditionFailed)
+	}
+	return newMemoryWriterFile(&memoryWriterFile{store: m.store, path: filePath, fullPath: fullPath, token: &token}), nil
+}
+
+// MoveIfUnchanged takes an existing file at the fromPath location and moves it to the toPath location, but
+// only if the change token (see ChangeToken) of whatever is at toPath still matches the given one. An empty
+// token means that nothing may exist at toPath yet.
+func (m MemoryFS) MoveIfUnchanged(fromPath string, toPath string, token string) error {
+	return m.move(fromPath, toPath, &token)
+}
+
+// List performs the equivalent of the "ls" command. It returns a slice of
+// all files and directories found in the target dirPath.
+//
+// You can optionally provide a set of filters to limit which files/directories
+// are included in the final set.
+func (m MemoryFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	fullPath, err := m.resolve(dirPath)
+	if err != nil {
+		return nil, newPathError("memory", "list files", dirPath, err)
+	}
+
+	m.store.mutex.RLock()
+	node := m.store.lookup(fullPath)
+	if node == nil {
+		m.store.mutex.RUnlock()
+		return nil, nil
+	}
+	if !node.dir {
+		m.store.mutex.RUnlock()
+		return nil, newPathError("memory", "list files", dirPath, ErrNotDirectory)
+	}
+	infos := make([]FileInfo, 0, len(node.children))
+	for _, child := range node.children {
+		infos = append(infos, child.info())
+	}
+	m.store.mutex.RUnlock()
+
+	// Run the filters outside of the lock; they're caller-supplied code that could be slow.
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	results, err := filterFiles(infos, filters)
+	if err != nil {
+		return nil, newPathError("memory", "list files", dirPath, err)
+	}
+	return results, nil
+}
+
+// Remove deletes the given file/directory and any of its children. Removing the root
+// of the store simply removes everything in it.
+func (m MemoryFS) Remove(fileOrDirPath string) error {
+	fullPath, err := m.resolve(fileOrDirPath)
+	if err != nil {
+		return newPathError("memory", "remove", fileOrDirPath, err)
+	}
+
+	m.store.mutex.Lock()
+	defer m.store.mutex.Unlock()
+
+	if fullPath == "/" {
+		m.store.root.children = map[string]*memoryNode{}
+		return nil
+	}
+
+	parent := m.store.lookup(path.Dir(fullPath))
+	if parent == nil || !parent.dir {
+		return nil
+	}
+	delete(parent.children, path.Base(fullPath))
+	return nil
+}
+
+// Move takes an existing file at the fromPath location and moves it to another
+// spot in this file system; the toPath location.
+func (m MemoryFS) Move(fromPath string, toPath string) error {
+	return m.move(fromPath, toPath, nil)
+}
+
+// move does the work for Move() and MoveIfUnchanged(). When the token isn't nil, the change token of
+// whatever is at the destination must match it.
+func (m MemoryFS) move(fromPath string, toPath string, token *string) error {
+	fromFullPath, err := m.resolve(fromPath)
+	if err != nil {
+		return newPathError("memory", "move", fromPath, err)
+	}
+	toFullPath, err := m.resolve(toPath)
+	if err != nil {
+		return newPathError("memory", "move", toPath, err)
+	}
+
+	m.store.mutex.Lock()
+	defer m.store.mutex.Unlock()
+
+	// Ensure the original file exists in the first place.
+	node := m.store.lookup(fromFullPath)
+	if node == nil {
+		return newPathError("memory", "move", fromPath, fs.ErrNotExist)
+	}
+	if token != nil && m.store.lookup(toFullPath).token() != *token {
+		return newPathError("memory", "move", toPath, ErrPreconditionFailed)
+	}
+	if fromFullPath == toFullPath {
+		return nil
+	}
+	if fromFullPath == "/" || strings.HasPrefix(toFullPath, fromFullPath+"/") {
+		return newPathError("memory", "move", fromPath, errors.New("can not move a directory inside of itself"))
+	}
+
+	// Mirror the rules for os.Rename(). You can overwrite an existing file with another
+	// file, but you can never replace an existing directory or overwrite a file w/ a directory.
+	if existing := m.store.lookup(toFullPath); existing != nil {
+		if existing.dir {
+			return newPathError("memory", "move", toPath, fs.ErrExist)
+		}
+		if node.dir {
+			return newPathError("memory", "move", toPath, ErrNotDirectory)
+		}
+	}
+
+	// Lazily create the directory where we will move the file to.
+	toParent, err := m.store.mkdirAll(path.Dir(toFullPath), m.store.clock.Now())
+	if err != nil {
+		return newPathError("memory", "move", toPath, err)
+	}
+
+	fromParent := m.store.lookup(path.Dir(fromFullPath))
+	delete(fromParent.children, node.name)
+	node.name = path.Base(toFullPath)
+	toParent.children[node.name] = node
+	return nil
+}
+
+// Ping always succeeds since there's no external resource that could be unavailable.
+func (m MemoryFS) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// memoryFileInfo is an immutable snapshot of a memoryNode's 'stat' info.
+type memoryFileInfo struct {
+	name     string
+	size     int64
+	dir      bool
+	modTime  time.Time
+	created  time.Time
+	revision uint64
+}
+
+func (info memoryFileInfo) Name() string {
+	return info.name
+}
+
+func (info memoryFileInfo) Size() int64 {
+	return info.size
+}
+
+func (info memoryFileInfo) Mode() fs.FileMode {
+	if info.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (info memoryFileInfo) ModTime() time.Time {
+	return info.modTime
+}
+
+func (info memoryFileInfo) IsDir() bool {
+	return info.dir
+}
+
+func (info memoryFileInfo) Sys() any {
+	return nil
+}
+
+// CreationTime returns the time that this file was first written to the store.
+func (info memoryFileInfo) CreationTime() (time.Time, bool) {
+	return info.created, true
+}
+
+// ChangeToken returns the revision of the file, which changes every time it's written.
+func (info memoryFileInfo) ChangeToken() string {
+	return strconv.FormatUint(info.revision, 16)
+}
+
+// newBytesReaderFile creates a ReaderFile that reads from the given slice. The slice must not be
+// modified for as long as the reader is in use.
+func newBytesReaderFile(data []byte) ReaderFile {
+	return &memoryReaderFile{reader: bytes.NewReader(data)}
+}
+
+// memoryReaderFile reads from a snapshot of a file's contents.
+type memoryReaderFile struct {
+	mutex  sync.Mutex
+	reader *bytes.Reader
+	closed bool
+}
+
+// Read reads up to len(b) bytes from the file and stores them in b. At end of file, Read returns 0, io.EOF.
+func (r *memoryReaderFile) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return 0, fmt.Errorf("memory fs: read: %w", fs.ErrClosed)
+	}
+	return r.reader.Read(p)
+}
+
+// ReadAt reads len(b) bytes from the file starting at byte offset off. At end of file, that error is io.EOF.
+func (r *memoryReaderFile) ReadAt(p []byte, off int64) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return 0, fmt.Errorf("memory fs: read at: %w", fs.ErrClosed)
+	}
+	return r.reader.ReadAt(p, off)
+}
+
+// Seek moves to the given offset w/o reading any data.
+func (r *memoryReaderFile) Seek(offset int64, whence int) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return 0, fmt.Errorf("memory fs: seek: %w", fs.ErrClosed)
+	}
+	return r.reader.Seek(offset, whence)
+}
+
+// Close releases the snapshot. You will not be able to read any more data once this has been performed.
+func (r *memoryReaderFile) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closed = true
+	return nil
+}
+
+// memoryWriterFile buffers all writes privately and publishes them to the file's node on Close(). Files
+// opened by WriteIfUnchanged() don't have a node yet; they look it up (or create it) once they're closed,
+// provided that it still has the expected change token.
+type memoryWriterFile struct {
+	*bufferWriter
+	store    *memoryStore
+	node     *memoryNode
+	path     string
+	fullPath string
+	token    *string
+}
+
+func newMemoryWriterFile(file *memoryWriterFile) *memoryWriterFile {
+	file.bufferWriter = newBufferWriter("memory", file.publish)
+	return file
+}
+
+// publish makes everything you wrote visible to subsequent readers.
+func (w *memoryWriterFile) publish(data []byte) error {
+	w.store.mutex.Lock()
+	defer w.store.mutex.Unlock()
+
+	if w.token != nil {
+		if err := w.claimNode(); err != nil {
+			return err
+		}
+	}
+	w.node.data = data
+	w.node.modTime = w.store.clock.Now()
+	w.store.revisions++
+	w.node.revision = w.store.revisions
+	return nil
+}
+
+// claimNode finds (or creates) the node for a file opened by WriteIfUnchanged(), provided that it still has
+// the expected change token. You must hold the write lock on the store when calling this.
+func (w *memoryWriterFile) claimNode() error {
+	node := w.store.lookup(w.fullPath)
+	if node.token() != *w.token {
+		return newPathError("memory", "write", w.path, ErrPreconditionFailed)
+	}
+	if node != nil && node.dir {
+		return newPathError("memory", "write", w.path, errIsDirectory)
+	}
+	if node != nil {
+		w.node = node
+		return nil
+	}
+
+	now := w.store.clock.Now()
+	parent, err := w.store.mkdirAll(path.Dir(w.fullPath), now)
+	if err != nil {
+		return newPathError("memory", "write", w.path, err)
+	}
+	w.node = &memoryNode{name: path.Base(w.fullPath), created: now}
+	parent.children[w.node.name] = w.node
+	return nil
+}
+
+var _ FS = MemoryFS{}
+var _ ConditionalWriter = MemoryFS{}
+var _ requestBinder = MemoryFS{}
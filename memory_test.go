@@ -0,0 +1,402 @@
+package filestore_test
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type MemoryTestSuite struct {
+	suite.Suite
+	fs filestore.FS
+}
+
+func TestMemoryTestSuite(t *testing.T) {
+	suite.Run(t, &MemoryTestSuite{})
+}
+
+func (s *MemoryTestSuite) SetupTest() {
+	s.fs = filestore.Memory()
+	s.write("1.lebowski", "jeff")
+	s.write("2.lebowski", "walter")
+	s.write("3.lebowski", "donnie")
+	s.write("4.lebowski", "maude")
+	s.write("duderino/5.lebowski", "jackie")
+	s.write("duderino/6.lebowski", "nihilist")
+
+	// There's no "mkdir" operation, so create an empty "dude/" directory by writing a file and removing it.
+	s.write("dude/tmp.txt", "")
+	s.Require().NoError(s.fs.Remove("dude/tmp.txt"))
+}
+
+func (s *MemoryTestSuite) TestStat() {
+	info, err := s.fs.Stat("1.lebowski")
+	s.Require().NoError(err, "Running 'stat' on valid file should not give an error")
+	s.Require().Equal("1.lebowski", info.Name())
+	s.Require().Equal(int64(4), info.Size())
+	s.Require().False(info.IsDir())
+
+	info, err = s.fs.Stat("duderino")
+	s.Require().NoError(err, "Running 'stat' on valid directory should not give an error")
+	s.Require().True(info.IsDir())
+
+	_, err = s.fs.Stat("does-not-exist.txt")
+	s.Require().Error(err, "Running 'stat' on non-existent file should give an error")
+}
+
+func (s *MemoryTestSuite) TestWorkingDirectory() {
+	fs := s.fs
+	s.Require().Equal("/", fs.WorkingDirectory())
+
+	fs = fs.ChangeDirectory("duderino")
+	s.Require().Equal("/duderino", fs.WorkingDirectory())
+	s.Require().Equal("jackie", s.read(fs, "5.lebowski"), "Changing directory should share the same files.")
+
+	fs = fs.ChangeDirectory("../../..")
+	s.Require().Equal("/", fs.WorkingDirectory(), "Should not be able to cd above the root.")
+}
+
+func (s *MemoryTestSuite) TestExists() {
+	s.Require().True(s.fs.Exists("."), "Current directory should exist")
+	s.Require().True(s.fs.Exists("1.lebowski"), "Real file should exist")
+	s.Require().True(s.fs.Exists("duderino"), "Real directory should exist")
+	s.Require().True(s.fs.Exists("duderino/../dude"), "Real dir should exist when specifying relative path")
+	s.Require().False(s.fs.Exists("asldkfj"), "Non-existing entry should be false for Exists()")
+	s.Require().False(s.fs.Exists("1.lebowski/nope"), "Can't have children of a file")
+
+	s.Require().True(s.fs.ChangeDirectory("duderino").Exists("5.lebowski"), "Real file should exist even after cd")
+	s.Require().False(s.fs.ChangeDirectory("duderino").Exists("1.lebowski"), "Non-existing file should not exist even after cd")
+}
+
+func (s *MemoryTestSuite) TestRead() {
+	_, err := s.fs.Read("not-found.txt")
+	s.Require().Error(err, "Reading invalid file should fail")
+
+	_, err = s.fs.Read("duderino")
+	s.Require().Error(err, "Reading directory as if it were a file should fail")
+
+	file, err := s.fs.Read("duderino/6.lebowski")
+	s.Require().NoError(err, "Reading valid file should not fail")
+	defer file.Close()
+
+	buf := make([]byte, 4)
+	_, err = file.ReadAt(buf, 4)
+	s.Require().NoError(err)
+	s.Require().Equal("list", string(buf))
+
+	_, err = file.Seek(3, io.SeekStart)
+	s.Require().NoError(err)
+	data, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal("ilist", string(data))
+
+	s.Require().NoError(file.Close())
+	_, err = file.Read(buf)
+	s.Require().Error(err, "Should not be able to read a closed file")
+}
+
+func (s *MemoryTestSuite) TestWrite() {
+	s.write("1.lebowski", "thank you donnie")
+	s.Require().Equal("thank you donnie", s.read(s.fs, "1.lebowski"), "Overwritten file should contain new data.")
+
+	s.write("a/b/c/d/x.lebowski", "abide")
+	s.Require().Equal("abide", s.read(s.fs, "a/b/c/d/x.lebowski"), "Should auto-create parent directories.")
+
+	_, err := s.fs.Write("duderino")
+	s.Require().Error(err, "Should not be able to write to a directory")
+
+	_, err = s.fs.Write("1.lebowski/nope.txt")
+	s.Require().ErrorIs(err, filestore.ErrNotDirectory, "Should not be able to write a file inside of another file")
+
+	file, err := s.fs.Write("seek.lebowski")
+	s.Require().NoError(err)
+	_, _ = file.Write([]byte("abcdef"))
+	_, _ = file.Seek(2, io.SeekStart)
+	_, _ = file.Write([]byte("X"))
+	_, _ = file.WriteAt([]byte("YZ"), 8)
+	s.Require().NoError(file.Close())
+	s.Require().Equal("abXdef\x00\x00YZ", s.read(s.fs, "seek.lebowski"))
+}
+
+// Readers should see a snapshot of the file and writes are only visible once the writer is closed.
+func (s *MemoryTestSuite) TestWrite_snapshots() {
+	reader, err := s.fs.Read("1.lebowski")
+	s.Require().NoError(err)
+	defer reader.Close()
+
+	writer, err := s.fs.Write("1.lebowski")
+	s.Require().NoError(err)
+	_, _ = writer.Write([]byte("the dude"))
+	s.Require().True(s.fs.Exists("1.lebowski"))
+	s.Require().Equal("", s.read(s.fs, "1.lebowski"), "Unclosed writes should not be visible to new readers.")
+
+	s.Require().NoError(writer.Close())
+	s.Require().Equal("the dude", s.read(s.fs, "1.lebowski"), "Closed writes should be visible to new readers.")
+
+	data, err := io.ReadAll(reader)
+	s.Require().NoError(err)
+	s.Require().Equal("jeff", string(data), "Existing readers should not see new writes.")
+}
+
+func (s *MemoryTestSuite) TestList() {
+	files, err := s.fs.List("1.lebowski")
+	s.Require().Error(err, "File list for non-directories should return an error.")
+	s.Require().Equal(0, len(files))
+
+	files, err = s.fs.List("nope")
+	s.Require().NoError(err, "File list for non-existent directories should not return an error.")
+	s.Require().Equal(0, len(files))
+
+	files, err = s.fs.List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(6, len(files))
+	s.assertFile(files[0], "1.lebowski")
+	s.assertFile(files[3], "4.lebowski")
+	s.assertDir(files[4], "dude")
+	s.assertDir(files[5], "duderino")
+
+	files, err = s.fs.List(".", filestore.WithPattern("dude*"))
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(files))
+	s.assertDir(files[0], "dude")
+	s.assertDir(files[1], "duderino")
+}
+
+func (s *MemoryTestSuite) TestRemove() {
+	s.Require().NoError(s.fs.Remove("asldfjslkdfjasdf"), "Removing non-existent file should NOT return an error")
+	s.Require().NoError(s.fs.Remove("1.lebowski/asldfjslkdfjasdf"), "Removing non-existent file should NOT return an error")
+
+	s.Require().NoError(s.fs.Remove("4.lebowski"))
+	s.Require().False(s.fs.Exists("4.lebowski"))
+
+	s.Require().NoError(s.fs.Remove("duderino"))
+	s.Require().False(s.fs.Exists("duderino"))
+	s.Require().False(s.fs.Exists("duderino/5.lebowski"))
+
+	files, _ := s.fs.List(".")
+	s.Require().Equal(4, len(files))
+
+	s.Require().NoError(s.fs.Remove("."))
+	files, _ = s.fs.List(".")
+	s.Require().Equal(0, len(files), "Removing the root should remove everything.")
+}
+
+func (s *MemoryTestSuite) TestMove() {
+	s.Require().Error(s.fs.Move("nope.lebowski", "jeff.lebowski"), "Moving non-existent file should fail")
+	s.Require().Error(s.fs.Move("1.lebowski", "dude"), "Moving file onto directory should fail")
+	s.Require().Error(s.fs.Move("duderino", "1.lebowski"), "Moving directory onto file should fail")
+	s.Require().Error(s.fs.Move("duderino", "dude"), "Moving directory onto directory should fail")
+	s.Require().Error(s.fs.Move("duderino", "duderino/inner"), "Moving directory inside itself should fail")
+	s.Require().ErrorIs(s.fs.Move("2.lebowski", "1.lebowski/2.lebowski"), filestore.ErrNotDirectory, "Moving file inside of another file should fail")
+
+	s.Require().NoError(s.fs.Move("1.lebowski", "2.lebowski"), "Moving file onto another file should overwrite it")
+	s.Require().False(s.fs.Exists("1.lebowski"))
+	s.Require().Equal("jeff", s.read(s.fs, "2.lebowski"))
+
+	s.Require().NoError(s.fs.Move("duderino", "dude/a/b/el duderino"), "Moving dir to a new location should work")
+	s.Require().False(s.fs.Exists("duderino"))
+	s.Require().Equal("jackie", s.read(s.fs, "dude/a/b/el duderino/5.lebowski"))
+	s.Require().Equal("nihilist", s.read(s.fs, "dude/a/b/el duderino/6.lebowski"))
+}
+
+func (s *MemoryTestSuite) TestWithClock() {
+	start := time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC)
+	clock := filestoretest.NewClock(start)
+	s.fs = filestore.Memory(filestore.WithClock(clock))
+
+	s.write("a/foo.txt", "foo")
+	clock.Advance(time.Hour)
+	s.write("a/bar.txt", "bar")
+
+	info, err := s.fs.Stat("a/foo.txt")
+	s.Require().NoError(err)
+	s.Require().Equal(start, info.ModTime())
+
+	info, err = s.fs.Stat("a/bar.txt")
+	s.Require().NoError(err)
+	s.Require().Equal(start.Add(time.Hour), info.ModTime())
+
+	files, err := s.fs.List("a", filestore.WithCreatedAfter(start))
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(files))
+	s.assertFile(files[0], "bar.txt")
+}
+
+// Hammer the store from lots of goroutines. This is mainly useful when running with the race
+// detector enabled (e.g. "make race"), but it also ensures that we don't deadlock anywhere.
+func (s *MemoryTestSuite) TestConcurrency() {
+	wg := sync.WaitGroup{}
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("concurrent/%d/file.txt", i)
+			for j := 0; j < 20; j++ {
+				file, err := s.fs.Write(name)
+				if err != nil {
+					continue
+				}
+				_, _ = file.Write([]byte("abide"))
+				_ = file.Close()
+
+				_ = s.read(s.fs, "1.lebowski")
+				_ = s.read(s.fs, name)
+				_, _ = s.fs.List("concurrent")
+				_, _ = s.fs.Stat(name)
+				_ = s.fs.ChangeDirectory("concurrent").Exists(fmt.Sprintf("%d", i))
+				_ = s.fs.Move(name, name+".moved")
+				_ = s.fs.Remove(name + ".moved")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	files, err := s.fs.List("concurrent")
+	s.Require().NoError(err)
+	s.Require().Equal(25, len(files))
+}
+
+// Run the same script of operations against the real disk and the memory store; they should
+// agree on the outcome of every single operation.
+func (s *MemoryTestSuite) TestParityWithDisk() {
+	diskFS := filestore.Disk(s.T().TempDir())
+	memoryFS := filestore.Memory()
+
+	type outcome struct {
+		Exists bool
+		Err    bool
+		Names  []string
+		Data   string
+	}
+	list := func(fs filestore.FS, dir string, filters ...filestore.FileFilter) outcome {
+		files, err := fs.List(dir, filters...)
+		result := outcome{Err: err != nil}
+		for _, file := range files {
+			result.Names = append(result.Names, fmt.Sprintf("%s[dir=%v]", file.Name(), file.IsDir()))
+		}
+		return result
+	}
+	steps := []func(fs filestore.FS) outcome{
+		func(fs filestore.FS) outcome { return outcome{Err: writeString(fs, "a/b/c.txt", "c") != nil} },
+		func(fs filestore.FS) outcome { return outcome{Err: writeString(fs, "a/b/d.log", "d") != nil} },
+		func(fs filestore.FS) outcome { return outcome{Err: writeString(fs, "a/e.TXT", "e") != nil} },
+		func(fs filestore.FS) outcome { return outcome{Err: writeString(fs, "a/b", "nope") != nil} },
+		func(fs filestore.FS) outcome { return outcome{Err: writeString(fs, "a/e.TXT/f.txt", "nope") != nil} },
+		func(fs filestore.FS) outcome { return list(fs, "a") },
+		func(fs filestore.FS) outcome { return list(fs, "a/b", filestore.WithExts("txt", "log")) },
+		func(fs filestore.FS) outcome { return list(fs, "a", filestore.WithExt("txt")) },
+		func(fs filestore.FS) outcome { return list(fs, "a/b", filestore.WithPattern("?.txt")) },
+		func(fs filestore.FS) outcome { return list(fs, "a/e.TXT") },
+		func(fs filestore.FS) outcome { return list(fs, "nope") },
+		func(fs filestore.FS) outcome { return outcome{Exists: fs.Exists("a/b/../e.TXT")} },
+		func(fs filestore.FS) outcome { return outcome{Exists: fs.ChangeDirectory("a/b").Exists("c.txt")} },
+		func(fs filestore.FS) outcome {
+			data, err := readString(fs, "a/b/c.txt")
+			return outcome{Data: data, Err: err != nil}
+		},
+		func(fs filestore.FS) outcome {
+			data, err := readString(fs, "a/b")
+			return outcome{Data: data, Err: err != nil}
+		},
+		func(fs filestore.FS) outcome {
+			data, err := readString(fs, "a/nope")
+			return outcome{Data: data, Err: err != nil}
+		},
+		func(fs filestore.FS) outcome { return outcome{Err: fs.Move("a/b/c.txt", "a/b/d.log") != nil} },
+		func(fs filestore.FS) outcome { return outcome{Err: fs.Move("a/b/d.log", "a/b") != nil} },
+		func(fs filestore.FS) outcome { return outcome{Err: fs.Move("a/b", "a/e.TXT") != nil} },
+		func(fs filestore.FS) outcome { return outcome{Err: fs.Move("a/nope", "a/nope2") != nil} },
+		func(fs filestore.FS) outcome { return outcome{Err: fs.Move("a/b", "x/y/z") != nil} },
+		func(fs filestore.FS) outcome { return list(fs, "x/y/z") },
+		func(fs filestore.FS) outcome {
+			data, err := readString(fs, "x/y/z/d.log")
+			return outcome{Data: data, Err: err != nil}
+		},
+		func(fs filestore.FS) outcome { return outcome{Err: fs.Remove("x/y") != nil} },
+		func(fs filestore.FS) outcome { return outcome{Err: fs.Remove("x/nope") != nil} },
+		func(fs filestore.FS) outcome { return list(fs, "x") },
+		func(fs filestore.FS) outcome {
+			info, err := fs.Stat("a/e.TXT")
+			return outcome{Err: err != nil, Data: fmt.Sprintf("%s:%d:%v", info.Name(), info.Size(), info.IsDir())}
+		},
+		func(fs filestore.FS) outcome { _, err := fs.Stat("a/nope"); return outcome{Err: err != nil} },
+	}
+	for i, step := range steps {
+		s.Require().Equal(step(diskFS), step(memoryFS), "Step %d should behave the same for disk and memory", i)
+	}
+}
+
+func writeString(fs filestore.FS, name string, content string) error {
+	file, err := fs.Write(name)
+	if err != nil {
+		return err
+	}
+	_, _ = file.Write([]byte(content))
+	return file.Close()
+}
+
+func readString(fs filestore.FS, name string) (string, error) {
+	file, err := fs.Read(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	return string(data), err
+}
+
+func (s *MemoryTestSuite) write(name string, content string) {
+	file, err := s.fs.Write(name)
+	s.Require().NoError(err)
+	_, err = file.Write([]byte(content))
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+}
+
+func (s *MemoryTestSuite) read(fs filestore.FS, name string) string {
+	file, err := fs.Read(name)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	data, _ := io.ReadAll(file)
+	return string(data)
+}
+
+func (s *MemoryTestSuite) assertFile(file filestore.FileInfo, name string) {
+	s.Require().Equal(name, file.Name())
+	s.Require().False(file.IsDir())
+}
+
+func (s *MemoryTestSuite) assertDir(file filestore.FileInfo, name string) {
+	s.Require().Equal(name, file.Name())
+	s.Require().True(file.IsDir())
+}
+
+// Paths that no file system can represent should be rejected consistently by every operation.
+func (s *MemoryTestSuite) TestInvalidPaths() {
+	fs := s.fs
+	invalid := "bad\x00.lebowski"
+
+	_, err := fs.Stat(invalid)
+	s.Require().Error(err)
+	s.Require().False(fs.Exists(invalid))
+	_, err = fs.Read(invalid)
+	s.Require().Error(err)
+	_, err = fs.Write(invalid)
+	s.Require().Error(err)
+	_, err = fs.List(invalid)
+	s.Require().Error(err)
+	s.Require().Error(fs.Remove(invalid))
+	s.Require().Error(fs.Move(invalid, "1.lebowski"))
+	s.Require().Error(fs.Move("1.lebowski", invalid))
+	s.Require().False(fs.ChangeDirectory(invalid).Exists("."))
+}
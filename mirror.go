@@ -0,0 +1,282 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// mirrorOptions contains the settings that only apply to Mirror().
+type mirrorOptions struct {
+	fresh     func(primary FileInfo, replica FileInfo) bool
+	tolerance time.Duration
+}
+
+// WithMirrorFreshness determines whether a Mirror() replica's copy of a file is up-to-date enough to read
+// instead of the primary's. By default, the replica's copy must be the same size as the primary's and must
+// not be older than it.
+//
+// Example:
+//
+//	// Both stores are buckets that report the ETag of the contents.
+//	sameETag := func(primary filestore.FileInfo, replica filestore.FileInfo) bool {
+//	    return filestore.ETag(primary) == filestore.ETag(replica)
+//	}
+//	files := filestore.Mirror(primary, []filestore.FS{replica}, filestore.WithMirrorFreshness(sameETag))
+func WithMirrorFreshness(fn func(primary FileInfo, replica FileInfo) bool) Option {
+	return func(opts *options) {
+		if fn != nil {
+			opts.mirror.fresh = fn
+		}
+	}
+}
+
+// WithModTimeTolerance lets the default Mirror() freshness check treat modification times that are within
+// the tolerance of each other as the same, since stores don't all keep timestamps w/ the same precision
+// (FAT only has 2-second granularity, SMB shares and S3 round to the second, etc.). Otherwise, a replica on
+// a coarser store can look slightly older than the primary and never be read from. When the times are
+// within the tolerance and both stores report an ETag, we compare those instead so that a genuinely
+// different file doesn't slip through. It has no effect when you supply WithMirrorFreshness().
+//
+// Example:
+//
+//	files := filestore.Mirror(primary, []filestore.FS{usbDrive}, filestore.WithModTimeTolerance(2*time.Second))
+func WithModTimeTolerance(tolerance time.Duration) Option {
+	return func(opts *options) {
+		if tolerance >= 0 {
+			opts.mirror.tolerance = tolerance
+		}
+	}
+}
+
+// Mirror serves reads from read-only replicas of a primary store when they have an up-to-date copy of the
+// file, e.g. a same-region bucket or a local disk mirror of a remote share. Replicas are tried in the order
+// you give them, so put the nearest/cheapest ones first. Before reading a file from a replica, we check the
+// primary's metadata to make sure that the replica's copy is fresh (see WithMirrorFreshness); otherwise, the
+// file is read from the primary. Everything else, including all changes, goes to the primary. Keeping the
+// replicas in sync is up to you (e.g. Replicate or your cloud provider's replication).
+//
+// When the primary is down (i.e. the store itself fails, not just the request: a missing file, listing a
+// file, or a cancelled context doesn't count), reads, stats, and listings are served by the first replica
+// that can, w/o checking for freshness. Writes, moves, and removals still fail until an
+// operator promotes one of the replicas using Promote(). The promoted replica then handles everything, both
+// reads and writes, until you call Restore() to go back to the primary. Changes made while a replica was
+// promoted are not copied back to the primary for you. Every store derived from the mirror via
+// ChangeDirectory() or ForRequest() shares the same promotion.
+//
+// Example:
+//
+//	files := filestore.Mirror(filestore.Disk("/mnt/nfs/assets"), []filestore.FS{filestore.Disk("/var/mirror/assets")})
+//
+//	// Later, during an outage...
+//	err := files.Promote(0)
+func Mirror(primary FS, replicas []FS, opts ...Option) *MirrorFS {
+	options := newOptions(opts)
+	fresh := options.mirror.fresh
+	if fresh == nil {
+		fresh = mirrorFresh(options.mirror.tolerance)
+	}
+	return &MirrorFS{
+		primary:  primary,
+		replicas: replicas,
+		fresh:    fresh,
+		state:    &mirrorState{promoted: -1},
+	}
+}
+
+// MirrorFS is a file store that reads from replicas of the primary store when it can; see Mirror().
+type MirrorFS struct {
+	primary  FS
+	replicas []FS
+	fresh    func(primary FileInfo, replica FileInfo) bool
+	state    *mirrorState
+}
+
+// mirrorState tracks which replica (if any) has been promoted, shared by the original mirror and any
+// instances derived from it.
+type mirrorState struct {
+	mutex sync.RWMutex
+	// promoted is the index of the promoted replica, or -1 when the primary is in charge.
+	promoted int
+}
+
+// Promote makes the replica at the given index (in the order you passed them to Mirror) handle every
+// operation, including writes, until you call Restore().
+func (m *MirrorFS) Promote(replica int) error {
+	if replica < 0 || replica >= len(m.replicas) {
+		return fmt.Errorf("filestore: mirror: promote: no replica %d", replica)
+	}
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.promoted = replica
+	return nil
+}
+
+// Restore puts the primary store back in charge after a replica was promoted.
+func (m *MirrorFS) Restore() {
+	m.state.mutex.Lock()
+	defer m.state.mutex.Unlock()
+	m.state.promoted = -1
+}
+
+// Promoted returns the index of the replica that was promoted, or -1 when the primary store is in charge.
+func (m *MirrorFS) Promoted() int {
+	m.state.mutex.RLock()
+	defer m.state.mutex.RUnlock()
+	return m.state.promoted
+}
+
+// active returns the store that's in charge (the primary or the promoted replica) and whether it's the
+// primary.
+func (m *MirrorFS) active() (FS, bool) {
+	if promoted := m.Promoted(); promoted >= 0 {
+		return m.replicas[promoted], false
+	}
+	return m.primary, true
+}
+
+// WorkingDirectory returns the working directory of the store that's in charge.
+func (m *MirrorFS) WorkingDirectory() string {
+	active, _ := m.active()
+	return active.WorkingDirectory()
+}
+
+// Stat fetches metadata about the file from the store that's in charge, falling back to the replicas if
+// the primary is down.
+func (m *MirrorFS) Stat(filePath string) (FileInfo, error) {
+	active, isPrimary := m.active()
+	info, err := active.Stat(filePath)
+	if !isPrimary || !backendUnavailable(err) {
+		return info, err
+	}
+	for _, replica := range m.replicas {
+		if info, replicaErr := replica.Stat(filePath); replicaErr == nil {
+			return info, nil
+		}
+	}
+	return nil, err
+}
+
+// Exists returns true when the file/directory exists in the store that's in charge (or a replica if the
+// primary is down).
+func (m *MirrorFS) Exists(filePath string) bool {
+	_, err := m.Stat(filePath)
+	return err == nil
+}
+
+// List reads the directory from the store that's in charge, falling back to the replicas if the primary
+// is down.
+func (m *MirrorFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	active, isPrimary := m.active()
+	infos, err := active.List(dirPath, filters...)
+	if !isPrimary || !backendUnavailable(err) {
+		return infos, err
+	}
+	for _, replica := range m.replicas {
+		if infos, replicaErr := replica.List(dirPath, filters...); replicaErr == nil {
+			return infos, nil
+		}
+	}
+	return nil, err
+}
+
+// Read opens the file in the first replica that has a fresh copy of it, or in the primary if none of them
+// do. Once a replica has been promoted, the file is always read from it.
+func (m *MirrorFS) Read(filePath string) (ReaderFile, error) {
+	if active, isPrimary := m.active(); !isPrimary {
+		return active.Read(filePath)
+	}
+
+	info, err := m.primary.Stat(filePath)
+	switch {
+	case backendUnavailable(err):
+		for _, replica := range m.replicas {
+			if file, replicaErr := replica.Read(filePath); replicaErr == nil {
+				return file, nil
+			}
+		}
+		return nil, err
+	case err != nil:
+		return nil, err
+	}
+
+	for _, replica := range m.replicas {
+		replicaInfo, err := replica.Stat(filePath)
+		if err != nil || replicaInfo.IsDir() || !m.fresh(info, replicaInfo) {
+			continue
+		}
+		if file, err := replica.Read(filePath); err == nil {
+			return file, nil
+		}
+	}
+	return m.primary.Read(filePath)
+}
+
+// Write opens the file for writing in the store that's in charge.
+func (m *MirrorFS) Write(filePath string) (WriterFile, error) {
+	active, _ := m.active()
+	return active.Write(filePath)
+}
+
+// Move relocates the file/directory in the store that's in charge.
+func (m *MirrorFS) Move(fromPath string, toPath string) error {
+	active, _ := m.active()
+	return active.Move(fromPath, toPath)
+}
+
+// Remove deletes the file/directory from the store that's in charge.
+func (m *MirrorFS) Remove(fileOrDirPath string) error {
+	active, _ := m.active()
+	return active.Remove(fileOrDirPath)
+}
+
+// ChangeDirectory creates a new mirror of the same stores rooted in the given subdirectory that shares the
+// same promotion.
+func (m *MirrorFS) ChangeDirectory(dir string) FS {
+	replicas := make([]FS, len(m.replicas))
+	for i, replica := range m.replicas {
+		replicas[i] = replica.ChangeDirectory(dir)
+	}
+	return &MirrorFS{primary: m.primary.ChangeDirectory(dir), replicas: replicas, fresh: m.fresh, state: m.state}
+}
+
+func (m *MirrorFS) withContext(ctx context.Context) FS {
+	replicas := make([]FS, len(m.replicas))
+	for i, replica := range m.replicas {
+		replicas[i] = ForRequest(replica, ctx)
+	}
+	return &MirrorFS{primary: ForRequest(m.primary, ctx), replicas: replicas, fresh: m.fresh, state: m.state}
+}
+
+func (m *MirrorFS) requestContext() context.Context {
+	return RequestContext(m.primary)
+}
+
+func (m *MirrorFS) wrapped() []FS {
+	return append([]FS{m.primary}, m.replicas...)
+}
+
+// mirrorFresh creates the default freshness check for Mirror(): the replica's copy must be the same size as
+// the primary's and must not be older than it. Times within the tolerance of each other count as the same,
+// in which case matching ETags (when both stores have them) are the tie-breaker.
+func mirrorFresh(tolerance time.Duration) func(primary FileInfo, replica FileInfo) bool {
+	return func(primary FileInfo, replica FileInfo) bool {
+		if replica.Size() != primary.Size() {
+			return false
+		}
+		skew := replica.ModTime().Sub(primary.ModTime())
+		if skew > tolerance {
+			return true
+		}
+		if skew < -tolerance {
+			return false
+		}
+		primaryETag, replicaETag := ETag(primary), ETag(replica)
+		return primaryETag == "" || replicaETag == "" || primaryETag == replicaETag
+	}
+}
+
+var _ FS = &MirrorFS{}
+var _ requestBinder = &MirrorFS{}
+var _ wrapper = &MirrorFS{}
@@ -0,0 +1,136 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type MirrorTestSuite struct {
+	suite.Suite
+	clock   *filestoretest.Clock
+	primary *unavailableFS
+	near    filestore.FS
+	far     filestore.FS
+	fs      *filestore.MirrorFS
+}
+
+func TestMirrorTestSuite(t *testing.T) {
+	suite.Run(t, &MirrorTestSuite{})
+}
+
+func (s *MirrorTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.primary = &unavailableFS{FS: filestore.Memory(filestore.WithClock(s.clock))}
+	s.near = filestore.Memory(filestore.WithClock(s.clock))
+	s.far = filestore.Memory(filestore.WithClock(s.clock))
+	s.fs = filestore.Mirror(s.primary, []filestore.FS{s.near, s.far})
+
+	s.Require().NoError(writeString(s.primary.FS, "assets/logo.png", "logo"))
+	s.Require().NoError(writeString(s.primary.FS, "assets/app.css", "body"))
+	s.clock.Advance(time.Minute)
+	s.Require().NoError(writeString(s.near, "assets/logo.png", "LOGO"))
+	s.Require().NoError(writeString(s.far, "assets/logo.png", "L0GO"))
+	s.Require().NoError(writeString(s.far, "assets/app.css", "BODY"))
+}
+
+func (s *MirrorTestSuite) TestRead() {
+	s.assertContent(s.fs, "assets/logo.png", "LOGO")
+	s.assertContent(s.fs.ChangeDirectory("assets"), "app.css", "BODY", "Should fall through to the next replica")
+
+	// The primary's copy is newer now, so the replicas' copies are stale.
+	s.clock.Advance(time.Minute)
+	s.Require().NoError(writeString(s.fs, "assets/logo.png", "logo"))
+	s.assertContent(s.fs, "assets/logo.png", "logo")
+	s.assertContent(s.near, "assets/logo.png", "LOGO", "Writes should only go to the primary")
+
+	_, err := s.fs.Read("assets/missing.png")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+}
+
+func (s *MirrorTestSuite) TestRead_freshness() {
+	never := func(filestore.FileInfo, filestore.FileInfo) bool { return false }
+	mirror := filestore.Mirror(s.primary, []filestore.FS{s.near, s.far}, filestore.WithMirrorFreshness(never))
+	s.assertContent(mirror, "assets/logo.png", "logo")
+}
+
+func (s *MirrorTestSuite) TestRead_modTimeTolerance() {
+	// The replica rounded the timestamp down, like FAT does, so its copy looks a second older than the primary's.
+	s.clock.Advance(time.Second)
+	s.Require().NoError(writeString(s.primary.FS, "assets/logo.png", "logo"))
+	s.assertContent(s.fs, "assets/logo.png", "logo")
+
+	mirror := filestore.Mirror(s.primary, []filestore.FS{s.near, s.far}, filestore.WithModTimeTolerance(2*time.Second))
+	s.assertContent(mirror, "assets/logo.png", "LOGO")
+
+	// Beyond the tolerance, the replica's copy is stale again.
+	s.clock.Advance(5 * time.Second)
+	s.Require().NoError(writeString(s.primary.FS, "assets/logo.png", "logo"))
+	s.assertContent(mirror, "assets/logo.png", "logo")
+}
+
+func (s *MirrorTestSuite) TestPrimaryDown() {
+	s.primary.down = true
+	s.assertContent(s.fs, "assets/logo.png", "LOGO")
+	s.Require().True(s.fs.Exists("assets/app.css"))
+	infos, err := s.fs.List("assets")
+	s.Require().NoError(err)
+	s.Require().Len(infos, 1, "Should list the first replica that works")
+
+	s.Require().Error(writeString(s.fs, "assets/new.png", "new"), "Should not write to replicas until promoted")
+	s.Require().False(s.near.Exists("assets/new.png"))
+}
+
+// Mistakes in the request would fail the same way on any store, so they shouldn't fall through to the
+// (possibly stale) replicas.
+func (s *MirrorTestSuite) TestPrimaryDown_requestErrors() {
+	_, err := s.fs.List("assets/logo.png")
+	s.Require().ErrorIs(err, filestore.ErrNotDirectory)
+
+	s.primary.failWith = context.Canceled
+	_, err = s.fs.Read("assets/logo.png")
+	s.Require().ErrorIs(err, context.Canceled)
+	_, err = s.fs.Stat("assets/logo.png")
+	s.Require().ErrorIs(err, context.Canceled)
+
+	s.primary.failWith = fs.ErrPermission
+	_, err = s.fs.List("assets")
+	s.Require().ErrorIs(err, fs.ErrPermission)
+}
+
+func (s *MirrorTestSuite) TestPromote() {
+	s.Require().Equal(-1, s.fs.Promoted())
+	s.Require().Error(s.fs.Promote(2))
+	s.Require().Error(s.fs.Promote(-1))
+
+	s.primary.down = true
+	s.Require().NoError(s.fs.Promote(1))
+	s.Require().Equal(1, s.fs.Promoted())
+
+	derived := filestore.ForRequest(s.fs.ChangeDirectory("assets"), context.Background()).(*filestore.MirrorFS)
+	s.Require().Equal(1, derived.Promoted(), "Should share the promotion w/ derived stores")
+	s.Require().NoError(writeString(derived, "new.png", "new"))
+	s.assertContent(s.far, "assets/new.png", "new")
+	s.assertContent(s.fs, "assets/logo.png", "L0GO", "Should read from the promoted replica")
+	s.Require().NoError(s.fs.Remove("assets/app.css"))
+	s.Require().False(s.far.Exists("assets/app.css"))
+
+	s.primary.down = false
+	derived.Restore()
+	s.Require().Equal(-1, s.fs.Promoted())
+	s.Require().NoError(writeString(s.fs, "assets/newer.png", "newer"))
+	s.Require().True(s.primary.FS.Exists("assets/newer.png"))
+	s.Require().False(s.far.Exists("assets/newer.png"))
+}
+
+func (s *MirrorTestSuite) assertContent(fs filestore.FS, filePath string, expected string, msgAndArgs ...any) {
+	content, err := readString(fs, filePath)
+	s.Require().NoError(err, msgAndArgs...)
+	s.Require().Equal(expected, content, msgAndArgs...)
+}
@@ -0,0 +1,270 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrInvalidName is the error you can check for (using errors.Is) when a path breaks a NamePolicy.
+var ErrInvalidName = errors.New("filestore: invalid name")
+
+// NameError is the error returned when a path breaks a NamePolicy.
+type NameError struct {
+	// Path is the path that you tried to write/move to.
+	Path string
+	// Name is the offending file/directory name within Path (or Path itself when it's too long).
+	Name string
+	// Reason describes which rule the name breaks (e.g. "reserved name").
+	Reason string
+}
+
+func (e *NameError) Error() string {
+	if e.Name == e.Path {
+		return fmt.Sprintf("filestore: invalid name %q: %s", e.Name, e.Reason)
+	}
+	return fmt.Sprintf("filestore: invalid name %q in %q: %s", e.Name, e.Path, e.Reason)
+}
+
+func (e *NameError) Unwrap() error {
+	return ErrInvalidName
+}
+
+// NamePolicy describes which file/directory names are acceptable, so you can find out that a name won't
+// work on your target file system (NTFS, S3, ext4, etc.) when you write it rather than in production.
+// The zero value accepts everything; each field you set adds another rule.
+type NamePolicy struct {
+	// MaxNameLength is the longest that each file/directory name in a path may be, in bytes.
+	MaxNameLength int
+	// MaxPathLength is the longest that an entire path (relative to the root of the store) may be, in bytes.
+	MaxPathLength int
+	// AllowedChars reports whether names may contain the given character. When nil, all characters are allowed.
+	AllowedChars func(ch rune) bool
+	// ReservedNames are names that you can't use at all, regardless of case. Like Windows, a name is also
+	// reserved when the part before its extension is (e.g. "con.txt" when "CON" is reserved).
+	ReservedNames []string
+	// Check lets you add your own rules. Return a non-nil error describing what's wrong w/ the name.
+	Check func(name string) error
+}
+
+// NTFSNamePolicy matches the rules for file names on Windows (NTFS): at most 255 UTF-16 characters, none
+// of the characters <>:"/\|?* or control characters, no device names like CON or LPT1, and no names
+// that end w/ a space or a period.
+func NTFSNamePolicy() NamePolicy {
+	reserved := []string{"CON", "PRN", "AUX", "NUL"}
+	for i := 1; i <= 9; i++ {
+		reserved = append(reserved, fmt.Sprintf("COM%d", i), fmt.Sprintf("LPT%d", i))
+	}
+	return NamePolicy{
+		AllowedChars: func(ch rune) bool {
+			return ch >= 32 && !strings.ContainsRune(`<>:"/\|?*`, ch)
+		},
+		ReservedNames: reserved,
+		Check: func(name string) error {
+			switch {
+			case len(utf16.Encode([]rune(name))) > 255:
+				return errors.New("longer than 255 characters")
+			case strings.HasSuffix(name, " ") || strings.HasSuffix(name, "."):
+				return errors.New("ends w/ a space or period")
+			default:
+				return nil
+			}
+		},
+	}
+}
+
+// S3NamePolicy matches the limits of S3 object keys: the whole path can be at most 1024 bytes, and names
+// can't contain control characters or the characters that AWS recommends you avoid in keys (\{}^%`[]"<>~#|).
+func S3NamePolicy() NamePolicy {
+	return NamePolicy{
+		MaxPathLength: 1024,
+		AllowedChars: func(ch rune) bool {
+			return ch >= 32 && ch != 127 && !strings.ContainsRune("\\{}^%`[]\"<>~#|", ch)
+		},
+	}
+}
+
+// Ext4NamePolicy matches the limits of file names on ext4 (and most other Linux file systems): at most
+// 255 bytes each. Slashes and NUL bytes are the only characters that aren't allowed, and you can't put
+// either in a name anyway.
+func Ext4NamePolicy() NamePolicy {
+	return NamePolicy{MaxNameLength: 255}
+}
+
+// Validate checks every file/directory name in the path against the policy, failing w/ a *NameError
+// for the first one that breaks a rule. Use this to reject a user-supplied name up front. The path
+// should be relative to the root of the store, since every directory in it counts.
+//
+// Example:
+//
+//	if err := filestore.NTFSNamePolicy().Validate(req.FormValue("name")); err != nil {
+//	    http.Error(w, err.Error(), http.StatusBadRequest)
+//	}
+func (policy NamePolicy) Validate(filePath string) error {
+	cleanPath := strings.TrimPrefix(path.Clean("/"+filePath), "/")
+	if policy.MaxPathLength > 0 && len(cleanPath) > policy.MaxPathLength {
+		reason := fmt.Sprintf("longer than %d bytes", policy.MaxPathLength)
+		return &NameError{Path: filePath, Name: filePath, Reason: reason}
+	}
+	if cleanPath == "" {
+		return nil
+	}
+	for _, name := range strings.Split(cleanPath, "/") {
+		if reason := policy.check(name); reason != "" {
+			return &NameError{Path: filePath, Name: name, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// check returns the reason that the single file/directory name breaks the policy, if any.
+func (policy NamePolicy) check(name string) string {
+	if policy.MaxNameLength > 0 && len(name) > policy.MaxNameLength {
+		return fmt.Sprintf("longer than %d bytes", policy.MaxNameLength)
+	}
+	if !utf8.ValidString(name) {
+		return "not valid UTF-8"
+	}
+	if policy.AllowedChars != nil {
+		for _, ch := range name {
+			if !policy.AllowedChars(ch) {
+				return fmt.Sprintf("character %q is not allowed", ch)
+			}
+		}
+	}
+	stem := name
+	if dot := strings.IndexByte(name, '.'); dot > 0 {
+		stem = name[:dot]
+	}
+	for _, reserved := range policy.ReservedNames {
+		if strings.EqualFold(name, reserved) || strings.EqualFold(stem, reserved) {
+			return "reserved name"
+		}
+	}
+	if policy.Check != nil {
+		if err := policy.Check(name); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// EnforceNames wraps a file store so that writing or moving anything to a path that breaks the policy
+// fails w/ a *NameError instead. Every directory name in the path counts (relative to the store's working
+// directory when you wrapped it), since writing a file creates any directories that don't exist yet.
+// Reading, listing, and removing are unaffected, so you can still clean up files w/ bad names that were
+// created some other way.
+//
+// Example:
+//
+//	// Everything we store here gets synced to Windows desktops eventually.
+//	shared := filestore.EnforceNames(filestore.Disk("/srv/shared"), filestore.NTFSNamePolicy())
+//	_, err := shared.Write("reports/Q3: final.xlsx") // fails w/ filestore.ErrInvalidName
+func EnforceNames(fs FS, policy NamePolicy) FS {
+	return &namePolicyFS{FS: fs, policy: policy, root: fs.WorkingDirectory()}
+}
+
+func init() {
+	RegisterLayer("name_policy", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Preset        string   `json:"preset"`
+			MaxNameLength int      `json:"maxNameLength"`
+			MaxPathLength int      `json:"maxPathLength"`
+			ReservedNames []string `json:"reservedNames"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+
+		var policy NamePolicy
+		switch strings.ToLower(settings.Preset) {
+		case "":
+		case "ntfs":
+			policy = NTFSNamePolicy()
+		case "s3":
+			policy = S3NamePolicy()
+		case "ext4":
+			policy = Ext4NamePolicy()
+		default:
+			return nil, fmt.Errorf("name_policy: unknown preset: %s", settings.Preset)
+		}
+		if settings.MaxNameLength > 0 {
+			policy.MaxNameLength = settings.MaxNameLength
+		}
+		if settings.MaxPathLength > 0 {
+			policy.MaxPathLength = settings.MaxPathLength
+		}
+		policy.ReservedNames = append(policy.ReservedNames, settings.ReservedNames...)
+		return EnforceNames(fs, policy), nil
+	})
+}
+
+type namePolicyFS struct {
+	FS
+	policy NamePolicy
+	// root is the working directory of the store when we wrapped it; paths are validated relative to it.
+	root string
+}
+
+// check validates the path relative to the root of the wrapped store.
+func (n *namePolicyFS) check(filePath string) error {
+	fullPath := joinPath(n.FS.WorkingDirectory(), filePath)
+	relativePath := fullPath
+	switch {
+	case fullPath == n.root:
+		relativePath = "."
+	case n.root == "/" || n.root == ".":
+	case strings.HasPrefix(fullPath, n.root+"/"):
+		relativePath = fullPath[len(n.root)+1:]
+	}
+
+	// Report the path the way that the caller gave it to us.
+	err := n.policy.Validate(relativePath)
+	var nameErr *NameError
+	if errors.As(err, &nameErr) {
+		if nameErr.Name == nameErr.Path {
+			nameErr.Name = filePath
+		}
+		nameErr.Path = filePath
+	}
+	return err
+}
+
+// Write opens the file for writing unless its path breaks the policy.
+func (n *namePolicyFS) Write(filePath string) (WriterFile, error) {
+	if err := n.check(filePath); err != nil {
+		return nil, err
+	}
+	return n.FS.Write(filePath)
+}
+
+// Move relocates the file/directory unless its new path breaks the policy.
+func (n *namePolicyFS) Move(fromPath string, toPath string) error {
+	if err := n.check(toPath); err != nil {
+		return err
+	}
+	return n.FS.Move(fromPath, toPath)
+}
+
+func (n *namePolicyFS) ChangeDirectory(dir string) FS {
+	return &namePolicyFS{FS: n.FS.ChangeDirectory(dir), policy: n.policy, root: n.root}
+}
+
+func (n *namePolicyFS) withContext(ctx context.Context) FS {
+	return &namePolicyFS{FS: ForRequest(n.FS, ctx), policy: n.policy, root: n.root}
+}
+
+func (n *namePolicyFS) requestContext() context.Context {
+	return RequestContext(n.FS)
+}
+
+func (n *namePolicyFS) wrapped() []FS {
+	return []FS{n.FS}
+}
+
+var _ requestBinder = &namePolicyFS{}
+var _ wrapper = &namePolicyFS{}
@@ -0,0 +1,139 @@
+package filestore_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type NamePolicyTestSuite struct {
+	suite.Suite
+}
+
+func TestNamePolicyTestSuite(t *testing.T) {
+	suite.Run(t, &NamePolicyTestSuite{})
+}
+
+func (s *NamePolicyTestSuite) TestValidate_ntfs() {
+	policy := filestore.NTFSNamePolicy()
+	s.Require().NoError(policy.Validate("reports/2022/Q3 final.xlsx"))
+	s.Require().NoError(policy.Validate("reports/console.txt"), "Only exact device names are reserved")
+
+	s.assertInvalid(policy, "reports/Q3: final.xlsx", "Q3: final.xlsx", "character ':' is not allowed")
+	s.assertInvalid(policy, "reports/what?/a.txt", "what?", "character '?' is not allowed")
+	s.assertInvalid(policy, "con", "con", "reserved name")
+	s.assertInvalid(policy, "devices/LPT1.txt", "LPT1.txt", "reserved name")
+	s.assertInvalid(policy, "reports./a.txt", "reports.", "ends w/ a space or period")
+	s.assertInvalid(policy, "a\tb.txt", "a\tb.txt", "character '\\t' is not allowed")
+	s.assertInvalid(policy, strings.Repeat("é", 256), strings.Repeat("é", 256), "longer than 255 characters")
+	s.Require().NoError(policy.Validate(strings.Repeat("é", 255)), "NTFS counts characters, not bytes")
+}
+
+func (s *NamePolicyTestSuite) TestValidate_s3() {
+	policy := filestore.S3NamePolicy()
+	s.Require().NoError(policy.Validate("uploads/Q3: final (1).xlsx"))
+	s.Require().NoError(policy.Validate(strings.Repeat("a/", 511) + "b"))
+
+	s.assertInvalid(policy, "uploads/50%.txt", "50%.txt", "character '%' is not allowed")
+	s.assertInvalid(policy, "uploads/a\\b.txt", "a\\b.txt", "character '\\\\' is not allowed")
+
+	long := strings.Repeat("a/", 512) + "b"
+	s.assertInvalid(policy, long, long, "longer than 1024 bytes")
+}
+
+func (s *NamePolicyTestSuite) TestValidate_ext4() {
+	policy := filestore.Ext4NamePolicy()
+	s.Require().NoError(policy.Validate("Q3: final?.xlsx"))
+	s.Require().NoError(policy.Validate(strings.Repeat("a", 255)))
+	s.assertInvalid(policy, strings.Repeat("é", 128), strings.Repeat("é", 128), "longer than 255 bytes")
+	s.assertInvalid(policy, "bad/\xff.txt", "\xff.txt", "not valid UTF-8")
+}
+
+func (s *NamePolicyTestSuite) TestValidate_custom() {
+	policy := filestore.NamePolicy{
+		ReservedNames: []string{"tmp"},
+		Check: func(name string) error {
+			if strings.HasPrefix(name, "-") {
+				return errors.New("starts w/ a dash")
+			}
+			return nil
+		},
+	}
+	s.Require().NoError(policy.Validate("a/b/c.txt"))
+	s.Require().NoError(policy.Validate("."))
+	s.assertInvalid(policy, "TMP/a.txt", "TMP", "reserved name")
+	s.assertInvalid(policy, "a/-rf", "-rf", "starts w/ a dash")
+	s.Require().NoError(filestore.NamePolicy{}.Validate("anything: goes?"), "Zero value should allow everything")
+}
+
+func (s *NamePolicyTestSuite) TestEnforceNames() {
+	inner := filestore.Memory()
+	s.Require().NoError(writeString(inner, "legacy/CON.txt", "abide"))
+	fs := filestore.EnforceNames(inner.ChangeDirectory("shared"), filestore.NTFSNamePolicy())
+
+	s.Require().NoError(writeString(fs, "reports/a.txt", "abide"))
+	s.Require().Equal("abide", s.read(inner, "shared/reports/a.txt"))
+
+	err := writeString(fs, "reports/Q3: final.xlsx", "abide")
+	s.Require().ErrorIs(err, filestore.ErrInvalidName)
+	var nameErr *filestore.NameError
+	s.Require().True(errors.As(err, &nameErr))
+	s.Require().Equal("reports/Q3: final.xlsx", nameErr.Path)
+	s.Require().Equal("Q3: final.xlsx", nameErr.Name)
+	s.Require().False(inner.Exists("shared/reports/Q3: final.xlsx"))
+
+	s.Require().ErrorIs(fs.Move("reports/a.txt", "reports/aux.txt"), filestore.ErrInvalidName)
+	s.Require().True(inner.Exists("shared/reports/a.txt"), "Failed moves shouldn't touch the file")
+	s.Require().NoError(fs.Move("reports/a.txt", "reports/b.txt"))
+
+	// Paths are checked relative to where we wrapped the store, even after you cd.
+	reports := fs.ChangeDirectory("reports")
+	s.Require().ErrorIs(writeString(reports, "nul", "abide"), filestore.ErrInvalidName)
+	s.Require().NoError(writeString(reports, "c.txt", "abide"))
+	s.Require().Equal("abide", s.read(inner, "shared/reports/c.txt"))
+
+	// Files w/ bad names that already exist can still be read and removed.
+	legacy := filestore.EnforceNames(inner, filestore.NTFSNamePolicy())
+	s.Require().Equal("abide", s.read(legacy, "legacy/CON.txt"))
+	s.Require().NoError(legacy.Remove("legacy/CON.txt"))
+}
+
+func (s *NamePolicyTestSuite) TestFromConfig() {
+	fs, err := filestore.FromConfig(filestore.Config{
+		URL: "mem://",
+		Layers: []filestore.LayerConfig{{Type: "name_policy", Options: filestore.LayerOptions{
+			"preset":        "s3",
+			"reservedNames": []string{"tmp"},
+		}}},
+	})
+	s.Require().NoError(err)
+	s.Require().ErrorIs(writeString(fs, "a%b.txt", "abide"), filestore.ErrInvalidName)
+	s.Require().ErrorIs(writeString(fs, "tmp/a.txt", "abide"), filestore.ErrInvalidName)
+	s.Require().NoError(writeString(fs, "a.txt", "abide"))
+
+	_, err = filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "name_policy", Options: filestore.LayerOptions{"preset": "fat32"}}},
+	})
+	s.Require().Error(err)
+}
+
+func (s *NamePolicyTestSuite) assertInvalid(policy filestore.NamePolicy, filePath string, name string, reason string) {
+	err := policy.Validate(filePath)
+	s.Require().ErrorIs(err, filestore.ErrInvalidName, filePath)
+
+	var nameErr *filestore.NameError
+	s.Require().True(errors.As(err, &nameErr))
+	s.Require().Equal(filePath, nameErr.Path)
+	s.Require().Equal(name, nameErr.Name)
+	s.Require().Equal(reason, nameErr.Reason)
+}
+
+func (s *NamePolicyTestSuite) read(fs filestore.FS, name string) string {
+	content, err := readString(fs, name)
+	s.Require().NoError(err)
+	return content
+}
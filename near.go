@@ -0,0 +1,242 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// WithNearMatches makes Stat() and Exists() look for files whose paths only differ from the one you
+// asked for by case or Unicode normalization (e.g. "photo.JPG" vs "photo.jpg", or an "é" typed as a
+// single character vs "e" plus a combining accent) when the exact path doesn't exist. If there are
+// any, they fail w/ a *NearMatchError so you can ask the user "did you mean...?"
+func WithNearMatches() Option {
+	return func(opts *options) {
+		opts.nearMatches = true
+	}
+}
+
+// NearMatchError is the error returned when the path you asked for doesn't exist, but one or more paths
+// similar to it do. Stat() and Exists() (w/ the WithNearMatches() option) return it for paths that differ
+// only by case or Unicode normalization, and ResolveClosest() returns it for fuzzier matches. It wraps the
+// original error, so errors.Is(err, fs.ErrNotExist) is still true.
+type NearMatchError struct {
+	// Path is the path that you asked for.
+	Path string
+	// Matches are the paths of the files/directories that nearly match Path, best match first.
+	Matches []string
+	// Err is the store's original "not found" error.
+	Err error
+}
+
+func (e *NearMatchError) Error() string {
+	return fmt.Sprintf("filestore: %s: not found (did you mean %s?)", e.Path, strings.Join(e.Matches, " or "))
+}
+
+func (e *NearMatchError) Unwrap() error {
+	return e.Err
+}
+
+// Stat describes the file/directory just like fsys.Stat(). If you supply the WithNearMatches() option
+// and the path doesn't exist, it fails w/ a *NearMatchError when there are files whose paths differ only
+// by case or Unicode normalization.
+//
+// Example:
+//
+//	info, err := filestore.Stat(uploads, "Photo.jpg", filestore.WithNearMatches())
+//	var nearMatch *filestore.NearMatchError
+//	if errors.As(err, &nearMatch) {
+//	    fmt.Printf("Did you mean %s?\n", nearMatch.Matches[0])
+//	}
+func Stat(fsys FS, filePath string, opts ...Option) (FileInfo, error) {
+	info, err := fsys.Stat(filePath)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) || !newOptions(opts).nearMatches {
+		return info, err
+	}
+	if matches := nearMatches(fsys, filePath); len(matches) > 0 {
+		return nil, &NearMatchError{Path: filePath, Matches: matches, Err: err}
+	}
+	return nil, err
+}
+
+// Exists returns true when the file/directory exists just like fsys.Exists(). If you supply the
+// WithNearMatches() option and the path doesn't exist, the error is a *NearMatchError when there are
+// files whose paths differ only by case or Unicode normalization. Otherwise, the error is always nil.
+func Exists(fsys FS, filePath string, opts ...Option) (bool, error) {
+	if fsys.Exists(filePath) {
+		return true, nil
+	}
+	if !newOptions(opts).nearMatches {
+		return false, nil
+	}
+	if matches := nearMatches(fsys, filePath); len(matches) > 0 {
+		err := fmt.Errorf("%s: %w", filePath, fs.ErrNotExist)
+		return false, &NearMatchError{Path: filePath, Matches: matches, Err: err}
+	}
+	return false, nil
+}
+
+// maxClosestMatches is the most candidates that ResolveClosest() will suggest.
+const maxClosestMatches = 5
+
+// ResolveClosest helps you explain why a path doesn't exist. If it does exist, you get the path back w/ a
+// nil error. Otherwise, it walks up the path to the deepest ancestor directory that does exist and
+// returns that, along w/ an error describing what's missing. When the ancestor contains entries w/
+// names similar to the first missing segment (differing by case, a typo or two, etc.), the error is a
+// *NearMatchError whose Matches are the best of those candidates; otherwise it's just the store's
+// "not found" error.
+//
+// Example:
+//
+//	// "photos/2022/beach.jpg" exists, but the user typed "photos/2202/beach.jpg"
+//	closest, err := filestore.ResolveClosest(files, "photos/2202/beach.jpg")
+//	// closest is "photos" and err is a *NearMatchError w/ the match "photos/2022"
+func ResolveClosest(fsys FS, filePath string) (string, error) {
+	_, err := fsys.Stat(filePath)
+	if err == nil {
+		return filePath, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	ancestor := path.Clean(filePath)
+	missing := ""
+	for ancestor != "." && ancestor != "/" && !fsys.Exists(ancestor) {
+		missing = path.Base(ancestor)
+		ancestor = path.Dir(ancestor)
+	}
+	if matches := closestMatches(fsys, ancestor, missing); len(matches) > 0 {
+		return ancestor, &NearMatchError{Path: filePath, Matches: matches, Err: err}
+	}
+	return ancestor, err
+}
+
+// closestMatches returns the paths of the entries in the directory whose names are similar to the
+// given name, ordered from the most to the least similar.
+func closestMatches(fsys FS, dir string, name string) []string {
+	if name == "" {
+		return nil
+	}
+	infos, err := fsys.List(dir)
+	if err != nil {
+		return nil
+	}
+
+	target := []rune(foldName(name))
+	maxDistance := len(target) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, info := range infos {
+		if distance := editDistance([]rune(foldName(info.Name())), target); distance <= maxDistance {
+			candidates = append(candidates, candidate{name: info.Name(), distance: distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	if len(candidates) > maxClosestMatches {
+		candidates = candidates[:maxClosestMatches]
+	}
+
+	matches := make([]string, len(candidates))
+	for i, c := range candidates {
+		matches[i] = path.Join(dir, c.name)
+	}
+	return matches
+}
+
+// editDistance is the Damerau-Levenshtein (optimal string alignment) distance between the two names:
+// the number of inserted, removed, replaced, or swapped characters it takes to turn one into the other.
+func editDistance(a []rune, b []rune) int {
+	distances := make([][]int, len(a)+1)
+	for i := range distances {
+		distances[i] = make([]int, len(b)+1)
+		distances[i][0] = i
+	}
+	for j := range distances[0] {
+		distances[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			distances[i][j] = minInt(distances[i-1][j]+1, distances[i][j-1]+1, distances[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				distances[i][j] = minInt(distances[i][j], distances[i-2][j-2]+1)
+			}
+		}
+	}
+	return distances[len(a)][len(b)]
+}
+
+func minInt(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
+
+// nearMatches resolves the path one segment at a time, listing each directory along the way to find
+// the entries that fold to the same name. We follow every near match, so "A/b" finds both "a/B" and
+// "A/B" if they exist.
+func nearMatches(fsys FS, filePath string) []string {
+	cleanPath := strings.TrimPrefix(path.Clean(filePath), "/")
+	if cleanPath == "." || cleanPath == "" {
+		return nil
+	}
+
+	candidates := []string{"."}
+	for _, segment := range strings.Split(cleanPath, "/") {
+		if segment == ".." {
+			return nil
+		}
+		folded := foldName(segment)
+
+		var next []string
+		for _, dir := range candidates {
+			infos, err := fsys.List(dir)
+			if err != nil {
+				continue
+			}
+			for _, info := range infos {
+				if foldName(info.Name()) == folded {
+					next = append(next, path.Join(dir, info.Name()))
+				}
+			}
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		candidates = next
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// foldName normalizes the name so that names that only differ by case or Unicode normalization
+// are equal.
+func foldName(name string) string {
+	return cases.Fold().String(norm.NFC.String(name))
+}
@@ -0,0 +1,125 @@
+package filestore_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type NearMatchTestSuite struct {
+	suite.Suite
+	fs filestore.FS
+}
+
+func TestNearMatchTestSuite(t *testing.T) {
+	suite.Run(t, &NearMatchTestSuite{})
+}
+
+func (s *NearMatchTestSuite) SetupTest() {
+	s.fs = filestore.Memory()
+	s.Require().NoError(writeString(s.fs, "photos/beach.JPG", "sand"))
+	s.Require().NoError(writeString(s.fs, "photos/Beach.jpg", "more sand"))
+	s.Require().NoError(writeString(s.fs, "photos/caf\u00e9.png", "coffee"))
+	s.Require().NoError(writeString(s.fs, "Docs/Readme.md", "abide"))
+}
+
+func (s *NearMatchTestSuite) TestStat() {
+	info, err := filestore.Stat(s.fs, "photos/beach.JPG", filestore.WithNearMatches())
+	s.Require().NoError(err, "Exact matches should behave like a normal Stat()")
+	s.Require().Equal("beach.JPG", info.Name())
+
+	_, err = filestore.Stat(s.fs, "photos/BEACH.jpg", filestore.WithNearMatches())
+	s.assertNearMatches(err, "photos/BEACH.jpg", "photos/Beach.jpg", "photos/beach.JPG")
+
+	_, err = filestore.Stat(s.fs, "docs/README.MD", filestore.WithNearMatches())
+	s.assertNearMatches(err, "docs/README.MD", "Docs/Readme.md")
+
+	// The file was written w/ a precomposed "é", but this is an "e" followed by a combining accent.
+	_, err = filestore.Stat(s.fs, "photos/cafe\u0301.png", filestore.WithNearMatches())
+	s.assertNearMatches(err, "photos/cafe\u0301.png", "photos/caf\u00e9.png")
+
+	_, err = filestore.Stat(s.fs, "photos/nope.jpg", filestore.WithNearMatches())
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+	s.Require().False(errors.As(err, new(*filestore.NearMatchError)), "Should not report near matches when there are none")
+
+	_, err = filestore.Stat(s.fs, "photos/BEACH.jpg")
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+	s.Require().False(errors.As(err, new(*filestore.NearMatchError)), "Should not look for near matches w/o the option")
+}
+
+func (s *NearMatchTestSuite) TestExists() {
+	exists, err := filestore.Exists(s.fs, "Docs/Readme.md", filestore.WithNearMatches())
+	s.Require().NoError(err)
+	s.Require().True(exists)
+
+	exists, err = filestore.Exists(s.fs, "DOCS/readme.md", filestore.WithNearMatches())
+	s.Require().False(exists)
+	s.assertNearMatches(err, "DOCS/readme.md", "Docs/Readme.md")
+
+	exists, err = filestore.Exists(s.fs, "DOCS", filestore.WithNearMatches())
+	s.Require().False(exists)
+	s.assertNearMatches(err, "DOCS", "Docs")
+
+	exists, err = filestore.Exists(s.fs, "docs/nope.md", filestore.WithNearMatches())
+	s.Require().NoError(err)
+	s.Require().False(exists)
+
+	exists, err = filestore.Exists(s.fs, "DOCS/readme.md")
+	s.Require().NoError(err)
+	s.Require().False(exists)
+}
+
+func (s *NearMatchTestSuite) TestChangeDirectory() {
+	photos := s.fs.ChangeDirectory("photos")
+	_, err := filestore.Stat(photos, "CAFE\u0301.PNG", filestore.WithNearMatches())
+	s.assertNearMatches(err, "CAFE\u0301.PNG", "caf\u00e9.png")
+}
+
+func (s *NearMatchTestSuite) assertNearMatches(err error, filePath string, matches ...string) {
+	s.Require().ErrorIs(err, fs.ErrNotExist, "Near matches should still be 'not exist' errors")
+	var nearMatch *filestore.NearMatchError
+	s.Require().True(errors.As(err, &nearMatch), "Should be a *NearMatchError: %v", err)
+	s.Require().Equal(filePath, nearMatch.Path)
+	s.Require().Equal(matches, nearMatch.Matches)
+	s.Require().Contains(err.Error(), "did you mean "+matches[0])
+}
+
+func (s *NearMatchTestSuite) TestResolveClosest() {
+	s.Require().NoError(writeString(s.fs, "photos/2022/beach.jpg", "sand"))
+	s.Require().NoError(writeString(s.fs, "photos/2021/beach.jpg", "sand"))
+
+	closest, err := filestore.ResolveClosest(s.fs, "photos/2022/beach.jpg")
+	s.Require().NoError(err, "Paths that exist should resolve to themselves")
+	s.Require().Equal("photos/2022/beach.jpg", closest)
+
+	closest, err = filestore.ResolveClosest(s.fs, "photos/2202/beach.jpg")
+	s.Require().Equal("photos", closest)
+	s.assertNearMatches(err, "photos/2202/beach.jpg", "photos/2022")
+
+	closest, err = filestore.ResolveClosest(s.fs, "photos/2022/bech.jpg")
+	s.Require().Equal("photos/2022", closest)
+	s.assertNearMatches(err, "photos/2022/bech.jpg", "photos/2022/beach.jpg")
+
+	closest, err = filestore.ResolveClosest(s.fs, "photo/2022/beach.jpg")
+	s.Require().Equal(".", closest)
+	s.assertNearMatches(err, "photo/2022/beach.jpg", "photos")
+
+	closest, err = filestore.ResolveClosest(s.fs, "photos/videos/clip.mp4")
+	s.Require().Equal("photos", closest)
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+	s.Require().False(errors.As(err, new(*filestore.NearMatchError)), "Should not suggest dissimilar names")
+}
+
+func (s *NearMatchTestSuite) TestResolveClosest_ordering() {
+	s.Require().NoError(writeString(s.fs, "notes/reports.txt", "a"))
+	s.Require().NoError(writeString(s.fs, "notes/raport.txt", "b"))
+	s.Require().NoError(writeString(s.fs, "notes/report.txt", "c"))
+	s.Require().NoError(writeString(s.fs, "notes/unrelated.txt", "d"))
+
+	closest, err := filestore.ResolveClosest(s.fs, "notes/reprt.txt")
+	s.Require().Equal("notes", closest)
+	s.assertNearMatches(err, "notes/reprt.txt", "notes/report.txt", "notes/raport.txt", "notes/reports.txt")
+}
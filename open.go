@@ -0,0 +1,123 @@
+package filestore
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Opener creates a file store based on the details of a connection URL. Backends register an
+// Opener for their URL scheme(s) using RegisterScheme() so that Open() can construct them.
+type Opener func(u *url.URL) (FS, error)
+
+var schemes = struct {
+	mutex   sync.RWMutex
+	openers map[string]Opener
+}{openers: map[string]Opener{}}
+
+// RegisterScheme makes a backend available to Open() for URLs with the given scheme (e.g. "s3"). This is
+// typically called from the init() function of the package that implements the backend. Much like
+// database/sql.Register(), this panics if you register the same scheme twice or supply a nil opener.
+func RegisterScheme(scheme string, opener Opener) {
+	schemes.mutex.Lock()
+	defer schemes.mutex.Unlock()
+
+	scheme = strings.ToLower(scheme)
+	if opener == nil {
+		panic("filestore: register scheme: opener is nil for " + scheme)
+	}
+	if _, exists := schemes.openers[scheme]; exists {
+		panic("filestore: register scheme: called twice for " + scheme)
+	}
+	schemes.openers[scheme] = opener
+}
+
+// Schemes returns the sorted list of URL schemes that Open() currently supports.
+func Schemes() []string {
+	schemes.mutex.RLock()
+	defer schemes.mutex.RUnlock()
+
+	var results []string
+	for scheme := range schemes.openers {
+		results = append(results, scheme)
+	}
+	sort.Strings(results)
+	return results
+}
+
+// Open creates a file store based on a connection URL, allowing applications to configure their storage
+// entirely via a connection string. The URL's scheme determines which backend to use. Anything that
+// doesn't start w/ "scheme://" (or a registered scheme followed by ":", like "file:data") is treated as a
+// path on the local disk as-is, so paths containing characters like '%' or ':' work, too.
+//
+// Example:
+//
+//	files, err := filestore.Open("file:///var/data")  // Disk("/var/data")
+//	files, err := filestore.Open("file:data")         // Disk("data") - relative to the working directory
+//	files, err := filestore.Open("./data")            // Disk("./data")
+//	files, err := filestore.Open("/tmp/100%.d")       // Disk("/tmp/100%.d")
+//	files, err := filestore.Open("mem://")            // Memory()
+//	files, err := filestore.Open("mem:///some/dir")   // Memory().ChangeDirectory("/some/dir")
+func Open(rawURL string) (FS, error) {
+	if !isURL(rawURL) {
+		return Disk(rawURL), nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: open: %w", err)
+	}
+
+	schemes.mutex.RLock()
+	opener, ok := schemes.openers[strings.ToLower(u.Scheme)]
+	schemes.mutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("filestore: open: unsupported scheme: %s", u.Scheme)
+	}
+	fs, err := opener(u)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: open: %w", err)
+	}
+	return fs, nil
+}
+
+// isURL returns true when the input starts w/ "scheme://" or w/ a registered scheme followed by a colon
+// (e.g. "file:data"). Everything else is a plain path that we shouldn't try to unescape.
+func isURL(rawURL string) bool {
+	scheme, rest, ok := strings.Cut(rawURL, ":")
+	if !ok || !isScheme(scheme) {
+		return false
+	}
+	if strings.HasPrefix(rest, "//") {
+		return true
+	}
+
+	schemes.mutex.RLock()
+	defer schemes.mutex.RUnlock()
+	_, registered := schemes.openers[strings.ToLower(scheme)]
+	return registered
+}
+
+// isScheme checks the syntax of a URL scheme per RFC 3986: a letter followed by letters, digits, '+', '-', or '.'.
+func isScheme(scheme string) bool {
+	for i, c := range scheme {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return scheme != ""
+}
+
+// urlPath extracts the path portion of URLs like "file:///var/data" or "file:relative/data". For
+// convenience, it also treats the host of URLs like "file://data" as the first path segment.
+func urlPath(u *url.URL) string {
+	if u.Opaque != "" {
+		return u.Opaque
+	}
+	return u.Host + u.Path
+}
@@ -0,0 +1,94 @@
+package filestore_test
+
+import (
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type OpenTestSuite struct {
+	suite.Suite
+}
+
+func TestOpenTestSuite(t *testing.T) {
+	suite.Run(t, &OpenTestSuite{})
+}
+
+func (s *OpenTestSuite) TestOpen_file() {
+	s.assertDisk("file:///var/data", "/var/data")
+	s.assertDisk("file:testdata", "testdata")
+	s.assertDisk("file:./testdata/inner1", "testdata/inner1")
+	s.assertDisk("file://testdata/inner1", "testdata/inner1")
+	s.assertDisk("FILE:///tmp", "/tmp")
+	s.assertDisk("file://", ".")
+
+	// No scheme means local disk.
+	s.assertDisk("testdata", "testdata")
+	s.assertDisk("./testdata/inner1", "testdata/inner1")
+	s.assertDisk("/var/data", "/var/data")
+	s.assertDisk("/tmp/100%.d", "/tmp/100%.d")
+	s.assertDisk("data/a:b", "data/a:b")
+	s.assertDisk("notes:2022", "notes:2022")
+
+	fs, err := filestore.Open("file:testdata")
+	s.Require().NoError(err)
+	s.Require().True(fs.Exists("hello.txt"))
+}
+
+func (s *OpenTestSuite) TestOpen_memory() {
+	fs, err := filestore.Open("mem://")
+	s.Require().NoError(err)
+	s.Require().IsType(&filestore.MemoryFS{}, fs)
+	s.Require().Equal("/", fs.WorkingDirectory())
+
+	fs, err = filestore.Open("mem:///some/dir")
+	s.Require().NoError(err)
+	s.Require().Equal("/some/dir", fs.WorkingDirectory())
+}
+
+func (s *OpenTestSuite) TestOpen_invalid() {
+	_, err := filestore.Open("nope://foo")
+	s.Require().Error(err, "Unregistered schemes should fail")
+
+	_, err = filestore.Open("file://%zz")
+	s.Require().Error(err, "Malformed URLs should fail")
+}
+
+var openTestURL *url.URL
+var openTestRegister sync.Once
+
+func (s *OpenTestSuite) TestRegisterScheme() {
+	// Registration is global, so make sure that running the tests multiple times doesn't explode.
+	openTestRegister.Do(func() {
+		filestore.RegisterScheme("opentest", func(u *url.URL) (filestore.FS, error) {
+			openTestURL = u
+			return filestore.Memory(), nil
+		})
+	})
+	s.Require().Contains(filestore.Schemes(), "opentest")
+	s.Require().Contains(filestore.Schemes(), "file")
+	s.Require().Contains(filestore.Schemes(), "mem")
+
+	_, err := filestore.Open("opentest://bucket/prefix?region=us-east-1")
+	s.Require().NoError(err)
+	s.Require().Equal("bucket", openTestURL.Host)
+	s.Require().Equal("/prefix", openTestURL.Path)
+	s.Require().Equal("us-east-1", openTestURL.Query().Get("region"))
+
+	s.Require().Panics(func() {
+		filestore.RegisterScheme("opentest", func(u *url.URL) (filestore.FS, error) { return nil, nil })
+	}, "Registering the same scheme twice should panic")
+	s.Require().Panics(func() {
+		filestore.RegisterScheme("opentest2", nil)
+	}, "Registering a nil opener should panic")
+}
+
+func (s *OpenTestSuite) assertDisk(rawURL string, expectedDir string) {
+	fs, err := filestore.Open(rawURL)
+	s.Require().NoError(err, "Opening %s should not fail", rawURL)
+	s.Require().IsType(&filestore.DiskFS{}, fs, "Opening %s should be a disk store", rawURL)
+	s.Require().Equal(expectedDir, fs.WorkingDirectory())
+}
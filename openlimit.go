@@ -0,0 +1,202 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTooManyOpenFiles is the error returned by LimitOpenFiles() stores when all of their slots are in use
+// and you can't (or won't) wait for one to free up.
+var ErrTooManyOpenFiles = errors.New("filestore: too many open files")
+
+// openFileOptions contains the settings that only apply to LimitOpenFiles().
+type openFileOptions struct {
+	timeout time.Duration
+}
+
+// WithOpenFileTimeout limits how long LimitOpenFiles() waits for another file to be closed before failing
+// w/ ErrTooManyOpenFiles. A timeout of 0 fails right away. By default, it waits for as long as it takes
+// (or until the request's context is done; see ForRequest).
+func WithOpenFileTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		if timeout >= 0 {
+			opts.openFiles.timeout = timeout
+		}
+	}
+}
+
+// LimitOpenFiles wraps a file store so that no more than 'limit' files are open through it at the same
+// time. Once the limit is reached, Read() and Write() wait for another file to be closed, so a careless
+// parallel walker slows down rather than hitting the process' file descriptor limit (EMFILE). List() also
+// occupies a slot while it runs since reading a directory needs a file descriptor, too.
+//
+// By default, callers wait indefinitely, unless the store was bound to a request w/ ForRequest(), in which
+// case they give up when its context is done. Use WithOpenFileTimeout() to wait for a limited amount of
+// time (or not at all) and fail w/ ErrTooManyOpenFiles instead. Stores derived from the wrapper via
+// ChangeDirectory() or ForRequest() share the same limit.
+//
+// Example:
+//
+//	// Leave plenty of room under a `ulimit -n` of 1024 for sockets and such.
+//	files := filestore.LimitOpenFiles(filestore.Disk("data"), 512)
+func LimitOpenFiles(fs FS, limit int, opts ...Option) FS {
+	if limit < 1 {
+		limit = 1
+	}
+	options := newOptions(opts)
+	return &openLimitFS{
+		FS:      fs,
+		slots:   make(chan struct{}, limit),
+		timeout: options.openFiles.timeout,
+		clock:   options.clock,
+	}
+}
+
+func init() {
+	RegisterLayer("limit_open_files", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Limit   int    `json:"limit"`
+			Timeout string `json:"timeout"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		if settings.Limit < 1 {
+			return nil, fmt.Errorf("limit open files: limit must be at least 1")
+		}
+		if settings.Timeout == "" {
+			return LimitOpenFiles(fs, settings.Limit), nil
+		}
+		timeout, err := time.ParseDuration(settings.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		return LimitOpenFiles(fs, settings.Limit, WithOpenFileTimeout(timeout)), nil
+	})
+}
+
+type openLimitFS struct {
+	FS
+	// slots is a semaphore w/ one buffered value for every file that's open.
+	slots chan struct{}
+	// timeout is how long to wait for a slot; a negative timeout waits indefinitely.
+	timeout time.Duration
+	clock   Clock
+}
+
+// Read opens the file for reading once there's a free slot. The slot is freed when you close the file.
+func (o *openLimitFS) Read(filePath string) (ReaderFile, error) {
+	if err := o.acquire(); err != nil {
+		return nil, newPathError("open limit", "read", filePath, err)
+	}
+	file, err := o.FS.Read(filePath)
+	if err != nil {
+		o.release()
+		return nil, err
+	}
+	return &openLimitReaderFile{ReaderFile: file, fs: o}, nil
+}
+
+// Write opens the file for writing once there's a free slot. The slot is freed when you close the file.
+func (o *openLimitFS) Write(filePath string) (WriterFile, error) {
+	if err := o.acquire(); err != nil {
+		return nil, newPathError("open limit", "write", filePath, err)
+	}
+	file, err := o.FS.Write(filePath)
+	if err != nil {
+		o.release()
+		return nil, err
+	}
+	return &openLimitWriterFile{WriterFile: file, fs: o}, nil
+}
+
+// List reads the contents of the directory once there's a free slot.
+func (o *openLimitFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	if err := o.acquire(); err != nil {
+		return nil, newPathError("open limit", "list", dirPath, err)
+	}
+	defer o.release()
+	return o.FS.List(dirPath, filters...)
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that shares the same limit.
+func (o *openLimitFS) ChangeDirectory(dir string) FS {
+	return &openLimitFS{FS: o.FS.ChangeDirectory(dir), slots: o.slots, timeout: o.timeout, clock: o.clock}
+}
+
+func (o *openLimitFS) withContext(ctx context.Context) FS {
+	return &openLimitFS{FS: ForRequest(o.FS, ctx), slots: o.slots, timeout: o.timeout, clock: o.clock}
+}
+
+func (o *openLimitFS) requestContext() context.Context {
+	return RequestContext(o.FS)
+}
+
+func (o *openLimitFS) wrapped() []FS {
+	return []FS{o.FS}
+}
+
+// acquire waits for a free slot, giving up once the timeout elapses or the request's context is done.
+func (o *openLimitFS) acquire() error {
+	select {
+	case o.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if o.timeout == 0 {
+		return ErrTooManyOpenFiles
+	}
+
+	var timeout <-chan time.Time
+	if o.timeout > 0 {
+		timeout = o.clock.After(o.timeout)
+	}
+	ctx := RequestContext(o.FS)
+	select {
+	case o.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		return ErrTooManyOpenFiles
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (o *openLimitFS) release() {
+	<-o.slots
+}
+
+// openLimitReaderFile frees up its slot once it's closed.
+type openLimitReaderFile struct {
+	ReaderFile
+	fs     *openLimitFS
+	closed bool
+}
+
+func (f *openLimitReaderFile) Close() error {
+	if !f.closed {
+		f.closed = true
+		defer f.fs.release()
+	}
+	return f.ReaderFile.Close()
+}
+
+// openLimitWriterFile frees up its slot once it's closed.
+type openLimitWriterFile struct {
+	WriterFile
+	fs     *openLimitFS
+	closed bool
+}
+
+func (f *openLimitWriterFile) Close() error {
+	if !f.closed {
+		f.closed = true
+		defer f.fs.release()
+	}
+	return f.WriterFile.Close()
+}
+
+var _ requestBinder = &openLimitFS{}
+var _ wrapper = &openLimitFS{}
@@ -0,0 +1,121 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type LimitOpenFilesTestSuite struct {
+	suite.Suite
+	inner filestore.FS
+}
+
+func TestLimitOpenFilesTestSuite(t *testing.T) {
+	suite.Run(t, &LimitOpenFilesTestSuite{})
+}
+
+func (s *LimitOpenFilesTestSuite) SetupTest() {
+	s.inner = filestore.Memory()
+	s.Require().NoError(writeString(s.inner, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.inner, "duderino/5.lebowski", "jackie"))
+}
+
+func (s *LimitOpenFilesTestSuite) TestFailFast() {
+	fs := filestore.LimitOpenFiles(s.inner, 2, filestore.WithOpenFileTimeout(0))
+	reader, err := fs.Read("1.lebowski")
+	s.Require().NoError(err)
+	writer, err := fs.ChangeDirectory("duderino").Write("6.lebowski")
+	s.Require().NoError(err)
+
+	_, err = fs.Read("duderino/5.lebowski")
+	s.Require().True(errors.Is(err, filestore.ErrTooManyOpenFiles), "Should share the limit w/ derived stores")
+	_, err = fs.List(".")
+	s.Require().True(errors.Is(err, filestore.ErrTooManyOpenFiles))
+
+	s.Require().NoError(writer.Close())
+	s.Require().NoError(writer.Close(), "Closing twice should not free up two slots")
+	content, err := readString(fs, "duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jackie", content)
+	_, err = fs.Write("2.lebowski")
+	s.Require().NoError(err)
+	_, err = fs.Write("3.lebowski")
+	s.Require().True(errors.Is(err, filestore.ErrTooManyOpenFiles))
+	s.Require().NoError(reader.Close())
+
+	_, err = fs.Read("missing.lebowski")
+	s.Require().Error(err)
+	_, err = fs.Read("1.lebowski")
+	s.Require().NoError(err, "Failing to open a file should free up its slot")
+}
+
+func (s *LimitOpenFilesTestSuite) TestWait() {
+	fs := filestore.LimitOpenFiles(s.inner, 1)
+	reader, err := fs.Read("1.lebowski")
+	s.Require().NoError(err)
+
+	opened := make(chan error)
+	go func() {
+		_, err := readString(fs, "duderino/5.lebowski")
+		opened <- err
+	}()
+
+	select {
+	case <-opened:
+		s.Fail("Should wait for a free slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+	s.Require().NoError(reader.Close())
+	s.Require().NoError(<-opened)
+}
+
+func (s *LimitOpenFilesTestSuite) TestWait_timeout() {
+	clock := filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	fs := filestore.LimitOpenFiles(s.inner, 1, filestore.WithOpenFileTimeout(time.Second), filestore.WithClock(clock))
+	reader, err := fs.Read("1.lebowski")
+	s.Require().NoError(err)
+	defer reader.Close()
+
+	opened := make(chan error)
+	go func() {
+		_, err := fs.Read("duderino/5.lebowski")
+		opened <- err
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	s.Require().True(errors.Is(<-opened, filestore.ErrTooManyOpenFiles))
+}
+
+func (s *LimitOpenFilesTestSuite) TestWait_requestContext() {
+	fs := filestore.LimitOpenFiles(s.inner, 1)
+	reader, err := fs.Read("1.lebowski")
+	s.Require().NoError(err)
+	defer reader.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = filestore.ForRequest(fs, ctx).Read("duderino/5.lebowski")
+	s.Require().True(errors.Is(err, context.Canceled))
+}
+
+func (s *LimitOpenFilesTestSuite) TestFromConfig() {
+	fs, err := filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "limit_open_files", Options: filestore.LayerOptions{"limit": 1, "timeout": "0s"}}},
+	})
+	s.Require().NoError(err)
+	writer, err := fs.Write("dude.txt")
+	s.Require().NoError(err)
+	defer writer.Close()
+	_, err = fs.Write("walter.txt")
+	s.Require().True(errors.Is(err, filestore.ErrTooManyOpenFiles))
+
+	_, err = filestore.FromConfig(filestore.Config{URL: "mem://", Layers: []filestore.LayerConfig{{Type: "limit_open_files"}}})
+	s.Require().Error(err, "Should require a limit")
+}
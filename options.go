@@ -0,0 +1,104 @@
+package filestore
+
+import (
+	"crypto/rand"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Option customizes cross-cutting behaviors of the stores and wrappers in this package (e.g. which
+// clock they use). Constructors that accept options quietly ignore any that don't apply to them.
+//
+// Example:
+//
+//	clock := filestoretest.NewClock(time.Now())
+//	files := filestore.Memory(filestore.WithClock(clock))
+type Option func(*options)
+
+// options contains the resolved values of all Option values supplied to a constructor.
+type options struct {
+	clock       Clock
+	random      io.Reader
+	httpClient  *http.Client
+	transport   transportOptions
+	correlation string
+	s3          s3Options
+	redis       redisOptions
+	walk        walkOptions
+	watch       watchOptions
+	poll        pollOptions
+	nearMatches bool
+	listing     listingOptions
+	copy        copyOptions
+	batch       batchOptions
+	cache       cacheOptions
+	handles     handleOptions
+	openFiles   openFileOptions
+	replication replicationOptions
+	failover    failoverOptions
+	mirror      mirrorOptions
+	versions    versionOptions
+	expiring    expiringOptions
+}
+
+// newOptions applies all of the given options on top of the package defaults.
+func newOptions(opts []Option) options {
+	result := options{
+		clock:       SystemClock(),
+		random:      rand.Reader,
+		httpClient:  http.DefaultClient,
+		correlation: defaultCorrelationHeader,
+		walk:        walkOptions{maxDepth: -1},
+		watch:       watchOptions{warn: func(string, error) {}},
+		poll:        pollOptions{interval: 2 * time.Second, fingerprint: FingerprintModTime},
+		copy:        copyOptions{changed: ChangedFileFail, attempts: 3, warn: func(string, error) {}},
+		cache:       cacheOptions{maxSize: 1 << 30},
+		openFiles:   openFileOptions{timeout: -1},
+		replication: replicationOptions{policy: ReplicateFailFast, warn: func(int, error) {}},
+		failover:    failoverOptions{interval: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&result)
+		}
+	}
+	return result
+}
+
+// WithClock overrides the source of time used by the store/wrapper. By default, everything
+// uses the SystemClock().
+func WithClock(clock Clock) Option {
+	return func(opts *options) {
+		if clock != nil {
+			opts.clock = clock
+		}
+	}
+}
+
+// WithRandom overrides the source of randomness used when generating things like unique
+// file names. By default, this is crypto/rand, but tests that want stable output names can
+// supply a seeded source instead.
+//
+// Example:
+//
+//	seeded := rand.New(rand.NewSource(42)) // math/rand
+//	name, err := filestore.UniqueName("report-*.csv", filestore.WithRandom(seeded))
+func WithRandom(random io.Reader) Option {
+	return func(opts *options) {
+		if random != nil {
+			opts.random = random
+		}
+	}
+}
+
+// WithHTTPClient overrides the HTTP client used by stores that talk to remote services (e.g. S3). You
+// can use this to customize timeouts, proxies, or TLS settings, although WithProxy(), WithRootCAs(), and
+// WithClientCertificate() are usually easier. By default, this is http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(opts *options) {
+		if client != nil {
+			opts.httpClient = client
+		}
+	}
+}
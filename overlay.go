@@ -0,0 +1,287 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// whiteoutPrefix is the prefix of the marker files that Overlay() puts in the upper layer to hide files
+// that were removed from the lower layers, just like Linux's overlayfs and Docker images do.
+const whiteoutPrefix = ".wh."
+
+// Overlay stacks one or more read-only lower layers underneath a writable upper layer, presenting them as a
+// single store. Reads fall through the layers: you get the file from the upper layer if it's there, then
+// the first lower layer that has it, and so on. Directories are merged, so listing one shows what's in every
+// layer (the upper-most version of each name wins). Writes always go to the upper layer; the lower layers are
+// never modified.
+//
+// Removing a file that exists in a lower layer puts a hidden "whiteout" marker (".wh.<name>") in the upper
+// layer so that it stays gone. Moving a file/directory that exists in a lower layer copies it into the upper
+// layer before removing the original. Because whiteouts are stored in the upper layer, a persistent upper layer
+// (e.g. Disk) remembers removals across restarts.
+//
+// Example:
+//
+//	// Users can override (or remove) any of the built-in templates.
+//	templates := filestore.Overlay(filestore.Disk("/etc/myapp/templates"), filestore.FromEmbed(defaults, "templates"))
+func Overlay(upper FS, lower ...FS) FS {
+	return &overlayFS{upper: upper, lower: lower, dir: "."}
+}
+
+type overlayFS struct {
+	upper FS
+	lower []FS
+	// dir is the working directory, relative to the working directories of all of the layers. We resolve
+	// every path ourselves (rather than changing the layers' directories) so whiteouts in parent
+	// directories still apply after ChangeDirectory().
+	dir string
+}
+
+func (o *overlayFS) resolve(filePath string) string {
+	return joinPath(o.dir, filePath)
+}
+
+// WorkingDirectory returns the working directory of the upper layer.
+func (o *overlayFS) WorkingDirectory() string {
+	return joinPath(o.upper.WorkingDirectory(), o.dir)
+}
+
+// Stat fetches metadata about the upper-most version of the file.
+func (o *overlayFS) Stat(filePath string) (FileInfo, error) {
+	_, info, err := o.find(o.resolve(filePath))
+	return info, err
+}
+
+// Exists returns true when the file/directory exists in any layer (and hasn't been removed).
+func (o *overlayFS) Exists(filePath string) bool {
+	_, _, err := o.find(o.resolve(filePath))
+	return err == nil
+}
+
+// Read opens the upper-most version of the file for reading.
+func (o *overlayFS) Read(filePath string) (ReaderFile, error) {
+	resolved := o.resolve(filePath)
+	layer, _, err := o.find(resolved)
+	if err != nil {
+		return nil, err
+	}
+	return layer.Read(resolved)
+}
+
+// Write opens the file for writing in the upper layer.
+func (o *overlayFS) Write(filePath string) (WriterFile, error) {
+	return o.upper.Write(o.resolve(filePath))
+}
+
+// List merges the contents of the directory in every layer.
+func (o *overlayFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	resolved := o.resolve(dirPath)
+	layer, info, err := o.find(resolved)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return layer.List(resolved, filters...)
+	}
+
+	// We need to see every entry (not just the ones that pass the filters) to know which names are shadowed.
+	seen := map[string]bool{}
+	var merged []FileInfo
+	include := func(entries []FileInfo) {
+		for _, entry := range entries {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				merged = append(merged, entry)
+			}
+		}
+	}
+
+	if info, err = o.upper.Stat(resolved); err == nil && info.IsDir() {
+		entries, err := o.upper.List(resolved)
+		if err != nil {
+			return nil, err
+		}
+		var visible, whiteouts []FileInfo
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), whiteoutPrefix) {
+				whiteouts = append(whiteouts, entry)
+				continue
+			}
+			visible = append(visible, entry)
+		}
+		include(visible)
+		// Whiteouts hide the lower layers' entries, not something you wrote to the upper layer afterwards.
+		for _, whiteout := range whiteouts {
+			seen[strings.TrimPrefix(whiteout.Name(), whiteoutPrefix)] = true
+		}
+	}
+	if !o.hidden(resolved) {
+		for _, lower := range o.lower {
+			if info, err = lower.Stat(resolved); err != nil || !info.IsDir() {
+				continue
+			}
+			entries, err := lower.List(resolved)
+			if err != nil {
+				return nil, err
+			}
+			include(entries)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Name() < merged[j].Name()
+	})
+	results, err := filterFiles(merged, filters)
+	if err != nil {
+		return nil, newPathError("overlay", "list files", dirPath, err)
+	}
+	return results, nil
+}
+
+// ChangeDirectory creates a new overlay of the same layers rooted in the given subdirectory.
+func (o *overlayFS) ChangeDirectory(dir string) FS {
+	return &overlayFS{upper: o.upper, lower: o.lower, dir: o.resolve(dir)}
+}
+
+// Remove deletes the file/directory from the upper layer, leaving a whiteout behind if it also exists in one
+// of the lower layers.
+func (o *overlayFS) Remove(fileOrDirPath string) error {
+	resolved := o.resolve(fileOrDirPath)
+	if resolved == "." {
+		// There's nowhere to put a whiteout for the root, so remove everything in it instead.
+		entries, err := o.List(fileOrDirPath)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err = o.Remove(path.Join(fileOrDirPath, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := o.upper.Remove(resolved); err != nil {
+		return err
+	}
+	if !o.inLower(resolved) {
+		return nil
+	}
+	dir, name := path.Split(resolved)
+	file, err := o.upper.Write(path.Join(dir, whiteoutPrefix+name))
+	if err != nil {
+		return fmt.Errorf("filestore: overlay: remove: %w", err)
+	}
+	return file.Close()
+}
+
+// Move relocates the file/directory. When any of it lives in a lower layer, it's copied into the upper layer
+// and the original is removed (leaving a whiteout).
+func (o *overlayFS) Move(fromPath string, toPath string) error {
+	from, to := o.resolve(fromPath), o.resolve(toPath)
+	if _, _, err := o.find(from); err != nil {
+		return err
+	}
+	// Check these before removing the destination, since it could be the very thing we're moving.
+	if from == to {
+		return nil
+	}
+	if isWithinPath(from, to) {
+		return newPathError("overlay", "move", fromPath, errors.New("can not move a directory inside of itself"))
+	}
+	// Whatever is at the destination, in any layer, is replaced.
+	if err := o.Remove(toPath); err != nil {
+		return err
+	}
+	if !o.inLower(from) {
+		return o.upper.Move(from, to)
+	}
+	if err := CopyAll(o, fromPath, o.upper, to); err != nil {
+		return fmt.Errorf("filestore: overlay: move: %w", err)
+	}
+	return o.Remove(fromPath)
+}
+
+func (o *overlayFS) withContext(ctx context.Context) FS {
+	lower := make([]FS, len(o.lower))
+	for i, layer := range o.lower {
+		lower[i] = ForRequest(layer, ctx)
+	}
+	return &overlayFS{upper: ForRequest(o.upper, ctx), lower: lower, dir: o.dir}
+}
+
+func (o *overlayFS) requestContext() context.Context {
+	return RequestContext(o.upper)
+}
+
+func (o *overlayFS) wrapped() []FS {
+	return append([]FS{o.upper}, o.lower...)
+}
+
+// find returns the upper-most layer that has the file/directory at the resolved path.
+func (o *overlayFS) find(resolved string) (FS, FileInfo, error) {
+	info, err := o.upper.Stat(resolved)
+	switch {
+	case err == nil:
+		return o.upper, info, nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, nil, err
+	}
+
+	if !o.hidden(resolved) {
+		for _, lower := range o.lower {
+			info, err = lower.Stat(resolved)
+			switch {
+			case err == nil:
+				return lower, info, nil
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, nil, err
+			}
+		}
+	}
+	return nil, nil, &fs.PathError{Op: "stat", Path: resolved, Err: fs.ErrNotExist}
+}
+
+// inLower returns true when the resolved path exists (and is visible) in one of the lower layers.
+func (o *overlayFS) inLower(resolved string) bool {
+	if o.hidden(resolved) {
+		return false
+	}
+	for _, lower := range o.lower {
+		if lower.Exists(resolved) {
+			return true
+		}
+	}
+	return false
+}
+
+// hidden returns true when the lower layers' version of the resolved path should not be visible, either
+// because it (or one of its parents) has a whiteout or because the upper layer has a file where one of its
+// parent directories should be.
+func (o *overlayFS) hidden(resolved string) bool {
+	if resolved == "." || resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return false
+	}
+	parent := "."
+	for _, segment := range strings.Split(resolved, "/") {
+		if o.upper.Exists(path.Join(parent, whiteoutPrefix+segment)) {
+			return true
+		}
+		if parent != "." {
+			if info, err := o.upper.Stat(parent); err == nil && !info.IsDir() {
+				return true
+			}
+		}
+		parent = path.Join(parent, segment)
+	}
+	return false
+}
+
+var _ FS = &overlayFS{}
+var _ requestBinder = &overlayFS{}
+var _ wrapper = &overlayFS{}
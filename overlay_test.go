@@ -0,0 +1,202 @@
+package filestore_test
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type OverlayTestSuite struct {
+	suite.Suite
+	upper  filestore.FS
+	middle filestore.FS
+	lower  filestore.FS
+	fs     filestore.FS
+}
+
+func TestOverlayTestSuite(t *testing.T) {
+	suite.Run(t, &OverlayTestSuite{})
+}
+
+func (s *OverlayTestSuite) SetupTest() {
+	s.upper = filestore.Memory()
+	s.middle = filestore.Memory()
+	s.lower = filestore.Memory()
+	s.fs = filestore.Overlay(s.upper, s.middle, s.lower)
+
+	s.Require().NoError(writeString(s.lower, "templates/index.html", "lower index"))
+	s.Require().NoError(writeString(s.lower, "templates/about.html", "lower about"))
+	s.Require().NoError(writeString(s.lower, "templates/partials/nav.html", "lower nav"))
+	s.Require().NoError(writeString(s.middle, "templates/about.html", "middle about"))
+	s.Require().NoError(writeString(s.upper, "templates/index.html", "upper index"))
+}
+
+func (s *OverlayTestSuite) TestRead() {
+	s.assertContent("templates/index.html", "upper index")
+	s.assertContent("templates/about.html", "middle about")
+	s.assertContent("templates/partials/nav.html", "lower nav")
+
+	_, err := s.fs.Read("templates/missing.html")
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+	s.Require().True(s.fs.Exists("templates/partials"))
+	s.Require().False(s.fs.Exists("templates/missing.html"))
+
+	info, err := s.fs.Stat("templates/about.html")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(len("middle about")), info.Size())
+}
+
+func (s *OverlayTestSuite) TestWrite() {
+	s.Require().NoError(writeString(s.fs, "templates/partials/nav.html", "custom nav"))
+	s.assertContent("templates/partials/nav.html", "custom nav")
+
+	content, err := readString(s.lower, "templates/partials/nav.html")
+	s.Require().NoError(err)
+	s.Require().Equal("lower nav", content, "Should never touch the lower layers")
+	content, err = readString(s.upper, "templates/partials/nav.html")
+	s.Require().NoError(err)
+	s.Require().Equal("custom nav", content)
+}
+
+func (s *OverlayTestSuite) TestList() {
+	s.Require().NoError(writeString(s.middle, "templates/contact.html", "middle contact"))
+	s.assertList("templates", "about.html", "contact.html", "index.html", "partials")
+
+	sized := func(info filestore.FileInfo) bool { return info.Size() == int64(len("middle about")) }
+	files, err := s.fs.List("templates", filestore.WithExt("html"), sized)
+	s.Require().NoError(err)
+	s.Require().Len(files, 1)
+	s.Require().Equal("about.html", files[0].Name(), "Should filter the version from the upper-most layer")
+
+	_, err = s.fs.List("missing")
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+}
+
+func (s *OverlayTestSuite) TestRemove() {
+	s.Require().NoError(s.fs.Remove("templates/about.html"))
+	s.Require().False(s.fs.Exists("templates/about.html"), "Should hide it in every lower layer")
+	_, err := s.fs.Read("templates/about.html")
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+	s.Require().True(s.middle.Exists("templates/about.html"))
+	s.Require().True(s.lower.Exists("templates/about.html"))
+	s.assertList("templates", "index.html", "partials")
+
+	s.Require().NoError(s.fs.Remove("templates/index.html"))
+	s.Require().False(s.fs.Exists("templates/index.html"), "Should remove the upper copy and hide the lower one")
+	s.Require().NoError(s.fs.Remove("templates/partials"))
+	s.Require().False(s.fs.Exists("templates/partials/nav.html"), "Should hide everything inside of it")
+	s.assertList("templates")
+	s.Require().NoError(s.fs.Remove("templates/missing.html"))
+
+	// Writing it again should bring it back, but not the rest of the lower directory's contents.
+	s.Require().NoError(writeString(s.fs, "templates/about.html", "new about"))
+	s.assertContent("templates/about.html", "new about")
+	s.assertList("templates", "about.html")
+	s.Require().NoError(writeString(s.fs, "templates/partials/footer.html", "footer"))
+	s.assertList("templates/partials", "footer.html")
+}
+
+func (s *OverlayTestSuite) TestRemove_root() {
+	s.Require().NoError(s.fs.Remove("."))
+	s.assertList(".")
+	s.Require().True(s.lower.Exists("templates/index.html"))
+}
+
+func (s *OverlayTestSuite) TestRemove_persistent() {
+	upper := filestore.Disk(s.T().TempDir())
+	s.Require().NoError(filestore.Overlay(upper, s.lower).Remove("templates/about.html"))
+
+	// Whiteouts live in the upper layer, so a fresh overlay should still respect them.
+	overlay := filestore.Overlay(upper, s.lower)
+	s.Require().False(overlay.Exists("templates/about.html"))
+	s.Require().True(overlay.Exists("templates/index.html"))
+}
+
+func (s *OverlayTestSuite) TestMove() {
+	s.Require().NoError(s.fs.Move("templates/about.html", "templates/about-us.html"))
+	s.assertContent("templates/about-us.html", "middle about")
+	s.Require().False(s.fs.Exists("templates/about.html"))
+	s.Require().True(s.middle.Exists("templates/about.html"))
+
+	s.Require().NoError(s.fs.Move("templates/partials", "partials"))
+	s.assertContent("partials/nav.html", "lower nav")
+	s.Require().False(s.fs.Exists("templates/partials"))
+	s.assertList("templates", "about-us.html", "index.html")
+
+	// The destination should be replaced in every layer, not just the upper one.
+	s.Require().NoError(writeString(s.lower, "partials/footer.html", "lower footer"))
+	s.Require().NoError(s.fs.Move("templates/index.html", "partials"))
+	s.assertContent("partials", "upper index")
+	s.Require().False(s.fs.Exists("partials/footer.html"))
+
+	err := s.fs.Move("templates/missing.html", "templates/other.html")
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+}
+
+func (s *OverlayTestSuite) TestMove_ontoItself() {
+	s.Require().NoError(s.fs.Move("templates/about.html", "templates/about.html"))
+	s.assertContent("templates/about.html", "middle about")
+
+	s.Require().NoError(s.fs.Move("templates", "templates"))
+	s.assertContent("templates/partials/nav.html", "lower nav")
+}
+
+func (s *OverlayTestSuite) TestMove_insideItself() {
+	err := s.fs.Move("templates", "templates/old")
+	s.Require().Error(err)
+	s.assertContent("templates/index.html", "upper index")
+	s.assertContent("templates/partials/nav.html", "lower nav")
+	s.Require().False(s.fs.Exists("templates/old"))
+
+	err = s.fs.Move("templates/partials", "templates/partials/nav.html")
+	s.Require().Error(err)
+	s.assertContent("templates/partials/nav.html", "lower nav")
+}
+
+func (s *OverlayTestSuite) TestChangeDirectory() {
+	s.Require().NoError(s.fs.Remove("templates/about.html"))
+
+	templates := s.fs.ChangeDirectory("templates")
+	s.Require().Equal("/templates", templates.WorkingDirectory())
+	s.Require().False(templates.Exists("about.html"), "Should still respect whiteouts")
+	s.assertList("templates", "index.html", "partials")
+
+	partials := templates.ChangeDirectory("partials")
+	s.Require().NoError(writeString(partials, "footer.html", "footer"))
+	s.Require().True(s.upper.Exists("templates/partials/footer.html"))
+	s.Require().NoError(partials.Remove("nav.html"))
+	s.Require().False(s.fs.Exists("templates/partials/nav.html"))
+}
+
+func (s *OverlayTestSuite) TestForRequest() {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "dude")
+	bound := filestore.ForRequest(s.fs, ctx)
+	s.Require().Equal("dude", filestore.RequestContext(bound).Value(key{}))
+
+	content, err := readString(bound, "templates/about.html")
+	s.Require().NoError(err)
+	s.Require().Equal("middle about", content)
+}
+
+func (s *OverlayTestSuite) assertContent(filePath string, expected string) {
+	content, err := readString(s.fs, filePath)
+	s.Require().NoError(err)
+	s.Require().Equal(expected, content)
+}
+
+func (s *OverlayTestSuite) assertList(dirPath string, expected ...string) {
+	files, err := s.fs.List(dirPath)
+	s.Require().NoError(err)
+	names := []string{}
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	if expected == nil {
+		expected = []string{}
+	}
+	s.Require().Equal(expected, names)
+}
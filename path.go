@@ -1,7 +1,10 @@
 package filestore
 
 import (
+	"errors"
+	"fmt"
 	"path"
+	"path/filepath"
 	"strings"
 )
 
@@ -29,3 +32,72 @@ func ChangeExtension(fileName string, ext string) string {
 		return strings.TrimSuffix(fileName, currentExt) + ext
 	}
 }
+
+// resolvePath is the one place where every store in this package converts a caller-supplied path
+// into the path of the actual file/directory relative to the store's base path. Having every
+// operation (Stat, Exists, Read, Write, List, Move, Remove, etc.) go through here guarantees that
+// they all agree on which file a given path refers to.
+//
+// The resulting path is always cleaned. Paths are allowed to contain ".." segments, but this
+// does not prevent them from escaping the base path; that's a policy decision for the caller. It
+// does, however, reject paths that no file system can represent (e.g. those containing NUL bytes).
+func resolvePath(basePath string, filePath string) (string, error) {
+	if strings.ContainsRune(basePath, 0) || strings.ContainsRune(filePath, 0) {
+		return "", fmt.Errorf("invalid path: %q", filePath)
+	}
+	return joinPath(basePath, filePath), nil
+}
+
+// joinPath is the non-validating half of resolvePath(). Use this only in places that can't
+// report an error (e.g. ChangeDirectory); the invalid path will fail on the next operation.
+func joinPath(basePath string, filePath string) string {
+	return path.Clean(path.Join(filepath.ToSlash(basePath), filepath.ToSlash(filePath)))
+}
+
+// ErrPathEscapesBase is the error returned by SafeJoin() when the resulting path would be outside of
+// the base path.
+var ErrPathEscapesBase = errors.New("filestore: path escapes base directory")
+
+// SafeJoin joins the parts onto the base path using the exact same rules that every store in this
+// package uses to resolve the paths you give it, but it fails w/ ErrPathEscapesBase if the result is
+// outside of the base path (e.g. "../../etc/passwd"). It also rejects paths that no file system can
+// represent (e.g. those containing NUL bytes). Use this to validate user-supplied paths before handing
+// them to a store (or anything else). The resulting path is always cleaned and includes the base.
+//
+// Example:
+//
+//	filePath, err := filestore.SafeJoin("uploads", userID, req.FormValue("name"))
+//	if err != nil {
+//	    // reject the request
+//	}
+func SafeJoin(basePath string, parts ...string) (string, error) {
+	base, err := resolvePath(basePath, "")
+	if err != nil {
+		return "", fmt.Errorf("filestore: safe join: %w", err)
+	}
+	fullPath := base
+	for _, part := range parts {
+		if fullPath, err = resolvePath(fullPath, part); err != nil {
+			return "", fmt.Errorf("filestore: safe join: %w", err)
+		}
+	}
+	if !isWithinPath(base, fullPath) {
+		return "", fmt.Errorf("filestore: safe join: %s: %w", fullPath, ErrPathEscapesBase)
+	}
+	return fullPath, nil
+}
+
+// isWithinPath returns true when the resolved path is the base path itself or something inside of it.
+// Both paths must already be cleaned (e.g. by resolvePath).
+func isWithinPath(basePath string, fullPath string) bool {
+	switch {
+	case fullPath == basePath:
+		return true
+	case basePath == "/":
+		return strings.HasPrefix(fullPath, "/")
+	case basePath == ".":
+		return fullPath != ".." && !strings.HasPrefix(fullPath, "../") && !strings.HasPrefix(fullPath, "/")
+	default:
+		return strings.HasPrefix(fullPath, basePath+"/")
+	}
+}
@@ -1,6 +1,8 @@
 package filestore_test
 
 import (
+	"path"
+	"strings"
 	"testing"
 
 	"github.com/monadicstack/filestore"
@@ -48,6 +50,122 @@ func (s *PathTestSuite) TestChangeExtension() {
 	s.Require().Equal("a.super-🍺", filestore.ChangeExtension("a.b", ".super-🍺"))
 }
 
+func (s *PathTestSuite) TestResolvePath() {
+	resolve := func(basePath string, filePath string) string {
+		result, err := filestore.ResolvePath(basePath, filePath)
+		s.Require().NoError(err, "Resolving valid path should not fail: %s + %s", basePath, filePath)
+		return result
+	}
+
+	s.Require().Equal("testdata", resolve("testdata", ""))
+	s.Require().Equal("testdata", resolve("testdata", "."))
+	s.Require().Equal("testdata", resolve("testdata/", "./"))
+	s.Require().Equal("testdata/hello.txt", resolve("testdata", "hello.txt"))
+	s.Require().Equal("testdata/hello.txt", resolve("testdata", "/hello.txt"))
+	s.Require().Equal("testdata/hello.txt", resolve("./testdata", "inner1/../hello.txt"))
+	s.Require().Equal("testdata/inner1/foo.txt", resolve("testdata", "inner1//foo.txt"))
+	s.Require().Equal(".", resolve("testdata", ".."))
+	s.Require().Equal("..", resolve("testdata", "../.."))
+	s.Require().Equal("/", resolve("/", "../.."))
+	s.Require().Equal("/a/b", resolve("/a", "b"))
+	s.Require().Equal("foo.txt", resolve("", "foo.txt"))
+
+	_, err := filestore.ResolvePath("testdata", "foo\x00.txt")
+	s.Require().Error(err, "Paths containing NUL bytes should be rejected")
+	_, err = filestore.ResolvePath("test\x00data", "foo.txt")
+	s.Require().Error(err, "Base paths containing NUL bytes should be rejected")
+}
+
+func (s *PathTestSuite) TestSafeJoin() {
+	join := func(basePath string, parts ...string) string {
+		result, err := filestore.SafeJoin(basePath, parts...)
+		s.Require().NoError(err, "Joining safe path should not fail: %s + %v", basePath, parts)
+		return result
+	}
+	s.Require().Equal("uploads", join("uploads"))
+	s.Require().Equal("uploads", join("uploads/", "", "."))
+	s.Require().Equal("uploads/dude/rug.txt", join("uploads", "dude", "rug.txt"))
+	s.Require().Equal("uploads/dude/rug.txt", join("./uploads", "dude/", "/rug.txt"))
+	s.Require().Equal("uploads/rug.txt", join("uploads", "dude", "../rug.txt"))
+	s.Require().Equal("uploads/etc/passwd", join("uploads", "/etc/passwd"), "Absolute parts are still relative to the base")
+	s.Require().Equal("/var/uploads/a", join("/var/uploads", "a"))
+	s.Require().Equal("/a/b", join("/", "..", "a/b"), "Nothing escapes the root")
+	s.Require().Equal("a/b", join("", "a", "b"))
+	s.Require().Equal("..foo", join(".", "..foo"))
+
+	escapes := func(basePath string, parts ...string) {
+		_, err := filestore.SafeJoin(basePath, parts...)
+		s.Require().ErrorIs(err, filestore.ErrPathEscapesBase, "Should not escape base: %s + %v", basePath, parts)
+	}
+	escapes("uploads", "..")
+	escapes("uploads", "../uploads-other/rug.txt")
+	escapes("uploads", "dude", "../../etc/passwd")
+	escapes("/var/uploads", "../log")
+	escapes(".", "../secret")
+	escapes("", "..")
+
+	_, err := filestore.SafeJoin("uploads", "rug\x00.txt")
+	s.Require().Error(err, "Paths containing NUL bytes should be rejected")
+	_, err = filestore.SafeJoin("up\x00loads", "rug.txt")
+	s.Require().Error(err, "Base paths containing NUL bytes should be rejected")
+}
+
 func TestPathTestSuite(t *testing.T) {
 	suite.Run(t, &PathTestSuite{})
 }
+
+func FuzzResolvePath(f *testing.F) {
+	f.Add("testdata", "hello.txt")
+	f.Add("testdata", "inner1/../../..")
+	f.Add("/", "../../etc/passwd")
+	f.Add("", "")
+	f.Add("./a//b", "c/./d/")
+	f.Add("a", "b\x00c")
+
+	f.Fuzz(func(t *testing.T, basePath string, filePath string) {
+		result, err := filestore.ResolvePath(basePath, filePath)
+		if strings.ContainsRune(basePath+filePath, 0) {
+			if err == nil {
+				t.Fatalf("Paths w/ NUL bytes should be rejected: %q + %q", basePath, filePath)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error resolving %q + %q: %v", basePath, filePath, err)
+		}
+		if result != path.Clean(result) {
+			t.Fatalf("Resolved path should always be clean: %q", result)
+		}
+		if strings.HasPrefix(basePath, "/") && !strings.HasPrefix(result, "/") {
+			t.Fatalf("Resolved path should remain absolute when base is absolute: %q", result)
+		}
+		if again, _ := filestore.ResolvePath(result, ""); again != result {
+			t.Fatalf("Resolving should be idempotent: %q != %q", again, result)
+		}
+	})
+}
+
+func FuzzSafeJoin(f *testing.F) {
+	f.Add("uploads", "dude/rug.txt")
+	f.Add("uploads", "../../etc/passwd")
+	f.Add("/", "../..")
+	f.Add("", "..")
+	f.Add("./a//b", "c/../../d")
+
+	f.Fuzz(func(t *testing.T, basePath string, filePath string) {
+		result, err := filestore.SafeJoin(basePath, filePath)
+		if err != nil {
+			return
+		}
+		base := path.Clean(basePath)
+		if base == "." {
+			if result == ".." || strings.HasPrefix(result, "../") {
+				t.Fatalf("Joined path should not escape the base: %q + %q = %q", basePath, filePath, result)
+			}
+			return
+		}
+		if result != base && !strings.HasPrefix(result, strings.TrimSuffix(base, "/")+"/") {
+			t.Fatalf("Joined path should not escape the base: %q + %q = %q", basePath, filePath, result)
+		}
+	})
+}
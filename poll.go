@@ -0,0 +1,336 @@
+package filestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Fingerprint summarizes a file so that Poll() can tell when it changed between two passes; the file
+// changed when its fingerprint did. Poll() also uses fingerprints to pair up a removal and a creation
+// as a single EventMove, so they should stay the same when a file is renamed.
+type Fingerprint func(fsys FS, filePath string, info FileInfo) (string, error)
+
+// FingerprintModTime considers a file changed when its modification time or size changes. This is cheap
+// since it only needs the info that listing the directory already gave us, but it can miss a rewrite
+// that doesn't change the file's size on stores w/ coarse modification times. This is the default.
+func FingerprintModTime(_ FS, _ string, info FileInfo) (string, error) {
+	return strconv.FormatInt(info.ModTime().UnixNano(), 10) + ":" + strconv.FormatInt(info.Size(), 10), nil
+}
+
+// FingerprintHash considers a file changed when its contents change. It reads every file on every
+// pass, so only use this for small trees (or long intervals) where you can't trust modification times.
+func FingerprintHash(fsys FS, filePath string, _ FileInfo) (string, error) {
+	file, err := fsys.Read(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// pollOptions contains the settings that only apply to Poll().
+type pollOptions struct {
+	interval    time.Duration
+	fingerprint Fingerprint
+}
+
+// WithPollInterval changes how long Poll() waits between passes over the directory. The default is
+// 2 seconds.
+func WithPollInterval(interval time.Duration) Option {
+	return func(opts *options) {
+		if interval > 0 {
+			opts.poll.interval = interval
+		}
+	}
+}
+
+// WithFingerprint changes how Poll() decides that a file changed. The default is FingerprintModTime; use
+// FingerprintHash (or your own Fingerprint) when modification times aren't reliable enough.
+//
+// Example:
+//
+//	events, err := filestore.Poll(ctx, files, "inbox", filestore.WithFingerprint(filestore.FingerprintHash))
+func WithFingerprint(fingerprint Fingerprint) Option {
+	return func(opts *options) {
+		if fingerprint != nil {
+			opts.poll.fingerprint = fingerprint
+		}
+	}
+}
+
+// Poll watches the given directory of any store that can list its files by comparing snapshots of the
+// directory every so often (see WithPollInterval). It delivers the very same Events that Watch() does,
+// so your code doesn't have to care whether changes come from native notifications or from polling.
+// Use this for stores that don't support the Watcher capability. Supply WithRecursive() to watch the
+// entire tree rather than just the directory's immediate children.
+//
+// Since we only ever see the result of each change, a new file gives you an EventCreate immediately
+// followed by an EventWrite, and a file that changed (according to its Fingerprint) gives you an
+// EventWrite. A removal and a creation w/ identical fingerprints in the same pass are reported as a
+// single EventMove; when watching recursively, that includes directories w/ identical contents. Events
+// keep coming until you cancel the context or the directory itself is removed, at which point the
+// channel is closed.
+//
+// Example:
+//
+//	events, err := filestore.Poll(ctx, bucket, "inbox", filestore.WithPollInterval(time.Minute))
+//	if err != nil {
+//	    // handle error
+//	}
+//	for event := range events {
+//	    fmt.Println(event) // e.g. "CREATE inbox/a.txt"
+//	}
+func Poll(ctx context.Context, fsys FS, dir string, opts ...Option) (<-chan Event, error) {
+	options := newOptions(opts)
+	p := &poller{
+		fs:      fsys,
+		dir:     path.Clean(dir),
+		opts:    opts,
+		options: options,
+		events:  make(chan Event, 64),
+	}
+
+	if err := p.checkDir(); err != nil {
+		return nil, fmt.Errorf("filestore: poll: %s: %w", dir, err)
+	}
+	snapshot, err := p.snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("filestore: poll: %s: %w", dir, err)
+	}
+	go p.run(ctx, snapshot)
+	return p.events, nil
+}
+
+// pollEntry is what a poller remembers about a single file/directory between passes.
+type pollEntry struct {
+	dir         bool
+	fingerprint string
+}
+
+// key identifies entries that are (probably) the same thing. Entries w/o a fingerprint are never
+// considered the same as anything else.
+func (entry pollEntry) key() string {
+	switch {
+	case entry.fingerprint == "":
+		return ""
+	case entry.dir:
+		return "d:" + entry.fingerprint
+	default:
+		return "f:" + entry.fingerprint
+	}
+}
+
+// errPollNotDir is the error we give you when you try to poll a file.
+var errPollNotDir = errors.New("not a directory")
+
+// poller compares snapshots of a directory to turn the differences into Events.
+type poller struct {
+	fs      FS
+	dir     string
+	opts    []Option
+	options options
+	events  chan Event
+}
+
+func (p *poller) run(ctx context.Context, snapshot map[string]pollEntry) {
+	defer close(p.events)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.options.clock.After(p.options.poll.interval):
+		}
+
+		err := p.checkDir()
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, errPollNotDir) {
+			return
+		}
+		next, err := p.snapshot()
+		if err != nil {
+			// Most likely a hiccup talking to the store. Don't report everything as removed; just
+			// try again next time.
+			continue
+		}
+		for _, event := range diffSnapshots(snapshot, next) {
+			select {
+			case p.events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+		snapshot = next
+	}
+}
+
+// checkDir makes sure that the directory we're polling still exists.
+func (p *poller) checkDir() error {
+	info, err := p.fs.Stat(p.dir)
+	switch {
+	case err != nil:
+		return err
+	case !info.IsDir():
+		return errPollNotDir
+	default:
+		return nil
+	}
+}
+
+// snapshot walks the directory and fingerprints everything inside of it.
+func (p *poller) snapshot() (map[string]pollEntry, error) {
+	walkOpts := p.opts
+	if !p.options.watch.recursive {
+		walkOpts = append(walkOpts[:len(walkOpts):len(walkOpts)], WithMaxDepth(1))
+	}
+
+	snapshot := map[string]pollEntry{}
+	children := map[string][]string{}
+	err := Walk(p.fs, p.dir, func(filePath string, info FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case filePath == p.dir:
+			return nil
+		}
+
+		entry := pollEntry{dir: info.IsDir()}
+		if !entry.dir {
+			entry.fingerprint, err = p.options.poll.fingerprint(p.fs, filePath, info)
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil // removed while we were walking; we'll notice next time
+			}
+			if err != nil {
+				return err
+			}
+		}
+		snapshot[filePath] = entry
+		children[path.Dir(filePath)] = append(children[path.Dir(filePath)], filePath)
+		return nil
+	}, walkOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// When we know everything inside of a directory, it gets a fingerprint, too, so that we can spot
+	// a directory that moved w/ all of its contents.
+	if p.options.watch.recursive {
+		for filePath, entry := range snapshot {
+			if entry.dir {
+				entry.fingerprint = dirFingerprint(filePath, snapshot, children)
+				snapshot[filePath] = entry
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+// dirFingerprint combines the names and fingerprints of everything inside of the directory. Empty
+// directories don't get a fingerprint since they'd all look the same.
+func dirFingerprint(dirPath string, snapshot map[string]pollEntry, children map[string][]string) string {
+	names := children[dirPath]
+	if len(names) == 0 {
+		return ""
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, childPath := range names {
+		child := snapshot[childPath]
+		if child.dir {
+			child.fingerprint = dirFingerprint(childPath, snapshot, children)
+		}
+		fmt.Fprintf(hash, "%s\x00%t\x00%s\n", path.Base(childPath), child.dir, child.fingerprint)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// diffSnapshots describes the changes between two snapshots as Events: moves first, then removals
+// (deepest first), then creations (parents first), then writes.
+func diffSnapshots(prev map[string]pollEntry, next map[string]pollEntry) []Event {
+	var removed, created, written []string
+	for filePath, entry := range prev {
+		nextEntry, ok := next[filePath]
+		switch {
+		case !ok || nextEntry.dir != entry.dir:
+			removed = append(removed, filePath)
+		case !entry.dir && nextEntry.fingerprint != entry.fingerprint:
+			written = append(written, filePath)
+		}
+	}
+	for filePath, entry := range next {
+		if prevEntry, ok := prev[filePath]; !ok || prevEntry.dir != entry.dir {
+			created = append(created, filePath)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(created)
+	sort.Strings(written)
+
+	removedByKey := map[string][]string{}
+	for _, filePath := range removed {
+		removedByKey[prev[filePath].key()] = append(removedByKey[prev[filePath].key()], filePath)
+	}
+	createdByKey := map[string][]string{}
+	for _, filePath := range created {
+		createdByKey[next[filePath].key()] = append(createdByKey[next[filePath].key()], filePath)
+	}
+
+	// Only pair up a removal and a creation when there's no doubt about which one goes w/ which.
+	var events []Event
+	var movedFrom, movedTo []string
+	for _, oldPath := range removed {
+		key := prev[oldPath].key()
+		if key == "" || len(removedByKey[key]) != 1 || len(createdByKey[key]) != 1 {
+			continue
+		}
+		newPath := createdByKey[key][0]
+		if withinAny(movedFrom, oldPath) || withinAny(movedTo, newPath) {
+			continue
+		}
+		events = append(events, Event{Op: EventMove, Path: newPath, OldPath: oldPath, Dir: prev[oldPath].dir})
+		movedFrom = append(movedFrom, oldPath)
+		movedTo = append(movedTo, newPath)
+	}
+
+	for i := len(removed) - 1; i >= 0; i-- {
+		if !withinAny(movedFrom, removed[i]) {
+			events = append(events, Event{Op: EventRemove, Path: removed[i], Dir: prev[removed[i]].dir})
+		}
+	}
+	for _, filePath := range created {
+		if withinAny(movedTo, filePath) {
+			continue
+		}
+		events = append(events, Event{Op: EventCreate, Path: filePath, Dir: next[filePath].dir})
+		if !next[filePath].dir {
+			events = append(events, Event{Op: EventWrite, Path: filePath})
+		}
+	}
+	for _, filePath := range written {
+		events = append(events, Event{Op: EventWrite, Path: filePath})
+	}
+	return events
+}
+
+// withinAny returns true when the path is one of the given paths or inside of one of them.
+func withinAny(paths []string, filePath string) bool {
+	for _, p := range paths {
+		if isWithinPath(p, filePath) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package filestore_test
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type PollTestSuite struct {
+	suite.Suite
+	clock *filestoretest.Clock
+	fs    filestore.FS
+}
+
+func TestPollTestSuite(t *testing.T) {
+	suite.Run(t, &PollTestSuite{})
+}
+
+func (s *PollTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.fs = filestore.Memory(filestore.WithClock(s.clock))
+	s.Require().NoError(writeString(s.fs, "inbox/a.txt", "abide"))
+	s.Require().NoError(writeString(s.fs, "inbox/2022/09/report.pdf", "walter"))
+	s.Require().NoError(writeString(s.fs, "inbox/2022/09/summary.pdf", "donnie"))
+}
+
+func (s *PollTestSuite) TestPoll() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := filestore.Poll(ctx, s.fs, "inbox", filestore.WithClock(s.clock), filestore.WithPollInterval(time.Minute))
+	s.Require().NoError(err)
+
+	// Nothing changed, so there's nothing to report.
+	s.tick()
+	s.Require().NoError(writeString(s.fs, "inbox/b.txt", "bunny"))
+	s.tick()
+	s.assertEvents(events,
+		filestore.Event{Op: filestore.EventCreate, Path: "inbox/b.txt"},
+		filestore.Event{Op: filestore.EventWrite, Path: "inbox/b.txt"},
+	)
+
+	s.Require().NoError(writeString(s.fs, "inbox/a.txt", "abide, man"))
+	s.Require().NoError(s.fs.Move("inbox/b.txt", "inbox/c.txt"))
+	s.Require().NoError(writeString(s.fs, "inbox/2022/09/ignored.pdf", "not recursive"))
+	s.tick()
+	s.assertEvents(events,
+		filestore.Event{Op: filestore.EventMove, OldPath: "inbox/b.txt", Path: "inbox/c.txt"},
+		filestore.Event{Op: filestore.EventWrite, Path: "inbox/a.txt"},
+	)
+
+	s.Require().NoError(s.fs.Remove("inbox/2022"))
+	s.tick()
+	s.assertEvents(events, filestore.Event{Op: filestore.EventRemove, Path: "inbox/2022", Dir: true})
+
+	s.Require().NoError(s.fs.Remove("inbox"))
+	s.tick()
+	s.assertClosed(events)
+}
+
+func (s *PollTestSuite) TestPoll_recursive() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := filestore.Poll(ctx, s.fs, "inbox", filestore.WithClock(s.clock), filestore.WithRecursive())
+	s.Require().NoError(err)
+
+	s.Require().NoError(writeString(s.fs, "inbox/2022/10/new.pdf", "maude"))
+	s.tick()
+	s.assertEvents(events,
+		filestore.Event{Op: filestore.EventCreate, Path: "inbox/2022/10", Dir: true},
+		filestore.Event{Op: filestore.EventCreate, Path: "inbox/2022/10/new.pdf"},
+		filestore.Event{Op: filestore.EventWrite, Path: "inbox/2022/10/new.pdf"},
+	)
+
+	// Moving a directory should be a single event, not one per file.
+	s.Require().NoError(s.fs.Move("inbox/2022", "inbox/archive"))
+	s.tick()
+	s.assertEvents(events, filestore.Event{Op: filestore.EventMove, OldPath: "inbox/2022", Path: "inbox/archive", Dir: true})
+
+	s.Require().NoError(s.fs.Remove("inbox/archive/09"))
+	s.tick()
+	s.assertEvents(events,
+		filestore.Event{Op: filestore.EventRemove, Path: "inbox/archive/09/summary.pdf"},
+		filestore.Event{Op: filestore.EventRemove, Path: "inbox/archive/09/report.pdf"},
+		filestore.Event{Op: filestore.EventRemove, Path: "inbox/archive/09", Dir: true},
+	)
+
+	cancel()
+	s.assertClosed(events)
+}
+
+func (s *PollTestSuite) TestPoll_fingerprint() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	byModTime, err := filestore.Poll(ctx, s.fs, "inbox", filestore.WithClock(s.clock))
+	s.Require().NoError(err)
+	byHash, err := filestore.Poll(ctx, s.fs, "inbox", filestore.WithClock(s.clock), filestore.WithFingerprint(filestore.FingerprintHash))
+	s.Require().NoError(err)
+
+	// Same size, same (fake) modification time, different contents.
+	s.Require().NoError(writeString(s.fs, "inbox/a.txt", "ABIDE"))
+	s.clock.BlockUntil(2)
+	s.clock.Advance(2 * time.Second)
+	s.assertEvents(byHash, filestore.Event{Op: filestore.EventWrite, Path: "inbox/a.txt"})
+
+	s.clock.BlockUntil(2)
+	s.Require().Empty(byModTime, "Modification times and sizes didn't change")
+}
+
+func (s *PollTestSuite) TestPoll_missing() {
+	_, err := filestore.Poll(context.Background(), s.fs, "nope", filestore.WithClock(s.clock))
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+
+	_, err = filestore.Poll(context.Background(), s.fs, "inbox/a.txt", filestore.WithClock(s.clock))
+	s.Require().Error(err)
+}
+
+// tick lets the poller make one more pass over the store.
+func (s *PollTestSuite) tick() {
+	s.clock.BlockUntil(1)
+	s.clock.Advance(time.Minute)
+}
+
+func (s *PollTestSuite) assertEvents(events <-chan filestore.Event, expected ...filestore.Event) {
+	for _, expectedEvent := range expected {
+		select {
+		case event, ok := <-events:
+			s.Require().True(ok, "Channel closed while waiting for %v", expectedEvent)
+			s.Require().Equal(expectedEvent, event)
+		case <-time.After(5 * time.Second):
+			s.Require().Fail("Timed out waiting for event", "%v", expectedEvent)
+		}
+	}
+
+	// Once the poller is waiting on the clock again, it's done reporting this pass.
+	s.clock.BlockUntil(1)
+	s.Require().Empty(events, "Should not report anything else")
+}
+
+func (s *PollTestSuite) assertClosed(events <-chan filestore.Event) {
+	select {
+	case _, ok := <-events:
+		s.Require().False(ok, "Should not report anything else")
+	case <-time.After(5 * time.Second):
+		s.Require().Fail("Timed out waiting for the channel to close")
+	}
+}
@@ -0,0 +1,67 @@
+package filestore
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+func init() {
+	opener := func(u *url.URL) (FS, error) {
+		if u.Host == "" {
+			return nil, fmt.Errorf("missing host")
+		}
+		return openPostgres(u.String())
+	}
+	RegisterScheme("postgres", opener)
+	RegisterScheme("postgresql", opener)
+}
+
+// postgresDrivers are the names that the popular Postgres drivers register themselves as (jackc/pgx's
+// stdlib package and lib/pq, respectively).
+var postgresDrivers = []string{"pgx", "postgres"}
+
+// postgresDialect stores file contents as bytea, which Postgres caps at 1GB per value.
+var postgresDialect = sqlDialect{name: "postgres", blobType: "BYTEA", numberedParams: true}
+
+// Postgres creates a file store that keeps files inside the Postgres database you already run, so small
+// deployments don't need a separate bucket or disk just for uploads. Files and directories live in the
+// "filestore_files" table (created if it doesn't exist yet), and every Write, Move, and Remove is a single
+// transaction. See SQLFS for more details.
+//
+// The store uses the *sql.DB you give it rather than opening its own, so you can share your existing
+// connection pool. Closing the store does NOT close the database; that's still up to you. You can supply
+// the WithClock() option to control the modification/creation times recorded for files in this store.
+//
+// Example:
+//
+//	db, err := sql.Open("pgx", "postgres://app@localhost:5432/app")
+//	...
+//	uploads, err := filestore.Postgres(db)
+//	if err != nil {
+//	    // handle your error nicely
+//	}
+//	avatar, err := uploads.Write("avatars/dude.png")
+func Postgres(db *sql.DB, opts ...Option) (*SQLFS, error) {
+	return newSQLFS(db, postgresDialect, false, opts)
+}
+
+// openPostgres connects to the database at the given URL using whichever Postgres driver is registered. Since
+// we opened the database ourselves, closing the store closes the database as well.
+func openPostgres(dataSource string) (*SQLFS, error) {
+	driver := registeredDriver(postgresDrivers)
+	if driver == "" {
+		return nil, newPathError("postgres", "open", "", errors.New("no Postgres driver registered; import one such as github.com/jackc/pgx/v5/stdlib"))
+	}
+	db, err := sql.Open(driver, dataSource)
+	if err != nil {
+		return nil, newPathError("postgres", "open", "", err)
+	}
+	store, err := newSQLFS(db, postgresDialect, true, nil)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,36 @@
+package filestore_test
+
+import (
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+// The Postgres store shares all of its behavior w/ the SQLite store, so these tests only cover
+// what's specific to Postgres; there's no Postgres server in the test environment to run the rest against.
+type PostgresTestSuite struct {
+	suite.Suite
+}
+
+func TestPostgresTestSuite(t *testing.T) {
+	suite.Run(t, &PostgresTestSuite{})
+}
+
+func (s *PostgresTestSuite) TestRebind() {
+	s.Require().Equal("SELECT data FROM files WHERE path = $1", filestore.RebindPostgres("SELECT data FROM files WHERE path = ?"))
+	s.Require().Equal("UPDATE t SET a = $1 || substr(a, $2) WHERE b = $3",
+		filestore.RebindPostgres("UPDATE t SET a = ? || substr(a, ?) WHERE b = ?"))
+	s.Require().Equal("DELETE FROM files", filestore.RebindPostgres("DELETE FROM files"))
+}
+
+func (s *PostgresTestSuite) TestOpen() {
+	s.Require().Contains(filestore.Schemes(), "postgres")
+	s.Require().Contains(filestore.Schemes(), "postgresql")
+
+	_, err := filestore.Open("postgres:///app")
+	s.Require().Error(err, "Should require a host")
+
+	_, err = filestore.Open("postgres://app@localhost:5432/app")
+	s.Require().ErrorContains(err, "no Postgres driver registered")
+}
@@ -0,0 +1,64 @@
+package filestore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Put stores everything in the reader as a new file in the given directory whose name is the SHA-256
+// hash of its contents plus the given extension (e.g. "9f86d081...0a08.png"). It returns the path of
+// that file. Since the name is derived from the contents, a path returned by Put() always refers to
+// the exact same bytes, which makes these files safe to cache forever (e.g. for static assets served
+// w/ "Cache-Control: immutable"). Putting the same contents twice returns the same path w/o storing
+// another copy.
+//
+// The data is written to a temporary file in the same directory first and then moved into place, so
+// readers never see a partially written file under its final name. The extension may be given w/ or
+// w/o its leading dot; use "" for no extension.
+//
+// Example:
+//
+//	assetPath, err := filestore.Put(assets, "css", "css", bundle)
+//	if err != nil {
+//	    // handle error
+//	}
+//	fmt.Println(assetPath) // "css/2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae.css"
+func Put(fsys FS, dir string, ext string, r io.Reader) (string, error) {
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	if strings.ContainsAny(ext, "/\\") {
+		return "", fmt.Errorf("filestore: put: extension contains path separator: %s", ext)
+	}
+
+	file, tempPath, err := TempFile(fsys, dir, ".put-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("filestore: put: %w", err)
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(file, hash), r); err != nil {
+		_ = file.Close()
+		_ = fsys.Remove(tempPath)
+		return "", fmt.Errorf("filestore: put: %w", err)
+	}
+	if err = file.Close(); err != nil {
+		_ = fsys.Remove(tempPath)
+		return "", fmt.Errorf("filestore: put: %w", err)
+	}
+
+	// Identical contents always get the same name, so if the file is already there, we're done.
+	filePath := path.Join(dir, hex.EncodeToString(hash.Sum(nil))+ext)
+	if fsys.Exists(filePath) {
+		_ = fsys.Remove(tempPath)
+		return filePath, nil
+	}
+	if err = fsys.Move(tempPath, filePath); err != nil {
+		_ = fsys.Remove(tempPath)
+		return "", fmt.Errorf("filestore: put: %w", err)
+	}
+	return filePath, nil
+}
@@ -0,0 +1,65 @@
+package filestore_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type PutTestSuite struct {
+	suite.Suite
+}
+
+func TestPutTestSuite(t *testing.T) {
+	suite.Run(t, &PutTestSuite{})
+}
+
+func (s *PutTestSuite) TestPut() {
+	fs := filestore.Memory()
+
+	// echo -n "abide" | sha256sum
+	const hash = "072469151f6451023b0cbcab21730504d661cf6ca6958135f2fc7c9a59174ff8"
+	filePath, err := filestore.Put(fs, "assets/css", "css", strings.NewReader("abide"))
+	s.Require().NoError(err)
+	s.Require().Equal("assets/css/"+hash+".css", filePath)
+	s.Require().Equal("abide", s.read(fs, filePath))
+
+	// The same contents should always end up w/ the same name.
+	again, err := filestore.Put(fs, "assets/css", ".css", strings.NewReader("abide"))
+	s.Require().NoError(err)
+	s.Require().Equal(filePath, again)
+
+	other, err := filestore.Put(fs, "assets/css", "css", strings.NewReader("abide, man"))
+	s.Require().NoError(err)
+	s.Require().NotEqual(filePath, other)
+
+	files, err := fs.List("assets/css")
+	s.Require().NoError(err)
+	s.Require().Len(files, 2, "Should not leave any temp files behind")
+
+	noExt, err := filestore.Put(fs, ".", "", strings.NewReader("abide"))
+	s.Require().NoError(err)
+	s.Require().Equal(hash, noExt)
+}
+
+func (s *PutTestSuite) TestPut_failure() {
+	fs := filestore.Memory()
+
+	_, err := filestore.Put(fs, "assets", "css", iotest.ErrReader(errors.New("nope")))
+	s.Require().Error(err)
+	files, _ := fs.List("assets")
+	s.Require().Empty(files, "Should clean up the temp file when reading fails")
+
+	_, err = filestore.Put(fs, "assets", "../css", strings.NewReader("abide"))
+	s.Require().Error(err, "Extensions should not be able to change the directory")
+}
+
+func (s *PutTestSuite) read(fs filestore.FS, name string) string {
+	content, err := readString(fs, name)
+	s.Require().NoError(err)
+	return content
+}
@@ -0,0 +1,683 @@
+package filestore
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRedisKeyPrefix is the prefix of every key that a Redis store creates unless you specify otherwise.
+const defaultRedisKeyPrefix = "filestore:"
+
+// redisScanCount is the number of keys we ask Redis to look at during each SCAN call.
+const redisScanCount = "1000"
+
+func init() {
+	opener := func(u *url.URL) (FS, error) {
+		if u.Host == "" {
+			return nil, fmt.Errorf("missing host")
+		}
+		var opts []Option
+		if u.User != nil {
+			password, _ := u.User.Password()
+			opts = append(opts, WithRedisAuth(u.User.Username(), password))
+		}
+		if db := strings.Trim(u.Path, "/"); db != "" {
+			number, err := strconv.Atoi(db)
+			if err != nil {
+				return nil, fmt.Errorf("invalid database number: %s", db)
+			}
+			opts = append(opts, WithRedisDB(number))
+		}
+		if prefix, ok := u.Query()["prefix"]; ok {
+			opts = append(opts, WithKeyPrefix(prefix[0]))
+		}
+		if ttl := u.Query().Get("ttl"); ttl != "" {
+			duration, err := time.ParseDuration(ttl)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ttl: %w", err)
+			}
+			opts = append(opts, WithTTL(duration))
+		}
+		if u.Scheme == "rediss" {
+			opts = append(opts, WithRedisTLS())
+		}
+		return Redis(u.Host, opts...), nil
+	}
+	RegisterScheme("redis", opener)
+	RegisterScheme("rediss", opener)
+}
+
+// redisOptions contains the settings that only apply to Redis stores.
+type redisOptions struct {
+	keyPrefix *string
+	ttl       time.Duration
+	username  string
+	password  string
+	auth      func(ctx context.Context) (string, string, error)
+	db        int
+	tls       bool
+}
+
+// WithKeyPrefix sets the string that a Redis store prepends to the key of every file it stores, so that
+// several stores (or other applications) can share the same Redis database. Defaults to "filestore:".
+func WithKeyPrefix(prefix string) Option {
+	return func(opts *options) {
+		opts.redis.keyPrefix = &prefix
+	}
+}
+
+// WithTTL makes every file that a Redis store writes expire this long after it was last written. Moving
+// a file does not reset the clock. By default, files stick around until you remove them.
+func WithTTL(ttl time.Duration) Option {
+	return func(opts *options) {
+		if ttl > 0 {
+			opts.redis.ttl = ttl
+		}
+	}
+}
+
+// WithRedisAuth sets the credentials that a Redis store uses to authenticate each connection. Leave the
+// username empty if your server only uses the "requirepass" password rather than ACL users.
+func WithRedisAuth(username string, password string) Option {
+	return func(opts *options) {
+		opts.redis.username = username
+		opts.redis.password = password
+		opts.redis.auth = nil
+	}
+}
+
+// WithRedisAuthProvider makes a Redis store fetch the username and password it authenticates w/ from the
+// provider rather than using static ones, so rotating the password doesn't require a restart. We call the
+// provider every time we open a new connection (connections that are already authenticated stay that way),
+// so make it cheap or cache the secret yourself.
+//
+// Example:
+//
+//	scratch := filestore.Redis("redis.internal:6379", filestore.WithRedisAuthProvider(func(ctx context.Context) (string, string, error) {
+//	    password, err := os.ReadFile("/var/run/secrets/redis/password")
+//	    return "scratch", strings.TrimSpace(string(password)), err
+//	}))
+func WithRedisAuthProvider(provider func(ctx context.Context) (username string, password string, err error)) Option {
+	return func(opts *options) {
+		if provider != nil {
+			opts.redis.auth = provider
+		}
+	}
+}
+
+// WithRedisDB selects which of the server's numbered databases a Redis store uses. Defaults to 0.
+func WithRedisDB(db int) Option {
+	return func(opts *options) {
+		opts.redis.db = db
+	}
+}
+
+// WithRedisTLS makes a Redis store connect to the server over TLS. Supply WithRootCAs(),
+// WithClientCertificate(), or WithTLSConfig() as well if the server's certificate isn't signed by a
+// public authority or it requires mutual TLS.
+func WithRedisTLS() Option {
+	return func(opts *options) {
+		opts.redis.tls = true
+	}
+}
+
+// Redis creates a file store whose files are keys in the Redis server at the given address (e.g.
+// "localhost:6379"). It's meant to be scratch space that several replicas of a service can share (partial
+// uploads, rendered exports, etc.) while still using the familiar FS API. Each file is a hash whose key is
+// the file's full path (e.g. "filestore:/exports/2022.csv"), so you can also poke around w/ redis-cli.
+//
+// Much like S3, Redis has no real directories, so a directory exists as long as there is at least one
+// file "in" it. Writing a file implicitly "creates" all of its parent directories, and removing (or
+// expiring) the last file in a directory makes the directory disappear. Listing and removing directories
+// SCAN the keys w/ the directory's prefix, which is fine for scratch space but gets slower as the
+// database grows.
+//
+// Files are kept in memory until you close the WriterFile, at which point they are stored (along w/ their
+// expiration, if any) in a single transaction. Readers load the entire file when you Read() it, so this
+// is best suited for small to medium sized files. Moving a directory renames all of its files in a single
+// transaction, so the store does not support Redis Cluster, where those keys could live on different nodes.
+//
+// You can supply WithTTL(), WithKeyPrefix(), WithRedisAuth() (or WithRedisAuthProvider()), WithRedisDB(),
+// WithRedisTLS(), and WithClock() to customize the store. You can also Open() a URL like
+// "redis://:password@localhost:6379/0?ttl=1h&prefix=scratch:" (or "rediss://..." to connect over TLS).
+//
+// Example:
+//
+//	scratch := filestore.Redis("localhost:6379", filestore.WithTTL(time.Hour))
+//	defer filestore.Shutdown(ctx, scratch)
+//
+//	output, err := scratch.Write("exports/2022.csv") // expires in an hour
+//	if err != nil {
+//	    // handle your error nicely
+//	}
+//	output.Write(data)
+//	output.Close()
+func Redis(addr string, opts ...Option) *RedisFS {
+	options := newOptions(opts)
+
+	prefix := defaultRedisKeyPrefix
+	if options.redis.keyPrefix != nil {
+		prefix = *options.redis.keyPrefix
+	}
+	client := &redisClient{
+		addr:        addr,
+		username:    options.redis.username,
+		password:    options.redis.password,
+		auth:        options.redis.auth,
+		db:          options.redis.db,
+		dialTimeout: 10 * time.Second,
+	}
+	if options.redis.tls {
+		client.tls = options.transport.tlsClientConfig(&tls.Config{})
+	}
+	return &RedisFS{client: client, prefix: prefix, ttl: options.redis.ttl, clock: options.clock, basePath: "/"}
+}
+
+// RedisFS is a file store backed by keys in a Redis database. See Redis() for more details.
+//
+// A RedisFS is safe for concurrent use by multiple goroutines.
+type RedisFS struct {
+	client   *redisClient
+	prefix   string
+	ttl      time.Duration
+	clock    Clock
+	basePath string
+
+	// ctx is the context of the request this store was derived for via ForRequest(), if any.
+	ctx context.Context
+}
+
+// resolve converts a path relative to this FS' working directory into an absolute path within the store.
+func (r RedisFS) resolve(filePath string) (string, error) {
+	return resolvePath(r.basePath, filePath)
+}
+
+// key is the Redis key of the file w/ the given absolute path.
+func (r RedisFS) key(fullPath string) string {
+	return r.prefix + fullPath
+}
+
+// childPattern is the SCAN pattern that matches the keys of every file beneath the given absolute path.
+func (r RedisFS) childPattern(fullPath string) string {
+	return escapeRedisPattern(r.key(strings.TrimSuffix(fullPath, "/")+"/")) + "*"
+}
+
+// WorkingDirectory returns the current FS context's path/directory.
+func (r RedisFS) WorkingDirectory() string {
+	return path.Clean(r.basePath)
+}
+
+// ChangeDirectory returns a new FS that is rooted in the given subdirectory of this FS. The new
+// instance shares the same connections as this one.
+func (r RedisFS) ChangeDirectory(dir string) FS {
+	r.basePath = joinPath(r.basePath, dir)
+	return &r
+}
+
+// withContext returns a copy of this store that carries the request's context; see ForRequest().
+func (r RedisFS) withContext(ctx context.Context) FS {
+	r.ctx = ctx
+	return &r
+}
+
+func (r RedisFS) requestContext() context.Context {
+	return contextOrBackground(r.ctx)
+}
+
+// Stat fetches metadata about the file w/o actually opening it for reading/writing.
+func (r RedisFS) Stat(filePath string) (FileInfo, error) {
+	fullPath, err := r.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("redis", "stat", filePath, err)
+	}
+	info, err := r.stat(r.requestContext(), fullPath)
+	if err != nil {
+		return nil, newPathError("redis", "stat", filePath, err)
+	}
+	return info, nil
+}
+
+func (r RedisFS) stat(ctx context.Context, fullPath string) (redisFileInfo, error) {
+	if fullPath == "/" {
+		return redisFileInfo{name: "/", dir: true}, nil
+	}
+
+	reply, err := r.client.do(ctx, "HMGET", r.key(fullPath), "size", "mod")
+	if err != nil {
+		return redisFileInfo{}, err
+	}
+	if info, ok := redisInfoFromFields(path.Base(fullPath), reply); ok {
+		return info, nil
+	}
+
+	// There's no file, but it could still be a directory if there are files "in" it.
+	children, err := r.scan(ctx, r.childPattern(fullPath), 1)
+	if err != nil {
+		return redisFileInfo{}, err
+	}
+	if len(children) == 0 {
+		return redisFileInfo{}, fs.ErrNotExist
+	}
+	return redisFileInfo{name: path.Base(fullPath), dir: true}, nil
+}
+
+// Exists returns true when the file/directory already exits in the store.
+func (r RedisFS) Exists(filePath string) bool {
+	_, err := r.Stat(filePath)
+	return err == nil
+}
+
+// Read opens the given file at the given path, providing you with an io.Reader that you can use to
+// stream bytes from it. The entire file is loaded when you call Read(), so the reader sees a snapshot
+// of the file's contents regardless of any writes that happen afterwards.
+func (r RedisFS) Read(filePath string) (ReaderFile, error) {
+	fullPath, err := r.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("redis", "open", filePath, err)
+	}
+
+	ctx := r.requestContext()
+	reply, err := r.client.do(ctx, "HGET", r.key(fullPath), "data")
+	if err != nil {
+		return nil, newPathError("redis", "open", filePath, err)
+	}
+	if data, _ := reply.([]byte); data != nil {
+		return newBytesReaderFile(data), nil
+	}
+
+	// There's no file, so figure out whether it's a directory or just missing.
+	info, err := r.stat(ctx, fullPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) || (err == nil && !info.dir):
+		// The latter means that someone wrote the file after we tried to read it. Since we
+		// would have failed had we been a bit faster, it's fine to treat it as missing.
+		return nil, newPathError("redis", "open", filePath, fs.ErrNotExist)
+	case err != nil:
+		return nil, newPathError("redis", "open", filePath, err)
+	default:
+		return nil, newPathError("redis", "open", filePath, errIsDirectory)
+	}
+}
+
+// Write opens the given file at the given path for writing. The resulting file behaves like a standard
+// io.Writer/At, but the file is not created/replaced until you close it. Should the file already exist,
+// this will overwrite its entire contents (and restart its TTL, if any).
+func (r RedisFS) Write(filePath string) (WriterFile, error) {
+	fullPath, err := r.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("redis", "write", filePath, err)
+	}
+	if fullPath == "/" {
+		return nil, newPathError("redis", "write", filePath, errIsDirectory)
+	}
+	return newRedisWriterFile(r, fullPath), nil
+}
+
+// store writes the file's data (and expiration) in a single transaction.
+func (r RedisFS) store(fullPath string, data []byte) error {
+	ctx := r.requestContext()
+	if err := r.checkParents(ctx, fullPath); err != nil {
+		return err
+	}
+	children, err := r.scan(ctx, r.childPattern(fullPath), 1)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("trying to write directory like a file")
+	}
+
+	key := r.key(fullPath)
+	mod := strconv.FormatInt(r.clock.Now().UnixNano(), 10)
+	commands := [][]string{
+		{"DEL", key},
+		{"HSET", key, "data", string(data), "size", strconv.Itoa(len(data)), "mod", mod},
+	}
+	if r.ttl > 0 {
+		commands = append(commands, []string{"PEXPIRE", key, strconv.FormatInt(r.ttl.Milliseconds(), 10)})
+	}
+	_, err = r.client.transaction(ctx, commands)
+	return err
+}
+
+// checkParents fails when any of the path's parent directories is actually a file.
+func (r RedisFS) checkParents(ctx context.Context, fullPath string) error {
+	args := []string{"EXISTS"}
+	for dir := path.Dir(fullPath); dir != "/"; dir = path.Dir(dir) {
+		args = append(args, r.key(dir))
+	}
+	if len(args) == 1 {
+		return nil
+	}
+	reply, err := r.client.do(ctx, args...)
+	if err != nil {
+		return err
+	}
+	if count, _ := reply.(int64); count > 0 {
+		return fmt.Errorf("%s: not a directory", path.Dir(fullPath))
+	}
+	return nil
+}
+
+// List performs the equivalent of the "ls" command. It returns a slice of
+// all files and directories found in the target dirPath.
+//
+// You can optionally provide a set of filters to limit which files/directories
+// are included in the final set. When the filters require a name prefix (e.g.
+// WithPrefix() or WithPattern("2022-*")), we only SCAN for keys with that prefix.
+func (r RedisFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	fullPath, err := r.resolve(dirPath)
+	if err != nil {
+		return nil, newPathError("redis", "list files", dirPath, err)
+	}
+
+	ctx := r.requestContext()
+	dir, err := r.stat(ctx, fullPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, newPathError("redis", "list files", dirPath, err)
+	case !dir.dir:
+		return nil, newPathError("redis", "list files", dirPath, ErrNotDirectory)
+	}
+
+	dirPrefix := r.key(strings.TrimSuffix(fullPath, "/") + "/")
+	keys, err := r.scan(ctx, escapeRedisPattern(dirPrefix+filtersPrefix(filters))+"*", 0)
+	if err != nil {
+		return nil, newPathError("redis", "list files", dirPath, err)
+	}
+
+	var infos []FileInfo
+	var names []string
+	var commands [][]string
+	dirs := map[string]bool{}
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, dirPrefix)
+		if slash := strings.IndexByte(name, '/'); slash >= 0 {
+			if name = name[:slash]; !dirs[name] {
+				dirs[name] = true
+				infos = append(infos, redisFileInfo{name: name, dir: true})
+			}
+			continue
+		}
+		names = append(names, name)
+		commands = append(commands, []string{"HMGET", key, "size", "mod"})
+	}
+
+	// Fetch the size/mod time of every file in a single round trip.
+	if len(commands) > 0 {
+		replies, err := r.client.pipeline(ctx, commands)
+		if err != nil {
+			return nil, newPathError("redis", "list files", dirPath, err)
+		}
+		for i, reply := range replies {
+			if err, ok := reply.(redisError); ok {
+				return nil, newPathError("redis", "list files", dirPath, err)
+			}
+			// Files that expired since we scanned for them simply don't show up.
+			if info, ok := redisInfoFromFields(names[i], reply); ok {
+				infos = append(infos, info)
+			}
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	results, err := filterFiles(infos, filters)
+	if err != nil {
+		return nil, newPathError("redis", "list files", dirPath, err)
+	}
+	return results, nil
+}
+
+// Remove deletes the given file/directory and any of its children. Removing the root
+// of the store deletes every file under the store's key prefix.
+func (r RedisFS) Remove(fileOrDirPath string) error {
+	fullPath, err := r.resolve(fileOrDirPath)
+	if err != nil {
+		return newPathError("redis", "remove", fileOrDirPath, err)
+	}
+
+	ctx := r.requestContext()
+	keys, err := r.scan(ctx, r.childPattern(fullPath), 0)
+	if err != nil {
+		return newPathError("redis", "remove", fileOrDirPath, err)
+	}
+	if fullPath != "/" {
+		keys = append(keys, r.key(fullPath))
+	}
+
+	// Delete the keys in batches so that huge directories don't turn into one giant command.
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > 500 {
+			batch = batch[:500]
+		}
+		keys = keys[len(batch):]
+		if _, err := r.client.do(ctx, append([]string{"DEL"}, batch...)...); err != nil {
+			return newPathError("redis", "remove", fileOrDirPath, err)
+		}
+	}
+	return nil
+}
+
+// Move takes an existing file at the fromPath location and moves it to another
+// spot in this file system; the toPath location. Files keep their remaining TTL (if
+// any), and moving a directory renames all of its files in a single transaction.
+func (r RedisFS) Move(fromPath string, toPath string) error {
+	fromFullPath, err := r.resolve(fromPath)
+	if err != nil {
+		return newPathError("redis", "move", fromPath, err)
+	}
+	toFullPath, err := r.resolve(toPath)
+	if err != nil {
+		return newPathError("redis", "move", toPath, err)
+	}
+
+	ctx := r.requestContext()
+	from, err := r.stat(ctx, fromFullPath)
+	if err != nil {
+		return newPathError("redis", "move", fromPath, err)
+	}
+	if fromFullPath == toFullPath {
+		return nil
+	}
+	if fromFullPath == "/" || strings.HasPrefix(toFullPath, fromFullPath+"/") {
+		return newPathError("redis", "move", fromPath, errors.New("can not move a directory inside of itself"))
+	}
+
+	// Mirror the rules for os.Rename(). You can overwrite an existing file with another
+	// file, but you can never replace an existing directory or overwrite a file w/ a directory.
+	to, err := r.stat(ctx, toFullPath)
+	switch {
+	case err == nil && to.dir:
+		return newPathError("redis", "move", toPath, fs.ErrExist)
+	case err == nil && from.dir:
+		return newPathError("redis", "move", toPath, ErrNotDirectory)
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return newPathError("redis", "move", toPath, err)
+	}
+	if err := r.checkParents(ctx, toFullPath); err != nil {
+		return newPathError("redis", "move", fromPath, err)
+	}
+
+	if !from.dir {
+		if _, err := r.client.do(ctx, "RENAME", r.key(fromFullPath), r.key(toFullPath)); err != nil {
+			return newPathError("redis", "move", fromPath, err)
+		}
+		return nil
+	}
+
+	keys, err := r.scan(ctx, r.childPattern(fromFullPath), 0)
+	if err != nil {
+		return newPathError("redis", "move", fromPath, err)
+	}
+	fromKey, toKey := r.key(fromFullPath), r.key(toFullPath)
+	var commands [][]string
+	for _, key := range keys {
+		commands = append(commands, []string{"RENAME", key, toKey + strings.TrimPrefix(key, fromKey)})
+	}
+	if _, err := r.client.transaction(ctx, commands); err != nil {
+		return newPathError("redis", "move", fromPath, err)
+	}
+	return nil
+}
+
+// Ping verifies that we can still talk to the Redis server.
+func (r RedisFS) Ping(ctx context.Context) error {
+	if _, err := r.client.do(ctx, "PING"); err != nil {
+		return newPathError("redis", "ping", "", err)
+	}
+	return nil
+}
+
+// Close closes the store's connections to the Redis server. Since every store derived from this one
+// (e.g. via ChangeDirectory()) shares those connections, none of them work anymore either.
+func (r RedisFS) Close(_ context.Context) error {
+	if err := r.client.close(); err != nil {
+		return newPathError("redis", "close", "", err)
+	}
+	return nil
+}
+
+// scan finds the keys matching the pattern, stopping once it has found the given number of them (or
+// all of them when the limit is 0). Keys may be missed or repeated if they change during the scan.
+func (r RedisFS) scan(ctx context.Context, pattern string, limit int) ([]string, error) {
+	var keys []string
+	seen := map[string]bool{}
+	cursor := "0"
+	for {
+		reply, err := r.client.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", redisScanCount)
+		if err != nil {
+			return nil, err
+		}
+		page, _ := reply.([]any)
+		if len(page) != 2 {
+			return nil, fmt.Errorf("scan: malformed reply")
+		}
+		next, _ := page[0].([]byte)
+		batch, _ := page[1].([]any)
+		for _, value := range batch {
+			key, _ := value.([]byte)
+			if seen[string(key)] {
+				continue
+			}
+			seen[string(key)] = true
+			keys = append(keys, string(key))
+			if limit > 0 && len(keys) >= limit {
+				return keys, nil
+			}
+		}
+		if cursor = string(next); cursor == "0" || cursor == "" {
+			return keys, nil
+		}
+	}
+}
+
+// escapeRedisPattern escapes the characters that have special meaning in the glob-style patterns used by
+// SCAN's MATCH option, so that the result only matches the literal string.
+func escapeRedisPattern(value string) string {
+	var builder strings.Builder
+	for _, ch := range value {
+		if strings.ContainsRune(`*?[]\`, ch) {
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(ch)
+	}
+	return builder.String()
+}
+
+// redisInfoFromFields builds the info for a file from the reply to "HMGET key size mod". It returns false
+// when the file doesn't exist.
+func redisInfoFromFields(name string, reply any) (redisFileInfo, bool) {
+	fields, _ := reply.([]any)
+	if len(fields) != 2 {
+		return redisFileInfo{}, false
+	}
+	size, _ := fields[0].([]byte)
+	mod, _ := fields[1].([]byte)
+	if size == nil {
+		return redisFileInfo{}, false
+	}
+	info := redisFileInfo{name: name}
+	info.size, _ = strconv.ParseInt(string(size), 10, 64)
+	if nanos, err := strconv.ParseInt(string(mod), 10, 64); err == nil {
+		info.modTime = time.Unix(0, nanos)
+	}
+	return info, true
+}
+
+type redisFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+// Name returns the base name of the file/directory.
+func (info redisFileInfo) Name() string {
+	return info.name
+}
+
+// Size returns the number of bytes in the file (0 for directories).
+func (info redisFileInfo) Size() int64 {
+	return info.size
+}
+
+// Mode returns the file mode bits; files are read/write and directories are also executable.
+func (info redisFileInfo) Mode() fs.FileMode {
+	if info.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+// ModTime returns the time that the file was last written (zero for directories).
+func (info redisFileInfo) ModTime() time.Time {
+	return info.modTime
+}
+
+// IsDir returns true for directories (key prefixes).
+func (info redisFileInfo) IsDir() bool {
+	return info.dir
+}
+
+// Sys returns nil; there's no underlying data source for Redis files.
+func (info redisFileInfo) Sys() any {
+	return nil
+}
+
+// redisWriterFile buffers all writes privately and stores them in Redis on Close().
+type redisWriterFile struct {
+	*bufferWriter
+	fs       RedisFS
+	fullPath string
+}
+
+func newRedisWriterFile(fs RedisFS, fullPath string) *redisWriterFile {
+	file := &redisWriterFile{fs: fs, fullPath: fullPath}
+	file.bufferWriter = newBufferWriter("redis", file.store)
+	return file
+}
+
+// store saves everything you wrote in a single transaction so that subsequent readers can see it.
+func (w *redisWriterFile) store(data []byte) error {
+	if err := w.fs.store(w.fullPath, data); err != nil {
+		return fmt.Errorf("redis fs: close: %s: %w", w.fullPath, err)
+	}
+	return nil
+}
+
+var _ FS = RedisFS{}
+var _ Closer = RedisFS{}
+var _ Pinger = RedisFS{}
+var _ requestBinder = RedisFS{}
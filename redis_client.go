@@ -0,0 +1,318 @@
+package filestore
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redisMaxIdleConns is the number of connections that a Redis store keeps open between commands.
+const redisMaxIdleConns = 8
+
+// redisClient is a minimal client for the handful of Redis commands that RedisFS needs. Much like our
+// S3 client, we roll our own rather than pull in a full-featured Redis library; we only ever send plain
+// commands and read back RESP2 replies, which takes a lot less code than you'd think.
+type redisClient struct {
+	addr     string
+	username string
+	password string
+	// auth fetches the username/password for each new connection when they come from a provider.
+	auth        func(ctx context.Context) (string, string, error)
+	db          int
+	dialTimeout time.Duration
+	// tls is the config for connecting over TLS, or nil to use plain TCP.
+	tls *tls.Config
+
+	mutex  sync.Mutex
+	idle   []*redisConn
+	closed bool
+}
+
+// redisConn is a single connection to the server along w/ the buffered reader for its replies.
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// redisError is an error reply sent by the server (e.g. "ERR no such key").
+type redisError string
+
+func (err redisError) Error() string {
+	return string(err)
+}
+
+// errRedisClosed is returned when you send commands after closing the client.
+var errRedisClosed = errors.New("redis client closed")
+
+// do sends a single command and returns its reply. Replies are decoded as follows: simple strings are a
+// string, integers are an int64, bulk strings are a []byte (nil when the server sends a null), arrays are
+// a []any (nil when the server sends a null), and error replies are returned as a redisError.
+func (c *redisClient) do(ctx context.Context, args ...string) (any, error) {
+	replies, err := c.pipeline(ctx, [][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := replies[0].(redisError); ok {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// transaction sends all of the commands wrapped in MULTI/EXEC so that Redis runs them atomically, w/o
+// commands from any other client in between. It returns the reply of each command in the transaction.
+// Should any of them fail, the error reply of the first one that did is returned as the error.
+func (c *redisClient) transaction(ctx context.Context, commands [][]string) ([]any, error) {
+	wrapped := make([][]string, 0, len(commands)+2)
+	wrapped = append(wrapped, []string{"MULTI"})
+	wrapped = append(wrapped, commands...)
+	wrapped = append(wrapped, []string{"EXEC"})
+
+	replies, err := c.pipeline(ctx, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	// Errors while queueing (e.g. a typo in the command name) show up before EXEC and abort the transaction.
+	for _, reply := range replies[:len(replies)-1] {
+		if err, ok := reply.(redisError); ok {
+			return nil, err
+		}
+	}
+	results, ok := replies[len(replies)-1].([]any)
+	if !ok {
+		return nil, fmt.Errorf("transaction aborted")
+	}
+	for _, result := range results {
+		if err, ok := result.(redisError); ok {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// pipeline sends all of the commands at once and then reads back each of their replies, so a batch of
+// commands only costs a single round trip. Error replies are returned in the slice rather than as the error.
+func (c *redisClient) pipeline(ctx context.Context, commands [][]string) ([]any, error) {
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := conn.roundTrip(ctx, commands)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	c.release(conn)
+	return replies, nil
+}
+
+// conn grabs an idle connection from the pool, dialing a new one if there aren't any.
+func (c *redisClient) conn(ctx context.Context) (*redisConn, error) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil, errRedisClosed
+	}
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mutex.Unlock()
+		return conn, nil
+	}
+	c.mutex.Unlock()
+
+	username, password := c.username, c.password
+	if c.auth != nil {
+		var err error
+		if username, password, err = c.auth(ctx); err != nil {
+			return nil, fmt.Errorf("fetch credentials: %w", err)
+		}
+	}
+
+	dialer := net.Dialer{Timeout: c.dialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.tls != nil {
+		config := c.tls
+		if config.ServerName == "" {
+			config = config.Clone()
+			config.ServerName, _, _ = net.SplitHostPort(c.addr)
+		}
+		tlsConn := tls.Client(netConn, config)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = netConn.Close()
+			return nil, err
+		}
+		netConn = tlsConn
+	}
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn)}
+
+	// Authenticate and pick the database before anyone else gets to use the connection.
+	var setup [][]string
+	switch {
+	case username != "":
+		setup = append(setup, []string{"AUTH", username, password})
+	case password != "":
+		setup = append(setup, []string{"AUTH", password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) == 0 {
+		return conn, nil
+	}
+	replies, err := conn.roundTrip(ctx, setup)
+	if err == nil {
+		for _, reply := range replies {
+			if replyErr, ok := reply.(redisError); ok {
+				err = replyErr
+				break
+			}
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// release puts a healthy connection back in the pool (or closes it if the pool is already full).
+func (c *redisClient) release(conn *redisConn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed || len(c.idle) >= redisMaxIdleConns {
+		_ = conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+// close closes every idle connection; connections currently in use are closed once they're released.
+func (c *redisClient) close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var firstErr error
+	for _, conn := range c.idle {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.idle = nil
+	c.closed = true
+	return firstErr
+}
+
+// roundTrip writes the commands and reads one reply for each of them. The context's deadline (if any)
+// applies to the whole exchange, and cancelling the context interrupts it.
+func (conn *redisConn) roundTrip(ctx context.Context, commands [][]string) ([]any, error) {
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	var buffer []byte
+	for _, args := range commands {
+		buffer = appendRedisCommand(buffer, args)
+	}
+	if _, err := conn.Write(buffer); err != nil {
+		return nil, redisContextError(ctx, err)
+	}
+
+	replies := make([]any, len(commands))
+	for i := range commands {
+		reply, err := readRedisReply(conn.reader)
+		if err != nil {
+			return nil, redisContextError(ctx, err)
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// redisContextError prefers the context's error over the I/O error it caused by interrupting a round trip.
+func redisContextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// appendRedisCommand encodes the command as a RESP array of bulk strings.
+func appendRedisCommand(buffer []byte, args []string) []byte {
+	buffer = append(buffer, '*')
+	buffer = strconv.AppendInt(buffer, int64(len(args)), 10)
+	buffer = append(buffer, '\r', '\n')
+	for _, arg := range args {
+		buffer = append(buffer, '$')
+		buffer = strconv.AppendInt(buffer, int64(len(arg)), 10)
+		buffer = append(buffer, '\r', '\n')
+		buffer = append(buffer, arg...)
+		buffer = append(buffer, '\r', '\n')
+	}
+	return buffer
+}
+
+// readRedisReply decodes the next RESP2 reply; see redisClient.do() for the types that each reply becomes.
+func readRedisReply(reader *bufio.Reader) (any, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply: %q", line)
+	}
+	kind, value := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return value, nil
+	case '-':
+		return redisError(value), nil
+	case ':':
+		return strconv.ParseInt(value, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return []byte(nil), err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	case '*':
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return []any(nil), err
+		}
+		values := make([]any, count)
+		for i := range values {
+			if values[i], err = readRedisReply(reader); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("malformed reply: %q", line)
+	}
+}
@@ -0,0 +1,306 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type RedisTestSuite struct {
+	suite.Suite
+	server *filestoretest.RedisServer
+	fs     *filestore.RedisFS
+}
+
+func TestRedisTestSuite(t *testing.T) {
+	suite.Run(t, &RedisTestSuite{})
+}
+
+func (s *RedisTestSuite) SetupTest() {
+	s.server = filestoretest.NewRedisServer()
+	s.fs = filestore.Redis(s.server.Addr, filestore.WithClock(s.server.Clock()))
+	s.write("1.lebowski", "jeff")
+	s.write("2.lebowski", "walter")
+	s.write("3.lebowski", "donnie")
+	s.write("dude/7.lebowski", "bunny")
+	s.write("duderino/5.lebowski", "jackie")
+	s.write("duderino/6.lebowski", "nihilist")
+}
+
+func (s *RedisTestSuite) TearDownTest() {
+	s.Require().NoError(s.fs.Close(context.Background()))
+	s.server.Close()
+}
+
+func (s *RedisTestSuite) TestStat() {
+	info, err := s.fs.Stat("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("1.lebowski", info.Name())
+	s.Require().Equal(int64(4), info.Size())
+	s.Require().False(info.IsDir())
+	s.Require().True(s.server.Clock().Now().Equal(info.ModTime()))
+
+	info, err = s.fs.Stat("duderino")
+	s.Require().NoError(err, "Key prefixes should be treated like directories")
+	s.Require().Equal("duderino", info.Name())
+	s.Require().True(info.IsDir())
+
+	info, err = s.fs.Stat(".")
+	s.Require().NoError(err, "The root should always exist")
+	s.Require().True(info.IsDir())
+
+	_, err = s.fs.Stat("does-not-exist.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = s.fs.Stat("dud")
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Partial key prefixes should not be directories")
+}
+
+func (s *RedisTestSuite) TestRead() {
+	_, err := s.fs.Read("not-found.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = s.fs.Read("duderino")
+	s.Require().Error(err, "Reading a directory should fail")
+
+	s.Require().Equal("jeff", s.read(s.fs, "1.lebowski"))
+	s.Require().Equal("jackie", s.read(s.fs.ChangeDirectory("duderino"), "5.lebowski"))
+
+	s.write("empty.txt", "")
+	s.Require().Equal("", s.read(s.fs, "empty.txt"))
+}
+
+func (s *RedisTestSuite) TestWrite() {
+	file, err := s.fs.Write("the/dude/abides.txt")
+	s.Require().NoError(err)
+	_, err = file.Write([]byte("the dude "))
+	s.Require().NoError(err)
+	s.Require().False(s.fs.Exists("the/dude/abides.txt"), "Files should not show up until you close them")
+	_, err = file.Write([]byte("abides"))
+	s.Require().NoError(err)
+	_, err = file.WriteAt([]byte("DUDE"), 4)
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+
+	s.Require().Equal("the DUDE abides", s.read(s.fs, "the/dude/abides.txt"))
+	s.Require().Contains(s.server.Keys(0), "filestore:/the/dude/abides.txt")
+	s.Require().Equal("15", s.server.Hash(0, "filestore:/the/dude/abides.txt")["size"])
+
+	s.write("1.lebowski", "jeffrey")
+	s.Require().Equal("jeffrey", s.read(s.fs, "1.lebowski"), "Should overwrite existing files")
+
+	s.Require().Error(writeString(s.fs, "duderino", "nope"), "Should not overwrite directories")
+	s.Require().Error(writeString(s.fs, "1.lebowski/nope.txt", "nope"), "Parent directories should not be files")
+	s.Require().Error(writeString(s.fs, ".", "nope"))
+}
+
+func (s *RedisTestSuite) TestList() {
+	files, err := s.fs.List(".")
+	s.Require().NoError(err)
+	s.Require().Len(files, 5)
+	s.assertFile(files[0], "1.lebowski")
+	s.assertFile(files[1], "2.lebowski")
+	s.assertFile(files[2], "3.lebowski")
+	s.assertDir(files[3], "dude")
+	s.assertDir(files[4], "duderino")
+	s.Require().Equal(int64(6), files[1].Size())
+
+	files, err = s.fs.List("duderino", filestore.WithPrefix("6"))
+	s.Require().NoError(err)
+	s.Require().Len(files, 1)
+	s.assertFile(files[0], "6.lebowski")
+
+	files, err = s.fs.List(".", filestore.WithPattern("dude*"))
+	s.Require().NoError(err)
+	s.Require().Len(files, 2)
+
+	files, err = s.fs.List("not-found")
+	s.Require().NoError(err)
+	s.Require().Empty(files)
+
+	_, err = s.fs.List("1.lebowski")
+	s.Require().Error(err, "Listing a file should fail")
+}
+
+func (s *RedisTestSuite) TestList_specialCharacters() {
+	s.write("globs/[a]*.txt", "bracket")
+	s.write("globs/a*.txt", "star")
+	s.write("globby/a.txt", "nope")
+
+	files, err := s.fs.List("globs", filestore.WithPrefix("[a]"))
+	s.Require().NoError(err)
+	s.Require().Len(files, 1, "Glob characters in paths should be matched literally")
+	s.assertFile(files[0], "[a]*.txt")
+}
+
+func (s *RedisTestSuite) TestRemove() {
+	s.Require().NoError(s.fs.Remove("1.lebowski"))
+	s.Require().False(s.fs.Exists("1.lebowski"))
+
+	s.Require().NoError(s.fs.Remove("duderino"))
+	s.Require().False(s.fs.Exists("duderino"))
+	s.Require().True(s.fs.Exists("dude/7.lebowski"), "Should not remove directories w/ the same prefix")
+
+	s.Require().NoError(s.fs.Remove("not-found.txt"), "Removing missing files should be a nop")
+
+	s.Require().NoError(s.fs.ChangeDirectory("dude").Remove("."))
+	s.Require().NoError(s.fs.Remove("."))
+	s.Require().Empty(s.server.Keys(0))
+}
+
+func (s *RedisTestSuite) TestMove() {
+	s.Require().NoError(s.fs.Move("1.lebowski", "moved/jeff.txt"))
+	s.Require().False(s.fs.Exists("1.lebowski"))
+	s.Require().Equal("jeff", s.read(s.fs, "moved/jeff.txt"))
+
+	s.Require().NoError(s.fs.Move("2.lebowski", "3.lebowski"), "Should overwrite existing files")
+	s.Require().Equal("walter", s.read(s.fs, "3.lebowski"))
+
+	s.Require().NoError(s.fs.Move("duderino", "bowling/alley"))
+	s.Require().False(s.fs.Exists("duderino"))
+	s.Require().Equal("jackie", s.read(s.fs, "bowling/alley/5.lebowski"))
+	s.Require().Equal("nihilist", s.read(s.fs, "bowling/alley/6.lebowski"))
+
+	err := s.fs.Move("not-found.txt", "nope.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	s.Require().True(errors.Is(s.fs.Move("3.lebowski", "dude"), fs.ErrExist), "Should not replace directories")
+	s.Require().Error(s.fs.Move("dude", "3.lebowski"), "Should not replace files w/ directories")
+	s.Require().Error(s.fs.Move("bowling", "bowling/lane"), "Should not move directories inside themselves")
+	s.Require().Error(s.fs.Move("3.lebowski", "moved/jeff.txt/nope.txt"), "Parent directories should not be files")
+}
+
+func (s *RedisTestSuite) TestTTL() {
+	scratch := filestore.Redis(s.server.Addr, filestore.WithClock(s.server.Clock()), filestore.WithTTL(time.Hour))
+	defer scratch.Close(context.Background())
+
+	s.Require().NoError(writeString(scratch, "exports/a.csv", "abide"))
+	s.Require().Equal(time.Hour, s.server.TTL(0, "filestore:/exports/a.csv"))
+
+	s.server.Clock().Advance(30 * time.Minute)
+	s.Require().NoError(writeString(scratch, "exports/b.csv", "abide"))
+	s.Require().NoError(scratch.Move("exports/a.csv", "exports/c.csv"))
+	s.Require().Equal(30*time.Minute, s.server.TTL(0, "filestore:/exports/c.csv"), "Moving should keep the TTL")
+
+	s.server.Clock().Advance(30 * time.Minute)
+	s.Require().False(scratch.Exists("exports/c.csv"), "File should expire after the TTL")
+	s.Require().True(scratch.Exists("exports/b.csv"))
+
+	s.Require().NoError(writeString(s.fs, "exports/b.csv", "abide"))
+	s.Require().Equal(time.Duration(0), s.server.TTL(0, "filestore:/exports/b.csv"), "Stores w/o a TTL should remove it")
+
+	s.server.Clock().Advance(time.Hour)
+	s.Require().True(s.fs.Exists("exports/b.csv"))
+}
+
+func (s *RedisTestSuite) TestKeyPrefix() {
+	other := filestore.Redis(s.server.Addr, filestore.WithKeyPrefix("scratch:"))
+	defer other.Close(context.Background())
+
+	s.Require().NoError(writeString(other, "1.lebowski", "the stranger"))
+	s.Require().Equal("the stranger", s.read(other, "1.lebowski"))
+	s.Require().Equal("jeff", s.read(s.fs, "1.lebowski"), "Stores w/ different prefixes should not collide")
+
+	s.Require().NoError(other.Remove("."))
+	s.Require().True(s.fs.Exists("1.lebowski"), "Removing the root should only remove keys w/ the prefix")
+}
+
+func (s *RedisTestSuite) TestAuth() {
+	s.server.RequireAuth("", "shh")
+
+	fs := filestore.Redis(s.server.Addr)
+	defer fs.Close(context.Background())
+	s.Require().Error(fs.Ping(context.Background()), "Should fail w/o credentials")
+
+	fs = filestore.Redis(s.server.Addr, filestore.WithRedisAuth("", "shh"), filestore.WithRedisDB(3))
+	defer fs.Close(context.Background())
+	s.Require().NoError(fs.Ping(context.Background()))
+	s.Require().NoError(writeString(fs, "a.txt", "abide"))
+	s.Require().Equal([]string{"filestore:/a.txt"}, s.server.Keys(3))
+}
+
+func (s *RedisTestSuite) TestAuthProvider() {
+	s.server.RequireAuth("dude", "shh")
+
+	var providerErr error
+	fs := filestore.Redis(s.server.Addr, filestore.WithRedisAuthProvider(func(ctx context.Context) (string, string, error) {
+		return "dude", "shh", providerErr
+	}))
+	defer fs.Close(context.Background())
+
+	providerErr = errors.New("vault is sealed")
+	s.Require().ErrorIs(fs.Ping(context.Background()), providerErr)
+
+	providerErr = nil
+	s.Require().NoError(fs.Ping(context.Background()))
+	s.Require().NoError(writeString(fs, "a.txt", "abide"))
+}
+
+func (s *RedisTestSuite) TestOpen() {
+	s.Require().Contains(filestore.Schemes(), "redis")
+	s.server.RequireAuth("dude", "shh")
+
+	fs, err := filestore.Open("redis://dude:shh@" + s.server.Addr + "/2?prefix=scratch:&ttl=10m")
+	s.Require().NoError(err)
+	defer filestore.Shutdown(context.Background(), fs)
+
+	s.Require().NoError(writeString(fs, "a.txt", "abide"))
+	s.Require().Equal([]string{"scratch:/a.txt"}, s.server.Keys(2))
+	s.Require().Equal(10*time.Minute, s.server.TTL(2, "scratch:/a.txt"))
+
+	_, err = filestore.Open("redis:///0")
+	s.Require().Error(err, "Should require a host")
+	_, err = filestore.Open("redis://localhost/zero")
+	s.Require().Error(err, "Should require a numeric database")
+	_, err = filestore.Open("redis://localhost?ttl=forever")
+	s.Require().Error(err, "Should require a valid TTL")
+}
+
+func (s *RedisTestSuite) TestPing() {
+	s.Require().NoError(filestore.Ping(context.Background(), s.fs))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Require().ErrorIs(s.fs.Ping(ctx), context.Canceled)
+
+	s.server.Close()
+	s.Require().Error(s.fs.Ping(context.Background()), "Should fail once the server goes away")
+}
+
+func (s *RedisTestSuite) TestForRequest() {
+	ctx, cancel := context.WithCancel(context.Background())
+	fs := filestore.ForRequest(s.fs, ctx)
+	s.Require().True(fs.Exists("1.lebowski"))
+
+	cancel()
+	_, err := fs.Read("1.lebowski")
+	s.Require().ErrorIs(err, context.Canceled)
+}
+
+func (s *RedisTestSuite) write(name string, content string) {
+	s.Require().NoError(writeString(s.fs, name, content))
+}
+
+func (s *RedisTestSuite) read(fs filestore.FS, name string) string {
+	file, err := fs.Read(name)
+	s.Require().NoError(err)
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	return string(content)
+}
+
+func (s *RedisTestSuite) assertFile(file filestore.FileInfo, name string) {
+	s.Require().Equal(name, file.Name())
+	s.Require().False(file.IsDir())
+}
+
+func (s *RedisTestSuite) assertDir(file filestore.FileInfo, name string) {
+	s.Require().Equal(name, file.Name())
+	s.Require().True(file.IsDir())
+}
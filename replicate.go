@@ -0,0 +1,259 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReplicationPolicy determines what a Replicate() store does when a change can't be applied to one of
+// its secondary stores.
+type ReplicationPolicy string
+
+const (
+	// ReplicateFailFast fails the operation as soon as any of the secondary stores fails. This is the default.
+	ReplicateFailFast ReplicationPolicy = "fail_fast"
+	// ReplicateBestEffort reports secondary failures to the WithReplicationWarning() callback and carries on;
+	// the operation only fails when the primary store fails.
+	ReplicateBestEffort ReplicationPolicy = "best_effort"
+)
+
+// replicationOptions contains the settings that only apply to Replicate().
+type replicationOptions struct {
+	policy ReplicationPolicy
+	warn   func(replica int, err error)
+}
+
+// WithReplication determines what a Replicate() store does when a change can't be applied to one of its
+// secondary stores. By default, the operation fails (ReplicateFailFast).
+func WithReplication(policy ReplicationPolicy) Option {
+	return func(opts *options) {
+		switch policy {
+		case ReplicateFailFast, ReplicateBestEffort:
+			opts.replication.policy = policy
+		}
+	}
+}
+
+// WithReplicationWarning lets you know when a Replicate() store w/ the ReplicateBestEffort policy fails to
+// apply a change to one of its secondary stores. The callback is given the index of the secondary store
+// (in the order you passed them to Replicate) and the reason, which includes the operation and path.
+//
+// Example:
+//
+//	warn := func(replica int, err error) {
+//	    log.Printf("replica %d is out of sync: %v", replica, err)
+//	}
+//	files := filestore.Replicate(local, []filestore.FS{bucket}, filestore.WithReplication(filestore.ReplicateBestEffort), filestore.WithReplicationWarning(warn))
+func WithReplicationWarning(fn func(replica int, err error)) Option {
+	return func(opts *options) {
+		if fn != nil {
+			opts.replication.warn = fn
+		}
+	}
+}
+
+// Replicate creates a store whose writes, moves, and removals are applied to the primary store and then to
+// every one of the secondary stores, so that each of them holds a full copy of your files. Everything else
+// (reads, listings, etc.) is served by the primary store alone. A typical setup is a fast local disk as the
+// primary w/ a durable remote store like S3 as a secondary.
+//
+// By default, an operation fails as soon as the primary or any of the secondaries fails, in which case the
+// stores that already succeeded keep their changes. With WithReplication(ReplicateBestEffort), failures of
+// secondary stores are only reported to the WithReplicationWarning() callback. A file that fails to be
+// written to a secondary store part way through is removed from it so that it doesn't hold a corrupt copy.
+//
+// Example:
+//
+//	files := filestore.Replicate(filestore.Disk("/var/data"), []filestore.FS{filestore.S3("backups")})
+//	err := writeReport(files, "reports/2022-09.pdf") // written to disk and S3
+func Replicate(primary FS, secondaries []FS, opts ...Option) FS {
+	options := newOptions(opts)
+	return &replicatedFS{FS: primary, secondaries: secondaries, options: options.replication}
+}
+
+type replicatedFS struct {
+	FS
+	secondaries []FS
+	options     replicationOptions
+}
+
+// Write opens the file for writing in every store. Everything you write to it is written to all of them.
+func (r *replicatedFS) Write(filePath string) (WriterFile, error) {
+	primary, err := r.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &replicatedWriterFile{WriterFile: primary, fs: r, path: filePath, replicas: make([]WriterFile, len(r.secondaries))}
+	for i, secondary := range r.secondaries {
+		replica, err := secondary.Write(filePath)
+		if err == nil {
+			file.replicas[i] = replica
+			continue
+		}
+		if err = r.failed(i, "write", filePath, err); err != nil {
+			file.abort()
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// Move relocates the file/directory in the primary store and then in each secondary store.
+func (r *replicatedFS) Move(fromPath string, toPath string) error {
+	if err := r.FS.Move(fromPath, toPath); err != nil {
+		return err
+	}
+	for i, secondary := range r.secondaries {
+		if err := secondary.Move(fromPath, toPath); err != nil {
+			if err = r.failed(i, "move", fromPath, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Remove deletes the file/directory from the primary store and then from each secondary store.
+func (r *replicatedFS) Remove(fileOrDirPath string) error {
+	if err := r.FS.Remove(fileOrDirPath); err != nil {
+		return err
+	}
+	for i, secondary := range r.secondaries {
+		if err := secondary.Remove(fileOrDirPath); err != nil {
+			if err = r.failed(i, "remove", fileOrDirPath, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory of every store.
+func (r *replicatedFS) ChangeDirectory(dir string) FS {
+	secondaries := make([]FS, len(r.secondaries))
+	for i, secondary := range r.secondaries {
+		secondaries[i] = secondary.ChangeDirectory(dir)
+	}
+	return &replicatedFS{FS: r.FS.ChangeDirectory(dir), secondaries: secondaries, options: r.options}
+}
+
+func (r *replicatedFS) withContext(ctx context.Context) FS {
+	secondaries := make([]FS, len(r.secondaries))
+	for i, secondary := range r.secondaries {
+		secondaries[i] = ForRequest(secondary, ctx)
+	}
+	return &replicatedFS{FS: ForRequest(r.FS, ctx), secondaries: secondaries, options: r.options}
+}
+
+func (r *replicatedFS) requestContext() context.Context {
+	return RequestContext(r.FS)
+}
+
+func (r *replicatedFS) wrapped() []FS {
+	return append([]FS{r.FS}, r.secondaries...)
+}
+
+// failed decides what to do when an operation fails on one of the secondary stores. It returns the error
+// that the operation should fail with, or nil when we're making a best effort.
+func (r *replicatedFS) failed(replica int, op string, filePath string, err error) error {
+	err = fmt.Errorf("filestore: replicate: %s: %s: replica %d: %w", op, filePath, replica, err)
+	if r.options.policy == ReplicateBestEffort {
+		r.options.warn(replica, err)
+		return nil
+	}
+	return err
+}
+
+// replicatedWriterFile writes everything to the primary file and each of its replicas. Replicas that fail
+// while we're making a best effort are dropped (and removed) so that we don't leave a corrupt copy behind.
+type replicatedWriterFile struct {
+	WriterFile
+	fs       *replicatedFS
+	path     string
+	replicas []WriterFile
+}
+
+func (f *replicatedWriterFile) Write(data []byte) (int, error) {
+	n, err := f.WriterFile.Write(data)
+	if err != nil {
+		return n, err
+	}
+	return n, f.each("write", func(replica WriterFile) error {
+		_, err := replica.Write(data)
+		return err
+	})
+}
+
+func (f *replicatedWriterFile) WriteAt(data []byte, offset int64) (int, error) {
+	n, err := f.WriterFile.WriteAt(data, offset)
+	if err != nil {
+		return n, err
+	}
+	return n, f.each("write", func(replica WriterFile) error {
+		_, err := replica.WriteAt(data, offset)
+		return err
+	})
+}
+
+func (f *replicatedWriterFile) Seek(offset int64, whence int) (int64, error) {
+	position, err := f.WriterFile.Seek(offset, whence)
+	if err != nil {
+		return position, err
+	}
+	return position, f.each("seek", func(replica WriterFile) error {
+		_, err := replica.Seek(offset, whence)
+		return err
+	})
+}
+
+func (f *replicatedWriterFile) Close() error {
+	err := f.WriterFile.Close()
+	replicaErr := f.each("close", func(replica WriterFile) error {
+		return replica.Close()
+	})
+	for i := range f.replicas {
+		f.replicas[i] = nil
+	}
+	if err != nil {
+		return err
+	}
+	return replicaErr
+}
+
+// each applies the operation to every replica that's still in play, returning the first failure that we're
+// not allowed to ignore.
+func (f *replicatedWriterFile) each(op string, fn func(replica WriterFile) error) error {
+	for i, replica := range f.replicas {
+		if replica == nil {
+			continue
+		}
+		if err := fn(replica); err != nil {
+			f.drop(i)
+			if err = f.fs.failed(i, op, f.path, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// drop stops writing to the replica and removes its partial copy.
+func (f *replicatedWriterFile) drop(replica int) {
+	_ = f.replicas[replica].Close()
+	_ = f.fs.secondaries[replica].Remove(f.path)
+	f.replicas[replica] = nil
+}
+
+// abort closes every file that we've opened so far when we fail to open one of them.
+func (f *replicatedWriterFile) abort() {
+	_ = f.WriterFile.Close()
+	for _, replica := range f.replicas {
+		if replica != nil {
+			_ = replica.Close()
+		}
+	}
+}
+
+var _ requestBinder = &replicatedFS{}
+var _ wrapper = &replicatedFS{}
@@ -0,0 +1,137 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReplicateTestSuite struct {
+	suite.Suite
+	primary   filestore.FS
+	secondary filestore.FS
+	flaky     *flakyFS
+	fs        filestore.FS
+}
+
+func TestReplicateTestSuite(t *testing.T) {
+	suite.Run(t, &ReplicateTestSuite{})
+}
+
+func (s *ReplicateTestSuite) SetupTest() {
+	s.primary = filestore.Memory()
+	s.secondary = filestore.Memory()
+	s.flaky = &flakyFS{FS: filestore.Memory(), failMoveAfter: -1}
+	s.fs = filestore.Replicate(s.primary, []filestore.FS{s.secondary, s.flaky})
+}
+
+func (s *ReplicateTestSuite) TestWrite() {
+	s.Require().NoError(writeString(s.fs, "reports/q3.txt", "the dude abides"))
+	s.assertContent(s.primary, "reports/q3.txt", "the dude abides")
+	s.assertContent(s.secondary, "reports/q3.txt", "the dude abides")
+	s.assertContent(s.flaky, "reports/q3.txt", "the dude abides")
+
+	file, err := s.fs.ChangeDirectory("reports").Write("q4.txt")
+	s.Require().NoError(err)
+	_, err = file.Write([]byte("the dude"))
+	s.Require().NoError(err)
+	_, err = file.WriteAt([]byte("DUDE"), 4)
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+	s.assertContent(s.primary, "reports/q4.txt", "the DUDE")
+	s.assertContent(s.flaky, "reports/q4.txt", "the DUDE")
+}
+
+func (s *ReplicateTestSuite) TestWrite_failFast() {
+	s.flaky.failWrite = "q3.txt"
+	err := writeString(s.fs, "reports/q3.txt", "the dude abides")
+	s.Require().Error(err)
+	s.Require().Contains(err.Error(), "replica 1")
+	s.Require().NoError(writeString(s.fs, "reports/q4.txt", "abide"), "Other files should be fine")
+}
+
+func (s *ReplicateTestSuite) TestWrite_bestEffort() {
+	var failures []int
+	warn := func(replica int, err error) {
+		failures = append(failures, replica)
+	}
+	s.fs = filestore.Replicate(s.primary, []filestore.FS{s.secondary, s.flaky},
+		filestore.WithReplication(filestore.ReplicateBestEffort),
+		filestore.WithReplicationWarning(warn))
+
+	s.flaky.failWrite = "q3.txt"
+	s.Require().NoError(writeString(s.fs, "reports/q3.txt", "the dude abides"))
+	s.assertContent(s.primary, "reports/q3.txt", "the dude abides")
+	s.assertContent(s.secondary, "reports/q3.txt", "the dude abides")
+	s.Require().False(s.flaky.Exists("reports/q3.txt"))
+	s.Require().Equal([]int{1}, failures)
+
+	// Failing part way through should not leave a partial copy behind.
+	failing := filestore.Replicate(s.primary, []filestore.FS{&failingWriteFS{FS: s.secondary}},
+		filestore.WithReplication(filestore.ReplicateBestEffort),
+		filestore.WithReplicationWarning(warn))
+	s.Require().NoError(writeString(failing, "reports/q4.txt", "abide"))
+	s.assertContent(s.primary, "reports/q4.txt", "abide")
+	s.Require().False(s.secondary.Exists("reports/q4.txt"))
+	s.Require().Equal([]int{1, 0}, failures)
+}
+
+func (s *ReplicateTestSuite) TestMoveAndRemove() {
+	s.Require().NoError(writeString(s.fs, "reports/q3.txt", "the dude abides"))
+	s.Require().NoError(s.fs.Move("reports/q3.txt", "archive/q3.txt"))
+	for _, fs := range []filestore.FS{s.primary, s.secondary, s.flaky} {
+		s.Require().False(fs.Exists("reports/q3.txt"))
+		s.assertContent(fs, "archive/q3.txt", "the dude abides")
+	}
+
+	s.Require().NoError(s.fs.Remove("archive"))
+	for _, fs := range []filestore.FS{s.primary, s.secondary, s.flaky} {
+		s.Require().False(fs.Exists("archive"))
+	}
+
+	s.flaky.failRemove = true
+	s.Require().NoError(writeString(s.fs, "reports/q4.txt", "abide"))
+	s.Require().Error(s.fs.Remove("reports"))
+	s.Require().False(s.primary.Exists("reports"))
+	s.Require().False(s.secondary.Exists("reports"))
+}
+
+func (s *ReplicateTestSuite) TestRead() {
+	s.Require().NoError(writeString(s.secondary, "secondary.txt", "nope"))
+	s.Require().False(s.fs.Exists("secondary.txt"), "Should only read from the primary")
+
+	ctx := context.WithValue(context.Background(), replicateKey{}, "dude")
+	s.Require().Equal("dude", filestore.RequestContext(filestore.ForRequest(s.fs, ctx)).Value(replicateKey{}))
+}
+
+func (s *ReplicateTestSuite) assertContent(fs filestore.FS, filePath string, expected string) {
+	content, err := readString(fs, filePath)
+	s.Require().NoError(err)
+	s.Require().Equal(expected, content)
+}
+
+type replicateKey struct{}
+
+// failingWriteFS opens files for writing just fine, but you can't actually write anything to them.
+type failingWriteFS struct {
+	filestore.FS
+}
+
+func (f *failingWriteFS) Write(filePath string) (filestore.WriterFile, error) {
+	file, err := f.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &failingWriterFile{WriterFile: file}, nil
+}
+
+type failingWriterFile struct {
+	filestore.WriterFile
+}
+
+func (f *failingWriterFile) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
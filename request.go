@@ -0,0 +1,112 @@
+package filestore
+
+import (
+	"context"
+)
+
+// Identity describes who (or what) is performing a store's operations so that layers like audit logs,
+// hooks, and access policies can attribute each action to the right user.
+type Identity struct {
+	// User identifies the user/service on whose behalf the operations are performed.
+	User string
+	// TraceID correlates the operations w/ the rest of the work done for the same request.
+	TraceID string
+}
+
+type identityContextKey struct{}
+
+// ContextWithIdentity returns a copy of the context that carries the given identity. Use this along
+// w/ ForRequest() to derive a store that knows who is using it.
+func ContextWithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// IdentityFromContext returns the identity attached to the context using ContextWithIdentity(). The
+// boolean is false if the context doesn't carry one.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityContextKey{}).(Identity)
+	return identity, ok
+}
+
+// requestBinder is implemented by stores and layers that carry the context of the request that derived
+// them via ForRequest(), e.g. so that remote stores can cancel requests that are no longer needed or so
+// that audit logs can attribute operations to a user. Layers typically pass the context along to the
+// store they wrap using ForRequest() as well.
+type requestBinder interface {
+	// withContext returns a copy of the store that is bound to the given context.
+	withContext(ctx context.Context) FS
+	// requestContext returns the context the store is bound to (context.Background() if none).
+	requestContext() context.Context
+}
+
+// ForRequest derives a store for a single request (HTTP request, job, etc.) that carries the request's
+// context, including any Identity you attached using ContextWithIdentity(). The derived store behaves
+// exactly like the original (including its optional capabilities when it's one of the stores/layers in
+// this package), but layers that care about the caller can look up the context using RequestContext(),
+// and remote stores like S3() and HTTP() send their requests using it, so they're cancelled along w/ the
+// request. Stores derived from it via ChangeDirectory() carry the same context.
+//
+// Deriving a store is cheap; the original is never modified, so it's safe to share a single store
+// between all of your request handlers and derive a new one for each request.
+//
+// Example:
+//
+//	func (h Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+//	    ctx := filestore.ContextWithIdentity(req.Context(), filestore.Identity{
+//	        User:    userID(req),
+//	        TraceID: req.Header.Get("X-Trace-Id"),
+//	    })
+//	    files := filestore.ForRequest(h.files, ctx)
+//	    ...
+//	}
+func ForRequest(fs FS, ctx context.Context) FS {
+	if binder, ok := fs.(requestBinder); ok {
+		return binder.withContext(ctx)
+	}
+	return &requestFS{FS: fs, ctx: ctx}
+}
+
+// RequestContext returns the context of the request that the store was derived for via ForRequest().
+// For all other stores, this is context.Background().
+func RequestContext(fs FS) context.Context {
+	if binder, ok := fs.(requestBinder); ok {
+		return binder.requestContext()
+	}
+	return context.Background()
+}
+
+// contextOrBackground is the context that stores use for their operations when the context they're
+// bound to might be nil (i.e. they were never derived via ForRequest()).
+func contextOrBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// requestFS carries the request's context for stores that don't know how to carry it themselves (e.g.
+// third-party stores). Those stores' optional capabilities are hidden by the wrapper.
+type requestFS struct {
+	FS
+	ctx context.Context
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that carries the same context.
+func (r *requestFS) ChangeDirectory(dir string) FS {
+	return &requestFS{FS: r.FS.ChangeDirectory(dir), ctx: r.ctx}
+}
+
+func (r *requestFS) withContext(ctx context.Context) FS {
+	return &requestFS{FS: r.FS, ctx: ctx}
+}
+
+func (r *requestFS) requestContext() context.Context {
+	return r.ctx
+}
+
+func (r *requestFS) wrapped() []FS {
+	return []FS{r.FS}
+}
+
+var _ requestBinder = &requestFS{}
+var _ wrapper = &requestFS{}
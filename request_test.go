@@ -0,0 +1,100 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type RequestTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func TestRequestTestSuite(t *testing.T) {
+	suite.Run(t, &RequestTestSuite{})
+}
+
+func (s *RequestTestSuite) SetupTest() {
+	s.ctx = filestore.ContextWithIdentity(context.Background(), filestore.Identity{User: "dude", TraceID: "abc123"})
+}
+
+func (s *RequestTestSuite) requireIdentity(fs filestore.FS) {
+	identity, ok := filestore.IdentityFromContext(filestore.RequestContext(fs))
+	s.Require().True(ok, "Derived store should carry the request's identity")
+	s.Require().Equal("dude", identity.User)
+	s.Require().Equal("abc123", identity.TraceID)
+}
+
+func (s *RequestTestSuite) TestForRequest() {
+	shared := filestore.Memory()
+	files := filestore.ForRequest(shared, s.ctx)
+	s.requireIdentity(files)
+	s.requireIdentity(files.ChangeDirectory("duderino"))
+
+	s.Require().NoError(writeString(files, "duderino/5.lebowski", "jackie"))
+	content, err := readString(shared, "duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jackie", content, "Derived store should share the original's files")
+
+	_, ok := filestore.IdentityFromContext(filestore.RequestContext(shared))
+	s.Require().False(ok, "Original store should not be modified")
+	s.Require().Equal(context.Background(), filestore.RequestContext(shared))
+
+	other := filestore.ContextWithIdentity(context.Background(), filestore.Identity{User: "walter"})
+	identity, _ := filestore.IdentityFromContext(filestore.RequestContext(filestore.ForRequest(files, other)))
+	s.Require().Equal("walter", identity.User, "Should be able to re-derive a store for another request")
+}
+
+func (s *RequestTestSuite) TestForRequest_layers() {
+	disk := filestore.ForRequest(filestore.Disk(s.T().TempDir()), s.ctx)
+	_, ok := disk.(filestore.Linker)
+	s.Require().True(ok, "Derived store should keep the original's capabilities")
+
+	tenant, err := filestore.Scoped(filestore.Deduplicated(filestore.Memory()), "acme")
+	s.Require().NoError(err)
+	files := filestore.ForRequest(filestore.WORM(tenant), s.ctx)
+	s.requireIdentity(files)
+
+	s.Require().NoError(writeString(files, "1.lebowski", "jeff"))
+	s.Require().NoError(filestore.SetImmutable(files, "1.lebowski", time.Now().Add(time.Hour)), "Layers should keep their capabilities")
+	s.Require().True(errors.Is(files.Remove("1.lebowski"), filestore.ErrImmutable))
+}
+
+func (s *RequestTestSuite) TestForRequest_thirdParty() {
+	files := filestore.ForRequest(thirdPartyFS{FS: filestore.Memory()}, s.ctx)
+	s.requireIdentity(files)
+	s.requireIdentity(files.ChangeDirectory("duderino"))
+	s.Require().Equal(context.Background(), filestore.RequestContext(thirdPartyFS{FS: filestore.Memory()}))
+}
+
+func (s *RequestTestSuite) TestForRequest_cancel() {
+	server := filestoretest.NewS3Server("lebowski")
+	defer server.Close()
+	server.PutObject("lebowski", "1.lebowski", []byte("jeff"))
+	bucket := filestore.S3("lebowski",
+		filestore.WithEndpoint(server.URL),
+		filestore.WithCredentials("AKID", "SECRET", ""),
+	)
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	files := filestore.ForRequest(bucket, ctx)
+	s.Require().True(files.Exists("1.lebowski"))
+
+	cancel()
+	_, err := files.Stat("1.lebowski")
+	s.Require().True(errors.Is(err, context.Canceled), "Requests should be cancelled along w/ the request")
+	_, err = files.ChangeDirectory(".").Stat("1.lebowski")
+	s.Require().True(errors.Is(err, context.Canceled))
+	s.Require().True(bucket.Exists("1.lebowski"), "Original store should be unaffected")
+}
+
+// thirdPartyFS is a store that knows nothing about ForRequest().
+type thirdPartyFS struct {
+	filestore.FS
+}
@@ -0,0 +1,68 @@
+package filestore
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// defaultCorrelationHeader is the header that remote stores send the request's trace ID in unless you
+// specify otherwise.
+const defaultCorrelationHeader = "X-Correlation-Id"
+
+// WithCorrelationHeader sets the header that remote stores (e.g. S3 and HTTP) use to send the TraceID of
+// the Identity attached to the request's context (see ContextWithIdentity and ForRequest), so that the
+// provider's logs can be matched up w/ yours. Defaults to "X-Correlation-Id"; an empty name turns it off.
+func WithCorrelationHeader(name string) Option {
+	return func(opts *options) {
+		opts.correlation = http.CanonicalHeaderKey(name)
+	}
+}
+
+// ProviderRequestID returns the ID that a remote service assigned to the request that failed w/ the given
+// error (e.g. S3's "x-amz-request-id"), which is what the provider's support team will ask you for. It
+// returns an empty string if the error didn't come from a remote service or the service didn't supply an ID.
+//
+// Example:
+//
+//	if err = output.Close(); err != nil {
+//	    log.Printf("upload failed (provider request id=%s): %v", filestore.ProviderRequestID(err), err)
+//	}
+func ProviderRequestID(err error) string {
+	var identified providerRequestIdentifier
+	if errors.As(err, &identified) {
+		return identified.ProviderRequestID()
+	}
+	return ""
+}
+
+// providerRequestIdentifier is implemented by the errors of remote stores that know the ID the provider
+// assigned to the failed request.
+type providerRequestIdentifier interface {
+	ProviderRequestID() string
+}
+
+// setCorrelationHeader adds the trace ID of the context's Identity (if any) to the outgoing request.
+func setCorrelationHeader(req *http.Request, ctx context.Context, name string) {
+	if name == "" {
+		return
+	}
+	if identity, ok := IdentityFromContext(ctx); ok && identity.TraceID != "" {
+		req.Header.Set(name, identity.TraceID)
+	}
+}
+
+// newInvocationID generates a random, UUID-formatted token that identifies a single logical operation
+// across all of its attempts. It returns an empty string if the source of randomness fails.
+func newInvocationID(random io.Reader) string {
+	id := make([]byte, 16)
+	if _, err := io.ReadFull(random, id); err != nil {
+		return ""
+	}
+	id[6] = (id[6] & 0x0f) | 0x40 // version 4
+	id[8] = (id[8] & 0x3f) | 0x80 // RFC 4122 variant
+	encoded := hex.EncodeToString(id)
+	return encoded[0:8] + "-" + encoded[8:12] + "-" + encoded[12:16] + "-" + encoded[16:20] + "-" + encoded[20:]
+}
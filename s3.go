@@ -0,0 +1,1335 @@
+package filestore
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultS3PartSize is the size of each part in a multipart upload unless you specify otherwise.
+const defaultS3PartSize = 8 * 1024 * 1024
+
+func init() {
+	RegisterScheme("s3", func(u *url.URL) (FS, error) {
+		if u.Host == "" {
+			return nil, fmt.Errorf("missing bucket name")
+		}
+		var opts []Option
+		if region := u.Query().Get("region"); region != "" {
+			opts = append(opts, WithRegion(region))
+		}
+		if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+			opts = append(opts, WithEndpoint(endpoint))
+		}
+		if pathStyle := u.Query().Get("path_style"); pathStyle != "" {
+			enabled, err := strconv.ParseBool(pathStyle)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path_style: %w", err)
+			}
+			opts = append(opts, WithPathStyle(enabled))
+		}
+		if requesterPays := u.Query().Get("requester_pays"); requesterPays != "" {
+			enabled, err := strconv.ParseBool(requesterPays)
+			if err != nil {
+				return nil, fmt.Errorf("invalid requester_pays: %w", err)
+			}
+			if enabled {
+				opts = append(opts, WithRequesterPays())
+			}
+		}
+		return S3(u.Host, opts...).ChangeDirectory(u.Path), nil
+	})
+}
+
+// s3Options contains the settings that only apply to S3 stores.
+type s3Options struct {
+	region        string
+	endpoint      string
+	credentials   *s3Credentials
+	provider      S3CredentialsProvider
+	partSize      int
+	storageClass  string
+	addressing    s3Addressing
+	requesterPays bool
+	acl           string
+	kms           bool
+	kmsKeyID      string
+	customerKey   []byte
+	header        http.Header
+}
+
+// s3Addressing determines whether the bucket name is part of the host name (virtual-hosted style) or
+// the path (path style) of each request's URL.
+type s3Addressing int
+
+const (
+	// s3AddressingAuto uses virtual-hosted style for AWS and path style for custom endpoints.
+	s3AddressingAuto s3Addressing = iota
+	s3AddressingPath
+	s3AddressingVirtual
+)
+
+// WithRegion sets the AWS region that an S3 store's bucket lives in. By default, we use the
+// AWS_REGION (or AWS_DEFAULT_REGION) environment variable, falling back to "us-east-1".
+func WithRegion(region string) Option {
+	return func(opts *options) {
+		opts.s3.region = region
+	}
+}
+
+// WithCredentials sets the static keys that an S3 store uses to sign its requests. The session
+// token is only required for temporary credentials. By default, we use the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables; if those aren't set, requests
+// are sent anonymously (which only works with public buckets).
+func WithCredentials(accessKeyID string, secretAccessKey string, sessionToken string) Option {
+	return func(opts *options) {
+		opts.s3.provider = nil
+		opts.s3.credentials = &s3Credentials{
+			accessKeyID:     accessKeyID,
+			secretAccessKey: secretAccessKey,
+			sessionToken:    sessionToken,
+		}
+	}
+}
+
+// S3Credentials are the keys that an S3 store signs its requests w/ when they come from an
+// S3CredentialsProvider.
+type S3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only required for temporary credentials (e.g. from STS).
+	SessionToken string
+	// Expires is when temporary credentials stop working. Leave it zero for credentials that don't expire
+	// on their own, such as a key pair that someone rotates now and then.
+	Expires time.Time
+}
+
+// S3CredentialsProvider fetches the latest credentials for an S3 store, e.g. by assuming a role w/ STS or
+// by reading a secret that gets rotated. The context is that of the request that needed them.
+type S3CredentialsProvider func(ctx context.Context) (S3Credentials, error)
+
+// WithCredentialsProvider makes an S3 store fetch the keys it signs requests w/ from the provider rather
+// than using static ones, so short-lived tokens and rotated secrets don't require a restart. We call the
+// provider the first time we need credentials and hang on to them until a minute before they expire. We
+// also call it again whenever S3 rejects the credentials we have (expired token, unknown access key,
+// etc.), retrying the request once w/ the fresh ones, so keys rotated out from under us are picked up,
+// too. The provider is never called by more than one request at a time.
+//
+// Example:
+//
+//	files := filestore.S3("my-bucket", filestore.WithCredentialsProvider(func(ctx context.Context) (filestore.S3Credentials, error) {
+//	    keys, err := vault.ReadAWSKeys(ctx)
+//	    if err != nil {
+//	        return filestore.S3Credentials{}, err
+//	    }
+//	    return filestore.S3Credentials{AccessKeyID: keys.ID, SecretAccessKey: keys.Secret, SessionToken: keys.Token, Expires: keys.Expires}, nil
+//	}))
+func WithCredentialsProvider(provider S3CredentialsProvider) Option {
+	return func(opts *options) {
+		if provider != nil {
+			opts.s3.credentials = nil
+			opts.s3.provider = provider
+		}
+	}
+}
+
+// WithPartSize sets how many bytes an S3 store buffers before uploading each part of a large file
+// via multipart upload. Files smaller than this are uploaded in a single request. S3 requires every
+// part but the last to be at least 5MB. Defaults to 8MB.
+func WithPartSize(size int) Option {
+	return func(opts *options) {
+		if size > 0 {
+			opts.s3.partSize = size
+		}
+	}
+}
+
+// WithStorageClass sets the S3 storage class (e.g. "STANDARD_IA", "GLACIER_IR", or "DEEP_ARCHIVE") of
+// every file that an S3 store writes. By default, we leave it up to the bucket, which is usually
+// "STANDARD". Files moved within the store keep their original storage class.
+func WithStorageClass(class string) Option {
+	return func(opts *options) {
+		opts.s3.storageClass = class
+	}
+}
+
+// WithEndpoint points an S3 store at an S3-compatible service other than AWS, such as MinIO, Ceph RGW,
+// DigitalOcean Spaces, or the fake server in the filestoretest package. The endpoint is the service's
+// base URL (e.g. "http://localhost:9000" or "https://nyc3.digitaloceanspaces.com"); if you leave off the
+// scheme, we assume "https". Requests to custom endpoints use path-style addressing unless you supply
+// WithPathStyle(false). Use WithRegion() for the region the service expects in request signatures,
+// which is often just "us-east-1".
+func WithEndpoint(endpoint string) Option {
+	return func(opts *options) {
+		if endpoint != "" && !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+		opts.s3.endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithPathStyle controls whether an S3 store puts the bucket name in the path of each request's URL
+// (e.g. "http://localhost:9000/my-bucket/key") rather than the host name (e.g.
+// "https://my-bucket.nyc3.digitaloceanspaces.com/key"). By default, we use path-style addressing for
+// custom endpoints (see WithEndpoint()) and virtual-hosted style for AWS itself.
+func WithPathStyle(enabled bool) Option {
+	return func(opts *options) {
+		if enabled {
+			opts.s3.addressing = s3AddressingPath
+		} else {
+			opts.s3.addressing = s3AddressingVirtual
+		}
+	}
+}
+
+// WithRequesterPays acknowledges that you (rather than the bucket owner) pay for the requests and data
+// transfer of an S3 store whose bucket has "Requester Pays" enabled. S3 rejects every request to those
+// buckets w/o it.
+func WithRequesterPays() Option {
+	return func(opts *options) {
+		opts.s3.requesterPays = true
+	}
+}
+
+// WithACL applies the canned ACL (e.g. "bucket-owner-full-control" or "public-read") to every file that an
+// S3 store writes, including files that it moves. By default, we leave it up to the bucket. Buckets that
+// enforce object ownership (the default for new buckets) reject every ACL but "bucket-owner-full-control".
+func WithACL(acl string) Option {
+	return func(opts *options) {
+		opts.s3.acl = acl
+	}
+}
+
+// WithKMSEncryption makes an S3 store encrypt every file that it writes w/ the given AWS KMS key (SSE-KMS).
+// The key can be a key ID, key ARN, or alias ARN. Pass an empty string to use the AWS managed key for S3.
+// Reading these files doesn't require anything extra, as long as your credentials can use the key.
+func WithKMSEncryption(keyID string) Option {
+	return func(opts *options) {
+		opts.s3.kms = true
+		opts.s3.kmsKeyID = keyID
+	}
+}
+
+// WithCustomerKey makes an S3 store encrypt every file that it writes w/ your own 256-bit AES key (SSE-C).
+// S3 doesn't keep the key, so we send it w/ every request that reads or writes a file's data, and you
+// won't be able to read those files w/o it.
+func WithCustomerKey(key []byte) Option {
+	return func(opts *options) {
+		opts.s3.customerKey = append([]byte(nil), key...)
+	}
+}
+
+// WithHeaders adds the given headers to every request that an S3 store sends, e.g. for proxies or
+// S3-compatible services that require something extra. They're signed along w/ the rest of the request.
+// The headers that we set ourselves for a given operation take precedence over these.
+func WithHeaders(header http.Header) Option {
+	return func(opts *options) {
+		if opts.s3.header == nil {
+			opts.s3.header = http.Header{}
+		}
+		for name, values := range header {
+			opts.s3.header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+		}
+	}
+}
+
+// S3 creates a file store whose files are objects in the given Amazon S3 bucket. Since S3 has no
+// real directories, key prefixes delimited by "/" act as directories instead; the directory
+// "reports/2022" exists as long as there is at least one object whose key starts with "reports/2022/".
+// Writing a file implicitly "creates" all of its parent directories, and removing the last file in a
+// directory makes the directory disappear.
+//
+// Files are uploaded when you close the WriterFile. Small files are uploaded with a single request
+// while large ones are streamed in chunks via multipart upload (see WithPartSize()), so you never
+// need to hold an entire large file in memory. Readers fetch data lazily using ranged requests, so
+// seeking around a large object only downloads the bytes you actually read.
+//
+// You can supply WithRegion(), WithCredentials() (or WithCredentialsProvider()), WithPartSize(), WithStorageClass(), WithHTTPClient(),
+// and WithClock() to customize how the store talks to S3. For buckets that require more than that, there's
+// WithRequesterPays(), WithACL(), WithKMSEncryption(), WithCustomerKey(), and WithHeaders(). To use an
+// S3-compatible service instead of AWS (MinIO, Ceph RGW, DigitalOcean Spaces, etc.), supply WithEndpoint()
+// and WithPathStyle() as well.
+//
+// Example:
+//
+//	files := filestore.S3("my-bucket", filestore.WithRegion("us-west-2"))
+//	reports := files.ChangeDirectory("reports/2022")
+//
+//	output, err := reports.Write("summary.csv") // key "reports/2022/summary.csv"
+//	if err != nil {
+//	    // handle your error nicely
+//	}
+//	output.Write(data)
+//	output.Close()
+func S3(bucket string, opts ...Option) *S3FS {
+	options := newOptions(opts)
+
+	region := options.s3.region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	credentials := s3Credentials{
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if options.s3.credentials != nil {
+		credentials = *options.s3.credentials
+	}
+	source := &s3CredentialSource{current: credentials, provider: options.s3.provider, clock: options.clock}
+
+	partSize := options.s3.partSize
+	if partSize <= 0 {
+		partSize = defaultS3PartSize
+	}
+
+	client := &s3Client{
+		http:          options.client(),
+		region:        region,
+		endpoint:      options.s3.endpoint,
+		credentials:   source,
+		partSize:      partSize,
+		storageClass:  options.s3.storageClass,
+		addressing:    options.s3.addressing,
+		requesterPays: options.s3.requesterPays,
+		acl:           options.s3.acl,
+		kms:           options.s3.kms,
+		kmsKeyID:      options.s3.kmsKeyID,
+		customerKey:   options.s3.customerKey,
+		header:        options.s3.header,
+		correlation:   options.correlation,
+		random:        options.random,
+		clock:         options.clock,
+	}
+	return &S3FS{client: client, bucket: bucket, basePath: "/"}
+}
+
+// S3FS is a file store whose files are objects in an Amazon S3 bucket.
+type S3FS struct {
+	client   *s3Client
+	bucket   string
+	basePath string
+
+	// ctx is the context of the request this store was derived for via ForRequest(), if any.
+	ctx context.Context
+}
+
+// key converts a path relative to this FS' working directory into an object key. The root of the
+// bucket is the empty key.
+func (s S3FS) key(filePath string) (string, error) {
+	fullPath, err := resolvePath(s.basePath, filePath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(fullPath, "/"), nil
+}
+
+// WorkingDirectory returns the current FS context's path/directory.
+func (s S3FS) WorkingDirectory() string {
+	return path.Clean(s.basePath)
+}
+
+// ChangeDirectory returns a new FS that is rooted in the given "subdirectory" (key prefix) of this FS.
+func (s S3FS) ChangeDirectory(dir string) FS {
+	return &S3FS{client: s.client, bucket: s.bucket, basePath: joinPath(s.basePath, dir), ctx: s.ctx}
+}
+
+// withContext makes every request this store sends use the given context, so that cancelling the
+// request that derived it via ForRequest() also cancels any S3 requests still in flight.
+func (s S3FS) withContext(ctx context.Context) FS {
+	s.ctx = ctx
+	return &s
+}
+
+// requestContext is the context that S3 requests should use.
+func (s S3FS) requestContext() context.Context {
+	return contextOrBackground(s.ctx)
+}
+
+// Stat fetches metadata about the file w/o actually opening it for reading/writing. A path is
+// considered a directory when there are any objects under its prefix.
+func (s S3FS) Stat(filePath string) (FileInfo, error) {
+	key, err := s.key(filePath)
+	if err != nil {
+		return nil, newPathError("s3", "stat", filePath, err)
+	}
+	info, err := s.stat(s.requestContext(), key)
+	if err != nil {
+		return nil, newPathError("s3", "stat", filePath, err)
+	}
+	return info, nil
+}
+
+func (s S3FS) stat(ctx context.Context, key string) (s3FileInfo, error) {
+	if key == "" {
+		return s3FileInfo{name: "/", dir: true}, nil
+	}
+
+	res, err := s.client.do(ctx, s3Request{method: http.MethodHead, bucket: s.bucket, key: key, header: s.keyHeader()})
+	if err == nil {
+		res.Body.Close()
+		return s3InfoFromHeader(key, res.Header), nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return s3FileInfo{}, err
+	}
+
+	// There's no object, but it could still be a directory if there are objects "in" it.
+	objects, prefixes, err := s.list(ctx, key+"/", "/", 1)
+	if err != nil {
+		return s3FileInfo{}, err
+	}
+	if len(objects) == 0 && len(prefixes) == 0 {
+		return s3FileInfo{}, fs.ErrNotExist
+	}
+	return s3FileInfo{name: path.Base(key), dir: true}, nil
+}
+
+// Exists returns true when the file/directory already exits in the bucket.
+func (s S3FS) Exists(filePath string) bool {
+	_, err := s.Stat(filePath)
+	return err == nil
+}
+
+// Read opens the given file at the given path, providing you with an io.Reader that
+// you can use to stream bytes from it. Data is downloaded lazily as you read it, and seeking
+// simply changes the offset of the next download. Should the object be overwritten while you are
+// reading it, subsequent reads fail rather than mixing the contents of the old and new versions.
+func (s S3FS) Read(filePath string) (ReaderFile, error) {
+	key, err := s.key(filePath)
+	if err != nil {
+		return nil, newPathError("s3", "open", filePath, err)
+	}
+	info, err := s.stat(s.requestContext(), key)
+	if err != nil {
+		return nil, newPathError("s3", "open", filePath, err)
+	}
+	if info.dir {
+		return nil, newPathError("s3", "open", filePath, errIsDirectory)
+	}
+
+	return &rangeReaderFile{size: info.size, fetch: func(offset int64, length int64) (io.ReadCloser, error) {
+		rangeHeader := fmt.Sprintf("bytes=%d-", offset)
+		if length >= 0 {
+			rangeHeader += strconv.FormatInt(offset+length-1, 10)
+		}
+		header := s.keyHeader()
+		header.Set("Range", rangeHeader)
+		if info.etag != "" {
+			header.Set("If-Match", info.etag)
+		}
+		res, err := s.client.do(s.requestContext(), s3Request{method: http.MethodGet, bucket: s.bucket, key: key, header: header})
+		if err != nil {
+			return nil, err
+		}
+		return res.Body, nil
+	}}, nil
+}
+
+// Write opens the given file at the given path for writing. The resulting file
+// behaves like a standard io.Writer/At, but the object is not created/replaced until
+// you close the file. Large files are uploaded in parts as you write them, so once a
+// part has been uploaded, you can no longer WriteAt() or Seek() to offsets within it.
+func (s S3FS) Write(filePath string) (WriterFile, error) {
+	key, err := s.key(filePath)
+	if err != nil {
+		return nil, newPathError("s3", "write", filePath, err)
+	}
+	if key == "" {
+		return nil, newPathError("s3", "write", filePath, errIsDirectory)
+	}
+	return &s3WriterFile{fs: s, key: key}, nil
+}
+
+// List performs the equivalent of the "ls" command. It returns a slice of
+// all files and directories found in the target dirPath.
+//
+// You can optionally provide a set of filters to limit which files/directories
+// are included in the final set. When the filters require a name prefix (e.g.
+// WithPrefix() or WithPattern("2022-*")), we only ask S3 for keys with that prefix.
+func (s S3FS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	key, err := s.key(dirPath)
+	if err != nil {
+		return nil, newPathError("s3", "list files", dirPath, err)
+	}
+
+	dirPrefix := ""
+	if key != "" {
+		dirPrefix = key + "/"
+	}
+	namePrefix := filtersPrefix(filters)
+
+	ctx := s.requestContext()
+	objects, prefixes, err := s.list(ctx, dirPrefix+namePrefix, "/", 0)
+	if err != nil {
+		return nil, newPathError("s3", "list files", dirPath, err)
+	}
+
+	var infos []FileInfo
+	for _, object := range objects {
+		// Some tools create empty "directory marker" objects like "reports/"; they're not files.
+		if object.Key == dirPrefix {
+			continue
+		}
+		infos = append(infos, object.info(strings.TrimPrefix(object.Key, dirPrefix)))
+	}
+	for _, prefix := range prefixes {
+		infos = append(infos, s3FileInfo{name: strings.TrimSuffix(strings.TrimPrefix(prefix, dirPrefix), "/"), dir: true})
+	}
+
+	// An empty listing might be because the path is a file, not a directory.
+	if len(infos) == 0 && namePrefix == "" && key != "" {
+		if info, err := s.stat(ctx, key); err == nil && !info.dir {
+			return nil, newPathError("s3", "list files", dirPath, ErrNotDirectory)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	results, err := filterFiles(infos, filters)
+	if err != nil {
+		return nil, newPathError("s3", "list files", dirPath, err)
+	}
+	return results, nil
+}
+
+// Remove deletes the given file/directory and any of its children. Removing the root
+// of the store deletes every object under the working directory.
+func (s S3FS) Remove(fileOrDirPath string) error {
+	key, err := s.key(fileOrDirPath)
+	if err != nil {
+		return newPathError("s3", "remove", fileOrDirPath, err)
+	}
+
+	ctx := s.requestContext()
+	prefix := ""
+	var keys []string
+	if key != "" {
+		prefix = key + "/"
+		keys = append(keys, key)
+	}
+
+	objects, _, err := s.list(ctx, prefix, "", 0)
+	if err != nil {
+		return newPathError("s3", "remove", fileOrDirPath, err)
+	}
+	for _, object := range objects {
+		keys = append(keys, object.Key)
+	}
+	if err := s.deleteAll(ctx, keys); err != nil {
+		return newPathError("s3", "remove", fileOrDirPath, err)
+	}
+	return nil
+}
+
+// Move takes an existing file at the fromPath location and moves it to another
+// spot in this file system; the toPath location. S3 has no rename operation, so this
+// copies each object to its new key and then deletes the original. Moving a directory
+// is therefore not atomic; should it fail halfway through, some objects may exist
+// in both places.
+func (s S3FS) Move(fromPath string, toPath string) error {
+	fromKey, err := s.key(fromPath)
+	if err != nil {
+		return newPathError("s3", "move", fromPath, err)
+	}
+	toKey, err := s.key(toPath)
+	if err != nil {
+		return newPathError("s3", "move", toPath, err)
+	}
+
+	ctx := s.requestContext()
+	from, err := s.stat(ctx, fromKey)
+	if err != nil {
+		return newPathError("s3", "move", fromPath, err)
+	}
+	if fromKey == toKey {
+		return nil
+	}
+	if fromKey == "" || strings.HasPrefix(toKey+"/", fromKey+"/") {
+		return newPathError("s3", "move", fromPath, errors.New("can not move a directory inside of itself"))
+	}
+
+	// Mirror the rules for os.Rename(). You can overwrite an existing file with another
+	// file, but you can never replace an existing directory or overwrite a file w/ a directory.
+	to, err := s.stat(ctx, toKey)
+	switch {
+	case err == nil && to.dir:
+		return newPathError("s3", "move", toPath, fs.ErrExist)
+	case err == nil && from.dir:
+		return newPathError("s3", "move", toPath, ErrNotDirectory)
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		return newPathError("s3", "move", toPath, err)
+	}
+
+	if !from.dir {
+		if err := s.copy(ctx, fromKey, toKey, from.storageClass); err != nil {
+			return newPathError("s3", "move", fromPath, err)
+		}
+		if err := s.delete(ctx, fromKey); err != nil {
+			return newPathError("s3", "move", fromPath, err)
+		}
+		return nil
+	}
+
+	objects, _, err := s.list(ctx, fromKey+"/", "", 0)
+	if err != nil {
+		return newPathError("s3", "move", fromPath, err)
+	}
+	for _, object := range objects {
+		if err := s.copy(ctx, object.Key, toKey+strings.TrimPrefix(object.Key, fromKey), object.StorageClass); err != nil {
+			return newPathError("s3", "move", fromPath, err)
+		}
+	}
+	keys := make([]string, len(objects))
+	for i, object := range objects {
+		keys[i] = object.Key
+	}
+	if err := s.deleteAll(ctx, keys); err != nil {
+		return newPathError("s3", "move", fromPath, err)
+	}
+	return nil
+}
+
+// Ping verifies that the bucket exists and that our credentials allow us to access it.
+func (s S3FS) Ping(ctx context.Context) error {
+	res, err := s.client.do(ctx, s3Request{method: http.MethodHead, bucket: s.bucket})
+	if err != nil {
+		return newPathError("s3", "ping", s.bucket, err)
+	}
+	res.Body.Close()
+	return nil
+}
+
+// SetImmutable applies an S3 Object Lock retention period to the current version of the file using
+// COMPLIANCE mode, so nobody (not even the root account) can delete or overwrite that version until the
+// given time. You can extend the retention period, but never shorten it. The bucket must have been
+// created with Object Lock enabled.
+//
+// Object Lock protects versions, not keys. You can still upload a new version of the file or "remove"
+// it (S3 just adds a delete marker), but the locked version remains available via Versions() and
+// RestoreVersion() until it expires.
+func (s S3FS) SetImmutable(filePath string, until time.Time) error {
+	key, err := s.key(filePath)
+	if err != nil {
+		return newPathError("s3", "set immutable", filePath, err)
+	}
+	if key == "" {
+		return newPathError("s3", "set immutable", filePath, errIsDirectory)
+	}
+
+	retention := struct {
+		XMLName         xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Retention"`
+		Mode            string   `xml:"Mode"`
+		RetainUntilDate string   `xml:"RetainUntilDate"`
+	}{Mode: "COMPLIANCE", RetainUntilDate: until.UTC().Format(time.RFC3339)}
+	body, err := xml.Marshal(retention)
+	if err != nil {
+		return newPathError("s3", "set immutable", filePath, err)
+	}
+
+	// S3 requires a checksum of the body for any request that configures Object Lock.
+	sum := md5.Sum(body)
+	req := s3Request{
+		method: http.MethodPut,
+		bucket: s.bucket,
+		key:    key,
+		query:  url.Values{"retention": {""}},
+		header: http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])}},
+		body:   body,
+	}
+	res, err := s.client.do(s.requestContext(), req)
+	if err != nil {
+		return newPathError("s3", "set immutable", filePath, err)
+	}
+	return res.Body.Close()
+}
+
+// Restore temporarily restores an archived file (one in the GLACIER or DEEP_ARCHIVE storage class) so
+// that you can read it for the given number of days. S3 performs the restore in the background, which
+// can take several hours; until it's done, reading the file still fails. Restoring a file that has
+// already been restored just extends the number of days it stays available.
+func (s S3FS) Restore(filePath string, days int) error {
+	key, err := s.key(filePath)
+	if err != nil {
+		return newPathError("s3", "restore", filePath, err)
+	}
+	if key == "" {
+		return newPathError("s3", "restore", filePath, errIsDirectory)
+	}
+	if days <= 0 {
+		return newPathError("s3", "restore", filePath, errors.New("days must be positive"))
+	}
+
+	restore := struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ RestoreRequest"`
+		Days    int      `xml:"Days"`
+		Tier    string   `xml:"GlacierJobParameters>Tier"`
+	}{Days: days, Tier: "Standard"}
+	body, err := xml.Marshal(restore)
+	if err != nil {
+		return newPathError("s3", "restore", filePath, err)
+	}
+	req := s3Request{method: http.MethodPost, bucket: s.bucket, key: key, query: url.Values{"restore": {""}}, body: body}
+	res, err := s.client.do(s.requestContext(), req)
+	if err != nil {
+		return newPathError("s3", "restore", filePath, err)
+	}
+	return res.Body.Close()
+}
+
+// s3ListPageSize is the maximum number of keys S3 returns for a single ListObjectsV2 request.
+const s3ListPageSize = 1000
+
+// estimateList records one ListObjectsV2 request per page of entries in the directory.
+func (s S3FS) estimateList(entries int, cost *StoreCost) {
+	pages := (entries + s3ListPageSize - 1) / s3ListPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	cost.Requests["ListObjectsV2"] += pages
+}
+
+// estimateRead records the HeadObject request that Read() sends to stat the file and the GetObject
+// request that downloads it (empty files don't need to download anything).
+func (s S3FS) estimateRead(size int64, cost *StoreCost) {
+	cost.Requests["HeadObject"]++
+	if size > 0 {
+		cost.Requests["GetObject"]++
+	}
+}
+
+// estimateStat records the HeadObject request that Stat() sends.
+func (s S3FS) estimateStat(cost *StoreCost) {
+	cost.Requests["HeadObject"]++
+}
+
+// estimateWrite records a single PutObject request for small files or a multipart upload for files
+// that are at least as large as the part size.
+func (s S3FS) estimateWrite(size int64, cost *StoreCost) {
+	partSize := int64(s.client.partSize)
+	if size < partSize {
+		cost.Requests["PutObject"]++
+		return
+	}
+	cost.Requests["CreateMultipartUpload"]++
+	cost.Requests["UploadPart"] += int((size + partSize - 1) / partSize)
+	cost.Requests["CompleteMultipartUpload"]++
+}
+
+// s3Object is a single entry in the results of a ListObjectsV2 call.
+type s3Object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+func (object s3Object) info(name string) s3FileInfo {
+	modTime, _ := time.Parse(time.RFC3339, object.LastModified)
+	return s3FileInfo{name: name, size: object.Size, modTime: modTime, etag: object.ETag, storageClass: object.StorageClass}
+}
+
+// list fetches the objects and common prefixes (i.e. "subdirectories") whose keys start with the
+// given prefix, following continuation tokens until we've seen everything (or 'limit' results
+// when that's greater than zero).
+func (s S3FS) list(ctx context.Context, prefix string, delimiter string, limit int) ([]s3Object, []string, error) {
+	var objects []s3Object
+	var prefixes []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		if limit > 0 {
+			query.Set("max-keys", strconv.Itoa(limit))
+		}
+
+		page := struct {
+			IsTruncated           bool       `xml:"IsTruncated"`
+			NextContinuationToken string     `xml:"NextContinuationToken"`
+			Contents              []s3Object `xml:"Contents"`
+			CommonPrefixes        []struct {
+				Prefix string `xml:"Prefix"`
+			} `xml:"CommonPrefixes"`
+		}{}
+		if err := s.client.doXML(ctx, s3Request{method: http.MethodGet, bucket: s.bucket, query: query}, &page); err != nil {
+			return nil, nil, err
+		}
+
+		objects = append(objects, page.Contents...)
+		for _, common := range page.CommonPrefixes {
+			prefixes = append(prefixes, common.Prefix)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" || (limit > 0 && len(objects)+len(prefixes) >= limit) {
+			return objects, prefixes, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// copy performs a server-side copy of one object to another key in the same bucket. S3 resets the
+// copy's storage class unless we tell it otherwise, so you should supply the original's class (or an
+// empty string to use the store's default class).
+func (s S3FS) copy(ctx context.Context, fromKey string, toKey string, storageClass string) error {
+	header := s.copyHeader(storageClass)
+	header.Set("X-Amz-Copy-Source", uriEncode("/"+s.bucket+"/"+fromKey, false))
+	result := struct{}{}
+	return s.client.doXML(ctx, s3Request{method: http.MethodPut, bucket: s.bucket, key: toKey, header: header}, &result)
+}
+
+// objectHeader returns the headers that we send w/ every request that creates an object. An empty
+// storage class means that we should use the store's default class (if any).
+func (s S3FS) objectHeader(storageClass string) http.Header {
+	header := s.keyHeader()
+	if storageClass == "" {
+		storageClass = s.client.storageClass
+	}
+	if storageClass != "" {
+		header.Set("X-Amz-Storage-Class", storageClass)
+	}
+	if s.client.acl != "" {
+		header.Set("X-Amz-Acl", s.client.acl)
+	}
+	if s.client.kms {
+		header.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		if s.client.kmsKeyID != "" {
+			header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", s.client.kmsKeyID)
+		}
+	}
+	return header
+}
+
+// copyHeader returns the headers that we send w/ every request that copies an object. These are the same
+// as objectHeader(), plus the customer key (if any) to decrypt the source object.
+func (s S3FS) copyHeader(storageClass string) http.Header {
+	header := s.objectHeader(storageClass)
+	for name, values := range s.keyHeader() {
+		header["X-Amz-Copy-Source-"+strings.TrimPrefix(name, "X-Amz-")] = values
+	}
+	return header
+}
+
+// keyHeader returns the headers that we send w/ every request that reads or writes an object's data. That's
+// nothing unless you supplied WithCustomerKey(), since S3 needs the key to encrypt/decrypt the data.
+func (s S3FS) keyHeader() http.Header {
+	header := http.Header{}
+	if key := s.client.customerKey; key != nil {
+		sum := md5.Sum(key)
+		header.Set("X-Amz-Server-Side-Encryption-Customer-Algorithm", "AES256")
+		header.Set("X-Amz-Server-Side-Encryption-Customer-Key", base64.StdEncoding.EncodeToString(key))
+		header.Set("X-Amz-Server-Side-Encryption-Customer-Key-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	return header
+}
+
+// delete removes a single object. Deleting an object that doesn't exist is not an error.
+func (s S3FS) delete(ctx context.Context, key string) error {
+	res, err := s.client.do(ctx, s3Request{method: http.MethodDelete, bucket: s.bucket, key: key})
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// s3DeleteBatchSize is the most keys that S3 lets us delete in a single DeleteObjects request.
+const s3DeleteBatchSize = 1000
+
+// deleteAll removes every one of the given objects, deleting them in batches rather than sending
+// one request per object. Deleting objects that don't exist is not an error.
+func (s S3FS) deleteAll(ctx context.Context, keys []string) error {
+	if len(keys) == 1 {
+		return s.delete(ctx, keys[0])
+	}
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > s3DeleteBatchSize {
+			batch = batch[:s3DeleteBatchSize]
+		}
+		if err := s.deleteBatch(ctx, batch); err != nil {
+			return err
+		}
+		keys = keys[len(batch):]
+	}
+	return nil
+}
+
+// deleteBatch removes up to s3DeleteBatchSize objects w/ a single DeleteObjects request. S3 reports
+// failures for individual keys in an otherwise successful response, so we check for those, too.
+func (s S3FS) deleteBatch(ctx context.Context, keys []string) error {
+	type s3DeleteObject struct {
+		Key string `xml:"Key"`
+	}
+	request := struct {
+		XMLName xml.Name         `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Delete"`
+		Objects []s3DeleteObject `xml:"Object"`
+		Quiet   bool             `xml:"Quiet"`
+	}{Quiet: true}
+	for _, key := range keys {
+		request.Objects = append(request.Objects, s3DeleteObject{Key: key})
+	}
+	body, err := xml.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	// S3 requires a checksum of the body for DeleteObjects requests.
+	sum := md5.Sum(body)
+	req := s3Request{
+		method: http.MethodPost,
+		bucket: s.bucket,
+		query:  url.Values{"delete": {""}},
+		header: http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])}},
+		body:   body,
+	}
+	res, err := s.client.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	result := struct {
+		Errors []struct {
+			Key     string `xml:"Key"`
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		} `xml:"Error"`
+	}{}
+	if err := xml.NewDecoder(res.Body).Decode(&result); err != nil && err != io.EOF {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		// Like other errors reported in a 2xx response (see decodeS3Error), these don't have a status of their own.
+		failed := result.Errors[0]
+		return fmt.Errorf("delete %s: %w", failed.Key, &s3Error{
+			StatusCode: http.StatusInternalServerError,
+			Code:       failed.Code,
+			Message:    failed.Message,
+			RequestID:  res.Header.Get("X-Amz-Request-Id"),
+			HostID:     res.Header.Get("X-Amz-Id-2"),
+		})
+	}
+	return nil
+}
+
+// s3FileInfo describes an object (or a key prefix acting as a directory).
+type s3FileInfo struct {
+	name         string
+	size         int64
+	dir          bool
+	modTime      time.Time
+	etag         string
+	storageClass string
+}
+
+func s3InfoFromHeader(key string, header http.Header) s3FileInfo {
+	size, _ := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	modTime, _ := http.ParseTime(header.Get("Last-Modified"))
+	// S3 only includes the storage class header for objects that aren't STANDARD.
+	storageClass := header.Get("X-Amz-Storage-Class")
+	if storageClass == "" {
+		storageClass = "STANDARD"
+	}
+	return s3FileInfo{name: path.Base(key), size: size, modTime: modTime, etag: header.Get("ETag"), storageClass: storageClass}
+}
+
+func (info s3FileInfo) Name() string {
+	return info.name
+}
+
+func (info s3FileInfo) Size() int64 {
+	return info.size
+}
+
+func (info s3FileInfo) Mode() fs.FileMode {
+	if info.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (info s3FileInfo) ModTime() time.Time {
+	return info.modTime
+}
+
+func (info s3FileInfo) IsDir() bool {
+	return info.dir
+}
+
+// StorageClass returns the object's S3 storage class (e.g. "STANDARD" or "GLACIER"). Directories
+// don't have one.
+func (info s3FileInfo) StorageClass() string {
+	return info.storageClass
+}
+
+func (info s3FileInfo) ETag() string {
+	return info.etag
+}
+
+func (info s3FileInfo) Sys() any {
+	return nil
+}
+
+// s3WriterFile buffers writes in memory, uploading a part of a multipart upload each time the buffer
+// reaches the store's part size. Small files never start a multipart upload; they're uploaded with a
+// single PutObject when the file is closed.
+type s3WriterFile struct {
+	mutex    sync.Mutex
+	fs       S3FS
+	key      string
+	buffer   []byte
+	uploaded int64
+	offset   int64
+	uploadID string
+	parts    []s3CompletedPart
+	err      error
+	closed   bool
+}
+
+// s3CompletedPart is one entry in the manifest we send to complete a multipart upload.
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// Write writes len(b) bytes from b to the file at the current offset.
+func (w *s3WriterFile) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	n, err := w.writeAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+// WriteAt writes len(b) bytes to the file starting at byte offset off. The offset must not fall within
+// a part that has already been uploaded.
+func (w *s3WriterFile) WriteAt(p []byte, off int64) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.writeAt(p, off)
+}
+
+func (w *s3WriterFile) writeAt(p []byte, off int64) (int, error) {
+	switch {
+	case w.closed:
+		return 0, fmt.Errorf("s3 fs: write: %w", fs.ErrClosed)
+	case w.err != nil:
+		return 0, w.err
+	case off < w.uploaded:
+		return 0, fmt.Errorf("s3 fs: write: offset %d has already been uploaded", off)
+	}
+
+	bufferOffset := off - w.uploaded
+	if end := bufferOffset + int64(len(p)); end > int64(len(w.buffer)) {
+		w.buffer = append(w.buffer, make([]byte, end-int64(len(w.buffer)))...)
+	}
+	copy(w.buffer[bufferOffset:], p)
+
+	for len(w.buffer) >= w.fs.client.partSize {
+		if err := w.uploadPart(w.buffer[:w.fs.client.partSize]); err != nil {
+			return 0, err
+		}
+		w.buffer = append([]byte(nil), w.buffer[w.fs.client.partSize:]...)
+	}
+	return len(p), nil
+}
+
+// Seek moves to the given offset w/o writing any data. You can't seek back into a part that has
+// already been uploaded.
+func (w *s3WriterFile) Seek(offset int64, whence int) (int64, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, fmt.Errorf("s3 fs: seek: %w", fs.ErrClosed)
+	}
+
+	var position int64
+	switch whence {
+	case io.SeekStart:
+		position = offset
+	case io.SeekCurrent:
+		position = w.offset + offset
+	case io.SeekEnd:
+		position = w.uploaded + int64(len(w.buffer)) + offset
+	default:
+		return 0, fmt.Errorf("s3 fs: seek: invalid whence %d", whence)
+	}
+	if position < w.uploaded {
+		return 0, fmt.Errorf("s3 fs: seek: offset %d has already been uploaded", position)
+	}
+	w.offset = position
+	return position, nil
+}
+
+// Close uploads whatever is left in the buffer, creating/replacing the object.
+func (w *s3WriterFile) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.err != nil {
+		w.abort()
+		return w.err
+	}
+
+	ctx := w.fs.requestContext()
+	request := s3Request{method: http.MethodPut, bucket: w.fs.bucket, key: w.key}
+	if w.uploadID == "" {
+		request.header = w.fs.objectHeader("")
+		request.body = w.buffer
+		res, err := w.fs.client.do(ctx, request)
+		if err != nil {
+			return fmt.Errorf("s3 fs: upload: %w", err)
+		}
+		return res.Body.Close()
+	}
+
+	if len(w.buffer) > 0 {
+		if err := w.uploadPart(w.buffer); err != nil {
+			w.abort()
+			return err
+		}
+	}
+
+	manifest := struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: w.parts}
+	body, err := xml.Marshal(manifest)
+	if err != nil {
+		w.abort()
+		return fmt.Errorf("s3 fs: complete upload: %w", err)
+	}
+	request.method = http.MethodPost
+	request.query = url.Values{"uploadId": {w.uploadID}}
+	request.body = body
+	if err := w.fs.client.doXML(ctx, request, &struct{}{}); err != nil {
+		w.abort()
+		return fmt.Errorf("s3 fs: complete upload: %w", err)
+	}
+	return nil
+}
+
+// uploadPart sends the next part of the multipart upload, starting the upload if this is the
+// first part. You must hold the mutex when calling this.
+func (w *s3WriterFile) uploadPart(data []byte) error {
+	ctx := w.fs.requestContext()
+	if w.uploadID == "" {
+		result := struct {
+			UploadID string `xml:"UploadId"`
+		}{}
+		request := s3Request{
+			method: http.MethodPost,
+			bucket: w.fs.bucket,
+			key:    w.key,
+			query:  url.Values{"uploads": {""}},
+			header: w.fs.objectHeader(""),
+		}
+		if err := w.fs.client.doXML(ctx, request, &result); err != nil {
+			w.err = fmt.Errorf("s3 fs: start upload: %w", err)
+			return w.err
+		}
+		w.uploadID = result.UploadID
+	}
+
+	partNumber := len(w.parts) + 1
+	res, err := w.fs.client.do(ctx, s3Request{
+		method: http.MethodPut,
+		bucket: w.fs.bucket,
+		key:    w.key,
+		query:  url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {w.uploadID}},
+		header: w.fs.keyHeader(),
+		body:   data,
+	})
+	if err != nil {
+		w.err = fmt.Errorf("s3 fs: upload part %d: %w", partNumber, err)
+		return w.err
+	}
+	res.Body.Close()
+
+	w.parts = append(w.parts, s3CompletedPart{PartNumber: partNumber, ETag: res.Header.Get("ETag")})
+	w.uploaded += int64(len(data))
+	return nil
+}
+
+// abort cancels the multipart upload (if we started one) so that S3 doesn't keep charging
+// you for the orphaned parts. This doesn't use the request's context since its cancellation
+// may well be the reason we're aborting.
+func (w *s3WriterFile) abort() {
+	if w.uploadID == "" {
+		return
+	}
+	request := s3Request{method: http.MethodDelete, bucket: w.fs.bucket, key: w.key, query: url.Values{"uploadId": {w.uploadID}}}
+	if res, err := w.fs.client.do(context.Background(), request); err == nil {
+		res.Body.Close()
+	}
+}
+
+// rangeReaderFile is a ReaderFile for remote objects that supports ranged downloads. Sequential reads
+// stream from a single response; seeking or calling ReadAt() issues a new request for just the
+// bytes you want.
+type rangeReaderFile struct {
+	mutex sync.Mutex
+	size  int64
+
+	// fetch downloads 'length' bytes of the object starting at 'offset'. A negative length means
+	// that you want everything through the end of the object.
+	fetch func(offset int64, length int64) (io.ReadCloser, error)
+
+	offset     int64
+	body       io.ReadCloser
+	bodyOffset int64
+	closed     bool
+}
+
+// Read reads up to len(b) bytes from the file and stores them in b. At end of file, Read returns 0, io.EOF.
+func (r *rangeReaderFile) Read(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return 0, fmt.Errorf("read: %w", fs.ErrClosed)
+	}
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// Only start a new download if we've seeked away from where the current one is.
+	if r.body == nil || r.bodyOffset != r.offset {
+		if r.body != nil {
+			r.body.Close()
+		}
+		body, err := r.fetch(r.offset, -1)
+		if err != nil {
+			r.body = nil
+			return 0, fmt.Errorf("read: %w", err)
+		}
+		r.body, r.bodyOffset = body, r.offset
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	r.bodyOffset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt reads len(b) bytes from the file starting at byte offset off. At end of file, that error is io.EOF.
+func (r *rangeReaderFile) ReadAt(p []byte, off int64) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	switch {
+	case r.closed:
+		return 0, fmt.Errorf("read at: %w", fs.ErrClosed)
+	case off < 0:
+		return 0, fmt.Errorf("read at: negative offset")
+	case off >= r.size:
+		return 0, io.EOF
+	case len(p) == 0:
+		return 0, nil
+	}
+
+	length := int64(len(p))
+	if off+length > r.size {
+		length = r.size - off
+	}
+	body, err := r.fetch(off, length)
+	if err != nil {
+		return 0, fmt.Errorf("read at: %w", err)
+	}
+	defer body.Close()
+
+	n, err := io.ReadFull(body, p[:length])
+	if err != nil {
+		return n, fmt.Errorf("read at: %w", err)
+	}
+	if length < int64(len(p)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek moves to the given offset w/o reading any data.
+func (r *rangeReaderFile) Seek(offset int64, whence int) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return 0, fmt.Errorf("seek: %w", fs.ErrClosed)
+	}
+
+	var position int64
+	switch whence {
+	case io.SeekStart:
+		position = offset
+	case io.SeekCurrent:
+		position = r.offset + offset
+	case io.SeekEnd:
+		position = r.size + offset
+	default:
+		return 0, fmt.Errorf("seek: invalid whence %d", whence)
+	}
+	if position < 0 {
+		return 0, fmt.Errorf("seek: negative position")
+	}
+	r.offset = position
+	return position, nil
+}
+
+// Close stops any in-progress download. You will not be able to read any more data once this has been performed.
+func (r *rangeReaderFile) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closed = true
+	if r.body != nil {
+		return r.body.Close()
+	}
+	return nil
+}
+
+var _ FS = S3FS{}
+var _ ImmutableSetter = S3FS{}
+var _ Restorer = S3FS{}
+var _ costEstimator = S3FS{}
+var _ requestBinder = S3FS{}
+var _ etagger = s3FileInfo{}
@@ -0,0 +1,435 @@
+package filestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// s3Client is a minimal client for the subset of the S3 REST API that S3FS needs. We roll our own
+// rather than pull in the AWS SDK so that you don't pay for dozens of transitive dependencies just
+// because this package supports S3 alongside the local disk.
+type s3Client struct {
+	http          *http.Client
+	region        string
+	endpoint      string
+	credentials   *s3CredentialSource
+	partSize      int
+	storageClass  string
+	addressing    s3Addressing
+	requesterPays bool
+	acl           string
+	kms           bool
+	kmsKeyID      string
+	customerKey   []byte
+	// header contains the extra headers to send w/ every request (see WithHeaders).
+	header http.Header
+	// correlation is the header we send the request's trace ID in (see WithCorrelationHeader).
+	correlation string
+	random      io.Reader
+	clock       Clock
+}
+
+// s3Credentials are the static keys used to sign requests. When the access key is empty, requests
+// are sent anonymously, which is only useful for public buckets.
+type s3Credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// s3CredentialsExpiryWindow is how long before temporary credentials expire that we fetch new ones, so
+// that a request doesn't get signed w/ credentials that expire while it's in flight.
+const s3CredentialsExpiryWindow = time.Minute
+
+// s3CredentialSource hands out the credentials to sign each request w/. Static credentials never change,
+// but ones from an S3CredentialsProvider are fetched again when they're about to expire or when S3
+// rejects them.
+type s3CredentialSource struct {
+	mutex    sync.Mutex
+	provider S3CredentialsProvider
+	clock    Clock
+	current  s3Credentials
+	expires  time.Time
+	fetched  bool
+	// blind is true when the current credentials replaced ones that S3 rejected w/o saying why (e.g. a 403
+	// for a HEAD request, which has no body). If S3 rejects these the same way, the request itself is most
+	// likely forbidden, so fetching yet another set won't help.
+	blind bool
+}
+
+// get returns the credentials to sign a request w/, fetching them from the provider if we don't have
+// current ones.
+func (source *s3CredentialSource) get(ctx context.Context) (s3Credentials, error) {
+	if source.provider == nil {
+		return source.current, nil
+	}
+
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	if source.fetched && (source.expires.IsZero() || source.clock.Now().Before(source.expires.Add(-s3CredentialsExpiryWindow))) {
+		return source.current, nil
+	}
+	if source.fetched {
+		// These simply expired, so the next ones start w/ a clean slate.
+		source.blind = false
+	}
+	credentials, err := source.provider(ctx)
+	if err != nil {
+		return s3Credentials{}, fmt.Errorf("fetch credentials: %w", err)
+	}
+	source.current = s3Credentials{
+		accessKeyID:     credentials.AccessKeyID,
+		secretAccessKey: credentials.SecretAccessKey,
+		sessionToken:    credentials.SessionToken,
+	}
+	source.expires = credentials.Expires
+	source.fetched = true
+	return source.current, nil
+}
+
+// invalidate makes the next call to get() fetch new credentials from the provider, but only if the
+// rejected ones haven't already been replaced by another request. Certain is false when S3 didn't say
+// that it was the credentials' fault, in which case we only refresh them once per generation. It returns
+// false when there's no point in retrying, e.g. because the credentials are static.
+func (source *s3CredentialSource) invalidate(rejected s3Credentials, certain bool) bool {
+	if source.provider == nil {
+		return false
+	}
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	if source.current != rejected {
+		return true
+	}
+	if !certain && source.blind {
+		return false
+	}
+	source.fetched = false
+	source.blind = !certain
+	return true
+}
+
+// s3Request describes a single call to the S3 API. An empty key means that this is a bucket-level
+// operation such as ListObjectsV2.
+type s3Request struct {
+	method string
+	bucket string
+	key    string
+	query  url.Values
+	header http.Header
+	body   []byte
+}
+
+// url builds the address of the bucket/object. We use virtual-hosted style addressing for AWS itself
+// (i.e. "https://bucket.s3.region.amazonaws.com/key") unless the bucket name contains dots, since those
+// break TLS certificate validation. Custom endpoints use path-style addressing by default. You can
+// override either default w/ WithPathStyle().
+func (c *s3Client) url(req s3Request) *url.URL {
+	u := &url.URL{Scheme: "https", Host: "s3." + c.region + ".amazonaws.com"}
+	prefix := "/"
+	if c.endpoint != "" {
+		endpoint, _ := url.Parse(c.endpoint)
+		u.Scheme, u.Host = endpoint.Scheme, endpoint.Host
+		prefix = strings.TrimSuffix(endpoint.Path, "/") + "/"
+	}
+	if c.pathStyle(req.bucket) {
+		prefix += req.bucket + "/"
+	} else {
+		u.Host = req.bucket + "." + u.Host
+	}
+
+	u.Path = prefix + req.key
+	u.RawPath = uriEncode(prefix, false) + uriEncode(req.key, false)
+	u.RawQuery = canonicalQuery(req.query)
+	return u
+}
+
+// pathStyle returns true when requests for the bucket should use path-style addressing.
+func (c *s3Client) pathStyle(bucket string) bool {
+	switch c.addressing {
+	case s3AddressingPath:
+		return true
+	case s3AddressingVirtual:
+		return false
+	default:
+		return c.endpoint != "" || strings.Contains(bucket, ".")
+	}
+}
+
+// do sends the request, returning an *s3Error for any non-2xx response. When this succeeds, you're
+// responsible for closing the response body. If S3 rejects credentials that came from a provider, we
+// fetch new ones and try again once.
+func (c *s3Client) do(ctx context.Context, req s3Request) (*http.Response, error) {
+	invocationID := newInvocationID(c.random)
+	for attempt := 1; ; attempt++ {
+		credentials, err := c.credentials.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		httpReq, err := c.newRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		// This is the same token the AWS SDKs send, so that AWS can tell retries of the same operation apart
+		// from new ones (and so can you when you open a support ticket).
+		if invocationID != "" {
+			httpReq.Header.Set("Amz-Sdk-Invocation-Id", invocationID)
+			httpReq.Header.Set("Amz-Sdk-Request", fmt.Sprintf("attempt=%d; max=2", attempt))
+		}
+		if credentials.accessKeyID != "" {
+			sum := sha256.Sum256(req.body)
+			signV4(httpReq, hex.EncodeToString(sum[:]), credentials, c.region, c.clock.Now())
+		}
+
+		res, err := c.http.Do(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode >= 200 && res.StatusCode <= 299 {
+			return res, nil
+		}
+		err = decodeS3Error(res)
+		res.Body.Close()
+		rejected, certain := s3CredentialsRejected(err)
+		if attempt > 1 || !rejected || !c.credentials.invalidate(credentials, certain) {
+			return nil, err
+		}
+	}
+}
+
+// newRequest builds the (unsigned) HTTP request for the S3 call.
+func (c *s3Client) newRequest(ctx context.Context, req s3Request) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.url(req).String(), bytes.NewReader(req.body))
+	if err != nil {
+		return nil, err
+	}
+	if req.body == nil {
+		httpReq.Body = http.NoBody
+	}
+	for name, values := range c.header {
+		httpReq.Header[name] = values
+	}
+	if c.requesterPays {
+		httpReq.Header.Set("X-Amz-Request-Payer", "requester")
+	}
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	setCorrelationHeader(httpReq, ctx, c.correlation)
+	return httpReq, nil
+}
+
+// doXML sends the request and decodes the XML response body into the given value.
+func (c *s3Client) doXML(ctx context.Context, req s3Request, result any) error {
+	res, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	// Some operations (e.g. CopyObject) can fail after S3 has already sent a 200 status.
+	if bytes.Contains(body, []byte("<Error>")) {
+		res.Body = io.NopCloser(bytes.NewReader(body))
+		return decodeS3Error(res)
+	}
+	return xml.Unmarshal(body, result)
+}
+
+// s3Error is the error payload returned by S3 when a request fails.
+type s3Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+	RequestID  string `xml:"RequestId"`
+	HostID     string `xml:"HostId"`
+}
+
+func (err *s3Error) Error() string {
+	message := fmt.Sprintf("s3 status %d", err.StatusCode)
+	if err.Code != "" {
+		message += fmt.Sprintf(": %s: %s", err.Code, err.Message)
+	}
+	if err.RequestID != "" {
+		message += fmt.Sprintf(" (request id: %s, host id: %s)", err.RequestID, err.HostID)
+	}
+	return message
+}
+
+// ProviderRequestID is the ID that S3 assigned to the failed request.
+func (err *s3Error) ProviderRequestID() string {
+	return err.RequestID
+}
+
+// Unwrap lets you use errors.Is(err, fs.ErrNotExist) to detect missing objects just like you would
+// with any other store.
+func (err *s3Error) Unwrap() error {
+	if err.StatusCode == http.StatusNotFound && err.Code != "NoSuchBucket" {
+		return fs.ErrNotExist
+	}
+	return nil
+}
+
+// s3CredentialsRejected returns true when S3 may have failed the request because of the credentials it
+// was signed w/ rather than what the request was trying to do. HEAD responses don't have a body, so we
+// only have the status code to go on. S3 reports expired/invalid session tokens w/ a 400, which HEAD
+// requests don't otherwise get, but a 403 might just as well be a forbidden object, so certain is false.
+func s3CredentialsRejected(err error) (rejected bool, certain bool) {
+	var s3Err *s3Error
+	if !errors.As(err, &s3Err) {
+		return false, false
+	}
+	switch s3Err.Code {
+	case "":
+		return s3Err.StatusCode == http.StatusBadRequest || s3Err.StatusCode == http.StatusForbidden,
+			s3Err.StatusCode == http.StatusBadRequest
+	case "ExpiredToken", "InvalidToken", "TokenRefreshRequired", "InvalidAccessKeyId", "SignatureDoesNotMatch":
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+func decodeS3Error(res *http.Response) error {
+	result := &s3Error{}
+	// HEAD responses don't have a body, so we'll just have the status code to go on.
+	_ = xml.NewDecoder(res.Body).Decode(result)
+	if result.RequestID == "" {
+		result.RequestID = res.Header.Get("X-Amz-Request-Id")
+	}
+	if result.HostID == "" {
+		result.HostID = res.Header.Get("X-Amz-Id-2")
+	}
+	result.StatusCode = res.StatusCode
+	if result.StatusCode < 300 {
+		result.StatusCode = http.StatusInternalServerError
+	}
+	return result
+}
+
+// signV4 adds the headers for AWS Signature Version 4 to the request. The payload hash is the
+// hex-encoded SHA256 of the request body.
+func signV4(req *http.Request, payloadHash string, credentials s3Credentials, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if credentials.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.sessionToken)
+	}
+
+	// Sign every header we send (plus the host), so that nothing can be tampered with in transit.
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalPath := req.URL.EscapedPath()
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+credentials.secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes the query string the way SigV4 expects: sorted by name, then value, with
+// everything but unreserved characters percent-encoded. We use the same encoding for the actual
+// request URL so that the signature always matches what we send.
+func canonicalQuery(query url.Values) string {
+	type pair struct{ name, value string }
+	var pairs []pair
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, pair{name: uriEncode(name, true), value: uriEncode(value, true)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].name != pairs[j].name {
+			return pairs[i].name < pairs[j].name
+		}
+		return pairs[i].value < pairs[j].value
+	})
+
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p.name + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// uriEncode percent-encodes everything except unreserved characters (and optionally slashes).
+func uriEncode(value string, encodeSlash bool) string {
+	result := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			result.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			result.WriteByte(c)
+		default:
+			fmt.Fprintf(&result, "%%%02X", c)
+		}
+	}
+	return result.String()
+}
@@ -0,0 +1,739 @@
+package filestore_test
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type S3TestSuite struct {
+	suite.Suite
+	server *filestoretest.S3Server
+	fs     filestore.FS
+}
+
+func TestS3TestSuite(t *testing.T) {
+	suite.Run(t, &S3TestSuite{})
+}
+
+func (s *S3TestSuite) SetupTest() {
+	s.server = filestoretest.NewS3Server("lebowski")
+	s.fs = s.newFS()
+	s.server.PutObject("lebowski", "1.lebowski", []byte("jeff"))
+	s.server.PutObject("lebowski", "2.lebowski", []byte("walter"))
+	s.server.PutObject("lebowski", "3.lebowski", []byte("donnie"))
+	s.server.PutObject("lebowski", "4.lebowski", []byte("maude"))
+	s.server.PutObject("lebowski", "dude/7.lebowski", []byte("bunny"))
+	s.server.PutObject("lebowski", "duderino/5.lebowski", []byte("jackie"))
+	s.server.PutObject("lebowski", "duderino/6.lebowski", []byte("nihilist"))
+}
+
+func (s *S3TestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *S3TestSuite) newFS(opts ...filestore.Option) filestore.FS {
+	opts = append([]filestore.Option{
+		filestore.WithEndpoint(s.server.URL),
+		filestore.WithRegion("us-east-1"),
+		filestore.WithCredentials("AKID", "SECRET", ""),
+	}, opts...)
+	return filestore.S3("lebowski", opts...)
+}
+
+func (s *S3TestSuite) TestStat() {
+	info, err := s.fs.Stat("1.lebowski")
+	s.Require().NoError(err, "Running 'stat' on valid file should not give an error")
+	s.Require().Equal("1.lebowski", info.Name())
+	s.Require().Equal(int64(4), info.Size())
+	s.Require().False(info.IsDir())
+	s.Require().Equal(s.server.Clock().Now(), info.ModTime())
+
+	info, err = s.fs.Stat("duderino")
+	s.Require().NoError(err, "Running 'stat' on a key prefix should treat it like a directory")
+	s.Require().Equal("duderino", info.Name())
+	s.Require().True(info.IsDir())
+
+	info, err = s.fs.Stat(".")
+	s.Require().NoError(err, "The root of the bucket should always exist")
+	s.Require().True(info.IsDir())
+
+	_, err = s.fs.Stat("does-not-exist.txt")
+	s.Require().Error(err, "Running 'stat' on non-existent file should give an error")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+
+	_, err = s.fs.Stat("dud")
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Partial key prefixes should not be directories")
+}
+
+func (s *S3TestSuite) TestWorkingDirectory() {
+	fs := s.fs
+	s.Require().Equal("/", fs.WorkingDirectory())
+
+	fs = fs.ChangeDirectory("duderino")
+	s.Require().Equal("/duderino", fs.WorkingDirectory())
+	s.Require().Equal("jackie", s.read(fs, "5.lebowski"), "Changing directory should change the key prefix.")
+
+	fs = fs.ChangeDirectory("../../..")
+	s.Require().Equal("/", fs.WorkingDirectory(), "Should not be able to cd above the root.")
+}
+
+func (s *S3TestSuite) TestExists() {
+	s.Require().True(s.fs.Exists("."), "Current directory should exist")
+	s.Require().True(s.fs.Exists("1.lebowski"), "Real file should exist")
+	s.Require().True(s.fs.Exists("duderino"), "Real directory should exist")
+	s.Require().True(s.fs.Exists("duderino/../dude"), "Real dir should exist when specifying relative path")
+	s.Require().False(s.fs.Exists("asldkfj"), "Non-existing entry should be false for Exists()")
+
+	s.Require().True(s.fs.ChangeDirectory("duderino").Exists("5.lebowski"), "Real file should exist even after cd")
+	s.Require().False(s.fs.ChangeDirectory("duderino").Exists("1.lebowski"), "Non-existing file should not exist even after cd")
+}
+
+func (s *S3TestSuite) TestRead() {
+	_, err := s.fs.Read("not-found.txt")
+	s.Require().Error(err, "Reading invalid file should fail")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+
+	_, err = s.fs.Read("duderino")
+	s.Require().Error(err, "Reading a directory should fail")
+
+	s.Require().Equal("jeff", s.read(s.fs, "1.lebowski"))
+	s.Require().Equal("jackie", s.read(s.fs, "duderino/5.lebowski"))
+
+	s.server.PutObject("lebowski", "empty.txt", nil)
+	s.Require().Equal("", s.read(s.fs, "empty.txt"))
+}
+
+func (s *S3TestSuite) TestRead_ranges() {
+	s.server.PutObject("lebowski", "rug.txt", []byte("it really tied the room together"))
+
+	file, err := s.fs.Read("rug.txt")
+	s.Require().NoError(err)
+	defer file.Close()
+
+	buffer := make([]byte, 4)
+	n, err := file.ReadAt(buffer, 10)
+	s.Require().NoError(err)
+	s.Require().Equal("tied", string(buffer[:n]))
+
+	n, err = file.ReadAt(buffer, 30)
+	s.Require().Equal(io.EOF, err, "Reading past the end should return EOF")
+	s.Require().Equal("er", string(buffer[:n]))
+
+	_, err = file.Seek(-8, io.SeekEnd)
+	s.Require().NoError(err)
+	data, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal("together", string(data))
+
+	var ranges []string
+	for _, req := range s.server.Requests() {
+		if req.Method == http.MethodGet && req.Key == "rug.txt" {
+			ranges = append(ranges, req.Header.Get("Range"))
+		}
+	}
+	s.Require().Equal([]string{"bytes=10-13", "bytes=30-31", "bytes=24-"}, ranges, "Should only download the bytes we read")
+}
+
+func (s *S3TestSuite) TestRead_overwritten() {
+	file, err := s.fs.Read("1.lebowski")
+	s.Require().NoError(err)
+	defer file.Close()
+
+	s.server.PutObject("lebowski", "1.lebowski", []byte("the dude"))
+	_, err = io.ReadAll(file)
+	s.Require().Error(err, "Should not read a different version of the object than the one we opened")
+}
+
+func (s *S3TestSuite) TestWrite() {
+	s.write("1.lebowski", "the dude")
+	s.Require().Equal("the dude", s.read(s.fs, "1.lebowski"), "Writing should overwrite existing files")
+
+	s.write("a/b/c/new.lebowski", "abides")
+	s.Require().Equal("abides", s.read(s.fs, "a/b/c/new.lebowski"))
+	s.Require().True(s.fs.Exists("a/b"), "Writing a file should implicitly create its directories")
+
+	file, err := s.fs.Write("pending.lebowski")
+	s.Require().NoError(err)
+	_, _ = file.Write([]byte("nope"))
+	s.Require().False(s.fs.Exists("pending.lebowski"), "Objects should not exist until closed")
+	s.Require().NoError(file.Close())
+	s.Require().True(s.fs.Exists("pending.lebowski"))
+
+	_, err = s.fs.Write(".")
+	s.Require().Error(err, "Should not be able to write to the root of the bucket")
+}
+
+func (s *S3TestSuite) TestWrite_multipart() {
+	fs := s.newFS(filestore.WithPartSize(5))
+
+	file, err := fs.Write("big.lebowski")
+	s.Require().NoError(err)
+	for _, chunk := range []string{"abc", "defg", "hijklmn", "op"} {
+		_, err = file.Write([]byte(chunk))
+		s.Require().NoError(err)
+	}
+	s.Require().Equal(1, s.server.Uploads(), "Parts should be uploaded as we write them")
+	s.Require().Nil(s.server.Object("lebowski", "big.lebowski"))
+
+	_, err = file.WriteAt([]byte("X"), 2)
+	s.Require().Error(err, "Should not be able to overwrite data that was already uploaded")
+	_, err = file.Seek(0, io.SeekStart)
+	s.Require().Error(err, "Should not be able to seek into data that was already uploaded")
+	_, err = file.WriteAt([]byte("P"), 15)
+	s.Require().NoError(err, "Should be able to overwrite data that hasn't been uploaded yet")
+	s.Require().NoError(file.Close())
+
+	s.Require().Equal(0, s.server.Uploads())
+	s.Require().Equal("abcdefghijklmnoP", s.read(fs, "big.lebowski"))
+
+	var parts []string
+	for _, req := range s.server.Requests() {
+		if req.Method == http.MethodPut && req.Query.Has("uploadId") {
+			parts = append(parts, req.Query.Get("partNumber"))
+		}
+	}
+	s.Require().Equal([]string{"1", "2", "3", "4"}, parts)
+}
+
+func (s *S3TestSuite) TestList() {
+	files, err := s.fs.List("1.lebowski")
+	s.Require().Error(err, "File list for non-directories should return an error.")
+	s.Require().Equal(0, len(files))
+
+	files, err = s.fs.List("nope")
+	s.Require().NoError(err, "File list for non-existent directories should not return an error.")
+	s.Require().Equal(0, len(files))
+
+	files, err = s.fs.List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(6, len(files))
+	s.assertFile(files[0], "1.lebowski")
+	s.assertFile(files[3], "4.lebowski")
+	s.assertDir(files[4], "dude")
+	s.assertDir(files[5], "duderino")
+
+	files, err = s.fs.ChangeDirectory("duderino").List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(files))
+	s.assertFile(files[0], "5.lebowski")
+	s.assertFile(files[1], "6.lebowski")
+	s.Require().Equal(int64(8), files[1].Size())
+}
+
+func (s *S3TestSuite) TestList_prefixQuery() {
+	s.server.ResetRequests()
+	files, err := s.fs.List(".", filestore.WithPattern("dude*"))
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(files))
+	s.assertDir(files[0], "dude")
+	s.assertDir(files[1], "duderino")
+
+	files, err = s.fs.List("duderino", filestore.WithPrefix("6"), filestore.WithExt("lebowski"))
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(files))
+	s.assertFile(files[0], "6.lebowski")
+
+	requests := s.server.Requests()
+	s.Require().Equal(2, len(requests), "Filtered listings should only need a single request")
+	s.Require().Equal("dude", requests[0].Query.Get("prefix"))
+	s.Require().Equal("duderino/6", requests[1].Query.Get("prefix"))
+}
+
+func (s *S3TestSuite) TestList_composedPrefixFilters() {
+	s.server.ResetRequests()
+	notDude := func(info filestore.FileInfo) bool {
+		return !filestore.WithPrefix("dude")(info)
+	}
+	files, err := s.fs.List(".", notDude)
+	s.Require().NoError(err)
+	s.Require().Equal(4, len(files), "Negated prefix filters should exclude the prefix, not require it")
+	s.assertFile(files[0], "1.lebowski")
+	s.assertFile(files[3], "4.lebowski")
+
+	oneOrTwo := func(info filestore.FileInfo) bool {
+		return filestore.WithPrefix("1")(info) || filestore.WithPattern("2.*")(info)
+	}
+	files, err = s.fs.List(".", oneOrTwo)
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(files), "Either prefix should be allowed")
+	s.assertFile(files[0], "1.lebowski")
+	s.assertFile(files[1], "2.lebowski")
+
+	requests := s.server.Requests()
+	s.Require().Equal(2, len(requests))
+	s.Require().Equal("", requests[0].Query.Get("prefix"), "Shouldn't narrow the query for custom filters")
+	s.Require().Equal("", requests[1].Query.Get("prefix"), "Shouldn't narrow the query for custom filters")
+}
+
+func (s *S3TestSuite) TestList_pagination() {
+	s.server.PageSize = 2
+
+	files, err := s.fs.List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(6, len(files), "Should follow continuation tokens to fetch every page")
+	s.assertFile(files[0], "1.lebowski")
+	s.assertDir(files[5], "duderino")
+}
+
+func (s *S3TestSuite) TestRemove() {
+	s.Require().NoError(s.fs.Remove("asldfjslkdfjasdf"), "Removing non-existent file should NOT return an error")
+
+	s.Require().NoError(s.fs.Remove("4.lebowski"))
+	s.Require().False(s.fs.Exists("4.lebowski"))
+
+	s.Require().NoError(s.fs.Remove("duderino"))
+	s.Require().False(s.fs.Exists("duderino"))
+	s.Require().False(s.fs.Exists("duderino/5.lebowski"))
+
+	files, _ := s.fs.List(".")
+	s.Require().Equal(4, len(files))
+
+	s.Require().NoError(s.fs.ChangeDirectory("dude").Remove("."))
+	s.Require().False(s.fs.Exists("dude"), "Removing the root of a cd'd store should only remove its objects")
+	s.Require().True(s.fs.Exists("1.lebowski"))
+
+	s.Require().NoError(s.fs.Remove("."))
+	s.Require().Empty(s.server.Keys("lebowski"), "Removing the root should remove everything.")
+}
+
+func (s *S3TestSuite) TestRemove_batches() {
+	for i := 0; i < 2500; i++ {
+		s.server.PutObject("lebowski", fmt.Sprintf("many/%d.txt", i), []byte("dude"))
+	}
+	s.server.ResetRequests()
+
+	s.Require().NoError(s.fs.Remove("many"))
+	s.Require().False(s.fs.Exists("many"))
+
+	methods := map[string]int{}
+	for _, req := range s.server.Requests() {
+		methods[req.Method]++
+	}
+	s.Require().Equal(3, methods[http.MethodPost], "Should delete objects 1000 at a time")
+	s.Require().Equal(0, methods[http.MethodDelete], "Should not delete objects one at a time")
+
+	s.server.PutObject("lebowski", "many/1.txt", []byte("dude"))
+	s.server.PutObject("lebowski", "many/2.txt", []byte("dude"))
+	s.server.Deny("lebowski", "many/1.txt")
+	s.Require().Error(s.fs.Remove("many"), "Keys that fail to delete should fail the removal")
+	s.Require().NotNil(s.server.Object("lebowski", "many/1.txt"))
+	s.Require().False(s.fs.Exists("many/2.txt"), "Should still delete the rest of the keys")
+}
+
+func (s *S3TestSuite) TestMove() {
+	s.Require().Error(s.fs.Move("nope.lebowski", "jeff.lebowski"), "Moving non-existent file should fail")
+	s.Require().Error(s.fs.Move("1.lebowski", "dude"), "Moving file onto directory should fail")
+	s.Require().Error(s.fs.Move("duderino", "1.lebowski"), "Moving directory onto file should fail")
+	s.Require().Error(s.fs.Move("duderino", "dude"), "Moving directory onto directory should fail")
+	s.Require().Error(s.fs.Move("duderino", "duderino/inner"), "Moving directory inside itself should fail")
+
+	s.Require().NoError(s.fs.Move("1.lebowski", "2.lebowski"), "Moving file onto another file should overwrite it")
+	s.Require().False(s.fs.Exists("1.lebowski"))
+	s.Require().Equal("jeff", s.read(s.fs, "2.lebowski"))
+
+	s.Require().NoError(s.fs.Move("duderino", "dude/a/b/el duderino"), "Moving dir to a new location should work")
+	s.Require().False(s.fs.Exists("duderino"))
+	s.Require().Equal("jackie", s.read(s.fs, "dude/a/b/el duderino/5.lebowski"))
+	s.Require().Equal("nihilist", s.read(s.fs, "dude/a/b/el duderino/6.lebowski"))
+}
+
+func (s *S3TestSuite) TestETag() {
+	local := filestore.Memory()
+	bucket := s.newFS(filestore.WithPartSize(5))
+	contents := map[string]string{
+		"empty.lebowski":   "",
+		"small.lebowski":   "jeff",
+		"exact.lebowski":   "dude!",
+		"two.lebowski":     "the dude!!",
+		"partial.lebowski": "the dude abides!",
+	}
+	for name, content := range contents {
+		s.Require().NoError(writeString(local, name, content))
+		s.Require().NoError(writeString(bucket, "etag/"+name, content))
+	}
+
+	infos, err := bucket.List("etag")
+	s.Require().NoError(err)
+	s.Require().Len(infos, len(contents))
+	for _, info := range infos {
+		expected, err := filestore.ComputeETag(local, info.Name(), 5)
+		s.Require().NoError(err)
+		s.Require().Equal(expected, filestore.ETag(info), "List: %s", info.Name())
+
+		info, err = bucket.Stat("etag/" + info.Name())
+		s.Require().NoError(err)
+		s.Require().Equal(expected, filestore.ETag(info), "Stat: %s", info.Name())
+	}
+
+	etag, err := filestore.ComputeETag(local, "empty.lebowski", 5)
+	s.Require().NoError(err)
+	s.Require().Equal("d41d8cd98f00b204e9800998ecf8427e", etag)
+	etag, err = filestore.ComputeETag(local, "partial.lebowski", 5)
+	s.Require().NoError(err)
+	s.Require().True(strings.HasSuffix(etag, "-4"), "Should be a 4 part upload: %s", etag)
+	etag, err = filestore.ComputeETag(local, "partial.lebowski", 1024)
+	s.Require().NoError(err)
+	s.Require().NotContains(etag, "-", "Should be a single upload w/ a larger part size")
+
+	_, err = filestore.ComputeETag(local, "nope.lebowski", 5)
+	s.Require().Error(err)
+	_, err = filestore.ComputeETag(local, "small.lebowski", 0)
+	s.Require().Error(err)
+
+	info, err := local.Stat("small.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("", filestore.ETag(info), "Stores w/o ETags should return an empty string")
+}
+
+func (s *S3TestSuite) TestStorageClass() {
+	info, err := s.fs.Stat("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("STANDARD", filestore.StorageClass(info))
+
+	archive := s.newFS(filestore.WithStorageClass("GLACIER"), filestore.WithPartSize(5))
+	s.Require().NoError(writeString(archive, "cold/small.lebowski", "jeff"))
+	s.Require().NoError(writeString(archive, "cold/big.lebowski", "the dude abides"))
+	s.Require().Equal("GLACIER", s.server.Object("lebowski", "cold/small.lebowski").StorageClass())
+	s.Require().Equal("GLACIER", s.server.Object("lebowski", "cold/big.lebowski").StorageClass(), "Should apply to multipart uploads")
+
+	info, err = s.fs.Stat("cold/small.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("GLACIER", filestore.StorageClass(info))
+
+	infos, err := s.fs.List("cold")
+	s.Require().NoError(err)
+	s.Require().Equal("GLACIER", filestore.StorageClass(infos[0]))
+
+	s.Require().NoError(s.fs.Move("cold", "colder"))
+	s.Require().Equal("GLACIER", s.server.Object("lebowski", "colder/small.lebowski").StorageClass(),
+		"Moving files should not change their storage class")
+
+	memoryInfo, err := filestore.Memory().Stat(".")
+	s.Require().NoError(err)
+	s.Require().Equal("", filestore.StorageClass(memoryInfo), "Stores w/o storage classes should have no class")
+}
+
+func (s *S3TestSuite) TestRestore() {
+	archive := s.newFS(filestore.WithStorageClass("DEEP_ARCHIVE"))
+	s.Require().NoError(writeString(archive, "cold.lebowski", "jeff"))
+
+	_, err := readString(s.fs, "cold.lebowski")
+	s.Require().Error(err, "Archived files can't be read until they're restored")
+
+	s.Require().NoError(filestore.Restore(s.fs, "cold.lebowski", 2))
+	content, err := readString(s.fs, "cold.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jeff", content)
+
+	s.server.Clock().Advance(49 * time.Hour)
+	_, err = readString(s.fs, "cold.lebowski")
+	s.Require().Error(err, "Restored files should be archived again after the given number of days")
+
+	s.Require().Error(filestore.Restore(s.fs, "cold.lebowski", 0))
+	s.Require().Error(filestore.Restore(s.fs, "1.lebowski", 1), "Can't restore files that aren't archived")
+	s.Require().True(errors.Is(filestore.Restore(filestore.Memory(), "1.lebowski", 1), filestore.ErrRestoreNotSupported))
+}
+
+func (s *S3TestSuite) TestPing() {
+	s.Require().NoError(filestore.Ping(context.Background(), s.fs))
+
+	missing := filestore.S3("nope", filestore.WithEndpoint(s.server.URL))
+	s.Require().Error(filestore.Ping(context.Background(), missing), "Ping should fail for missing buckets")
+}
+
+func (s *S3TestSuite) TestSigning() {
+	s.read(s.fs, "1.lebowski")
+	for _, req := range s.server.Requests() {
+		s.Require().True(strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		s.Require().NotEmpty(req.Header.Get("X-Amz-Date"))
+		s.Require().NotEmpty(req.Header.Get("X-Amz-Content-Sha256"))
+	}
+
+	s.server.ResetRequests()
+	anonymous := filestore.S3("lebowski", filestore.WithEndpoint(s.server.URL), filestore.WithCredentials("", "", ""))
+	s.read(anonymous, "1.lebowski")
+	for _, req := range s.server.Requests() {
+		s.Require().Empty(req.Header.Get("Authorization"), "Requests w/o credentials should be anonymous")
+	}
+}
+
+func (s *S3TestSuite) TestRequestHeaders() {
+	fs := s.newFS(filestore.WithRequesterPays(), filestore.WithHeaders(http.Header{"x-partner-id": {"dude"}}))
+	s.read(fs, "1.lebowski")
+	s.Require().NoError(writeString(fs, "abide.lebowski", "abide"))
+	s.Require().NoError(fs.Move("abide.lebowski", "abides.lebowski"))
+	_, err := fs.List(".")
+	s.Require().NoError(err)
+
+	for _, req := range s.server.Requests() {
+		s.Require().Equal("requester", req.Header.Get("X-Amz-Request-Payer"), "%s %s", req.Method, req.Key)
+		s.Require().Equal("dude", req.Header.Get("X-Partner-Id"), "%s %s", req.Method, req.Key)
+		s.Require().Contains(req.Header.Get("Authorization"), "x-partner-id", "Should sign the extra headers")
+	}
+
+	fs, err = filestore.Open("s3://lebowski?requester_pays=true&endpoint=" + url.QueryEscape(s.server.URL))
+	s.Require().NoError(err)
+	s.server.ResetRequests()
+	s.read(fs, "1.lebowski")
+	s.Require().Equal("requester", s.server.Requests()[0].Header.Get("X-Amz-Request-Payer"))
+}
+
+func (s *S3TestSuite) TestCredentialsProvider() {
+	clock := filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	calls := 0
+	var providerErr error
+	fs := s.newFS(filestore.WithClock(clock), filestore.WithCredentialsProvider(func(ctx context.Context) (filestore.S3Credentials, error) {
+		calls++
+		return filestore.S3Credentials{
+			AccessKeyID:     "AKID",
+			SecretAccessKey: "SECRET",
+			SessionToken:    fmt.Sprintf("token-%d", calls),
+			Expires:         clock.Now().Add(10 * time.Minute),
+		}, providerErr
+	}))
+	token := func() string {
+		requests := s.server.Requests()
+		return requests[len(requests)-1].Header.Get("X-Amz-Security-Token")
+	}
+
+	s.Require().Equal("jeff", s.read(fs, "1.lebowski"))
+	s.Require().Equal("walter", s.read(fs, "2.lebowski"))
+	s.Require().Equal(1, calls, "Should hang on to the credentials until they expire")
+	s.Require().Equal("token-1", token())
+
+	clock.Advance(9*time.Minute + 30*time.Second)
+	s.Require().Equal("jeff", s.read(fs, "1.lebowski"))
+	s.Require().Equal(2, calls, "Should refresh the credentials shortly before they expire")
+	s.Require().Equal("token-2", token())
+
+	s.server.ExpireSessionToken("token-2")
+	s.Require().Equal("jeff", s.read(fs, "1.lebowski"))
+	s.Require().Equal(3, calls, "Should refresh the credentials when S3 rejects them")
+	s.Require().Equal("token-3", token())
+
+	providerErr = errors.New("vault is sealed")
+	s.server.ExpireSessionToken("token-3")
+	_, err := fs.Stat("1.lebowski")
+	s.Require().ErrorIs(err, providerErr)
+
+	static := s.newFS(filestore.WithCredentials("AKID", "SECRET", "static"))
+	s.server.ExpireSessionToken("static")
+	s.server.ResetRequests()
+	_, err = static.Stat("1.lebowski")
+	s.Require().Error(err)
+	s.Require().Len(s.server.Requests(), 1, "Should not retry w/ static credentials")
+}
+
+// A HEAD request for a forbidden object gets a 403 w/o a body, just like one signed w/ bad credentials.
+// That's worth one refresh, but not a new set of credentials for every single Stat().
+func (s *S3TestSuite) TestCredentialsProvider_forbidden() {
+	calls := 0
+	fs := s.newFS(filestore.WithCredentialsProvider(func(ctx context.Context) (filestore.S3Credentials, error) {
+		calls++
+		return filestore.S3Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: fmt.Sprintf("token-%d", calls)}, nil
+	}))
+	s.server.Deny("lebowski", "1.lebowski")
+	s.server.ResetRequests()
+
+	for i := 0; i < 3; i++ {
+		_, err := fs.Stat("1.lebowski")
+		s.Require().Error(err)
+	}
+	s.Require().Equal(2, calls, "Should only refresh the credentials once")
+	s.Require().Len(s.server.Requests(), 4, "Should only retry once")
+
+	// S3 explaining that the credentials expired is always worth a refresh.
+	s.server.ExpireSessionToken("token-2")
+	s.Require().Equal("walter", s.read(fs, "2.lebowski"))
+	s.Require().Equal(3, calls)
+}
+
+func (s *S3TestSuite) TestRequestIDs() {
+	ctx := filestore.ContextWithIdentity(context.Background(), filestore.Identity{TraceID: "trace-123"})
+	files := filestore.ForRequest(s.newFS(filestore.WithPartSize(5)), ctx)
+	s.Require().NoError(writeString(files, "big.lebowski", "the dude abides"))
+
+	invocations := map[string]bool{}
+	for _, req := range s.server.Requests() {
+		s.Require().Equal("trace-123", req.Header.Get("X-Correlation-Id"))
+		s.Require().Equal("attempt=1; max=2", req.Header.Get("Amz-Sdk-Request"))
+		invocations[req.Header.Get("Amz-Sdk-Invocation-Id")] = true
+	}
+	s.Require().Len(invocations, len(s.server.Requests()), "Each operation should have its own token")
+
+	s.server.ExpireSessionToken("expired")
+	_, err := s.newFS(filestore.WithCredentials("AKID", "SECRET", "expired")).Stat("1.lebowski")
+	s.Require().Error(err)
+	s.Require().Regexp("^FAKE[0-9]+$", filestore.ProviderRequestID(err), "Should use the header when there's no body")
+	s.Require().Contains(err.Error(), filestore.ProviderRequestID(err))
+
+	_, err = filestore.S3("walter", filestore.WithEndpoint(s.server.URL)).List(".")
+	s.Require().ErrorContains(err, "NoSuchBucket")
+	s.Require().Regexp("^FAKE[0-9]+$", filestore.ProviderRequestID(err))
+	s.Require().Empty(filestore.ProviderRequestID(errors.New("nope")))
+}
+
+func (s *S3TestSuite) TestRequestIDs_retries() {
+	tokens := 0
+	fs := s.newFS(filestore.WithCorrelationHeader(""), filestore.WithCredentialsProvider(func(ctx context.Context) (filestore.S3Credentials, error) {
+		tokens++
+		return filestore.S3Credentials{AccessKeyID: "AKID", SecretAccessKey: "SECRET", SessionToken: fmt.Sprintf("token-%d", tokens)}, nil
+	}))
+	s.server.ExpireSessionToken("token-1")
+	_, err := fs.Stat("1.lebowski")
+	s.Require().NoError(err)
+
+	requests := s.server.Requests()
+	s.Require().Len(requests, 2)
+	s.Require().Equal(requests[0].Header.Get("Amz-Sdk-Invocation-Id"), requests[1].Header.Get("Amz-Sdk-Invocation-Id"),
+		"Retries should use the same token")
+	s.Require().Equal("attempt=2; max=2", requests[1].Header.Get("Amz-Sdk-Request"))
+	s.Require().Empty(requests[0].Header.Get("X-Correlation-Id"))
+}
+
+func (s *S3TestSuite) TestEncryption() {
+	kms := s.newFS(filestore.WithKMSEncryption("alias/dude"), filestore.WithACL("bucket-owner-full-control"), filestore.WithPartSize(5))
+	s.Require().NoError(writeString(kms, "small.lebowski", "jeff"))
+	s.Require().NoError(writeString(kms, "big.lebowski", "the dude abides"))
+	s.Require().NoError(kms.Move("small.lebowski", "moved.lebowski"))
+
+	creates := 0
+	for _, req := range s.server.Requests() {
+		if req.Query.Has("partNumber") || (req.Method != http.MethodPut && !req.Query.Has("uploads")) {
+			continue
+		}
+		creates++
+		s.Require().Equal("aws:kms", req.Header.Get("X-Amz-Server-Side-Encryption"), "%s %s", req.Method, req.Key)
+		s.Require().Equal("alias/dude", req.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+		s.Require().Equal("bucket-owner-full-control", req.Header.Get("X-Amz-Acl"))
+	}
+	s.Require().Equal(3, creates, "Should apply to simple uploads, multipart uploads, and copies")
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	sum := md5.Sum(key)
+	sseC := s.newFS(filestore.WithCustomerKey(key), filestore.WithPartSize(5))
+	s.server.ResetRequests()
+	s.Require().NoError(writeString(sseC, "big.lebowski", "the dude abides"))
+	s.Require().NoError(sseC.Move("big.lebowski", "moved.lebowski"))
+	s.read(sseC, "moved.lebowski")
+
+	for _, req := range s.server.Requests() {
+		if req.Method == http.MethodDelete || req.Key == "" || req.Query.Has("uploadId") && !req.Query.Has("partNumber") {
+			continue
+		}
+		s.Require().Equal("AES256", req.Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm"), "%s %s", req.Method, req.Key)
+		s.Require().Equal(base64.StdEncoding.EncodeToString(key), req.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key"))
+		s.Require().Equal(base64.StdEncoding.EncodeToString(sum[:]), req.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5"))
+		if req.Header.Get("X-Amz-Copy-Source") != "" {
+			s.Require().Equal(base64.StdEncoding.EncodeToString(key), req.Header.Get("X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key"),
+				"Should be able to decrypt the source of a copy")
+		}
+	}
+}
+
+func (s *S3TestSuite) TestOpen() {
+	s.Require().Contains(filestore.Schemes(), "s3")
+
+	fs, err := filestore.Open("s3://lebowski/reports/2022?region=us-west-2")
+	s.Require().NoError(err)
+	s.Require().Equal("/reports/2022", fs.WorkingDirectory())
+
+	_, err = filestore.Open("s3:///reports")
+	s.Require().Error(err, "Should require a bucket name")
+
+	fs, err = filestore.Open("s3://lebowski/dude?path_style=true&endpoint=" + url.QueryEscape(s.server.URL))
+	s.Require().NoError(err)
+	s.Require().Equal("bunny", s.read(fs, "7.lebowski"), "Should talk to the custom endpoint")
+
+	_, err = filestore.Open("s3://lebowski?path_style=maybe")
+	s.Require().Error(err, "Should reject invalid path_style values")
+}
+
+func (s *S3TestSuite) TestEndpoint() {
+	var hosts []string
+	var paths []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Host)
+		paths = append(paths, req.URL.Path)
+		return nil, errors.New("nope")
+	})}
+
+	spaces := filestore.S3("lebowski",
+		filestore.WithEndpoint("nyc3.digitaloceanspaces.com"),
+		filestore.WithPathStyle(false),
+		filestore.WithRegion("nyc3"),
+		filestore.WithHTTPClient(client),
+	)
+	s.Require().False(spaces.Exists("rug.txt"))
+	s.Require().Equal("lebowski.nyc3.digitaloceanspaces.com", hosts[len(hosts)-1])
+	s.Require().Equal("/rug.txt", paths[len(paths)-1])
+
+	minio := filestore.S3("lebowski", filestore.WithEndpoint("http://localhost:9000/"), filestore.WithHTTPClient(client))
+	s.Require().False(minio.Exists("rug.txt"))
+	s.Require().Equal("localhost:9000", hosts[len(hosts)-1])
+	s.Require().Equal("/lebowski/rug.txt", paths[len(paths)-1], "Custom endpoints should default to path-style")
+
+	aws := filestore.S3("lebowski", filestore.WithRegion("us-west-2"), filestore.WithPathStyle(true), filestore.WithHTTPClient(client))
+	s.Require().False(aws.Exists("rug.txt"))
+	s.Require().Equal("s3.us-west-2.amazonaws.com", hosts[len(hosts)-1])
+	s.Require().Equal("/lebowski/rug.txt", paths[len(paths)-1])
+}
+
+// Paths that no file system can represent should be rejected consistently by every operation.
+func (s *S3TestSuite) TestInvalidPaths() {
+	fs := s.fs
+	invalid := "bad\x00.lebowski"
+
+	_, err := fs.Stat(invalid)
+	s.Require().Error(err)
+	s.Require().False(fs.Exists(invalid))
+	_, err = fs.Read(invalid)
+	s.Require().Error(err)
+	_, err = fs.Write(invalid)
+	s.Require().Error(err)
+	_, err = fs.List(invalid)
+	s.Require().Error(err)
+	s.Require().Error(fs.Remove(invalid))
+	s.Require().Error(fs.Move(invalid, "1.lebowski"))
+	s.Require().Error(fs.Move("1.lebowski", invalid))
+}
+
+func (s *S3TestSuite) write(name string, content string) {
+	s.Require().NoError(writeString(s.fs, name, content))
+}
+
+func (s *S3TestSuite) read(fs filestore.FS, name string) string {
+	content, _ := readString(fs, name)
+	return content
+}
+
+func (s *S3TestSuite) assertFile(file filestore.FileInfo, name string) {
+	s.Require().Equal(name, file.Name())
+	s.Require().False(file.IsDir())
+}
+
+func (s *S3TestSuite) assertDir(file filestore.FileInfo, name string) {
+	s.Require().Equal(name, file.Name())
+	s.Require().True(file.IsDir())
+}
+
+// roundTripFunc lets a plain function act as an HTTP transport so we can see where requests are sent.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
@@ -0,0 +1,386 @@
+package filestore
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AtVersion returns a read-only view of the bucket as it looked at the given moment. Every
+// file resolves to the latest version of its object that was written at or before that time,
+// and files that had been deleted (or not yet created) simply don't exist. This only makes
+// sense for buckets that have versioning enabled; otherwise S3 only remembers the current
+// version of each object.
+//
+// Any attempt to Write(), Remove(), or Move() files in the resulting FS fails with ErrReadOnly.
+//
+// Example:
+//
+//	// What did the config look like yesterday?
+//	yesterday := bucket.AtVersion(time.Now().Add(-24 * time.Hour))
+//	config, err := yesterday.Read("conf/app.json")
+func (s S3FS) AtVersion(at time.Time) FS {
+	return &s3VersionFS{s3: s, at: at}
+}
+
+// Versions returns every version of the file, newest first, including the delete markers that
+// record when the file was removed. The bucket must have versioning enabled for there to be more
+// than one version.
+func (s S3FS) Versions(filePath string) ([]VersionInfo, error) {
+	key, err := s.key(filePath)
+	if err != nil {
+		return nil, newPathError("s3", "versions", filePath, err)
+	}
+	if key == "" {
+		return nil, newPathError("s3", "versions", filePath, errIsDirectory)
+	}
+
+	versions, err := s.listVersions(s.requestContext(), key)
+	if err != nil {
+		return nil, newPathError("s3", "versions", filePath, err)
+	}
+	var results []VersionInfo
+	for _, version := range versions {
+		// Listing by prefix also gives us "foo.txt.bak" and "foo.txt/bar.txt" when you ask for "foo.txt".
+		if version.Key != key {
+			continue
+		}
+		results = append(results, VersionInfo{
+			ID:      version.VersionID,
+			ModTime: version.modTime(),
+			Size:    version.Size,
+			Latest:  version.IsLatest,
+			Deleted: version.deleteMarker(),
+		})
+	}
+	if len(results) == 0 {
+		return nil, newPathError("s3", "versions", filePath, fs.ErrNotExist)
+	}
+	return results, nil
+}
+
+// ReadVersion opens a specific version of the file for reading. Just like Read(), data is downloaded
+// lazily as you read it.
+func (s S3FS) ReadVersion(filePath string, versionID string) (ReaderFile, error) {
+	key, err := s.key(filePath)
+	if err != nil {
+		return nil, newPathError("s3", "read version", filePath, err)
+	}
+	if key == "" {
+		return nil, newPathError("s3", "read version", filePath, errIsDirectory)
+	}
+
+	versions, err := s.listVersions(s.requestContext(), key)
+	if err != nil {
+		return nil, newPathError("s3", "read version", filePath, err)
+	}
+	for _, version := range versions {
+		switch {
+		case version.Key != key || version.VersionID != versionID:
+			continue
+		case version.deleteMarker():
+			return nil, newPathError("s3", "read version", filePath, fmt.Errorf("%s is a delete marker", versionID))
+		}
+		return s.readVersion(key, versionID, version.Size), nil
+	}
+	return nil, newPathError("s3", "read version", filePath, fmt.Errorf("no version %s: %w", versionID, fs.ErrNotExist))
+}
+
+// RestoreVersion makes an older version of the file its current version again by copying that
+// version on top of the current one. This works even if the file has since been deleted.
+func (s S3FS) RestoreVersion(filePath string, versionID string) error {
+	key, err := s.key(filePath)
+	if err != nil {
+		return newPathError("s3", "restore version", filePath, err)
+	}
+	if key == "" {
+		return newPathError("s3", "restore version", filePath, errIsDirectory)
+	}
+
+	source := uriEncode("/"+s.bucket+"/"+key, false) + "?versionId=" + url.QueryEscape(versionID)
+	header := s.copyHeader("")
+	header.Set("X-Amz-Copy-Source", source)
+	result := struct{}{}
+	if err := s.client.doXML(s.requestContext(), s3Request{method: http.MethodPut, bucket: s.bucket, key: key, header: header}, &result); err != nil {
+		return newPathError("s3", "restore version", filePath, err)
+	}
+	return nil
+}
+
+// s3VersionFS is the read-only, point-in-time view of a bucket created by AtVersion().
+type s3VersionFS struct {
+	s3 S3FS
+	at time.Time
+}
+
+// WorkingDirectory returns the current FS context's path/directory.
+func (v s3VersionFS) WorkingDirectory() string {
+	return v.s3.WorkingDirectory()
+}
+
+// ChangeDirectory returns a new FS that is rooted in the given "subdirectory" (key prefix) of this
+// FS, pinned to the same point in time.
+func (v s3VersionFS) ChangeDirectory(dir string) FS {
+	return &s3VersionFS{s3: *v.s3.ChangeDirectory(dir).(*S3FS), at: v.at}
+}
+
+// withContext makes every request this view sends use the given context; see ForRequest().
+func (v s3VersionFS) withContext(ctx context.Context) FS {
+	v.s3.ctx = ctx
+	return &v
+}
+
+func (v s3VersionFS) requestContext() context.Context {
+	return v.s3.requestContext()
+}
+
+// Stat fetches metadata about the file as it was at this view's point in time.
+func (v s3VersionFS) Stat(filePath string) (FileInfo, error) {
+	key, err := v.s3.key(filePath)
+	if err != nil {
+		return nil, newPathError("s3", "stat", filePath, err)
+	}
+	info, _, err := v.stat(v.s3.requestContext(), key)
+	if err != nil {
+		return nil, newPathError("s3", "stat", filePath, err)
+	}
+	return info, nil
+}
+
+// stat resolves the key to the version that was current at this view's point in time. We list every
+// version under the key so that we can also tell whether it was a "directory" at the time.
+func (v s3VersionFS) stat(ctx context.Context, key string) (s3FileInfo, s3Version, error) {
+	if key == "" {
+		return s3FileInfo{name: "/", dir: true}, s3Version{}, nil
+	}
+
+	versions, err := v.s3.listVersions(ctx, key)
+	if err != nil {
+		return s3FileInfo{}, s3Version{}, err
+	}
+	current := v.resolve(versions)
+	if version, ok := current[key]; ok {
+		return version.info(path.Base(key)), version, nil
+	}
+	for childKey := range current {
+		if strings.HasPrefix(childKey, key+"/") {
+			return s3FileInfo{name: path.Base(key), dir: true}, s3Version{}, nil
+		}
+	}
+	return s3FileInfo{}, s3Version{}, fs.ErrNotExist
+}
+
+// Exists returns true when the file/directory existed at this view's point in time.
+func (v s3VersionFS) Exists(filePath string) bool {
+	_, err := v.Stat(filePath)
+	return err == nil
+}
+
+// Read opens the version of the file that was current at this view's point in time. Just like
+// S3FS.Read(), data is downloaded lazily as you read it.
+func (v s3VersionFS) Read(filePath string) (ReaderFile, error) {
+	key, err := v.s3.key(filePath)
+	if err != nil {
+		return nil, newPathError("s3", "open", filePath, err)
+	}
+	info, version, err := v.stat(v.s3.requestContext(), key)
+	if err != nil {
+		return nil, newPathError("s3", "open", filePath, err)
+	}
+	if info.dir {
+		return nil, newPathError("s3", "open", filePath, errIsDirectory)
+	}
+	return v.s3.readVersion(key, version.VersionID, info.size), nil
+}
+
+// Write always fails with ErrReadOnly; you can't change the past.
+func (v s3VersionFS) Write(filePath string) (WriterFile, error) {
+	return nil, newPathError("s3", "write", filePath, ErrReadOnly)
+}
+
+// List performs the equivalent of the "ls" command, returning the files and directories that were
+// in the target dirPath at this view's point in time. S3 can't group versions by "directory" for us,
+// so this lists every version of every object below dirPath.
+func (v s3VersionFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	key, err := v.s3.key(dirPath)
+	if err != nil {
+		return nil, newPathError("s3", "list files", dirPath, err)
+	}
+
+	dirPrefix := ""
+	if key != "" {
+		dirPrefix = key + "/"
+	}
+	namePrefix := filtersPrefix(filters)
+
+	ctx := v.s3.requestContext()
+	versions, err := v.s3.listVersions(ctx, dirPrefix+namePrefix)
+	if err != nil {
+		return nil, newPathError("s3", "list files", dirPath, err)
+	}
+
+	var infos []FileInfo
+	dirs := map[string]bool{}
+	for childKey, version := range v.resolve(versions) {
+		name := strings.TrimPrefix(childKey, dirPrefix)
+		switch slash := strings.Index(name, "/"); {
+		case name == "":
+			// Directory marker objects like "reports/" are not files.
+		case slash >= 0:
+			dirs[name[:slash]] = true
+		default:
+			infos = append(infos, version.info(name))
+		}
+	}
+	for name := range dirs {
+		infos = append(infos, s3FileInfo{name: name, dir: true})
+	}
+
+	// An empty listing might be because the path was a file, not a directory.
+	if len(infos) == 0 && namePrefix == "" && key != "" {
+		if info, _, err := v.stat(ctx, key); err == nil && !info.dir {
+			return nil, newPathError("s3", "list files", dirPath, ErrNotDirectory)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	results, err := filterFiles(infos, filters)
+	if err != nil {
+		return nil, newPathError("s3", "list files", dirPath, err)
+	}
+	return results, nil
+}
+
+// Remove always fails with ErrReadOnly; you can't change the past.
+func (v s3VersionFS) Remove(fileOrDirPath string) error {
+	return newPathError("s3", "remove", fileOrDirPath, ErrReadOnly)
+}
+
+// Move always fails with ErrReadOnly; you can't change the past.
+func (v s3VersionFS) Move(fromPath string, toPath string) error {
+	return newPathError("s3", "move", fromPath, ErrReadOnly)
+}
+
+// resolve picks the version of each key that was current at this view's point in time. Keys whose
+// current version was a delete marker (or that didn't exist yet) are left out entirely.
+func (v s3VersionFS) resolve(versions []s3Version) map[string]s3Version {
+	current := map[string]s3Version{}
+	for _, version := range versions {
+		modTime := version.modTime()
+		if modTime.After(v.at) {
+			continue
+		}
+		// S3 lists each key's versions newest first, so on a tie, the one we saw first wins.
+		if existing, ok := current[version.Key]; ok && !modTime.After(existing.modTime()) {
+			continue
+		}
+		current[version.Key] = version
+	}
+	for key, version := range current {
+		if version.deleteMarker() {
+			delete(current, key)
+		}
+	}
+	return current
+}
+
+// s3Version is a single entry in the results of a ListObjectVersions call. The same struct
+// describes both object versions and delete markers; the element name tells you which.
+type s3Version struct {
+	XMLName      xml.Name
+	Key          string `xml:"Key"`
+	VersionID    string `xml:"VersionId"`
+	IsLatest     bool   `xml:"IsLatest"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+func (version s3Version) deleteMarker() bool {
+	return version.XMLName.Local == "DeleteMarker"
+}
+
+func (version s3Version) modTime() time.Time {
+	modTime, _ := time.Parse(time.RFC3339, version.LastModified)
+	return modTime
+}
+
+func (version s3Version) info(name string) s3FileInfo {
+	return s3FileInfo{name: name, size: version.Size, modTime: version.modTime(), etag: version.ETag, storageClass: version.StorageClass}
+}
+
+// listVersions fetches every version and delete marker whose key starts with the given prefix,
+// following the key/version markers until we've seen everything. Versions are returned in the
+// order S3 lists them: by key, then newest to oldest.
+func (s S3FS) listVersions(ctx context.Context, prefix string) ([]s3Version, error) {
+	var versions []s3Version
+	keyMarker, versionMarker := "", ""
+	for {
+		query := url.Values{"versions": {""}, "prefix": {prefix}}
+		if keyMarker != "" {
+			query.Set("key-marker", keyMarker)
+		}
+		if versionMarker != "" {
+			query.Set("version-id-marker", versionMarker)
+		}
+
+		// Versions and delete markers are interleaved in the response, so we capture them all
+		// with ",any" to preserve their order, then weed out everything else.
+		page := struct {
+			IsTruncated         bool        `xml:"IsTruncated"`
+			NextKeyMarker       string      `xml:"NextKeyMarker"`
+			NextVersionIDMarker string      `xml:"NextVersionIdMarker"`
+			Entries             []s3Version `xml:",any"`
+		}{}
+		if err := s.client.doXML(ctx, s3Request{method: http.MethodGet, bucket: s.bucket, query: query}, &page); err != nil {
+			return nil, err
+		}
+
+		for _, entry := range page.Entries {
+			if entry.XMLName.Local == "Version" || entry.XMLName.Local == "DeleteMarker" {
+				versions = append(versions, entry)
+			}
+		}
+		if !page.IsTruncated || page.NextKeyMarker == "" {
+			return versions, nil
+		}
+		keyMarker, versionMarker = page.NextKeyMarker, page.NextVersionIDMarker
+	}
+}
+
+// readVersion lazily downloads a specific version of an object using ranged GET requests.
+func (s S3FS) readVersion(key string, versionID string, size int64) ReaderFile {
+	return &rangeReaderFile{size: size, fetch: func(offset int64, length int64) (io.ReadCloser, error) {
+		rangeHeader := fmt.Sprintf("bytes=%d-", offset)
+		if length >= 0 {
+			rangeHeader += strconv.FormatInt(offset+length-1, 10)
+		}
+		req := s3Request{
+			method: http.MethodGet,
+			bucket: s.bucket,
+			key:    key,
+			query:  url.Values{"versionId": {versionID}},
+			header: s.keyHeader(),
+		}
+		req.header.Set("Range", rangeHeader)
+		res, err := s.client.do(s.requestContext(), req)
+		if err != nil {
+			return nil, err
+		}
+		return res.Body, nil
+	}}
+}
+
+var _ FS = s3VersionFS{}
+var _ requestBinder = s3VersionFS{}
+var _ Versioner = S3FS{}
@@ -0,0 +1,258 @@
+package filestore_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type S3VersionTestSuite struct {
+	suite.Suite
+	server *filestoretest.S3Server
+	fs     *filestore.S3FS
+	start  time.Time
+}
+
+func TestS3VersionTestSuite(t *testing.T) {
+	suite.Run(t, &S3VersionTestSuite{})
+}
+
+// SetupTest builds up some history, one second apart:
+//
+//	+0s: 1.lebowski=jeff, dude/7.lebowski=bunny
+//	+1s: 1.lebowski=walter, 2.lebowski=donnie
+//	+2s: dude/7.lebowski deleted
+//	+3s: 1.lebowski deleted, dude/8.lebowski=maude
+func (s *S3VersionTestSuite) SetupTest() {
+	s.server = filestoretest.NewS3Server("lebowski")
+	s.server.EnableVersioning("lebowski")
+	s.fs = filestore.S3("lebowski",
+		filestore.WithEndpoint(s.server.URL),
+		filestore.WithRegion("us-east-1"),
+		filestore.WithCredentials("AKID", "SECRET", ""),
+	)
+
+	clock := s.server.Clock()
+	s.start = clock.Now()
+	s.server.PutObject("lebowski", "1.lebowski", []byte("jeff"))
+	s.server.PutObject("lebowski", "dude/7.lebowski", []byte("bunny"))
+	clock.Advance(time.Second)
+	s.server.PutObject("lebowski", "1.lebowski", []byte("walter"))
+	s.server.PutObject("lebowski", "2.lebowski", []byte("donnie"))
+	clock.Advance(time.Second)
+	s.server.DeleteObject("lebowski", "dude/7.lebowski")
+	clock.Advance(time.Second)
+	s.server.DeleteObject("lebowski", "1.lebowski")
+	s.server.PutObject("lebowski", "dude/8.lebowski", []byte("maude"))
+}
+
+func (s *S3VersionTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *S3VersionTestSuite) at(seconds int) filestore.FS {
+	return s.fs.AtVersion(s.start.Add(time.Duration(seconds) * time.Second))
+}
+
+func (s *S3VersionTestSuite) names(fsys filestore.FS, dir string) []string {
+	infos, err := fsys.List(dir)
+	s.Require().NoError(err)
+
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	return names
+}
+
+func (s *S3VersionTestSuite) TestStat() {
+	info, err := s.at(0).Stat("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(4), info.Size())
+	s.Require().Equal(s.start, info.ModTime())
+
+	info, err = s.at(1).Stat("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(6), info.Size(), "Should see the version written at exactly that time")
+
+	_, err = s.at(3).Stat("1.lebowski")
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Deleted files should not exist after the delete")
+	_, err = s.at(0).Stat("2.lebowski")
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Files should not exist before they were written")
+	_, err = s.at(-1).Stat("1.lebowski")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+
+	info, err = s.at(0).Stat("dude")
+	s.Require().NoError(err)
+	s.Require().True(info.IsDir())
+	_, err = s.at(2).Stat("dude")
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Directories should disappear when their files do")
+
+	s.Require().True(s.at(3).Exists("dude"))
+	s.Require().True(s.at(3).Exists("."))
+	s.Require().False(s.at(3).Exists("dud"))
+}
+
+func (s *S3VersionTestSuite) TestRead() {
+	content, err := readString(s.at(0), "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jeff", content)
+
+	content, err = readString(s.at(2), "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("walter", content)
+
+	content, err = readString(s.at(1).ChangeDirectory("dude"), "7.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("bunny", content, "Changing directories should stay pinned to the same time")
+
+	_, err = s.at(3).Read("1.lebowski")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = s.at(0).Read("dude")
+	s.Require().Error(err, "Should not be able to read directories")
+}
+
+func (s *S3VersionTestSuite) TestList() {
+	s.Require().Equal([]string{"1.lebowski", "dude"}, s.names(s.at(0), "."))
+	s.Require().Equal([]string{"1.lebowski", "2.lebowski", "dude"}, s.names(s.at(1), "."))
+	s.Require().Equal([]string{"1.lebowski", "2.lebowski"}, s.names(s.at(2), "."))
+	s.Require().Equal([]string{"2.lebowski", "dude"}, s.names(s.at(3), "."))
+	s.Require().Equal([]string{"7.lebowski"}, s.names(s.at(1), "dude"))
+	s.Require().Equal([]string{"8.lebowski"}, s.names(s.at(3), "dude"))
+	s.Require().Empty(s.names(s.at(-1), "."))
+
+	infos, err := s.at(1).List(".", filestore.WithPrefix("2"))
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(infos))
+	s.Require().Equal("2.lebowski", infos[0].Name())
+
+	infos, err = s.at(1).List(".", func(info filestore.FileInfo) bool { return !filestore.WithPrefix("2")(info) })
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(infos), "Negated prefix filters should exclude the prefix, not require it")
+	s.Require().Equal("1.lebowski", infos[0].Name())
+
+	_, err = s.at(1).List("1.lebowski")
+	s.Require().Error(err, "Listing a file should fail")
+}
+
+func (s *S3VersionTestSuite) TestList_pagination() {
+	s.server.PageSize = 2
+	s.Require().Equal([]string{"1.lebowski", "2.lebowski", "dude"}, s.names(s.at(1), "."))
+	s.Require().Equal([]string{"2.lebowski", "dude"}, s.names(s.at(3), "."))
+}
+
+func (s *S3VersionTestSuite) TestReadOnly() {
+	past := s.at(1)
+	_, err := past.Write("1.lebowski")
+	s.Require().True(errors.Is(err, filestore.ErrReadOnly))
+	s.Require().True(errors.Is(past.Remove("1.lebowski"), filestore.ErrReadOnly))
+	s.Require().True(errors.Is(past.Move("1.lebowski", "3.lebowski"), filestore.ErrReadOnly))
+
+	s.Require().False(s.fs.Exists("1.lebowski"), "Nothing should have been restored")
+}
+
+func (s *S3VersionTestSuite) TestVersions() {
+	versions, err := filestore.Versions(s.fs, "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(3, len(versions))
+
+	s.Require().True(versions[0].Deleted)
+	s.Require().True(versions[0].Latest)
+	s.Require().Equal(s.start.Add(3*time.Second), versions[0].ModTime)
+
+	s.Require().False(versions[1].Deleted)
+	s.Require().False(versions[1].Latest)
+	s.Require().Equal(int64(6), versions[1].Size)
+	s.Require().Equal(s.start.Add(time.Second), versions[1].ModTime)
+
+	s.Require().Equal(int64(4), versions[2].Size)
+	s.Require().Equal(s.start, versions[2].ModTime)
+
+	versions, err = filestore.Versions(s.fs.ChangeDirectory("dude"), "8.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(versions))
+
+	_, err = filestore.Versions(s.fs, "dude")
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Directories don't have versions")
+	_, err = filestore.Versions(s.fs, "1.leb")
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Should not include versions of other keys w/ the same prefix")
+}
+
+func (s *S3VersionTestSuite) TestRestoreVersion() {
+	versions, err := s.fs.Versions("1.lebowski")
+	s.Require().NoError(err)
+
+	s.Require().NoError(filestore.RestoreVersion(s.fs, "1.lebowski", versions[2].ID))
+	content, err := readString(s.fs, "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jeff", content, "Restoring should undelete the file w/ the old contents")
+
+	restored, err := s.fs.Versions("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(4, len(restored), "Restoring should add a new version, not discard history")
+
+	s.Require().Error(s.fs.RestoreVersion("1.lebowski", versions[0].ID), "Can't restore a delete marker")
+	s.Require().Error(s.fs.RestoreVersion("1.lebowski", "nope"))
+	s.Require().Error(s.fs.RestoreVersion(".", versions[2].ID))
+}
+
+func (s *S3VersionTestSuite) TestReadVersion() {
+	versions, err := s.fs.Versions("1.lebowski")
+	s.Require().NoError(err)
+
+	file, err := filestore.ReadVersion(s.fs, "1.lebowski", versions[2].ID)
+	s.Require().NoError(err)
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal("jeff", string(content), "Should read old versions even after the file was deleted")
+
+	_, err = s.fs.ReadVersion("1.lebowski", versions[0].ID)
+	s.Require().Error(err, "Can't read a delete marker")
+	_, err = s.fs.ReadVersion("1.lebowski", "nope")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = s.fs.ReadVersion(".", versions[2].ID)
+	s.Require().Error(err)
+}
+
+func (s *S3VersionTestSuite) TestVersions_notSupported() {
+	memory := filestore.Memory()
+	s.Require().NoError(writeString(memory, "1.lebowski", "jeff"))
+
+	_, err := filestore.Versions(memory, "1.lebowski")
+	s.Require().True(errors.Is(err, filestore.ErrVersioningNotSupported))
+	_, err = filestore.ReadVersion(memory, "1.lebowski", "1")
+	s.Require().True(errors.Is(err, filestore.ErrVersioningNotSupported))
+	err = filestore.RestoreVersion(memory, "1.lebowski", "1")
+	s.Require().True(errors.Is(err, filestore.ErrVersioningNotSupported))
+}
+
+func (s *S3VersionTestSuite) TestSetImmutable() {
+	until := s.server.Clock().Now().Add(time.Hour)
+	s.Require().NoError(filestore.SetImmutable(s.fs, "2.lebowski", until))
+	s.Require().Equal(until, s.server.Object("lebowski", "2.lebowski").RetainUntil)
+	s.Require().Error(s.fs.SetImmutable("2.lebowski", until.Add(-time.Minute)), "Should not be able to shorten retention")
+	s.Require().NoError(s.fs.SetImmutable("2.lebowski", until.Add(time.Minute)))
+
+	// S3 locks the version, not the key, so removing the file just hides the locked version.
+	s.Require().NoError(s.fs.Remove("2.lebowski"))
+	versions, err := s.fs.Versions("2.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(versions))
+	s.Require().NoError(s.fs.RestoreVersion("2.lebowski", versions[1].ID))
+
+	s.Require().Error(s.fs.SetImmutable("1.lebowski", until), "Can't lock a deleted file")
+	s.Require().Error(s.fs.SetImmutable(".", until))
+
+	unversioned := filestoretest.NewS3Server("lebowski")
+	defer unversioned.Close()
+	unversioned.PutObject("lebowski", "1.lebowski", []byte("jeff"))
+	bucket := filestore.S3("lebowski", filestore.WithEndpoint(unversioned.URL), filestore.WithCredentials("", "", ""))
+	s.Require().Error(bucket.SetImmutable("1.lebowski", until), "Buckets w/o object lock should fail")
+}
@@ -0,0 +1,151 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Scoped returns a file store that is confined to a single tenant's directory within the given store,
+// so multi-tenant code can't accidentally read or modify another tenant's files by forgetting to add
+// the tenant prefix. The tenant ID is sanitized into a single directory name, and every operation fails
+// with fs.ErrPermission if its path (e.g. "../other-tenant/secrets.json") resolves to anything outside
+// of that directory. The same goes for ChangeDirectory(); you can cd anywhere you like, but operations
+// that leave the tenant's directory fail.
+//
+// Sanitizing keeps letters, digits, '-', '_', and '.' as-is and percent-encodes everything else (as well
+// as a leading '.'), so distinct tenant IDs always map to distinct directories and an ID like ".." or
+// "acme/../globex" can't escape. Empty tenant IDs are rejected since they'd expose every tenant's files.
+//
+// Example:
+//
+//	func handleUpload(w http.ResponseWriter, req *http.Request) {
+//	    files, err := filestore.Scoped(uploads, tenantID(req))
+//	    ...
+//	    file, err := files.Write(req.URL.Query().Get("name")) // can't be "../globex/logo.png"
+//	}
+func Scoped(fs FS, tenantID string) (FS, error) {
+	if tenantID == "" {
+		return nil, fmt.Errorf("filestore: scoped: missing tenant id")
+	}
+	tenantFS := fs.ChangeDirectory(sanitizeTenantID(tenantID))
+	return &scopedFS{FS: tenantFS, root: tenantFS.WorkingDirectory()}, nil
+}
+
+func init() {
+	RegisterLayer("scoped", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Tenant string `json:"tenant"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		return Scoped(fs, settings.Tenant)
+	})
+}
+
+// sanitizeTenantID converts the tenant ID into a single, safe directory name.
+func sanitizeTenantID(tenantID string) string {
+	var name strings.Builder
+	for i := 0; i < len(tenantID); i++ {
+		c := tenantID[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+			name.WriteByte(c)
+		case c == '.' && i > 0:
+			name.WriteByte(c)
+		default:
+			fmt.Fprintf(&name, "%%%02X", c)
+		}
+	}
+	return name.String()
+}
+
+type scopedFS struct {
+	FS
+	// root is the working directory of the tenant's directory; nothing may escape it.
+	root string
+}
+
+// check fails with fs.ErrPermission when the path refers to something outside of the tenant's directory.
+func (s *scopedFS) check(op string, filePath string) error {
+	if strings.ContainsRune(filePath, 0) {
+		return nil // let the underlying store reject the invalid path as usual
+	}
+	fullPath := joinPath(s.FS.WorkingDirectory(), filePath)
+	if isWithinPath(s.root, fullPath) {
+		return nil
+	}
+	return newPathError("scoped", op, filePath, fmt.Errorf("outside of tenant directory: %w", fs.ErrPermission))
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that is still confined to the
+// tenant's directory.
+func (s *scopedFS) ChangeDirectory(dir string) FS {
+	return &scopedFS{FS: s.FS.ChangeDirectory(dir), root: s.root}
+}
+
+func (s *scopedFS) withContext(ctx context.Context) FS {
+	return &scopedFS{FS: ForRequest(s.FS, ctx), root: s.root}
+}
+
+func (s *scopedFS) requestContext() context.Context {
+	return RequestContext(s.FS)
+}
+
+func (s *scopedFS) wrapped() []FS {
+	return []FS{s.FS}
+}
+
+func (s *scopedFS) Stat(filePath string) (FileInfo, error) {
+	if err := s.check("stat", filePath); err != nil {
+		return nil, err
+	}
+	return s.FS.Stat(filePath)
+}
+
+func (s *scopedFS) Exists(filePath string) bool {
+	return s.check("exists", filePath) == nil && s.FS.Exists(filePath)
+}
+
+func (s *scopedFS) Read(filePath string) (ReaderFile, error) {
+	if err := s.check("open", filePath); err != nil {
+		return nil, err
+	}
+	return s.FS.Read(filePath)
+}
+
+func (s *scopedFS) Write(filePath string) (WriterFile, error) {
+	if err := s.check("write", filePath); err != nil {
+		return nil, err
+	}
+	return s.FS.Write(filePath)
+}
+
+func (s *scopedFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	if err := s.check("list files", dirPath); err != nil {
+		return nil, err
+	}
+	return s.FS.List(dirPath, filters...)
+}
+
+func (s *scopedFS) Remove(fileOrDirPath string) error {
+	if err := s.check("remove", fileOrDirPath); err != nil {
+		return err
+	}
+	return s.FS.Remove(fileOrDirPath)
+}
+
+func (s *scopedFS) Move(fromPath string, toPath string) error {
+	if err := s.check("move", fromPath); err != nil {
+		return err
+	}
+	if err := s.check("move", toPath); err != nil {
+		return err
+	}
+	return s.FS.Move(fromPath, toPath)
+}
+
+var _ requestBinder = &scopedFS{}
+var _ wrapper = &scopedFS{}
@@ -0,0 +1,108 @@
+package filestore_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ScopedTestSuite struct {
+	suite.Suite
+	shared filestore.FS
+	tenant filestore.FS
+}
+
+func TestScopedTestSuite(t *testing.T) {
+	suite.Run(t, &ScopedTestSuite{})
+}
+
+func (s *ScopedTestSuite) SetupTest() {
+	s.shared = filestore.Memory()
+	s.Require().NoError(writeString(s.shared, "acme/1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.shared, "acme/duderino/5.lebowski", "jackie"))
+	s.Require().NoError(writeString(s.shared, "globex/2.lebowski", "walter"))
+	s.Require().NoError(writeString(s.shared, "secrets.json", "{}"))
+
+	var err error
+	s.tenant, err = filestore.Scoped(s.shared, "acme")
+	s.Require().NoError(err)
+}
+
+func (s *ScopedTestSuite) TestScoped() {
+	content, err := readString(s.tenant, "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jeff", content)
+
+	s.Require().NoError(writeString(s.tenant, "duderino/6.lebowski", "nihilist"))
+	content, err = readString(s.shared, "acme/duderino/6.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("nihilist", content, "Writes should land in the tenant's directory")
+
+	infos, err := s.tenant.List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(infos))
+
+	s.Require().NoError(s.tenant.Move("duderino/./../1.lebowski", "1.dude"), "Paths that stay inside the tenant are fine")
+	s.Require().True(s.shared.Exists("acme/1.dude"))
+}
+
+func (s *ScopedTestSuite) TestScoped_crossTenant() {
+	assertDenied := func(err error) {
+		s.Require().True(errors.Is(err, fs.ErrPermission), "Should not escape the tenant directory: %v", err)
+	}
+
+	_, err := s.tenant.Read("../globex/2.lebowski")
+	assertDenied(err)
+	_, err = s.tenant.Stat("../secrets.json")
+	assertDenied(err)
+	_, err = s.tenant.Write("../globex/2.lebowski")
+	assertDenied(err)
+	_, err = s.tenant.List("..")
+	assertDenied(err)
+	assertDenied(s.tenant.Remove("/../globex"))
+	assertDenied(s.tenant.Move("1.lebowski", "../globex/1.lebowski"))
+	assertDenied(s.tenant.Move("../globex/2.lebowski", "2.lebowski"))
+	s.Require().False(s.tenant.Exists("../secrets.json"))
+
+	outside := s.tenant.ChangeDirectory("duderino/../..")
+	_, err = outside.List(".")
+	assertDenied(err)
+	_, err = outside.Read("globex/2.lebowski")
+	assertDenied(err)
+	content, err := readString(outside, "acme/1.lebowski")
+	s.Require().NoError(err, "Should still be able to cd back into the tenant")
+	s.Require().Equal("jeff", content)
+
+	content, err = readString(s.shared, "globex/2.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("walter", content, "Other tenant's files should be untouched")
+}
+
+func (s *ScopedTestSuite) TestScoped_sanitize() {
+	for tenantID, expected := range map[string]string{
+		"acme.co":       "/acme.co",
+		"..":            "/%2E.",
+		".hidden":       "/%2Ehidden",
+		"acme/../other": "/acme%2F..%2Fother",
+		"a b%":          "/a%20b%25",
+	} {
+		tenant, err := filestore.Scoped(s.shared, tenantID)
+		s.Require().NoError(err)
+		s.Require().Equal(expected, tenant.WorkingDirectory())
+	}
+
+	_, err := filestore.Scoped(s.shared, "")
+	s.Require().Error(err, "Empty tenant IDs would expose every tenant")
+}
+
+func (s *ScopedTestSuite) TestScoped_config() {
+	tenant, err := filestore.FromConfig(filestore.Config{
+		URL:    "mem://",
+		Layers: []filestore.LayerConfig{{Type: "scoped", Options: filestore.LayerOptions{"tenant": "globex"}}},
+	})
+	s.Require().NoError(err)
+	s.Require().Equal("/globex", tenant.WorkingDirectory())
+}
@@ -0,0 +1,423 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IndexEntry is everything that a search Index knows about a single file.
+type IndexEntry struct {
+	// Path is the location of the file. Index implementations always deal w/ full paths (i.e. including
+	// the store's working directory), but search results are relative to the store you searched.
+	Path string
+	// Size is the size of the file, in bytes.
+	Size int64
+	// ModTime is when the file was last written.
+	ModTime time.Time
+	// Metadata contains any custom key/value pairs you've attached to the file using SetMetadata().
+	Metadata map[string]string
+}
+
+// SearchQuery describes the files you're looking for in a call to Search(). Every criteria must match.
+type SearchQuery struct {
+	// Dir limits the search to files in this directory or any of its subdirectories. Leave it empty to
+	// search the store's entire working directory.
+	Dir string
+	// Filters are the same ones you pass to List() (e.g. WithExt or WithPattern). They're given each file's
+	// base name, size, and modification time.
+	Filters []FileFilter
+	// Metadata only matches files that have all of these key/value pairs.
+	Metadata map[string]string
+	// Limit is the maximum number of files to return; 0 means there is no limit.
+	Limit int
+}
+
+// Index is a catalog of the files in a store that you can query w/o walking the entire store. Indexed() keeps
+// it up to date as files are written, moved, and removed. You can use the built-in MemoryIndex() or plug in
+// your own backed by a database or search engine.
+type Index interface {
+	// Get fetches the entry for the file at the given full path. The boolean is false if there isn't one.
+	Get(fullPath string) (IndexEntry, bool, error)
+	// Put adds/replaces the file's entry.
+	Put(entry IndexEntry) error
+	// Move changes the path of the entry for the file, or of every entry in the directory, at fromPath. Any
+	// entries already at toPath (or in it) must be removed.
+	Move(fromPath string, toPath string) error
+	// Delete removes the entry for the file, or every entry in the directory, at the given full path.
+	Delete(fullPath string) error
+	// Search returns the entries that match the query, sorted by path. The query's Dir is a full path.
+	Search(query SearchQuery) ([]IndexEntry, error)
+}
+
+// MemoryIndex creates a search Index that lives entirely in memory. It's lost when the process exits, so
+// use Reindex() to rebuild it when you start up.
+func MemoryIndex() Index {
+	return &memoryIndex{entries: map[string]IndexEntry{}}
+}
+
+type memoryIndex struct {
+	mutex   sync.RWMutex
+	entries map[string]IndexEntry
+}
+
+func (index *memoryIndex) Get(fullPath string) (IndexEntry, bool, error) {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	entry, ok := index.entries[fullPath]
+	return entry, ok, nil
+}
+
+func (index *memoryIndex) Put(entry IndexEntry) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.entries[entry.Path] = entry
+	return nil
+}
+
+func (index *memoryIndex) Move(fromPath string, toPath string) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	var moved []IndexEntry
+	for entryPath, entry := range index.entries {
+		if isWithinPath(fromPath, entryPath) {
+			delete(index.entries, entryPath)
+			entry.Path = toPath + strings.TrimPrefix(entryPath, fromPath)
+			moved = append(moved, entry)
+		}
+	}
+	index.delete(toPath)
+	for _, entry := range moved {
+		index.entries[entry.Path] = entry
+	}
+	return nil
+}
+
+func (index *memoryIndex) Delete(fullPath string) error {
+	index.mutex.Lock()
+	defer index.mutex.Unlock()
+
+	index.delete(fullPath)
+	return nil
+}
+
+func (index *memoryIndex) delete(fullPath string) {
+	for entryPath := range index.entries {
+		if isWithinPath(fullPath, entryPath) {
+			delete(index.entries, entryPath)
+		}
+	}
+}
+
+func (index *memoryIndex) Search(query SearchQuery) ([]IndexEntry, error) {
+	index.mutex.RLock()
+	defer index.mutex.RUnlock()
+
+	var results []IndexEntry
+	for entryPath, entry := range index.entries {
+		if !isWithinPath(query.Dir, entryPath) {
+			continue
+		}
+		matches, err := query.matches(entry)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			results = append(results, entry)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+	return results, nil
+}
+
+// matches returns true when the entry satisfies all of the query's filters and metadata. It does not look
+// at the query's Dir, since Index implementations will likely want to handle that more efficiently. It fails
+// w/ a *PanicError if one of the filters panics.
+func (query SearchQuery) matches(entry IndexEntry) (bool, error) {
+	for key, value := range query.Metadata {
+		if actual, ok := entry.Metadata[key]; !ok || actual != value {
+			return false, nil
+		}
+	}
+	return fileMatchesFilters(indexEntryInfo{entry: entry}, query.Filters)
+}
+
+// Indexed wraps a file store so that every file you write, move, or remove through it is reflected in the
+// given search Index. You can then Search() for files by name, size, modification time, or any custom
+// metadata you've attached to them (see SetMetadata) w/o having to walk the entire store.
+//
+// The index only knows about changes made through this wrapper (or any store derived from it using
+// ChangeDirectory). Use Reindex() to catalog the files that were already in the store, or to catch up
+// w/ changes that were made some other way.
+//
+// Example:
+//
+//	files := filestore.Indexed(filestore.S3("documents"), filestore.MemoryIndex())
+//	err := files.Reindex(".")
+//	...
+//	err = files.SetMetadata("contracts/acme.pdf", map[string]string{"customer": "acme"})
+//	...
+//	results, err := files.Search(filestore.SearchQuery{
+//	    Dir:      "contracts",
+//	    Filters:  []filestore.FileFilter{filestore.WithExt("pdf")},
+//	    Metadata: map[string]string{"customer": "acme"},
+//	})
+func Indexed(fs FS, index Index) *IndexedFS {
+	return &IndexedFS{FS: fs, index: index}
+}
+
+func init() {
+	RegisterLayer("indexed", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Reindex bool `json:"reindex"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		indexed := Indexed(fs, MemoryIndex())
+		if settings.Reindex {
+			if err := indexed.Reindex("."); err != nil {
+				return nil, err
+			}
+		}
+		return indexed, nil
+	})
+}
+
+// IndexedFS is a file store wrapper that keeps a search index up to date; see Indexed().
+type IndexedFS struct {
+	FS
+	index Index
+}
+
+// Index returns the search index that this store keeps up to date.
+func (x *IndexedFS) Index() Index {
+	return x.index
+}
+
+// Search returns the files that match the query, sorted by path. The paths in the results are relative to
+// this store's working directory.
+func (x *IndexedFS) Search(query SearchQuery) ([]IndexEntry, error) {
+	workingDirectory := x.FS.WorkingDirectory()
+	dir, err := resolvePath(workingDirectory, query.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: search: %w", err)
+	}
+	query.Dir = dir
+	results, err := x.index.Search(query)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: search: %w", err)
+	}
+	for i := range results {
+		results[i].Path = relativeTo(workingDirectory, results[i].Path)
+	}
+	return results, nil
+}
+
+// SetMetadata replaces all of the custom metadata attached to the file. Pass nil to remove it all. The file
+// keeps its metadata when you overwrite or move it, and it goes away when you remove the file.
+func (x *IndexedFS) SetMetadata(filePath string, metadata map[string]string) error {
+	entry, err := x.entry(filePath)
+	if err != nil {
+		return fmt.Errorf("filestore: set metadata: %w", err)
+	}
+	entry.Metadata = nil
+	if metadata != nil {
+		entry.Metadata = make(map[string]string, len(metadata))
+		for key, value := range metadata {
+			entry.Metadata[key] = value
+		}
+	}
+	if err = x.index.Put(entry); err != nil {
+		return fmt.Errorf("filestore: set metadata: %w", err)
+	}
+	return nil
+}
+
+// Metadata returns the custom metadata attached to the file.
+func (x *IndexedFS) Metadata(filePath string) (map[string]string, error) {
+	fullPath, err := resolvePath(x.FS.WorkingDirectory(), filePath)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: metadata: %w", err)
+	}
+	entry, ok, err := x.index.Get(fullPath)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("filestore: metadata: %w", err)
+	case !ok && !x.FS.Exists(filePath):
+		return nil, fmt.Errorf("filestore: metadata: %s: %w", filePath, fs.ErrNotExist)
+	default:
+		return entry.Metadata, nil
+	}
+}
+
+// Reindex catalogs every file in the directory (and its subdirectories), removing the entries of any files
+// that no longer exist. Files keep any metadata they already had.
+func (x *IndexedFS) Reindex(dir string) error {
+	fullDir, err := resolvePath(x.FS.WorkingDirectory(), dir)
+	if err != nil {
+		return fmt.Errorf("filestore: reindex: %w", err)
+	}
+	existing, err := x.index.Search(SearchQuery{Dir: fullDir})
+	if err != nil {
+		return fmt.Errorf("filestore: reindex: %w", err)
+	}
+	stale := map[string]bool{}
+	for _, entry := range existing {
+		stale[entry.Path] = true
+	}
+
+	err = Walk(x.FS, dir, func(filePath string, info FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		fullPath := joinPath(x.FS.WorkingDirectory(), filePath)
+		delete(stale, fullPath)
+		return x.put(fullPath, info)
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("filestore: reindex: %w", err)
+	}
+	for fullPath := range stale {
+		if err = x.index.Delete(fullPath); err != nil {
+			return fmt.Errorf("filestore: reindex: %w", err)
+		}
+	}
+	return nil
+}
+
+// Write opens the file for writing. The index is updated once you close it.
+func (x *IndexedFS) Write(filePath string) (WriterFile, error) {
+	file, err := x.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &indexedWriterFile{WriterFile: file, fs: x, path: filePath}, nil
+}
+
+// Move relocates the file/directory, along w/ the index entries of everything it contains.
+func (x *IndexedFS) Move(fromPath string, toPath string) error {
+	workingDirectory := x.FS.WorkingDirectory()
+	if err := x.FS.Move(fromPath, toPath); err != nil {
+		return err
+	}
+	if err := x.index.Move(joinPath(workingDirectory, fromPath), joinPath(workingDirectory, toPath)); err != nil {
+		return fmt.Errorf("filestore: indexed: move: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the file/directory, along w/ the index entries of everything it contains.
+func (x *IndexedFS) Remove(fileOrDirPath string) error {
+	if err := x.FS.Remove(fileOrDirPath); err != nil {
+		return err
+	}
+	if err := x.index.Delete(joinPath(x.FS.WorkingDirectory(), fileOrDirPath)); err != nil {
+		return fmt.Errorf("filestore: indexed: remove: %w", err)
+	}
+	return nil
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that updates the same index.
+func (x *IndexedFS) ChangeDirectory(dir string) FS {
+	return &IndexedFS{FS: x.FS.ChangeDirectory(dir), index: x.index}
+}
+
+func (x *IndexedFS) withContext(ctx context.Context) FS {
+	return &IndexedFS{FS: ForRequest(x.FS, ctx), index: x.index}
+}
+
+func (x *IndexedFS) requestContext() context.Context {
+	return RequestContext(x.FS)
+}
+
+func (x *IndexedFS) wrapped() []FS {
+	return []FS{x.FS}
+}
+
+// entry builds the index entry for the file based on its current info, keeping any metadata it already has.
+func (x *IndexedFS) entry(filePath string) (IndexEntry, error) {
+	fullPath, err := resolvePath(x.FS.WorkingDirectory(), filePath)
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	info, err := x.FS.Stat(filePath)
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	if info.IsDir() {
+		return IndexEntry{}, fmt.Errorf("%s: is a directory", filePath)
+	}
+	entry, _, err := x.index.Get(fullPath)
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	entry.Path, entry.Size, entry.ModTime = fullPath, info.Size(), info.ModTime()
+	return entry, nil
+}
+
+// put adds/updates the entry for the file, keeping any metadata it already has.
+func (x *IndexedFS) put(fullPath string, info FileInfo) error {
+	entry, _, err := x.index.Get(fullPath)
+	if err != nil {
+		return err
+	}
+	entry.Path, entry.Size, entry.ModTime = fullPath, info.Size(), info.ModTime()
+	return x.index.Put(entry)
+}
+
+// indexedWriterFile updates the file's index entry once it has been written.
+type indexedWriterFile struct {
+	WriterFile
+	fs     *IndexedFS
+	path   string
+	closed bool
+}
+
+func (f *indexedWriterFile) Close() error {
+	if f.closed {
+		return f.WriterFile.Close()
+	}
+	f.closed = true
+	if err := f.WriterFile.Close(); err != nil {
+		return err
+	}
+	entry, err := f.fs.entry(f.path)
+	if err != nil {
+		return fmt.Errorf("filestore: indexed: %w", err)
+	}
+	if err = f.fs.index.Put(entry); err != nil {
+		return fmt.Errorf("filestore: indexed: %w", err)
+	}
+	return nil
+}
+
+// indexEntryInfo lets you run an index entry through the same FileFilter functions you use w/ List().
+type indexEntryInfo struct {
+	entry IndexEntry
+}
+
+func (info indexEntryInfo) Name() string       { return path.Base(info.entry.Path) }
+func (info indexEntryInfo) Size() int64        { return info.entry.Size }
+func (info indexEntryInfo) Mode() fs.FileMode  { return 0 }
+func (info indexEntryInfo) ModTime() time.Time { return info.entry.ModTime }
+func (info indexEntryInfo) IsDir() bool        { return false }
+func (info indexEntryInfo) Sys() any           { return nil }
+
+var _ FS = &IndexedFS{}
+var _ requestBinder = &IndexedFS{}
+var _ wrapper = &IndexedFS{}
@@ -0,0 +1,138 @@
+package filestore_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type IndexedTestSuite struct {
+	suite.Suite
+	inner filestore.FS
+	fs    *filestore.IndexedFS
+}
+
+func TestIndexedTestSuite(t *testing.T) {
+	suite.Run(t, &IndexedTestSuite{})
+}
+
+func (s *IndexedTestSuite) SetupTest() {
+	s.inner = filestore.Memory()
+	s.fs = filestore.Indexed(s.inner, filestore.MemoryIndex())
+	s.Require().NoError(writeString(s.fs, "contracts/acme.pdf", "acme"))
+	s.Require().NoError(writeString(s.fs, "contracts/globex.pdf", "globex!"))
+	s.Require().NoError(writeString(s.fs, "contracts/notes.txt", "notes"))
+	s.Require().NoError(writeString(s.fs, "invoices/acme-001.pdf", "invoice"))
+}
+
+func (s *IndexedTestSuite) TestSearch() {
+	s.assertSearch(filestore.SearchQuery{},
+		"contracts/acme.pdf", "contracts/globex.pdf", "contracts/notes.txt", "invoices/acme-001.pdf")
+	s.assertSearch(filestore.SearchQuery{Dir: "contracts", Filters: []filestore.FileFilter{filestore.WithExt("pdf")}},
+		"contracts/acme.pdf", "contracts/globex.pdf")
+	s.assertSearch(filestore.SearchQuery{Filters: []filestore.FileFilter{filestore.WithPrefix("acme")}, Limit: 1},
+		"contracts/acme.pdf")
+	s.assertSearch(filestore.SearchQuery{Dir: "missing"})
+
+	results, err := s.fs.Search(filestore.SearchQuery{Filters: []filestore.FileFilter{func(info filestore.FileInfo) bool {
+		return info.Size() > 6
+	}}})
+	s.Require().NoError(err)
+	s.Require().Len(results, 2)
+	s.Require().Equal("contracts/globex.pdf", results[0].Path)
+	s.Require().Equal(int64(7), results[0].Size)
+	s.Require().False(results[0].ModTime.IsZero())
+}
+
+func (s *IndexedTestSuite) TestMetadata() {
+	s.Require().NoError(s.fs.SetMetadata("contracts/acme.pdf", map[string]string{"customer": "acme", "status": "signed"}))
+	s.Require().NoError(s.fs.SetMetadata("invoices/acme-001.pdf", map[string]string{"customer": "acme"}))
+	s.assertSearch(filestore.SearchQuery{Metadata: map[string]string{"customer": "acme"}},
+		"contracts/acme.pdf", "invoices/acme-001.pdf")
+	s.assertSearch(filestore.SearchQuery{Metadata: map[string]string{"customer": "acme", "status": "signed"}},
+		"contracts/acme.pdf")
+
+	// Metadata should stick w/ the file when it's overwritten or moved.
+	s.Require().NoError(writeString(s.fs, "contracts/acme.pdf", "acme v2"))
+	s.Require().NoError(s.fs.Move("contracts", "archive/contracts"))
+	metadata, err := s.fs.Metadata("archive/contracts/acme.pdf")
+	s.Require().NoError(err)
+	s.Require().Equal("signed", metadata["status"])
+	s.assertSearch(filestore.SearchQuery{Metadata: map[string]string{"status": "signed"}}, "archive/contracts/acme.pdf")
+
+	s.Require().NoError(s.fs.SetMetadata("archive/contracts/acme.pdf", nil))
+	s.assertSearch(filestore.SearchQuery{Metadata: map[string]string{"status": "signed"}})
+
+	err = s.fs.SetMetadata("missing.pdf", map[string]string{"customer": "acme"})
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = s.fs.Metadata("missing.pdf")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	s.Require().Error(s.fs.SetMetadata("archive", map[string]string{"customer": "acme"}), "Directories can't have metadata")
+}
+
+func (s *IndexedTestSuite) TestMoveAndRemove() {
+	s.Require().NoError(s.fs.Move("contracts/acme.pdf", "invoices/acme-001.pdf"))
+	s.assertSearch(filestore.SearchQuery{Dir: "invoices"}, "invoices/acme-001.pdf")
+	results, err := s.fs.Search(filestore.SearchQuery{Dir: "invoices"})
+	s.Require().NoError(err)
+	s.Require().Equal(int64(4), results[0].Size, "Should replace the entry of the file we overwrote")
+
+	s.Require().NoError(s.fs.Remove("contracts"))
+	s.assertSearch(filestore.SearchQuery{}, "invoices/acme-001.pdf")
+}
+
+func (s *IndexedTestSuite) TestReindex() {
+	s.Require().NoError(writeString(s.inner, "contracts/initech.pdf", "initech"))
+	s.Require().NoError(s.inner.Remove("contracts/notes.txt"))
+	s.Require().NoError(s.fs.SetMetadata("contracts/acme.pdf", map[string]string{"customer": "acme"}))
+	s.assertSearch(filestore.SearchQuery{Dir: "contracts"}, "contracts/acme.pdf", "contracts/globex.pdf", "contracts/notes.txt")
+
+	s.Require().NoError(s.fs.Reindex("contracts"))
+	s.assertSearch(filestore.SearchQuery{Dir: "contracts"}, "contracts/acme.pdf", "contracts/globex.pdf", "contracts/initech.pdf")
+	s.assertSearch(filestore.SearchQuery{Metadata: map[string]string{"customer": "acme"}}, "contracts/acme.pdf")
+
+	s.Require().NoError(s.inner.Remove("contracts"))
+	s.Require().NoError(s.fs.Reindex("contracts"))
+	s.assertSearch(filestore.SearchQuery{}, "invoices/acme-001.pdf")
+}
+
+func (s *IndexedTestSuite) TestChangeDirectory() {
+	contracts := s.fs.ChangeDirectory("contracts").(*filestore.IndexedFS)
+	s.Require().NoError(writeString(contracts, "initech.pdf", "initech"))
+	s.Require().NoError(contracts.SetMetadata("initech.pdf", map[string]string{"customer": "initech"}))
+
+	results, err := contracts.Search(filestore.SearchQuery{Filters: []filestore.FileFilter{filestore.WithExt("pdf")}})
+	s.Require().NoError(err)
+	s.Require().Len(results, 3, "Should only search the working directory")
+	s.Require().Equal("initech.pdf", results[2].Path)
+
+	s.assertSearch(filestore.SearchQuery{Metadata: map[string]string{"customer": "initech"}}, "contracts/initech.pdf")
+}
+
+func (s *IndexedTestSuite) TestFromConfig() {
+	root := s.T().TempDir()
+	s.Require().NoError(writeString(filestore.Disk(root), "contracts/acme.pdf", "acme"))
+
+	fs, err := filestore.FromConfig(filestore.Config{
+		URL:    "file://" + root,
+		Layers: []filestore.LayerConfig{{Type: "indexed", Options: filestore.LayerOptions{"reindex": true}}},
+	})
+	s.Require().NoError(err)
+	results, err := fs.(*filestore.IndexedFS).Search(filestore.SearchQuery{})
+	s.Require().NoError(err)
+	s.Require().Len(results, 1)
+	s.Require().Equal("contracts/acme.pdf", results[0].Path)
+}
+
+func (s *IndexedTestSuite) assertSearch(query filestore.SearchQuery, expected ...string) {
+	results, err := s.fs.Search(query)
+	s.Require().NoError(err)
+	var paths []string
+	for _, result := range results {
+		paths = append(paths, result.Path)
+	}
+	s.Require().Equal(expected, paths)
+}
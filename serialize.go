@@ -0,0 +1,194 @@
+package filestore
+
+import (
+	"context"
+	"sync"
+)
+
+// Serialized wraps a file store so that concurrent changes to the same path are applied one at a time
+// rather than on top of each other. Writing to a file locks its path from Write() until you close the file,
+// so when two goroutines write the same report, one of them finishes before the other one starts and you
+// end up w/ one complete version rather than an interleaved mess on backends that don't replace files
+// atomically. Moves and removals wait for (and block) changes to the paths they touch, too.
+//
+// Paths are locked individually, so writing "reports/q3.txt" does not block removing "reports", and
+// reads are never blocked. If the store was bound to a request w/ ForRequest(), waiting for a path gives
+// up once its context is done. Stores derived from the wrapper via ChangeDirectory() or ForRequest() share
+// the same locks, but they only live in memory so they can't coordinate changes across processes.
+//
+// Example:
+//
+//	files := filestore.Serialized(filestore.Disk("/mnt/nfs/reports"))
+func Serialized(fs FS) FS {
+	return &serializedFS{FS: fs, locks: &pathLocks{locks: map[string]*pathLock{}}}
+}
+
+func init() {
+	RegisterLayer("serialized", func(fs FS, _ LayerOptions) (FS, error) {
+		return Serialized(fs), nil
+	})
+}
+
+type serializedFS struct {
+	FS
+	locks *pathLocks
+}
+
+// pathLocks is a keyed mutex w/ one lock for every path that's currently being changed. It's shared by
+// the original wrapper and any instances derived from it.
+type pathLocks struct {
+	mutex sync.Mutex
+	locks map[string]*pathLock
+}
+
+// pathLock is a mutex for a single path. It uses a channel rather than a sync.Mutex so that we can stop
+// waiting for it when the request is canceled.
+type pathLock struct {
+	held chan struct{}
+	// users counts the callers holding or waiting for the lock so we know when to discard it.
+	users int
+}
+
+// Write opens the file for writing once nobody else is changing it. Nobody else can change it until you
+// close the file.
+func (s *serializedFS) Write(filePath string) (WriterFile, error) {
+	unlock, err := s.lock(s.fullPath(filePath))
+	if err != nil {
+		return nil, newPathError("serialized", "write", filePath, err)
+	}
+	file, err := s.FS.Write(filePath)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	return &serializedWriterFile{WriterFile: file, unlock: unlock}, nil
+}
+
+// Move relocates the file/directory once nobody else is changing the source or the destination.
+func (s *serializedFS) Move(fromPath string, toPath string) error {
+	unlock, err := s.lock(s.fullPath(fromPath), s.fullPath(toPath))
+	if err != nil {
+		return newPathError("serialized", "move", fromPath, err)
+	}
+	defer unlock()
+	return s.FS.Move(fromPath, toPath)
+}
+
+// Remove deletes the file/directory once nobody else is changing it.
+func (s *serializedFS) Remove(fileOrDirPath string) error {
+	unlock, err := s.lock(s.fullPath(fileOrDirPath))
+	if err != nil {
+		return newPathError("serialized", "remove", fileOrDirPath, err)
+	}
+	defer unlock()
+	return s.FS.Remove(fileOrDirPath)
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that shares the same locks.
+func (s *serializedFS) ChangeDirectory(dir string) FS {
+	return &serializedFS{FS: s.FS.ChangeDirectory(dir), locks: s.locks}
+}
+
+func (s *serializedFS) withContext(ctx context.Context) FS {
+	return &serializedFS{FS: ForRequest(s.FS, ctx), locks: s.locks}
+}
+
+func (s *serializedFS) requestContext() context.Context {
+	return RequestContext(s.FS)
+}
+
+func (s *serializedFS) wrapped() []FS {
+	return []FS{s.FS}
+}
+
+func (s *serializedFS) fullPath(filePath string) string {
+	return joinPath(s.FS.WorkingDirectory(), filePath)
+}
+
+// lock waits until it holds the locks for all of the paths (or the request's context is done). Call the
+// returned function to release them.
+func (s *serializedFS) lock(paths ...string) (func(), error) {
+	// Always lock paths in the same order so that a move from A to B and another from B to A can't
+	// deadlock each other.
+	if len(paths) == 2 && paths[1] < paths[0] {
+		paths[0], paths[1] = paths[1], paths[0]
+	}
+	if len(paths) == 2 && paths[0] == paths[1] {
+		paths = paths[:1]
+	}
+
+	ctx := RequestContext(s.FS)
+	var held []string
+	unlock := func() {
+		for _, path := range held {
+			s.locks.release(path)
+		}
+	}
+	for _, path := range paths {
+		if err := s.locks.acquire(ctx, path); err != nil {
+			unlock()
+			return nil, err
+		}
+		held = append(held, path)
+	}
+
+	var once sync.Once
+	return func() { once.Do(unlock) }, nil
+}
+
+// acquire waits until the caller holds the lock for the path or the context is done.
+func (p *pathLocks) acquire(ctx context.Context, path string) error {
+	p.mutex.Lock()
+	lock, ok := p.locks[path]
+	if !ok {
+		lock = &pathLock{held: make(chan struct{}, 1)}
+		p.locks[path] = lock
+	}
+	lock.users++
+	p.mutex.Unlock()
+
+	select {
+	case lock.held <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		p.discard(path, lock)
+		return ctx.Err()
+	}
+}
+
+// release unlocks the path so that the next caller waiting for it can go.
+func (p *pathLocks) release(path string) {
+	p.mutex.Lock()
+	lock := p.locks[path]
+	p.mutex.Unlock()
+
+	<-lock.held
+	p.discard(path, lock)
+}
+
+// discard forgets about the lock once nobody is holding or waiting for it anymore.
+func (p *pathLocks) discard(path string, lock *pathLock) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if lock.users--; lock.users <= 0 {
+		delete(p.locks, path)
+	}
+}
+
+// serializedWriterFile releases the lock on its path once it's closed.
+type serializedWriterFile struct {
+	WriterFile
+	unlock func()
+	closed bool
+}
+
+func (f *serializedWriterFile) Close() error {
+	if !f.closed {
+		f.closed = true
+		defer f.unlock()
+	}
+	return f.WriterFile.Close()
+}
+
+var _ requestBinder = &serializedFS{}
+var _ wrapper = &serializedFS{}
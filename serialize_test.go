@@ -0,0 +1,105 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type SerializedTestSuite struct {
+	suite.Suite
+	inner filestore.FS
+	fs    filestore.FS
+}
+
+func TestSerializedTestSuite(t *testing.T) {
+	suite.Run(t, &SerializedTestSuite{})
+}
+
+func (s *SerializedTestSuite) SetupTest() {
+	s.inner = filestore.Memory()
+	s.fs = filestore.Serialized(s.inner)
+	s.Require().NoError(writeString(s.inner, "reports/q3.txt", "the dude abides"))
+}
+
+func (s *SerializedTestSuite) TestWrite() {
+	writer, err := s.fs.Write("reports/q4.txt")
+	s.Require().NoError(err)
+	_, err = writer.Write([]byte("first"))
+	s.Require().NoError(err)
+
+	written := make(chan error)
+	go func() {
+		written <- writeString(s.fs.ChangeDirectory("reports"), "q4.txt", "second")
+	}()
+	select {
+	case <-written:
+		s.Fail("Should wait for the first writer to close the file")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Other paths aren't blocked, nor are reads.
+	s.Require().NoError(writeString(s.fs, "reports/q5.txt", "other"))
+	content, err := readString(s.fs, "reports/q3.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("the dude abides", content)
+
+	s.Require().NoError(writer.Close())
+	s.Require().NoError(writer.Close(), "Closing twice should not unlock twice")
+	s.Require().NoError(<-written)
+	content, err = readString(s.fs, "reports/q4.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("second", content)
+}
+
+func (s *SerializedTestSuite) TestMoveAndRemove() {
+	writer, err := s.fs.Write("reports/q4.txt")
+	s.Require().NoError(err)
+
+	moved := make(chan error)
+	go func() {
+		moved <- s.fs.Move("reports/q3.txt", "reports/q4.txt")
+	}()
+	select {
+	case <-moved:
+		s.Fail("Should wait to move over a file that's being written")
+	case <-time.After(20 * time.Millisecond):
+	}
+	s.Require().NoError(writer.Close())
+	s.Require().NoError(<-moved)
+	s.Require().False(s.fs.Exists("reports/q3.txt"))
+
+	writer, err = s.fs.Write("reports/q4.txt")
+	s.Require().NoError(err)
+	removed := make(chan error)
+	go func() {
+		removed <- s.fs.Remove("reports/q4.txt")
+	}()
+	select {
+	case <-removed:
+		s.Fail("Should wait to remove a file that's being written")
+	case <-time.After(20 * time.Millisecond):
+	}
+	s.Require().NoError(writer.Close())
+	s.Require().NoError(<-removed)
+	s.Require().False(s.fs.Exists("reports/q4.txt"))
+}
+
+func (s *SerializedTestSuite) TestCanceled() {
+	writer, err := s.fs.Write("reports/q3.txt")
+	s.Require().NoError(err)
+	defer writer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = filestore.ForRequest(s.fs, ctx).Write("reports/q3.txt")
+	s.Require().True(errors.Is(err, context.DeadlineExceeded))
+	err = filestore.ForRequest(s.fs, ctx).Move("reports/q4.txt", "reports/q3.txt")
+	s.Require().True(errors.Is(err, context.DeadlineExceeded))
+
+	s.Require().NoError(writeString(s.fs, "reports/q4.txt", "abide"), "Giving up should not leave other paths locked")
+}
@@ -0,0 +1,252 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// Sharded spreads files across several stores while presenting them as a single one. Each file lives in
+// exactly one shard, chosen by hashing its path (relative to the root of the sharded store, regardless
+// of which directory you cd'd into), so the same path always maps to the same shard. Directories are
+// merged, so listing one shows the files from every shard. This lets you put millions of small files on
+// several disks or buckets w/o having to keep track of where each one went.
+//
+// The hash function is given the file's path (e.g. "images/2022/dude.png") and the shard is the hash
+// modulo the number of shards. Pass nil to use FNV-1a. Since files don't move when you change the hash
+// function or the number of shards, you'll need to redistribute them yourself (e.g. w/ CopyAll) when
+// you do.
+//
+// Moving a file to a path that belongs to a different shard copies it over and removes the original, and
+// moving a directory moves each of its files that way. Removing a directory removes it from every shard.
+//
+// Example:
+//
+//	files := filestore.Sharded([]filestore.FS{
+//	    filestore.Disk("/mnt/disk0/files"),
+//	    filestore.Disk("/mnt/disk1/files"),
+//	    filestore.Disk("/mnt/disk2/files"),
+//	}, nil)
+func Sharded(shards []FS, hashFn func(path string) uint64) FS {
+	if hashFn == nil {
+		hashFn = fnvHash
+	}
+	return &shardedFS{shards: shards, hash: hashFn, dir: "."}
+}
+
+type shardedFS struct {
+	shards []FS
+	hash   func(path string) uint64
+	// dir is the working directory, relative to the working directories of all of the shards. We resolve
+	// every path ourselves (rather than changing the shards' directories) so each file is hashed using the
+	// same path no matter where you cd'd into.
+	dir string
+}
+
+func (s *shardedFS) resolve(filePath string) string {
+	return joinPath(s.dir, filePath)
+}
+
+// shard returns the index of the store that the file at the resolved path belongs in.
+func (s *shardedFS) shard(resolved string) int {
+	return int(s.hash(resolved) % uint64(len(s.shards)))
+}
+
+// WorkingDirectory returns the working directory of the first shard.
+func (s *shardedFS) WorkingDirectory() string {
+	return joinPath(s.shards[0].WorkingDirectory(), s.dir)
+}
+
+// Stat fetches metadata about the file from its shard, or about the directory from the first shard that
+// has it.
+func (s *shardedFS) Stat(filePath string) (FileInfo, error) {
+	_, info, err := s.find(s.resolve(filePath))
+	return info, err
+}
+
+// Exists returns true when the file exists in its shard or the directory exists in any shard.
+func (s *shardedFS) Exists(filePath string) bool {
+	_, _, err := s.find(s.resolve(filePath))
+	return err == nil
+}
+
+// Read opens the file in its shard for reading.
+func (s *shardedFS) Read(filePath string) (ReaderFile, error) {
+	resolved := s.resolve(filePath)
+	return s.shards[s.shard(resolved)].Read(resolved)
+}
+
+// Write opens the file in its shard for writing.
+func (s *shardedFS) Write(filePath string) (WriterFile, error) {
+	resolved := s.resolve(filePath)
+	return s.shards[s.shard(resolved)].Write(resolved)
+}
+
+// List merges the contents of the directory in every shard.
+func (s *shardedFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	resolved := s.resolve(dirPath)
+	shard, info, err := s.find(resolved)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return s.shards[shard].List(resolved, filters...)
+	}
+
+	// Every shard has its own copy of the directories that contain its files, so only include them once.
+	seen := map[string]bool{}
+	var results []FileInfo
+	for _, shard := range s.shards {
+		if info, err = shard.Stat(resolved); err != nil || !info.IsDir() {
+			continue
+		}
+		entries, err := shard.List(resolved, filters...)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				results = append(results, entry)
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name() < results[j].Name()
+	})
+	return results, nil
+}
+
+// ChangeDirectory creates a new sharded store of the same shards rooted in the given subdirectory.
+func (s *shardedFS) ChangeDirectory(dir string) FS {
+	return &shardedFS{shards: s.shards, hash: s.hash, dir: s.resolve(dir)}
+}
+
+// Remove deletes the file/directory from every shard.
+func (s *shardedFS) Remove(fileOrDirPath string) error {
+	resolved := s.resolve(fileOrDirPath)
+	for _, shard := range s.shards {
+		if err := shard.Remove(resolved); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Move relocates the file/directory. Files whose new path belongs to a different shard are copied over to
+// that shard and removed from the old one.
+func (s *shardedFS) Move(fromPath string, toPath string) error {
+	from, to := s.resolve(fromPath), s.resolve(toPath)
+	shard, info, err := s.find(from)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+	if isWithinPath(from, to) {
+		return newPathError("sharded", "move", fromPath, errors.New("can not move a directory inside of itself"))
+	}
+	// Whatever is at the destination, in any shard, is replaced.
+	if err = s.Remove(toPath); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return s.moveFile(shard, from, to)
+	}
+
+	var files []string
+	err = Walk(s, fromPath, func(filePath string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("filestore: sharded: move: %s: %w", fromPath, err)
+	}
+	for _, filePath := range files {
+		fileFrom := s.resolve(filePath)
+		fileTo := path.Join(to, relativeTo(from, fileFrom))
+		if err = s.moveFile(s.shard(fileFrom), fileFrom, fileTo); err != nil {
+			return err
+		}
+	}
+	return s.Remove(fromPath)
+}
+
+// moveFile relocates a single file from the given shard to the shard that its new path belongs in.
+func (s *shardedFS) moveFile(shard int, from string, to string) error {
+	target := s.shard(to)
+	if target == shard {
+		return s.shards[shard].Move(from, to)
+	}
+	if err := CopyAll(s.shards[shard], from, s.shards[target], to); err != nil {
+		return fmt.Errorf("filestore: sharded: move: %s: %w", from, err)
+	}
+	return s.shards[shard].Remove(from)
+}
+
+func (s *shardedFS) withContext(ctx context.Context) FS {
+	shards := make([]FS, len(s.shards))
+	for i, shard := range s.shards {
+		shards[i] = ForRequest(shard, ctx)
+	}
+	return &shardedFS{shards: shards, hash: s.hash, dir: s.dir}
+}
+
+func (s *shardedFS) requestContext() context.Context {
+	return RequestContext(s.shards[0])
+}
+
+func (s *shardedFS) wrapped() []FS {
+	return s.shards
+}
+
+// find returns the index of the shard that has the file at the resolved path, or the first shard that
+// has the directory at the resolved path.
+func (s *shardedFS) find(resolved string) (int, FileInfo, error) {
+	owner := s.shard(resolved)
+	info, err := s.shards[owner].Stat(resolved)
+	switch {
+	case err == nil:
+		return owner, info, nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return -1, nil, err
+	}
+
+	// It's not a file (or a directory that also holds files in the shard the path hashes to), but it
+	// could be a directory of files that hash to the other shards.
+	for i, shard := range s.shards {
+		if i == owner {
+			continue
+		}
+		info, err = shard.Stat(resolved)
+		switch {
+		case err == nil && info.IsDir():
+			return i, info, nil
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			return -1, nil, err
+		}
+	}
+	return -1, nil, &fs.PathError{Op: "stat", Path: resolved, Err: fs.ErrNotExist}
+}
+
+// fnvHash is the default hash function for Sharded(): FNV-1a.
+func fnvHash(filePath string) uint64 {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(filePath))
+	return hash.Sum64()
+}
+
+var _ FS = &shardedFS{}
+var _ requestBinder = &shardedFS{}
+var _ wrapper = &shardedFS{}
@@ -0,0 +1,130 @@
+package filestore_test
+
+import (
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ShardedTestSuite struct {
+	suite.Suite
+	even filestore.FS
+	odd  filestore.FS
+	fs   filestore.FS
+}
+
+func TestShardedTestSuite(t *testing.T) {
+	suite.Run(t, &ShardedTestSuite{})
+}
+
+func (s *ShardedTestSuite) SetupTest() {
+	s.even = filestore.Memory()
+	s.odd = filestore.Memory()
+	// Files w/ even-length names go in the first shard; odd-length names go in the second one.
+	s.fs = filestore.Sharded([]filestore.FS{s.even, s.odd}, func(filePath string) uint64 {
+		return uint64(len(path.Base(filePath)))
+	})
+
+	s.Require().NoError(writeString(s.fs, "images/dude.png", "dude"))
+	s.Require().NoError(writeString(s.fs, "images/jesus.png", "jesus"))
+	s.Require().NoError(writeString(s.fs, "images/2022/donny.png", "donny"))
+}
+
+func (s *ShardedTestSuite) TestReadWrite() {
+	s.Require().True(s.even.Exists("images/dude.png"))
+	s.Require().False(s.odd.Exists("images/dude.png"))
+	s.Require().True(s.odd.Exists("images/jesus.png"))
+	s.Require().False(s.even.Exists("images/jesus.png"))
+
+	s.assertContent(s.fs, "images/jesus.png", "jesus")
+	s.assertContent(s.fs.ChangeDirectory("images"), "dude.png", "dude")
+	s.assertContent(s.fs.ChangeDirectory("images").ChangeDirectory("2022"), "donny.png", "donny")
+
+	s.Require().True(s.fs.Exists("images/2022"))
+	info, err := s.fs.Stat("images/2022")
+	s.Require().NoError(err)
+	s.Require().True(info.IsDir())
+	s.Require().False(s.fs.Exists("images/maude.png"))
+}
+
+func (s *ShardedTestSuite) TestList() {
+	infos, err := s.fs.List("images")
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"2022", "dude.png", "jesus.png"}, s.names(infos))
+
+	infos, err = s.fs.ChangeDirectory("images").List(".", func(info filestore.FileInfo) bool {
+		return !info.IsDir()
+	})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"dude.png", "jesus.png"}, s.names(infos))
+
+	_, err = s.fs.List("missing")
+	s.Require().Error(err)
+}
+
+func (s *ShardedTestSuite) TestMove() {
+	// "dude.png" and "maude.png" belong in different shards.
+	s.Require().NoError(s.fs.Move("images/dude.png", "images/maude.png"))
+	s.Require().False(s.fs.Exists("images/dude.png"))
+	s.Require().False(s.even.Exists("images/dude.png"))
+	s.Require().True(s.odd.Exists("images/maude.png"))
+	s.assertContent(s.fs, "images/maude.png", "dude")
+
+	s.Require().NoError(s.fs.Move("images", "archive"))
+	s.Require().False(s.fs.Exists("images"))
+	s.assertContent(s.fs, "archive/maude.png", "dude")
+	s.assertContent(s.fs, "archive/jesus.png", "jesus")
+	s.assertContent(s.fs, "archive/2022/donny.png", "donny")
+}
+
+func (s *ShardedTestSuite) TestMove_insideItself() {
+	s.Require().Error(s.fs.Move("images", "images/old"))
+	s.assertContent(s.fs, "images/dude.png", "dude")
+	s.assertContent(s.fs, "images/jesus.png", "jesus")
+	s.assertContent(s.fs, "images/2022/donny.png", "donny")
+	s.Require().False(s.fs.Exists("images/old"))
+}
+
+func (s *ShardedTestSuite) TestRemove() {
+	s.Require().NoError(s.fs.Remove("images/dude.png"))
+	s.Require().False(s.fs.Exists("images/dude.png"))
+	s.Require().True(s.fs.Exists("images/jesus.png"))
+
+	s.Require().NoError(s.fs.Remove("images"))
+	s.Require().False(s.even.Exists("images"))
+	s.Require().False(s.odd.Exists("images"))
+}
+
+func (s *ShardedTestSuite) TestDefaultHash() {
+	shards := []filestore.FS{filestore.Memory(), filestore.Memory(), filestore.Memory()}
+	fs := filestore.Sharded(shards, nil)
+	for i := 0; i < 30; i++ {
+		s.Require().NoError(writeString(fs, fmt.Sprintf("files/%d.txt", i), "abide"))
+	}
+
+	infos, err := fs.List("files")
+	s.Require().NoError(err)
+	s.Require().Len(infos, 30)
+	for _, shard := range shards {
+		infos, err = shard.List("files")
+		s.Require().NoError(err)
+		s.Require().NotEmpty(infos, "Should spread the files across every shard")
+	}
+}
+
+func (s *ShardedTestSuite) assertContent(fs filestore.FS, filePath string, expected string) {
+	content, err := readString(fs, filePath)
+	s.Require().NoError(err)
+	s.Require().Equal(expected, content)
+}
+
+func (s *ShardedTestSuite) names(files []filestore.FileInfo) []string {
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	return names
+}
@@ -0,0 +1,273 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// NetworkProfile describes how slow a SimulateNetwork() store should be.
+type NetworkProfile struct {
+	// Latency is the delay added to the start of every operation on the store. Calls to the files that
+	// Read() and Write() return are only subject to the bandwidth caps.
+	Latency time.Duration
+	// OpLatency overrides the Latency for specific operations. It uses the same names as SlowOperation:
+	// "stat", "exists", "list", "move", and "remove" for the store's methods of the same name, and "open" or
+	// "create" for Read() and Write().
+	OpLatency map[string]time.Duration
+	// ReadBytesPerSecond caps how quickly you can read from files. Zero means there's no cap.
+	ReadBytesPerSecond int64
+	// WriteBytesPerSecond caps how quickly you can write to files. Zero means there's no cap.
+	WriteBytesPerSecond int64
+}
+
+// latency determines how long we should wait before performing the operation.
+func (profile NetworkProfile) latency(op string) time.Duration {
+	if latency, ok := profile.OpLatency[op]; ok {
+		return latency
+	}
+	return profile.Latency
+}
+
+// SimulateNetwork wraps a file store so that it's as slow as the given profile says: every operation takes
+// at least the profile's latency, and reading/writing files is capped at the profile's bandwidth. This lets
+// you see how your timeouts, retries, and progress bars behave against a "slow S3" w/o any real
+// infrastructure; wrap a Memory() store in one and you're good to go.
+//
+// Reads and writes trickle through in chunks of roughly a tenth of a second's worth of bandwidth, so
+// anything that reports progress sees steady progress rather than one big jump at the end. When the store
+// is bound to a request via ForRequest(), cancelling the request's context interrupts the wait and fails
+// the operation w/ the context's error, just like a real remote store. You can supply WithClock() to
+// control the delays in tests.
+//
+// Example:
+//
+//	slowS3 := filestore.SimulateNetwork(filestore.Memory(), filestore.NetworkProfile{
+//	    Latency:            150 * time.Millisecond,
+//	    ReadBytesPerSecond: 512 << 10,
+//	})
+func SimulateNetwork(fs FS, profile NetworkProfile, opts ...Option) FS {
+	options := newOptions(opts)
+	return &simulatedFS{FS: fs, network: &simulatedNetwork{profile: profile, clock: options.clock}}
+}
+
+func init() {
+	RegisterLayer("simulate_network", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			Latency             string `json:"latency"`
+			ReadBytesPerSecond  int64  `json:"readBytesPerSecond"`
+			WriteBytesPerSecond int64  `json:"writeBytesPerSecond"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+
+		profile := NetworkProfile{ReadBytesPerSecond: settings.ReadBytesPerSecond, WriteBytesPerSecond: settings.WriteBytesPerSecond}
+		if settings.Latency != "" {
+			latency, err := time.ParseDuration(settings.Latency)
+			if err != nil {
+				return nil, fmt.Errorf("invalid latency: %w", err)
+			}
+			profile.Latency = latency
+		}
+		return SimulateNetwork(fs, profile), nil
+	})
+}
+
+type simulatedFS struct {
+	FS
+	network *simulatedNetwork
+}
+
+// simulatedNetwork is shared by the original wrapper and any instances derived from it via ChangeDirectory().
+type simulatedNetwork struct {
+	profile NetworkProfile
+	clock   Clock
+}
+
+// wait blocks for the given duration, or until the store's request is cancelled.
+func (n *simulatedNetwork) wait(fs FS, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	ctx := RequestContext(fs)
+	select {
+	case <-n.clock.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay waits out the latency of a single operation on the store.
+func (s *simulatedFS) delay(op string, filePath string) error {
+	if err := s.network.wait(s.FS, s.network.profile.latency(op)); err != nil {
+		return newPathError("simulated", op, filePath, err)
+	}
+	return nil
+}
+
+func (s *simulatedFS) Stat(filePath string) (FileInfo, error) {
+	if err := s.delay("stat", filePath); err != nil {
+		return nil, err
+	}
+	return s.FS.Stat(filePath)
+}
+
+// Exists returns false if the request is cancelled while we wait, since it has no other way to report errors.
+func (s *simulatedFS) Exists(filePath string) bool {
+	return s.delay("exists", filePath) == nil && s.FS.Exists(filePath)
+}
+
+func (s *simulatedFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	if err := s.delay("list", dirPath); err != nil {
+		return nil, err
+	}
+	return s.FS.List(dirPath, filters...)
+}
+
+// Read opens the file for reading; reading from it is capped at the profile's read bandwidth.
+func (s *simulatedFS) Read(filePath string) (ReaderFile, error) {
+	if err := s.delay("open", filePath); err != nil {
+		return nil, err
+	}
+	file, err := s.FS.Read(filePath)
+	if err != nil || s.network.profile.ReadBytesPerSecond <= 0 {
+		return file, err
+	}
+	return &simulatedReaderFile{ReaderFile: file, fs: s, path: filePath}, nil
+}
+
+// Write opens the file for writing; writing to it is capped at the profile's write bandwidth.
+func (s *simulatedFS) Write(filePath string) (WriterFile, error) {
+	if err := s.delay("create", filePath); err != nil {
+		return nil, err
+	}
+	file, err := s.FS.Write(filePath)
+	if err != nil || s.network.profile.WriteBytesPerSecond <= 0 {
+		return file, err
+	}
+	return &simulatedWriterFile{WriterFile: file, fs: s, path: filePath}, nil
+}
+
+func (s *simulatedFS) Move(fromPath string, toPath string) error {
+	if err := s.delay("move", fromPath); err != nil {
+		return err
+	}
+	return s.FS.Move(fromPath, toPath)
+}
+
+func (s *simulatedFS) Remove(fileOrDirPath string) error {
+	if err := s.delay("remove", fileOrDirPath); err != nil {
+		return err
+	}
+	return s.FS.Remove(fileOrDirPath)
+}
+
+func (s *simulatedFS) ChangeDirectory(dir string) FS {
+	return &simulatedFS{FS: s.FS.ChangeDirectory(dir), network: s.network}
+}
+
+func (s *simulatedFS) withContext(ctx context.Context) FS {
+	return &simulatedFS{FS: ForRequest(s.FS, ctx), network: s.network}
+}
+
+func (s *simulatedFS) requestContext() context.Context {
+	return RequestContext(s.FS)
+}
+
+func (s *simulatedFS) wrapped() []FS {
+	return []FS{s.FS}
+}
+
+// transfer waits as long as it takes to move n bytes at the given bandwidth.
+func (s *simulatedFS) transfer(op string, filePath string, n int, bytesPerSecond int64) error {
+	delay := time.Duration(int64(n) * int64(time.Second) / bytesPerSecond)
+	if err := s.network.wait(s.FS, delay); err != nil {
+		return newPathError("simulated", op, filePath, err)
+	}
+	return nil
+}
+
+// chunkSize is the most we transfer in one go: about a tenth of a second's worth of bandwidth.
+func chunkSize(bytesPerSecond int64) int {
+	if bytesPerSecond < 10 {
+		return 1
+	}
+	return int(bytesPerSecond / 10)
+}
+
+// simulatedReaderFile caps how quickly you can read from the file.
+type simulatedReaderFile struct {
+	ReaderFile
+	fs   *simulatedFS
+	path string
+}
+
+// Read reads at most one chunk's worth of data and then waits as long as that would take over the network.
+func (f *simulatedReaderFile) Read(data []byte) (int, error) {
+	bytesPerSecond := f.fs.network.profile.ReadBytesPerSecond
+	if chunk := chunkSize(bytesPerSecond); len(data) > chunk {
+		data = data[:chunk]
+	}
+	n, err := f.ReaderFile.Read(data)
+	if waitErr := f.fs.transfer("read", f.path, n, bytesPerSecond); waitErr != nil {
+		return 0, waitErr
+	}
+	return n, err
+}
+
+// ReadAt must fill the whole buffer, so it waits however long the whole buffer takes.
+func (f *simulatedReaderFile) ReadAt(data []byte, offset int64) (int, error) {
+	n, err := f.ReaderFile.ReadAt(data, offset)
+	if waitErr := f.fs.transfer("read", f.path, n, f.fs.network.profile.ReadBytesPerSecond); waitErr != nil {
+		return 0, waitErr
+	}
+	return n, err
+}
+
+// simulatedWriterFile caps how quickly you can write to the file.
+type simulatedWriterFile struct {
+	WriterFile
+	fs   *simulatedFS
+	path string
+}
+
+// Write sends the data one chunk at a time, waiting as long as each chunk would take over the network.
+func (f *simulatedWriterFile) Write(data []byte) (int, error) {
+	return f.chunked(data, f.WriterFile.Write)
+}
+
+func (f *simulatedWriterFile) WriteAt(data []byte, offset int64) (int, error) {
+	written := int64(0)
+	return f.chunked(data, func(chunk []byte) (int, error) {
+		n, err := f.WriterFile.WriteAt(chunk, offset+written)
+		written += int64(n)
+		return n, err
+	})
+}
+
+func (f *simulatedWriterFile) chunked(data []byte, write func([]byte) (int, error)) (int, error) {
+	bytesPerSecond := f.fs.network.profile.WriteBytesPerSecond
+	chunk := chunkSize(bytesPerSecond)
+	total := 0
+	for len(data) > 0 {
+		size := chunk
+		if size > len(data) {
+			size = len(data)
+		}
+		if err := f.fs.transfer("write", f.path, size, bytesPerSecond); err != nil {
+			return total, err
+		}
+		n, err := write(data[:size])
+		total += n
+		if err != nil {
+			return total, err
+		}
+		data = data[size:]
+	}
+	return total, nil
+}
+
+var _ requestBinder = &simulatedFS{}
+var _ wrapper = &simulatedFS{}
@@ -0,0 +1,116 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type SimulateNetworkTestSuite struct {
+	suite.Suite
+	clock *filestoretest.Clock
+	fs    filestore.FS
+}
+
+func TestSimulateNetworkTestSuite(t *testing.T) {
+	suite.Run(t, &SimulateNetworkTestSuite{})
+}
+
+func (s *SimulateNetworkTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	memory := filestore.Memory()
+	s.Require().NoError(writeString(memory, "a.txt", "the dude abides"))
+	s.fs = filestore.SimulateNetwork(memory, filestore.NetworkProfile{
+		Latency:             100 * time.Millisecond,
+		OpLatency:           map[string]time.Duration{"exists": 0},
+		ReadBytesPerSecond:  50,
+		WriteBytesPerSecond: 20,
+	}, filestore.WithClock(s.clock))
+}
+
+func (s *SimulateNetworkTestSuite) TestLatency() {
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.fs.Stat("a.txt")
+		done <- err
+	}()
+
+	s.clock.BlockUntil(1)
+	s.clock.Advance(99 * time.Millisecond)
+	s.Require().Empty(done, "Should not finish before the latency elapses")
+	s.clock.Advance(time.Millisecond)
+	s.Require().NoError(<-done)
+
+	s.Require().True(s.fs.Exists("a.txt"), "Should use the latency for the specific operation")
+}
+
+func (s *SimulateNetworkTestSuite) TestBandwidth() {
+	go func() {
+		s.clock.BlockUntil(1)
+		s.clock.Advance(100 * time.Millisecond)
+	}()
+	file, err := s.fs.ChangeDirectory(".").Read("a.txt")
+	s.Require().NoError(err)
+	defer file.Close()
+
+	done := make(chan []int, 1)
+	go func() {
+		var reads []int
+		buf := make([]byte, 100)
+		for {
+			n, err := file.Read(buf)
+			if err != nil {
+				done <- reads
+				return
+			}
+			reads = append(reads, n)
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		s.clock.BlockUntil(1)
+		s.clock.Advance(100 * time.Millisecond)
+	}
+	s.Require().Equal([]int{5, 5, 5}, <-done, "Should read 1/10 of a second's worth at a time")
+}
+
+func (s *SimulateNetworkTestSuite) TestBandwidth_write() {
+	go func() {
+		s.clock.BlockUntil(1)
+		s.clock.Advance(100 * time.Millisecond)
+	}()
+	file, err := s.fs.Write("b.txt")
+	s.Require().NoError(err)
+
+	done := make(chan int, 1)
+	go func() {
+		n, _ := file.Write([]byte("walter"))
+		done <- n
+	}()
+	for i := 0; i < 3; i++ {
+		s.clock.BlockUntil(1)
+		s.Require().Empty(done, "Should write 2 bytes every 1/10 of a second")
+		s.clock.Advance(100 * time.Millisecond)
+	}
+	s.Require().Equal(6, <-done)
+	s.Require().NoError(file.Close())
+}
+
+func (s *SimulateNetworkTestSuite) TestCancel() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	files := filestore.ForRequest(s.fs, ctx)
+	_, err := files.Read("a.txt")
+	s.Require().True(errors.Is(err, context.Canceled))
+	s.Require().True(errors.Is(files.Remove("a.txt"), context.Canceled))
+	s.Require().True(files.Exists("a.txt"))
+
+	var pathErr *filestore.PathError
+	s.Require().True(errors.As(err, &pathErr))
+	s.Require().Equal("open", pathErr.Op)
+}
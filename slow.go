@@ -0,0 +1,239 @@
+package filestore
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// SlowOperation describes a single call to a ReportSlow() store (or one of its files) that took too long.
+type SlowOperation struct {
+	// Op is the operation that was slow: "stat", "exists", "list", "move", or "remove" for the store's
+	// methods of the same name, "open" or "create" for Read() and Write(), and "read", "write", "seek",
+	// or "close" for calls to the files they return.
+	Op string
+	// Path is the full path of the file/directory, including the working directory of the store.
+	Path string
+	// ToPath is the full path that a file/directory was moved to; it's empty for every other operation.
+	ToPath string
+	// Duration is how long the operation took.
+	Duration time.Duration
+	// Deadline is the deadline of the request that the store was bound to (see ForRequest), if any.
+	Deadline time.Time
+	// Err is the error that the operation failed with, if any.
+	Err error
+}
+
+// ReportSlow wraps a file store, calling 'report' whenever an operation takes at least 'threshold' to
+// complete, or finishes after the deadline of the request that the store was bound to w/ ForRequest(). This
+// includes calls to the files returned by Read() and Write(), so you'll find out about a single read that
+// stalls on a flaky NFS mount, too. The report tells you which operation it was, on which path, and how long
+// it took, so you can log it however you like. The wrapper never changes the outcome of the operation.
+//
+// The report is called synchronously after the slow operation completes, so keep it quick. You can supply
+// the WithClock() option to control the durations in tests.
+//
+// Example:
+//
+//	files := filestore.ReportSlow(filestore.Disk("/mnt/nfs"), 2*time.Second, func(op filestore.SlowOperation) {
+//	    log.Printf("slow %s: %s took %v (err=%v)", op.Op, op.Path, op.Duration, op.Err)
+//	})
+func ReportSlow(fs FS, threshold time.Duration, report func(SlowOperation), opts ...Option) FS {
+	options := newOptions(opts)
+	return &slowFS{FS: fs, slow: &slowReporter{threshold: threshold, report: report, clock: options.clock}}
+}
+
+type slowFS struct {
+	FS
+	slow *slowReporter
+}
+
+// slowReporter decides which operations are slow enough to report.
+type slowReporter struct {
+	threshold time.Duration
+	report    func(SlowOperation)
+	clock     Clock
+}
+
+// Stat fetches metadata about the file, reporting it if it's slow.
+func (s *slowFS) Stat(filePath string) (FileInfo, error) {
+	finish := s.slow.start(s.FS, "stat", s.fullPath(filePath), "")
+	info, err := s.FS.Stat(filePath)
+	finish(err)
+	return info, err
+}
+
+// Exists returns true when the file/directory exists, reporting the check if it's slow.
+func (s *slowFS) Exists(filePath string) bool {
+	finish := s.slow.start(s.FS, "exists", s.fullPath(filePath), "")
+	exists := s.FS.Exists(filePath)
+	finish(nil)
+	return exists
+}
+
+// List reads the contents of the directory, reporting it if it's slow.
+func (s *slowFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	finish := s.slow.start(s.FS, "list", s.fullPath(dirPath), "")
+	infos, err := s.FS.List(dirPath, filters...)
+	finish(err)
+	return infos, err
+}
+
+// Read opens the file for reading, reporting it (or any call to the file) if it's slow.
+func (s *slowFS) Read(filePath string) (ReaderFile, error) {
+	fullPath := s.fullPath(filePath)
+	finish := s.slow.start(s.FS, "open", fullPath, "")
+	file, err := s.FS.Read(filePath)
+	finish(err)
+	if err != nil {
+		return nil, err
+	}
+	return &slowReaderFile{ReaderFile: file, slow: s.slow, fs: s.FS, path: fullPath}, nil
+}
+
+// Write opens the file for writing, reporting it (or any call to the file) if it's slow.
+func (s *slowFS) Write(filePath string) (WriterFile, error) {
+	fullPath := s.fullPath(filePath)
+	finish := s.slow.start(s.FS, "create", fullPath, "")
+	file, err := s.FS.Write(filePath)
+	finish(err)
+	if err != nil {
+		return nil, err
+	}
+	return &slowWriterFile{WriterFile: file, slow: s.slow, fs: s.FS, path: fullPath}, nil
+}
+
+// Move relocates the file/directory, reporting it if it's slow.
+func (s *slowFS) Move(fromPath string, toPath string) error {
+	finish := s.slow.start(s.FS, "move", s.fullPath(fromPath), s.fullPath(toPath))
+	err := s.FS.Move(fromPath, toPath)
+	finish(err)
+	return err
+}
+
+// Remove deletes the file/directory, reporting it if it's slow.
+func (s *slowFS) Remove(fileOrDirPath string) error {
+	finish := s.slow.start(s.FS, "remove", s.fullPath(fileOrDirPath), "")
+	err := s.FS.Remove(fileOrDirPath)
+	finish(err)
+	return err
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that reports slow operations, too.
+func (s *slowFS) ChangeDirectory(dir string) FS {
+	return &slowFS{FS: s.FS.ChangeDirectory(dir), slow: s.slow}
+}
+
+func (s *slowFS) withContext(ctx context.Context) FS {
+	return &slowFS{FS: ForRequest(s.FS, ctx), slow: s.slow}
+}
+
+func (s *slowFS) requestContext() context.Context {
+	return RequestContext(s.FS)
+}
+
+func (s *slowFS) wrapped() []FS {
+	return []FS{s.FS}
+}
+
+func (s *slowFS) fullPath(filePath string) string {
+	return joinPath(s.FS.WorkingDirectory(), filePath)
+}
+
+// start records when an operation began. Call the returned function w/ the operation's error once it's
+// done to report it if it was slow.
+func (r *slowReporter) start(fs FS, op string, fullPath string, toPath string) func(err error) {
+	began := r.clock.Now()
+	return func(err error) {
+		finished := r.clock.Now()
+		duration := finished.Sub(began)
+		deadline, hasDeadline := RequestContext(fs).Deadline()
+		if duration < r.threshold && (!hasDeadline || finished.Before(deadline)) {
+			return
+		}
+		r.report(SlowOperation{Op: op, Path: fullPath, ToPath: toPath, Duration: duration, Deadline: deadline, Err: err})
+	}
+}
+
+// slowReaderFile reports slow calls to a file opened for reading.
+type slowReaderFile struct {
+	ReaderFile
+	slow *slowReporter
+	fs   FS
+	path string
+}
+
+func (f *slowReaderFile) Read(data []byte) (int, error) {
+	finish := f.slow.start(f.fs, "read", f.path, "")
+	n, err := f.ReaderFile.Read(data)
+	finish(ignoreEOF(err))
+	return n, err
+}
+
+func (f *slowReaderFile) ReadAt(data []byte, offset int64) (int, error) {
+	finish := f.slow.start(f.fs, "read", f.path, "")
+	n, err := f.ReaderFile.ReadAt(data, offset)
+	finish(ignoreEOF(err))
+	return n, err
+}
+
+func (f *slowReaderFile) Seek(offset int64, whence int) (int64, error) {
+	finish := f.slow.start(f.fs, "seek", f.path, "")
+	position, err := f.ReaderFile.Seek(offset, whence)
+	finish(err)
+	return position, err
+}
+
+func (f *slowReaderFile) Close() error {
+	finish := f.slow.start(f.fs, "close", f.path, "")
+	err := f.ReaderFile.Close()
+	finish(err)
+	return err
+}
+
+// slowWriterFile reports slow calls to a file opened for writing.
+type slowWriterFile struct {
+	WriterFile
+	slow *slowReporter
+	fs   FS
+	path string
+}
+
+func (f *slowWriterFile) Write(data []byte) (int, error) {
+	finish := f.slow.start(f.fs, "write", f.path, "")
+	n, err := f.WriterFile.Write(data)
+	finish(err)
+	return n, err
+}
+
+func (f *slowWriterFile) WriteAt(data []byte, offset int64) (int, error) {
+	finish := f.slow.start(f.fs, "write", f.path, "")
+	n, err := f.WriterFile.WriteAt(data, offset)
+	finish(err)
+	return n, err
+}
+
+func (f *slowWriterFile) Seek(offset int64, whence int) (int64, error) {
+	finish := f.slow.start(f.fs, "seek", f.path, "")
+	position, err := f.WriterFile.Seek(offset, whence)
+	finish(err)
+	return position, err
+}
+
+func (f *slowWriterFile) Close() error {
+	finish := f.slow.start(f.fs, "close", f.path, "")
+	err := f.WriterFile.Close()
+	finish(err)
+	return err
+}
+
+// ignoreEOF drops io.EOF since reaching the end of a file isn't a failure worth reporting.
+func ignoreEOF(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+var _ requestBinder = &slowFS{}
+var _ wrapper = &slowFS{}
@@ -0,0 +1,133 @@
+package filestore_test
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReportSlowTestSuite struct {
+	suite.Suite
+	clock   *filestoretest.Clock
+	stalled *stallingFS
+	reports []filestore.SlowOperation
+	fs      filestore.FS
+}
+
+func TestReportSlowTestSuite(t *testing.T) {
+	suite.Run(t, &ReportSlowTestSuite{})
+}
+
+func (s *ReportSlowTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.stalled = &stallingFS{FS: filestore.Memory(), clock: s.clock, stalls: map[string]time.Duration{}}
+	s.reports = nil
+	s.fs = filestore.ReportSlow(s.stalled, time.Second, func(op filestore.SlowOperation) {
+		s.reports = append(s.reports, op)
+	}, filestore.WithClock(s.clock))
+
+	s.Require().NoError(writeString(s.stalled.FS, "mnt/fast.txt", "fast"))
+	s.Require().NoError(writeString(s.stalled.FS, "mnt/slow.txt", "slow"))
+}
+
+func (s *ReportSlowTestSuite) TestReport() {
+	s.stalled.stalls["/mnt/slow.txt"] = 2 * time.Second
+	s.stalled.stalls["/mnt/missing.txt"] = time.Second
+
+	_, err := s.fs.Stat("mnt/fast.txt")
+	s.Require().NoError(err)
+	_, err = s.fs.ChangeDirectory("mnt").Stat("slow.txt")
+	s.Require().NoError(err)
+	_, err = s.fs.Stat("mnt/missing.txt")
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+	s.Require().NoError(s.fs.Move("mnt/slow.txt", "mnt/moved.txt"))
+
+	s.Require().Equal([]filestore.SlowOperation{
+		{Op: "stat", Path: "/mnt/slow.txt", Duration: 2 * time.Second},
+		{Op: "stat", Path: "/mnt/missing.txt", Duration: time.Second, Err: s.reports[1].Err},
+		{Op: "move", Path: "/mnt/slow.txt", ToPath: "/mnt/moved.txt", Duration: 2 * time.Second},
+	}, s.reports)
+	s.Require().ErrorIs(s.reports[1].Err, fs.ErrNotExist)
+}
+
+func (s *ReportSlowTestSuite) TestReport_files() {
+	file, err := s.fs.Read("mnt/slow.txt")
+	s.Require().NoError(err)
+	s.stalled.stallReads = 3 * time.Second
+	buffer := make([]byte, 10)
+	_, err = file.Read(buffer)
+	s.Require().NoError(err)
+	s.stalled.stallReads = 0
+	s.Require().NoError(file.Close())
+
+	s.Require().Len(s.reports, 1)
+	s.Require().Equal("read", s.reports[0].Op)
+	s.Require().Equal("/mnt/slow.txt", s.reports[0].Path)
+	s.Require().Equal(3*time.Second, s.reports[0].Duration)
+}
+
+func (s *ReportSlowTestSuite) TestReport_deadline() {
+	ctx, cancel := context.WithDeadline(context.Background(), s.clock.Now().Add(100*time.Millisecond))
+	defer cancel()
+	s.stalled.stalls["/mnt/fast.txt"] = 200 * time.Millisecond
+
+	_, err := filestore.ForRequest(s.fs, ctx).Stat("mnt/fast.txt")
+	s.Require().NoError(err)
+	s.Require().Len(s.reports, 1, "Should report operations that blow past the request's deadline")
+	s.Require().Equal(s.clock.Now().Add(-100*time.Millisecond), s.reports[0].Deadline)
+
+	_, err = s.fs.Stat("mnt/fast.txt")
+	s.Require().NoError(err)
+	s.Require().Len(s.reports, 1, "Should not report it w/o the deadline")
+}
+
+// stallingFS advances a fake clock whenever you stat/move certain paths or read from a file, simulating
+// a backend that's slow to respond.
+type stallingFS struct {
+	filestore.FS
+	clock      *filestoretest.Clock
+	stalls     map[string]time.Duration
+	stallReads time.Duration
+}
+
+func (f *stallingFS) stall(filePath string) {
+	f.clock.Advance(f.stalls[path.Join(f.FS.WorkingDirectory(), filePath)])
+}
+
+func (f *stallingFS) Stat(filePath string) (filestore.FileInfo, error) {
+	f.stall(filePath)
+	return f.FS.Stat(filePath)
+}
+
+func (f *stallingFS) Move(fromPath string, toPath string) error {
+	f.stall(fromPath)
+	return f.FS.Move(fromPath, toPath)
+}
+
+func (f *stallingFS) Read(filePath string) (filestore.ReaderFile, error) {
+	file, err := f.FS.Read(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &stallingReaderFile{ReaderFile: file, fs: f}, nil
+}
+
+func (f *stallingFS) ChangeDirectory(dir string) filestore.FS {
+	return &stallingFS{FS: f.FS.ChangeDirectory(dir), clock: f.clock, stalls: f.stalls}
+}
+
+type stallingReaderFile struct {
+	filestore.ReaderFile
+	fs *stallingFS
+}
+
+func (f *stallingReaderFile) Read(data []byte) (int, error) {
+	f.fs.clock.Advance(f.fs.stallReads)
+	return f.ReaderFile.Read(data)
+}
@@ -0,0 +1,214 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+)
+
+// SparseMirror presents the entire tree of a (typically huge, remote) store while only keeping local copies
+// of the files you actually use, like git's sparse-checkout or OneDrive's Files-on-Demand. Stat(), List(),
+// and Exists() always describe the remote tree. The first time you Read() a file, we "hydrate" it by
+// downloading it into the same path in the local store; after that, it's read from the local copy for as
+// long as the copy is still fresh (same size as the remote file and not older than it). Use Hydrate() to
+// download files or entire directories ahead of time, and Dehydrate() to free up the local space again.
+//
+// Writes, moves, and removals go to the remote store. Moves and removals are applied to any local copies,
+// too, while writing a file discards its local copy so the next read hydrates the new version. Unlike
+// Cached(), local copies persist between processes and are never evicted on their own, so the local store
+// doubles as a browsable checkout of everything you've used so far.
+//
+// Example:
+//
+//	assets := filestore.SparseMirror(filestore.S3("game-assets"), filestore.Disk("/work/assets"))
+//	err := assets.Hydrate("levels/tutorial")           // download a whole directory up front
+//	file, err := assets.Read("textures/grass/01.png") // or download files as you go
+func SparseMirror(remote FS, local FS) *SparseFS {
+	return &SparseFS{FS: remote, local: local}
+}
+
+func init() {
+	RegisterLayer("sparse", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			LocalDir string `json:"localDir"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		if settings.LocalDir == "" {
+			return nil, fmt.Errorf("sparse: localDir is required")
+		}
+		return SparseMirror(fs, Disk(settings.LocalDir)), nil
+	})
+}
+
+// SparseFS is a file store that downloads remote files on demand; see SparseMirror().
+type SparseFS struct {
+	// FS is the remote store, which answers every question about the tree.
+	FS
+	local FS
+}
+
+// Read opens the local copy of the file, hydrating it from the remote store first if we don't have a fresh copy.
+func (s *SparseFS) Read(filePath string) (ReaderFile, error) {
+	info, err := s.FS.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		// Let the remote store fail however it normally does for directories.
+		return s.FS.Read(filePath)
+	case s.fresh(filePath, info):
+		return s.local.Read(filePath)
+	}
+	if err = s.hydrate(filePath); err != nil {
+		return nil, fmt.Errorf("filestore: sparse: read: %w", err)
+	}
+	return s.local.Read(filePath)
+}
+
+// Write opens the file for writing in the remote store. Its local copy is discarded once you close it.
+func (s *SparseFS) Write(filePath string) (WriterFile, error) {
+	file, err := s.FS.Write(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &sparseWriterFile{WriterFile: file, fs: s, path: filePath}, nil
+}
+
+// Move relocates the file/directory in the remote store and then moves any local copies along w/ it.
+func (s *SparseFS) Move(fromPath string, toPath string) error {
+	if err := s.FS.Move(fromPath, toPath); err != nil {
+		return err
+	}
+	err := s.local.Move(fromPath, toPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		// We can always hydrate it again later, so just make sure that nothing stale is left behind.
+		_ = s.Dehydrate(fromPath)
+		_ = s.Dehydrate(toPath)
+	}
+	return nil
+}
+
+// Remove deletes the file/directory from the remote store and then removes any local copies of it.
+func (s *SparseFS) Remove(fileOrDirPath string) error {
+	if err := s.FS.Remove(fileOrDirPath); err != nil {
+		return err
+	}
+	if err := s.Dehydrate(fileOrDirPath); err != nil {
+		return fmt.Errorf("filestore: sparse: remove: %w", err)
+	}
+	return nil
+}
+
+// Hydrate downloads local copies of the given files ahead of time. Directories are hydrated recursively.
+// Files that already have a fresh local copy aren't downloaded again.
+func (s *SparseFS) Hydrate(paths ...string) error {
+	for _, filePath := range paths {
+		err := Walk(s.FS, filePath, func(walkPath string, info FileInfo, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case info.IsDir() || s.fresh(walkPath, info):
+				return nil
+			default:
+				return s.hydrate(walkPath)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("filestore: sparse: hydrate: %w", err)
+		}
+	}
+	return nil
+}
+
+// Dehydrate removes the local copies of the file, or of everything in the directory, to free up local space.
+// The remote files are untouched, and reading them again hydrates them again.
+func (s *SparseFS) Dehydrate(fileOrDirPath string) error {
+	err := s.local.Remove(fileOrDirPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("filestore: sparse: dehydrate: %w", err)
+	}
+	return nil
+}
+
+// Hydrated returns true when we have a fresh local copy of the file, so reading it won't touch the remote store.
+func (s *SparseFS) Hydrated(filePath string) bool {
+	info, err := s.FS.Stat(filePath)
+	return err == nil && !info.IsDir() && s.fresh(filePath, info)
+}
+
+// ChangeDirectory creates a new sparse mirror rooted in the given subdirectory of both stores.
+func (s *SparseFS) ChangeDirectory(dir string) FS {
+	return &SparseFS{FS: s.FS.ChangeDirectory(dir), local: s.local.ChangeDirectory(dir)}
+}
+
+func (s *SparseFS) withContext(ctx context.Context) FS {
+	return &SparseFS{FS: ForRequest(s.FS, ctx), local: ForRequest(s.local, ctx)}
+}
+
+func (s *SparseFS) requestContext() context.Context {
+	return RequestContext(s.FS)
+}
+
+func (s *SparseFS) wrapped() []FS {
+	return []FS{s.FS, s.local}
+}
+
+// fresh returns true when the local copy of the file is up-to-date w/ the remote file's info.
+func (s *SparseFS) fresh(filePath string, remoteInfo FileInfo) bool {
+	localInfo, err := s.local.Stat(filePath)
+	return err == nil && !localInfo.IsDir() && mirrorFresh(0)(remoteInfo, localInfo)
+}
+
+// hydrate downloads the remote file to a temp file next to the local copy and then moves it into place,
+// so nobody ever reads a partially downloaded copy.
+func (s *SparseFS) hydrate(filePath string) error {
+	remote, err := s.FS.Read(filePath)
+	if err != nil {
+		return err
+	}
+	defer remote.Close()
+
+	tempName, err := UniqueName("." + path.Base(filePath) + ".*.hydrate")
+	if err != nil {
+		return err
+	}
+	tempPath := path.Join(path.Dir(filePath), tempName)
+	defer func() { _ = s.local.Remove(tempPath) }()
+
+	output, err := s.local.Write(tempPath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(output, remote); err != nil {
+		_ = output.Close()
+		return err
+	}
+	if err = output.Close(); err != nil {
+		return err
+	}
+	return s.local.Move(tempPath, filePath)
+}
+
+// sparseWriterFile discards the local copy of the file once the new version has been written.
+type sparseWriterFile struct {
+	WriterFile
+	fs   *SparseFS
+	path string
+}
+
+func (f *sparseWriterFile) Close() error {
+	if err := f.WriterFile.Close(); err != nil {
+		return err
+	}
+	return f.fs.Dehydrate(f.path)
+}
+
+var _ FS = &SparseFS{}
+var _ requestBinder = &SparseFS{}
+var _ wrapper = &SparseFS{}
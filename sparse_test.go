@@ -0,0 +1,102 @@
+package filestore_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type SparseTestSuite struct {
+	suite.Suite
+	clock  *filestoretest.Clock
+	remote filestore.FS
+	local  filestore.FS
+	fs     *filestore.SparseFS
+}
+
+func TestSparseTestSuite(t *testing.T) {
+	suite.Run(t, &SparseTestSuite{})
+}
+
+func (s *SparseTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.remote = filestore.Memory(filestore.WithClock(s.clock))
+	s.local = filestore.Memory(filestore.WithClock(s.clock))
+	s.fs = filestore.SparseMirror(s.remote, s.local)
+
+	s.Require().NoError(writeString(s.remote, "levels/tutorial/map.json", "map"))
+	s.Require().NoError(writeString(s.remote, "levels/tutorial/intro.txt", "intro"))
+	s.Require().NoError(writeString(s.remote, "textures/grass.png", "grass"))
+}
+
+func (s *SparseTestSuite) TestRead() {
+	infos, err := s.fs.List("levels/tutorial")
+	s.Require().NoError(err)
+	s.Require().Len(infos, 2, "Should list the full remote tree")
+	s.Require().False(s.local.Exists("levels"), "Listing shouldn't hydrate anything")
+
+	s.assertContent(s.fs, "textures/grass.png", "grass")
+	s.Require().True(s.fs.Hydrated("textures/grass.png"))
+	s.Require().False(s.fs.Hydrated("levels/tutorial/map.json"))
+	s.assertContent(s.local, "textures/grass.png", "grass")
+
+	// Tamper w/ the local copy to prove that it's the one we read from.
+	s.clock.Advance(time.Minute)
+	s.Require().NoError(writeString(s.local, "textures/grass.png", "GRASS"))
+	s.assertContent(s.fs.ChangeDirectory("textures"), "grass.png", "GRASS")
+
+	// Once the remote file changes, the local copy is stale and we hydrate it again.
+	s.clock.Advance(time.Minute)
+	s.Require().NoError(writeString(s.remote, "textures/grass.png", "grass v2"))
+	s.Require().False(s.fs.Hydrated("textures/grass.png"))
+	s.assertContent(s.fs, "textures/grass.png", "grass v2")
+	s.Require().True(s.fs.Hydrated("textures/grass.png"))
+
+	_, err = s.fs.Read("textures/missing.png")
+	s.Require().Error(err)
+}
+
+func (s *SparseTestSuite) TestHydrate() {
+	s.Require().NoError(s.fs.Hydrate("levels"))
+	s.assertContent(s.local, "levels/tutorial/map.json", "map")
+	s.assertContent(s.local, "levels/tutorial/intro.txt", "intro")
+	s.Require().False(s.local.Exists("textures"))
+
+	infos, err := s.local.List("levels/tutorial")
+	s.Require().NoError(err)
+	s.Require().Len(infos, 2, "Shouldn't leave any temp files behind")
+
+	s.Require().NoError(s.fs.Dehydrate("levels/tutorial/map.json"))
+	s.Require().False(s.fs.Hydrated("levels/tutorial/map.json"))
+	s.Require().True(s.fs.Exists("levels/tutorial/map.json"), "Dehydrating shouldn't touch the remote file")
+	s.Require().NoError(s.fs.Dehydrate("levels/tutorial/map.json"), "Dehydrating twice should be fine")
+
+	s.Require().Error(s.fs.Hydrate("levels/missing"))
+}
+
+func (s *SparseTestSuite) TestChanges() {
+	files := filestore.ForRequest(s.fs.ChangeDirectory("levels"), context.Background())
+	s.Require().NoError(s.fs.Hydrate("levels"))
+
+	s.Require().NoError(writeString(files, "tutorial/map.json", "new map"))
+	s.Require().False(s.local.Exists("levels/tutorial/map.json"), "Writing should discard the local copy")
+	s.assertContent(s.remote, "levels/tutorial/map.json", "new map")
+
+	s.Require().NoError(files.Move("tutorial/intro.txt", "tutorial/outro.txt"))
+	s.Require().False(s.remote.Exists("levels/tutorial/intro.txt"))
+	s.assertContent(s.local, "levels/tutorial/outro.txt", "intro")
+
+	s.Require().NoError(files.Remove("tutorial"))
+	s.Require().False(s.remote.Exists("levels/tutorial"))
+	s.Require().False(s.local.Exists("levels/tutorial"))
+}
+
+func (s *SparseTestSuite) assertContent(fs filestore.FS, filePath string, expected string, msgAndArgs ...any) {
+	content, err := readString(fs, filePath)
+	s.Require().NoError(err, msgAndArgs...)
+	s.Require().Equal(expected, content, msgAndArgs...)
+}
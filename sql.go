@@ -0,0 +1,542 @@
+package filestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// sqlTable is the name of the table that SQL-backed stores keep their files and directories in.
+const sqlTable = "filestore_files"
+
+// sqlDialect captures the (few) differences between the databases that we support.
+type sqlDialect struct {
+	// name identifies the database in error messages (e.g. "sqlite").
+	name string
+	// blobType is the column type for binary data.
+	blobType string
+	// numberedParams is true when the database uses $1, $2, etc. rather than ? for parameters.
+	numberedParams bool
+}
+
+// rebind converts a query written w/ ? placeholders to the dialect's placeholder syntax.
+func (d sqlDialect) rebind(query string) string {
+	if !d.numberedParams {
+		return query
+	}
+	var builder strings.Builder
+	param := 0
+	for _, ch := range query {
+		if ch == '?' {
+			param++
+			builder.WriteString("$" + strconv.Itoa(param))
+			continue
+		}
+		builder.WriteRune(ch)
+	}
+	return builder.String()
+}
+
+// SQLFS is a file store that keeps every file's contents and metadata in a single table of a SQL
+// database. Directories are rows, too, so they behave just like they do in a MemoryFS: writing a file
+// creates its parent directories, and empty directories stick around until you remove them. Every
+// Write, Move, and Remove is a single transaction, so readers never see half-finished changes.
+//
+// Files are loaded into memory in their entirety when you Read() them, and written in a single
+// statement when you close the WriterFile, so this is best suited for small to medium sized files.
+//
+// A SQLFS is safe for concurrent use by multiple goroutines.
+type SQLFS struct {
+	db       *sql.DB
+	dialect  sqlDialect
+	clock    Clock
+	basePath string
+
+	// ownsDB is true when we opened the database ourselves, so Close() should close it.
+	ownsDB bool
+
+	// ctx is the context of the request this store was derived for via ForRequest(), if any.
+	ctx context.Context
+}
+
+// registeredDriver returns the first of the candidate driver names that is registered w/ database/sql, if any.
+func registeredDriver(candidates []string) string {
+	registered := map[string]bool{}
+	for _, driver := range sql.Drivers() {
+		registered[driver] = true
+	}
+	for _, driver := range candidates {
+		if registered[driver] {
+			return driver
+		}
+	}
+	return ""
+}
+
+// newSQLFS creates the table (if necessary) and returns a store that uses it.
+func newSQLFS(db *sql.DB, dialect sqlDialect, ownsDB bool, opts []Option) (*SQLFS, error) {
+	options := newOptions(opts)
+	schema := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		path     TEXT PRIMARY KEY,
+		parent   TEXT NOT NULL,
+		name     TEXT NOT NULL,
+		dir      INTEGER NOT NULL,
+		data     %s,
+		size     BIGINT NOT NULL,
+		mod_time BIGINT NOT NULL,
+		created  BIGINT NOT NULL
+	)`, sqlTable, dialect.blobType)
+	if _, err := db.Exec(schema); err != nil {
+		return nil, newPathError(dialect.name, "create table", "", err)
+	}
+	index := fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_parent ON %s (parent)", sqlTable, sqlTable)
+	if _, err := db.Exec(index); err != nil {
+		return nil, newPathError(dialect.name, "create index", "", err)
+	}
+	return &SQLFS{db: db, dialect: dialect, clock: options.clock, basePath: "/", ownsDB: ownsDB}, nil
+}
+
+// pathError creates the structured error for an operation that failed on this store's database.
+func (s SQLFS) pathError(op string, filePath string, err error) error {
+	return newPathError(s.dialect.name, op, filePath, err)
+}
+
+// resolve converts a path relative to this FS' working directory into an absolute path within the store.
+// Since the base path is always absolute, there's no way to ".." your way out of the root.
+func (s SQLFS) resolve(filePath string) (string, error) {
+	return resolvePath(s.basePath, filePath)
+}
+
+// WorkingDirectory returns the current FS context's path/directory.
+func (s SQLFS) WorkingDirectory() string {
+	return path.Clean(s.basePath)
+}
+
+// ChangeDirectory returns a new FS that is rooted in the given subdirectory of this FS. The new
+// instance shares the same database as this one.
+func (s SQLFS) ChangeDirectory(dir string) FS {
+	s.basePath = joinPath(s.basePath, dir)
+	return &s
+}
+
+// withContext returns a copy of this store that carries the request's context; see ForRequest().
+func (s SQLFS) withContext(ctx context.Context) FS {
+	s.ctx = ctx
+	return &s
+}
+
+func (s SQLFS) requestContext() context.Context {
+	return contextOrBackground(s.ctx)
+}
+
+// Stat fetches metadata about the file w/o actually opening it for reading/writing.
+func (s SQLFS) Stat(filePath string) (FileInfo, error) {
+	fullPath, err := s.resolve(filePath)
+	if err != nil {
+		return nil, s.pathError("stat", filePath, err)
+	}
+	info, err := s.lookup(s.requestContext(), s.db, fullPath)
+	switch {
+	case err != nil:
+		return nil, s.pathError("stat", filePath, err)
+	case info == nil:
+		return nil, s.pathError("stat", filePath, fs.ErrNotExist)
+	default:
+		return *info, nil
+	}
+}
+
+// Exists returns true when the file/directory already exits in the file system.
+func (s SQLFS) Exists(filePath string) bool {
+	info, err := s.Stat(filePath)
+	return err == nil && info != nil
+}
+
+// Read opens the given file at the given path, providing you with an io.Reader that you can use to
+// stream bytes from it. The entire file is loaded when you call Read(), so the reader sees a snapshot
+// of the file's contents regardless of any writes that happen afterwards.
+func (s SQLFS) Read(filePath string) (ReaderFile, error) {
+	fullPath, err := s.resolve(filePath)
+	if err != nil {
+		return nil, s.pathError("open", filePath, err)
+	}
+
+	var dir bool
+	var data []byte
+	query := s.dialect.rebind("SELECT dir, data FROM " + sqlTable + " WHERE path = ?")
+	err = s.db.QueryRowContext(s.requestContext(), query, fullPath).Scan(&dir, &data)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) && fullPath != "/":
+		return nil, s.pathError("open", filePath, fs.ErrNotExist)
+	case errors.Is(err, sql.ErrNoRows) || dir:
+		return nil, s.pathError("open", filePath, errIsDirectory)
+	case err != nil:
+		return nil, s.pathError("open", filePath, err)
+	}
+	return newBytesReaderFile(data), nil
+}
+
+// Write opens the given file at the given path for writing. The resulting file
+// behaves like a standard io.Writer/At.
+//
+// This operation will lazy-create the parent directory(s) if it does not exist. Should
+// the file already exist, this will overwrite its entire contents so that it only contains
+// what you write this time. The new contents are stored once you close the file.
+func (s SQLFS) Write(filePath string) (WriterFile, error) {
+	fullPath, err := s.resolve(filePath)
+	if err != nil {
+		return nil, s.pathError("write", filePath, err)
+	}
+	if fullPath == "/" {
+		return nil, s.pathError("write", filePath, errIsDirectory)
+	}
+	if err := s.store(fullPath, nil); err != nil {
+		return nil, s.pathError("write", filePath, err)
+	}
+	return newSQLWriterFile(s, fullPath), nil
+}
+
+// store writes the file's data (creating its parent directories as necessary) in a single transaction.
+func (s SQLFS) store(fullPath string, data []byte) error {
+	ctx := s.requestContext()
+	return s.transaction(ctx, func(tx *sql.Tx) error {
+		now := s.clock.Now().UnixNano()
+		if err := s.mkdirAll(ctx, tx, path.Dir(fullPath), now); err != nil {
+			return err
+		}
+		existing, err := s.lookup(ctx, tx, fullPath)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.dir {
+			return fmt.Errorf("trying to write directory like a file")
+		}
+
+		query := s.dialect.rebind("INSERT INTO " + sqlTable + " (path, parent, name, dir, data, size, mod_time, created) " +
+			"VALUES (?, ?, ?, 0, ?, ?, ?, ?) " +
+			"ON CONFLICT (path) DO UPDATE SET data = excluded.data, size = excluded.size, mod_time = excluded.mod_time")
+		if data == nil {
+			data = []byte{}
+		}
+		_, err = tx.ExecContext(ctx, query, fullPath, path.Dir(fullPath), path.Base(fullPath), data, len(data), now, now)
+		return err
+	})
+}
+
+// List performs the equivalent of the "ls" command. It returns a slice of
+// all files and directories found in the target dirPath.
+//
+// You can optionally provide a set of filters to limit which files/directories
+// are included in the final set.
+func (s SQLFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	fullPath, err := s.resolve(dirPath)
+	if err != nil {
+		return nil, s.pathError("list files", dirPath, err)
+	}
+
+	ctx := s.requestContext()
+	dir, err := s.lookup(ctx, s.db, fullPath)
+	switch {
+	case err != nil:
+		return nil, s.pathError("list files", dirPath, err)
+	case dir == nil:
+		return nil, nil
+	case !dir.dir:
+		return nil, s.pathError("list files", dirPath, ErrNotDirectory)
+	}
+
+	query := s.dialect.rebind("SELECT name, dir, size, mod_time, created FROM " + sqlTable + " WHERE parent = ?")
+	rows, err := s.db.QueryContext(ctx, query, fullPath)
+	if err != nil {
+		return nil, s.pathError("list files", dirPath, err)
+	}
+	defer rows.Close()
+
+	var infos []FileInfo
+	for rows.Next() {
+		info, err := scanSQLFileInfo(rows)
+		if err != nil {
+			return nil, s.pathError("list files", dirPath, err)
+		}
+		matches, err := fileMatchesFilters(info, filters)
+		if err != nil {
+			return nil, s.pathError("list files", dirPath, err)
+		}
+		if matches {
+			infos = append(infos, info)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.pathError("list files", dirPath, err)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// Remove deletes the given file/directory and any of its children. Removing the root
+// of the store simply removes everything in it.
+func (s SQLFS) Remove(fileOrDirPath string) error {
+	fullPath, err := s.resolve(fileOrDirPath)
+	if err != nil {
+		return s.pathError("remove", fileOrDirPath, err)
+	}
+
+	ctx := s.requestContext()
+	if fullPath == "/" {
+		_, err = s.db.ExecContext(ctx, "DELETE FROM "+sqlTable)
+	} else {
+		query := s.dialect.rebind("DELETE FROM " + sqlTable + " WHERE path = ? OR substr(path, 1, ?) = ?")
+		prefix := fullPath + "/"
+		_, err = s.db.ExecContext(ctx, query, fullPath, utf8.RuneCountInString(prefix), prefix)
+	}
+	if err != nil {
+		return s.pathError("remove", fileOrDirPath, err)
+	}
+	return nil
+}
+
+// Move takes an existing file at the fromPath location and moves it to another
+// spot in this file system; the toPath location. Moving a directory moves everything
+// inside of it in the same transaction.
+func (s SQLFS) Move(fromPath string, toPath string) error {
+	fromFullPath, err := s.resolve(fromPath)
+	if err != nil {
+		return s.pathError("move", fromPath, err)
+	}
+	toFullPath, err := s.resolve(toPath)
+	if err != nil {
+		return s.pathError("move", toPath, err)
+	}
+
+	ctx := s.requestContext()
+	return s.transaction(ctx, func(tx *sql.Tx) error {
+		// Ensure the original file exists in the first place.
+		node, err := s.lookup(ctx, tx, fromFullPath)
+		switch {
+		case err != nil:
+			return s.pathError("move", fromPath, err)
+		case node == nil:
+			return s.pathError("move", fromPath, fs.ErrNotExist)
+		case fromFullPath == toFullPath:
+			return nil
+		case fromFullPath == "/" || strings.HasPrefix(toFullPath, fromFullPath+"/"):
+			return s.pathError("move", fromPath, errors.New("can not move a directory inside of itself"))
+		}
+
+		// Mirror the rules for os.Rename(). You can overwrite an existing file with another
+		// file, but you can never replace an existing directory or overwrite a file w/ a directory.
+		existing, err := s.lookup(ctx, tx, toFullPath)
+		switch {
+		case err != nil:
+			return s.pathError("move", toPath, err)
+		case existing != nil && existing.dir:
+			return s.pathError("move", toPath, fs.ErrExist)
+		case existing != nil && node.dir:
+			return s.pathError("move", toPath, ErrNotDirectory)
+		}
+
+		// Lazily create the directory where we will move the file to.
+		if err := s.mkdirAll(ctx, tx, path.Dir(toFullPath), s.clock.Now().UnixNano()); err != nil {
+			return s.pathError("move", fromPath, err)
+		}
+		if existing != nil {
+			query := s.dialect.rebind("DELETE FROM " + sqlTable + " WHERE path = ?")
+			if _, err := tx.ExecContext(ctx, query, toFullPath); err != nil {
+				return s.pathError("move", fromPath, err)
+			}
+		}
+
+		query := s.dialect.rebind("UPDATE " + sqlTable + " SET path = ?, parent = ?, name = ? WHERE path = ?")
+		if _, err := tx.ExecContext(ctx, query, toFullPath, path.Dir(toFullPath), path.Base(toFullPath), fromFullPath); err != nil {
+			return s.pathError("move", fromPath, err)
+		}
+		if !node.dir {
+			return nil
+		}
+
+		// Everything inside of the directory keeps its name, but the beginning of its path changes.
+		prefix := fromFullPath + "/"
+		prefixLength := utf8.RuneCountInString(fromFullPath)
+		query = s.dialect.rebind("UPDATE " + sqlTable + " SET path = ? || substr(path, ?), parent = ? || substr(parent, ?) " +
+			"WHERE substr(path, 1, ?) = ?")
+		_, err = tx.ExecContext(ctx, query, toFullPath, prefixLength+1, toFullPath, prefixLength+1, prefixLength+1, prefix)
+		if err != nil {
+			return s.pathError("move", fromPath, err)
+		}
+		return nil
+	})
+}
+
+// Ping verifies that we can still talk to the database.
+func (s SQLFS) Ping(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return s.pathError("ping", "", err)
+	}
+	return nil
+}
+
+// Close closes the database if the store opened it (e.g. SQLite()). If you supplied your own
+// *sql.DB, it's up to you to close it.
+func (s SQLFS) Close(_ context.Context) error {
+	if !s.ownsDB {
+		return nil
+	}
+	if err := s.db.Close(); err != nil {
+		return s.pathError("close", "", err)
+	}
+	return nil
+}
+
+// sqlQueryer is the subset of *sql.DB and *sql.Tx that lookups need.
+type sqlQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// lookup finds the file/directory at the given absolute, cleaned path. It returns nil when there is
+// no such file/directory.
+func (s SQLFS) lookup(ctx context.Context, db sqlQueryer, fullPath string) (*sqlFileInfo, error) {
+	if fullPath == "/" {
+		return &sqlFileInfo{name: "/", dir: true}, nil
+	}
+	query := s.dialect.rebind("SELECT name, dir, size, mod_time, created FROM " + sqlTable + " WHERE path = ?")
+	info, err := scanSQLFileInfo(db.QueryRowContext(ctx, query, fullPath))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// mkdirAll creates the directory at the given absolute path along w/ any missing parents.
+func (s SQLFS) mkdirAll(ctx context.Context, tx *sql.Tx, fullPath string, now int64) error {
+	var dirs []string
+	for dir := fullPath; dir != "/" && dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+
+	// Databases like Postgres let another transaction create the same directory concurrently, and
+	// that's fine as long as it ends up existing.
+	insert := s.dialect.rebind("INSERT INTO " + sqlTable + " (path, parent, name, dir, size, mod_time, created) " +
+		"VALUES (?, ?, ?, 1, 0, ?, ?) ON CONFLICT (path) DO NOTHING")
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		existing, err := s.lookup(ctx, tx, dir)
+		switch {
+		case err != nil:
+			return err
+		case existing == nil:
+			if _, err := tx.ExecContext(ctx, insert, dir, path.Dir(dir), path.Base(dir), now, now); err != nil {
+				return err
+			}
+		case !existing.dir:
+			return fmt.Errorf("not a directory: %s", existing.name)
+		}
+	}
+	return nil
+}
+
+// transaction runs the function in a transaction, committing if it succeeds and rolling back if it fails.
+func (s SQLFS) transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlScanner is the subset of *sql.Row and *sql.Rows that we need to read file info.
+type sqlScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSQLFileInfo(row sqlScanner) (sqlFileInfo, error) {
+	var info sqlFileInfo
+	var modTime, created int64
+	if err := row.Scan(&info.name, &info.dir, &info.size, &modTime, &created); err != nil {
+		return sqlFileInfo{}, err
+	}
+	info.modTime = time.Unix(0, modTime)
+	info.created = time.Unix(0, created)
+	return info, nil
+}
+
+// sqlFileInfo is the 'stat' info of a single row.
+type sqlFileInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+	created time.Time
+}
+
+func (info sqlFileInfo) Name() string {
+	return info.name
+}
+
+func (info sqlFileInfo) Size() int64 {
+	return info.size
+}
+
+func (info sqlFileInfo) Mode() fs.FileMode {
+	if info.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (info sqlFileInfo) ModTime() time.Time {
+	return info.modTime
+}
+
+func (info sqlFileInfo) IsDir() bool {
+	return info.dir
+}
+
+func (info sqlFileInfo) Sys() any {
+	return nil
+}
+
+// CreationTime returns the time that this file was first written to the store.
+func (info sqlFileInfo) CreationTime() (time.Time, bool) {
+	return info.created, true
+}
+
+// sqlWriterFile buffers all writes privately and stores them in the database on Close().
+type sqlWriterFile struct {
+	*bufferWriter
+	fs       SQLFS
+	fullPath string
+}
+
+func newSQLWriterFile(fs SQLFS, fullPath string) *sqlWriterFile {
+	file := &sqlWriterFile{fs: fs, fullPath: fullPath}
+	file.bufferWriter = newBufferWriter(fs.dialect.name, file.store)
+	return file
+}
+
+// store saves everything you wrote in a single transaction so that subsequent readers can see it.
+func (w *sqlWriterFile) store(data []byte) error {
+	if err := w.fs.store(w.fullPath, data); err != nil {
+		return fmt.Errorf("%s fs: close: %w", w.fs.dialect.name, err)
+	}
+	return nil
+}
+
+var _ FS = SQLFS{}
+var _ Closer = SQLFS{}
+var _ Pinger = SQLFS{}
+var _ requestBinder = SQLFS{}
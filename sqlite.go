@@ -0,0 +1,63 @@
+package filestore
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+func init() {
+	RegisterScheme("sqlite", func(u *url.URL) (FS, error) {
+		dbPath := urlPath(u)
+		if dbPath == "" {
+			return nil, fmt.Errorf("missing database path")
+		}
+		return SQLite(dbPath)
+	})
+}
+
+// sqliteDrivers are the names that the popular SQLite drivers register themselves as (mattn/go-sqlite3
+// and modernc.org/sqlite, respectively).
+var sqliteDrivers = []string{"sqlite3", "sqlite"}
+
+// SQLite creates a file store that keeps every file and directory in a single SQLite database file,
+// creating the database if it doesn't exist yet. This gives you one portable artifact (great for desktop
+// apps) w/ transactional writes, moves, and removes. See SQLFS for more details.
+//
+// This package doesn't link in a SQLite driver itself, so you don't pay for cgo (or a pure Go port of
+// SQLite) unless you use this. Import the driver of your choice in your main package, and we'll use
+// whichever one is registered w/ database/sql. You can supply the WithClock() option to control the
+// modification/creation times recorded for files in this store. Close() the store to close the database.
+//
+// Example:
+//
+//	import _ "github.com/mattn/go-sqlite3"
+//	...
+//	files, err := filestore.SQLite("data/library.db")
+//	if err != nil {
+//	    // handle your error nicely
+//	}
+//	defer files.Close(ctx)
+func SQLite(dbPath string, opts ...Option) (*SQLFS, error) {
+	driver := registeredDriver(sqliteDrivers)
+	if driver == "" {
+		return nil, newPathError("sqlite", "open", dbPath, errors.New("no SQLite driver registered; import one such as github.com/mattn/go-sqlite3"))
+	}
+	db, err := sql.Open(driver, dbPath)
+	if err != nil {
+		return nil, newPathError("sqlite", "open", dbPath, err)
+	}
+
+	// SQLite only allows one writer at a time anyway, and a single connection ensures that concurrent
+	// transactions wait their turn rather than failing because the database is locked.
+	db.SetMaxOpenConns(1)
+
+	dialect := sqlDialect{name: "sqlite", blobType: "BLOB"}
+	store, err := newSQLFS(db, dialect, true, opts)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,289 @@
+//go:build cgo
+
+package filestore_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type SQLiteTestSuite struct {
+	suite.Suite
+	dbPath string
+	fs     *filestore.SQLFS
+}
+
+func TestSQLiteTestSuite(t *testing.T) {
+	suite.Run(t, &SQLiteTestSuite{})
+}
+
+func (s *SQLiteTestSuite) SetupTest() {
+	s.dbPath = filepath.Join(s.T().TempDir(), "files.db")
+	fs, err := filestore.SQLite(s.dbPath)
+	s.Require().NoError(err)
+	s.fs = fs
+
+	s.write("1.lebowski", "jeff")
+	s.write("2.lebowski", "walter")
+	s.write("3.lebowski", "donnie")
+	s.write("4.lebowski", "maude")
+	s.write("duderino/5.lebowski", "jackie")
+	s.write("duderino/6.lebowski", "nihilist")
+
+	// There's no "mkdir" operation, so create an empty "dude/" directory by writing a file and removing it.
+	s.write("dude/tmp.txt", "")
+	s.Require().NoError(s.fs.Remove("dude/tmp.txt"))
+}
+
+func (s *SQLiteTestSuite) TearDownTest() {
+	s.Require().NoError(s.fs.Close(context.Background()))
+}
+
+func (s *SQLiteTestSuite) TestStat() {
+	info, err := s.fs.Stat("1.lebowski")
+	s.Require().NoError(err, "Running 'stat' on valid file should not give an error")
+	s.Require().Equal("1.lebowski", info.Name())
+	s.Require().Equal(int64(4), info.Size())
+	s.Require().False(info.IsDir())
+
+	info, err = s.fs.Stat("duderino")
+	s.Require().NoError(err, "Running 'stat' on valid directory should not give an error")
+	s.Require().True(info.IsDir())
+
+	info, err = s.fs.Stat(".")
+	s.Require().NoError(err, "Running 'stat' on the root should not give an error")
+	s.Require().True(info.IsDir())
+
+	_, err = s.fs.Stat("does-not-exist.txt")
+	s.Require().Error(err, "Running 'stat' on non-existent file should give an error")
+}
+
+func (s *SQLiteTestSuite) TestExists() {
+	s.Require().True(s.fs.Exists("."), "Current directory should exist")
+	s.Require().True(s.fs.Exists("1.lebowski"), "Real file should exist")
+	s.Require().True(s.fs.Exists("dude"), "Empty directory should exist")
+	s.Require().True(s.fs.Exists("duderino/../dude"), "Real dir should exist when specifying relative path")
+	s.Require().False(s.fs.Exists("asldkfj"), "Non-existing entry should be false for Exists()")
+	s.Require().False(s.fs.Exists("1.lebowski/nope"), "Can't have children of a file")
+
+	duderino := s.fs.ChangeDirectory("duderino")
+	s.Require().Equal("/duderino", duderino.WorkingDirectory())
+	s.Require().True(duderino.Exists("5.lebowski"), "Real file should exist even after cd")
+	s.Require().False(duderino.Exists("1.lebowski"), "Non-existing file should not exist even after cd")
+}
+
+func (s *SQLiteTestSuite) TestRead() {
+	_, err := s.fs.Read("not-found.txt")
+	s.Require().Error(err, "Reading invalid file should fail")
+
+	_, err = s.fs.Read("duderino")
+	s.Require().Error(err, "Reading directory as if it were a file should fail")
+
+	file, err := s.fs.Read("duderino/6.lebowski")
+	s.Require().NoError(err, "Reading valid file should not fail")
+	defer file.Close()
+
+	buf := make([]byte, 4)
+	_, err = file.ReadAt(buf, 4)
+	s.Require().NoError(err)
+	s.Require().Equal("list", string(buf))
+
+	_, err = file.Seek(3, io.SeekStart)
+	s.Require().NoError(err)
+	data, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal("ilist", string(data))
+}
+
+func (s *SQLiteTestSuite) TestWrite() {
+	s.write("1.lebowski", "thank you donnie")
+	s.Require().Equal("thank you donnie", s.read(s.fs, "1.lebowski"), "Overwritten file should contain new data.")
+
+	s.write("a/b/c/d/x.lebowski", "abide")
+	s.Require().Equal("abide", s.read(s.fs, "a/b/c/d/x.lebowski"), "Should auto-create parent directories.")
+	info, err := s.fs.Stat("a/b/c")
+	s.Require().NoError(err)
+	s.Require().True(info.IsDir())
+
+	_, err = s.fs.Write("duderino")
+	s.Require().Error(err, "Should not be able to write to a directory")
+
+	_, err = s.fs.Write("1.lebowski/nope.txt")
+	s.Require().Error(err, "Should not be able to write a file inside of another file")
+
+	file, err := s.fs.Write("seek.lebowski")
+	s.Require().NoError(err)
+	_, _ = file.Write([]byte("abcdef"))
+	_, _ = file.Seek(2, io.SeekStart)
+	_, _ = file.Write([]byte("X"))
+	_, _ = file.WriteAt([]byte("YZ"), 8)
+	s.Require().NoError(file.Close())
+	s.Require().Equal("abXdef\x00\x00YZ", s.read(s.fs, "seek.lebowski"))
+
+	reader, err := s.fs.Read("2.lebowski")
+	s.Require().NoError(err)
+	defer reader.Close()
+	writer, err := s.fs.Write("2.lebowski")
+	s.Require().NoError(err)
+	_, _ = writer.Write([]byte("the dude"))
+	s.Require().Equal("", s.read(s.fs, "2.lebowski"), "Unclosed writes should not be visible to new readers.")
+	s.Require().NoError(writer.Close())
+	s.Require().Equal("the dude", s.read(s.fs, "2.lebowski"), "Closed writes should be visible to new readers.")
+	data, err := io.ReadAll(reader)
+	s.Require().NoError(err)
+	s.Require().Equal("walter", string(data), "Existing readers should not see new writes.")
+}
+
+func (s *SQLiteTestSuite) TestList() {
+	files, err := s.fs.List("1.lebowski")
+	s.Require().Error(err, "File list for non-directories should return an error.")
+	s.Require().Equal(0, len(files))
+
+	files, err = s.fs.List("nope")
+	s.Require().NoError(err, "File list for non-existent directories should not return an error.")
+	s.Require().Equal(0, len(files))
+
+	files, err = s.fs.List(".")
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"1.lebowski", "2.lebowski", "3.lebowski", "4.lebowski", "dude/", "duderino/"}, s.names(files))
+
+	files, err = s.fs.List("duderino", filestore.WithPattern("5.*"))
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"5.lebowski"}, s.names(files))
+}
+
+func (s *SQLiteTestSuite) TestRemove() {
+	s.Require().NoError(s.fs.Remove("asldfjslkdfjasdf"), "Removing non-existent file should NOT return an error")
+
+	s.Require().NoError(s.fs.Remove("4.lebowski"))
+	s.Require().False(s.fs.Exists("4.lebowski"))
+
+	s.write("duderino-other/7.lebowski", "bunny")
+	s.Require().NoError(s.fs.Remove("duderino"))
+	s.Require().False(s.fs.Exists("duderino"))
+	s.Require().False(s.fs.Exists("duderino/5.lebowski"))
+	s.Require().True(s.fs.Exists("duderino-other/7.lebowski"), "Should only remove the directory's children")
+
+	s.Require().NoError(s.fs.Remove("."))
+	files, _ := s.fs.List(".")
+	s.Require().Equal(0, len(files), "Removing the root should remove everything.")
+}
+
+func (s *SQLiteTestSuite) TestMove() {
+	s.Require().Error(s.fs.Move("nope.lebowski", "jeff.lebowski"), "Moving non-existent file should fail")
+	s.Require().Error(s.fs.Move("1.lebowski", "dude"), "Moving file onto directory should fail")
+	s.Require().Error(s.fs.Move("duderino", "1.lebowski"), "Moving directory onto file should fail")
+	s.Require().Error(s.fs.Move("duderino", "dude"), "Moving directory onto directory should fail")
+	s.Require().Error(s.fs.Move("duderino", "duderino/inner"), "Moving directory inside itself should fail")
+
+	s.Require().NoError(s.fs.Move("1.lebowski", "2.lebowski"), "Moving file onto another file should overwrite it")
+	s.Require().False(s.fs.Exists("1.lebowski"))
+	s.Require().Equal("jeff", s.read(s.fs, "2.lebowski"))
+
+	s.write("duderino/deep/7.lebowski", "bunny")
+	s.Require().NoError(s.fs.Move("duderino", "dude/a/b/el duderino"), "Moving dir to a new location should work")
+	s.Require().False(s.fs.Exists("duderino"))
+	s.Require().Equal("jackie", s.read(s.fs, "dude/a/b/el duderino/5.lebowski"))
+	s.Require().Equal("nihilist", s.read(s.fs, "dude/a/b/el duderino/6.lebowski"))
+	s.Require().Equal("bunny", s.read(s.fs, "dude/a/b/el duderino/deep/7.lebowski"))
+
+	files, err := s.fs.List("dude/a/b/el duderino")
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"5.lebowski", "6.lebowski", "deep/"}, s.names(files), "Children should move w/ their parent")
+}
+
+func (s *SQLiteTestSuite) TestWithClock() {
+	start := time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC)
+	clock := filestoretest.NewClock(start)
+	fs, err := filestore.SQLite(filepath.Join(s.T().TempDir(), "clock.db"), filestore.WithClock(clock))
+	s.Require().NoError(err)
+	defer fs.Close(context.Background())
+
+	s.Require().NoError(writeString(fs, "a/foo.txt", "foo"))
+	clock.Advance(time.Hour)
+	s.Require().NoError(writeString(fs, "a/bar.txt", "bar"))
+
+	info, err := fs.Stat("a/foo.txt")
+	s.Require().NoError(err)
+	s.Require().True(start.Equal(info.ModTime()))
+
+	files, err := fs.List("a", filestore.WithCreatedAfter(start))
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"bar.txt"}, s.names(files))
+}
+
+func (s *SQLiteTestSuite) TestReopen() {
+	s.Require().NoError(s.fs.Close(context.Background()))
+
+	var err error
+	s.fs, err = filestore.SQLite(s.dbPath)
+	s.Require().NoError(err)
+	s.Require().Equal("jackie", s.read(s.fs, "duderino/5.lebowski"), "Files should persist in the database file")
+	s.Require().True(s.fs.Exists("dude"))
+}
+
+func (s *SQLiteTestSuite) TestOpen() {
+	s.Require().Contains(filestore.Schemes(), "sqlite")
+
+	fs, err := filestore.Open("sqlite://" + s.dbPath)
+	s.Require().NoError(err)
+	defer filestore.Shutdown(context.Background(), fs)
+	s.Require().Equal("walter", s.read(fs, "2.lebowski"))
+	s.Require().NoError(filestore.Ping(context.Background(), fs))
+}
+
+func (s *SQLiteTestSuite) TestConcurrency() {
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := fmt.Sprintf("concurrent/%d/file.txt", i)
+			for j := 0; j < 10; j++ {
+				s.Require().NoError(writeString(s.fs, name, "abide"))
+				_ = s.read(s.fs, name)
+				_, _ = s.fs.List("concurrent")
+				s.Require().NoError(s.fs.Move(name, name+".moved"))
+				s.Require().NoError(s.fs.Remove(name + ".moved"))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	files, err := s.fs.List("concurrent")
+	s.Require().NoError(err)
+	s.Require().Equal(10, len(files))
+}
+
+func (s *SQLiteTestSuite) write(name string, content string) {
+	s.Require().NoError(writeString(s.fs, name, content))
+}
+
+func (s *SQLiteTestSuite) read(fs filestore.FS, name string) string {
+	content, err := readString(fs, name)
+	s.Require().NoError(err)
+	return content
+}
+
+func (s *SQLiteTestSuite) names(files []filestore.FileInfo) []string {
+	var names []string
+	for _, file := range files {
+		if file.IsDir() {
+			names = append(names, file.Name()+"/")
+		} else {
+			names = append(names, file.Name())
+		}
+	}
+	return names
+}
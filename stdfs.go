@@ -0,0 +1,185 @@
+package filestore
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// ToStdFS adapts a file store to the standard library's fs.FS interface, so you can hand a DiskFS,
+// S3FS, etc. to anything that consumes one, such as html/template.ParseFS(), http.FS(), or fs.WalkDir().
+// The result also implements fs.StatFS, fs.ReadDirFS, fs.ReadFileFS, and fs.SubFS, so those functions
+// use the store's Stat() and List() operations directly rather than opening files to get the same info.
+//
+// Names follow the fs.FS rules: they're unrooted, slash-separated paths relative to the store's working
+// directory (e.g. "templates/index.html" or "." for the working directory itself), and names that aren't
+// valid according to fs.ValidPath() (e.g. "../secrets.txt") fail w/ fs.ErrInvalid. Errors are always
+// *fs.PathError values, so errors.Is(err, fs.ErrNotExist) works regardless of the store.
+//
+// Example:
+//
+//	templates, err := template.ParseFS(filestore.ToStdFS(files), "templates/*.html")
+//	...
+//	http.Handle("/assets/", http.FileServer(http.FS(filestore.ToStdFS(assets))))
+func ToStdFS(fsys FS) fs.FS {
+	return stdFS{fs: fsys}
+}
+
+// stdFS is the fs.FS view of one of our stores.
+type stdFS struct {
+	fs FS
+}
+
+// Open opens the named file or directory for reading.
+func (s stdFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := s.fs.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if info.IsDir() {
+		return &stdDir{fs: s.fs, name: name, info: info}, nil
+	}
+	file, err := s.fs.Read(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &stdFile{ReaderFile: file, info: info}, nil
+}
+
+// Stat describes the named file or directory w/o opening it.
+func (s stdFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := s.fs.Stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// ReadDir lists the named directory, sorted by file name.
+func (s stdFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := s.readDir(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	return entries, nil
+}
+
+// readDir lists the directory's contents. Stores typically treat missing directories as empty, but
+// the fs.FS contract requires an error, so we check that the directory actually exists.
+func (s stdFS) readDir(name string) ([]fs.DirEntry, error) {
+	info, err := s.fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("not a directory")
+	}
+	infos, err := s.fs.List(name)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, nil
+}
+
+// ReadFile reads the entire contents of the named file.
+func (s stdFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	file, err := s.fs.Read(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return data, nil
+}
+
+// Sub returns an fs.FS rooted in the named subdirectory using the store's ChangeDirectory().
+func (s stdFS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	return stdFS{fs: s.fs.ChangeDirectory(dir)}, nil
+}
+
+// stdFile is a regular file opened via stdFS.Open().
+type stdFile struct {
+	ReaderFile
+	info FileInfo
+}
+
+func (f *stdFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// stdDir is a directory opened via stdFS.Open(). We don't list its contents until the first call
+// to ReadDir() since plenty of callers only Stat() it.
+type stdDir struct {
+	fs      FS
+	name    string
+	info    FileInfo
+	entries []fs.DirEntry
+	listed  bool
+	offset  int
+}
+
+func (d *stdDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *stdDir) Read(_ []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fmt.Errorf("is a directory")}
+}
+
+func (d *stdDir) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries in the directory, following the rules of fs.ReadDirFile.
+func (d *stdDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := stdFS{fs: d.fs}.readDir(d.name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: err}
+		}
+		d.entries, d.listed = entries, true
+	}
+
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
+
+var _ fs.StatFS = stdFS{}
+var _ fs.ReadDirFS = stdFS{}
+var _ fs.ReadFileFS = stdFS{}
+var _ fs.SubFS = stdFS{}
+var _ fs.ReadDirFile = &stdDir{}
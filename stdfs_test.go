@@ -0,0 +1,75 @@
+package filestore_test
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type StdFSTestSuite struct {
+	suite.Suite
+	files filestore.FS
+	fs    fs.FS
+}
+
+func TestStdFSTestSuite(t *testing.T) {
+	suite.Run(t, &StdFSTestSuite{})
+}
+
+func (s *StdFSTestSuite) SetupTest() {
+	s.files = filestore.Memory()
+	s.Require().NoError(writeString(s.files, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.files, "duderino/5.lebowski", "jackie"))
+	s.Require().NoError(writeString(s.files, "duderino/inner/6.lebowski", "nihilist"))
+	s.Require().NoError(writeString(s.files, "templates/greeting.html", "Hello {{.}}"))
+	s.fs = filestore.ToStdFS(s.files)
+}
+
+// TestFS runs the standard library's conformance tests for fs.FS implementations.
+func (s *StdFSTestSuite) TestFS() {
+	s.Require().NoError(fstest.TestFS(s.fs, "1.lebowski", "duderino/5.lebowski", "duderino/inner/6.lebowski"))
+	s.Require().NoError(fstest.TestFS(filestore.ToStdFS(filestore.Disk("testdata")), "hello.txt", "inner1/inner2/bar.txt"))
+}
+
+func (s *StdFSTestSuite) TestErrors() {
+	_, err := s.fs.Open("nope.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+
+	_, err = s.fs.Open("../1.lebowski")
+	s.Require().True(errors.Is(err, fs.ErrInvalid))
+	_, err = s.fs.Open("/1.lebowski")
+	s.Require().True(errors.Is(err, fs.ErrInvalid))
+
+	_, err = fs.ReadDir(s.fs, "nope")
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Missing directories should be an error")
+	_, err = fs.ReadDir(s.fs, "1.lebowski")
+	s.Require().Error(err)
+
+	var pathErr *fs.PathError
+	_, err = fs.Stat(s.fs, "nope.txt")
+	s.Require().True(errors.As(err, &pathErr))
+	s.Require().Equal("nope.txt", pathErr.Path)
+}
+
+func (s *StdFSTestSuite) TestSub() {
+	sub, err := fs.Sub(s.fs, "duderino")
+	s.Require().NoError(err)
+	data, err := fs.ReadFile(sub, "inner/6.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("nihilist", string(data))
+}
+
+func (s *StdFSTestSuite) TestTemplates() {
+	tmpl, err := template.ParseFS(s.fs, "templates/*.html")
+	s.Require().NoError(err)
+
+	output := &strings.Builder{}
+	s.Require().NoError(tmpl.Execute(output, "Dude"))
+	s.Require().Equal("Hello Dude", output.String())
+}
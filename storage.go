@@ -0,0 +1,49 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+)
+
+// storageClasser is implemented by FileInfo values for stores that support multiple storage tiers
+// (e.g. S3's "STANDARD" vs "GLACIER").
+type storageClasser interface {
+	StorageClass() string
+}
+
+// StorageClass returns the storage class/tier of the file (e.g. "STANDARD", "STANDARD_IA", or "GLACIER"
+// for S3). Stores that don't have storage classes (e.g. DiskFS) return an empty string.
+//
+// Example:
+//
+//	info, err := bucket.Stat("backups/2019.tar.gz")
+//	if filestore.StorageClass(info) == "GLACIER" {
+//	    err = filestore.Restore(bucket, "backups/2019.tar.gz", 7)
+//	}
+func StorageClass(info FileInfo) string {
+	if classer, ok := info.(storageClasser); ok {
+		return classer.StorageClass()
+	}
+	return ""
+}
+
+// Restorer is an optional capability for stores that move files to archival storage tiers where
+// they can't be read until they're temporarily restored (e.g. S3 Glacier).
+type Restorer interface {
+	// Restore makes the archived file readable again for the given number of days.
+	Restore(path string, days int) error
+}
+
+// ErrRestoreNotSupported is the error returned by Restore() when the store does not implement the
+// Restorer capability.
+var ErrRestoreNotSupported = errors.New("filestore: restore not supported")
+
+// Restore makes an archived file readable again for the given number of days if the store supports
+// the Restorer capability. For all other stores, this fails with ErrRestoreNotSupported. Restores
+// typically happen in the background, so the file may not be readable right away.
+func Restore(fs FS, path string, days int) error {
+	if restorer, ok := fs.(Restorer); ok {
+		return restorer.Restore(path, days)
+	}
+	return fmt.Errorf("filestore: restore: %s: %w", path, ErrRestoreNotSupported)
+}
@@ -0,0 +1,101 @@
+package filestore
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// UniqueName generates a random file name based on the given pattern. Much like os.CreateTemp(), the
+// random portion replaces the last "*" in the pattern. If the pattern doesn't contain a "*", the random
+// portion is appended to the end.
+//
+// The random portion comes from crypto/rand by default. Supply the WithRandom() option if you need
+// reproducible names (e.g. golden tests).
+//
+// Example:
+//
+//	filestore.UniqueName("upload-*.png") // "upload-3f9a0c51d2b87e46.png"
+//	filestore.UniqueName("upload")       // "upload3f9a0c51d2b87e46"
+func UniqueName(pattern string, opts ...Option) (string, error) {
+	if strings.ContainsAny(pattern, "/\\") {
+		return "", fmt.Errorf("unique name: pattern contains path separator: %s", pattern)
+	}
+
+	options := newOptions(opts)
+	randomBytes := make([]byte, 8)
+	if _, err := io.ReadFull(options.random, randomBytes); err != nil {
+		return "", fmt.Errorf("unique name: %w", err)
+	}
+	random := hex.EncodeToString(randomBytes)
+
+	if index := strings.LastIndex(pattern, "*"); index >= 0 {
+		return pattern[:index] + random + pattern[index+1:], nil
+	}
+	return pattern + random, nil
+}
+
+// TempFile creates a brand-new file in the given directory of the file system whose name is
+// generated by UniqueName() using the given pattern. It returns the open file as well as the
+// path to the new file (relative to the FS) so that you can read it back later.
+//
+// Claiming the name is atomic when the store supports it: DiskFS creates the file w/ O_EXCL, and stores
+// w/ the ConditionalWriter capability refuse to replace a file that someone else created in the meantime
+// (closing the file fails w/ ErrPreconditionFailed). Other stores can only check whether the name is taken
+// before writing to it.
+//
+// It is the caller's responsibility to close the file and remove it when it is no longer needed.
+//
+// Example:
+//
+//	file, filePath, err := filestore.TempFile(files, "tmp", "upload-*.png")
+//	if err != nil {
+//	    // handle error
+//	}
+//	defer files.Remove(filePath)
+//	defer file.Close()
+func TempFile(fs FS, dir string, pattern string, opts ...Option) (WriterFile, string, error) {
+	// Mirror os.CreateTemp() and give up after a whole bunch of conflicts; something is clearly
+	// wrong with the randomness at that point.
+	for attempt := 0; attempt < 10000; attempt++ {
+		name, err := UniqueName(pattern, opts...)
+		if err != nil {
+			return nil, "", fmt.Errorf("temp file: %w", err)
+		}
+
+		filePath := path.Join(dir, name)
+		file, err := createNew(fs, filePath)
+		switch {
+		case errors.Is(err, os.ErrExist) || errors.Is(err, ErrPreconditionFailed):
+			continue
+		case err != nil:
+			return nil, "", fmt.Errorf("temp file: %w", err)
+		}
+		return file, filePath, nil
+	}
+	return nil, "", fmt.Errorf("temp file: unable to find unused name for pattern %s in %s", pattern, dir)
+}
+
+// exclusiveCreator is implemented by stores that can atomically create a file only if it doesn't exist yet.
+type exclusiveCreator interface {
+	createExclusive(filePath string) (WriterFile, error)
+}
+
+// createNew opens a file that must not exist yet, failing w/ os.ErrExist (or ErrPreconditionFailed) when
+// it does. It uses the most reliable way that the store supports to make sure nobody else claims it first.
+func createNew(fs FS, filePath string) (WriterFile, error) {
+	switch creator := fs.(type) {
+	case exclusiveCreator:
+		return creator.createExclusive(filePath)
+	case ConditionalWriter:
+		return creator.WriteIfUnchanged(filePath, "")
+	}
+	if fs.Exists(filePath) {
+		return nil, os.ErrExist
+	}
+	return fs.Write(filePath)
+}
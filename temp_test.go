@@ -0,0 +1,125 @@
+package filestore_test
+
+import (
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type TempTestSuite struct {
+	suite.Suite
+}
+
+func TestTempTestSuite(t *testing.T) {
+	suite.Run(t, &TempTestSuite{})
+}
+
+func (s *TempTestSuite) TestUniqueName() {
+	name, err := filestore.UniqueName("upload-*.png")
+	s.Require().NoError(err)
+	s.Require().True(strings.HasPrefix(name, "upload-"), "Should keep text before '*'")
+	s.Require().True(strings.HasSuffix(name, ".png"), "Should keep text after '*'")
+	s.Require().Equal(len("upload-.png")+16, len(name))
+
+	name, err = filestore.UniqueName("upload")
+	s.Require().NoError(err)
+	s.Require().True(strings.HasPrefix(name, "upload"), "Should append random text when no '*' present")
+	s.Require().Equal(len("upload")+16, len(name))
+
+	name, err = filestore.UniqueName("*-*.txt")
+	s.Require().NoError(err)
+	s.Require().True(strings.HasPrefix(name, "*-"), "Should only replace the last '*'")
+
+	other, err := filestore.UniqueName("*-*.txt")
+	s.Require().NoError(err)
+	s.Require().NotEqual(name, other, "Random names should not repeat")
+
+	_, err = filestore.UniqueName("foo/*.txt")
+	s.Require().Error(err, "Should not allow path separators in pattern")
+}
+
+func (s *TempTestSuite) TestUniqueName_seeded() {
+	random1 := rand.New(rand.NewSource(42))
+	random2 := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 5; i++ {
+		name1, err := filestore.UniqueName("golden-*.json", filestore.WithRandom(random1))
+		s.Require().NoError(err)
+		name2, err := filestore.UniqueName("golden-*.json", filestore.WithRandom(random2))
+		s.Require().NoError(err)
+		s.Require().Equal(name1, name2, "Same seed should produce same sequence of names")
+	}
+}
+
+func (s *TempTestSuite) TestUniqueName_randomFailure() {
+	_, err := filestore.UniqueName("foo-*", filestore.WithRandom(strings.NewReader("abc")))
+	s.Require().Error(err, "Should fail if random source runs out of data")
+}
+
+func (s *TempTestSuite) TestTempFile() {
+	fs := filestore.Memory()
+
+	file, filePath, err := filestore.TempFile(fs, "tmp/uploads", "upload-*.png")
+	s.Require().NoError(err)
+	_, _ = file.Write([]byte("abide"))
+	s.Require().NoError(file.Close())
+	s.Require().True(strings.HasPrefix(filePath, "tmp/uploads/upload-"))
+	s.Require().True(strings.HasSuffix(filePath, ".png"))
+
+	reader, err := fs.Read(filePath)
+	s.Require().NoError(err)
+	data, _ := io.ReadAll(reader)
+	s.Require().Equal("abide", string(data))
+}
+
+// Using the same seed twice in a row forces a collision, so we should skip past the existing name.
+func (s *TempTestSuite) TestTempFile_collision() {
+	fs := filestore.Memory()
+
+	file, path1, err := filestore.TempFile(fs, "tmp", "*.txt", filestore.WithRandom(rand.New(rand.NewSource(1))))
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+
+	file, path2, err := filestore.TempFile(fs, "tmp", "*.txt", filestore.WithRandom(rand.New(rand.NewSource(1))))
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+
+	s.Require().NotEqual(path1, path2, "Should not reuse the name of an existing file")
+	files, _ := fs.List("tmp")
+	s.Require().Equal(2, len(files))
+}
+
+func (s *TempTestSuite) TestTempFile_collisionDisk() {
+	fs := filestore.Disk(s.T().TempDir())
+
+	file, path1, err := filestore.TempFile(fs, "tmp", "*.txt", filestore.WithRandom(rand.New(rand.NewSource(1))))
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+
+	file, path2, err := filestore.TempFile(fs, "tmp", "*.txt", filestore.WithRandom(rand.New(rand.NewSource(1))))
+	s.Require().NoError(err)
+	s.Require().NoError(file.Close())
+
+	s.Require().NotEqual(path1, path2, "Should not reuse the name of an existing file")
+	files, _ := fs.List("tmp")
+	s.Require().Equal(2, len(files))
+}
+
+// Someone claiming the same name while our temp file is still open must not get clobbered when we close it.
+func (s *TempTestSuite) TestTempFile_claimedWhileOpen() {
+	fs := filestore.Memory()
+
+	file, filePath, err := filestore.TempFile(fs, "tmp", "*.txt")
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(fs, filePath, "walter"))
+
+	_, _ = file.Write([]byte("donny"))
+	s.Require().ErrorIs(file.Close(), filestore.ErrPreconditionFailed)
+	data, err := readString(fs, filePath)
+	s.Require().NoError(err)
+	s.Require().Equal("walter", data)
+}
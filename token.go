@@ -0,0 +1,92 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ConditionalWriter is an optional capability for stores that can make changes only when a file hasn't
+// changed since you last looked at it (optimistic concurrency). See ChangeToken().
+type ConditionalWriter interface {
+	// WriteIfUnchanged opens the file for writing just like Write(), but the new contents only replace the
+	// file when you close it if the file's change token still matches the given one. An empty token means
+	// that the file must not exist yet.
+	WriteIfUnchanged(path string, token string) (WriterFile, error)
+	// MoveIfUnchanged relocates the file/directory just like Move(), but only if the change token of
+	// whatever is at the destination still matches the given one. An empty token means that nothing may
+	// exist at the destination yet.
+	MoveIfUnchanged(fromPath string, toPath string, token string) error
+}
+
+// ErrPreconditionFailed is the error returned by WriteIfUnchanged() and MoveIfUnchanged() when the file's
+// change token no longer matches the one you gave them, i.e. someone else changed it in the meantime.
+var ErrPreconditionFailed = errors.New("filestore: file was changed by someone else")
+
+// ErrConditionalWriteNotSupported is the error returned by WriteIfUnchanged() and MoveIfUnchanged() when
+// the store does not implement the ConditionalWriter capability.
+var ErrConditionalWriteNotSupported = errors.New("filestore: conditional writes not supported")
+
+// changeTokener is implemented by FileInfo values for stores that keep track of their own change tokens.
+type changeTokener interface {
+	ChangeToken() string
+}
+
+// ChangeToken returns an opaque value that changes whenever the file does, so that you can detect when
+// someone else changed it after you read it. Pass it to WriteIfUnchanged() or MoveIfUnchanged() to save your
+// changes only if nobody beat you to it. Stores w/ entity tags (e.g. S3) use those. For everything else,
+// including DiskFS, the token is made up of the file's modification time, size, and inode (when the
+// platform has them), so it's only as precise as the file system's timestamps. It returns an empty string
+// for a nil FileInfo, which is also the token WriteIfUnchanged() uses for a file that doesn't exist.
+//
+// Example:
+//
+//	info, err := files.Stat("docs/readme.md")
+//	...
+//	token := filestore.ChangeToken(info)
+//	// ... let the user edit the document ...
+//	file, err := filestore.WriteIfUnchanged(files, "docs/readme.md", token)
+//	...
+//	if err = file.Close(); errors.Is(err, filestore.ErrPreconditionFailed) {
+//	    // Someone else saved their changes first.
+//	}
+func ChangeToken(info FileInfo) string {
+	if info == nil {
+		return ""
+	}
+	if tokener, ok := info.(changeTokener); ok {
+		return tokener.ChangeToken()
+	}
+	if etag := ETag(info); etag != "" {
+		return etag
+	}
+	token := fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+	if id, _, ok := sysFileID(info.Sys()); ok {
+		token += fmt.Sprintf("-%x-%x", id.dev, id.ino)
+	}
+	return token
+}
+
+// WriteIfUnchanged opens the file for writing, provided that the store supports the ConditionalWriter
+// capability. Your changes only replace the file when you close it if its change token (see ChangeToken)
+// still matches the given one; otherwise, opening or closing the file fails w/ ErrPreconditionFailed and
+// the file is left alone. An empty token means that the file must not exist yet. For all other stores,
+// this fails with ErrConditionalWriteNotSupported.
+func WriteIfUnchanged(fs FS, path string, token string) (WriterFile, error) {
+	if writer, ok := fs.(ConditionalWriter); ok {
+		return writer.WriteIfUnchanged(path, token)
+	}
+	return nil, fmt.Errorf("filestore: write if unchanged: %s: %w", path, ErrConditionalWriteNotSupported)
+}
+
+// MoveIfUnchanged relocates the file/directory only if the change token (see ChangeToken) of whatever is at
+// the destination still matches the given one, failing w/ ErrPreconditionFailed otherwise. This lets you
+// write your changes to a temporary file and then move it over the original only if nobody else changed
+// the original in the meantime. An empty token means that nothing may exist at the destination yet. For
+// stores that don't support the ConditionalWriter capability, this fails with
+// ErrConditionalWriteNotSupported.
+func MoveIfUnchanged(fs FS, fromPath string, toPath string, token string) error {
+	if writer, ok := fs.(ConditionalWriter); ok {
+		return writer.MoveIfUnchanged(fromPath, toPath, token)
+	}
+	return fmt.Errorf("filestore: move if unchanged: %s: %w", fromPath, ErrConditionalWriteNotSupported)
+}
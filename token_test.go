@@ -0,0 +1,132 @@
+package filestore_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ChangeTokenTestSuite struct {
+	suite.Suite
+	stores map[string]filestore.FS
+}
+
+func TestChangeTokenTestSuite(t *testing.T) {
+	suite.Run(t, &ChangeTokenTestSuite{})
+}
+
+func (s *ChangeTokenTestSuite) SetupTest() {
+	s.stores = map[string]filestore.FS{
+		"disk":   filestore.Disk(s.T().TempDir()),
+		"memory": filestore.Memory(),
+	}
+	for _, fs := range s.stores {
+		s.Require().NoError(writeString(fs, "docs/readme.md", "the dude abides"))
+	}
+}
+
+func (s *ChangeTokenTestSuite) TestChangeToken() {
+	s.Require().Equal("", filestore.ChangeToken(nil))
+	for name, fs := range s.stores {
+		before := s.token(fs, "docs/readme.md")
+		s.Require().NotEmpty(before, name)
+		s.Require().Equal(before, s.token(fs, "docs/readme.md"), "%s: Should not change on its own", name)
+
+		s.Require().NoError(writeString(fs, "docs/readme.md", "the dude does not abide"))
+		s.Require().NotEqual(before, s.token(fs, "docs/readme.md"), "%s: Should change when the file does", name)
+	}
+}
+
+func (s *ChangeTokenTestSuite) TestWriteIfUnchanged() {
+	for name, fs := range s.stores {
+		token := s.token(fs, "docs/readme.md")
+		file, err := filestore.WriteIfUnchanged(fs.ChangeDirectory("docs"), "readme.md", token)
+		s.Require().NoError(err, name)
+		_, err = file.Write([]byte("mine"))
+		s.Require().NoError(err, name)
+		s.assertContent(fs, "docs/readme.md", "the dude abides", "%s: Should not replace the file until it's closed", name)
+		s.Require().NoError(file.Close(), name)
+		s.assertContent(fs, "docs/readme.md", "mine", name)
+
+		// The token we used is stale now.
+		_, err = filestore.WriteIfUnchanged(fs, "docs/readme.md", token)
+		s.Require().True(errors.Is(err, filestore.ErrPreconditionFailed), name)
+
+		// Nobody else has this file yet, so nobody else can have changed it.
+		s.Require().NoError(s.writeIfUnchanged(fs, "docs/new.md", "", "new"), name)
+		s.assertContent(fs, "docs/new.md", "new", name)
+		err = s.writeIfUnchanged(fs, "docs/new.md", "", "newer")
+		s.Require().True(errors.Is(err, filestore.ErrPreconditionFailed), "%s: Should not replace a file that exists", name)
+	}
+}
+
+func (s *ChangeTokenTestSuite) TestWriteIfUnchanged_concurrentChange() {
+	for name, fs := range s.stores {
+		file, err := filestore.WriteIfUnchanged(fs, "docs/readme.md", s.token(fs, "docs/readme.md"))
+		s.Require().NoError(err, name)
+		_, err = file.Write([]byte("mine"))
+		s.Require().NoError(err, name)
+
+		s.Require().NoError(writeString(fs, "docs/readme.md", "theirs"))
+		err = file.Close()
+		s.Require().True(errors.Is(err, filestore.ErrPreconditionFailed), name)
+		s.assertContent(fs, "docs/readme.md", "theirs", "%s: Should keep the other change", name)
+
+		infos, err := fs.List("docs")
+		s.Require().NoError(err, name)
+		s.Require().Len(infos, 1, "%s: Should not leave temp files behind", name)
+	}
+}
+
+func (s *ChangeTokenTestSuite) TestMoveIfUnchanged() {
+	for name, fs := range s.stores {
+		token := s.token(fs, "docs/readme.md")
+		s.Require().NoError(writeString(fs, "docs/readme.md.tmp", "mine"))
+		s.Require().NoError(filestore.MoveIfUnchanged(fs, "docs/readme.md.tmp", "docs/readme.md", token), name)
+		s.assertContent(fs, "docs/readme.md", "mine", name)
+
+		s.Require().NoError(writeString(fs, "docs/readme.md.tmp", "mine again"))
+		err := filestore.MoveIfUnchanged(fs, "docs/readme.md.tmp", "docs/readme.md", token)
+		s.Require().True(errors.Is(err, filestore.ErrPreconditionFailed), name)
+		s.assertContent(fs, "docs/readme.md", "mine", name)
+		s.Require().True(fs.Exists("docs/readme.md.tmp"), name)
+
+		err = filestore.MoveIfUnchanged(fs, "docs/readme.md.tmp", "docs/readme.md", "")
+		s.Require().True(errors.Is(err, filestore.ErrPreconditionFailed), name)
+		s.Require().NoError(filestore.MoveIfUnchanged(fs, "docs/readme.md.tmp", "docs/other.md", ""), name)
+		s.assertContent(fs, "docs/other.md", "mine again", name)
+	}
+}
+
+func (s *ChangeTokenTestSuite) TestNotSupported() {
+	fs := struct{ filestore.FS }{filestore.Memory()}
+	_, err := filestore.WriteIfUnchanged(fs, "docs/readme.md", "")
+	s.Require().True(errors.Is(err, filestore.ErrConditionalWriteNotSupported))
+	err = filestore.MoveIfUnchanged(fs, "docs/readme.md", "docs/other.md", "")
+	s.Require().True(errors.Is(err, filestore.ErrConditionalWriteNotSupported))
+}
+
+func (s *ChangeTokenTestSuite) token(fs filestore.FS, filePath string) string {
+	info, err := fs.Stat(filePath)
+	s.Require().NoError(err)
+	return filestore.ChangeToken(info)
+}
+
+func (s *ChangeTokenTestSuite) writeIfUnchanged(fs filestore.FS, filePath string, token string, content string) error {
+	file, err := filestore.WriteIfUnchanged(fs, filePath, token)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write([]byte(content)); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func (s *ChangeTokenTestSuite) assertContent(fs filestore.FS, filePath string, expected string, msgAndArgs ...any) {
+	content, err := readString(fs, filePath)
+	s.Require().NoError(err, msgAndArgs...)
+	s.Require().Equal(expected, content, msgAndArgs...)
+}
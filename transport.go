@@ -0,0 +1,122 @@
+package filestore
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+)
+
+// transportOptions contains the proxy/TLS settings for stores that talk to remote services.
+type transportOptions struct {
+	proxy       *url.URL
+	tlsConfig   *tls.Config
+	rootCAs     *x509.CertPool
+	clientCerts []tls.Certificate
+}
+
+// WithProxy sends the requests of stores that talk to remote services over HTTP (e.g. S3) through the
+// given proxy (e.g. "http://proxy.internal:3128"). By default, we use the proxy from the HTTPS_PROXY,
+// HTTP_PROXY, and NO_PROXY environment variables, if any.
+//
+// Like the rest of the proxy/TLS options, this only applies when the HTTP client's transport is an
+// *http.Transport (which it is unless you supply your own w/ WithHTTPClient()). We configure a copy of
+// it, so the client you supplied is left alone.
+func WithProxy(proxy *url.URL) Option {
+	return func(opts *options) {
+		opts.transport.proxy = proxy
+	}
+}
+
+// WithRootCAs sets the certificate authorities that remote stores use to verify the servers they connect
+// to, e.g. so they can trust a TLS-intercepting proxy or an on-prem MinIO w/ an internal CA. This replaces
+// the system's pool rather than adding to it, so start from x509.SystemCertPool() if you still need to
+// trust public servers as well.
+//
+// Example:
+//
+//	pool, _ := x509.SystemCertPool()
+//	bundle, err := os.ReadFile("/etc/ssl/corp-ca.pem")
+//	...
+//	pool.AppendCertsFromPEM(bundle)
+//	files := filestore.S3("my-bucket", filestore.WithRootCAs(pool))
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(opts *options) {
+		opts.transport.rootCAs = pool
+	}
+}
+
+// WithClientCertificate makes remote stores present the given certificate to servers (or proxies) that
+// require mutual TLS. You can supply this more than once to offer several certificates.
+//
+// Example:
+//
+//	cert, err := tls.LoadX509KeyPair("client.crt", "client.key")
+//	...
+//	files := filestore.S3("my-bucket", filestore.WithClientCertificate(cert))
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(opts *options) {
+		opts.transport.clientCerts = append(opts.transport.clientCerts, cert)
+	}
+}
+
+// WithTLSConfig sets the TLS settings that remote stores use to connect to their servers, for anything
+// that WithRootCAs() and WithClientCertificate() don't cover (minimum versions, server names, etc.). Those
+// options are applied on top of a copy of this config.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(opts *options) {
+		opts.transport.tlsConfig = config
+	}
+}
+
+// configured returns true when any of the proxy/TLS options were supplied.
+func (opts transportOptions) configured() bool {
+	return opts.proxy != nil || opts.tlsConfig != nil || opts.rootCAs != nil || len(opts.clientCerts) > 0
+}
+
+// tlsClientConfig builds the TLS config described by the options on top of a copy of the given one (which
+// may be nil). It returns the base config untouched if none of the TLS options were supplied.
+func (opts transportOptions) tlsClientConfig(base *tls.Config) *tls.Config {
+	if opts.tlsConfig == nil && opts.rootCAs == nil && len(opts.clientCerts) == 0 {
+		return base
+	}
+	if opts.tlsConfig != nil {
+		base = opts.tlsConfig
+	}
+	config := &tls.Config{}
+	if base != nil {
+		config = base.Clone()
+	}
+	if opts.rootCAs != nil {
+		config.RootCAs = opts.rootCAs
+	}
+	config.Certificates = append(config.Certificates, opts.clientCerts...)
+	return config
+}
+
+// client returns the HTTP client that remote stores should use: the one from WithHTTPClient() (or
+// http.DefaultClient), w/ a copy of its transport configured w/ the proxy/TLS options.
+func (opts options) client() *http.Client {
+	if !opts.transport.configured() {
+		return opts.httpClient
+	}
+
+	var transport *http.Transport
+	switch base := opts.httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = base.Clone()
+	default:
+		// We have no idea how to configure a custom round tripper, so it's up to you.
+		return opts.httpClient
+	}
+
+	if opts.transport.proxy != nil {
+		transport.Proxy = http.ProxyURL(opts.transport.proxy)
+	}
+	transport.TLSClientConfig = opts.transport.tlsClientConfig(transport.TLSClientConfig)
+	client := *opts.httpClient
+	client.Transport = transport
+	return &client
+}
@@ -0,0 +1,158 @@
+package filestore_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type TransportTestSuite struct {
+	suite.Suite
+	server *filestoretest.S3Server
+}
+
+func TestTransportTestSuite(t *testing.T) {
+	suite.Run(t, &TransportTestSuite{})
+}
+
+func (s *TransportTestSuite) SetupTest() {
+	s.server = filestoretest.NewS3Server("lebowski")
+	s.server.PutObject("lebowski", "1.lebowski", []byte("jeff"))
+}
+
+func (s *TransportTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *TransportTestSuite) TestRootCAs() {
+	secure := httptest.NewUnstartedServer(s.server.Config.Handler)
+	secure.Config.ErrorLog = log.New(io.Discard, "", 0)
+	secure.StartTLS()
+	defer secure.Close()
+
+	fs := s.newFS(secure.URL)
+	_, err := fs.Stat("1.lebowski")
+	s.Require().Error(err, "Should not trust the server's certificate by default")
+
+	fs = s.newFS(secure.URL, filestore.WithRootCAs(s.pool(secure)))
+	content, err := readString(fs, "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jeff", content)
+}
+
+func (s *TransportTestSuite) TestClientCertificate() {
+	secure := httptest.NewUnstartedServer(s.server.Config.Handler)
+	secure.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	secure.Config.ErrorLog = log.New(io.Discard, "", 0)
+	secure.StartTLS()
+	defer secure.Close()
+
+	fs := s.newFS(secure.URL, filestore.WithRootCAs(s.pool(secure)))
+	_, err := fs.Stat("1.lebowski")
+	s.Require().Error(err, "Should not get in w/o a client certificate")
+
+	// The server's own certificate is as good a client certificate as any.
+	fs = s.newFS(secure.URL,
+		filestore.WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}),
+		filestore.WithRootCAs(s.pool(secure)),
+		filestore.WithClientCertificate(secure.TLS.Certificates[0]),
+	)
+	_, err = fs.Stat("1.lebowski")
+	s.Require().NoError(err)
+}
+
+func (s *TransportTestSuite) TestProxy() {
+	var mutex sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mutex.Lock()
+		proxied = append(proxied, req.URL.String())
+		mutex.Unlock()
+		s.server.Config.Handler.ServeHTTP(w, req)
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := &http.Client{Transport: &http.Transport{}}
+	fs := s.newFS("http://s3.internal", filestore.WithHTTPClient(client), filestore.WithProxy(proxyURL))
+	content, err := readString(fs, "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jeff", content)
+	s.Require().Contains(proxied, "http://s3.internal/lebowski/1.lebowski")
+	s.Require().Nil(client.Transport.(*http.Transport).Proxy, "Should not modify the client you supplied")
+
+	assets := filestore.HTTP("http://cdn.internal/lebowski", filestore.WithProxy(proxyURL))
+	_, err = assets.Stat("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Contains(proxied, "http://cdn.internal/lebowski/1.lebowski", "Should apply to every store that uses HTTP")
+}
+
+func (s *TransportTestSuite) TestRedisTLS() {
+	redis := filestoretest.NewRedisServer()
+	defer redis.Close()
+
+	// Terminate TLS in front of the fake server, which only speaks plain TCP.
+	secure := httptest.NewUnstartedServer(nil)
+	secure.StartTLS()
+	secure.Close()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: secure.TLS.Certificates})
+	s.Require().NoError(err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			backend, err := net.Dial("tcp", redis.Addr)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			go func() { _, _ = io.Copy(backend, conn); backend.Close() }()
+			go func() { _, _ = io.Copy(conn, backend); conn.Close() }()
+		}
+	}()
+
+	plain := filestore.Redis(listener.Addr().String())
+	defer plain.Close(context.Background())
+	s.Require().Error(plain.Ping(context.Background()), "Should not speak plain TCP to a TLS server")
+
+	opened, err := filestore.Open("rediss://" + listener.Addr().String())
+	s.Require().NoError(err)
+	defer filestore.Shutdown(context.Background(), opened)
+	err = filestore.Ping(context.Background(), opened)
+	s.Require().ErrorContains(err, "certificate", "Should connect over TLS but not trust the certificate")
+
+	fs := filestore.Redis(listener.Addr().String(), filestore.WithRedisTLS(), filestore.WithRootCAs(s.pool(secure)))
+	defer fs.Close(context.Background())
+	s.Require().NoError(writeString(fs, "a.txt", "abide"))
+	s.Require().Equal([]string{"filestore:/a.txt"}, redis.Keys(0))
+}
+
+func (s *TransportTestSuite) newFS(endpoint string, opts ...filestore.Option) filestore.FS {
+	opts = append([]filestore.Option{
+		filestore.WithEndpoint(endpoint),
+		filestore.WithRegion("us-east-1"),
+		filestore.WithCredentials("AKID", "SECRET", ""),
+	}, opts...)
+	return filestore.S3("lebowski", opts...)
+}
+
+func (s *TransportTestSuite) pool(server *httptest.Server) *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	return pool
+}
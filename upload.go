@@ -0,0 +1,245 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// uploadsDir is the directory, relative to the store's working directory, that contains the staging
+// area for every upload session.
+const uploadsDir = ".uploads"
+
+// UploadSession is a staging area for files that are uploaded over the course of several requests
+// (e.g. chunked browser uploads). The session itself is an FS, so you write the files into it just like
+// any other store. Nothing shows up in its final location until you Commit() the session, and sessions
+// that are never committed are cleaned up by CollectUploads().
+type UploadSession struct {
+	// FS is the session's staging area.
+	FS
+	store FS
+	token string
+}
+
+// StartUpload begins a new upload session in the given store. The session's files are staged in a
+// ".uploads" directory in the store's working directory, so committing them only requires a Move()
+// within the same store. Hand the session's Token() to the client so that subsequent requests can
+// continue the same upload using ResumeUpload().
+//
+// The token comes from UniqueName(), so you can supply the WithRandom() option for reproducible tokens.
+//
+// Example:
+//
+//	session, err := filestore.StartUpload(files)
+//	...
+//	file, err := session.Write("photos/beach.jpg")
+//	...
+//	err = session.Commit("albums/vacation") // creates "albums/vacation/photos/beach.jpg"
+func StartUpload(fsys FS, opts ...Option) (*UploadSession, error) {
+	token, err := UniqueName("", opts...)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: start upload: %w", err)
+	}
+	session := newUploadSession(fsys, token)
+	if err := session.touch(); err != nil {
+		return nil, fmt.Errorf("filestore: start upload: %w", err)
+	}
+	return session, nil
+}
+
+// ResumeUpload continues the upload session w/ the given token, which must have been started using
+// StartUpload() and not yet committed, aborted, or collected. Resuming a session counts as activity,
+// so it won't be collected for another full TTL.
+func ResumeUpload(fsys FS, token string) (*UploadSession, error) {
+	if token == "" || token == "." || token == ".." || strings.ContainsAny(token, "/\\") {
+		return nil, fmt.Errorf("filestore: resume upload: invalid token: %q", token)
+	}
+	session := newUploadSession(fsys, token)
+	if !fsys.Exists(session.markerPath()) {
+		return nil, fmt.Errorf("filestore: resume upload: %s: %w", token, fs.ErrNotExist)
+	}
+	if err := session.touch(); err != nil {
+		return nil, fmt.Errorf("filestore: resume upload: %w", err)
+	}
+	return session, nil
+}
+
+func newUploadSession(store FS, token string) *UploadSession {
+	return &UploadSession{
+		FS:    store.ChangeDirectory(path.Join(uploadsDir, token, "files")),
+		store: store,
+		token: token,
+	}
+}
+
+// Token identifies the session so that you can resume it using ResumeUpload().
+func (u *UploadSession) Token() string {
+	return u.token
+}
+
+// dir is the path of the session's directory relative to the store's working directory.
+func (u *UploadSession) dir() string {
+	return path.Join(uploadsDir, u.token)
+}
+
+// markerPath is the file that tells us that the session exists; its modification time is the last
+// time the session was started/resumed.
+func (u *UploadSession) markerPath() string {
+	return path.Join(u.dir(), "session")
+}
+
+func (u *UploadSession) touch() error {
+	file, err := u.store.Write(u.markerPath())
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Commit moves every file in the staging area to the same relative path within the given destination
+// directory of the store and then removes the session. When the destination doesn't exist yet, we promote
+// the entire staging area w/ a single Move(), so on stores w/ atomic renames (e.g. DiskFS), readers see
+// either none of the files or all of them. Otherwise, we move the files one at a time.
+//
+// Either way, the commit is all-or-nothing: if any move fails, we move the files that already made it back
+// into the staging area (restoring any files they replaced), so you can retry the commit.
+func (u *UploadSession) Commit(dest string) error {
+	filesDir := path.Join(u.dir(), "files")
+	var staged []string
+	err := Walk(u.store, filesDir, func(filePath string, info FileInfo, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && filePath == filesDir {
+			return nil // nothing was uploaded
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			staged = append(staged, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("filestore: commit upload: %s: %w", u.token, err)
+	}
+
+	if err = u.promote(filesDir, dest, staged); err != nil {
+		return fmt.Errorf("filestore: commit upload: %s: %w", u.token, err)
+	}
+	return u.Abort()
+}
+
+// uploadMove is a single file that promote() moved, so that we can undo it.
+type uploadMove struct {
+	staged string
+	target string
+	// replaced is where we set aside the file that used to be at the target, if there was one.
+	replaced string
+}
+
+// promote moves the staged files into the destination directory, undoing every move if any of them fail.
+func (u *UploadSession) promote(filesDir string, dest string, staged []string) error {
+	moves := make([]uploadMove, len(staged))
+	for i, filePath := range staged {
+		relativePath := strings.TrimPrefix(filePath, filesDir+"/")
+		moves[i] = uploadMove{staged: filePath, target: path.Join(dest, relativePath)}
+	}
+
+	if len(staged) > 0 && !u.store.Exists(dest) {
+		err := u.store.Move(filesDir, dest)
+		if err != nil {
+			u.rollback(moves)
+		}
+		return err
+	}
+
+	for i, move := range moves {
+		if u.store.Exists(move.target) {
+			moves[i].replaced = path.Join(u.dir(), "replaced", strings.TrimPrefix(move.staged, filesDir+"/"))
+			if err := u.store.Move(move.target, moves[i].replaced); err != nil {
+				u.rollback(moves[:i])
+				return err
+			}
+		}
+		if err := u.store.Move(move.staged, move.target); err != nil {
+			u.rollback(moves[:i+1])
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback moves files that made it to their targets back into the staging area and restores the files
+// that they replaced. This is best-effort; we've already failed, and we report that failure instead.
+func (u *UploadSession) rollback(moves []uploadMove) {
+	for _, move := range moves {
+		if !u.store.Exists(move.staged) && u.store.Exists(move.target) {
+			_ = u.store.Move(move.target, move.staged)
+		}
+		if move.replaced != "" && u.store.Exists(move.replaced) {
+			_ = u.store.Move(move.replaced, move.target)
+		}
+	}
+}
+
+// Abort discards the session and everything that was uploaded to it.
+func (u *UploadSession) Abort() error {
+	if err := u.store.Remove(u.dir()); err != nil {
+		return fmt.Errorf("filestore: abort upload: %s: %w", u.token, err)
+	}
+	return nil
+}
+
+// CollectUploads removes every upload session in the store that has been abandoned, meaning that
+// it hasn't been resumed and none of its files have been modified in the last 'ttl'. It returns the
+// number of sessions it removed. Call this periodically (e.g. from a time.Ticker) to keep incomplete
+// uploads from piling up. You can supply the WithClock() option to control the current time.
+//
+// Example:
+//
+//	// Clean up uploads that nobody has touched in a day.
+//	removed, err := filestore.CollectUploads(files, 24*time.Hour)
+func CollectUploads(fsys FS, ttl time.Duration, opts ...Option) (int, error) {
+	now := newOptions(opts).clock.Now()
+	sessions, err := fsys.List(uploadsDir)
+	if err != nil {
+		return 0, fmt.Errorf("filestore: collect uploads: %w", err)
+	}
+
+	removed := 0
+	for _, session := range sessions {
+		if !session.IsDir() {
+			continue
+		}
+		sessionDir := path.Join(uploadsDir, session.Name())
+		lastActive, err := lastModified(fsys, sessionDir)
+		if err != nil {
+			return removed, fmt.Errorf("filestore: collect uploads: %w", err)
+		}
+		if now.Sub(lastActive) < ttl {
+			continue
+		}
+		if err := fsys.Remove(sessionDir); err != nil {
+			return removed, fmt.Errorf("filestore: collect uploads: %w", err)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// lastModified returns the most recent modification time of any file in the directory.
+func lastModified(fsys FS, dir string) (time.Time, error) {
+	var latest time.Time
+	err := Walk(fsys, dir, func(_ string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest, err
+}
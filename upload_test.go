@@ -0,0 +1,236 @@
+package filestore_test
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type UploadTestSuite struct {
+	suite.Suite
+	clock *filestoretest.Clock
+	fs    filestore.FS
+}
+
+func TestUploadTestSuite(t *testing.T) {
+	suite.Run(t, &UploadTestSuite{})
+}
+
+func (s *UploadTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.fs = filestore.Memory(filestore.WithClock(s.clock))
+}
+
+func (s *UploadTestSuite) TestCommit() {
+	session, err := filestore.StartUpload(s.fs)
+	s.Require().NoError(err)
+	s.Require().NotEmpty(session.Token())
+	s.Require().NoError(writeString(session, "1.lebowski", "jeff"))
+
+	resumed, err := filestore.ResumeUpload(s.fs, session.Token())
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(resumed, "duderino/5.lebowski", "jackie"))
+	s.Require().True(resumed.Exists("1.lebowski"), "Resumed session should see earlier uploads")
+	s.Require().False(s.fs.Exists("albums"), "Nothing should be visible before committing")
+
+	s.Require().NoError(resumed.Commit("albums"))
+	s.Require().Equal("jeff", s.read("albums/1.lebowski"))
+	s.Require().Equal("jackie", s.read("albums/duderino/5.lebowski"))
+
+	_, err = filestore.ResumeUpload(s.fs, session.Token())
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Committed sessions should be gone")
+	infos, err := s.fs.List(".uploads")
+	s.Require().NoError(err)
+	s.Require().Empty(infos)
+}
+
+func (s *UploadTestSuite) TestCommit_empty() {
+	session, err := filestore.StartUpload(s.fs)
+	s.Require().NoError(err)
+	s.Require().NoError(session.Commit("albums"))
+	s.Require().False(s.fs.Exists("albums"))
+}
+
+func (s *UploadTestSuite) TestCommit_failure() {
+	errDiskFull := errors.New("disk full")
+	files := filestoretest.NewFaultFS(s.fs)
+	s.Require().NoError(writeString(files, "albums/2.lebowski", "walter"))
+
+	session, err := filestore.StartUpload(files)
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(session, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(session, "2.lebowski", "donny"))
+	s.Require().NoError(writeString(session, "3.lebowski", "maude"))
+
+	// The first two files make it and then the last one fails, so we should undo the first two.
+	files.FailPath("move", ".uploads/"+session.Token()+"/files/3.lebowski", errDiskFull)
+	s.Require().True(errors.Is(session.Commit("albums"), errDiskFull))
+	s.Require().False(s.fs.Exists("albums/1.lebowski"))
+	s.Require().Equal("walter", s.read("albums/2.lebowski"), "Should restore the file we replaced")
+	s.Require().False(s.fs.Exists("albums/3.lebowski"))
+	s.Require().Equal("donny", s.read(".uploads/"+session.Token()+"/files/2.lebowski"))
+
+	files.FailPath("move", ".uploads/"+session.Token()+"/files/3.lebowski", nil)
+	s.Require().NoError(session.Commit("albums"))
+	s.Require().Equal("jeff", s.read("albums/1.lebowski"))
+	s.Require().Equal("donny", s.read("albums/2.lebowski"))
+	s.Require().Equal("maude", s.read("albums/3.lebowski"))
+	s.Require().False(s.fs.Exists(".uploads/" + session.Token()))
+}
+
+func (s *UploadTestSuite) TestCommit_newDirectory() {
+	errDiskFull := errors.New("disk full")
+	files := filestoretest.NewFaultFS(s.fs)
+	session, err := filestore.StartUpload(files)
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(session, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(session, "duderino/5.lebowski", "jackie"))
+
+	// A new destination gets the whole staging directory in a single move.
+	files.FailPath("move", ".uploads/"+session.Token()+"/files", errDiskFull)
+	s.Require().True(errors.Is(session.Commit("albums"), errDiskFull))
+	s.Require().False(s.fs.Exists("albums"))
+	s.Require().True(session.Exists("duderino/5.lebowski"))
+
+	files.FailPath("move", ".uploads/"+session.Token()+"/files", nil)
+	s.Require().NoError(session.Commit("albums"))
+	s.Require().Equal("jeff", s.read("albums/1.lebowski"))
+	s.Require().Equal("jackie", s.read("albums/duderino/5.lebowski"))
+}
+
+func (s *UploadTestSuite) TestAbort() {
+	session, err := filestore.StartUpload(s.fs)
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(session, "1.lebowski", "jeff"))
+	s.Require().NoError(session.Abort())
+
+	_, err = filestore.ResumeUpload(s.fs, session.Token())
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+}
+
+func (s *UploadTestSuite) TestResumeUpload_invalid() {
+	for _, token := range []string{"", ".", "..", "../albums", "a/b"} {
+		_, err := filestore.ResumeUpload(s.fs, token)
+		s.Require().Error(err, "Token %q should be rejected", token)
+	}
+	_, err := filestore.ResumeUpload(s.fs, "nope")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+}
+
+func (s *UploadTestSuite) TestCollectUploads() {
+	abandoned, err := filestore.StartUpload(s.fs)
+	s.Require().NoError(err)
+	s.Require().NoError(writeString(abandoned, "1.lebowski", "jeff"))
+
+	active, err := filestore.StartUpload(s.fs)
+	s.Require().NoError(err)
+	resumed, err := filestore.StartUpload(s.fs)
+	s.Require().NoError(err)
+
+	s.clock.Advance(50 * time.Minute)
+	s.Require().NoError(writeString(active, "2.lebowski", "walter"))
+	_, err = filestore.ResumeUpload(s.fs, resumed.Token())
+	s.Require().NoError(err)
+
+	s.clock.Advance(20 * time.Minute)
+	removed, err := filestore.CollectUploads(s.fs, time.Hour, filestore.WithClock(s.clock))
+	s.Require().NoError(err)
+	s.Require().Equal(1, removed)
+
+	_, err = filestore.ResumeUpload(s.fs, abandoned.Token())
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Abandoned session should be collected")
+	_, err = filestore.ResumeUpload(s.fs, active.Token())
+	s.Require().NoError(err, "Sessions w/ recent uploads should be kept")
+	_, err = filestore.ResumeUpload(s.fs, resumed.Token())
+	s.Require().NoError(err, "Recently resumed sessions should be kept")
+
+	removed, err = filestore.CollectUploads(filestore.Memory(), time.Hour)
+	s.Require().NoError(err, "Stores w/o any uploads are fine")
+	s.Require().Equal(0, removed)
+}
+
+func (s *UploadTestSuite) TestAssembleChunks() {
+	session, err := filestore.StartUpload(s.fs)
+	s.Require().NoError(err)
+
+	chunks, err := session.Chunks("videos/dude.mp4")
+	s.Require().NoError(err)
+	s.Require().Empty(chunks)
+
+	s.Require().NoError(session.WriteChunk("videos/dude.mp4", 2, strings.NewReader("abides")))
+	s.Require().NoError(session.WriteChunk("videos/dude.mp4", 0, strings.NewReader("the ")))
+	s.Require().NoError(session.WriteChunk("videos/dude", 1, strings.NewReader("nope")), "Should not collide w/ other files")
+	s.Require().Error(session.WriteChunk("videos/dude.mp4", -1, strings.NewReader("nope")))
+
+	chunks, err = session.Chunks("videos/dude.mp4")
+	s.Require().NoError(err)
+	s.Require().Equal([]int{0, 2}, chunks)
+
+	err = session.AssembleChunks("videos/dude.mp4", 3, nil, nil)
+	s.Require().True(errors.Is(err, fs.ErrNotExist), "Should not assemble w/ missing chunks")
+	s.Require().False(session.Exists("videos/dude.mp4"))
+
+	resumed, err := filestore.ResumeUpload(s.fs, session.Token())
+	s.Require().NoError(err)
+	s.Require().NoError(resumed.WriteChunk("videos/dude.mp4", 1, strings.NewReader("dude ")))
+	checksum := sha256.Sum256([]byte("the dude abides"))
+	s.Require().NoError(resumed.AssembleChunks("videos/dude.mp4", 3, sha256.New(), checksum[:]))
+
+	chunks, err = resumed.Chunks("videos/dude.mp4")
+	s.Require().NoError(err)
+	s.Require().Empty(chunks, "Chunks should be discarded once assembled")
+
+	s.Require().NoError(resumed.Commit("."))
+	s.Require().Equal("the dude abides", s.read("videos/dude.mp4"))
+	s.Require().False(s.fs.Exists("videos/dude"), "Unassembled chunks are not files")
+}
+
+func (s *UploadTestSuite) TestAssembleChunks_checksumMismatch() {
+	session, err := filestore.StartUpload(s.fs)
+	s.Require().NoError(err)
+	s.Require().NoError(session.WriteChunk("1.lebowski", 0, strings.NewReader("jeff")))
+
+	checksum := sha256.Sum256([]byte("walter"))
+	err = session.AssembleChunks("1.lebowski", 1, sha256.New(), checksum[:])
+	s.Require().True(errors.Is(err, filestore.ErrChecksumMismatch))
+	s.Require().False(session.Exists("1.lebowski"), "Corrupt file should be discarded")
+
+	chunks, err := session.Chunks("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal([]int{0}, chunks, "Chunks should be kept so the client can retry")
+}
+
+func (s *UploadTestSuite) TestAssembleChunks_checksumMismatchKeepsPrevious() {
+	session, err := filestore.StartUpload(s.fs)
+	s.Require().NoError(err)
+	s.Require().NoError(session.WriteChunk("1.lebowski", 0, strings.NewReader("jeff")))
+	checksum := sha256.Sum256([]byte("jeff"))
+	s.Require().NoError(session.AssembleChunks("1.lebowski", 1, sha256.New(), checksum[:]))
+
+	s.Require().NoError(session.WriteChunk("1.lebowski", 0, strings.NewReader("walter")))
+	err = session.AssembleChunks("1.lebowski", 1, sha256.New(), checksum[:])
+	s.Require().True(errors.Is(err, filestore.ErrChecksumMismatch))
+
+	content, err := readString(session, "1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jeff", content, "The previously assembled file should be untouched")
+	temps, err := s.fs.List(".uploads/"+session.Token(), filestore.WithPattern("assemble-*"))
+	s.Require().NoError(err)
+	s.Require().Empty(temps, "Temp files should not be left behind")
+
+	s.Require().NoError(session.Commit("."))
+	s.Require().Equal("jeff", s.read("1.lebowski"))
+}
+
+func (s *UploadTestSuite) read(name string) string {
+	content, err := readString(s.fs, name)
+	s.Require().NoError(err)
+	return content
+}
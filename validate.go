@@ -0,0 +1,228 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ErrInvalidContent is the error you can check for (using errors.Is) when a write fails because one of the
+// validators of a Validated() store rejected the file.
+var ErrInvalidContent = errors.New("filestore: invalid content")
+
+// ValidationError is the error returned when one of the validators of a Validated() store rejects a file.
+// Both errors.Is(err, ErrInvalidContent) and checks for whatever the validator failed w/ work on it.
+type ValidationError struct {
+	// Path is the file that you were writing.
+	Path string
+	// Err is the reason the validator gave for rejecting the file.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("filestore: %s: invalid content: %v", e.Path, e.Err)
+}
+
+// Is makes errors.Is(err, ErrInvalidContent) work.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidContent
+}
+
+// Unwrap returns the reason the validator gave for rejecting the file.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Validator inspects the complete contents of a file written to a Validated() store, returning an error
+// to reject it. A validator doesn't need to read all of the data; it can stop as soon as it's made up its
+// mind.
+type Validator func(filePath string, data io.Reader) error
+
+// MaxSize creates a validator that rejects files bigger than maxBytes. It only reads as far as the limit.
+// If you want writes to fail the moment they'd cross the limit, use MaxFileSize() instead.
+func MaxSize(maxBytes int64) Validator {
+	return func(_ string, data io.Reader) error {
+		n, err := io.Copy(io.Discard, io.LimitReader(data, maxBytes+1))
+		switch {
+		case err != nil:
+			return err
+		case n > maxBytes:
+			return fmt.Errorf("larger than %d bytes: %w", maxBytes, ErrFileTooLarge)
+		default:
+			return nil
+		}
+	}
+}
+
+// AllowContentTypes creates a validator that only accepts files whose content type is one of the given
+// MIME types. We sniff the type from the file's first bytes (its "magic bytes") using the algorithm from
+// http.DetectContentType() rather than trusting the file's extension. Types may end in "/*" to allow a whole
+// family, e.g. "image/*". Parameters like "; charset=utf-8" are ignored.
+//
+// Example:
+//
+//	filestore.AllowContentTypes("image/png", "image/jpeg", "application/pdf")
+func AllowContentTypes(contentTypes ...string) Validator {
+	return func(_ string, data io.Reader) error {
+		head := make([]byte, 512)
+		n, err := io.ReadFull(data, head)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+
+		detected, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+		for _, allowed := range contentTypes {
+			family := strings.TrimSuffix(allowed, "/*")
+			wildcard := family != allowed
+			if detected == allowed || (wildcard && strings.HasPrefix(detected, family+"/")) {
+				return nil
+			}
+		}
+		return fmt.Errorf("content type %s is not allowed", detected)
+	}
+}
+
+// Validated wraps a file store so that every file written through it must pass all of the validators
+// (size limits, content type allowlists, virus scanners, etc.) before it shows up in the store. Data is
+// written to a temp file next to the destination first. When you close the file, we run the validators
+// over the temp file's contents one at a time, in order, and only move it into place if they all accept
+// it. Otherwise, the temp file is removed and Close() fails w/ a *ValidationError, so rejected files never
+// leave anything behind, and an existing file at that path is left untouched.
+//
+// Validators that panic reject the file w/ a *PanicError.
+//
+// Example:
+//
+//	scanForViruses := func(filePath string, data io.Reader) error {
+//	    return clamav.Scan(data)
+//	}
+//	uploads := filestore.Validated(filestore.Disk("/var/uploads"),
+//	    filestore.MaxSize(25<<20),
+//	    filestore.AllowContentTypes("image/*", "application/pdf"),
+//	    scanForViruses,
+//	)
+//	...
+//	if err = file.Close(); errors.Is(err, filestore.ErrInvalidContent) {
+//	    http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+//	}
+func Validated(fs FS, validators ...Validator) FS {
+	return &validatedFS{FS: fs, validators: validators}
+}
+
+func init() {
+	RegisterLayer("validated", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			MaxSize      int64    `json:"maxSize"`
+			ContentTypes []string `json:"contentTypes"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+
+		var validators []Validator
+		if settings.MaxSize > 0 {
+			validators = append(validators, MaxSize(settings.MaxSize))
+		}
+		if len(settings.ContentTypes) > 0 {
+			validators = append(validators, AllowContentTypes(settings.ContentTypes...))
+		}
+		return Validated(fs, validators...), nil
+	})
+}
+
+type validatedFS struct {
+	FS
+	validators []Validator
+}
+
+// Write opens a temp file next to the destination. The file only replaces the destination once you close it
+// and all of the validators accept it.
+func (v *validatedFS) Write(filePath string) (WriterFile, error) {
+	if len(v.validators) == 0 {
+		return v.FS.Write(filePath)
+	}
+	file, tempPath, err := TempFile(v.FS, path.Dir(filePath), "."+path.Base(filePath)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("filestore: validated: write: %w", err)
+	}
+	return &validatedWriterFile{WriterFile: file, fs: v, path: filePath, tempPath: tempPath}, nil
+}
+
+func (v *validatedFS) ChangeDirectory(dir string) FS {
+	return &validatedFS{FS: v.FS.ChangeDirectory(dir), validators: v.validators}
+}
+
+func (v *validatedFS) withContext(ctx context.Context) FS {
+	return &validatedFS{FS: ForRequest(v.FS, ctx), validators: v.validators}
+}
+
+func (v *validatedFS) requestContext() context.Context {
+	return RequestContext(v.FS)
+}
+
+func (v *validatedFS) wrapped() []FS {
+	return []FS{v.FS}
+}
+
+// validate runs each validator over the data in the temp file until one of them rejects it.
+func (v *validatedFS) validate(filePath string, tempPath string) error {
+	for _, validator := range v.validators {
+		if err := v.runValidator(validator, filePath, tempPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runValidator gives the validator a fresh reader for the temp file, converting a panic into a *PanicError.
+func (v *validatedFS) runValidator(validator Validator, filePath string, tempPath string) (err error) {
+	file, err := v.FS.Read(tempPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	defer func() {
+		if err != nil {
+			err = &ValidationError{Path: filePath, Err: err}
+		}
+	}()
+	defer recoverPanic(&err)
+	return validator(filePath, file)
+}
+
+// validatedWriterFile writes to a temp file and moves it into place once it's been validated.
+type validatedWriterFile struct {
+	WriterFile
+	fs       *validatedFS
+	path     string
+	tempPath string
+	closed   bool
+}
+
+func (f *validatedWriterFile) Close() error {
+	if f.closed {
+		return f.WriterFile.Close()
+	}
+	f.closed = true
+
+	if err := f.WriterFile.Close(); err != nil {
+		_ = f.fs.FS.Remove(f.tempPath)
+		return err
+	}
+	if err := f.fs.validate(f.path, f.tempPath); err != nil {
+		_ = f.fs.FS.Remove(f.tempPath)
+		return err
+	}
+	if err := f.fs.FS.Move(f.tempPath, f.path); err != nil {
+		_ = f.fs.FS.Remove(f.tempPath)
+		return err
+	}
+	return nil
+}
+
+var _ requestBinder = &validatedFS{}
+var _ wrapper = &validatedFS{}
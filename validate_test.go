@@ -0,0 +1,99 @@
+package filestore_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ValidatedTestSuite struct {
+	suite.Suite
+	base filestore.FS
+	fs   filestore.FS
+}
+
+func TestValidatedTestSuite(t *testing.T) {
+	suite.Run(t, &ValidatedTestSuite{})
+}
+
+func (s *ValidatedTestSuite) SetupTest() {
+	s.base = filestore.Memory()
+	s.fs = filestore.Validated(s.base,
+		filestore.MaxSize(16),
+		filestore.AllowContentTypes("text/*", "image/png"),
+	)
+}
+
+func (s *ValidatedTestSuite) TestWrite() {
+	files := s.fs.ChangeDirectory("docs")
+	s.Require().NoError(writeString(files, "a.txt", "abide"))
+	s.Require().NoError(writeString(files, "logo.png", "\x89PNG\r\n\x1a\nlogo"))
+	s.assertContent(s.base, "docs/a.txt", "abide")
+
+	infos, err := s.base.List("docs")
+	s.Require().NoError(err)
+	s.Require().Len(infos, 2, "Shouldn't leave any temp files behind")
+}
+
+func (s *ValidatedTestSuite) TestWrite_rejected() {
+	s.Require().NoError(writeString(s.fs, "a.txt", "abide"))
+
+	err := writeString(s.fs, "a.txt", "the dude abides, man")
+	var validationErr *filestore.ValidationError
+	s.Require().True(errors.As(err, &validationErr))
+	s.Require().Equal("a.txt", validationErr.Path)
+	s.Require().True(errors.Is(err, filestore.ErrInvalidContent))
+	s.Require().True(errors.Is(err, filestore.ErrFileTooLarge))
+	s.assertContent(s.base, "a.txt", "abide", "Should leave the existing file alone")
+
+	err = writeString(s.fs, "b.pdf", "%PDF-1.7")
+	s.Require().True(errors.Is(err, filestore.ErrInvalidContent))
+	s.Require().False(s.base.Exists("b.pdf"), "Should leave nothing behind")
+
+	infos, err := s.base.List(".")
+	s.Require().NoError(err)
+	s.Require().Len(infos, 1, "Shouldn't leave any temp files behind")
+}
+
+func (s *ValidatedTestSuite) TestCustomValidator() {
+	errInfected := errors.New("infected")
+	scanned := ""
+	files := filestore.Validated(s.base,
+		func(filePath string, data io.Reader) error {
+			content, err := io.ReadAll(data)
+			if err != nil {
+				return err
+			}
+			scanned = filePath
+			if strings.Contains(string(content), "EICAR") {
+				return errInfected
+			}
+			return nil
+		},
+		func(filePath string, data io.Reader) error {
+			if strings.HasSuffix(filePath, ".exe") {
+				panic("no executables")
+			}
+			return nil
+		},
+	)
+
+	s.Require().NoError(writeString(files, "clean.txt", "abide"))
+	s.Require().Equal("clean.txt", scanned)
+	s.Require().True(errors.Is(writeString(files, "virus.txt", "X5O!EICAR"), errInfected))
+	s.Require().False(s.base.Exists("virus.txt"))
+
+	var panicErr *filestore.PanicError
+	s.Require().True(errors.As(writeString(files, "app.exe", "MZ"), &panicErr))
+	s.Require().False(s.base.Exists("app.exe"))
+}
+
+func (s *ValidatedTestSuite) assertContent(fs filestore.FS, filePath string, expected string, msgAndArgs ...any) {
+	content, err := readString(fs, filePath)
+	s.Require().NoError(err, msgAndArgs...)
+	s.Require().Equal(expected, content, msgAndArgs...)
+}
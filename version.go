@@ -0,0 +1,89 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// VersionInfo describes a single version in a file's history.
+type VersionInfo struct {
+	// ID is the store-specific identifier for this version (e.g. the S3 version ID). This is
+	// the value you pass to RestoreVersion().
+	ID string
+	// ModTime is when this version was written (or when the file was deleted).
+	ModTime time.Time
+	// Size is the number of bytes in this version of the file.
+	Size int64
+	// Latest is true for the file's current version.
+	Latest bool
+	// Deleted is true when this "version" just records that the file was deleted at this time
+	// (e.g. an S3 delete marker). There's no data to read or restore.
+	Deleted bool
+}
+
+// Versioner is an optional capability for stores that keep a history of each file's contents
+// (e.g. an S3 bucket with versioning enabled).
+type Versioner interface {
+	// Versions returns every version of the file, newest first.
+	Versions(path string) ([]VersionInfo, error)
+	// ReadVersion opens a specific version of the file for reading.
+	ReadVersion(path string, versionID string) (ReaderFile, error)
+	// RestoreVersion makes an older version of the file its current version again. The versions
+	// written since then are not lost; restoring just adds another version w/ the old contents.
+	RestoreVersion(path string, versionID string) error
+}
+
+// ErrVersioningNotSupported is the error returned by Versions() and RestoreVersion() when the
+// store does not implement the Versioner capability.
+var ErrVersioningNotSupported = errors.New("filestore: versioning not supported")
+
+// Versions returns the history of the file, newest first, if the store supports the Versioner
+// capability. For all other stores, this fails with ErrVersioningNotSupported.
+//
+// Example:
+//
+//	versions, err := filestore.Versions(bucket, "conf/app.json")
+//	if err != nil {
+//	    // handle error
+//	}
+//	for _, version := range versions {
+//	    fmt.Println(version.ID, version.ModTime, version.Size)
+//	}
+func Versions(fs FS, path string) ([]VersionInfo, error) {
+	if versioner, ok := fs.(Versioner); ok {
+		return versioner.Versions(path)
+	}
+	return nil, fmt.Errorf("filestore: versions: %s: %w", path, ErrVersioningNotSupported)
+}
+
+// ReadVersion opens a specific version of the file for reading (e.g. to show what changed) if the store
+// supports the Versioner capability. For all other stores, this fails w/ ErrVersioningNotSupported.
+//
+// Example:
+//
+//	versions, err := filestore.Versions(bucket, "conf/app.json")
+//	...
+//	previous, err := filestore.ReadVersion(bucket, "conf/app.json", versions[1].ID)
+func ReadVersion(fs FS, path string, versionID string) (ReaderFile, error) {
+	if versioner, ok := fs.(Versioner); ok {
+		return versioner.ReadVersion(path, versionID)
+	}
+	return nil, fmt.Errorf("filestore: read version: %s: %w", path, ErrVersioningNotSupported)
+}
+
+// RestoreVersion makes an older version of the file its current version again if the store
+// supports the Versioner capability. For all other stores, this fails w/ ErrVersioningNotSupported.
+//
+// Example:
+//
+//	// Oops. Undo that last config change.
+//	versions, err := filestore.Versions(bucket, "conf/app.json")
+//	...
+//	err = filestore.RestoreVersion(bucket, "conf/app.json", versions[1].ID)
+func RestoreVersion(fs FS, path string, versionID string) error {
+	if versioner, ok := fs.(Versioner); ok {
+		return versioner.RestoreVersion(path, versionID)
+	}
+	return fmt.Errorf("filestore: restore version: %s: %w", path, ErrVersioningNotSupported)
+}
@@ -0,0 +1,378 @@
+package filestore
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// versionsDir is the hidden directory where Versioned() keeps the history of every file.
+const versionsDir = ".versions"
+
+// currentVersionID is the version ID that Versioned() stores use for a file's current contents.
+const currentVersionID = "current"
+
+// versionOptions contains the settings that only apply to Versioned().
+type versionOptions struct {
+	maxVersions int
+}
+
+// WithMaxVersions limits how many old versions of each file a Versioned() store keeps, discarding the
+// oldest ones once there are more than that. By default, we keep every version forever.
+func WithMaxVersions(n int) Option {
+	return func(opts *options) {
+		if n > 0 {
+			opts.versions.maxVersions = n
+		}
+	}
+}
+
+// Versioned wraps a file store so that it keeps a history of every file: whenever you overwrite a file
+// (via Write or by moving something on top of it) or remove it, its previous contents are copied into the
+// hidden ".versions" directory first. The wrapper supports the Versioner capability, so you can use
+// Versions(), ReadVersion(), and RestoreVersion() to see/undo changes, even for files that have since been
+// removed. Restoring a version is itself a change, so the contents it replaces become a version, too.
+//
+// The file's current contents are always the newest version, w/ the ID "current". Older versions are
+// named after the modification time of the contents they hold, so they're in chronological order. Moving
+// a file doesn't take its history along; the history stays w/ the original path. The ".versions"
+// directory doesn't show up in listings, and you can't change it through the wrapper. Use
+// WithMaxVersions() to limit how much history is kept and WithRandom() to control the random part of the
+// version IDs in tests.
+//
+// Example:
+//
+//	documents := filestore.Versioned(filestore.Disk("/var/lib/editor"), filestore.WithMaxVersions(50))
+//	...
+//	// Undo the last save.
+//	versions, err := filestore.Versions(documents, "drafts/proposal.md")
+//	...
+//	err = filestore.RestoreVersion(documents, "drafts/proposal.md", versions[1].ID)
+func Versioned(fs FS, opts ...Option) FS {
+	options := newOptions(opts)
+	return &versionedFS{fs: fs, dir: "/", random: options.random, maxVersions: options.versions.maxVersions}
+}
+
+func init() {
+	RegisterLayer("versioned", func(fs FS, options LayerOptions) (FS, error) {
+		settings := struct {
+			MaxVersions int `json:"maxVersions"`
+		}{}
+		if err := options.Decode(&settings); err != nil {
+			return nil, err
+		}
+		return Versioned(fs, WithMaxVersions(settings.MaxVersions)), nil
+	})
+}
+
+type versionedFS struct {
+	fs FS
+	// dir is the working directory, relative to the wrapped store's working directory. We resolve every
+	// path ourselves so that the history of every file lives under the same ".versions" directory, no
+	// matter which directory you're working in.
+	dir         string
+	random      io.Reader
+	maxVersions int
+}
+
+// resolve converts the path into an absolute path within the wrapped store, failing if it's part of
+// the history.
+func (v *versionedFS) resolve(filePath string) (string, error) {
+	resolved, err := resolvePath(v.dir, filePath)
+	if err != nil {
+		return "", err
+	}
+	if resolved == "/"+versionsDir || strings.HasPrefix(resolved, "/"+versionsDir+"/") {
+		return "", fmt.Errorf("reserved for file history: %w", fs.ErrPermission)
+	}
+	return resolved, nil
+}
+
+// historyDir is the directory that contains every old version of the file at the resolved path.
+func (v *versionedFS) historyDir(resolved string) string {
+	return path.Join("/", versionsDir, resolved)
+}
+
+// WorkingDirectory returns the current FS context's path/directory.
+func (v *versionedFS) WorkingDirectory() string {
+	return joinPath(v.fs.WorkingDirectory(), v.dir)
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory that shares the same history.
+func (v *versionedFS) ChangeDirectory(dir string) FS {
+	return &versionedFS{fs: v.fs, dir: joinPath(v.dir, dir), random: v.random, maxVersions: v.maxVersions}
+}
+
+// Stat fetches metadata about the file's current version.
+func (v *versionedFS) Stat(filePath string) (FileInfo, error) {
+	resolved, err := v.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("versioned", "stat", filePath, err)
+	}
+	return v.fs.Stat(resolved)
+}
+
+// Exists returns true when the file/directory currently exists.
+func (v *versionedFS) Exists(filePath string) bool {
+	resolved, err := v.resolve(filePath)
+	return err == nil && v.fs.Exists(resolved)
+}
+
+// List reads the contents of the directory, leaving out the history.
+func (v *versionedFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	resolved, err := v.resolve(dirPath)
+	if err != nil {
+		return nil, newPathError("versioned", "list", dirPath, err)
+	}
+	infos, err := v.fs.List(resolved, filters...)
+	if err != nil || resolved != "/" {
+		return infos, err
+	}
+	results := infos[:0]
+	for _, info := range infos {
+		if info.Name() != versionsDir {
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// Read opens the file's current version for reading.
+func (v *versionedFS) Read(filePath string) (ReaderFile, error) {
+	resolved, err := v.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("versioned", "open", filePath, err)
+	}
+	return v.fs.Read(resolved)
+}
+
+// Write saves the file's current contents as a version before opening it for writing.
+func (v *versionedFS) Write(filePath string) (WriterFile, error) {
+	resolved, err := v.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("versioned", "write", filePath, err)
+	}
+	if err = v.preserve(resolved); err != nil {
+		return nil, newPathError("versioned", "write", filePath, err)
+	}
+	return v.fs.Write(resolved)
+}
+
+// Move relocates the file/directory, saving the contents of the file it replaces (if any) as a version.
+func (v *versionedFS) Move(fromPath string, toPath string) error {
+	from, err := v.resolve(fromPath)
+	if err != nil {
+		return newPathError("versioned", "move", fromPath, err)
+	}
+	to, err := v.resolve(toPath)
+	if err != nil {
+		return newPathError("versioned", "move", toPath, err)
+	}
+	if err = v.preserve(to); err != nil {
+		return newPathError("versioned", "move", toPath, err)
+	}
+	return v.fs.Move(from, to)
+}
+
+// Remove deletes the file/directory, saving the contents of every file it removes as a version first.
+func (v *versionedFS) Remove(fileOrDirPath string) error {
+	resolved, err := v.resolve(fileOrDirPath)
+	if err != nil {
+		return newPathError("versioned", "remove", fileOrDirPath, err)
+	}
+	err = Walk(v.fs, resolved, func(filePath string, info FileInfo, err error) error {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return nil
+		case err != nil:
+			return err
+		case info.IsDir() && path.Base(filePath) == versionsDir && path.Dir(filePath) == "/":
+			return fs.SkipDir
+		case info.IsDir():
+			return nil
+		default:
+			return v.preserve(filePath)
+		}
+	})
+	if err != nil {
+		return newPathError("versioned", "remove", fileOrDirPath, err)
+	}
+	if resolved != "/" {
+		return v.fs.Remove(resolved)
+	}
+
+	// Removing everything shouldn't take the history w/ it.
+	entries, err := v.List(fileOrDirPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err = v.fs.Remove(path.Join(resolved, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Versions returns every version of the file, newest first. The first one is the file's current version
+// (w/ the ID "current") unless the file was removed.
+func (v *versionedFS) Versions(filePath string) ([]VersionInfo, error) {
+	resolved, err := v.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("versioned", "versions", filePath, err)
+	}
+
+	var results []VersionInfo
+	info, err := v.fs.Stat(resolved)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, newPathError("versioned", "versions", filePath, err)
+	case info.IsDir():
+		return nil, newPathError("versioned", "versions", filePath, errIsDirectory)
+	default:
+		results = append(results, VersionInfo{ID: currentVersionID, ModTime: info.ModTime(), Size: info.Size(), Latest: true})
+	}
+
+	history, err := v.history(resolved)
+	if err != nil {
+		return nil, newPathError("versioned", "versions", filePath, err)
+	}
+	for _, version := range history {
+		results = append(results, VersionInfo{ID: version.Name(), ModTime: versionModTime(version.Name()), Size: version.Size()})
+	}
+	if len(results) == 0 {
+		return nil, newPathError("versioned", "versions", filePath, fs.ErrNotExist)
+	}
+	return results, nil
+}
+
+// ReadVersion opens a specific version of the file for reading.
+func (v *versionedFS) ReadVersion(filePath string, versionID string) (ReaderFile, error) {
+	resolved, err := v.resolve(filePath)
+	if err != nil {
+		return nil, newPathError("versioned", "read version", filePath, err)
+	}
+	if versionID == currentVersionID {
+		return v.fs.Read(resolved)
+	}
+	if versionID == "" || versionID == "." || versionID == ".." || versionID != path.Base(versionID) {
+		return nil, newPathError("versioned", "read version", filePath, fmt.Errorf("no version %q: %w", versionID, fs.ErrNotExist))
+	}
+	return v.fs.Read(path.Join(v.historyDir(resolved), versionID))
+}
+
+// RestoreVersion copies an old version of the file on top of its current contents, which become a
+// version of their own.
+func (v *versionedFS) RestoreVersion(filePath string, versionID string) error {
+	if versionID == currentVersionID {
+		return nil
+	}
+	input, err := v.ReadVersion(filePath, versionID)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := v.Write(filePath)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(output, input); err != nil {
+		_ = output.Close()
+		return newPathError("versioned", "restore version", filePath, err)
+	}
+	return output.Close()
+}
+
+func (v *versionedFS) withContext(ctx context.Context) FS {
+	return &versionedFS{fs: ForRequest(v.fs, ctx), dir: v.dir, random: v.random, maxVersions: v.maxVersions}
+}
+
+func (v *versionedFS) requestContext() context.Context {
+	return RequestContext(v.fs)
+}
+
+func (v *versionedFS) wrapped() []FS {
+	return []FS{v.fs}
+}
+
+// history returns the old versions of the file at the resolved path, newest first.
+func (v *versionedFS) history(resolved string) ([]FileInfo, error) {
+	versions, err := v.fs.List(v.historyDir(resolved))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := versions[:0]
+	for _, version := range versions {
+		if !version.IsDir() {
+			results = append(results, version)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name() > results[j].Name()
+	})
+	return results, nil
+}
+
+// preserve copies the current contents of the file at the resolved path (if there is one) into its
+// history, discarding the oldest versions if there are too many.
+func (v *versionedFS) preserve(resolved string) error {
+	info, err := v.fs.Stat(resolved)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return nil
+	case err != nil:
+		return err
+	case info.IsDir():
+		return nil
+	}
+
+	suffix := make([]byte, 4)
+	if _, err = io.ReadFull(v.random, suffix); err != nil {
+		return err
+	}
+	versionID := fmt.Sprintf("%016x-%s", info.ModTime().UnixNano(), hex.EncodeToString(suffix))
+	if err = copyFile(v.fs, resolved, v.fs, path.Join(v.historyDir(resolved), versionID), info.Size(), info.ModTime()); err != nil {
+		return err
+	}
+
+	if v.maxVersions <= 0 {
+		return nil
+	}
+	history, err := v.history(resolved)
+	if err != nil {
+		return err
+	}
+	for i := v.maxVersions; i < len(history); i++ {
+		if err = v.fs.Remove(path.Join(v.historyDir(resolved), history[i].Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// versionModTime extracts the modification time of the contents that an old version holds from its ID.
+func versionModTime(versionID string) time.Time {
+	nanos, _, _ := strings.Cut(versionID, "-")
+	unixNano, err := strconv.ParseInt(nanos, 16, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, unixNano)
+}
+
+var _ Versioner = &versionedFS{}
+var _ requestBinder = &versionedFS{}
+var _ wrapper = &versionedFS{}
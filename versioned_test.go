@@ -0,0 +1,155 @@
+package filestore_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type VersionedTestSuite struct {
+	suite.Suite
+	clock  *filestoretest.Clock
+	memory filestore.FS
+	fs     filestore.FS
+}
+
+func TestVersionedTestSuite(t *testing.T) {
+	suite.Run(t, &VersionedTestSuite{})
+}
+
+func (s *VersionedTestSuite) SetupTest() {
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.memory = filestore.Memory(filestore.WithClock(s.clock))
+	s.fs = filestore.Versioned(s.memory)
+
+	s.save("drafts/proposal.md", "the dude")
+	s.save("drafts/proposal.md", "the dude abides")
+	s.save("drafts/proposal.md", "the dude abides, man")
+}
+
+func (s *VersionedTestSuite) TestVersions() {
+	versions, err := filestore.Versions(s.fs, "drafts/proposal.md")
+	s.Require().NoError(err)
+	s.Require().Len(versions, 3)
+	s.Require().Equal(filestore.VersionInfo{ID: "current", ModTime: s.clock.Now(), Size: 20, Latest: true}, versions[0])
+	s.Require().Equal(int64(15), versions[1].Size)
+	s.Require().True(versions[1].ModTime.Equal(s.clock.Now().Add(-time.Minute)))
+	s.Require().Equal(int64(8), versions[2].Size)
+	s.Require().True(versions[2].ModTime.Equal(s.clock.Now().Add(-2 * time.Minute)))
+
+	s.assertVersion("drafts/proposal.md", versions[0].ID, "the dude abides, man")
+	s.assertVersion("drafts/proposal.md", versions[1].ID, "the dude abides")
+	s.assertVersion("drafts/proposal.md", versions[2].ID, "the dude")
+
+	versions, err = filestore.Versions(s.fs.ChangeDirectory("drafts"), "proposal.md")
+	s.Require().NoError(err)
+	s.Require().Len(versions, 3, "Should share the history w/ derived stores")
+
+	_, err = filestore.Versions(s.fs, "drafts")
+	s.Require().Error(err, "Directories don't have versions")
+	_, err = filestore.Versions(s.fs, "drafts/missing.md")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = filestore.ReadVersion(s.fs, "drafts/proposal.md", "nope")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	_, err = filestore.ReadVersion(s.fs, "drafts/proposal.md", "..")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+}
+
+func (s *VersionedTestSuite) TestHistoryHidden() {
+	infos, err := s.fs.List(".")
+	s.Require().NoError(err)
+	s.Require().Len(infos, 1)
+	s.Require().Equal("drafts", infos[0].Name())
+	s.Require().True(s.memory.Exists(".versions/drafts/proposal.md"))
+
+	s.Require().False(s.fs.Exists(".versions"))
+	_, err = s.fs.ChangeDirectory("drafts").Write("../.versions/drafts/proposal.md/nope")
+	s.Require().True(errors.Is(err, fs.ErrPermission), "Should not be able to tamper w/ the history")
+	s.Require().True(errors.Is(s.fs.Remove(".versions"), fs.ErrPermission))
+}
+
+func (s *VersionedTestSuite) TestRestoreVersion() {
+	versions, err := filestore.Versions(s.fs, "drafts/proposal.md")
+	s.Require().NoError(err)
+
+	s.Require().NoError(filestore.RestoreVersion(s.fs, "drafts/proposal.md", versions[2].ID))
+	s.assertContent("drafts/proposal.md", "the dude")
+
+	restored, err := filestore.Versions(s.fs, "drafts/proposal.md")
+	s.Require().NoError(err)
+	s.Require().Len(restored, 4, "Restoring should add a new version, not discard history")
+	s.assertVersion("drafts/proposal.md", restored[1].ID, "the dude abides, man")
+
+	s.Require().NoError(filestore.RestoreVersion(s.fs, "drafts/proposal.md", "current"))
+	s.Require().Error(filestore.RestoreVersion(s.fs, "drafts/proposal.md", "nope"))
+}
+
+func (s *VersionedTestSuite) TestRemove() {
+	s.save("drafts/notes.md", "nihilists")
+	s.Require().NoError(s.fs.Remove("drafts/proposal.md"))
+	s.Require().False(s.fs.Exists("drafts/proposal.md"))
+
+	versions, err := filestore.Versions(s.fs, "drafts/proposal.md")
+	s.Require().NoError(err)
+	s.Require().Len(versions, 3, "Should keep the last contents of removed files")
+	s.Require().False(versions[0].Latest)
+	s.Require().NoError(filestore.RestoreVersion(s.fs, "drafts/proposal.md", versions[0].ID))
+	s.assertContent("drafts/proposal.md", "the dude abides, man")
+
+	s.Require().NoError(s.fs.Remove("."))
+	infos, err := s.fs.List(".")
+	s.Require().NoError(err)
+	s.Require().Empty(infos)
+	versions, err = filestore.Versions(s.fs, "drafts/notes.md")
+	s.Require().NoError(err)
+	s.Require().Len(versions, 1, "Removing everything should not remove the history")
+	s.assertVersion("drafts/notes.md", versions[0].ID, "nihilists")
+}
+
+func (s *VersionedTestSuite) TestMove() {
+	s.save("drafts/proposal.md.tmp", "abide")
+	s.Require().NoError(s.fs.Move("drafts/proposal.md.tmp", "drafts/proposal.md"))
+	s.assertContent("drafts/proposal.md", "abide")
+
+	versions, err := filestore.Versions(s.fs, "drafts/proposal.md")
+	s.Require().NoError(err)
+	s.Require().Len(versions, 4, "Should keep the contents of the file that was replaced")
+	s.assertVersion("drafts/proposal.md", versions[1].ID, "the dude abides, man")
+}
+
+func (s *VersionedTestSuite) TestMaxVersions() {
+	fs := filestore.Versioned(s.memory, filestore.WithMaxVersions(1))
+	s.clock.Advance(time.Minute)
+	s.Require().NoError(writeString(fs, "drafts/proposal.md", "new"))
+
+	versions, err := filestore.Versions(fs, "drafts/proposal.md")
+	s.Require().NoError(err)
+	s.Require().Len(versions, 2)
+	s.assertVersion("drafts/proposal.md", versions[1].ID, "the dude abides, man")
+}
+
+func (s *VersionedTestSuite) save(filePath string, content string) {
+	s.clock.Advance(time.Minute)
+	s.Require().NoError(writeString(s.fs, filePath, content))
+}
+
+func (s *VersionedTestSuite) assertVersion(filePath string, versionID string, expected string) {
+	file, err := filestore.ReadVersion(s.fs, filePath, versionID)
+	s.Require().NoError(err)
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal(expected, string(content))
+}
+
+func (s *VersionedTestSuite) assertContent(filePath string, expected string) {
+	content, err := readString(s.fs, filePath)
+	s.Require().NoError(err)
+	s.Require().Equal(expected, content)
+}
@@ -0,0 +1,369 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// WalkFunc is the type of the function called by Walk to visit each file or directory. The path
+// is relative to the working directory of the store being walked, joined with the root you gave
+// to Walk (e.g. "photos/2022/beach.jpg" when walking "photos").
+//
+// When Walk can't stat the root or list a directory's contents, it calls the function with the
+// error so you can decide what to do; for directories, that's a second call for the same path.
+// Returning fs.SkipDir from a directory skips its contents, while returning it from a file skips
+// the rest of that file's directory. Returning any other error stops the walk and Walk returns
+// that error.
+type WalkFunc func(filePath string, info FileInfo, err error) error
+
+// walkOptions contains the settings that only apply to recursive operations like Walk.
+type walkOptions struct {
+	hardlinks  bool
+	symlinks   bool
+	maxDepth   int
+	maxEntries int
+}
+
+// ErrTooManyEntries is the error returned by recursive operations when they encounter more files and
+// directories than you allowed using WithMaxEntries().
+var ErrTooManyEntries = errors.New("filestore: too many entries")
+
+// WithMaxDepth limits how many levels below the root recursive operations (Walk, Find, DirSize, CopyAll)
+// will descend. A depth of 0 only visits the root itself, 1 visits the root and its immediate children,
+// and so on. Anything deeper is silently ignored, just like "find -maxdepth". By default, there is no limit.
+//
+// Example:
+//
+//	// Only look at the top-level files/directories in each user's home directory.
+//	files, err := filestore.Find(homes, ".", filestore.WithExt("txt"), filestore.WithMaxDepth(2))
+func WithMaxDepth(depth int) Option {
+	return func(opts *options) {
+		if depth >= 0 {
+			opts.walk.maxDepth = depth
+		}
+	}
+}
+
+// WithMaxEntries limits how many files and directories recursive operations (Walk, Find, DirSize, CopyAll)
+// will visit in total. Unlike WithMaxDepth(), hitting this limit is an error; the operation stops and
+// returns ErrTooManyEntries so that you know that the results are incomplete. This is a good guardrail for
+// request handlers that work with user-controlled directory trees. By default, there is no limit.
+func WithMaxEntries(entries int) Option {
+	return func(opts *options) {
+		if entries > 0 {
+			opts.walk.maxEntries = entries
+		}
+	}
+}
+
+// WithHardlinkDetection makes recursive operations (Walk, DirSize, CopyAll) notice when multiple
+// paths are hard links to the same underlying file (i.e. they have the same device and inode).
+// Walk only visits the first path for each file, DirSize only counts its size once, and CopyAll
+// recreates the links in the destination rather than copying the same data over and over.
+//
+// This only has an effect on stores whose file info exposes inodes (i.e. DiskFS on Unix-like systems).
+//
+// Example:
+//
+//	// Count the real disk usage of a hardlink farm like an rsnapshot backup.
+//	size, err := filestore.DirSize(backups, "daily.0", filestore.WithHardlinkDetection())
+func WithHardlinkDetection() Option {
+	return func(opts *options) {
+		opts.walk.hardlinks = true
+	}
+}
+
+// WithFollowSymlinks makes recursive operations (Walk, DirSize, CopyAll) treat symbolic links as the
+// files/directories they point to, descending into linked directories. Links that would lead back into
+// a directory we're already inside of (e.g. a link to "." or to a parent directory) are not followed,
+// so a self-referencing link can't send the operation into an infinite loop; those links are visited
+// as plain links instead. Broken links are also visited as plain links.
+//
+// Cycle detection relies on inodes, so only use this with stores whose file info exposes them (i.e.
+// DiskFS on Unix-like systems) unless you know the tree is free of cycles.
+//
+// Example:
+//
+//	err := filestore.Walk(files, "shared", visit, filestore.WithFollowSymlinks())
+func WithFollowSymlinks() Option {
+	return func(opts *options) {
+		opts.walk.symlinks = true
+	}
+}
+
+// Walk visits the file/directory at root and, if it's a directory, everything in it, calling fn for
+// each one. Entries in each directory are visited in lexical order. Walk does not follow symbolic links
+// unless you supply WithFollowSymlinks().
+//
+// Example:
+//
+//	err := filestore.Walk(files, "logs", func(filePath string, info filestore.FileInfo, err error) error {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    if !info.IsDir() && strings.HasSuffix(filePath, ".gz") {
+//	        archives = append(archives, filePath)
+//	    }
+//	    return nil
+//	})
+func Walk(fsys FS, root string, fn WalkFunc, opts ...Option) error {
+	w := newWalker(fsys, opts)
+	err := w.walkRoot(root, func(filePath string, info FileInfo, firstLink string, err error) error {
+		// We've already visited another path for this same file, so pretend this one doesn't exist.
+		if firstLink != "" {
+			return nil
+		}
+		return fn(filePath, info, err)
+	})
+	if errors.Is(err, fs.SkipDir) {
+		return nil
+	}
+	return err
+}
+
+// DirSize returns the total size, in bytes, of all of the files in the given directory and all of
+// its subdirectories. Use WithHardlinkDetection() to only count each hard-linked file once.
+func DirSize(fsys FS, dir string, opts ...Option) (int64, error) {
+	var total int64
+	err := Walk(fsys, dir, func(_ string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	}, opts...)
+	return total, err
+}
+
+// DirUsage is the aggregated disk usage of a single directory and everything in it.
+type DirUsage struct {
+	// Path is the directory's path, relative to the store's working directory just like Walk's paths.
+	Path string
+	// Size is the total size, in bytes, of all of the files in the directory and its subdirectories.
+	Size int64
+	// Files is the number of files in the directory and its subdirectories.
+	Files int
+	// Dirs is the number of subdirectories in the directory, at any depth.
+	Dirs int
+}
+
+// UsageByDir is a "du" for building storage breakdowns. It returns the aggregated usage of the root and
+// of every directory up to 'depth' levels below it, in the same order that Walk visits them; a depth of 0
+// only reports the root while a negative depth reports every directory. Files deeper than that still count
+// towards their ancestors. Everything is computed in a single walk of the tree, and it supports the same
+// options as Walk (e.g. WithHardlinkDetection to only count each hard-linked file once).
+//
+// Example:
+//
+//	// How much space is each customer using, and where?
+//	usage, err := filestore.UsageByDir(files, "customers", 2)
+//	for _, dir := range usage {
+//	    fmt.Printf("%-40s %10d bytes %6d files\n", dir.Path, dir.Size, dir.Files)
+//	}
+func UsageByDir(fsys FS, root string, depth int, opts ...Option) ([]DirUsage, error) {
+	root = path.Clean(root)
+	var results []*DirUsage
+	usage := map[string]*DirUsage{}
+
+	err := Walk(fsys, root, func(filePath string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath == root {
+			if !info.IsDir() {
+				return fmt.Errorf("%s: not a directory", root)
+			}
+			results = append(results, &DirUsage{Path: root})
+			usage[root] = results[0]
+			return nil
+		}
+
+		// Count this file/directory towards every ancestor that we're reporting on.
+		relativePath := filePath
+		if root != "." {
+			relativePath = strings.TrimPrefix(strings.TrimPrefix(filePath, root), "/")
+		}
+		segments := strings.Split(relativePath, "/")
+		ancestor := root
+		for i := 0; i < len(segments); i++ {
+			if depth >= 0 && i > depth {
+				break
+			}
+			dir := usage[ancestor]
+			if info.IsDir() {
+				dir.Dirs++
+			} else {
+				dir.Files++
+				dir.Size += info.Size()
+			}
+			ancestor = path.Join(ancestor, segments[i])
+		}
+
+		if info.IsDir() && (depth < 0 || len(segments) <= depth) {
+			results = append(results, &DirUsage{Path: filePath})
+			usage[filePath] = results[len(results)-1]
+		}
+		return nil
+	}, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("filestore: usage by dir: %w", err)
+	}
+
+	dirs := make([]DirUsage, len(results))
+	for i, dir := range results {
+		dirs[i] = *dir
+	}
+	return dirs, nil
+}
+
+// Find returns the paths of all of the files and directories in the tree rooted at 'root' that make it
+// through the given filter (nil matches everything). Paths are relative to the store's working directory
+// just like the paths Walk gives you, and they're in the same order that Walk visits them.
+//
+// Example:
+//
+//	// Find every CSV file in the reports directory and all of its subdirectories.
+//	paths, err := filestore.Find(files, "reports", filestore.WithExt("csv"), filestore.WithMaxEntries(10000))
+func Find(fsys FS, root string, filter FileFilter, opts ...Option) ([]string, error) {
+	var results []string
+	err := Walk(fsys, root, func(filePath string, info FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filter == nil {
+			results = append(results, filePath)
+			return nil
+		}
+		matches, err := applyFilter(filter, info)
+		if err != nil {
+			return fmt.Errorf("filestore: find: %s: %w", filePath, err)
+		}
+		if matches {
+			results = append(results, filePath)
+		}
+		return nil
+	}, opts...)
+	return results, err
+}
+
+// fileID uniquely identifies a file on a Unix-like system, regardless of how many hard links
+// (paths) point to it.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// walkVisitFunc is the internal version of WalkFunc. When hardlink detection is enabled and we
+// have already visited another link to the same file, firstLink is the path of that link.
+type walkVisitFunc func(filePath string, info FileInfo, firstLink string, err error) error
+
+// walker performs a single recursive traversal of a store.
+type walker struct {
+	fs      FS
+	options walkOptions
+	links   map[fileID]string
+
+	// ancestors contains the directory we're currently in and all of its parents. When following
+	// symlinks, we never descend into one of these again since that would loop forever.
+	ancestors map[fileID]struct{}
+
+	// entries is how many files/directories we've visited so far.
+	entries int
+}
+
+func newWalker(fsys FS, opts []Option) *walker {
+	return &walker{
+		fs:        fsys,
+		options:   newOptions(opts).walk,
+		links:     map[fileID]string{},
+		ancestors: map[fileID]struct{}{},
+	}
+}
+
+func (w *walker) walkRoot(root string, fn walkVisitFunc) error {
+	info, err := w.fs.Stat(root)
+	if err != nil {
+		return fn(root, nil, "", err)
+	}
+	return w.walk(root, info, 0, fn)
+}
+
+func (w *walker) walk(filePath string, info FileInfo, depth int, fn walkVisitFunc) error {
+	w.entries++
+	if w.options.maxEntries > 0 && w.entries > w.options.maxEntries {
+		return fmt.Errorf("%w: more than %d", ErrTooManyEntries, w.options.maxEntries)
+	}
+
+	info = w.follow(filePath, info)
+	if err := fn(filePath, info, w.firstLink(filePath, info), nil); err != nil || !info.IsDir() {
+		return err
+	}
+	if w.options.maxDepth >= 0 && depth >= w.options.maxDepth {
+		return nil
+	}
+
+	if id, _, ok := sysFileID(info.Sys()); ok {
+		w.ancestors[id] = struct{}{}
+		defer delete(w.ancestors, id)
+	}
+
+	entries, err := w.fs.List(filePath)
+	if err != nil {
+		return fn(filePath, info, "", err)
+	}
+	for _, entry := range entries {
+		err = w.walk(path.Join(filePath, entry.Name()), entry, depth+1, fn)
+		switch {
+		case err == nil:
+		case errors.Is(err, fs.SkipDir) && entry.IsDir():
+			// Only skip the contents of that one directory; keep going w/ its siblings.
+		case errors.Is(err, fs.SkipDir):
+			return nil
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// firstLink returns the path of the first file we visited that is a hard link to the same file as
+// this one. It returns an empty string when this is the first time we've seen the file, detection is
+// disabled, or the store doesn't expose inodes.
+func (w *walker) firstLink(filePath string, info FileInfo) string {
+	if !w.options.hardlinks || info.IsDir() {
+		return ""
+	}
+	id, links, ok := sysFileID(info.Sys())
+	if !ok || links < 2 {
+		return ""
+	}
+	if first, seen := w.links[id]; seen {
+		return first
+	}
+	w.links[id] = filePath
+	return ""
+}
+
+// follow resolves the info for symbolic links when WithFollowSymlinks() is enabled. You get back the
+// original (link) info when we're not following links, the link is broken, or following it would
+// lead to a cycle.
+func (w *walker) follow(filePath string, info FileInfo) FileInfo {
+	if !w.options.symlinks || info.Mode()&fs.ModeSymlink == 0 {
+		return info
+	}
+	target, err := w.fs.Stat(filePath)
+	if err != nil {
+		return info
+	}
+	if id, _, ok := sysFileID(target.Sys()); ok && target.IsDir() {
+		if _, cycle := w.ancestors[id]; cycle {
+			return info
+		}
+	}
+	return target
+}
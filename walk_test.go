@@ -0,0 +1,262 @@
+package filestore_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type WalkTestSuite struct {
+	suite.Suite
+	fs filestore.FS
+}
+
+func TestWalkTestSuite(t *testing.T) {
+	suite.Run(t, &WalkTestSuite{})
+}
+
+func (s *WalkTestSuite) SetupTest() {
+	s.fs = filestore.Memory()
+	s.Require().NoError(writeString(s.fs, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.fs, "2.lebowski", "walter"))
+	s.Require().NoError(writeString(s.fs, "duderino/5.lebowski", "jackie"))
+	s.Require().NoError(writeString(s.fs, "duderino/inner/6.lebowski", "nihilist"))
+	s.Require().NoError(writeString(s.fs, "dude/7.lebowski", "bunny"))
+}
+
+// walk collects the paths visited by Walk, optionally returning an error for specific paths.
+func (s *WalkTestSuite) walk(fsys filestore.FS, root string, results map[string]error, opts ...filestore.Option) ([]string, error) {
+	var visited []string
+	err := filestore.Walk(fsys, root, func(filePath string, info filestore.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		visited = append(visited, filePath)
+		return results[filePath]
+	}, opts...)
+	return visited, err
+}
+
+func (s *WalkTestSuite) TestWalk() {
+	visited, err := s.walk(s.fs, ".", nil)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{
+		".",
+		"1.lebowski",
+		"2.lebowski",
+		"dude",
+		"dude/7.lebowski",
+		"duderino",
+		"duderino/5.lebowski",
+		"duderino/inner",
+		"duderino/inner/6.lebowski",
+	}, visited)
+
+	visited, err = s.walk(s.fs, "duderino", nil)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"duderino", "duderino/5.lebowski", "duderino/inner", "duderino/inner/6.lebowski"}, visited)
+
+	visited, err = s.walk(s.fs, "1.lebowski", nil)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"1.lebowski"}, visited, "Walking a file should only visit that file")
+}
+
+func (s *WalkTestSuite) TestWalk_skipDir() {
+	visited, err := s.walk(s.fs, ".", map[string]error{"dude": fs.SkipDir})
+	s.Require().NoError(err)
+	s.Require().NotContains(visited, "dude/7.lebowski", "Skipping a directory should skip its contents")
+	s.Require().Contains(visited, "duderino", "Skipping a directory should not skip its siblings")
+
+	visited, err = s.walk(s.fs, ".", map[string]error{"duderino/5.lebowski": fs.SkipDir})
+	s.Require().NoError(err)
+	s.Require().NotContains(visited, "duderino/inner", "Skipping from a file should skip the rest of its directory")
+
+	visited, err = s.walk(s.fs, ".", map[string]error{".": fs.SkipDir})
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"."}, visited)
+}
+
+func (s *WalkTestSuite) TestWalk_errors() {
+	failure := errors.New("the dude does not abide")
+	visited, err := s.walk(s.fs, ".", map[string]error{"dude": failure})
+	s.Require().ErrorIs(err, failure)
+	s.Require().Equal([]string{".", "1.lebowski", "2.lebowski", "dude"}, visited, "Errors should stop the walk")
+
+	_, err = s.walk(s.fs, "nope", nil)
+	s.Require().ErrorIs(err, fs.ErrNotExist, "Walking a non-existent root should pass along the error")
+}
+
+func (s *WalkTestSuite) TestDirSize() {
+	size, err := filestore.DirSize(s.fs, ".")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(4+6+6+8+5), size)
+
+	size, err = filestore.DirSize(s.fs, "duderino")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(6+8), size)
+
+	_, err = filestore.DirSize(s.fs, "nope")
+	s.Require().Error(err)
+}
+
+func (s *WalkTestSuite) TestUsageByDir() {
+	usage, err := filestore.UsageByDir(s.fs, ".", 1)
+	s.Require().NoError(err)
+	s.Require().Equal([]filestore.DirUsage{
+		{Path: ".", Size: 4 + 6 + 6 + 8 + 5, Files: 5, Dirs: 3},
+		{Path: "dude", Size: 5, Files: 1},
+		{Path: "duderino", Size: 6 + 8, Files: 2, Dirs: 1},
+	}, usage)
+
+	usage, err = filestore.UsageByDir(s.fs, "duderino", -1)
+	s.Require().NoError(err)
+	s.Require().Equal([]filestore.DirUsage{
+		{Path: "duderino", Size: 6 + 8, Files: 2, Dirs: 1},
+		{Path: "duderino/inner", Size: 8, Files: 1},
+	}, usage)
+
+	usage, err = filestore.UsageByDir(s.fs, "duderino/", 0)
+	s.Require().NoError(err)
+	s.Require().Equal([]filestore.DirUsage{{Path: "duderino", Size: 6 + 8, Files: 2, Dirs: 1}}, usage)
+
+	_, err = filestore.UsageByDir(s.fs, "1.lebowski", 1)
+	s.Require().Error(err)
+	_, err = filestore.UsageByDir(s.fs, "nope", 1)
+	s.Require().Error(err)
+}
+
+func (s *WalkTestSuite) TestHardlinks() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("Hardlink detection requires inodes")
+	}
+
+	dir := s.T().TempDir()
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "a.txt"), []byte("the dude"), 0644))
+	s.Require().NoError(os.Mkdir(filepath.Join(dir, "links"), 0755))
+	s.Require().NoError(os.Link(filepath.Join(dir, "a.txt"), filepath.Join(dir, "links", "b.txt")))
+	s.Require().NoError(os.Link(filepath.Join(dir, "a.txt"), filepath.Join(dir, "links", "c.txt")))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "z.txt"), []byte("abides"), 0644))
+	disk := filestore.Disk(dir)
+
+	size, err := filestore.DirSize(disk, ".")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(8*3+6), size, "Without detection, every link should be counted")
+
+	size, err = filestore.DirSize(disk, ".", filestore.WithHardlinkDetection())
+	s.Require().NoError(err)
+	s.Require().Equal(int64(8+6), size, "With detection, linked files should only be counted once")
+
+	visited, err := s.walk(disk, ".", nil, filestore.WithHardlinkDetection())
+	s.Require().NoError(err)
+	s.Require().Equal([]string{".", "a.txt", "links", "z.txt"}, visited)
+
+	size, err = filestore.DirSize(filestore.Memory(), ".", filestore.WithHardlinkDetection())
+	s.Require().NoError(err, "Detection should quietly do nothing for stores w/o inodes")
+	s.Require().Equal(int64(0), size)
+}
+
+func (s *WalkTestSuite) TestFollowSymlinks() {
+	if runtime.GOOS == "windows" {
+		s.T().Skip("Cycle detection requires inodes")
+	}
+
+	dir := s.T().TempDir()
+	s.Require().NoError(os.MkdirAll(filepath.Join(dir, "a", "b"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "a", "b", "file.txt"), []byte("the dude"), 0644))
+	s.Require().NoError(os.Symlink("../..", filepath.Join(dir, "a", "b", "loop")))
+	s.Require().NoError(os.Symlink(".", filepath.Join(dir, "a", "self")))
+	s.Require().NoError(os.Symlink("a", filepath.Join(dir, "c")))
+	s.Require().NoError(os.Symlink("nope", filepath.Join(dir, "broken")))
+	disk := filestore.Disk(dir)
+
+	visited, err := s.walk(disk, ".", nil)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{".", "a", "a/b", "a/b/file.txt", "a/b/loop", "a/self", "broken", "c"}, visited,
+		"Links should not be followed by default")
+
+	visited, err = s.walk(disk, ".", nil, filestore.WithFollowSymlinks())
+	s.Require().NoError(err, "Self-referencing links should not cause an infinite loop")
+	s.Require().Equal([]string{
+		".",
+		"a",
+		"a/b",
+		"a/b/file.txt",
+		"a/b/loop",
+		"a/self",
+		"broken",
+		"c",
+		"c/b",
+		"c/b/file.txt",
+		"c/b/loop",
+		"c/self",
+	}, visited, "Links to directories that aren't cycles should be followed")
+
+	size, err := filestore.DirSize(disk, ".", filestore.WithFollowSymlinks())
+	s.Require().NoError(err)
+	s.Require().Greater(size, int64(16), "Linked files should be counted as the files they point to")
+
+	dst := filestore.Memory()
+	s.Require().NoError(filestore.CopyAll(disk, ".", dst, ".", filestore.WithFollowSymlinks()))
+	content, err := readString(dst, "c/b/file.txt")
+	s.Require().NoError(err)
+	s.Require().Equal("the dude", content)
+	s.Require().False(dst.Exists("a/self"), "Cyclic links should not be copied")
+	s.Require().False(dst.Exists("broken"), "Broken links should not be copied")
+}
+
+func (s *WalkTestSuite) TestFind() {
+	paths, err := filestore.Find(s.fs, ".", filestore.WithPattern("[56].lebowski"))
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"duderino/5.lebowski", "duderino/inner/6.lebowski"}, paths)
+
+	paths, err = filestore.Find(s.fs, "dude", nil)
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"dude", "dude/7.lebowski"}, paths, "A nil filter should match everything")
+
+	_, err = filestore.Find(s.fs, "nope", nil)
+	s.Require().Error(err)
+}
+
+func (s *WalkTestSuite) TestMaxDepth() {
+	visited, err := s.walk(s.fs, ".", nil, filestore.WithMaxDepth(0))
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"."}, visited)
+
+	visited, err = s.walk(s.fs, ".", nil, filestore.WithMaxDepth(1))
+	s.Require().NoError(err)
+	s.Require().Equal([]string{".", "1.lebowski", "2.lebowski", "dude", "duderino"}, visited)
+
+	paths, err := filestore.Find(s.fs, "duderino", filestore.WithExt("lebowski"), filestore.WithMaxDepth(1))
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"duderino/5.lebowski"}, paths, "Depth should be relative to the root")
+
+	dst := filestore.Memory()
+	s.Require().NoError(filestore.CopyAll(s.fs, ".", dst, ".", filestore.WithMaxDepth(1)))
+	s.Require().True(dst.Exists("1.lebowski"))
+	s.Require().False(dst.Exists("duderino"), "Files deeper than the limit should not be copied")
+}
+
+func (s *WalkTestSuite) TestMaxEntries() {
+	visited, err := s.walk(s.fs, ".", nil, filestore.WithMaxEntries(9))
+	s.Require().NoError(err, "Visiting exactly the limit should be fine")
+	s.Require().Equal(9, len(visited))
+
+	visited, err = s.walk(s.fs, ".", nil, filestore.WithMaxEntries(4))
+	s.Require().ErrorIs(err, filestore.ErrTooManyEntries)
+	s.Require().Equal([]string{".", "1.lebowski", "2.lebowski", "dude"}, visited)
+
+	_, err = filestore.Find(s.fs, ".", filestore.WithExt("txt"), filestore.WithMaxEntries(3))
+	s.Require().ErrorIs(err, filestore.ErrTooManyEntries, "Entries should count even if the filter rejects them")
+
+	_, err = filestore.DirSize(s.fs, ".", filestore.WithMaxEntries(3))
+	s.Require().ErrorIs(err, filestore.ErrTooManyEntries)
+
+	err = filestore.CopyAll(s.fs, ".", filestore.Memory(), ".", filestore.WithMaxEntries(3))
+	s.Require().ErrorIs(err, filestore.ErrTooManyEntries)
+}
@@ -0,0 +1,171 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// EventOp describes what happened to a file/directory in an Event.
+type EventOp int
+
+const (
+	// EventCreate means that the file/directory was created (or moved into the watched directory
+	// from somewhere we weren't watching).
+	EventCreate EventOp = iota + 1
+	// EventWrite means that someone finished writing new data to the file.
+	EventWrite
+	// EventRemove means that the file/directory was removed (or moved somewhere we're not watching).
+	EventRemove
+	// EventMove means that the file/directory was renamed/moved from OldPath to Path.
+	EventMove
+	// EventOverflow means that changes happened faster than we could keep up w/ (e.g. the kernel's
+	// inotify queue overflowed), so some events were lost. Path is the watched directory; rescan it to
+	// find out what changed.
+	EventOverflow
+)
+
+func (op EventOp) String() string {
+	switch op {
+	case EventCreate:
+		return "CREATE"
+	case EventWrite:
+		return "WRITE"
+	case EventRemove:
+		return "REMOVE"
+	case EventMove:
+		return "MOVE"
+	case EventOverflow:
+		return "OVERFLOW"
+	default:
+		return fmt.Sprintf("EventOp(%d)", int(op))
+	}
+}
+
+// Event describes a single change to a file/directory in a watched directory.
+type Event struct {
+	// Op is what happened to the file/directory.
+	Op EventOp
+	// Path is the file/directory that changed, relative to the store's working directory (i.e. the
+	// same path you'd pass to Stat). For moves, this is the new path.
+	Path string
+	// OldPath is where the file/directory was before it moved. It's only set for EventMove.
+	OldPath string
+	// Dir is true when the event is about a directory rather than a file.
+	Dir bool
+}
+
+func (e Event) String() string {
+	if e.Op == EventMove {
+		return fmt.Sprintf("%s %s -> %s", e.Op, e.OldPath, e.Path)
+	}
+	return fmt.Sprintf("%s %s", e.Op, e.Path)
+}
+
+// Remap tells you where something ended up after this move. If the path is the OldPath of this move
+// event or anything inside of it (when a directory moved), you get its new path and true. For any
+// other path (or event that isn't a move), you get the original path and false. This lets you update
+// everything you know about a directory's contents from a single EventMove.
+//
+// Example:
+//
+//	// Event: MOVE inbox/2022 -> archive/2022
+//	newPath, moved := event.Remap("inbox/2022/09/report.pdf") // "archive/2022/09/report.pdf", true
+func (e Event) Remap(filePath string) (string, bool) {
+	if e.Op != EventMove {
+		return filePath, false
+	}
+	cleanPath := path.Clean(filePath)
+	switch {
+	case cleanPath == e.OldPath:
+		return e.Path, true
+	case e.Dir && strings.HasPrefix(cleanPath, e.OldPath+"/"):
+		return path.Join(e.Path, strings.TrimPrefix(cleanPath, e.OldPath+"/")), true
+	default:
+		return filePath, false
+	}
+}
+
+// watchOptions contains the settings that only apply to Watch().
+type watchOptions struct {
+	recursive bool
+	warn      func(dir string, err error)
+}
+
+// WithRecursive makes Watch() report changes anywhere inside of the watched directory rather than just
+// its immediate children. Subdirectories created (or moved in) while you're watching are picked up
+// automatically, and you get an EventCreate for anything that was already inside of them by the time
+// we started watching them, so files dropped into a brand new directory aren't missed. The flip side
+// is that something created at the exact moment we start watching its directory may be announced twice.
+//
+// Example:
+//
+//	events, err := filestore.Watch(ctx, files, "uploads", filestore.WithRecursive())
+func WithRecursive() Option {
+	return func(opts *options) {
+		opts.watch.recursive = true
+	}
+}
+
+// WithWatchWarning lets you know when Watch() can't natively watch a directory (e.g. because you've hit
+// the system's limit on inotify watches) and falls back to polling it instead. You still get events
+// for everything in that directory, just not as quickly, so this is your cue to raise the limit. The
+// callback is given the directory (relative to the store's working directory) and the reason.
+//
+// Example:
+//
+//	warn := func(dir string, err error) {
+//	    log.Printf("polling %s: %v (consider raising fs.inotify.max_user_watches)", dir, err)
+//	}
+//	events, err := filestore.Watch(ctx, files, "uploads", filestore.WithRecursive(), filestore.WithWatchWarning(warn))
+func WithWatchWarning(fn func(dir string, err error)) Option {
+	return func(opts *options) {
+		if fn != nil {
+			opts.watch.warn = fn
+		}
+	}
+}
+
+// Watcher is an optional capability for stores that can notify you when files change.
+type Watcher interface {
+	// Watch starts delivering events for changes in the given directory until the context is
+	// cancelled, at which point the channel is closed.
+	Watch(ctx context.Context, dir string, opts ...Option) (<-chan Event, error)
+}
+
+// ErrWatchNotSupported is the error returned by Watch() when the store does not implement the
+// Watcher capability.
+var ErrWatchNotSupported = errors.New("filestore: watch not supported")
+
+// Watch delivers an Event for each change to the files in the given directory if the store supports
+// the Watcher capability (e.g. DiskFS on Linux). For all other stores, this fails w/ ErrWatchNotSupported;
+// use Poll() for those instead.
+// Events keep coming until you cancel the context, at which point the channel is closed. The channel
+// is also closed if the directory itself is removed. By default, only changes to the directory's
+// immediate children are reported; use WithRecursive() to watch the entire tree.
+//
+// Renames are reported as a single EventMove w/ both the old and new paths, even for directories, so
+// you don't have to stitch remove/create pairs back together. Use Event.Remap() to find the new
+// location of anything that was inside a moved directory. When something moves in from (or out to) a
+// directory you're not watching, you get an EventCreate (or EventRemove) instead.
+//
+// If changes pile up faster than you read them, the kernel may drop some. You get an EventOverflow
+// when that happens so you can rescan the directory rather than trusting an incomplete history.
+//
+// Example:
+//
+//	events, err := filestore.Watch(ctx, files, "inbox")
+//	if err != nil {
+//	    // handle error
+//	}
+//	for event := range events {
+//	    fmt.Println(event) // e.g. "MOVE inbox/a.txt -> inbox/b.txt"
+//	}
+func Watch(ctx context.Context, fsys FS, dir string, opts ...Option) (<-chan Event, error) {
+	if watcher, ok := fsys.(Watcher); ok {
+		return watcher.Watch(ctx, dir, opts...)
+	}
+	return nil, fmt.Errorf("filestore: watch: %s: %w", dir, ErrWatchNotSupported)
+}
@@ -0,0 +1,466 @@
+//go:build linux
+
+package filestore
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask is the set of inotify events that we translate into Events.
+const inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR
+
+// inotifyMoveWindow is how long we wait for the IN_MOVED_TO half of a rename before deciding that the
+// file moved somewhere we aren't watching. The kernel queues both halves back-to-back, so we only need
+// this when the IN_MOVED_FROM is the last event we've read so far.
+const inotifyMoveWindow = 50 * time.Millisecond
+
+// inotifyAddWatch is unix.InotifyAddWatch; tests swap it out to simulate hitting the watch limit.
+var inotifyAddWatch = unix.InotifyAddWatch
+
+// Watch uses inotify(7) to deliver an Event for each change to the files in the given directory. Since
+// inotify itself isn't recursive, WithRecursive() adds a watch for every subdirectory up front and
+// for each new one as soon as we hear about it. See the package-level Watch() for more details.
+//
+// The kernel limits how many watches (fs.inotify.max_user_watches) and inotify instances each user can
+// have, and big trees can easily hit those limits. Rather than quietly missing events, we fall back to
+// polling (see Poll) any directory that we can't watch along w/ everything inside of it, and let you
+// know via the WithWatchWarning() callback.
+func (d DiskFS) Watch(ctx context.Context, dir string, opts ...Option) (<-chan Event, error) {
+	options := newOptions(opts)
+	fullPath, err := resolvePath(d.basePath, dir)
+	if err != nil {
+		return nil, newPathError("disk", "watch", dir, err)
+	}
+
+	// A non-blocking descriptor lets the os.File use the runtime's poller, so closing the file
+	// interrupts a pending Read().
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if errors.Is(err, unix.EMFILE) {
+		return d.fallBackToPolling(ctx, dir, os.NewSyscallError("inotify_init1", err), opts)
+	}
+	if err != nil {
+		return nil, newPathError("disk", "watch", dir, os.NewSyscallError("inotify_init1", err))
+	}
+	wd, err := inotifyAddWatch(fd, fullPath, inotifyMask)
+	if errors.Is(err, unix.ENOSPC) {
+		_ = unix.Close(fd)
+		return d.fallBackToPolling(ctx, dir, &fs.PathError{Op: "inotify_add_watch", Path: fullPath, Err: err}, opts)
+	}
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, newPathError("disk", "watch", dir, &fs.PathError{Op: "inotify_add_watch", Path: fullPath, Err: err})
+	}
+
+	dirPath := path.Clean(filepath.ToSlash(dir))
+	pollCtx, cancelPolls := context.WithCancel(ctx)
+	watcher := &inotifyWatcher{
+		fd:          fd,
+		file:        os.NewFile(uintptr(fd), "inotify"),
+		fs:          d,
+		opts:        opts,
+		options:     options,
+		basePath:    d.basePath,
+		root:        wd,
+		recursive:   options.watch.recursive,
+		dirs:        map[int]string{wd: dirPath},
+		events:      make(chan Event, 64),
+		pollCtx:     pollCtx,
+		cancelPolls: cancelPolls,
+		polled:      map[string]context.CancelFunc{},
+		forwarded:   make(chan Event),
+	}
+	if watcher.recursive {
+		if err := watcher.watchTree(ctx, dirPath, false); err != nil {
+			cancelPolls()
+			_ = watcher.file.Close()
+			return nil, newPathError("disk", "watch", dir, err)
+		}
+	}
+	go watcher.run(ctx)
+	return watcher.events, nil
+}
+
+// fallBackToPolling polls the entire directory when we can't use inotify at all.
+func (d DiskFS) fallBackToPolling(ctx context.Context, dir string, cause error, opts []Option) (<-chan Event, error) {
+	newOptions(opts).watch.warn(path.Clean(filepath.ToSlash(dir)), cause)
+	events, err := Poll(ctx, d, dir, opts...)
+	if err != nil {
+		return nil, newPathError("disk", "watch", dir, err)
+	}
+	return events, nil
+}
+
+// inotifyWatcher translates the raw inotify events for a watched directory (and its subdirectories when
+// recursive) into Events.
+type inotifyWatcher struct {
+	fd        int
+	file      *os.File
+	fs        FS
+	opts      []Option
+	options   options
+	basePath  string
+	root      int
+	recursive bool
+	events    chan Event
+
+	// dirs maps each watch descriptor to the path of the directory it watches, relative to the store's
+	// working directory.
+	dirs map[int]string
+
+	// pending is the IN_MOVED_FROM half of a rename that we haven't seen the IN_MOVED_TO for yet.
+	pending *inotifyEvent
+
+	// polled contains the directories that we poll because we ran out of inotify watches, along w/
+	// the function that stops polling each one. Their events arrive on the forwarded channel.
+	polled      map[string]context.CancelFunc
+	forwarded   chan Event
+	pollCtx     context.Context
+	cancelPolls context.CancelFunc
+}
+
+// inotifyEvent is a single decoded inotify_event structure.
+type inotifyEvent struct {
+	wd       int
+	mask     uint32
+	cookie   uint32
+	name     string
+	received time.Time
+}
+
+func (w *inotifyWatcher) run(ctx context.Context) {
+	done := make(chan struct{})
+	defer close(w.events)
+	defer w.file.Close()
+	defer w.cancelPolls()
+	defer close(done)
+
+	batches := make(chan []inotifyEvent)
+	go w.read(batches, done)
+
+	var flush <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case batch, ok := <-batches:
+			if !ok {
+				return
+			}
+			for _, event := range batch {
+				if !w.handle(ctx, event) {
+					return
+				}
+			}
+		case <-flush:
+			if !w.flushPending(ctx) {
+				return
+			}
+		case event := <-w.forwarded:
+			if !w.emit(ctx, event) {
+				return
+			}
+		}
+
+		flush = nil
+		if w.pending != nil {
+			flush = time.After(time.Until(w.pending.received.Add(inotifyMoveWindow)))
+		}
+	}
+}
+
+// read decodes batches of raw events from the inotify descriptor until it's closed.
+func (w *inotifyWatcher) read(batches chan<- []inotifyEvent, done <-chan struct{}) {
+	defer close(batches)
+
+	buffer := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		var batch []inotifyEvent
+		now := time.Now()
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			nameEnd := nameStart + int(raw.Len)
+			batch = append(batch, inotifyEvent{
+				wd:       int(raw.Wd),
+				mask:     raw.Mask,
+				cookie:   raw.Cookie,
+				name:     strings.TrimRight(string(buffer[nameStart:nameEnd]), "\x00"),
+				received: now,
+			})
+			offset = nameEnd
+		}
+
+		select {
+		case batches <- batch:
+		case <-done:
+			return
+		}
+	}
+}
+
+// handle translates a single raw event, returning false when we should stop watching.
+func (w *inotifyWatcher) handle(ctx context.Context, event inotifyEvent) bool {
+	// The only thing that can legitimately separate the two halves of a rename is the other half.
+	if w.pending != nil && !(event.mask&unix.IN_MOVED_TO != 0 && event.cookie == w.pending.cookie) {
+		if !w.flushPending(ctx) {
+			return false
+		}
+	}
+
+	if event.mask&unix.IN_Q_OVERFLOW != 0 {
+		return w.overflowed(ctx)
+	}
+	if event.wd == w.root && event.mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF|unix.IN_IGNORED) != 0 {
+		return false
+	}
+	if event.mask&unix.IN_IGNORED != 0 {
+		delete(w.dirs, event.wd)
+		return true
+	}
+	dir, ok := w.dirs[event.wd]
+	if !ok || event.name == "" {
+		return true
+	}
+
+	filePath := path.Join(dir, event.name)
+	isDir := event.mask&unix.IN_ISDIR != 0
+	switch {
+	case event.mask&unix.IN_CREATE != 0:
+		return w.created(ctx, Event{Op: EventCreate, Path: filePath, Dir: isDir})
+	case event.mask&unix.IN_CLOSE_WRITE != 0:
+		return w.emit(ctx, Event{Op: EventWrite, Path: filePath})
+	case event.mask&unix.IN_DELETE != 0:
+		if isDir {
+			w.stopPolling(filePath)
+		}
+		return w.emit(ctx, Event{Op: EventRemove, Path: filePath, Dir: isDir})
+	case event.mask&unix.IN_MOVED_FROM != 0:
+		event.name = filePath
+		w.pending = &event
+		return true
+	case event.mask&unix.IN_MOVED_TO != 0:
+		if w.pending == nil {
+			return w.created(ctx, Event{Op: EventCreate, Path: filePath, Dir: isDir})
+		}
+		oldPath := w.pending.name
+		w.pending = nil
+		if isDir {
+			// The kernel keeps watching the directories that moved, so we only need to update their paths.
+			w.renameTree(oldPath, filePath)
+		}
+		return w.emit(ctx, Event{Op: EventMove, Path: filePath, OldPath: oldPath, Dir: isDir})
+	default:
+		return true
+	}
+}
+
+// flushPending reports a rename whose other half never arrived as a removal since the file moved
+// somewhere that we're not watching.
+func (w *inotifyWatcher) flushPending(ctx context.Context) bool {
+	if w.pending == nil {
+		return true
+	}
+	event := Event{Op: EventRemove, Path: w.pending.name, Dir: w.pending.mask&unix.IN_ISDIR != 0}
+	w.pending = nil
+	if event.Dir {
+		w.unwatchTree(event.Path)
+	}
+	return w.emit(ctx, event)
+}
+
+// overflowed handles the kernel dropping events because its queue filled up (the overflow event has
+// no watch descriptor, so it never matches a directory). We can't know what we missed, so we tell you
+// to rescan the whole tree. When recursive, we also start watching any directories created while
+// events were being dropped. It returns false when we should stop watching.
+func (w *inotifyWatcher) overflowed(ctx context.Context) bool {
+	root := w.dirs[w.root]
+	if w.recursive {
+		// Directories that we still can't watch are no worse off than before the overflow.
+		_ = w.watchTree(ctx, root, false)
+	}
+	return w.emit(ctx, Event{Op: EventOverflow, Path: root, Dir: true})
+}
+
+// created announces a new file/directory. When we're watching recursively, we also start watching new
+// directories (and announce anything that's already inside of them). It returns false when we should
+// stop watching.
+func (w *inotifyWatcher) created(ctx context.Context, event Event) bool {
+	if !w.recursive || !event.Dir {
+		return w.emit(ctx, event)
+	}
+	// Anything else that goes wrong only affects this directory, so keep watching everything else.
+	return w.watchTree(ctx, event.Path, true) != errWatchStopped
+}
+
+// errWatchStopped tells watchTree()'s callers that the context was cancelled while announcing files.
+var errWatchStopped = errors.New("watch stopped")
+
+// watchTree adds a watch for the directory and every directory inside of it. Files and directories
+// can be created inside of a new directory before we start watching it, so when announce is true, we
+// emit an EventCreate for the directory and everything we find inside of it.
+func (w *inotifyWatcher) watchTree(ctx context.Context, dirPath string, announce bool) error {
+	fullPath, err := resolvePath(w.basePath, dirPath)
+	if err != nil {
+		return err
+	}
+
+	var watchErr error
+	walkErr := filepath.WalkDir(fullPath, func(entryPath string, entry fs.DirEntry, err error) error {
+		// WalkDir calls us a second time for directories that it fails to read, but we already
+		// announced those the first time around.
+		if err != nil && entry != nil {
+			return nil
+		}
+
+		relPath, _ := filepath.Rel(fullPath, entryPath)
+		filePath := path.Join(dirPath, filepath.ToSlash(relPath))
+		isDir := entry == nil || entry.IsDir()
+
+		// Start watching a directory before we announce it so that we don't miss anything that
+		// you create in response to the event.
+		// The root is always watched already.
+		if err == nil && isDir && filePath != w.dirs[w.root] && !w.isPolled(filePath) {
+			err = w.addWatch(entryPath, filePath)
+			if errors.Is(err, unix.ENOSPC) {
+				err = w.fallBackToPolling(filePath, err)
+			}
+		}
+		switch {
+		case errors.Is(err, fs.ErrNotExist) || errors.Is(err, unix.ENOTDIR):
+			// Uploaders may remove things just as quickly as they create them. We'll hear about that next.
+		case err != nil && watchErr == nil:
+			watchErr = err
+		}
+
+		if announce && !w.emit(ctx, Event{Op: EventCreate, Path: filePath, Dir: isDir}) {
+			return errWatchStopped
+		}
+		if err != nil && isDir {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+	return watchErr
+}
+
+// addWatch starts watching the directory at the given absolute path on disk.
+func (w *inotifyWatcher) addWatch(fullPath string, dirPath string) error {
+	wd, err := inotifyAddWatch(w.fd, fullPath, inotifyMask)
+	if err != nil {
+		return &fs.PathError{Op: "inotify_add_watch", Path: fullPath, Err: err}
+	}
+	if wd != w.root {
+		w.dirs[wd] = dirPath
+	}
+	return nil
+}
+
+// renameTree updates the paths of the directory that moved and all of the directories inside of it.
+func (w *inotifyWatcher) renameTree(oldPath string, newPath string) {
+	for wd, dir := range w.dirs {
+		if wd != w.root && isWithinPath(oldPath, dir) {
+			w.dirs[wd] = newPath + strings.TrimPrefix(dir, oldPath)
+		}
+	}
+
+	// Pollers only know the path they started w/, so start over at the new one.
+	for dir, stop := range w.polled {
+		if isWithinPath(oldPath, dir) {
+			stop()
+			delete(w.polled, dir)
+			_ = w.poll(newPath + strings.TrimPrefix(dir, oldPath))
+		}
+	}
+}
+
+// unwatchTree stops watching the directory and all of the directories inside of it since they moved
+// somewhere that we're not watching.
+func (w *inotifyWatcher) unwatchTree(dirPath string) {
+	for wd, dir := range w.dirs {
+		if wd != w.root && isWithinPath(dirPath, dir) {
+			_, _ = unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+		}
+	}
+	w.stopPolling(dirPath)
+}
+
+// fallBackToPolling polls the directory (and everything inside of it) because we've run out of inotify
+// watches, letting you know via the WithWatchWarning() callback.
+func (w *inotifyWatcher) fallBackToPolling(dirPath string, cause error) error {
+	w.options.watch.warn(dirPath, cause)
+	return w.poll(dirPath)
+}
+
+// poll starts polling the directory, forwarding its events to our channel.
+func (w *inotifyWatcher) poll(dirPath string) error {
+	ctx, stop := context.WithCancel(w.pollCtx)
+	opts := append(w.opts[:len(w.opts):len(w.opts)], WithRecursive())
+	events, err := Poll(ctx, w.fs, dirPath, opts...)
+	if err != nil {
+		stop()
+		return err
+	}
+	w.polled[dirPath] = stop
+
+	go func() {
+		for event := range events {
+			select {
+			case w.forwarded <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// isPolled returns true when the directory is inside of one that we're already polling.
+func (w *inotifyWatcher) isPolled(dirPath string) bool {
+	for dir := range w.polled {
+		if isWithinPath(dir, dirPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// stopPolling stops polling the directory and any directories inside of it.
+func (w *inotifyWatcher) stopPolling(dirPath string) {
+	for dir, stop := range w.polled {
+		if isWithinPath(dirPath, dir) {
+			stop()
+			delete(w.polled, dir)
+		}
+	}
+}
+
+func (w *inotifyWatcher) emit(ctx context.Context, event Event) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+var _ Watcher = DiskFS{}
@@ -0,0 +1,279 @@
+//go:build linux
+
+package filestore_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"golang.org/x/sys/unix"
+)
+
+func (s *WatchTestSuite) TestWatch_disk() {
+	dir := s.T().TempDir()
+	outside := s.T().TempDir()
+	store := filestore.Disk(dir)
+	s.Require().NoError(os.MkdirAll(filepath.Join(dir, "inbox/2022/09"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, "inbox/2022/09/report.pdf"), []byte("abide"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := filestore.Watch(ctx, store, "inbox")
+	s.Require().NoError(err)
+
+	s.Require().NoError(writeString(store, "inbox/a.txt", "abide"))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/a.txt"})
+	s.assertEvent(events, filestore.Event{Op: filestore.EventWrite, Path: "inbox/a.txt"})
+
+	s.Require().NoError(store.Move("inbox/a.txt", "inbox/b.txt"))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventMove, OldPath: "inbox/a.txt", Path: "inbox/b.txt"})
+
+	// Moving a whole directory should be a single event, not one per file.
+	s.Require().NoError(store.Move("inbox/2022", "inbox/archive"))
+	move := s.assertEvent(events, filestore.Event{Op: filestore.EventMove, OldPath: "inbox/2022", Path: "inbox/archive", Dir: true})
+	remapped, _ := move.Remap("inbox/2022/09/report.pdf")
+	s.Require().True(store.Exists(remapped))
+
+	// Moving out of (or into) the watched directory is a remove (or create).
+	s.Require().NoError(os.Rename(filepath.Join(dir, "inbox/b.txt"), filepath.Join(outside, "b.txt")))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventRemove, Path: "inbox/b.txt"})
+	s.Require().NoError(os.Rename(filepath.Join(outside, "b.txt"), filepath.Join(dir, "inbox/c.txt")))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/c.txt"})
+
+	s.Require().NoError(store.Remove("inbox/c.txt"))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventRemove, Path: "inbox/c.txt"})
+
+	cancel()
+	s.assertClosed(events)
+}
+
+func (s *WatchTestSuite) TestWatch_diskRemoved() {
+	dir := s.T().TempDir()
+	store := filestore.Disk(dir)
+	s.Require().NoError(os.MkdirAll(filepath.Join(dir, "inbox"), 0755))
+
+	events, err := filestore.Watch(context.Background(), store, "inbox")
+	s.Require().NoError(err)
+	s.Require().NoError(os.Remove(filepath.Join(dir, "inbox")))
+	s.assertClosed(events)
+
+	_, err = filestore.Watch(context.Background(), store, "nope")
+	s.Require().ErrorIs(err, fs.ErrNotExist)
+}
+
+func (s *WatchTestSuite) TestWatch_diskRecursive() {
+	dir := s.T().TempDir()
+	outside := s.T().TempDir()
+	store := filestore.Disk(dir)
+	s.Require().NoError(os.MkdirAll(filepath.Join(dir, "inbox/existing/deep"), 0755))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := filestore.Watch(ctx, store, "inbox", filestore.WithRecursive())
+	s.Require().NoError(err)
+
+	s.Require().NoError(writeString(store, "inbox/existing/deep/a.txt", "abide"))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/existing/deep/a.txt"})
+	s.assertEvent(events, filestore.Event{Op: filestore.EventWrite, Path: "inbox/existing/deep/a.txt"})
+
+	// New directories should be watched as soon as we announce them.
+	s.Require().NoError(os.Mkdir(filepath.Join(dir, "inbox/new"), 0755))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/new", Dir: true})
+	s.Require().NoError(writeString(store, "inbox/new/b.txt", "abide"))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/new/b.txt"})
+	s.assertEvent(events, filestore.Event{Op: filestore.EventWrite, Path: "inbox/new/b.txt"})
+
+	// Anything already inside of a directory that shows up should be announced, too.
+	s.Require().NoError(os.MkdirAll(filepath.Join(outside, "upload/2022"), 0755))
+	s.Require().NoError(os.WriteFile(filepath.Join(outside, "upload/2022/c.txt"), []byte("abide"), 0644))
+	s.Require().NoError(os.Rename(filepath.Join(outside, "upload"), filepath.Join(dir, "inbox/upload")))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/upload", Dir: true})
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/upload/2022", Dir: true})
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/upload/2022/c.txt"})
+
+	// Directories that move within the tree keep reporting changes under their new path.
+	s.Require().NoError(store.Move("inbox/upload", "inbox/existing/upload"))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventMove, OldPath: "inbox/upload", Path: "inbox/existing/upload", Dir: true})
+	s.Require().NoError(store.Remove("inbox/existing/upload/2022/c.txt"))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventRemove, Path: "inbox/existing/upload/2022/c.txt"})
+
+	// Directories that move out of the tree are no longer watched.
+	s.Require().NoError(os.Rename(filepath.Join(dir, "inbox/new"), filepath.Join(outside, "new")))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventRemove, Path: "inbox/new", Dir: true})
+	s.Require().NoError(os.WriteFile(filepath.Join(outside, "new/ignored.txt"), []byte("abide"), 0644))
+	s.Require().NoError(writeString(store, "inbox/d.txt", "abide"))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/d.txt"})
+
+	cancel()
+	s.assertClosed(events)
+}
+
+func (s *WatchTestSuite) TestWatch_diskWatchLimit() {
+	dir := s.T().TempDir()
+	store := filestore.Disk(dir)
+	s.Require().NoError(os.MkdirAll(filepath.Join(dir, "inbox/a"), 0755))
+	s.Require().NoError(os.MkdirAll(filepath.Join(dir, "inbox/b/deep"), 0755))
+
+	// Only "inbox" and "inbox/a" get real watches.
+	restore := filestore.LimitInotifyWatches(2)
+	defer restore()
+
+	clock := filestoretest.NewClock(time.Now())
+	var warnings []string
+	warn := func(dir string, err error) {
+		s.Require().ErrorIs(err, unix.ENOSPC)
+		warnings = append(warnings, dir)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := filestore.Watch(ctx, store, "inbox",
+		filestore.WithRecursive(),
+		filestore.WithWatchWarning(warn),
+		filestore.WithClock(clock),
+		filestore.WithPollInterval(time.Minute))
+	s.Require().NoError(err)
+	s.Require().Equal([]string{"inbox/b"}, warnings)
+
+	s.Require().NoError(writeString(store, "inbox/a/1.txt", "abide"))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/a/1.txt"})
+	s.assertEvent(events, filestore.Event{Op: filestore.EventWrite, Path: "inbox/a/1.txt"})
+
+	// Changes inside of the polled directory show up on the next pass.
+	s.Require().NoError(writeString(store, "inbox/b/deep/2.txt", "abide"))
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/b/deep/2.txt"})
+	s.assertEvent(events, filestore.Event{Op: filestore.EventWrite, Path: "inbox/b/deep/2.txt"})
+
+	// New directories are polled, too, once we're out of watches.
+	s.Require().NoError(os.Mkdir(filepath.Join(dir, "inbox/c"), 0755))
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/c", Dir: true})
+	s.Require().Equal([]string{"inbox/b", "inbox/c"}, warnings)
+	s.Require().NoError(writeString(store, "inbox/c/3.txt", "abide"))
+	clock.BlockUntil(2)
+	clock.Advance(time.Minute)
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/c/3.txt"})
+	s.assertEvent(events, filestore.Event{Op: filestore.EventWrite, Path: "inbox/c/3.txt"})
+
+	cancel()
+	s.assertClosed(events)
+}
+
+func (s *WatchTestSuite) TestWatch_diskWatchLimitRoot() {
+	dir := s.T().TempDir()
+	store := filestore.Disk(dir)
+	s.Require().NoError(os.MkdirAll(filepath.Join(dir, "inbox"), 0755))
+
+	restore := filestore.LimitInotifyWatches(0)
+	defer restore()
+
+	clock := filestoretest.NewClock(time.Now())
+	warned := false
+	warn := func(dir string, err error) {
+		s.Require().Equal("inbox", dir)
+		warned = true
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := filestore.Watch(ctx, store, "inbox", filestore.WithWatchWarning(warn), filestore.WithClock(clock))
+	s.Require().NoError(err)
+	s.Require().True(warned)
+
+	s.Require().NoError(writeString(store, "inbox/a.txt", "abide"))
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	s.assertEvent(events, filestore.Event{Op: filestore.EventCreate, Path: "inbox/a.txt"})
+	s.assertEvent(events, filestore.Event{Op: filestore.EventWrite, Path: "inbox/a.txt"})
+}
+
+func (s *WatchTestSuite) TestWatch_diskOverflow() {
+	limit, err := os.ReadFile("/proc/sys/fs/inotify/max_queued_events")
+	if err != nil {
+		s.T().Skip("Can't read the inotify queue limit:", err)
+	}
+	queued, err := strconv.Atoi(strings.TrimSpace(string(limit)))
+	s.Require().NoError(err)
+
+	dir := s.T().TempDir()
+	store := filestore.Disk(dir)
+	s.Require().NoError(os.MkdirAll(filepath.Join(dir, "inbox"), 0755))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := filestore.Watch(ctx, store, "inbox", filestore.WithRecursive())
+	s.Require().NoError(err)
+
+	// We're not reading any events yet, so each file's CREATE+WRITE piles up in the kernel's queue
+	// (give or take the few that the watcher buffers itself).
+	for i := 0; i < queued; i++ {
+		file, err := os.Create(filepath.Join(dir, "inbox", strconv.Itoa(i)+".txt"))
+		s.Require().NoError(err)
+		s.Require().NoError(file.Close())
+	}
+	s.Require().NoError(os.Mkdir(filepath.Join(dir, "inbox/late"), 0755))
+
+	timeout := time.After(10 * time.Second)
+	for overflowed := false; !overflowed; {
+		select {
+		case event, ok := <-events:
+			s.Require().True(ok, "Channel closed while waiting for the overflow")
+			overflowed = event.Op == filestore.EventOverflow
+			if overflowed {
+				s.Require().Equal(filestore.Event{Op: filestore.EventOverflow, Path: "inbox", Dir: true}, event)
+			}
+		case <-timeout:
+			s.Require().Fail("Timed out waiting for the overflow")
+		}
+	}
+
+	// Directories created while events were dropped are still watched afterwards.
+	s.Require().NoError(writeString(store, "inbox/late/a.txt", "abide"))
+	for {
+		select {
+		case event := <-events:
+			if event.Path == "inbox/late/a.txt" {
+				s.Require().Equal(filestore.EventCreate, event.Op)
+				return
+			}
+		case <-timeout:
+			s.Require().Fail("Timed out waiting for inbox/late/a.txt")
+			return
+		}
+	}
+}
+
+func (s *WatchTestSuite) assertEvent(events <-chan filestore.Event, expected filestore.Event) filestore.Event {
+	select {
+	case event, ok := <-events:
+		s.Require().True(ok, "Channel closed while waiting for %v", expected)
+		s.Require().Equal(expected, event)
+		return event
+	case <-time.After(5 * time.Second):
+		s.Require().Fail("Timed out waiting for event", "%v", expected)
+		return filestore.Event{}
+	}
+}
+
+func (s *WatchTestSuite) assertClosed(events <-chan filestore.Event) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			s.Require().Fail("Timed out waiting for the channel to close")
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package filestore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type WatchTestSuite struct {
+	suite.Suite
+}
+
+func TestWatchTestSuite(t *testing.T) {
+	suite.Run(t, &WatchTestSuite{})
+}
+
+func (s *WatchTestSuite) TestWatch_notSupported() {
+	_, err := filestore.Watch(context.Background(), filestore.Memory(), ".")
+	s.Require().ErrorIs(err, filestore.ErrWatchNotSupported)
+}
+
+func (s *WatchTestSuite) TestEventRemap() {
+	move := filestore.Event{Op: filestore.EventMove, OldPath: "inbox/2022", Path: "archive/2022", Dir: true}
+	s.assertRemap(move, "inbox/2022", "archive/2022", true)
+	s.assertRemap(move, "inbox/2022/09/report.pdf", "archive/2022/09/report.pdf", true)
+	s.assertRemap(move, "inbox/2022/", "archive/2022", true)
+	s.assertRemap(move, "inbox/2022-old/report.pdf", "inbox/2022-old/report.pdf", false)
+	s.assertRemap(move, "inbox/report.pdf", "inbox/report.pdf", false)
+
+	fileMove := filestore.Event{Op: filestore.EventMove, OldPath: "a.txt", Path: "b.txt"}
+	s.assertRemap(fileMove, "a.txt", "b.txt", true)
+	s.assertRemap(fileMove, "a.txt/nope", "a.txt/nope", false)
+
+	create := filestore.Event{Op: filestore.EventCreate, Path: "a.txt"}
+	s.assertRemap(create, "a.txt", "a.txt", false)
+}
+
+func (s *WatchTestSuite) TestEventString() {
+	s.Require().Equal("CREATE a.txt", filestore.Event{Op: filestore.EventCreate, Path: "a.txt"}.String())
+	s.Require().Equal("MOVE a.txt -> b.txt", filestore.Event{Op: filestore.EventMove, OldPath: "a.txt", Path: "b.txt"}.String())
+	s.Require().Equal("EventOp(42)", filestore.EventOp(42).String())
+}
+
+func (s *WatchTestSuite) assertRemap(event filestore.Event, filePath string, expected string, expectedMoved bool) {
+	remapped, moved := event.Remap(filePath)
+	s.Require().Equal(expected, remapped, filePath)
+	s.Require().Equal(expectedMoved, moved, filePath)
+}
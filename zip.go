@@ -0,0 +1,140 @@
+package filestore
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"os"
+	"path"
+	"sync"
+)
+
+// ZipReader opens the .zip file at the given path on the local disk, presenting its contents as a
+// read-only file store. Directories in the archive behave just like directories on disk, so you can
+// List(), Stat(), and ChangeDirectory() as usual, while Write(), Remove(), and Move() fail with
+// ErrReadOnly. Files are read directly from the archive; nothing is extracted to disk. Compressed files
+// are decompressed as you read them rather than up front, so seeking backwards within them (or ReadAt()
+// anywhere but the current offset) means decompressing the file from the beginning again.
+//
+// The store holds the archive open until you Close() it (or call Shutdown() with it).
+//
+// Example:
+//
+//	archive, err := filestore.ZipReader("exports/2022-09.zip")
+//	if err != nil {
+//	    // handle your error nicely
+//	}
+//	defer archive.Close(context.Background())
+//
+//	csvFiles, err := archive.List("reports", filestore.WithExt("csv"))
+func ZipReader(zipPath string) (*ZipFS, error) {
+	file, err := os.Open(zipPath)
+	if err != nil {
+		return nil, newPathError("zip", "open", zipPath, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, newPathError("zip", "open", zipPath, err)
+	}
+	archive, err := ZipReaderAt(file, info.Size())
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	archive.closer = file
+	return archive, nil
+}
+
+// ZipReaderAt presents the contents of a zip archive that's already available via an io.ReaderAt
+// (e.g. a file you've already opened, a bytes.Reader, or a ReaderFile from another store) as a
+// read-only file store. See ZipReader() for details.
+//
+// Example:
+//
+//	input, err := bucket.Read("exports/2022-09.zip")
+//	...
+//	info, err := bucket.Stat("exports/2022-09.zip")
+//	...
+//	archive, err := filestore.ZipReaderAt(input, info.Size())
+func ZipReaderAt(r io.ReaderAt, size int64) (*ZipFS, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, newPathError("zip", "open", "", err)
+	}
+
+	archive := &ZipFS{files: map[string]*zip.File{}, closeOnce: &sync.Once{}}
+	for _, file := range reader.File {
+		archive.files[path.Clean(file.Name)] = file
+	}
+	archive.ioFS = ioFS{fsys: reader, basePath: "/", kind: "zip", open: archive.open(r)}
+	return archive, nil
+}
+
+// ZipFS is a read-only file store whose files are the contents of a zip archive.
+type ZipFS struct {
+	ioFS
+	files  map[string]*zip.File
+	closer io.Closer
+	// closeOnce is shared w/ every store derived via ChangeDirectory(), since they all share the archive.
+	closeOnce *sync.Once
+}
+
+// ChangeDirectory returns a new FS rooted in the given subdirectory of the archive. It shares the archive
+// w/ this store, so closing either of them closes the archive for both.
+func (z *ZipFS) ChangeDirectory(dir string) FS {
+	sub := *z
+	sub.ioFS.basePath = joinPath(z.ioFS.basePath, dir)
+	return &sub
+}
+
+// open returns the function used to read files from the archive. Uncompressed files are read
+// straight from the archive, so seeking and ReadAt() are cheap. Compressed files can't be read
+// out of order, so we decompress them as you read them, starting over when you move backwards.
+func (z *ZipFS) open(r io.ReaderAt) func(name string) (ReaderFile, error) {
+	return func(name string) (ReaderFile, error) {
+		file, ok := z.files[name]
+		if !ok {
+			// Directories that only exist implicitly (no entry of their own); Read() rejects these anyway.
+			return z.ioFS.openFile(name)
+		}
+
+		if file.Method == zip.Store {
+			offset, err := file.DataOffset()
+			if err != nil {
+				return nil, err
+			}
+			return &sectionReaderFile{SectionReader: io.NewSectionReader(r, offset, int64(file.UncompressedSize64))}, nil
+		}
+
+		reader := &compressedReaderFile{open: file.Open, name: "zip", size: int64(file.UncompressedSize64)}
+		if err := reader.reset(); err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+}
+
+// Close releases the archive file if the store opened it (i.e. via ZipReader()).
+func (z *ZipFS) Close(_ context.Context) error {
+	var err error
+	z.closeOnce.Do(func() {
+		if z.closer != nil {
+			err = z.closer.Close()
+		}
+	})
+	return err
+}
+
+// sectionReaderFile is a ReaderFile for a section of some larger io.ReaderAt.
+type sectionReaderFile struct {
+	*io.SectionReader
+}
+
+// Close does nothing; the underlying io.ReaderAt is owned by whoever created the section.
+func (r *sectionReaderFile) Close() error {
+	return nil
+}
+
+var _ FS = &ZipFS{}
+var _ Closer = &ZipFS{}
@@ -0,0 +1,205 @@
+package filestore_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/monadicstack/filestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ZipTestSuite struct {
+	suite.Suite
+	data []byte
+	fs   *filestore.ZipFS
+}
+
+func TestZipTestSuite(t *testing.T) {
+	suite.Run(t, &ZipTestSuite{})
+}
+
+func (s *ZipTestSuite) SetupTest() {
+	buf := &bytes.Buffer{}
+	writer := zip.NewWriter(buf)
+	s.addFile(writer, "1.lebowski", zip.Deflate, "the dude abides")
+	s.addFile(writer, "2.lebowski", zip.Store, "walter sobchak")
+	s.addFile(writer, "duderino/", zip.Store, "")
+	s.addFile(writer, "duderino/5.lebowski", zip.Deflate, "jackie")
+	s.addFile(writer, "implicit/inner/6.lebowski", zip.Store, "nihilist")
+	s.Require().NoError(writer.Close())
+	s.data = buf.Bytes()
+
+	var err error
+	s.fs, err = filestore.ZipReaderAt(bytes.NewReader(s.data), int64(len(s.data)))
+	s.Require().NoError(err)
+}
+
+func (s *ZipTestSuite) addFile(writer *zip.Writer, name string, method uint16, content string) {
+	file, err := writer.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+	s.Require().NoError(err)
+	_, err = io.WriteString(file, content)
+	s.Require().NoError(err)
+}
+
+func (s *ZipTestSuite) TestStat() {
+	info, err := s.fs.Stat("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("1.lebowski", info.Name())
+	s.Require().Equal(int64(15), info.Size())
+	s.Require().False(info.IsDir())
+
+	info, err = s.fs.Stat("duderino")
+	s.Require().NoError(err)
+	s.Require().True(info.IsDir())
+
+	info, err = s.fs.Stat("implicit/inner")
+	s.Require().NoError(err, "Directories w/o their own entries should still exist")
+	s.Require().True(info.IsDir())
+
+	info, err = s.fs.Stat(".")
+	s.Require().NoError(err)
+	s.Require().True(info.IsDir())
+
+	_, err = s.fs.Stat("nope.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	s.Require().True(s.fs.Exists("duderino/5.lebowski"))
+	s.Require().False(s.fs.Exists("duderino/nope.lebowski"))
+}
+
+func (s *ZipTestSuite) TestRead() {
+	for name, expected := range map[string]string{
+		"1.lebowski":                "the dude abides",
+		"2.lebowski":                "walter sobchak",
+		"duderino/5.lebowski":       "jackie",
+		"implicit/inner/6.lebowski": "nihilist",
+	} {
+		content, err := readString(s.fs, name)
+		s.Require().NoError(err)
+		s.Require().Equal(expected, content)
+	}
+
+	content, err := readString(s.fs.ChangeDirectory("implicit"), "inner/6.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("nihilist", content)
+
+	_, err = s.fs.Read("duderino")
+	s.Require().Error(err, "Should not be able to read directories")
+	_, err = s.fs.Read("nope.txt")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+}
+
+// Both compressed and uncompressed files should support random access.
+func (s *ZipTestSuite) TestRead_seek() {
+	for _, name := range []string{"1.lebowski", "2.lebowski"} {
+		file, err := s.fs.Read(name)
+		s.Require().NoError(err)
+
+		buf := make([]byte, 4)
+		_, err = file.ReadAt(buf, 4)
+		s.Require().NoError(err)
+
+		_, err = file.Seek(-3, io.SeekEnd)
+		s.Require().NoError(err)
+		rest, err := io.ReadAll(file)
+		s.Require().NoError(err)
+		s.Require().Equal(3, len(rest))
+		s.Require().NoError(file.Close())
+	}
+}
+
+func (s *ZipTestSuite) TestRead_largeCompressed() {
+	buf := &bytes.Buffer{}
+	writer := zip.NewWriter(buf)
+	content := strings.Repeat("the dude abides. ", 1<<16)
+	s.addFile(writer, "big.txt", zip.Deflate, content)
+	s.Require().NoError(writer.Close())
+	archive, err := filestore.ZipReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	s.Require().NoError(err)
+
+	file, err := archive.Read("big.txt")
+	s.Require().NoError(err)
+	defer file.Close()
+
+	position, err := file.Seek(-8, io.SeekEnd)
+	s.Require().NoError(err)
+	s.Require().Equal(int64(len(content)-8), position)
+	rest, err := io.ReadAll(file)
+	s.Require().NoError(err)
+	s.Require().Equal("abides. ", string(rest))
+
+	head := make([]byte, 8)
+	_, err = file.ReadAt(head, 4)
+	s.Require().NoError(err, "Should be able to go back to the beginning")
+	s.Require().Equal("dude abi", string(head))
+}
+
+func (s *ZipTestSuite) TestList() {
+	infos, err := s.fs.List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(4, len(infos))
+	s.Require().Equal("1.lebowski", infos[0].Name())
+	s.Require().Equal("2.lebowski", infos[1].Name())
+	s.Require().Equal("duderino", infos[2].Name())
+	s.Require().True(infos[2].IsDir())
+	s.Require().Equal("implicit", infos[3].Name())
+	s.Require().True(infos[3].IsDir())
+
+	infos, err = s.fs.List(".", filestore.WithPrefix("1"))
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(infos))
+
+	infos, err = s.fs.ChangeDirectory("implicit").List("inner")
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(infos))
+	s.Require().Equal("6.lebowski", infos[0].Name())
+
+	infos, err = s.fs.List("nope")
+	s.Require().NoError(err)
+	s.Require().Empty(infos)
+
+	_, err = s.fs.List("1.lebowski")
+	s.Require().Error(err, "Listing a file should fail")
+}
+
+func (s *ZipTestSuite) TestReadOnly() {
+	_, err := s.fs.Write("3.lebowski")
+	s.Require().True(errors.Is(err, filestore.ErrReadOnly))
+	s.Require().True(errors.Is(s.fs.Remove("1.lebowski"), filestore.ErrReadOnly))
+	s.Require().True(errors.Is(s.fs.Move("1.lebowski", "3.lebowski"), filestore.ErrReadOnly))
+}
+
+func (s *ZipTestSuite) TestZipReader() {
+	zipPath := filepath.Join(s.T().TempDir(), "lebowski.zip")
+	s.Require().NoError(os.WriteFile(zipPath, s.data, 0644))
+
+	archive, err := filestore.ZipReader(zipPath)
+	s.Require().NoError(err)
+	content, err := readString(archive, "duderino/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal("jackie", content)
+	s.Require().NoError(filestore.Shutdown(context.Background(), archive))
+	s.Require().NoError(archive.Close(context.Background()), "Closing twice should be harmless")
+
+	// Subdirectories share the archive, so they can close it, too.
+	archive, err = filestore.ZipReader(zipPath)
+	s.Require().NoError(err)
+	sub := archive.ChangeDirectory("duderino")
+	s.Require().Implements((*filestore.Closer)(nil), sub)
+	s.Require().NoError(filestore.Shutdown(context.Background(), sub))
+	_, err = readString(archive, "1.lebowski")
+	s.Require().Error(err, "Closing the subdirectory should close the whole archive")
+
+	_, err = filestore.ZipReader(filepath.Join(s.T().TempDir(), "nope.zip"))
+	s.Require().Error(err)
+
+	_, err = filestore.ZipReader("testdata/hello.txt")
+	s.Require().Error(err, "Should reject files that aren't zip archives")
+}
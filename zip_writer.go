@@ -0,0 +1,291 @@
+package filestore
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ZipWriter creates a write-only file store that builds a zip archive, streaming it to the given
+// writer. Every file you Write() becomes an entry in the archive once you close the WriterFile, so
+// code that exports files to Disk() or S3() can just as easily export them to a .zip file. You must
+// Close() the store (or call Shutdown() with it) once you're done to finalize the archive; this does
+// not close the underlying writer.
+//
+// Each file is buffered in memory until you close it, so you can WriteAt()/Seek() just like any other
+// store and have several files open at once. Entries appear in the archive in the order that you close
+// the files. Stat(), Exists(), and List() describe the entries written so far, but you can't read,
+// move, or remove entries, and writing the same file twice fails with fs.ErrExist. You can supply the
+// WithClock() option to control the modification times of the entries.
+//
+// Example:
+//
+//	output, err := os.Create("export.zip")
+//	...
+//	archive := filestore.ZipWriter(output)
+//	err = exportReports(archive) // calls archive.Write("reports/summary.csv"), etc.
+//	...
+//	err = archive.Close(ctx)
+func ZipWriter(w io.Writer, opts ...Option) *ZipWriterFS {
+	options := newOptions(opts)
+	return &ZipWriterFS{
+		archive: &zipArchive{
+			writer:  zip.NewWriter(w),
+			clock:   options.clock,
+			entries: map[string]zipEntryInfo{},
+			open:    map[string]bool{},
+		},
+		basePath: "/",
+	}
+}
+
+// ZipWriterFS is a write-only file store that builds a zip archive.
+type ZipWriterFS struct {
+	archive  *zipArchive
+	basePath string
+}
+
+// zipArchive is the archive being built. It's shared by the original store and any instances derived
+// from it via ChangeDirectory().
+type zipArchive struct {
+	mutex   sync.Mutex
+	writer  *zip.Writer
+	clock   Clock
+	entries map[string]zipEntryInfo
+	open    map[string]bool
+	closed  bool
+}
+
+// key converts a path relative to this FS' working directory into the name of the zip entry. The root
+// of the archive is the empty key.
+func (z ZipWriterFS) key(filePath string) (string, error) {
+	fullPath, err := resolvePath(z.basePath, filePath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(fullPath, "/"), nil
+}
+
+// WorkingDirectory returns the current FS context's path/directory.
+func (z ZipWriterFS) WorkingDirectory() string {
+	return path.Clean(z.basePath)
+}
+
+// ChangeDirectory returns a new FS that is rooted in the given subdirectory of this FS. Files written
+// to it are added to the same archive.
+func (z ZipWriterFS) ChangeDirectory(dir string) FS {
+	return &ZipWriterFS{archive: z.archive, basePath: joinPath(z.basePath, dir)}
+}
+
+// Stat describes an entry that has already been written to the archive. Directories exist as long as
+// there are entries inside of them.
+func (z ZipWriterFS) Stat(filePath string) (FileInfo, error) {
+	key, err := z.key(filePath)
+	if err != nil {
+		return nil, newPathError("zip", "stat", filePath, err)
+	}
+
+	z.archive.mutex.Lock()
+	defer z.archive.mutex.Unlock()
+
+	if key == "" {
+		return zipEntryInfo{name: "/", dir: true}, nil
+	}
+	if info, ok := z.archive.entries[key]; ok {
+		return info, nil
+	}
+	for entryKey := range z.archive.entries {
+		if strings.HasPrefix(entryKey, key+"/") {
+			return zipEntryInfo{name: path.Base(key), dir: true}, nil
+		}
+	}
+	return nil, newPathError("zip", "stat", filePath, fs.ErrNotExist)
+}
+
+// Exists returns true when the entry (or a directory containing entries) has already been written.
+func (z ZipWriterFS) Exists(filePath string) bool {
+	_, err := z.Stat(filePath)
+	return err == nil
+}
+
+// Read always fails; once data has been added to the archive, it's only available to whoever reads
+// the finished archive.
+func (z ZipWriterFS) Read(filePath string) (ReaderFile, error) {
+	return nil, newPathError("zip", "open", filePath, errors.New("can not read from an archive being written"))
+}
+
+// Write opens a new entry in the archive. Nothing is added to the archive until you close the file.
+func (z ZipWriterFS) Write(filePath string) (WriterFile, error) {
+	key, err := z.key(filePath)
+	if err != nil {
+		return nil, newPathError("zip", "write", filePath, err)
+	}
+	if key == "" {
+		return nil, newPathError("zip", "write", filePath, errIsDirectory)
+	}
+
+	z.archive.mutex.Lock()
+	defer z.archive.mutex.Unlock()
+
+	if z.archive.closed {
+		return nil, newPathError("zip", "write", filePath, fs.ErrClosed)
+	}
+	if _, exists := z.archive.entries[key]; exists || z.archive.open[key] {
+		return nil, newPathError("zip", "write", filePath, fs.ErrExist)
+	}
+	z.archive.open[key] = true
+	return newZipWriterFile(z.archive, key), nil
+}
+
+// List performs the equivalent of the "ls" command, returning the entries (and implicit directories)
+// written to the given directory so far.
+func (z ZipWriterFS) List(dirPath string, filters ...FileFilter) ([]FileInfo, error) {
+	key, err := z.key(dirPath)
+	if err != nil {
+		return nil, newPathError("zip", "list files", dirPath, err)
+	}
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+
+	z.archive.mutex.Lock()
+	if _, isFile := z.archive.entries[key]; isFile {
+		z.archive.mutex.Unlock()
+		return nil, newPathError("zip", "list files", dirPath, ErrNotDirectory)
+	}
+	var infos []FileInfo
+	dirs := map[string]bool{}
+	for entryKey, info := range z.archive.entries {
+		if !strings.HasPrefix(entryKey, prefix) {
+			continue
+		}
+		name := entryKey[len(prefix):]
+		if dir, _, nested := strings.Cut(name, "/"); nested {
+			dirs[dir] = true
+			continue
+		}
+		infos = append(infos, info)
+	}
+	z.archive.mutex.Unlock()
+
+	for name := range dirs {
+		infos = append(infos, zipEntryInfo{name: name, dir: true})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	results, err := filterFiles(infos, filters)
+	if err != nil {
+		return nil, newPathError("zip", "list files", dirPath, err)
+	}
+	return results, nil
+}
+
+// Remove always fails since you can't take entries back out of the archive.
+func (z ZipWriterFS) Remove(fileOrDirPath string) error {
+	return newPathError("zip", "remove", fileOrDirPath, errors.New("can not remove entries from an archive being written"))
+}
+
+// Move always fails since you can't rename entries that have already been written.
+func (z ZipWriterFS) Move(fromPath string, _ string) error {
+	return newPathError("zip", "move", fromPath, errors.New("can not move entries in an archive being written"))
+}
+
+// Close finalizes the archive by writing its central directory. It fails if any files are still
+// open since their contents would be missing from the archive. Closing more than once does nothing.
+func (z ZipWriterFS) Close(_ context.Context) error {
+	z.archive.mutex.Lock()
+	defer z.archive.mutex.Unlock()
+
+	if z.archive.closed {
+		return nil
+	}
+	if len(z.archive.open) > 0 {
+		return newPathError("zip", "close", "", fmt.Errorf("%d file(s) still open", len(z.archive.open)))
+	}
+	z.archive.closed = true
+	if err := z.archive.writer.Close(); err != nil {
+		return newPathError("zip", "close", "", err)
+	}
+	return nil
+}
+
+// zipWriterFile buffers a single entry's data until it's closed and added to the archive.
+type zipWriterFile struct {
+	*bufferWriter
+	archive *zipArchive
+	key     string
+}
+
+func newZipWriterFile(archive *zipArchive, key string) *zipWriterFile {
+	file := &zipWriterFile{archive: archive, key: key}
+	file.bufferWriter = newBufferWriter("zip", file.addEntry)
+	return file
+}
+
+// addEntry adds the entry to the archive.
+func (w *zipWriterFile) addEntry(data []byte) error {
+	w.archive.mutex.Lock()
+	defer w.archive.mutex.Unlock()
+
+	delete(w.archive.open, w.key)
+	if w.archive.closed {
+		return fmt.Errorf("zip fs: close: %s: archive already closed", w.key)
+	}
+
+	modTime := w.archive.clock.Now()
+	entry, err := w.archive.writer.CreateHeader(&zip.FileHeader{Name: w.key, Method: zip.Deflate, Modified: modTime})
+	if err != nil {
+		return fmt.Errorf("zip fs: close: %w", err)
+	}
+	if _, err = entry.Write(data); err != nil {
+		return fmt.Errorf("zip fs: close: %w", err)
+	}
+	w.archive.entries[w.key] = zipEntryInfo{name: path.Base(w.key), size: int64(len(data)), modTime: modTime}
+	return nil
+}
+
+// zipEntryInfo describes an entry that has been written to the archive (or an implicit directory).
+type zipEntryInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (info zipEntryInfo) Name() string {
+	return info.name
+}
+
+func (info zipEntryInfo) Size() int64 {
+	return info.size
+}
+
+func (info zipEntryInfo) Mode() fs.FileMode {
+	if info.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (info zipEntryInfo) ModTime() time.Time {
+	return info.modTime
+}
+
+func (info zipEntryInfo) IsDir() bool {
+	return info.dir
+}
+
+func (info zipEntryInfo) Sys() any {
+	return nil
+}
+
+var _ FS = ZipWriterFS{}
+var _ Closer = ZipWriterFS{}
@@ -0,0 +1,143 @@
+package filestore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/monadicstack/filestore"
+	"github.com/monadicstack/filestore/filestoretest"
+	"github.com/stretchr/testify/suite"
+)
+
+type ZipWriterTestSuite struct {
+	suite.Suite
+	output *bytes.Buffer
+	clock  *filestoretest.Clock
+	fs     *filestore.ZipWriterFS
+}
+
+func TestZipWriterTestSuite(t *testing.T) {
+	suite.Run(t, &ZipWriterTestSuite{})
+}
+
+func (s *ZipWriterTestSuite) SetupTest() {
+	s.output = &bytes.Buffer{}
+	s.clock = filestoretest.NewClock(time.Date(2022, 9, 6, 12, 0, 0, 0, time.UTC))
+	s.fs = filestore.ZipWriter(s.output, filestore.WithClock(s.clock))
+}
+
+// archive finalizes the zip we've been writing and opens it back up for reading.
+func (s *ZipWriterTestSuite) archive() *filestore.ZipFS {
+	s.Require().NoError(filestore.Shutdown(context.Background(), s.fs))
+	archive, err := filestore.ZipReaderAt(bytes.NewReader(s.output.Bytes()), int64(s.output.Len()))
+	s.Require().NoError(err)
+	return archive
+}
+
+func (s *ZipWriterTestSuite) TestWrite() {
+	s.Require().NoError(writeString(s.fs, "1.lebowski", "the dude abides"))
+	s.Require().NoError(writeString(s.fs.ChangeDirectory("duderino"), "5.lebowski", "jackie"))
+
+	file, err := s.fs.Write("2.lebowski")
+	s.Require().NoError(err)
+	_, err = file.Write([]byte("walter"))
+	s.Require().NoError(err)
+	_, err = file.WriteAt([]byte("W"), 0)
+	s.Require().NoError(err, "Should support random access like any other store")
+	s.Require().NoError(file.Close())
+
+	archive := s.archive()
+	for name, expected := range map[string]string{
+		"1.lebowski":          "the dude abides",
+		"2.lebowski":          "Walter",
+		"duderino/5.lebowski": "jackie",
+	} {
+		content, err := readString(archive, name)
+		s.Require().NoError(err)
+		s.Require().Equal(expected, content)
+	}
+
+	info, err := archive.Stat("1.lebowski")
+	s.Require().NoError(err)
+	s.Require().True(info.ModTime().Equal(s.clock.Now()))
+}
+
+func (s *ZipWriterTestSuite) TestWrite_errors() {
+	s.Require().NoError(writeString(s.fs, "1.lebowski", "jeff"))
+
+	_, err := s.fs.Write("1.lebowski")
+	s.Require().True(errors.Is(err, fs.ErrExist), "Should not be able to write the same entry twice")
+	_, err = s.fs.Write(".")
+	s.Require().Error(err)
+
+	open, err := s.fs.Write("2.lebowski")
+	s.Require().NoError(err)
+	_, err = s.fs.Write("2.lebowski")
+	s.Require().True(errors.Is(err, fs.ErrExist))
+	s.Require().Error(s.fs.Close(context.Background()), "Should not finalize while files are still open")
+
+	s.Require().NoError(open.Close())
+	s.Require().NoError(s.fs.Close(context.Background()))
+	_, err = s.fs.Write("3.lebowski")
+	s.Require().True(errors.Is(err, fs.ErrClosed), "Should not write to a finalized archive")
+
+	_, err = s.fs.Read("1.lebowski")
+	s.Require().Error(err)
+	s.Require().Error(s.fs.Remove("1.lebowski"))
+	s.Require().Error(s.fs.Move("1.lebowski", "3.lebowski"))
+}
+
+func (s *ZipWriterTestSuite) TestStat() {
+	s.Require().NoError(writeString(s.fs, "duderino/inner/5.lebowski", "jackie"))
+
+	info, err := s.fs.Stat("duderino/inner/5.lebowski")
+	s.Require().NoError(err)
+	s.Require().Equal(int64(6), info.Size())
+	s.Require().False(info.IsDir())
+
+	info, err = s.fs.Stat("duderino/inner")
+	s.Require().NoError(err)
+	s.Require().True(info.IsDir())
+
+	_, err = s.fs.Stat("duderino/nope")
+	s.Require().True(errors.Is(err, fs.ErrNotExist))
+	s.Require().True(s.fs.Exists("duderino"))
+	s.Require().False(s.fs.Exists("dude"))
+}
+
+func (s *ZipWriterTestSuite) TestList() {
+	s.Require().NoError(writeString(s.fs, "1.lebowski", "jeff"))
+	s.Require().NoError(writeString(s.fs, "duderino/5.lebowski", "jackie"))
+	s.Require().NoError(writeString(s.fs, "duderino/inner/6.lebowski", "nihilist"))
+
+	infos, err := s.fs.List(".")
+	s.Require().NoError(err)
+	s.Require().Equal(2, len(infos))
+	s.Require().Equal("1.lebowski", infos[0].Name())
+	s.Require().Equal("duderino", infos[1].Name())
+	s.Require().True(infos[1].IsDir())
+
+	infos, err = s.fs.List("duderino", filestore.WithExt("lebowski"))
+	s.Require().NoError(err)
+	s.Require().Equal(1, len(infos))
+	s.Require().Equal("5.lebowski", infos[0].Name())
+
+	_, err = s.fs.List("1.lebowski")
+	s.Require().Error(err)
+}
+
+// Exporting to a zip should work just like exporting to any other store.
+func (s *ZipWriterTestSuite) TestCopyAll() {
+	src := filestore.Memory()
+	s.Require().NoError(writeString(src, "reports/summary.csv", "a,b,c"))
+	s.Require().NoError(writeString(src, "reports/2022/09.csv", "1,2,3"))
+
+	s.Require().NoError(filestore.CopyAll(src, "reports", s.fs, "export"))
+	content, err := readString(s.archive(), "export/2022/09.csv")
+	s.Require().NoError(err)
+	s.Require().Equal("1,2,3", content)
+}